@@ -94,6 +94,11 @@ func TaskResource(orgID ID) resource {
 	return resource(fmt.Sprintf("org/%s/task", orgID))
 }
 
+// QueryResource represents the running queries resource scoped to an organization.
+func QueryResource(orgID ID) resource {
+	return resource(fmt.Sprintf("org/%s/query", orgID))
+}
+
 // BucketResource constructs a bucket resource.
 func BucketResource(id ID) resource {
 	return resource(fmt.Sprintf("bucket/%s", id))
@@ -137,3 +142,19 @@ func WriteBucketPermission(id ID) Permission {
 		Resource: BucketResource(id),
 	}
 }
+
+// ReadQueryPermission constructs a permission for listing the running queries of an organization.
+func ReadQueryPermission(orgID ID) Permission {
+	return Permission{
+		Action:   ReadAction,
+		Resource: QueryResource(orgID),
+	}
+}
+
+// DeleteQueryPermission constructs a permission for canceling the running queries of an organization.
+func DeleteQueryPermission(orgID ID) Permission {
+	return Permission{
+		Action:   DeleteAction,
+		Resource: QueryResource(orgID),
+	}
+}
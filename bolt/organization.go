@@ -267,6 +267,10 @@ func (c *Client) updateOrganization(ctx context.Context, tx *bolt.Tx, id platfor
 		o.Name = *upd.Name
 	}
 
+	if upd.QueryQuotas != nil {
+		o.QueryQuotas = upd.QueryQuotas
+	}
+
 	if err := c.putOrganization(ctx, tx, o); err != nil {
 		return nil, err
 	}
@@ -8,16 +8,19 @@ import (
 	"os"
 	"runtime"
 	"strings"
+	"time"
 
 	influxlogger "github.com/influxdata/influxdb/logger"
 	"github.com/EMCECS/influx"
 	"github.com/EMCECS/influx/http"
 	"github.com/EMCECS/influx/kit/prom"
+	"github.com/EMCECS/influx/nats"
 	"github.com/EMCECS/influx/query"
 	_ "github.com/EMCECS/influx/query/builtin"
 	"github.com/EMCECS/influx/query/control"
 	"github.com/EMCECS/influx/query/execute"
 	"github.com/EMCECS/influx/query/functions"
+	_ "github.com/EMCECS/influx/query/functions/sqldrivers"
 	"github.com/EMCECS/influx/query/functions/storage"
 	"github.com/EMCECS/influx/query/functions/storage/pb"
 	"github.com/EMCECS/influx/snowflake"
@@ -47,6 +50,16 @@ var (
 	bindAddr         string
 	concurrencyQuota int
 	memoryBytesQuota int
+	spillDir         string
+	queryTimeout     time.Duration
+	maxQueryTimeout  time.Duration
+	queueTimeout     time.Duration
+	liveInterval     time.Duration
+	maxLiveQueries   int
+	queryCacheBytes  int64
+	natsURL          string
+	natsClientID     string
+	sqlDataSources   []string
 )
 
 func init() {
@@ -68,6 +81,46 @@ func init() {
 	viper.BindEnv("MEM_BYTES")
 	viper.BindPFlag("mem_bytes", fluxdCmd.PersistentFlags().Lookup("mem-bytes"))
 
+	fluxdCmd.PersistentFlags().StringVar(&spillDir, "spill-dir", "", "The directory where queries spill buffered tables as they near their memory quota, queries do not spill when empty.")
+	viper.BindEnv("SPILL_DIR")
+	viper.BindPFlag("spill_dir", fluxdCmd.PersistentFlags().Lookup("spill-dir"))
+
+	fluxdCmd.PersistentFlags().DurationVar(&queryTimeout, "query-timeout", 0, "The default maximum duration queries execute for, queries do not time out when zero.")
+	viper.BindEnv("QUERY_TIMEOUT")
+	viper.BindPFlag("query_timeout", fluxdCmd.PersistentFlags().Lookup("query-timeout"))
+
+	fluxdCmd.PersistentFlags().DurationVar(&maxQueryTimeout, "max-query-timeout", 0, "The maximum timeout queries may request, longer timeouts are shortened to it, the default query timeout when zero.")
+	viper.BindEnv("MAX_QUERY_TIMEOUT")
+	viper.BindPFlag("max_query_timeout", fluxdCmd.PersistentFlags().Lookup("max-query-timeout"))
+
+	fluxdCmd.PersistentFlags().DurationVar(&queueTimeout, "queue-timeout", 0, "The maximum duration queries wait in the queue before executing, queries wait until resources are available when zero.")
+	viper.BindEnv("QUEUE_TIMEOUT")
+	viper.BindPFlag("queue_timeout", fluxdCmd.PersistentFlags().Lookup("queue-timeout"))
+
+	fluxdCmd.PersistentFlags().DurationVar(&liveInterval, "live-interval", execute.DefaultLiveInterval, "The interval at which live queries read new data when no write is notified.")
+	viper.BindEnv("LIVE_INTERVAL")
+	viper.BindPFlag("live_interval", fluxdCmd.PersistentFlags().Lookup("live-interval"))
+
+	fluxdCmd.PersistentFlags().IntVar(&maxLiveQueries, "max-live-queries", 100, "The maximum number of live queries that run at once, live queries over it are rejected. Live queries are not limited when zero.")
+	viper.BindEnv("MAX_LIVE_QUERIES")
+	viper.BindPFlag("max_live_queries", fluxdCmd.PersistentFlags().Lookup("max-live-queries"))
+
+	fluxdCmd.PersistentFlags().Int64Var(&queryCacheBytes, "query-cache-bytes", 0, "The maximum size of the cached query results. Results are not cached when zero or without a NATS server, whose write notifications invalidate them.")
+	viper.BindEnv("QUERY_CACHE_BYTES")
+	viper.BindPFlag("query_cache_bytes", fluxdCmd.PersistentFlags().Lookup("query-cache-bytes"))
+
+	fluxdCmd.PersistentFlags().StringVar(&natsURL, "nats-url", "", "The URL of the NATS server of influxd, whose write notifications wake up live queries. Live queries only read new data every live interval when empty.")
+	viper.BindEnv("NATS_URL")
+	viper.BindPFlag("nats_url", fluxdCmd.PersistentFlags().Lookup("nats-url"))
+
+	fluxdCmd.PersistentFlags().StringVar(&natsClientID, "nats-client-id", "fluxd", "The client ID of this daemon on the NATS server, it must be unique to each fluxd.")
+	viper.BindEnv("NATS_CLIENT_ID")
+	viper.BindPFlag("nats_client_id", fluxdCmd.PersistentFlags().Lookup("nats-client-id"))
+
+	fluxdCmd.PersistentFlags().StringArrayVar(&sqlDataSources, "sql-data-source", nil, "A driver=dataSourceName pair that fromSQL and toSQL may connect to, a dataSourceName of * allows any database of the driver. fromSQL and toSQL are disabled when empty.")
+	viper.BindEnv("SQL_DATA_SOURCE")
+	viper.BindPFlag("sql_data_source", fluxdCmd.PersistentFlags().Lookup("sql-data-source"))
+
 	fluxdCmd.PersistentFlags().String("storage-hosts", "localhost:8082", "host:port address of the storage server.")
 	viper.BindEnv("STORAGE_HOSTS")
 	viper.BindPFlag("STORAGE_HOSTS", fluxdCmd.PersistentFlags().Lookup("storage-hosts"))
@@ -93,10 +146,59 @@ func fluxF(cmd *cobra.Command, args []string) {
 	reg.MustRegister(prometheus.NewGoCollector())
 	reg.WithLogger(logger)
 
+	orgName, err := getStrList("ORGANIZATION_NAME")
+	if err != nil {
+		logger.Error("failed to get organization name", zap.Error(err))
+	}
+
+	// writeNotifier notifies live queries of the data written to influxd.
+	writeNotifier := execute.NewWriteNotifier()
+	// queryCache caches the results of queries, it is invalidated by the data written to the buckets they read.
+	var queryCache *control.Cache
+	if queryCacheBytes > 0 {
+		if natsURL != "" {
+			queryCache = control.NewCache(queryCacheBytes)
+		} else {
+			logger.Warn("query results are not cached without a NATS server")
+		}
+	}
+	if natsURL != "" {
+		subscriber := &nats.QueueSubscriber{
+			ClientID: natsClientID,
+			URL:      natsURL,
+		}
+		if err := subscriber.Open(); err != nil {
+			logger.Error("failed to connect to streaming server", zap.Error(err))
+			os.Exit(1)
+		}
+		// Every fluxd subscribes with its own group, so that each of them is notified of every write.
+		if err := subscriber.Subscribe(nats.WriteSubject, natsClientID, &nats.WriteHandler{
+			OnWrite: func(n nats.WriteNotification) {
+				// The queries of fluxd read the buckets of its organization as the static organization.
+				if queryCache != nil && n.OrganizationName == orgName[0] {
+					queryCache.Invalidate(staticOrgID, &platform.Bucket{ID: n.BucketID, Name: n.BucketName}, n.Min, n.Max)
+				}
+				writeNotifier.Notify()
+			},
+			Logger: logger.With(zap.String("handler", "writes")),
+		}); err != nil {
+			logger.Error("failed to create nats subscriber", zap.Error(err))
+			os.Exit(1)
+		}
+	}
+
 	config := control.Config{
 		ExecutorDependencies: make(execute.Dependencies),
 		ConcurrencyQuota:     concurrencyQuota,
 		MemoryBytesQuota:     int64(memoryBytesQuota),
+		SpillDir:             spillDir,
+		ExecuteTimeout:       queryTimeout,
+		MaxExecuteTimeout:    maxQueryTimeout,
+		QueueTimeout:         queueTimeout,
+		LiveInterval:         liveInterval,
+		WriteNotifier:        writeNotifier,
+		MaxLiveQueries:       maxLiveQueries,
+		Cache:                queryCache,
 		Logger:               logger,
 		Verbose:              viper.GetBool("verbose"),
 	}
@@ -107,10 +209,6 @@ func fluxF(cmd *cobra.Command, args []string) {
 	c := control.New(config)
 	reg.MustRegister(c.PrometheusCollectors()...)
 
-	orgName, err := getStrList("ORGANIZATION_NAME")
-	if err != nil {
-		logger.Error("failed to get organization name", zap.Error(err))
-	}
 	orgSvc := &StaticOrganizationService{Name: orgName[0]}
 
 	queryHandler := http.NewExternalQueryHandler()
@@ -161,11 +259,29 @@ func injectDeps(deps execute.Dependencies) error {
 	}
 	orgSvc := StaticOrganizationService{Name: orgName[0]}
 
-	return functions.InjectFromDependencies(deps, storage.Dependencies{
+	if err := functions.InjectFromDependencies(deps, storage.Dependencies{
 		Reader:             sr,
 		BucketLookup:       bucketLookup{},
 		OrganizationLookup: query.FromOrganizationService(&orgSvc),
-	})
+	}); err != nil {
+		return err
+	}
+
+	if len(sqlDataSources) == 0 {
+		return nil
+	}
+	sqlDeps := functions.SQLDependencies{
+		DataSources: make(map[string][]string),
+	}
+	for _, ds := range sqlDataSources {
+		i := strings.IndexByte(ds, '=')
+		if i <= 0 {
+			return fmt.Errorf("invalid sql data source %q, expected driver=dataSourceName", ds)
+		}
+		driver := ds[:i]
+		sqlDeps.DataSources[driver] = append(sqlDeps.DataSources[driver], ds[i+1:])
+	}
+	return functions.InjectSQLDependencies(deps, sqlDeps)
 }
 
 func main() {
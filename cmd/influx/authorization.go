@@ -29,6 +29,9 @@ type AuthorizationCreateFlags struct {
 
 	readBucketPermissions  []string
 	writeBucketPermissions []string
+
+	readQueryPermissions   []string
+	deleteQueryPermissions []string
 }
 
 var authorizationCreateFlags AuthorizationCreateFlags
@@ -49,6 +52,9 @@ func init() {
 	authorizationCreateCmd.Flags().StringArrayVarP(&authorizationCreateFlags.readBucketPermissions, "read-bucket", "", []string{}, "bucket id")
 	authorizationCreateCmd.Flags().StringArrayVarP(&authorizationCreateFlags.writeBucketPermissions, "write-bucket", "", []string{}, "bucket id")
 
+	authorizationCreateCmd.Flags().StringArrayVarP(&authorizationCreateFlags.readQueryPermissions, "read-queries", "", []string{}, "id of the organization whose running queries can be listed")
+	authorizationCreateCmd.Flags().StringArrayVarP(&authorizationCreateFlags.deleteQueryPermissions, "delete-queries", "", []string{}, "id of the organization whose running queries can be canceled")
+
 	authorizationCmd.AddCommand(authorizationCreateCmd)
 }
 
@@ -77,6 +83,22 @@ func authorizationCreateF(cmd *cobra.Command, args []string) {
 		}
 		permissions = append(permissions, platform.ReadBucketPermission(id))
 	}
+	for _, p := range authorizationCreateFlags.readQueryPermissions {
+		var id platform.ID
+		if err := id.DecodeFromString(p); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		permissions = append(permissions, platform.ReadQueryPermission(id))
+	}
+	for _, p := range authorizationCreateFlags.deleteQueryPermissions {
+		var id platform.ID
+		if err := id.DecodeFromString(p); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		permissions = append(permissions, platform.DeleteQueryPermission(id))
+	}
 
 	authorization := &platform.Authorization{
 		User:        authorizationCreateFlags.user,
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/EMCECS/influx/query/ast"
+	"github.com/EMCECS/influx/query/parser"
+	"github.com/spf13/cobra"
+)
+
+var fmtCmd = &cobra.Command{
+	Use:   "fmt [/path/to/query.flux ...]",
+	Short: "Format Flux source code",
+	Long: `Format Flux source code using the canonical Flux style.
+		The formatted source is printed to stdout unless -w is given.
+		If no files are given the source is read from stdin.`,
+	Run: fmtF,
+}
+
+var fmtFlags struct {
+	Write bool
+	Check bool
+}
+
+func init() {
+	fmtCmd.Flags().BoolVarP(&fmtFlags.Write, "write", "w", false, "write the formatted source back to the file instead of stdout")
+	fmtCmd.Flags().BoolVar(&fmtFlags.Check, "check", false, "list files whose formatting differs and exit with a non-zero status")
+}
+
+func fmtF(cmd *cobra.Command, args []string) {
+	if fmtFlags.Write && fmtFlags.Check {
+		fmt.Fprintln(os.Stderr, "must specify at most one of --write or --check")
+		os.Exit(1)
+	}
+
+	if len(args) == 0 {
+		if fmtFlags.Write {
+			fmt.Fprintln(os.Stderr, "cannot use --write with stdin")
+			os.Exit(1)
+		}
+		src, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		formatted, err := formatFlux(string(src))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if fmtFlags.Check {
+			if formatted != string(src) {
+				fmt.Println("<stdin>")
+				os.Exit(1)
+			}
+			return
+		}
+		fmt.Print(formatted)
+		return
+	}
+
+	unformatted := false
+	for _, file := range args {
+		changed, err := formatFile(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", file, err)
+			os.Exit(1)
+		}
+		if changed && fmtFlags.Check {
+			fmt.Println(file)
+			unformatted = true
+		}
+	}
+	if unformatted {
+		os.Exit(1)
+	}
+}
+
+// formatFile formats a single file according to the fmt flags
+// and reports whether its formatting differs from the canonical formatting.
+func formatFile(file string) (bool, error) {
+	src, err := ioutil.ReadFile(file)
+	if err != nil {
+		return false, err
+	}
+	formatted, err := formatFlux(string(src))
+	if err != nil {
+		return false, err
+	}
+	changed := formatted != string(src)
+
+	switch {
+	case fmtFlags.Check:
+	case fmtFlags.Write:
+		if changed {
+			info, err := os.Stat(file)
+			if err != nil {
+				return false, err
+			}
+			if err := ioutil.WriteFile(file, []byte(formatted), info.Mode()); err != nil {
+				return false, err
+			}
+		}
+	default:
+		fmt.Print(formatted)
+	}
+	return changed, nil
+}
+
+func formatFlux(src string) (string, error) {
+	program, err := parser.NewAST(src)
+	if err != nil {
+		return "", err
+	}
+	return ast.FormatWithComments(program, src), nil
+}
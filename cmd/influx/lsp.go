@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	_ "github.com/EMCECS/influx/query/builtin"
+	"github.com/EMCECS/influx/query/lsp"
+	"github.com/spf13/cobra"
+)
+
+var lspCmd = &cobra.Command{
+	Use:   "lsp",
+	Short: "Start a Flux language server",
+	Long: `Start a Flux language server that speaks the Language Server Protocol over stdin and stdout.
+		Editors can use it to provide diagnostics, completion, hover, go to definition and formatting for Flux.`,
+	Args: cobra.NoArgs,
+	Run:  lspF,
+}
+
+func lspF(cmd *cobra.Command, args []string) {
+	if err := lsp.NewServer(os.Stdin, os.Stdout).Serve(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -21,8 +21,11 @@ var influxCmd = &cobra.Command{
 func init() {
 	influxCmd.AddCommand(authorizationCmd)
 	influxCmd.AddCommand(bucketCmd)
+	influxCmd.AddCommand(fmtCmd)
+	influxCmd.AddCommand(lspCmd)
 	influxCmd.AddCommand(replCmd)
 	influxCmd.AddCommand(queryCmd)
+	influxCmd.AddCommand(testCmd)
 	influxCmd.AddCommand(organizationCmd)
 	influxCmd.AddCommand(userCmd)
 }
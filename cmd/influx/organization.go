@@ -134,6 +134,10 @@ func organizationFindF(cmd *cobra.Command, args []string) {
 type OrganizationUpdateFlags struct {
 	id   string
 	name string
+
+	queryConcurrency int
+	queryMemoryBytes int64
+	queryWeight      int
 }
 
 var organizationUpdateFlags OrganizationUpdateFlags
@@ -147,6 +151,9 @@ func init() {
 
 	organizationUpdateCmd.Flags().StringVarP(&organizationUpdateFlags.id, "id", "i", "", "organization ID (required)")
 	organizationUpdateCmd.Flags().StringVarP(&organizationUpdateFlags.name, "name", "n", "", "organization name")
+	organizationUpdateCmd.Flags().IntVarP(&organizationUpdateFlags.queryConcurrency, "query-concurrency", "", 0, "number of concurrency workers the queries of the organization may use at once, unlimited if 0")
+	organizationUpdateCmd.Flags().Int64VarP(&organizationUpdateFlags.queryMemoryBytes, "query-memory-bytes", "", 0, "number of bytes of memory the queries of the organization may use at once, unlimited if 0")
+	organizationUpdateCmd.Flags().IntVarP(&organizationUpdateFlags.queryWeight, "query-weight", "", 0, "share of the query resources given to the organization relative to other organizations, 1 if 0")
 	organizationUpdateCmd.MarkFlagRequired("id")
 
 	organizationCmd.AddCommand(organizationUpdateCmd)
@@ -170,20 +177,55 @@ func organizationUpdateF(cmd *cobra.Command, args []string) {
 		update.Name = &organizationUpdateFlags.name
 	}
 
+	changed := cmd.Flags().Changed
+	if changed("query-concurrency") || changed("query-memory-bytes") || changed("query-weight") {
+		// The quotas that are not set are left unchanged.
+		o, err := s.FindOrganizationByID(context.Background(), id)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		quotas := platform.QueryQuotas{}
+		if o.QueryQuotas != nil {
+			quotas = *o.QueryQuotas
+		}
+		if changed("query-concurrency") {
+			quotas.ConcurrencyQuota = organizationUpdateFlags.queryConcurrency
+		}
+		if changed("query-memory-bytes") {
+			quotas.MemoryBytesQuota = organizationUpdateFlags.queryMemoryBytes
+		}
+		if changed("query-weight") {
+			quotas.Weight = organizationUpdateFlags.queryWeight
+		}
+		update.QueryQuotas = &quotas
+	}
+
 	o, err := s.UpdateOrganization(context.Background(), id, update)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 
+	quotas := platform.QueryQuotas{}
+	if o.QueryQuotas != nil {
+		quotas = *o.QueryQuotas
+	}
+
 	w := internal.NewTabWriter(os.Stdout)
 	w.WriteHeaders(
 		"ID",
 		"Name",
+		"QueryConcurrency",
+		"QueryMemoryBytes",
+		"QueryWeight",
 	)
 	w.Write(map[string]interface{}{
-		"ID":   o.ID.String(),
-		"Name": o.Name,
+		"ID":               o.ID.String(),
+		"Name":             o.Name,
+		"QueryConcurrency": quotas.ConcurrencyQuota,
+		"QueryMemoryBytes": quotas.MemoryBytesQuota,
+		"QueryWeight":      quotas.Weight,
 	})
 	w.Flush()
 }
@@ -1,18 +1,26 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/EMCECS/influx"
+	"github.com/EMCECS/influx/cmd/influx/internal"
 	"github.com/EMCECS/influx/http"
 	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/arrow"
 	_ "github.com/EMCECS/influx/query/builtin"
+	"github.com/EMCECS/influx/query/csv"
 	"github.com/EMCECS/influx/query/execute"
 	"github.com/EMCECS/influx/query/functions"
 	"github.com/EMCECS/influx/query/functions/storage"
 	"github.com/EMCECS/influx/query/functions/storage/pb"
+	"github.com/EMCECS/influx/query/json"
+	"github.com/EMCECS/influx/query/lineprotocol"
+	"github.com/EMCECS/influx/query/plan"
 	"github.com/EMCECS/influx/query/repl"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -31,6 +39,9 @@ var queryFlags struct {
 	StorageHosts string
 	OrgID        string
 	Verbose      bool
+	Explain      string
+	Format       string
+	TagColumns   string
 }
 
 func init() {
@@ -51,6 +62,12 @@ func init() {
 	if h := viper.GetString("ORG_ID"); h != "" {
 		queryFlags.OrgID = h
 	}
+
+	queryCmd.PersistentFlags().StringVar(&queryFlags.Explain, "explain", "", "Print the plan of the query in the given format (text, json or dot) instead of executing it")
+	queryCmd.PersistentFlags().Lookup("explain").NoOptDefVal = string(plan.ExplainText)
+
+	queryCmd.PersistentFlags().StringVar(&queryFlags.Format, "format", "table", "Print the results in the given format (table, csv, json, arrow or lp)")
+	queryCmd.PersistentFlags().StringVar(&queryFlags.TagColumns, "tag-columns", "", "Comma-separated list of the columns written as tags in the lp format, the group key columns by default")
 }
 
 func fluxQueryF(cmd *cobra.Command, args []string) {
@@ -60,6 +77,35 @@ func fluxQueryF(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
+	var encoder query.MultiResultEncoder
+	switch queryFlags.Format {
+	case "table":
+	case csv.DialectType:
+		encoder = csv.DefaultDialect().Encoder()
+	case json.DialectType:
+		encoder = json.NewMultiResultEncoder()
+	case arrow.DialectType:
+		encoder = arrow.NewMultiResultEncoder()
+	case lineprotocol.DialectType:
+		var c lineprotocol.ResultEncoderConfig
+		if queryFlags.TagColumns != "" {
+			c.TagColumns = strings.Split(queryFlags.TagColumns, ",")
+		}
+		encoder = lineprotocol.NewMultiResultEncoder(c)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown format %q, expected table, csv, json, arrow or lp\n", queryFlags.Format)
+		os.Exit(1)
+	}
+
+	var explain plan.ExplainFormat
+	if queryFlags.Explain != "" {
+		explain, err = plan.ParseExplainFormat(queryFlags.Explain)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
 	hosts, err := storageHostReader(strings.Split(queryFlags.StorageHosts, ","))
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
@@ -90,7 +136,14 @@ func fluxQueryF(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	if err := r.Input(q); err != nil {
+	r.SetEncoder(encoder)
+
+	if explain != "" {
+		err = r.Explain(q, explain)
+	} else {
+		err = r.Input(q)
+	}
+	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
@@ -135,3 +188,123 @@ func orgID(org string) (platform.ID, error) {
 	err := oid.DecodeFromString(org)
 	return oid, err
 }
+
+// List Running Queries Command
+func init() {
+	queryLsCmd := &cobra.Command{
+		Use:   "ls",
+		Short: "List running queries, only those of the organization if --org-id is set",
+		Args:  cobra.NoArgs,
+		Run:   queryLsF,
+	}
+
+	queryCmd.AddCommand(queryLsCmd)
+}
+
+func queryLsF(cmd *cobra.Command, args []string) {
+	s := &http.RunningQueryService{
+		Addr:  flags.host,
+		Token: flags.token,
+	}
+
+	var filter *platform.ID
+	if queryFlags.OrgID != "" {
+		id, err := orgID(queryFlags.OrgID)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		filter = &id
+	}
+
+	qs, err := s.FindRunningQueries(context.Background(), filter)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	w := internal.NewTabWriter(os.Stdout)
+	w.WriteHeaders(
+		"ID",
+		"OrganizationID",
+		"State",
+		"Elapsed",
+		"Allocated",
+		"Query",
+	)
+	for _, q := range qs {
+		w.Write(map[string]interface{}{
+			"ID":             q.ID,
+			"OrganizationID": q.OrganizationID.String(),
+			"State":          q.State,
+			"Elapsed":        q.Elapsed.Round(time.Millisecond),
+			"Allocated":      q.Allocated,
+			"Query":          singleLine(q.Query),
+		})
+	}
+	w.Flush()
+}
+
+// Kill Running Query Command
+var queryKillFlags struct {
+	id uint64
+}
+
+func init() {
+	queryKillCmd := &cobra.Command{
+		Use:   "kill",
+		Short: "Cancel a running query",
+		Args:  cobra.NoArgs,
+		Run:   queryKillF,
+	}
+
+	queryKillCmd.Flags().Uint64VarP(&queryKillFlags.id, "id", "i", 0, "query id (required)")
+	queryKillCmd.MarkFlagRequired("id")
+
+	queryCmd.AddCommand(queryKillCmd)
+}
+
+func queryKillF(cmd *cobra.Command, args []string) {
+	s := &http.RunningQueryService{
+		Addr:  flags.host,
+		Token: flags.token,
+	}
+
+	ctx := context.Background()
+	q, err := s.FindRunningQueryByID(ctx, queryKillFlags.id)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if err := s.CancelRunningQuery(ctx, queryKillFlags.id); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	w := internal.NewTabWriter(os.Stdout)
+	w.WriteHeaders(
+		"ID",
+		"OrganizationID",
+		"State",
+		"Elapsed",
+		"Allocated",
+		"Query",
+		"Canceled",
+	)
+	w.Write(map[string]interface{}{
+		"ID":             q.ID,
+		"OrganizationID": q.OrganizationID.String(),
+		"State":          q.State,
+		"Elapsed":        q.Elapsed.Round(time.Millisecond),
+		"Allocated":      q.Allocated,
+		"Query":          singleLine(q.Query),
+		"Canceled":       true,
+	})
+	w.Flush()
+}
+
+// singleLine returns the text of a query on a single line, so it can be listed.
+func singleLine(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
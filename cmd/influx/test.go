@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+
+	"github.com/EMCECS/influx/query/control"
+	"github.com/EMCECS/influx/query/execute"
+	"github.com/EMCECS/influx/query/fluxtest"
+	"github.com/spf13/cobra"
+)
+
+var testCmd = &cobra.Command{
+	Use:   "test [/path/to/dir ...]",
+	Short: "Run Flux test files",
+	Long: `Run the Flux test files, files ending with _test.flux, found in the given paths.
+		Each assertEquals call in a test file is run as a test case.
+		If no paths are given the current directory is searched.`,
+	Run: testF,
+}
+
+var testFlags struct {
+	JUnit   string
+	Verbose bool
+}
+
+func init() {
+	testCmd.Flags().StringVar(&testFlags.JUnit, "junit", "", "write a JUnit XML report to the given file")
+	testCmd.Flags().BoolVarP(&testFlags.Verbose, "verbose", "v", false, "list passing test cases")
+}
+
+func testF(cmd *cobra.Command, args []string) {
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+	files, err := fluxtest.Discover(args...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "no test files found")
+		os.Exit(1)
+	}
+
+	r := &fluxtest.Runner{
+		Service: control.New(control.Config{
+			ExecutorDependencies: make(execute.Dependencies),
+			ConcurrencyQuota:     runtime.NumCPU(),
+			MemoryBytesQuota:     math.MaxInt64,
+		}),
+	}
+	results := r.Run(context.Background(), files)
+
+	if err := fluxtest.WriteText(os.Stdout, results, testFlags.Verbose); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if testFlags.JUnit != "" {
+		f, err := os.Create(testFlags.JUnit)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		err = fluxtest.WriteJUnit(f, results)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	for _, res := range results {
+		if !res.Passed() {
+			os.Exit(1)
+		}
+	}
+}
@@ -54,6 +54,10 @@ var (
 	authorizationPath string
 	boltPath          string
 	walPath           string
+	queryCacheBytes   int64
+	queryTimeout      time.Duration
+	queryMaxTimeout   time.Duration
+	queryQueueTimeout time.Duration
 )
 
 func influxDir() (string, error) {
@@ -108,6 +112,30 @@ func init() {
 	if h := viper.GetString("WAL_PATH"); h != "" {
 		walPath = h
 	}
+
+	platformCmd.Flags().Int64Var(&queryCacheBytes, "query-cache-bytes", 0, "maximum size of the cached query results, results are not cached when zero")
+	viper.BindEnv("QUERY_CACHE_BYTES")
+	if h := viper.GetInt64("QUERY_CACHE_BYTES"); h != 0 {
+		queryCacheBytes = h
+	}
+
+	platformCmd.Flags().DurationVar(&queryTimeout, "query-timeout", 0, "default maximum duration queries execute for, queries do not time out when zero")
+	viper.BindEnv("QUERY_TIMEOUT")
+	if h := viper.GetDuration("QUERY_TIMEOUT"); h != 0 {
+		queryTimeout = h
+	}
+
+	platformCmd.Flags().DurationVar(&queryMaxTimeout, "query-max-timeout", 0, "maximum timeout queries may request, longer timeouts are shortened to it, the default query timeout when zero")
+	viper.BindEnv("QUERY_MAX_TIMEOUT")
+	if h := viper.GetDuration("QUERY_MAX_TIMEOUT"); h != 0 {
+		queryMaxTimeout = h
+	}
+
+	platformCmd.Flags().DurationVar(&queryQueueTimeout, "query-queue-timeout", 0, "maximum duration queries wait in the queue before executing, queries wait until resources are available when zero")
+	viper.BindEnv("QUERY_QUEUE_TIMEOUT")
+	if h := viper.GetDuration("QUERY_QUEUE_TIMEOUT"); h != 0 {
+		queryQueueTimeout = h
+	}
 }
 
 var platformCmd = &cobra.Command{
@@ -168,7 +196,17 @@ func platformF(cmd *cobra.Command, args []string) {
 		sourceSvc = c
 	}
 
+	// writeNotifier notifies live queries of the data written to the ingress subject.
+	writeNotifier := execute.NewWriteNotifier()
+
+	// queryCache caches the results of queries, it is invalidated by the data written to the buckets they read.
+	var queryCache *control.Cache
+	if queryCacheBytes > 0 {
+		queryCache = control.NewCache(queryCacheBytes)
+	}
+
 	var queryService query.QueryService
+	var runningQuerySvc query.RunningQueryService
 	{
 		// TODO(lh): this is temporary until query endpoint is added here.
 		config := control.Config{
@@ -176,11 +214,19 @@ func platformF(cmd *cobra.Command, args []string) {
 			ConcurrencyQuota:     runtime.NumCPU() * 2,
 			MemoryBytesQuota:     0,
 			Verbose:              false,
+			WriteNotifier:        writeNotifier,
+			Cache:                queryCache,
+			OrganizationService:  orgSvc,
+			ExecuteTimeout:       queryTimeout,
+			MaxExecuteTimeout:    queryMaxTimeout,
+			QueueTimeout:         queryQueueTimeout,
 		}
 
+		ctrl := control.New(config)
 		queryService = query.QueryServiceBridge{
-			AsyncQueryService: control.New(config),
+			AsyncQueryService: ctrl,
 		}
+		runningQuerySvc = ctrl
 	}
 
 	var taskSvc platform.TaskService
@@ -225,14 +271,14 @@ func platformF(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	// TODO(jm): this is an example of using a subscriber to consume from the channel. It should be removed.
+	// The subscriber notifies live queries that data has been written.
 	subscriber := nats.NewQueueSubscriber("nats-subscriber")
 	if err := subscriber.Open(); err != nil {
 		logger.Error("failed to connect to streaming server", zap.Error(err))
 		os.Exit(1)
 	}
 
-	if err := subscriber.Subscribe(NatsSubject, IngressGroup, &nats.LogHandler{Logger: logger}); err != nil {
+	if err := subscriber.Subscribe(NatsSubject, IngressGroup, &nats.NotifyHandler{Notifier: writeNotifier}); err != nil {
 		logger.Error("failed to create nats subscriber", zap.Error(err))
 		os.Exit(1)
 	}
@@ -282,6 +328,26 @@ func platformF(cmd *cobra.Command, args []string) {
 		writeHandler.OrganizationService = orgSvc
 		writeHandler.BucketService = bucketSvc
 		writeHandler.Logger = logger.With(zap.String("handler", "write"))
+		writeHandler.OnWrite = func(org *platform.Organization, bucket *platform.Bucket, min, max time.Time) {
+			if queryCache != nil {
+				queryCache.Invalidate(org.ID, bucket, min, max)
+			}
+			// The live queries and caches of the processes that serve queries, such as fluxd, are notified of the write.
+			if err := nats.PublishWrite(publisher, nats.WriteNotification{
+				OrganizationID:   org.ID,
+				OrganizationName: org.Name,
+				BucketID:         bucket.ID,
+				BucketName:       bucket.Name,
+				Min:              min,
+				Max:              max,
+			}); err != nil {
+				logger.Info("Failed to publish write notification", zap.Error(err))
+			}
+		}
+
+		runningQueryHandler := http.NewRunningQueryHandler()
+		runningQueryHandler.AuthorizationService = authSvc
+		runningQueryHandler.RunningQueryService = runningQuerySvc
 
 		// TODO(desa): what to do about idpe.
 		chronografHandler := http.NewChronografHandler(chronografSvc)
@@ -299,6 +365,7 @@ func platformF(cmd *cobra.Command, args []string) {
 			TaskHandler:          taskHandler,
 			ViewHandler:          cellHandler,
 			WriteHandler:         writeHandler,
+			RunningQueryHandler:  runningQueryHandler,
 		}
 		reg.MustRegister(platformHandler.PrometheusCollectors()...)
 
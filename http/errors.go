@@ -113,6 +113,8 @@ func statusCode(e kerrors.Error) int {
 		return http.StatusForbidden
 	case kerrors.NotFound:
 		return http.StatusNotFound
+	case kerrors.Timeout:
+		return http.StatusGatewayTimeout
 	default:
 		return http.StatusInternalServerError
 	}
@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/EMCECS/influx/http"
+	kerrors "github.com/EMCECS/influx/kit/errors"
 )
 
 func TestEncodeError(t *testing.T) {
@@ -48,3 +49,20 @@ func TestEncodeErrorWithError(t *testing.T) {
 		t.Errorf("Expected a truncated X-Influx-Error header content: %s, got: %s", expected, errHeader)
 	}
 }
+
+func TestEncodeErrorWithTimeout(t *testing.T) {
+	ctx := context.TODO()
+	err := kerrors.Timeoutf("query timed out after executing for 1s")
+
+	w := httptest.NewRecorder()
+
+	http.EncodeError(ctx, err, w)
+
+	if w.Code != 504 {
+		t.Errorf("expected status code 504, got: %d", w.Code)
+	}
+
+	if ref := w.Header().Get("X-Influx-Reference"); ref != "6" {
+		t.Errorf("expected X-Influx-Reference: 6, got: %s", ref)
+	}
+}
@@ -6,18 +6,23 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"time"
 	"unicode/utf8"
 
 	"github.com/EMCECS/influx"
 	"github.com/EMCECS/influx/query"
+	fluxarrow "github.com/EMCECS/influx/query/arrow"
 	"github.com/EMCECS/influx/query/csv"
+	fluxjson "github.com/EMCECS/influx/query/json"
 	"github.com/julienschmidt/httprouter"
 	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
 )
 
 // ExternalQueryHandler implements the /query API endpoint defined in the swagger doc.
 // This only implements the POST method and only supports Spec or Flux queries.
+// Live queries are run over a WebSocket at /query/live.
 type ExternalQueryHandler struct {
 	*httprouter.Router
 
@@ -34,6 +39,7 @@ func NewExternalQueryHandler() *ExternalQueryHandler {
 	}
 
 	h.HandlerFunc("POST", "/query", h.handlePostQuery)
+	h.Handler("GET", "/query/live", websocket.Handler(h.handleLiveQuery))
 	return h
 }
 
@@ -42,6 +48,9 @@ func decodeQueryRequest(r *http.Request, req *query.ProxyRequest, orgSvc platfor
 		Spec    *query.Spec `json:"spec"`
 		Query   string      `json:"query"`
 		Type    string      `json:"type"`
+		Explain bool        `json:"explain"`
+		Profile bool        `json:"profile"`
+		Timeout string      `json:"timeout"`
 		Dialect struct {
 			Header         *bool    `json:"header"`
 			Delimiter      string   `json:"delimiter"`
@@ -127,6 +136,11 @@ func decodeQueryRequest(r *http.Request, req *query.ProxyRequest, orgSvc platfor
 		} else {
 			return errors.New(`request body requires either spec or query`)
 		}
+		req.Request.Explain = request.Explain
+		req.Request.Profile = request.Profile
+		if err := decodeQueryTimeout(request.Timeout, &req.Request); err != nil {
+			return err
+		}
 	default:
 		orgName := r.FormValue("organization")
 		if orgName == "" {
@@ -148,9 +162,16 @@ func decodeQueryRequest(r *http.Request, req *query.ProxyRequest, orgSvc platfor
 		req.Request.Compiler = query.FluxCompiler{
 			Query: q,
 		}
+		if err := decodeQueryTimeout(r.FormValue("timeout"), &req.Request); err != nil {
+			return err
+		}
 	}
 
 	switch r.Header.Get("Accept") {
+	case fluxjson.ContentType:
+		req.Dialect = fluxjson.Dialect{}
+	case fluxarrow.ContentType:
+		req.Dialect = fluxarrow.Dialect{}
 	case "text/csv":
 		fallthrough
 	default:
@@ -177,6 +198,22 @@ func decodeQueryRequest(r *http.Request, req *query.ProxyRequest, orgSvc platfor
 	return nil
 }
 
+// decodeQueryTimeout sets the timeout of the request from a duration such as "30s", if it is not empty.
+func decodeQueryTimeout(timeout string, req *query.Request) error {
+	if timeout == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(timeout)
+	if err != nil {
+		return fmt.Errorf("invalid timeout: %v", err)
+	}
+	if d <= 0 {
+		return fmt.Errorf("invalid timeout: must be positive")
+	}
+	req.Timeout = d
+	return nil
+}
+
 func (h *ExternalQueryHandler) handlePostQuery(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
@@ -193,7 +230,23 @@ func (h *ExternalQueryHandler) handlePostQuery(w http.ResponseWriter, r *http.Re
 	}
 	hd.SetHeaders(w)
 
-	n, err := h.ProxyQueryService.Query(ctx, w, &req)
+	// The statistics, with the profile of each operator of a profiled query,
+	// are written as a trailer like those of the QueryHandler.
+	sq, hasStats := h.ProxyQueryService.(query.ProxyQueryStatisticser)
+	if hasStats {
+		w.Header().Set("Trailer", statsTrailer)
+	}
+
+	var (
+		n     int64
+		stats query.Statistics
+		err   error
+	)
+	if hasStats {
+		n, stats, err = sq.QueryWithStatistics(ctx, w, &req)
+	} else {
+		n, err = h.ProxyQueryService.Query(ctx, w, &req)
+	}
 	if err != nil {
 		if n == 0 {
 			// Only record the error headers IFF nothing has been written to w.
@@ -205,6 +258,15 @@ func (h *ExternalQueryHandler) handlePostQuery(w http.ResponseWriter, r *http.Re
 			zap.Error(err),
 		)
 	}
+
+	if hasStats {
+		data, err := json.Marshal(stats)
+		if err != nil {
+			h.Logger.Info("Failed to encode statistics", zap.Error(err))
+			return
+		}
+		w.Header().Set(statsTrailer, string(data))
+	}
 }
 
 // PrometheusCollectors satisifies the prom.PrometheusCollector interface.
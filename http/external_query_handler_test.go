@@ -0,0 +1,180 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/EMCECS/influx/http"
+	kerrors "github.com/EMCECS/influx/kit/errors"
+	"github.com/EMCECS/influx/query"
+	"github.com/google/go-cmp/cmp"
+	"go.uber.org/zap"
+)
+
+// timeoutQueryService times out the queries whose timeout is shorter than its duration.
+type timeoutQueryService struct {
+	d time.Duration
+}
+
+func (s timeoutQueryService) Query(ctx context.Context, w io.Writer, req *query.ProxyRequest) (int64, error) {
+	if req.Request.Timeout != 0 && req.Request.Timeout < s.d {
+		return 0, kerrors.Timeoutf("query timed out after executing for %s", req.Request.Timeout)
+	}
+	return 0, nil
+}
+
+func TestExternalQueryHandler_Timeout(t *testing.T) {
+	testCases := []struct {
+		name        string
+		contentType string
+		url         string
+		body        string
+		code        int
+		reference   string
+	}{
+		{
+			name:        "no timeout",
+			contentType: "application/json",
+			url:         "/query?organization=myorg",
+			body:        `{"query":"from(bucket:\"telegraf\") |> range(start:-1m)"}`,
+			code:        200,
+		},
+		{
+			name:        "timeout longer than the query",
+			contentType: "application/json",
+			url:         "/query?organization=myorg",
+			body:        `{"query":"from(bucket:\"telegraf\") |> range(start:-1m)","timeout":"2s"}`,
+			code:        200,
+		},
+		{
+			name:        "timed out",
+			contentType: "application/json",
+			url:         "/query?organization=myorg",
+			body:        `{"query":"from(bucket:\"telegraf\") |> range(start:-1m)","timeout":"500ms"}`,
+			code:        504,
+			reference:   "6",
+		},
+		{
+			name:        "timed out form",
+			contentType: "application/x-www-form-urlencoded",
+			url:         "/query?organization=myorg&timeout=500ms",
+			body:        `query=from(bucket:"telegraf") |> range(start:-1m)`,
+			code:        504,
+			reference:   "6",
+		},
+		{
+			name:        "invalid timeout",
+			contentType: "application/json",
+			url:         "/query?organization=myorg",
+			body:        `{"query":"from(bucket:\"telegraf\") |> range(start:-1m)","timeout":"-1s"}`,
+			code:        500,
+			reference:   "1",
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			queryHandler := http.NewExternalQueryHandler()
+			queryHandler.Logger = zap.NewNop()
+			queryHandler.ProxyQueryService = timeoutQueryService{d: time.Second}
+			queryHandler.OrganizationService = organizationService{}
+			h := http.NewHandler("query")
+			h.Handler = queryHandler
+			server := httptest.NewServer(h)
+			defer server.Close()
+
+			resp, err := nethttp.Post(server.URL+tc.url, tc.contentType, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tc.code {
+				t.Fatalf("unexpected status code: got %d want %d: %s", resp.StatusCode, tc.code, resp.Header.Get(http.ErrorHeader))
+			}
+			if got := resp.Header.Get(http.ReferenceHeader); got != tc.reference {
+				t.Fatalf("unexpected reference code: got %q want %q", got, tc.reference)
+			}
+		})
+	}
+}
+
+// profileQueryService writes a result and reports a profile of the query when it is profiled.
+type profileQueryService struct{}
+
+func (s profileQueryService) Query(ctx context.Context, w io.Writer, req *query.ProxyRequest) (int64, error) {
+	n, _, err := s.QueryWithStatistics(ctx, w, req)
+	return n, err
+}
+
+func (profileQueryService) QueryWithStatistics(ctx context.Context, w io.Writer, req *query.ProxyRequest) (int64, query.Statistics, error) {
+	n, err := io.WriteString(w, "result\n")
+	stats := query.Statistics{Concurrency: 1}
+	if req.Request.Profile {
+		stats.Operators = []query.OperatorProfile{{ID: "from0", Kind: "from", TablesOut: 1, RowsOut: 2}}
+	}
+	return int64(n), stats, err
+}
+
+func TestExternalQueryHandler_Statistics(t *testing.T) {
+	testCases := []struct {
+		name string
+		body string
+		want query.Statistics
+	}{
+		{
+			name: "not profiled",
+			body: `{"query":"from(bucket:\"telegraf\") |> range(start:-1m)"}`,
+			want: query.Statistics{Concurrency: 1},
+		},
+		{
+			name: "profiled",
+			body: `{"query":"from(bucket:\"telegraf\") |> range(start:-1m)","profile":true}`,
+			want: query.Statistics{
+				Concurrency: 1,
+				Operators:   []query.OperatorProfile{{ID: "from0", Kind: "from", TablesOut: 1, RowsOut: 2}},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			queryHandler := http.NewExternalQueryHandler()
+			queryHandler.Logger = zap.NewNop()
+			queryHandler.ProxyQueryService = profileQueryService{}
+			queryHandler.OrganizationService = organizationService{}
+			h := http.NewHandler("query")
+			h.Handler = queryHandler
+			server := httptest.NewServer(h)
+			defer server.Close()
+
+			resp, err := nethttp.Post(server.URL+"/query?organization=myorg", "application/json", strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != nethttp.StatusOK {
+				t.Fatalf("unexpected status code: got %d want %d: %s", resp.StatusCode, nethttp.StatusOK, resp.Header.Get(http.ErrorHeader))
+			}
+			// The trailers are read along with the end of the body.
+			if _, err := ioutil.ReadAll(resp.Body); err != nil {
+				t.Fatal(err)
+			}
+			var got query.Statistics
+			if err := json.Unmarshal([]byte(resp.Trailer.Get("Influx-Query-Statistics")), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !cmp.Equal(tc.want, got) {
+				t.Errorf("unexpected statistics -want/+got\n%s", cmp.Diff(tc.want, got))
+			}
+		})
+	}
+}
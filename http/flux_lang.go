@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/EMCECS/influx/query/ast"
 	"github.com/EMCECS/influx/query/complete"
 	"github.com/EMCECS/influx/query/parser"
 	"github.com/julienschmidt/httprouter"
@@ -18,6 +19,17 @@ type astRequest struct {
 	Body string `json:"body"`
 }
 
+// formatRequest contains either the flux source or the flux AST to format.
+type formatRequest struct {
+	Body string       `json:"body"`
+	AST  *ast.Program `json:"ast,omitempty"`
+}
+
+// formatResponse contains the canonically formatted flux source.
+type formatResponse struct {
+	Body string `json:"body"`
+}
+
 // NewFluxLangHandler returns a new instance of FluxLangHandler.
 func NewFluxLangHandler() *FluxLangHandler {
 	h := &FluxLangHandler{
@@ -26,6 +38,7 @@ func NewFluxLangHandler() *FluxLangHandler {
 
 	h.HandlerFunc("GET", "/v2/flux", h.getFlux)
 	h.HandlerFunc("POST", "/v2/flux/ast", h.postFluxAST)
+	h.HandlerFunc("POST", "/v2/flux/format", h.postFluxFormat)
 	h.HandlerFunc("GET", "/v2/flux/suggestions", h.getFluxSuggestions)
 	h.HandlerFunc("GET", "/v2/flux/suggestions/:name", h.getFluxSuggestion)
 	return h
@@ -49,6 +62,7 @@ type fluxLinks struct {
 	Self        string `json:"self"`        // Self link mapping to this resource
 	Suggestions string `json:"suggestions"` // URL for flux builder function suggestions
 	AST         string `json:"ast"`         // URL for flux ast
+	Format      string `json:"format"`      // URL for flux source formatting
 }
 
 type fluxResponse struct {
@@ -59,6 +73,7 @@ var getFluxResponse = fluxResponse{
 	Links: fluxLinks{
 		Self:        "/v2/flux",
 		AST:         "/v2/flux/ast",
+		Format:      "/v2/flux/format",
 		Suggestions: "/v2/flux/suggestions",
 	},
 }
@@ -95,6 +110,36 @@ func (h *FluxLangHandler) postFluxAST(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// postFluxFormat returns the canonical flux source for the provided flux string or AST.
+// Comments are preserved when formatting a flux string.
+func (h *FluxLangHandler) postFluxFormat(w http.ResponseWriter, r *http.Request) {
+	var request formatRequest
+	ctx := r.Context()
+
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	var res formatResponse
+	if request.AST != nil {
+		res.Body = ast.Format(request.AST)
+	} else {
+		program, err := parser.NewAST(request.Body)
+		if err != nil {
+			EncodeError(ctx, err, w)
+			return
+		}
+		res.Body = ast.FormatWithComments(program, request.Body)
+	}
+
+	if err := encodeResponse(ctx, w, http.StatusOK, res); err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+}
+
 // getFluxSuggestions returns a list of available Flux functions for the Flux Builder
 func (h *FluxLangHandler) getFluxSuggestions(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -0,0 +1,82 @@
+package http
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/EMCECS/influx/query"
+	fluxjson "github.com/EMCECS/influx/query/json"
+	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
+)
+
+// handleLiveQuery runs a live query over a WebSocket.
+// The client sends the query as the first message, with the same JSON body as the POST method,
+// then each table of the results is sent as a JSON message as it is triggered.
+// The query runs until the stop of its bounds, or until the client closes the connection.
+func (h *ExternalQueryHandler) handleLiveQuery(ws *websocket.Conn) {
+	defer ws.Close()
+	r := ws.Request()
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	var body string
+	if err := websocket.Message.Receive(ws, &body); err != nil {
+		h.Logger.Info("Error receiving live query", zap.Error(err))
+		return
+	}
+
+	var req query.ProxyRequest
+	if err := decodeQueryRequest(liveQueryRequest(r, body), &req, h.OrganizationService); err != nil {
+		h.sendLiveError(ws, err)
+		return
+	}
+	req.Request.Live = true
+	req.Dialect = liveDialect{}
+
+	// The client sends no other message, the query is canceled once the connection is closed.
+	go func() {
+		defer cancel()
+		var msg string
+		for websocket.Message.Receive(ws, &msg) == nil {
+		}
+	}()
+
+	n, err := h.ProxyQueryService.Query(ctx, ws, &req)
+	if err != nil {
+		if n == 0 {
+			h.sendLiveError(ws, err)
+			return
+		}
+		h.Logger.Info("Error writing live query response to client",
+			zap.String("handler", "live"),
+			zap.Error(err),
+		)
+	}
+}
+
+func (h *ExternalQueryHandler) sendLiveError(ws *websocket.Conn, err error) {
+	if werr := fluxjson.NewResultEncoder().EncodeError(ws, err); werr != nil {
+		h.Logger.Info("Error writing live query error to client", zap.Error(werr))
+	}
+}
+
+// liveQueryRequest returns the request of a live query, whose JSON body is the first message of the WebSocket.
+func liveQueryRequest(r *http.Request, body string) *http.Request {
+	lr := r.WithContext(r.Context())
+	lr.Header = http.Header{"Content-Type": []string{"application/json"}}
+	lr.Body = ioutil.NopCloser(strings.NewReader(body))
+	return lr
+}
+
+// liveDialect sends each table of the results of a live query as a JSON message.
+type liveDialect struct{}
+
+func (d liveDialect) Encoder() query.MultiResultEncoder {
+	return fluxjson.NewLiveMultiResultEncoder()
+}
+func (d liveDialect) DialectType() query.DialectType {
+	return fluxjson.DialectType
+}
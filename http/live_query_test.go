@@ -0,0 +1,143 @@
+package http_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/EMCECS/influx"
+	"github.com/EMCECS/influx/http"
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/execute/executetest"
+	"github.com/google/go-cmp/cmp"
+	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
+)
+
+type organizationService struct {
+	platform.OrganizationService
+}
+
+func (s organizationService) FindOrganization(ctx context.Context, filter platform.OrganizationFilter) (*platform.Organization, error) {
+	if filter.Name == nil || *filter.Name != "myorg" {
+		return nil, errors.New("organization not found")
+	}
+	return &platform.Organization{Name: "myorg"}, nil
+}
+
+// liveQueryService encodes the tables of its result with the dialect of the request, if the request is live.
+type liveQueryService struct {
+	tables []*executetest.Table
+}
+
+func (s liveQueryService) Query(ctx context.Context, w io.Writer, req *query.ProxyRequest) (int64, error) {
+	if !req.Request.Live {
+		return 0, errors.New("query is not live")
+	}
+	results := query.NewSliceResultIterator([]query.Result{
+		&executetest.Result{Nm: "_result", Tbls: s.tables},
+	})
+	return req.Dialect.Encoder().Encode(w, results)
+}
+
+func TestExternalQueryHandler_LiveQuery(t *testing.T) {
+	tables := []*executetest.Table{
+		{
+			KeyCols: []string{"host"},
+			ColMeta: []query.ColMeta{
+				{Label: "host", Type: query.TString},
+				{Label: "_value", Type: query.TFloat},
+			},
+			Data: [][]interface{}{
+				{"A", 1.5},
+			},
+		},
+		{
+			KeyCols: []string{"host"},
+			ColMeta: []query.ColMeta{
+				{Label: "host", Type: query.TString},
+				{Label: "_value", Type: query.TFloat},
+			},
+			Data: [][]interface{}{
+				{"B", 2.5},
+			},
+		},
+	}
+	testCases := []struct {
+		name     string
+		org      string
+		body     string
+		messages []string
+	}{
+		{
+			name: "tables",
+			org:  "myorg",
+			body: `{"query":"from(bucket:\"telegraf\") |> range(start:-1m)"}`,
+			messages: []string{
+				`{"result":"_result","tables":[{"table":0,"key":{"host":"A"},"columns":[{"label":"host","datatype":"string","group":true},{"label":"_value","datatype":"double","group":false}],"data":[["A",1.5]]}]}
+`,
+				`{"result":"_result","tables":[{"table":1,"key":{"host":"B"},"columns":[{"label":"host","datatype":"string","group":true},{"label":"_value","datatype":"double","group":false}],"data":[["B",2.5]]}]}
+`,
+			},
+		},
+		{
+			name: "unknown organization",
+			org:  "otherorg",
+			body: `{"query":"from(bucket:\"telegraf\") |> range(start:-1m)"}`,
+			messages: []string{
+				`{"error":"organization not found"}
+`,
+			},
+		},
+		{
+			name: "missing query",
+			org:  "myorg",
+			body: `{}`,
+			messages: []string{
+				`{"error":"request body requires either spec or query"}
+`,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			queryHandler := http.NewExternalQueryHandler()
+			queryHandler.Logger = zap.NewNop()
+			queryHandler.ProxyQueryService = liveQueryService{tables: tables}
+			queryHandler.OrganizationService = organizationService{}
+			h := http.NewHandler("query")
+			h.Handler = queryHandler
+			server := httptest.NewServer(h)
+			defer server.Close()
+
+			url := "ws" + strings.TrimPrefix(server.URL, "http") + "/query/live?organization=" + tc.org
+			ws, err := websocket.Dial(url, "", server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ws.Close()
+			if err := websocket.Message.Send(ws, tc.body); err != nil {
+				t.Fatal(err)
+			}
+
+			var messages []string
+			for {
+				var msg string
+				if err := websocket.Message.Receive(ws, &msg); err != nil {
+					if err != io.EOF {
+						t.Fatal(err)
+					}
+					break
+				}
+				messages = append(messages, msg)
+			}
+			if !cmp.Equal(tc.messages, messages) {
+				t.Errorf("unexpected messages -want/+got:\n%s", cmp.Diff(tc.messages, messages))
+			}
+		})
+	}
+}
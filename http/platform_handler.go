@@ -23,6 +23,7 @@ type PlatformHandler struct {
 	TaskHandler          *TaskHandler
 	FluxLangHandler      *FluxLangHandler
 	WriteHandler         *WriteHandler
+	RunningQueryHandler  *RunningQueryHandler
 }
 
 func setCORSResponseHeaders(w nethttp.ResponseWriter, r *nethttp.Request) {
@@ -36,9 +37,11 @@ func setCORSResponseHeaders(w nethttp.ResponseWriter, r *nethttp.Request) {
 var platformLinks = map[string]interface{}{
 	"sources":    "/v2/sources",
 	"dashboards": "/v2/dashboards",
+	"queries":    "/v2/queries",
 	"flux": map[string]string{
 		"self":        "/v2/flux",
 		"ast":         "/v2/flux/ast",
+		"format":      "/v2/flux/format",
 		"suggestions": "/v2/flux/suggestions",
 	},
 	"external": map[string]string{
@@ -134,6 +137,11 @@ func (h *PlatformHandler) ServeHTTP(w nethttp.ResponseWriter, r *nethttp.Request
 		return
 	}
 
+	if strings.HasPrefix(r.URL.Path, "/v2/queries") {
+		h.RunningQueryHandler.ServeHTTP(w, r)
+		return
+	}
+
 	if strings.HasPrefix(r.URL.Path, "/v2/views") {
 		h.ViewHandler.ServeHTTP(w, r)
 		return
@@ -4,10 +4,13 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"mime"
 	"net/http"
 
 	"github.com/EMCECS/influx/query"
+	fluxarrow "github.com/EMCECS/influx/query/arrow"
 	"github.com/EMCECS/influx/query/csv"
+	fluxjson "github.com/EMCECS/influx/query/json"
 	"github.com/julienschmidt/httprouter"
 	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
@@ -24,7 +27,9 @@ type QueryHandler struct {
 
 	Logger *zap.Logger
 
-	csvDialect csv.Dialect
+	csvDialect   csv.Dialect
+	jsonDialect  fluxjson.Dialect
+	arrowDialect fluxarrow.Dialect
 
 	QueryService     query.QueryService
 	CompilerMappings query.CompilerMappings
@@ -83,22 +88,29 @@ func (h *QueryHandler) handlePostQuery(w http.ResponseWriter, r *http.Request) {
 	// As such we rely on the http.ResponseWriter behavior
 	// to write an StatusOK header with the first write.
 
+	var encoder query.MultiResultEncoder
 	switch r.Header.Get("Accept") {
+	case fluxjson.ContentType:
+		h.jsonDialect.SetHeaders(w)
+		encoder = h.jsonDialect.Encoder()
+	case fluxarrow.ContentType:
+		h.arrowDialect.SetHeaders(w)
+		encoder = h.arrowDialect.Encoder()
 	case "text/csv":
 		fallthrough
 	default:
 		h.csvDialect.SetHeaders(w)
-		encoder := h.csvDialect.Encoder()
-		n, err := encoder.Encode(w, results)
-		if err != nil {
-			if n == 0 {
-				// If the encoder did not write anything, we can write an error header.
-				EncodeError(ctx, err, w)
-			} else {
-				h.Logger.Info("Failed to encode client response",
-					zap.Error(err),
-				)
-			}
+		encoder = h.csvDialect.Encoder()
+	}
+	n, err := encoder.Encode(w, results)
+	if err != nil {
+		if n == 0 {
+			// If the encoder did not write anything, we can write an error header.
+			EncodeError(ctx, err, w)
+		} else {
+			h.Logger.Info("Failed to encode client response",
+				zap.Error(err),
+			)
 		}
 	}
 
@@ -123,6 +135,8 @@ type QueryService struct {
 	Addr               string
 	Token              string
 	InsecureSkipVerify bool
+	// Accept is the media type of the results requested from the server, text/csv if empty.
+	Accept string
 }
 
 func (s *QueryService) Query(ctx context.Context, req *query.Request) (query.ResultIterator, error) {
@@ -140,6 +154,9 @@ func (s *QueryService) Query(ctx context.Context, req *query.Request) (query.Res
 		return nil, err
 	}
 	SetToken(s.Token, hreq)
+	if s.Accept != "" {
+		hreq.Header.Set("Accept", s.Accept)
+	}
 	hreq = hreq.WithContext(ctx)
 
 	hc := newClient(u.Scheme, s.InsecureSkipVerify)
@@ -152,7 +169,12 @@ func (s *QueryService) Query(ctx context.Context, req *query.Request) (query.Res
 	}
 
 	var decoder query.MultiResultDecoder
-	switch resp.Header.Get("Content-Type") {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch mediaType {
+	case fluxjson.ContentType:
+		decoder = fluxjson.NewMultiResultDecoder()
+	case fluxarrow.ContentType:
+		decoder = fluxarrow.NewMultiResultDecoder()
 	case "text/csv":
 		fallthrough
 	default:
@@ -0,0 +1,294 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/EMCECS/influx"
+	pcontext "github.com/EMCECS/influx/context"
+	kerrors "github.com/EMCECS/influx/kit/errors"
+	"github.com/EMCECS/influx/query"
+	"github.com/julienschmidt/httprouter"
+)
+
+// RunningQueryHandler represents an HTTP API handler for running queries.
+// Queries are only listed and canceled for the organizations the token of the request has permissions on.
+type RunningQueryHandler struct {
+	*httprouter.Router
+
+	AuthorizationService platform.AuthorizationService
+	RunningQueryService  query.RunningQueryService
+}
+
+// NewRunningQueryHandler returns a new instance of RunningQueryHandler.
+func NewRunningQueryHandler() *RunningQueryHandler {
+	h := &RunningQueryHandler{
+		Router: httprouter.New(),
+	}
+
+	h.HandlerFunc("GET", runningQueryPath, h.handleGetRunningQueries)
+	h.HandlerFunc("GET", "/v2/queries/:id", h.handleGetRunningQuery)
+	h.HandlerFunc("DELETE", "/v2/queries/:id", h.handleDeleteRunningQuery)
+	return h
+}
+
+// handleGetRunningQueries is the HTTP handler for the GET /v2/queries route.
+func (h *RunningQueryHandler) handleGetRunningQueries(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	req, err := decodeGetRunningQueriesRequest(ctx, r)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	auth, err := h.findAuthorization(ctx)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	if req.OrganizationID != nil && !platform.Allowed(platform.ReadQueryPermission(*req.OrganizationID), auth) {
+		EncodeError(ctx, kerrors.Forbiddenf("insufficient permissions to read queries"), w)
+		return
+	}
+
+	qs, err := h.RunningQueryService.FindRunningQueries(ctx, req.OrganizationID)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	allowed := make([]*query.RunningQuery, 0, len(qs))
+	for _, q := range qs {
+		if platform.Allowed(platform.ReadQueryPermission(q.OrganizationID), auth) {
+			allowed = append(allowed, q)
+		}
+	}
+
+	if err := encodeResponse(ctx, w, http.StatusOK, allowed); err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+}
+
+type getRunningQueriesRequest struct {
+	OrganizationID *platform.ID
+}
+
+func decodeGetRunningQueriesRequest(ctx context.Context, r *http.Request) (*getRunningQueriesRequest, error) {
+	req := &getRunningQueriesRequest{}
+	if id := r.URL.Query().Get("orgID"); id != "" {
+		req.OrganizationID = &platform.ID{}
+		if err := req.OrganizationID.DecodeFromString(id); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+// handleGetRunningQuery is the HTTP handler for the GET /v2/queries/:id route.
+func (h *RunningQueryHandler) handleGetRunningQuery(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := decodeRunningQueryID(ctx)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	auth, err := h.findAuthorization(ctx)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	q, err := h.RunningQueryService.FindRunningQueryByID(ctx, id)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	if !platform.Allowed(platform.ReadQueryPermission(q.OrganizationID), auth) {
+		EncodeError(ctx, kerrors.Forbiddenf("insufficient permissions to read query"), w)
+		return
+	}
+
+	if err := encodeResponse(ctx, w, http.StatusOK, q); err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+}
+
+// handleDeleteRunningQuery is the HTTP handler for the DELETE /v2/queries/:id route.
+func (h *RunningQueryHandler) handleDeleteRunningQuery(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := decodeRunningQueryID(ctx)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	auth, err := h.findAuthorization(ctx)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	q, err := h.RunningQueryService.FindRunningQueryByID(ctx, id)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	if !platform.Allowed(platform.DeleteQueryPermission(q.OrganizationID), auth) {
+		EncodeError(ctx, kerrors.Forbiddenf("insufficient permissions to cancel query"), w)
+		return
+	}
+
+	if err := h.RunningQueryService.CancelRunningQuery(ctx, id); err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func decodeRunningQueryID(ctx context.Context) (uint64, error) {
+	params := httprouter.ParamsFromContext(ctx)
+	id := params.ByName("id")
+	if id == "" {
+		return 0, kerrors.InvalidDataf("url missing id")
+	}
+
+	i, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return 0, kerrors.InvalidDataf("invalid query id %q", id)
+	}
+	return i, nil
+}
+
+// findAuthorization returns the authorization of the token of the request.
+func (h *RunningQueryHandler) findAuthorization(ctx context.Context) (*platform.Authorization, error) {
+	tok, err := pcontext.GetToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	auth, err := h.AuthorizationService.FindAuthorizationByToken(ctx, tok)
+	if err != nil {
+		return nil, kerrors.Wrap(err, "invalid token", kerrors.InvalidData)
+	}
+	return auth, nil
+}
+
+const (
+	runningQueryPath = "/v2/queries"
+)
+
+// RunningQueryService connects to Influx via HTTP using tokens to manage running queries.
+type RunningQueryService struct {
+	Addr               string
+	Token              string
+	InsecureSkipVerify bool
+}
+
+// FindRunningQueries returns the running queries, only those of the organization if orgID is not nil.
+func (s *RunningQueryService) FindRunningQueries(ctx context.Context, orgID *platform.ID) ([]*query.RunningQuery, error) {
+	u, err := newURL(s.Addr, runningQueryPath)
+	if err != nil {
+		return nil, err
+	}
+
+	params := u.Query()
+	if orgID != nil {
+		params.Add("orgID", orgID.String())
+	}
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.URL.RawQuery = params.Encode()
+	SetToken(s.Token, req)
+
+	hc := newClient(u.Scheme, s.InsecureSkipVerify)
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := CheckError(resp); err != nil {
+		return nil, err
+	}
+
+	var qs []*query.RunningQuery
+	if err := json.NewDecoder(resp.Body).Decode(&qs); err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return qs, nil
+}
+
+// FindRunningQueryByID returns a single running query by ID.
+func (s *RunningQueryService) FindRunningQueryByID(ctx context.Context, id uint64) (*query.RunningQuery, error) {
+	u, err := newURL(s.Addr, runningQueryIDPath(id))
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	SetToken(s.Token, req)
+
+	hc := newClient(u.Scheme, s.InsecureSkipVerify)
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := CheckError(resp); err != nil {
+		return nil, err
+	}
+
+	var q query.RunningQuery
+	if err := json.NewDecoder(resp.Body).Decode(&q); err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return &q, nil
+}
+
+// CancelRunningQuery cancels a running query by ID.
+func (s *RunningQueryService) CancelRunningQuery(ctx context.Context, id uint64) error {
+	u, err := newURL(s.Addr, runningQueryIDPath(id))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("DELETE", u.String(), nil)
+	if err != nil {
+		return err
+	}
+	SetToken(s.Token, req)
+
+	hc := newClient(u.Scheme, s.InsecureSkipVerify)
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	return CheckError(resp)
+}
+
+func runningQueryIDPath(id uint64) string {
+	return path.Join(runningQueryPath, strconv.FormatUint(id, 10))
+}
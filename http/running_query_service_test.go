@@ -0,0 +1,213 @@
+package http_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/EMCECS/influx"
+	"github.com/EMCECS/influx/http"
+	kerrors "github.com/EMCECS/influx/kit/errors"
+	"github.com/EMCECS/influx/query"
+	"github.com/google/go-cmp/cmp"
+)
+
+type authorizationService struct {
+	platform.AuthorizationService
+	auths map[string]*platform.Authorization
+}
+
+func (s authorizationService) FindAuthorizationByToken(ctx context.Context, t string) (*platform.Authorization, error) {
+	a, ok := s.auths[t]
+	if !ok {
+		return nil, errors.New("authorization not found")
+	}
+	return a, nil
+}
+
+// runningQueryService runs its queries until they are canceled.
+type runningQueryService struct {
+	queries  []*query.RunningQuery
+	canceled []uint64
+}
+
+func (s *runningQueryService) FindRunningQueries(ctx context.Context, orgID *platform.ID) ([]*query.RunningQuery, error) {
+	var qs []*query.RunningQuery
+	for _, q := range s.queries {
+		if orgID == nil || bytes.Equal(q.OrganizationID, *orgID) {
+			qs = append(qs, q)
+		}
+	}
+	return qs, nil
+}
+
+func (s *runningQueryService) FindRunningQueryByID(ctx context.Context, id uint64) (*query.RunningQuery, error) {
+	for _, q := range s.queries {
+		if q.ID == id {
+			return q, nil
+		}
+	}
+	return nil, kerrors.Errorf(kerrors.NotFound, "query %d not found", id)
+}
+
+func (s *runningQueryService) CancelRunningQuery(ctx context.Context, id uint64) error {
+	s.canceled = append(s.canceled, id)
+	return nil
+}
+
+func TestRunningQueryService(t *testing.T) {
+	orgA, orgB := platform.ID("a"), platform.ID("b")
+	queries := []*query.RunningQuery{
+		{ID: 1, OrganizationID: orgA, State: "executing", Elapsed: time.Second, Allocated: 1024, Query: `from(bucket:"telegraf")`},
+		{ID: 2, OrganizationID: orgB, State: "queueing", Elapsed: time.Millisecond},
+		{ID: 3, OrganizationID: orgA, State: "planning"},
+	}
+	auths := map[string]*platform.Authorization{
+		"reader": {
+			Status:      platform.Active,
+			Permissions: []platform.Permission{platform.ReadQueryPermission(orgA)},
+		},
+		"admin": {
+			Status: platform.Active,
+			Permissions: []platform.Permission{
+				platform.ReadQueryPermission(orgA),
+				platform.DeleteQueryPermission(orgA),
+				platform.ReadQueryPermission(orgB),
+				platform.DeleteQueryPermission(orgB),
+			},
+		},
+	}
+
+	testCases := []struct {
+		name  string
+		token string
+		// do calls the service, returning the queries it finds.
+		do       func(s *http.RunningQueryService) ([]*query.RunningQuery, error)
+		want     []*query.RunningQuery
+		canceled []uint64
+		// code is the status code of the error, if the call is expected to fail.
+		code int
+	}{
+		{
+			name:  "list",
+			token: "admin",
+			do: func(s *http.RunningQueryService) ([]*query.RunningQuery, error) {
+				return s.FindRunningQueries(context.Background(), nil)
+			},
+			want: queries,
+		},
+		{
+			name:  "list allowed organizations",
+			token: "reader",
+			do: func(s *http.RunningQueryService) ([]*query.RunningQuery, error) {
+				return s.FindRunningQueries(context.Background(), nil)
+			},
+			want: []*query.RunningQuery{queries[0], queries[2]},
+		},
+		{
+			name:  "list organization",
+			token: "admin",
+			do: func(s *http.RunningQueryService) ([]*query.RunningQuery, error) {
+				return s.FindRunningQueries(context.Background(), &orgB)
+			},
+			want: []*query.RunningQuery{queries[1]},
+		},
+		{
+			name:  "list forbidden organization",
+			token: "reader",
+			do: func(s *http.RunningQueryService) ([]*query.RunningQuery, error) {
+				return s.FindRunningQueries(context.Background(), &orgB)
+			},
+			code: 403,
+		},
+		{
+			name:  "invalid token",
+			token: "invalid",
+			do: func(s *http.RunningQueryService) ([]*query.RunningQuery, error) {
+				return s.FindRunningQueries(context.Background(), nil)
+			},
+			code: 422,
+		},
+		{
+			name:  "inspect",
+			token: "reader",
+			do: func(s *http.RunningQueryService) ([]*query.RunningQuery, error) {
+				q, err := s.FindRunningQueryByID(context.Background(), 1)
+				return []*query.RunningQuery{q}, err
+			},
+			want: []*query.RunningQuery{queries[0]},
+		},
+		{
+			name:  "inspect forbidden",
+			token: "reader",
+			do: func(s *http.RunningQueryService) ([]*query.RunningQuery, error) {
+				q, err := s.FindRunningQueryByID(context.Background(), 2)
+				return []*query.RunningQuery{q}, err
+			},
+			code: 403,
+		},
+		{
+			name:  "inspect not found",
+			token: "admin",
+			do: func(s *http.RunningQueryService) ([]*query.RunningQuery, error) {
+				q, err := s.FindRunningQueryByID(context.Background(), 4)
+				return []*query.RunningQuery{q}, err
+			},
+			code: 404,
+		},
+		{
+			name:  "kill",
+			token: "admin",
+			do: func(s *http.RunningQueryService) ([]*query.RunningQuery, error) {
+				return nil, s.CancelRunningQuery(context.Background(), 2)
+			},
+			canceled: []uint64{2},
+		},
+		{
+			name:  "kill forbidden",
+			token: "reader",
+			do: func(s *http.RunningQueryService) ([]*query.RunningQuery, error) {
+				return nil, s.CancelRunningQuery(context.Background(), 1)
+			},
+			code: 403,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			svc := &runningQueryService{queries: queries}
+			h := http.NewRunningQueryHandler()
+			h.AuthorizationService = authorizationService{auths: auths}
+			h.RunningQueryService = svc
+			server := httptest.NewServer(&http.PlatformHandler{RunningQueryHandler: h})
+			defer server.Close()
+
+			got, err := tc.do(&http.RunningQueryService{
+				Addr:  server.URL,
+				Token: tc.token,
+			})
+			if tc.code != 0 {
+				e, ok := err.(*kerrors.Error)
+				if !ok {
+					t.Fatalf("expected an error with status code %d, got %v", tc.code, err)
+				}
+				if e.Code != tc.code {
+					t.Fatalf("unexpected status code: got %d want %d", e.Code, tc.code)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !cmp.Equal(tc.want, got) {
+				t.Errorf("unexpected queries -want/+got\n%s", cmp.Diff(tc.want, got))
+			}
+			if !cmp.Equal(tc.canceled, svc.canceled) {
+				t.Errorf("unexpected canceled queries -want/+got\n%s", cmp.Diff(tc.canceled, svc.canceled))
+			}
+		})
+	}
+}
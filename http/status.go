@@ -1,6 +1,11 @@
 package http
 
-import "net/http"
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+)
 
 type statusResponseWriter struct {
 	statusCode int
@@ -19,6 +24,16 @@ func (w *statusResponseWriter) WriteHeader(statusCode int) {
 	w.ResponseWriter.WriteHeader(statusCode)
 }
 
+// Hijack lets the handler take over the connection, as WebSocket handlers do.
+func (w *statusResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("%T is not a http.Hijacker", w.ResponseWriter)
+	}
+	w.statusCode = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
+
 func (w *statusResponseWriter) code() int {
 	code := w.statusCode
 	if code == 0 {
@@ -1,11 +1,14 @@
 package http
 
 import (
+	"bytes"
 	"compress/gzip"
 	"context"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/EMCECS/influx"
 	pcontext "github.com/EMCECS/influx/context"
@@ -27,6 +30,10 @@ type WriteHandler struct {
 	OrganizationService  platform.OrganizationService
 
 	Publish func(io.Reader) error
+
+	// OnWrite is called once points are published to a bucket of an organization,
+	// with the earliest and latest timestamps of the points. It may be nil.
+	OnWrite func(org *platform.Organization, bucket *platform.Bucket, min, max time.Time)
 }
 
 func NewWriteHandler(publishFn func(io.Reader) error) *WriteHandler {
@@ -123,14 +130,106 @@ func (h *WriteHandler) handleWrite(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.Publish(in); err != nil {
+	var body io.Reader = in
+	var times *pointTimes
+	if h.OnWrite != nil {
+		times = &pointTimes{now: time.Now().UnixNano()}
+		body = io.TeeReader(in, times)
+	}
+
+	if err := h.Publish(body); err != nil {
 		EncodeError(ctx, errors.BadRequestError(err.Error()), w)
 		return
 	}
 
+	if times != nil {
+		times.flush()
+		if times.n > 0 {
+			h.OnWrite(org, bucket, time.Unix(0, times.min).UTC(), time.Unix(0, times.max).UTC())
+		}
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// pointTimes tracks the earliest and latest timestamps of the points of the line protocol written to it.
+// The points without a timestamp are given the time now.
+type pointTimes struct {
+	now      int64
+	min, max int64
+	// n is the number of points.
+	n int
+	// line holds the line being written.
+	line []byte
+}
+
+func (p *pointTimes) Write(b []byte) (int, error) {
+	n := len(b)
+	for {
+		i := bytes.IndexByte(b, '\n')
+		if i < 0 {
+			p.line = append(p.line, b...)
+			return n, nil
+		}
+		p.line = append(p.line, b[:i]...)
+		p.point(p.line)
+		p.line = p.line[:0]
+		b = b[i+1:]
+	}
+}
+
+// flush tracks the last line, which may not end with a newline.
+func (p *pointTimes) flush() {
+	p.point(p.line)
+	p.line = p.line[:0]
+}
+
+func (p *pointTimes) point(line []byte) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 || line[0] == '#' {
+		return
+	}
+	t, ok := lineTimestamp(line)
+	if !ok {
+		t = p.now
+	}
+	if p.n == 0 || t < p.min {
+		p.min = t
+	}
+	if p.n == 0 || t > p.max {
+		p.max = t
+	}
+	p.n++
+}
+
+// lineTimestamp returns the timestamp of the line of a point, in nanoseconds.
+// The timestamp follows the measurement with its tags and the fields, separated by unescaped spaces outside of quoted field values.
+func lineTimestamp(line []byte) (int64, bool) {
+	sections := 0
+	start := -1
+	quoted, escaped := false, false
+	for i, c := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == '"' && sections == 1:
+			quoted = !quoted
+		case c == ' ' && !quoted:
+			sections++
+			if sections == 2 {
+				start = i + 1
+			}
+		}
+	}
+	if start < 0 {
+		return 0, false
+	}
+	t, err := strconv.ParseInt(string(line[start:]), 10, 64)
+	return t, err == nil
+}
+
 func decodeWriteRequest(ctx context.Context, r *http.Request) *postWriteRequest {
 	qp := r.URL.Query()
 
@@ -0,0 +1,78 @@
+package http
+
+import (
+	"testing"
+)
+
+func TestPointTimes(t *testing.T) {
+	const now = 100
+	tests := []struct {
+		name string
+		// writes are the writes of the line protocol.
+		writes []string
+		n      int
+		min    int64
+		max    int64
+	}{
+		{
+			name:   "timestamps",
+			writes: []string{"cpu,host=a value=1 30\ncpu,host=b value=2 10\n"},
+			n:      2,
+			min:    10,
+			max:    30,
+		},
+		{
+			name:   "no timestamp",
+			writes: []string{"cpu,host=a value=1 30\ncpu,host=b value=2\n"},
+			n:      2,
+			min:    30,
+			max:    now,
+		},
+		{
+			name:   "split lines",
+			writes: []string{"cpu,host=a val", "ue=1 2", "0\ncpu value=2 4", "0"},
+			n:      2,
+			min:    20,
+			max:    40,
+		},
+		{
+			name: "escaped and quoted spaces",
+			writes: []string{
+				"cpu\\ load,host=a\\ b value=1 50\n",
+				"log message=\"a \\\"b c\\\" d\",n=1i 60\n",
+				"log message=\"a b\"\n",
+			},
+			n:   3,
+			min: 50,
+			max: now,
+		},
+		{
+			name:   "comments and blank lines",
+			writes: []string{"# comment 1\n\n  \ncpu value=1 70\n"},
+			n:      1,
+			min:    70,
+			max:    70,
+		},
+		{
+			name:   "empty",
+			writes: []string{""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &pointTimes{now: now}
+			for _, w := range tt.writes {
+				if _, err := p.Write([]byte(w)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			p.flush()
+			if p.n != tt.n {
+				t.Fatalf("unexpected number of points: got %d want %d", p.n, tt.n)
+			}
+			if p.n > 0 && (p.min != tt.min || p.max != tt.max) {
+				t.Errorf("unexpected timestamps: got [%d, %d] want [%d, %d]", p.min, p.max, tt.min, tt.max)
+			}
+		})
+	}
+}
@@ -18,6 +18,8 @@ const (
 	Forbidden = 4
 	// NotFound indicates a resource was not found.
 	NotFound = 5
+	// Timeout indicates an operation that did not complete within its deadline.
+	Timeout = 6
 )
 
 // Error indicates an error with a reference code and an HTTP status code.
@@ -92,6 +94,11 @@ func InvalidDataf(format string, i ...interface{}) error {
 	return Errorf(InvalidData, format, i...)
 }
 
+// Timeoutf constructs a Timeout error with the given format.
+func Timeoutf(format string, i ...interface{}) error {
+	return Errorf(Timeout, format, i...)
+}
+
 // Forbiddenf constructs a Forbidden error with the given format.
 func Forbiddenf(format string, i ...interface{}) error {
 	return Errorf(Forbidden, format, i...)
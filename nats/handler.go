@@ -15,3 +15,18 @@ func (lh *LogHandler) Process(s Subscription, m Message) {
 	lh.Logger.Info(string(m.Data()))
 	m.Ack()
 }
+
+// Notifier is notified of the messages received by a NotifyHandler.
+type Notifier interface {
+	Notify()
+}
+
+// NotifyHandler notifies that a message has been received, such as new data being written to the ingress subject.
+type NotifyHandler struct {
+	Notifier Notifier
+}
+
+func (nh *NotifyHandler) Process(s Subscription, m Message) {
+	nh.Notifier.Notify()
+	m.Ack()
+}
@@ -10,7 +10,9 @@ type Subscriber interface {
 }
 
 type QueueSubscriber struct {
-	ClientID   string
+	ClientID string
+	// URL is the URL of the NATS server, stan.DefaultNatsURL if empty.
+	URL        string
 	Connection stan.Conn
 }
 
@@ -20,7 +22,11 @@ func NewQueueSubscriber(clientID string) *QueueSubscriber {
 
 // Open creates and maintains a connection to NATS server
 func (s *QueueSubscriber) Open() error {
-	sc, err := stan.Connect(ServerName, s.ClientID)
+	var opts []stan.Option
+	if s.URL != "" {
+		opts = append(opts, stan.NatsURL(s.URL))
+	}
+	sc, err := stan.Connect(ServerName, s.ClientID, opts...)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,49 @@
+package nats
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"github.com/EMCECS/influx"
+	"go.uber.org/zap"
+)
+
+// WriteSubject is the subject of the notifications of the points written to buckets,
+// read by the processes that serve queries, such as fluxd.
+const WriteSubject = "writes"
+
+// WriteNotification describes the points written to a bucket, from the earliest to the latest of their timestamps.
+type WriteNotification struct {
+	OrganizationID   platform.ID `json:"orgID"`
+	OrganizationName string      `json:"org"`
+	BucketID         platform.ID `json:"bucketID"`
+	BucketName       string      `json:"bucket"`
+	Min              time.Time   `json:"min"`
+	Max              time.Time   `json:"max"`
+}
+
+// PublishWrite publishes the write notification to WriteSubject.
+func PublishWrite(p Publisher, n WriteNotification) error {
+	data, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	return p.Publish(WriteSubject, bytes.NewReader(data))
+}
+
+// WriteHandler decodes the write notifications it receives and calls OnWrite with them.
+type WriteHandler struct {
+	OnWrite func(n WriteNotification)
+	Logger  *zap.Logger
+}
+
+func (wh *WriteHandler) Process(s Subscription, m Message) {
+	var n WriteNotification
+	if err := json.Unmarshal(m.Data(), &n); err != nil {
+		wh.Logger.Info("Failed to decode write notification", zap.Error(err))
+	} else {
+		wh.OnWrite(n)
+	}
+	m.Ack()
+}
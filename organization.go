@@ -6,6 +6,22 @@ import "context"
 type Organization struct {
 	ID   ID     `json:"id"`
 	Name string `json:"name"`
+	// QueryQuotas limits the resources of the queries of the organization, they are not limited if it is nil.
+	QueryQuotas *QueryQuotas `json:"queryQuotas,omitempty"`
+}
+
+// QueryQuotas limits the resources used at once by the queries of an organization,
+// and weighs the share of the query resources the organization gets when other organizations wait for them.
+type QueryQuotas struct {
+	// ConcurrencyQuota is the number of concurrency workers the queries may use at once.
+	// A zero value indicates unlimited.
+	ConcurrencyQuota int `json:"concurrencyQuota"`
+	// MemoryBytesQuota is the number of bytes of RAM the queries may consume at once.
+	// A zero value indicates unlimited.
+	MemoryBytesQuota int64 `json:"memoryBytesQuota"`
+	// Weight is the share of the query resources given to the organization relative to the other organizations.
+	// A zero value indicates a weight of one.
+	Weight int `json:"weight"`
 }
 
 // OrganizationService represents a service for managing organization data.
@@ -34,7 +50,8 @@ type OrganizationService interface {
 // OrganizationUpdate represents updates to a organization.
 // Only fields which are set are updated.
 type OrganizationUpdate struct {
-	Name *string
+	Name        *string
+	QueryQuotas *QueryQuotas
 }
 
 // OrganizationFilter represents a set of filter that restrict the returned results.
@@ -0,0 +1,770 @@
+// Package arrow contains the Arrow IPC result encoders and decoders.
+//
+// Each table is written as an Arrow IPC stream, and the streams of all tables of all results are concatenated.
+// The schema of a stream has a field for each column of the table and its record batches are the chunks of the table.
+// The name of the result and the group key of the table are the metadata of the schema:
+//
+//	flux.result            the name of the result
+//	flux.group_key         a JSON array of the labels of the group key columns
+//	flux.group_key_values  a JSON array of the group key values, formatted as strings or null
+//
+// An error that ends a result or the query is written as a stream without fields
+// whose schema has the error message as its flux.error metadata, and the name of the result if any.
+package arrow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/execute"
+	"github.com/EMCECS/influx/query/iocounter"
+	"github.com/EMCECS/influx/query/semantic"
+	"github.com/EMCECS/influx/query/values"
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/apache/arrow/go/arrow/ipc"
+	"github.com/apache/arrow/go/arrow/memory"
+)
+
+const (
+	// ContentType is the media type of the arrow dialect.
+	ContentType = "application/vnd.apache.arrow.stream"
+
+	resultMetadataKey         = "flux.result"
+	groupKeyMetadataKey       = "flux.group_key"
+	groupKeyValuesMetadataKey = "flux.group_key_values"
+	errorMetadataKey          = "flux.error"
+)
+
+// timestampType is the Arrow type of time columns.
+var timestampType = &arrow.TimestampType{Unit: arrow.Nanosecond, TimeZone: "UTC"}
+
+// dataType returns the Arrow type of a column type.
+func dataType(typ query.DataType) arrow.DataType {
+	switch typ {
+	case query.TBool:
+		return arrow.FixedWidthTypes.Boolean
+	case query.TInt:
+		return arrow.PrimitiveTypes.Int64
+	case query.TUInt:
+		return arrow.PrimitiveTypes.Uint64
+	case query.TFloat:
+		return arrow.PrimitiveTypes.Float64
+	case query.TString:
+		return arrow.BinaryTypes.String
+	case query.TTime:
+		return timestampType
+	default:
+		execute.PanicUnknownType(typ)
+		return nil
+	}
+}
+
+// columnType returns the column type of an Arrow type.
+func columnType(dt arrow.DataType) (query.DataType, error) {
+	switch dt.ID() {
+	case arrow.BOOL:
+		return query.TBool, nil
+	case arrow.INT64:
+		return query.TInt, nil
+	case arrow.UINT64:
+		return query.TUInt, nil
+	case arrow.FLOAT64:
+		return query.TFloat, nil
+	case arrow.STRING:
+		return query.TString, nil
+	case arrow.TIMESTAMP:
+		if unit := dt.(*arrow.TimestampType).Unit; unit != arrow.Nanosecond {
+			return query.TInvalid, fmt.Errorf("unsupported timestamp unit %v", unit)
+		}
+		return query.TTime, nil
+	default:
+		return query.TInvalid, fmt.Errorf("unsupported arrow type %v", dt)
+	}
+}
+
+// ResultEncoder encodes the tables of a result as Arrow IPC streams.
+type ResultEncoder struct {
+	mem memory.Allocator
+}
+
+// NewResultEncoder creates a new ResultEncoder.
+func NewResultEncoder() *ResultEncoder {
+	return &ResultEncoder{
+		mem: memory.NewGoAllocator(),
+	}
+}
+
+type arrowEncoderError struct {
+	msg string
+}
+
+func (e *arrowEncoderError) Error() string {
+	return "arrow encoder error: " + e.msg
+}
+
+func (e *arrowEncoderError) IsEncoderError() bool {
+	return true
+}
+
+func wrapEncodingError(err error) error {
+	return &arrowEncoderError{msg: err.Error()}
+}
+
+// Encode writes each table of the result as a stream as it is read.
+// If reading the result fails, the error is written as an error stream of the result and returned.
+func (e *ResultEncoder) Encode(w io.Writer, result query.Result) (int64, error) {
+	wc := &iocounter.Writer{Writer: w}
+	err := e.encode(wc, result)
+	return wc.Count(), err
+}
+
+func (e *ResultEncoder) encode(w io.Writer, result query.Result) error {
+	err := result.Tables().Do(func(tbl query.Table) error {
+		return e.encodeTable(w, result.Name(), tbl)
+	})
+	if err != nil && !query.IsEncoderError(err) {
+		if eerr := e.encodeError(w, result.Name(), err); eerr != nil {
+			return wrapEncodingError(eerr)
+		}
+	}
+	return err
+}
+
+func (e *ResultEncoder) encodeTable(w io.Writer, name string, tbl query.Table) error {
+	schema, err := newSchema(name, tbl)
+	if err != nil {
+		return wrapEncodingError(err)
+	}
+	iw := ipc.NewWriter(w, ipc.WithSchema(schema), ipc.WithAllocator(e.mem))
+	err = tbl.Do(func(cr query.ColReader) error {
+		rec := newRecord(schema, cr, e.mem)
+		defer rec.Release()
+		if err := iw.Write(rec); err != nil {
+			return wrapEncodingError(err)
+		}
+		return nil
+	})
+	// The stream is ended even if reading the table failed,
+	// so that the error stream that follows can be read.
+	if cerr := iw.Close(); cerr != nil {
+		return wrapEncodingError(cerr)
+	}
+	return err
+}
+
+// EncodeError writes the error of a query as an error stream without a result.
+func (e *ResultEncoder) EncodeError(w io.Writer, err error) error {
+	return e.encodeError(w, "", err)
+}
+
+func (e *ResultEncoder) encodeError(w io.Writer, name string, err error) error {
+	keys := []string{errorMetadataKey}
+	vals := []string{err.Error()}
+	if name != "" {
+		keys = append(keys, resultMetadataKey)
+		vals = append(vals, name)
+	}
+	md := arrow.NewMetadata(keys, vals)
+	iw := ipc.NewWriter(w, ipc.WithSchema(arrow.NewSchema(nil, &md)), ipc.WithAllocator(e.mem))
+	return iw.Close()
+}
+
+func newSchema(name string, tbl query.Table) (*arrow.Schema, error) {
+	cols := tbl.Cols()
+	fields := make([]arrow.Field, len(cols))
+	for j, c := range cols {
+		fields[j] = arrow.Field{
+			Name:     c.Label,
+			Type:     dataType(c.Type),
+			Nullable: true,
+		}
+	}
+
+	key := tbl.Key()
+	labels := make([]string, len(key.Cols()))
+	keyValues := make([]*string, len(key.Cols()))
+	for j, c := range key.Cols() {
+		labels[j] = c.Label
+		keyValues[j] = formatValue(key.Value(j))
+	}
+	labelsJSON, err := json.Marshal(labels)
+	if err != nil {
+		return nil, err
+	}
+	keyValuesJSON, err := json.Marshal(keyValues)
+	if err != nil {
+		return nil, err
+	}
+	md := arrow.NewMetadata(
+		[]string{resultMetadataKey, groupKeyMetadataKey, groupKeyValuesMetadataKey},
+		[]string{name, string(labelsJSON), string(keyValuesJSON)},
+	)
+	return arrow.NewSchema(fields, &md), nil
+}
+
+// newRecord copies a chunk of a table into a record batch.
+func newRecord(schema *arrow.Schema, cr query.ColReader, mem memory.Allocator) array.Record {
+	cols := make([]array.Interface, len(cr.Cols()))
+	for j, c := range cr.Cols() {
+		valid := validity(cr.Nulls(j))
+		switch c.Type {
+		case query.TBool:
+			b := array.NewBooleanBuilder(mem)
+			b.AppendValues(cr.Bools(j), valid)
+			cols[j] = b.NewArray()
+			b.Release()
+		case query.TInt:
+			b := array.NewInt64Builder(mem)
+			b.AppendValues(cr.Ints(j), valid)
+			cols[j] = b.NewArray()
+			b.Release()
+		case query.TUInt:
+			b := array.NewUint64Builder(mem)
+			b.AppendValues(cr.UInts(j), valid)
+			cols[j] = b.NewArray()
+			b.Release()
+		case query.TFloat:
+			b := array.NewFloat64Builder(mem)
+			b.AppendValues(cr.Floats(j), valid)
+			cols[j] = b.NewArray()
+			b.Release()
+		case query.TString:
+			b := array.NewStringBuilder(mem)
+			b.AppendValues(cr.Strings(j), valid)
+			cols[j] = b.NewArray()
+			b.Release()
+		case query.TTime:
+			times := cr.Times(j)
+			ts := make([]arrow.Timestamp, len(times))
+			for i, t := range times {
+				ts[i] = arrow.Timestamp(t)
+			}
+			b := array.NewTimestampBuilder(mem, timestampType)
+			b.AppendValues(ts, valid)
+			cols[j] = b.NewArray()
+			b.Release()
+		default:
+			execute.PanicUnknownType(c.Type)
+		}
+	}
+	rec := array.NewRecord(schema, cols, int64(cr.Len()))
+	for _, col := range cols {
+		col.Release()
+	}
+	return rec
+}
+
+// validity returns the Arrow validity of the nulls of a column, which is nil when all values are valid.
+func validity(nulls []bool) []bool {
+	if nulls == nil {
+		return nil
+	}
+	valid := make([]bool, len(nulls))
+	for i, null := range nulls {
+		valid[i] = !null
+	}
+	return valid
+}
+
+// formatValue formats a group key value as a string, null values are nil.
+func formatValue(v values.Value) *string {
+	var s string
+	switch v.Type() {
+	case semantic.Nil:
+		return nil
+	case semantic.Bool:
+		s = strconv.FormatBool(v.Bool())
+	case semantic.Int:
+		s = strconv.FormatInt(v.Int(), 10)
+	case semantic.UInt:
+		s = strconv.FormatUint(v.UInt(), 10)
+	case semantic.Float:
+		s = strconv.FormatFloat(v.Float(), 'g', -1, 64)
+	case semantic.String:
+		s = v.Str()
+	case semantic.Time:
+		s = v.Time().Time().Format(time.RFC3339Nano)
+	default:
+		panic(fmt.Errorf("unexpected value type %v", v.Type()))
+	}
+	return &s
+}
+
+// parseValue parses a group key value of the column type.
+func parseValue(s *string, typ query.DataType) (values.Value, error) {
+	if s == nil {
+		return values.Null, nil
+	}
+	switch typ {
+	case query.TBool:
+		b, err := strconv.ParseBool(*s)
+		if err != nil {
+			return nil, err
+		}
+		return values.NewBoolValue(b), nil
+	case query.TInt:
+		i, err := strconv.ParseInt(*s, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return values.NewIntValue(i), nil
+	case query.TUInt:
+		u, err := strconv.ParseUint(*s, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return values.NewUIntValue(u), nil
+	case query.TFloat:
+		f, err := strconv.ParseFloat(*s, 64)
+		if err != nil {
+			return nil, err
+		}
+		return values.NewFloatValue(f), nil
+	case query.TString:
+		return values.NewStringValue(*s), nil
+	case query.TTime:
+		t, err := time.Parse(time.RFC3339Nano, *s)
+		if err != nil {
+			return nil, err
+		}
+		return values.NewTimeValue(values.ConvertTime(t)), nil
+	default:
+		return nil, fmt.Errorf("unsupported data type %v", typ)
+	}
+}
+
+// MultiResultEncoder encodes the tables of multiple results as Arrow IPC streams.
+type MultiResultEncoder struct {
+	e *ResultEncoder
+}
+
+// NewMultiResultEncoder creates a new MultiResultEncoder.
+func NewMultiResultEncoder() *MultiResultEncoder {
+	return &MultiResultEncoder{
+		e: NewResultEncoder(),
+	}
+}
+
+type flusher interface {
+	Flush()
+}
+
+// Encode writes the results to w, flushing it after each result if it is a flusher.
+// An error of the query ends the encoding and is written as an error stream.
+func (e *MultiResultEncoder) Encode(w io.Writer, results query.ResultIterator) (int64, error) {
+	wc := &iocounter.Writer{Writer: w}
+	for results.More() {
+		if err := e.e.encode(wc, results.Next()); err != nil {
+			if query.IsEncoderError(err) {
+				return wc.Count(), err
+			}
+			// The error has been encoded with its result.
+			results.Cancel()
+			return wc.Count(), nil
+		}
+		if f, ok := w.(flusher); ok {
+			f.Flush()
+		}
+	}
+	if err := results.Err(); err != nil {
+		err := e.e.EncodeError(wc, err)
+		return wc.Count(), err
+	}
+	return wc.Count(), nil
+}
+
+// MultiResultDecoder reads the results encoded by a MultiResultEncoder.
+// Tables are read from the stream as the tables of a result are iterated.
+type MultiResultDecoder struct {
+	mem memory.Allocator
+}
+
+// NewMultiResultDecoder creates a new MultiResultDecoder.
+func NewMultiResultDecoder() *MultiResultDecoder {
+	return &MultiResultDecoder{
+		mem: memory.NewGoAllocator(),
+	}
+}
+
+func (d *MultiResultDecoder) Decode(r io.ReadCloser) (query.ResultIterator, error) {
+	return &resultIterator{
+		r:   r,
+		mem: d.mem,
+	}, nil
+}
+
+// stream is the beginning of a stream whose schema has been read.
+type stream struct {
+	r *ipc.Reader
+	// result is the name of the result of the stream and hasResult whether there is one.
+	result    string
+	hasResult bool
+	// err is the error of an error stream.
+	err error
+}
+
+// resultIterator iterates through the results encoded in r.
+type resultIterator struct {
+	r   io.ReadCloser
+	mem memory.Allocator
+
+	// pending is the stream read ahead of the tables of the current result.
+	pending *stream
+	current *result
+	err     error
+
+	canceled bool
+}
+
+// readStream reads the schema of the next stream, and returns io.EOF when there are no more streams.
+func (r *resultIterator) readStream() (*stream, error) {
+	rdr, err := ipc.NewReader(r.r, ipc.WithAllocator(r.mem))
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+	s := &stream{r: rdr}
+	md := rdr.Schema().Metadata()
+	if i := md.FindKey(resultMetadataKey); i >= 0 {
+		s.result = md.Values()[i]
+		s.hasResult = true
+	}
+	if i := md.FindKey(errorMetadataKey); i >= 0 {
+		s.err = errors.New(md.Values()[i])
+		// Read the end of the stream.
+		for rdr.Next() {
+		}
+		if err := rdr.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// next returns the next stream, reading it if it has not been read ahead.
+func (r *resultIterator) next() (*stream, error) {
+	if r.pending == nil {
+		s, err := r.readStream()
+		if err != nil {
+			return nil, err
+		}
+		r.pending = s
+	}
+	return r.pending, nil
+}
+
+func (r *resultIterator) More() bool {
+	if r.canceled {
+		return false
+	}
+	if r.current != nil {
+		// Skip the tables of the current result that were not read.
+		if err := r.current.do(nil); err != nil && r.err == nil {
+			r.err = err
+		}
+		r.current = nil
+		if r.err != nil {
+			r.Cancel()
+			return false
+		}
+	}
+	s, err := r.next()
+	if err != nil {
+		if err != io.EOF {
+			r.err = err
+		}
+		r.Cancel()
+		return false
+	}
+	if !s.hasResult {
+		r.err = s.err
+		if r.err == nil {
+			r.err = errors.New("arrow decoder error: missing result name")
+		}
+		r.Cancel()
+		return false
+	}
+	r.current = &result{
+		name: s.result,
+		it:   r,
+	}
+	return true
+}
+
+func (r *resultIterator) Next() query.Result {
+	return r.current
+}
+
+func (r *resultIterator) Cancel() {
+	if r.canceled {
+		return
+	}
+	r.canceled = true
+	r.r.Close()
+}
+
+func (r *resultIterator) Err() error {
+	return r.err
+}
+
+// result reads its tables from the streams of the iterator that have its name.
+type result struct {
+	name string
+	it   *resultIterator
+	done bool
+}
+
+func (r *result) Name() string {
+	return r.name
+}
+
+func (r *result) Tables() query.TableIterator {
+	return r
+}
+
+func (r *result) Do(f func(query.Table) error) error {
+	return r.do(f)
+}
+
+// do calls f with each table of the result, or skips the tables if f is nil.
+// The error that ended the result is returned once its tables are read.
+func (r *result) do(f func(query.Table) error) error {
+	if r.done {
+		return nil
+	}
+	defer func() {
+		r.done = true
+	}()
+	for {
+		s, err := r.it.next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if !s.hasResult || s.result != r.name {
+			// The stream is the beginning of the next result.
+			return nil
+		}
+		r.it.pending = nil
+		if s.err != nil {
+			return s.err
+		}
+		tbl, err := newTable(s.r)
+		if err != nil {
+			return err
+		}
+		if f == nil {
+			continue
+		}
+		if err := f(tbl); err != nil {
+			return err
+		}
+	}
+}
+
+// table is a table decoded from a stream, its chunks are the record batches of the stream.
+type table struct {
+	key     query.GroupKey
+	cols    []query.ColMeta
+	records []array.Record
+}
+
+func newTable(rdr *ipc.Reader) (*table, error) {
+	schema := rdr.Schema()
+	t := &table{
+		cols: make([]query.ColMeta, len(schema.Fields())),
+	}
+	for j, f := range schema.Fields() {
+		typ, err := columnType(f.Type)
+		if err != nil {
+			return nil, fmt.Errorf("arrow decoder error: column %q: %v", f.Name, err)
+		}
+		t.cols[j] = query.ColMeta{Label: f.Name, Type: typ}
+	}
+
+	var labels []string
+	var keyValues []*string
+	md := schema.Metadata()
+	if i := md.FindKey(groupKeyMetadataKey); i >= 0 {
+		if err := json.Unmarshal([]byte(md.Values()[i]), &labels); err != nil {
+			return nil, fmt.Errorf("arrow decoder error: invalid group key: %v", err)
+		}
+	}
+	if i := md.FindKey(groupKeyValuesMetadataKey); i >= 0 {
+		if err := json.Unmarshal([]byte(md.Values()[i]), &keyValues); err != nil {
+			return nil, fmt.Errorf("arrow decoder error: invalid group key values: %v", err)
+		}
+	}
+	if len(labels) != len(keyValues) {
+		return nil, fmt.Errorf("arrow decoder error: group key has %d columns and %d values", len(labels), len(keyValues))
+	}
+	keyCols := make([]query.ColMeta, len(labels))
+	vs := make([]values.Value, len(labels))
+	for k, label := range labels {
+		j := execute.ColIdx(label, t.cols)
+		if j < 0 {
+			return nil, fmt.Errorf("arrow decoder error: group key column %q does not exist", label)
+		}
+		v, err := parseValue(keyValues[k], t.cols[j].Type)
+		if err != nil {
+			return nil, fmt.Errorf("arrow decoder error: group key column %q: %v", label, err)
+		}
+		keyCols[k] = t.cols[j]
+		vs[k] = v
+	}
+	t.key = execute.NewGroupKey(keyCols, vs)
+
+	for rdr.Next() {
+		rec := rdr.Record()
+		rec.Retain()
+		t.records = append(t.records, rec)
+	}
+	if err := rdr.Err(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *table) Key() query.GroupKey {
+	return t.key
+}
+
+func (t *table) Cols() []query.ColMeta {
+	return t.cols
+}
+
+func (t *table) Do(f func(query.ColReader) error) error {
+	for _, rec := range t.records {
+		if err := f(newRecordColReader(t.key, t.cols, rec)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *table) RefCount(n int) {}
+
+func (t *table) Empty() bool {
+	for _, rec := range t.records {
+		if rec.NumRows() > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// recordColReader reads a record batch.
+// Ints, UInts and Floats share the memory of the record,
+// the other columns are converted once when they are first read.
+type recordColReader struct {
+	key  query.GroupKey
+	cols []query.ColMeta
+	rec  array.Record
+
+	bools   [][]bool
+	strings [][]string
+	times   [][]values.Time
+	nulls   [][]bool
+}
+
+func newRecordColReader(key query.GroupKey, cols []query.ColMeta, rec array.Record) *recordColReader {
+	return &recordColReader{
+		key:     key,
+		cols:    cols,
+		rec:     rec,
+		bools:   make([][]bool, len(cols)),
+		strings: make([][]string, len(cols)),
+		times:   make([][]values.Time, len(cols)),
+		nulls:   make([][]bool, len(cols)),
+	}
+}
+
+func (cr *recordColReader) Key() query.GroupKey {
+	return cr.key
+}
+
+func (cr *recordColReader) Cols() []query.ColMeta {
+	return cr.cols
+}
+
+func (cr *recordColReader) Len() int {
+	return int(cr.rec.NumRows())
+}
+
+func (cr *recordColReader) Bools(j int) []bool {
+	execute.CheckColType(cr.cols[j], query.TBool)
+	if cr.bools[j] == nil {
+		arr := cr.rec.Column(j).(*array.Boolean)
+		bs := make([]bool, arr.Len())
+		for i := range bs {
+			bs[i] = arr.Value(i)
+		}
+		cr.bools[j] = bs
+	}
+	return cr.bools[j]
+}
+
+func (cr *recordColReader) Ints(j int) []int64 {
+	execute.CheckColType(cr.cols[j], query.TInt)
+	return cr.rec.Column(j).(*array.Int64).Int64Values()
+}
+
+func (cr *recordColReader) UInts(j int) []uint64 {
+	execute.CheckColType(cr.cols[j], query.TUInt)
+	return cr.rec.Column(j).(*array.Uint64).Uint64Values()
+}
+
+func (cr *recordColReader) Floats(j int) []float64 {
+	execute.CheckColType(cr.cols[j], query.TFloat)
+	return cr.rec.Column(j).(*array.Float64).Float64Values()
+}
+
+func (cr *recordColReader) Strings(j int) []string {
+	execute.CheckColType(cr.cols[j], query.TString)
+	if cr.strings[j] == nil {
+		arr := cr.rec.Column(j).(*array.String)
+		ss := make([]string, arr.Len())
+		for i := range ss {
+			ss[i] = arr.Value(i)
+		}
+		cr.strings[j] = ss
+	}
+	return cr.strings[j]
+}
+
+func (cr *recordColReader) Times(j int) []values.Time {
+	execute.CheckColType(cr.cols[j], query.TTime)
+	if cr.times[j] == nil {
+		ts := cr.rec.Column(j).(*array.Timestamp).TimestampValues()
+		times := make([]values.Time, len(ts))
+		for i, t := range ts {
+			times[i] = values.Time(t)
+		}
+		cr.times[j] = times
+	}
+	return cr.times[j]
+}
+
+func (cr *recordColReader) Nulls(j int) []bool {
+	arr := cr.rec.Column(j)
+	if arr.NullN() == 0 {
+		return nil
+	}
+	if cr.nulls[j] == nil {
+		nulls := make([]bool, arr.Len())
+		for i := range nulls {
+			nulls[i] = arr.IsNull(i)
+		}
+		cr.nulls[j] = nulls
+	}
+	return cr.nulls[j]
+}
@@ -0,0 +1,439 @@
+package arrow_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/arrow"
+	"github.com/EMCECS/influx/query/csv"
+	"github.com/EMCECS/influx/query/execute"
+	"github.com/EMCECS/influx/query/execute/executetest"
+	"github.com/EMCECS/influx/query/values"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+var cpuTable = &executetest.Table{
+	KeyCols: []string{"_start", "_stop", "_measurement", "host"},
+	ColMeta: []query.ColMeta{
+		{Label: "_start", Type: query.TTime},
+		{Label: "_stop", Type: query.TTime},
+		{Label: "_time", Type: query.TTime},
+		{Label: "_measurement", Type: query.TString},
+		{Label: "host", Type: query.TString},
+		{Label: "_value", Type: query.TFloat},
+	},
+	Data: [][]interface{}{
+		{
+			values.ConvertTime(time.Date(2018, 4, 17, 0, 0, 0, 0, time.UTC)),
+			values.ConvertTime(time.Date(2018, 4, 17, 0, 5, 0, 0, time.UTC)),
+			values.ConvertTime(time.Date(2018, 4, 17, 0, 0, 0, 0, time.UTC)),
+			"cpu",
+			"A",
+			42.0,
+		},
+		{
+			values.ConvertTime(time.Date(2018, 4, 17, 0, 0, 0, 0, time.UTC)),
+			values.ConvertTime(time.Date(2018, 4, 17, 0, 5, 0, 0, time.UTC)),
+			values.ConvertTime(time.Date(2018, 4, 17, 0, 0, 1, 0, time.UTC)),
+			"cpu",
+			"A",
+			43.5,
+		},
+	},
+}
+
+func TestMultiResultEncoder_RoundTrip(t *testing.T) {
+	testCases := []struct {
+		name    string
+		results []*executetest.Result
+	}{
+		{
+			name: "single table",
+			results: []*executetest.Result{{
+				Nm:   "_result",
+				Tbls: []*executetest.Table{cpuTable},
+			}},
+		},
+		{
+			name: "multiple tables",
+			results: []*executetest.Result{{
+				Nm: "_result",
+				Tbls: []*executetest.Table{
+					{
+						KeyCols: []string{"host"},
+						ColMeta: []query.ColMeta{
+							{Label: "host", Type: query.TString},
+							{Label: "_value", Type: query.TInt},
+						},
+						Data: [][]interface{}{
+							{"A", int64(1)},
+							{"A", int64(2)},
+						},
+					},
+					{
+						KeyCols: []string{"host"},
+						ColMeta: []query.ColMeta{
+							{Label: "host", Type: query.TString},
+							{Label: "_value", Type: query.TInt},
+						},
+						Data: [][]interface{}{
+							{"B", int64(3)},
+						},
+					},
+				},
+			}},
+		},
+		{
+			name: "multiple results",
+			results: []*executetest.Result{
+				{
+					Nm:   "a",
+					Tbls: []*executetest.Table{cpuTable},
+				},
+				{
+					Nm:   "b",
+					Tbls: []*executetest.Table{cpuTable},
+				},
+			},
+		},
+		{
+			name: "empty table",
+			results: []*executetest.Result{{
+				Nm: "_result",
+				Tbls: []*executetest.Table{{
+					KeyCols:   []string{"host", "id"},
+					KeyValues: []interface{}{"A", uint64(7)},
+					ColMeta: []query.ColMeta{
+						{Label: "host", Type: query.TString},
+						{Label: "id", Type: query.TUInt},
+						{Label: "_value", Type: query.TBool},
+					},
+				}},
+			}},
+		},
+		{
+			name: "all types with nulls",
+			results: []*executetest.Result{{
+				Nm: "_result",
+				Tbls: []*executetest.Table{{
+					ColMeta: []query.ColMeta{
+						{Label: "b", Type: query.TBool},
+						{Label: "i", Type: query.TInt},
+						{Label: "u", Type: query.TUInt},
+						{Label: "f", Type: query.TFloat},
+						{Label: "s", Type: query.TString},
+						{Label: "t", Type: query.TTime},
+					},
+					Data: [][]interface{}{
+						{
+							true,
+							int64(math.MinInt64),
+							uint64(math.MaxUint64),
+							math.Inf(-1),
+							"a \"quoted\"\nstring",
+							values.ConvertTime(time.Date(2018, 4, 17, 0, 0, 0, 1, time.UTC)),
+						},
+						{nil, nil, nil, nil, nil, nil},
+						{false, int64(1), uint64(2), 3.5, "", values.Time(0)},
+					},
+				}},
+			}},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			results := make([]query.Result, len(tc.results))
+			for i, r := range tc.results {
+				results[i] = r
+			}
+			var buf bytes.Buffer
+			n, err := arrow.NewMultiResultEncoder().Encode(&buf, query.NewSliceResultIterator(results))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if g, w := n, int64(buf.Len()); g != w {
+				t.Errorf("unexpected encoding count -want/+got:\n%s", cmp.Diff(w, g))
+			}
+
+			got, err := decode(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, r := range tc.results {
+				r.Normalize()
+			}
+			if !cmp.Equal(got, tc.results) {
+				t.Error("unexpected results -want/+got", cmp.Diff(tc.results, got))
+			}
+		})
+	}
+}
+
+func TestMultiResultEncoder_Errors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		results query.ResultIterator
+		want    []*executetest.Result
+		err     error
+	}{
+		{
+			name: "error in result",
+			results: query.NewSliceResultIterator([]query.Result{
+				&executetest.Result{Nm: "a", Tbls: []*executetest.Table{cpuTable}},
+				&executetest.Result{Nm: "b", Tbls: []*executetest.Table{cpuTable}, Err: errors.New("execution failed")},
+				&executetest.Result{Nm: "c", Tbls: []*executetest.Table{cpuTable}},
+			}),
+			want: []*executetest.Result{{Nm: "a", Tbls: []*executetest.Table{cpuTable}}},
+			err:  errors.New("execution failed"),
+		},
+		{
+			name:    "error in query",
+			results: errorResultIterator{Error: errors.New("query failed")},
+			err:     errors.New("query failed"),
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if _, err := arrow.NewMultiResultEncoder().Encode(&buf, tc.results); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := decode(&buf)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if g, w := err.Error(), tc.err.Error(); g != w {
+				t.Errorf("unexpected error: got %q want %q", g, w)
+			}
+			for _, r := range tc.want {
+				r.Normalize()
+			}
+			if !cmp.Equal(got, tc.want) {
+				t.Error("unexpected results -want/+got", cmp.Diff(tc.want, got))
+			}
+		})
+	}
+}
+
+func TestMultiResultDecoder_SkipTables(t *testing.T) {
+	results := query.NewSliceResultIterator([]query.Result{
+		&executetest.Result{Nm: "a", Tbls: []*executetest.Table{cpuTable, cpuTable}},
+		&executetest.Result{Nm: "b", Tbls: []*executetest.Table{cpuTable}},
+	})
+	var buf bytes.Buffer
+	if _, err := arrow.NewMultiResultEncoder().Encode(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := arrow.NewMultiResultDecoder().Decode(ioutil.NopCloser(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer decoded.Cancel()
+	var names []string
+	for decoded.More() {
+		names = append(names, decoded.Next().Name())
+	}
+	if err := decoded.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "b"}; !cmp.Equal(names, want) {
+		t.Error("unexpected results -want/+got", cmp.Diff(want, names))
+	}
+}
+
+// decode decodes the results and returns them with the first error.
+func decode(buf *bytes.Buffer) ([]*executetest.Result, error) {
+	results, err := arrow.NewMultiResultDecoder().Decode(ioutil.NopCloser(buf))
+	if err != nil {
+		return nil, err
+	}
+	defer results.Cancel()
+
+	var got []*executetest.Result
+	for results.More() {
+		result := results.Next()
+		r := &executetest.Result{
+			Nm: result.Name(),
+		}
+		if err := result.Tables().Do(func(tbl query.Table) error {
+			cb, err := executetest.ConvertTable(tbl)
+			if err != nil {
+				return err
+			}
+			r.Tbls = append(r.Tbls, cb)
+			return nil
+		}); err != nil {
+			return got, err
+		}
+		r.Normalize()
+		got = append(got, r)
+	}
+	return got, results.Err()
+}
+
+type errorResultIterator struct {
+	Error error
+}
+
+func (r errorResultIterator) More() bool {
+	return false
+}
+
+func (r errorResultIterator) Next() query.Result {
+	panic("no results")
+}
+
+func (r errorResultIterator) Cancel() {
+}
+
+func (r errorResultIterator) Err() error {
+	return r.Error
+}
+
+// benchmarkResult is a result of 10 tables of 10000 rows.
+// The tables are copied into column tables so that each is read as a single chunk,
+// as it would be when produced by the engine.
+func benchmarkResult() query.Result {
+	const (
+		nTables = 10
+		nRows   = 10000
+	)
+	start := values.ConvertTime(time.Date(2018, 4, 17, 0, 0, 0, 0, time.UTC))
+	stop := start + values.Time(nRows*int64(time.Second))
+	r := &tableResult{name: "_result"}
+	for i := 0; i < nTables; i++ {
+		tbl := &executetest.Table{
+			KeyCols: []string{"_start", "_stop", "_measurement", "host"},
+			ColMeta: []query.ColMeta{
+				{Label: "_start", Type: query.TTime},
+				{Label: "_stop", Type: query.TTime},
+				{Label: "_time", Type: query.TTime},
+				{Label: "_measurement", Type: query.TString},
+				{Label: "host", Type: query.TString},
+				{Label: "_value", Type: query.TFloat},
+			},
+			Data: make([][]interface{}, nRows),
+		}
+		host := fmt.Sprintf("host%d", i)
+		for j := range tbl.Data {
+			tbl.Data[j] = []interface{}{
+				start,
+				stop,
+				start + values.Time(int64(j)*int64(time.Second)),
+				"cpu",
+				host,
+				float64(j) * 1.5,
+			}
+		}
+		r.tables = append(r.tables, execute.CopyTable(tbl, executetest.UnlimitedAllocator))
+	}
+	return r
+}
+
+type tableResult struct {
+	name   string
+	tables []query.Table
+}
+
+func (r *tableResult) Name() string {
+	return r.name
+}
+
+func (r *tableResult) Tables() query.TableIterator {
+	return r
+}
+
+func (r *tableResult) Do(f func(query.Table) error) error {
+	for _, tbl := range r.tables {
+		if err := f(tbl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type benchmarkDialect struct {
+	name    string
+	encoder func() query.MultiResultEncoder
+	decoder func() query.MultiResultDecoder
+}
+
+var benchmarkDialects = []benchmarkDialect{
+	{
+		name:    "arrow",
+		encoder: func() query.MultiResultEncoder { return arrow.NewMultiResultEncoder() },
+		decoder: func() query.MultiResultDecoder { return arrow.NewMultiResultDecoder() },
+	},
+	{
+		name:    "csv",
+		encoder: func() query.MultiResultEncoder { return csv.NewMultiResultEncoder(csv.DefaultEncoderConfig()) },
+		decoder: func() query.MultiResultDecoder { return csv.NewMultiResultDecoder(csv.ResultDecoderConfig{}) },
+	},
+}
+
+func BenchmarkMultiResultEncoder(b *testing.B) {
+	result := benchmarkResult()
+	for _, d := range benchmarkDialects {
+		d := d
+		b.Run(d.name, func(b *testing.B) {
+			var buf bytes.Buffer
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				buf.Reset()
+				if _, err := d.encoder().Encode(&buf, query.NewSliceResultIterator([]query.Result{result})); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.SetBytes(int64(buf.Len()))
+		})
+	}
+}
+
+func BenchmarkMultiResultDecoder(b *testing.B) {
+	result := benchmarkResult()
+	for _, d := range benchmarkDialects {
+		d := d
+		b.Run(d.name, func(b *testing.B) {
+			var buf bytes.Buffer
+			if _, err := d.encoder().Encode(&buf, query.NewSliceResultIterator([]query.Result{result})); err != nil {
+				b.Fatal(err)
+			}
+			encoded := buf.Bytes()
+			b.SetBytes(int64(len(encoded)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				results, err := d.decoder().Decode(ioutil.NopCloser(bytes.NewReader(encoded)))
+				if err != nil {
+					b.Fatal(err)
+				}
+				for results.More() {
+					// Read every value, as a consumer of the results would.
+					if err := results.Next().Tables().Do(func(tbl query.Table) error {
+						return tbl.Do(func(cr query.ColReader) error {
+							cr.Times(2)
+							cr.Strings(4)
+							cr.Floats(5)
+							return nil
+						})
+					}); err != nil {
+						b.Fatal(err)
+					}
+				}
+				if err := results.Err(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -71,7 +71,12 @@ type BaseNode struct {
 }
 
 // Location is the source location of the Node
-func (b *BaseNode) Location() *SourceLocation { return b.Loc }
+func (b *BaseNode) Location() *SourceLocation {
+	if b == nil {
+		return nil
+	}
+	return b.Loc
+}
 
 // Program represents a complete program source tree
 type Program struct {
@@ -0,0 +1,546 @@
+package ast
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// indentation is the string used for a single level of indentation in formatted source.
+const indentation = "    "
+
+// Comment represents a line comment in Flux source code.
+// Comments are not part of the syntax tree, they are recovered from the source when formatting.
+type Comment struct {
+	Loc *SourceLocation `json:"location,omitempty"`
+	// Text is the text of the comment including the leading "//".
+	Text string `json:"text"`
+	// Trailing reports whether the comment follows code on the same line.
+	Trailing bool `json:"trailing"`
+}
+
+// Comments scans Flux source code and returns all of its line comments in source order.
+// Comments cannot start inside string or regexp literals.
+func Comments(src string) []*Comment {
+	var (
+		comments []*Comment
+		line     = 1
+		col      = 1
+		// code reports whether the current line contains code before the current position.
+		code bool
+		// operand reports whether the last token may be the left operand of a division.
+		operand bool
+	)
+	for i := 0; i < len(src); {
+		r, size := utf8.DecodeRuneInString(src[i:])
+		n := size
+		switch {
+		case r == '\n':
+			line++
+			col = 1
+			code = false
+			i += size
+			continue
+		case r == '/' && strings.HasPrefix(src[i:], "//"):
+			n = strings.IndexByte(src[i:], '\n')
+			if n < 0 {
+				n = len(src) - i
+			}
+			text := strings.TrimRight(src[i:i+n], " \t\r")
+			comments = append(comments, &Comment{
+				Loc: &SourceLocation{
+					Start:  Position{Line: line, Column: col},
+					End:    Position{Line: line, Column: col + utf8.RuneCountInString(text)},
+					Source: &text,
+				},
+				Text:     text,
+				Trailing: code,
+			})
+		case r == '"' || (r == '/' && !operand):
+			// Skip over the string or regexp literal, honoring escapes.
+			n = scanLiteral(src[i:], r)
+			code = true
+			operand = true
+		case r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+			n = strings.IndexFunc(src[i:], func(r rune) bool {
+				return r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r)
+			})
+			if n < 0 {
+				n = len(src) - i
+			}
+			code = true
+			operand = !keywords[strings.ToLower(src[i:i+n])]
+		case unicode.IsSpace(r):
+		default:
+			code = true
+			operand = r == ')' || r == ']' || r == '}'
+		}
+		lit := src[i : i+n]
+		if nl := strings.Count(lit, "\n"); nl > 0 {
+			line += nl
+			col = utf8.RuneCountInString(lit[strings.LastIndexByte(lit, '\n')+1:]) + 1
+		} else {
+			col += utf8.RuneCountInString(lit)
+		}
+		i += n
+	}
+	return comments
+}
+
+// keywords are the words after which a slash starts a regexp literal instead of a division.
+var keywords = map[string]bool{
+	"and":        true,
+	"or":         true,
+	"not":        true,
+	"in":         true,
+	"empty":      true,
+	"startswith": true,
+	"return":     true,
+}
+
+// scanLiteral returns the length in bytes of the string or regexp literal at the start of src,
+// that is delimited by the delim rune.
+func scanLiteral(src string, delim rune) int {
+	escaped := false
+	for i, r := range src {
+		if i == 0 {
+			continue
+		}
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == delim:
+			return i + 1
+		case r == '\n' && delim == '/':
+			return i
+		}
+	}
+	return len(src)
+}
+
+// Format returns the canonical Flux source code for the node.
+func Format(n Node) string {
+	p := new(printer)
+	p.printNode(n)
+	return p.String()
+}
+
+// FormatWithComments returns the canonical Flux source code for the program,
+// preserving the comments found in src, the source code the program was parsed from.
+func FormatWithComments(program *Program, src string) string {
+	p := &printer{
+		comments: Comments(src),
+	}
+	p.printNode(program)
+	return p.String()
+}
+
+// Operator precedence levels used to decide where parentheses are required.
+// Higher levels bind more tightly.
+const (
+	precLowest = iota
+	precLogical
+	precEquality
+	precRelational
+	precAdditive
+	precMultiplicative
+	precUnary
+	precPrimary
+)
+
+type printer struct {
+	buf    strings.Builder
+	indent int
+
+	// comments are the source comments not yet printed.
+	comments []*Comment
+	// lastLine is the last source line that has been printed, zero if unknown.
+	lastLine int
+	// started reports whether anything has been printed on the current block level.
+	started bool
+}
+
+func (p *printer) String() string {
+	return p.buf.String()
+}
+
+func (p *printer) write(s string) {
+	p.buf.WriteString(s)
+}
+
+func (p *printer) writeIndent() {
+	for i := 0; i < p.indent; i++ {
+		p.write(indentation)
+	}
+}
+
+// lineBreak ends the current line and starts a new indented line for a node starting at loc.
+// Comments that appear in the source before loc are printed in between.
+// A single blank line is kept if the source separates the node from the previous line.
+func (p *printer) lineBreak(loc *SourceLocation) {
+	if loc != nil {
+		p.flushComments(loc.Start)
+	}
+	if p.started {
+		p.write("\n")
+		if loc != nil && p.lastLine > 0 && loc.Start.Line > p.lastLine+1 {
+			p.write("\n")
+		}
+	}
+	p.writeIndent()
+	p.started = true
+	if loc != nil {
+		p.lastLine = loc.Start.Line
+	}
+}
+
+// flushComments prints all pending comments that appear in the source before pos.
+func (p *printer) flushComments(pos Position) {
+	for len(p.comments) > 0 && before(p.comments[0].Loc.Start, pos) {
+		p.printComment(p.comments[0])
+		p.comments = p.comments[1:]
+	}
+}
+
+func (p *printer) printComment(c *Comment) {
+	if c.Trailing && p.started {
+		p.write(" ")
+		p.write(c.Text)
+		p.lastLine = c.Loc.Start.Line
+		return
+	}
+	if p.started {
+		p.write("\n")
+		if p.lastLine > 0 && c.Loc.Start.Line > p.lastLine+1 {
+			p.write("\n")
+		}
+	}
+	p.writeIndent()
+	p.write(c.Text)
+	p.lastLine = c.Loc.Start.Line
+	p.started = true
+}
+
+// markEnd records the last source line of the node as printed.
+func (p *printer) markEnd(n Node) {
+	if loc := n.Location(); loc != nil {
+		p.lastLine = endLine(loc)
+	}
+}
+
+// before reports whether position a comes before position b.
+func before(a, b Position) bool {
+	return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+}
+
+// endLine returns the last source line that contains text of the node at loc.
+// The parser does not track the end line of nodes that span multiple lines, so it is computed from the source text.
+func endLine(loc *SourceLocation) int {
+	if loc.Source == nil {
+		return loc.End.Line
+	}
+	return loc.Start.Line + strings.Count(strings.TrimRightFunc(*loc.Source, unicode.IsSpace), "\n")
+}
+
+func (p *printer) printNode(n Node) {
+	switch n := n.(type) {
+	case *Program:
+		for _, s := range n.Body {
+			p.lineBreak(s.Location())
+			p.printStatement(s)
+		}
+		for _, c := range p.comments {
+			p.printComment(c)
+		}
+		p.comments = nil
+		if p.buf.Len() > 0 {
+			p.write("\n")
+		}
+	case Statement:
+		p.printStatement(n)
+	case Expression:
+		p.printExpression(n, precLowest)
+	case *VariableDeclarator:
+		p.printDeclarator(n)
+	case *Property:
+		p.printProperty(n)
+	}
+}
+
+func (p *printer) printStatement(s Statement) {
+	switch s := s.(type) {
+	case *BlockStatement:
+		p.printBlock(s)
+	case *ExpressionStatement:
+		p.printTopExpression(s.Expression)
+	case *ReturnStatement:
+		p.write("return ")
+		p.printTopExpression(s.Argument)
+	case *OptionStatement:
+		p.write("option ")
+		p.printDeclarator(s.Declaration)
+	case *VariableDeclaration:
+		for i, d := range s.Declarations {
+			if i > 0 {
+				p.lineBreak(nil)
+			}
+			p.printDeclarator(d)
+		}
+	}
+	p.markEnd(s)
+}
+
+func (p *printer) printBlock(b *BlockStatement) {
+	p.write("{")
+	p.started = true
+	p.lastLine = 0
+	p.indent++
+	for _, s := range b.Body {
+		p.lineBreak(s.Location())
+		p.printStatement(s)
+	}
+	if loc := b.Location(); loc != nil {
+		// Comments on the lines before the closing brace belong to the block.
+		p.flushComments(Position{Line: endLine(loc)})
+	}
+	p.indent--
+	p.write("\n")
+	p.writeIndent()
+	p.write("}")
+}
+
+func (p *printer) printDeclarator(d *VariableDeclarator) {
+	p.write(d.ID.Name)
+	p.write(" = ")
+	p.printTopExpression(d.Init)
+}
+
+// printTopExpression prints an expression at the statement level,
+// where pipe expressions are broken so that each call is on its own line.
+func (p *printer) printTopExpression(e Expression) {
+	pipe, ok := e.(*PipeExpression)
+	if !ok {
+		p.printExpression(e, precLowest)
+		return
+	}
+	var calls []*CallExpression
+	var head Expression = pipe
+	for ok {
+		calls = append(calls, pipe.Call)
+		head = pipe.Argument
+		pipe, ok = head.(*PipeExpression)
+	}
+	p.printPipeHead(head)
+	p.indent++
+	for i := len(calls) - 1; i >= 0; i-- {
+		// Blank lines are not kept within a pipeline.
+		p.lastLine = 0
+		p.lineBreak(calls[i].Location())
+		p.write("|> ")
+		p.printExpression(calls[i], precPrimary)
+	}
+	p.indent--
+}
+
+func (p *printer) printPipeHead(e Expression) {
+	switch e.(type) {
+	case *CallExpression, *MemberExpression, *Identifier, *ArrayExpression, *ObjectExpression:
+		p.printExpression(e, precPrimary)
+	default:
+		if _, ok := e.(Literal); ok {
+			p.printExpression(e, precPrimary)
+			return
+		}
+		p.write("(")
+		p.printExpression(e, precLowest)
+		p.write(")")
+	}
+}
+
+// printExpression prints the expression, wrapping it in parentheses if its precedence is below prec.
+func (p *printer) printExpression(e Expression, prec int) {
+	if precedence(e) < prec {
+		p.write("(")
+		defer p.write(")")
+	}
+	switch e := e.(type) {
+	case *ArrayExpression:
+		p.write("[")
+		for i, el := range e.Elements {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.printExpression(el, precPrimary)
+		}
+		p.write("]")
+	case *ArrowFunctionExpression:
+		p.write("(")
+		for i, param := range e.Params {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.write(param.Key.Name)
+			if param.Value != nil {
+				p.write("=")
+				p.printExpression(param.Value, precPrimary)
+			}
+		}
+		p.write(") => ")
+		switch body := e.Body.(type) {
+		case *BlockStatement:
+			p.printBlock(body)
+		case Expression:
+			p.printExpression(body, precLowest)
+		}
+	case *BinaryExpression:
+		prec := operatorPrecedence(e.Operator)
+		p.printExpression(e.Left, prec)
+		p.write(" ")
+		p.write(e.Operator.String())
+		p.write(" ")
+		p.printExpression(e.Right, prec+1)
+	case *LogicalExpression:
+		p.printExpression(e.Left, precLogical)
+		p.write(" ")
+		p.write(e.Operator.String())
+		p.write(" ")
+		p.printExpression(e.Right, precLogical+1)
+	case *UnaryExpression:
+		p.write(e.Operator.String())
+		if e.Operator == NotOperator {
+			p.write(" ")
+		}
+		p.printExpression(e.Argument, precPrimary)
+	case *CallExpression:
+		p.printExpression(e.Callee, precPrimary)
+		p.write("(")
+		for i, arg := range e.Arguments {
+			if i > 0 {
+				p.write(", ")
+			}
+			if obj, ok := arg.(*ObjectExpression); ok {
+				p.printProperties(obj.Properties)
+			} else {
+				p.printExpression(arg, precLowest)
+			}
+		}
+		p.write(")")
+	case *ConditionalExpression:
+		p.write("if ")
+		p.printExpression(e.Test, precLowest)
+		p.write(" then ")
+		p.printExpression(e.Consequent, precLowest)
+		p.write(" else ")
+		p.printExpression(e.Alternate, precLowest)
+	case *MemberExpression:
+		p.printExpression(e.Object, precPrimary)
+		switch prop := e.Property.(type) {
+		case *Identifier:
+			p.write(".")
+			p.write(prop.Name)
+		default:
+			p.write("[")
+			p.printExpression(prop, precPrimary)
+			p.write("]")
+		}
+	case *PipeExpression:
+		p.printPipeHead(e.Argument)
+		p.write(" |> ")
+		p.printExpression(e.Call, precPrimary)
+	case *ObjectExpression:
+		p.write("{")
+		p.printProperties(e.Properties)
+		p.write("}")
+	case *Identifier:
+		p.write(e.Name)
+	case *PipeLiteral:
+		p.write("<-")
+	case *StringLiteral:
+		p.write(formatString(e.Value))
+	case *BooleanLiteral:
+		p.write(strconv.FormatBool(e.Value))
+	case *FloatLiteral:
+		s := strconv.FormatFloat(e.Value, 'f', -1, 64)
+		if !strings.ContainsRune(s, '.') {
+			s += ".0"
+		}
+		p.write(s)
+	case *IntegerLiteral:
+		p.write(strconv.FormatInt(e.Value, 10))
+	case *UnsignedIntegerLiteral:
+		p.write(strconv.FormatUint(e.Value, 10))
+	case *RegexpLiteral:
+		p.write("/")
+		p.write(strings.Replace(e.Value.String(), "/", "\\/", -1))
+		p.write("/")
+	case *DurationLiteral:
+		for _, d := range e.Values {
+			p.write(strconv.FormatInt(d.Magnitude, 10))
+			p.write(d.Unit)
+		}
+	case *DateTimeLiteral:
+		p.write(e.Value.Format(time.RFC3339Nano))
+	}
+}
+
+func (p *printer) printProperties(props []*Property) {
+	for i, prop := range props {
+		if i > 0 {
+			p.write(", ")
+		}
+		p.printProperty(prop)
+	}
+}
+
+func (p *printer) printProperty(prop *Property) {
+	p.write(prop.Key.Name)
+	if prop.Value != nil {
+		p.write(": ")
+		p.printExpression(prop.Value, precLowest)
+	}
+}
+
+// formatString returns the Flux string literal for s.
+// Double quotes are the only escaped characters in Flux strings.
+func formatString(s string) string {
+	return `"` + strings.Replace(s, `"`, `\"`, -1) + `"`
+}
+
+func precedence(e Expression) int {
+	switch e := e.(type) {
+	case *ArrowFunctionExpression, *ConditionalExpression:
+		return precLowest
+	case *LogicalExpression:
+		return precLogical
+	case *BinaryExpression:
+		return operatorPrecedence(e.Operator)
+	case *UnaryExpression:
+		return precUnary
+	case *IntegerLiteral:
+		if e.Value < 0 {
+			return precUnary
+		}
+	case *FloatLiteral:
+		if e.Value < 0 {
+			return precUnary
+		}
+	}
+	return precPrimary
+}
+
+func operatorPrecedence(op OperatorKind) int {
+	switch op {
+	case MultiplicationOperator, DivisionOperator:
+		return precMultiplicative
+	case AdditionOperator, SubtractionOperator:
+		return precAdditive
+	case EqualOperator, NotEqualOperator, RegexpMatchOperator, NotRegexpMatchOperator:
+		return precEquality
+	default:
+		return precRelational
+	}
+}
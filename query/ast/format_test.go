@@ -0,0 +1,223 @@
+package ast_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/EMCECS/influx/query/ast"
+	"github.com/EMCECS/influx/query/ast/asttest"
+	"github.com/EMCECS/influx/query/parser"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFormat(t *testing.T) {
+	testCases := []struct {
+		name string
+		node ast.Node
+		want string
+	}{
+		{
+			name: "binary expression precedence",
+			node: &ast.BinaryExpression{
+				Operator: ast.MultiplicationOperator,
+				Left: &ast.BinaryExpression{
+					Operator: ast.AdditionOperator,
+					Left:     &ast.IntegerLiteral{Value: 1},
+					Right:    &ast.IntegerLiteral{Value: 2},
+				},
+				Right: &ast.BinaryExpression{
+					Operator: ast.SubtractionOperator,
+					Left:     &ast.IntegerLiteral{Value: 3},
+					Right:    &ast.IntegerLiteral{Value: 4},
+				},
+			},
+			want: `(1 + 2) * (3 - 4)`,
+		},
+		{
+			name: "left associative",
+			node: &ast.BinaryExpression{
+				Operator: ast.SubtractionOperator,
+				Left: &ast.BinaryExpression{
+					Operator: ast.SubtractionOperator,
+					Left:     &ast.Identifier{Name: "a"},
+					Right:    &ast.Identifier{Name: "b"},
+				},
+				Right: &ast.Identifier{Name: "c"},
+			},
+			want: `a - b - c`,
+		},
+		{
+			name: "literals",
+			node: &ast.ArrayExpression{
+				Elements: []ast.Expression{
+					&ast.StringLiteral{Value: `a "quoted" string`},
+					&ast.FloatLiteral{Value: 2},
+					&ast.FloatLiteral{Value: 0.5},
+					&ast.BooleanLiteral{Value: true},
+					&ast.RegexpLiteral{Value: regexp.MustCompile(`^a/b$`)},
+					&ast.DurationLiteral{Values: []ast.Duration{{Magnitude: 1, Unit: "h"}, {Magnitude: 30, Unit: "m"}}},
+					&ast.DateTimeLiteral{Value: time.Date(2018, 5, 22, 19, 53, 26, 0, time.UTC)},
+				},
+			},
+			want: `["a \"quoted\" string", 2.0, 0.5, true, /^a\/b$/, 1h30m, 2018-05-22T19:53:26Z]`,
+		},
+		{
+			name: "unary expression",
+			node: &ast.UnaryExpression{
+				Operator: ast.NotOperator,
+				Argument: &ast.LogicalExpression{
+					Operator: ast.AndOperator,
+					Left:     &ast.Identifier{Name: "a"},
+					Right:    &ast.Identifier{Name: "b"},
+				},
+			},
+			want: `not (a and b)`,
+		},
+		{
+			name: "arrow function with block",
+			node: &ast.Program{
+				Body: []ast.Statement{
+					&ast.VariableDeclaration{
+						Declarations: []*ast.VariableDeclarator{{
+							ID: &ast.Identifier{Name: "f"},
+							Init: &ast.ArrowFunctionExpression{
+								Params: []*ast.Property{
+									{Key: &ast.Identifier{Name: "table"}, Value: &ast.PipeLiteral{}},
+									{Key: &ast.Identifier{Name: "n"}},
+								},
+								Body: &ast.BlockStatement{
+									Body: []ast.Statement{
+										&ast.ReturnStatement{
+											Argument: &ast.MemberExpression{
+												Object:   &ast.Identifier{Name: "table"},
+												Property: &ast.StringLiteral{Value: "n"},
+											},
+										},
+									},
+								},
+							},
+						}},
+					},
+				},
+			},
+			want: "f = (table=<-, n) => {\n    return table[\"n\"]\n}\n",
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			if got := ast.Format(tc.node); got != tc.want {
+				t.Errorf("unexpected formatted source -want/+got:\n%s", cmp.Diff(tc.want, got))
+			}
+		})
+	}
+}
+
+func TestFormatWithComments(t *testing.T) {
+	testCases := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "pipeline",
+			src: `from(bucket:"telegraf/autogen")
+  |> range(start:-1h)
+  |> filter(fn: (r) => r._measurement=="cpu" and r._field   == "usage_user")`,
+			want: `from(bucket: "telegraf/autogen")
+    |> range(start: -1h)
+    |> filter(fn: (r) => r._measurement == "cpu" and r._field == "usage_user")
+`,
+		},
+		{
+			name: "comments",
+			src: `// leading comment
+a = 1 // trailing comment
+
+
+// comment on b
+b = from(bucket:"x")
+  // before range
+  |> range(start:-1h) // after range
+c = (r) => {
+  // inside block
+  return r
+}
+// final comment
+`,
+			want: `// leading comment
+a = 1 // trailing comment
+
+// comment on b
+b = from(bucket: "x")
+    // before range
+    |> range(start: -1h) // after range
+c = (r) => {
+    // inside block
+    return r
+}
+// final comment
+`,
+		},
+		{
+			name: "comment markers in literals",
+			src: `a = "http://example.com" // url
+b = filter(fn: (r) => r.path =~ /\/\/"/)`,
+			want: `a = "http://example.com" // url
+b = filter(fn: (r) => r.path =~ /\/\/"/)
+`,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			program, err := parser.NewAST(tc.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ast.FormatWithComments(program, tc.src); got != tc.want {
+				t.Errorf("unexpected formatted source -want/+got:\n%s", cmp.Diff(tc.want, got))
+			}
+		})
+	}
+}
+
+// TestFormat_RoundTrip checks that formatting the test queries produces source that parses
+// into the same syntax tree and that formatting is stable.
+func TestFormat_RoundTrip(t *testing.T) {
+	files, err := filepath.Glob("../functions/testdata/*.flux")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no test queries found")
+	}
+	for _, file := range files {
+		file := file
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			src, err := ioutil.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			program, err := parser.NewAST(string(src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			formatted := ast.FormatWithComments(program, string(src))
+
+			got, err := parser.NewAST(formatted)
+			if err != nil {
+				t.Fatalf("failed to parse formatted source: %v\n%s", err, formatted)
+			}
+			if !cmp.Equal(program, got, asttest.CompareOptions...) {
+				t.Errorf("formatted source produced a different program -want/+got:\n%s", cmp.Diff(program, got, asttest.CompareOptions...))
+			}
+			if again := ast.FormatWithComments(got, formatted); again != formatted {
+				t.Errorf("formatting is not stable -want/+got:\n%s", cmp.Diff(formatted, again))
+			}
+		})
+	}
+}
@@ -87,7 +87,13 @@ func NewMapResultIterator(results map[string]Result) *MapResultIterator {
 	for k := range results {
 		order = append(order, k)
 	}
-	sort.Strings(order)
+	// The profiler result is last, since it is complete only once all other results have been read.
+	sort.Slice(order, func(i, j int) bool {
+		if (order[i] == ProfilerResultName) != (order[j] == ProfilerResultName) {
+			return order[j] == ProfilerResultName
+		}
+		return order[i] < order[j]
+	})
 	return &MapResultIterator{
 		results: results,
 		order:   order,
@@ -146,16 +152,26 @@ type ProxyQueryServiceBridge struct {
 }
 
 func (b ProxyQueryServiceBridge) Query(ctx context.Context, w io.Writer, req *ProxyRequest) (n int64, err error) {
+	n, _, err = b.QueryWithStatistics(ctx, w, req)
+	return n, err
+}
+
+// QueryWithStatistics implements ProxyQueryStatisticser.
+// The statistics are empty if the results of the QueryService do not report statistics.
+func (b ProxyQueryServiceBridge) QueryWithStatistics(ctx context.Context, w io.Writer, req *ProxyRequest) (n int64, stats Statistics, err error) {
 	results, err := b.QueryService.Query(ctx, &req.Request)
 	if err != nil {
-		return 0, err
+		return 0, stats, err
 	}
 	defer results.Cancel()
 	encoder := req.Dialect.Encoder()
 	n, err = encoder.Encode(w, results)
 	if err != nil {
-		return n, err
+		return n, stats, err
 	}
 
-	return n, nil
+	if s, ok := results.(Statisticser); ok {
+		stats = s.Statistics()
+	}
+	return n, stats, nil
 }
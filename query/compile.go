@@ -46,6 +46,7 @@ func Compile(ctx context.Context, q string, now time.Time, opts ...Option) (*Spe
 	s, _ := opentracing.StartSpanFromContext(ctx, "parse")
 	itrp := NewInterpreter()
 	itrp.SetOption(nowOption, nowFunc(now))
+	bindContextFunctions(ctx, itrp)
 	if err := Eval(itrp, q); err != nil {
 		return nil, err
 	}
@@ -148,6 +149,9 @@ var builtinValues = make(map[string]values.Value)
 var builtinOptions = make(map[string]values.Value)
 var builtinDeclarations = make(semantic.DeclarationScope)
 
+// builtinPackages are the builtin objects whose properties are package functions.
+var builtinPackages = make(map[string]values.Object)
+
 // list of builtin scripts
 var builtinScripts = make(map[string]string)
 var finalized bool
@@ -189,6 +193,33 @@ func RegisterFunctionWithSideEffect(name string, c CreateOperationSpec, sig sema
 	RegisterBuiltInValue(name, &f)
 }
 
+// RegisterPackageFunction adds a new builtin function to the package pkg.
+// The package is a builtin object, and the function is called as pkg.name.
+// c is a function reference of type CreateOperationSpec
+// sig is a function signature type that specifies the names and types of each argument for the function
+func RegisterPackageFunction(pkg, name string, c CreateOperationSpec, sig semantic.FunctionSignature) {
+	if finalized {
+		panic(errors.New("already finalized, cannot register builtin"))
+	}
+	obj, ok := builtinPackages[pkg]
+	if !ok {
+		obj = values.NewObject()
+		RegisterBuiltInValue(pkg, obj)
+		builtinPackages[pkg] = obj
+	}
+	if _, ok := obj.Get(name); ok {
+		panic(fmt.Errorf("duplicate registration for builtin %s.%s", pkg, name))
+	}
+	obj.Set(name, &function{
+		t:             semantic.NewFunctionType(sig),
+		name:          pkg + "." + name,
+		createOpSpec:  c,
+		hasSideEffect: false,
+	})
+	// The type of the package changes with each function.
+	builtinDeclarations[pkg] = semantic.NewExternalVariableDeclaration(pkg, obj.Type())
+}
+
 // RegisterBuiltInValue adds the value to the builtin scope.
 func RegisterBuiltInValue(name string, v values.Value) {
 	if finalized {
@@ -631,6 +662,15 @@ func (a Arguments) GetDuration(name string) (Duration, bool, error) {
 	return Duration(v.Duration()), true, nil
 }
 
+// GetCalendarDuration returns a duration argument keeping its calendar months apart from its fixed duration.
+func (a Arguments) GetCalendarDuration(name string) (values.CalendarDuration, bool, error) {
+	v, ok := a.Get(name)
+	if !ok {
+		return values.CalendarDuration{}, false, nil
+	}
+	return values.CalendarDurationOf(v), true, nil
+}
+
 func (a Arguments) GetRequiredDuration(name string) (Duration, error) {
 	d, ok, err := a.GetDuration(name)
 	if err != nil {
@@ -0,0 +1,675 @@
+package control
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/EMCECS/influx"
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/execute"
+	"github.com/EMCECS/influx/query/plan"
+	"github.com/EMCECS/influx/query/values"
+)
+
+// Cache caches the results of queries, so that the queries repeated with the same spec,
+// such as the queries of the cells of a dashboard as it refreshes, are not executed again.
+//
+// The results are cached by organization and spec, the now time of the spec aside,
+// along with the absolute bounds their plan resolves from the spec.
+// A query whose plan has the bounds of the cached results reads them instead of being executed.
+// A query whose procedures are all sliceable and share bounds that start within the cached bounds and stop after them,
+// is only executed over the time slice that follows the cached bounds,
+// its results are the cached results within its bounds merged with the results of the slice.
+// The procedures may also group the rows into windows that follow each other from the Unix epoch,
+// and process the tables of each window independently of the others, such as aggregates and selectors.
+// The slice of such a query then starts with the last cached window, which is clipped by the cached bounds,
+// and the cached tables of the windows before it are reused as they are.
+//
+// Only the queries that read buckets and have no side effects are cached.
+// The cached results that read a bucket are invalidated when points are written to it within their bounds,
+// the points written after their bounds are read as part of the next slice instead.
+// The least recently used results are evicted once the cache exceeds its size.
+type Cache struct {
+	maxBytes int64
+
+	mu   sync.Mutex
+	size int64
+	// lru holds the entries, the most recently used first.
+	lru     *list.List
+	entries map[string]*list.Element
+	// pending holds the lookups whose results are being recorded.
+	pending map[*cacheLookup]struct{}
+}
+
+// NewCache creates a cache holding at most maxBytes of encoded tables.
+func NewCache(maxBytes int64) *Cache {
+	return &Cache{
+		maxBytes: maxBytes,
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+		pending:  make(map[*cacheLookup]struct{}),
+	}
+}
+
+// Size reports the number of bytes of the cached results.
+func (c *Cache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// Invalidate invalidates the cached results of the organization that read the bucket
+// and whose bounds overlap the points written to it, from min to max.
+func (c *Cache) Invalidate(orgID platform.ID, bucket *platform.Bucket, min, max time.Time) {
+	start, stop := values.ConvertTime(min), values.ConvertTime(max)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for e := c.lru.Front(); e != nil; {
+		next := e.Next()
+		if entry := e.Value.(*cacheEntry); entry.invalidatedBy(orgID, bucket, start, stop) {
+			c.remove(e)
+		}
+		e = next
+	}
+	// The results being recorded may have been read before the points were written.
+	for l := range c.pending {
+		if l.entry.invalidatedBy(orgID, bucket, start, stop) {
+			delete(c.pending, l)
+		}
+	}
+}
+
+// lookup looks up the cached results of the query planned as p.
+// It returns nil if the results of the query cannot be cached.
+func (c *Cache) lookup(orgID platform.ID, spec *query.Spec, p *plan.PlanSpec) *cacheLookup {
+	key, buckets, ok := cacheKey(orgID, spec)
+	if !ok {
+		return nil
+	}
+	entry := &cacheEntry{
+		key:     key,
+		orgID:   orgID,
+		buckets: buckets,
+		bounds:  make(map[plan.ProcedureID]plan.BoundsSpec, len(p.Procedures)),
+		results: make(map[string][][]byte, len(p.Results)),
+	}
+	sliceable := true
+	for id, pr := range p.Procedures {
+		if s, ok := pr.Spec.(plan.SideEffectProcedureSpec); ok && s.SideEffect() {
+			return nil
+		}
+		if pr.Bounds == nil {
+			sliceable = false
+			continue
+		}
+		entry.bounds[id] = *pr.Bounds
+		if entry.slice == nil {
+			slice := *pr.Bounds
+			entry.slice = &slice
+		} else if *entry.slice != *pr.Bounds {
+			sliceable = false
+		}
+	}
+	if !sliceable || !entry.sliceable(p) {
+		entry.slice = nil
+		entry.windows = nil
+		entry.windowed = nil
+	}
+
+	l := &cacheLookup{
+		c:         c,
+		entry:     entry,
+		remaining: make(map[string]bool, len(p.Results)),
+	}
+	for name := range p.Results {
+		l.remaining[name] = true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		cached := e.Value.(*cacheEntry)
+		switch {
+		case cached.hasBounds(entry.bounds):
+			c.lru.MoveToFront(e)
+			l.hit = cached
+			// The results are not recorded again.
+			return l
+		default:
+			if delta, ok := cached.delta(entry); ok {
+				c.lru.MoveToFront(e)
+				l.hit = cached
+				l.delta = &delta
+			}
+		}
+	}
+	c.pending[l] = struct{}{}
+	return l
+}
+
+// cacheKey returns the key of the cached results of the query spec of the organization,
+// and the buckets the spec reads.
+// It reports false if the results of the spec cannot be cached.
+func cacheKey(orgID platform.ID, spec *query.Spec) (string, []platform.BucketFilter, bool) {
+	readBuckets, writeBuckets, err := spec.BucketsAccessed()
+	if err != nil || len(readBuckets) == 0 || len(writeBuckets) > 0 {
+		return "", nil, false
+	}
+	// The data read by the sources is only known to be invalidated by writes if they all read buckets.
+	for _, o := range spec.Operations {
+		if len(spec.Parents(o.ID)) > 0 {
+			continue
+		}
+		if _, ok := o.Spec.(query.BucketAwareOperationSpec); !ok {
+			return "", nil, false
+		}
+	}
+
+	normalized := *spec
+	normalized.Now = time.Time{}
+	data, err := json.Marshal(&normalized)
+	if err != nil {
+		return "", nil, false
+	}
+	h := sha256.New()
+	h.Write(orgID)
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil)), readBuckets, true
+}
+
+// store caches the entry, replacing the entry with the same key, and evicts the least recently used entries.
+// The caller must hold the lock.
+func (c *Cache) store(entry *cacheEntry) {
+	if e, ok := c.entries[entry.key]; ok {
+		c.remove(e)
+	}
+	if entry.size > c.maxBytes {
+		return
+	}
+	c.entries[entry.key] = c.lru.PushFront(entry)
+	c.size += entry.size
+	for c.size > c.maxBytes {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove removes the element of an entry.
+// The caller must hold the lock.
+func (c *Cache) remove(e *list.Element) {
+	entry := c.lru.Remove(e).(*cacheEntry)
+	delete(c.entries, entry.key)
+	c.size -= entry.size
+}
+
+// cacheEntry holds the cached results of a query.
+type cacheEntry struct {
+	key     string
+	orgID   platform.ID
+	buckets []platform.BucketFilter
+	// bounds holds the bounds of the bounded procedures of the plan of the query.
+	bounds map[plan.ProcedureID]plan.BoundsSpec
+	// slice is the bounds shared by the procedures of a sliceable plan, it is nil if the plan is not sliceable.
+	slice *plan.BoundsSpec
+	// windows is the windows the rows of a sliceable plan are grouped into, it is nil if they are not windowed.
+	windows *cacheWindows
+	// windowed holds the names of the results whose tables are windows.
+	windowed map[string]bool
+
+	// results holds the encoded tables of each result.
+	results map[string][][]byte
+	size    int64
+}
+
+// cacheWindows is the windows of a sliceable plan.
+type cacheWindows struct {
+	every values.Duration
+	// startCol is the label of the column of the start of the windows, in the group keys of their tables.
+	startCol string
+}
+
+// sliceable reports whether the results of the plan of the entry can be merged from the results of its time slices,
+// and records the windows of the plan.
+// The procedures must be sliceable, or group the rows into a single kind of windows and preserve the group keys after them.
+func (e *cacheEntry) sliceable(p *plan.PlanSpec) bool {
+	type state struct{ windowed, ok bool }
+	states := make(map[plan.ProcedureID]state, len(p.Procedures))
+	var visit func(id plan.ProcedureID) state
+	visit = func(id plan.ProcedureID) state {
+		if s, ok := states[id]; ok {
+			return s
+		}
+		pr, ok := p.Procedures[id]
+		if !ok {
+			return state{}
+		}
+		// Guard against cycles while the parents are visited.
+		states[id] = state{}
+		var s state
+		for i, parent := range pr.Parents {
+			ps := visit(parent)
+			if !ps.ok || (i > 0 && ps.windowed != s.windowed) {
+				return state{}
+			}
+			s.windowed = ps.windowed
+		}
+		switch spec := pr.Spec.(type) {
+		case plan.WindowSliceableProcedureSpec:
+			every, startCol, ok := spec.WindowSliceable()
+			if !ok || s.windowed {
+				return state{}
+			}
+			windows := &cacheWindows{every: every, startCol: startCol}
+			if e.windows != nil && *e.windows != *windows {
+				return state{}
+			}
+			e.windows = windows
+			s.windowed = true
+		case plan.SliceableProcedureSpec:
+			if !spec.Sliceable() {
+				return state{}
+			}
+		case plan.KeyPreservingProcedureSpec:
+			// The tables of each window are processed independently of the other windows.
+			if !spec.PreservesKey() || !s.windowed {
+				return state{}
+			}
+		default:
+			return state{}
+		}
+		s.ok = true
+		states[id] = s
+		return s
+	}
+	for id := range p.Procedures {
+		if !visit(id).ok {
+			return false
+		}
+	}
+	e.windowed = make(map[string]bool, len(p.Results))
+	for name, y := range p.Results {
+		if visit(y.ID).windowed {
+			e.windowed[name] = true
+		}
+	}
+	return true
+}
+
+// delta returns the time slice a query of the entry o is executed over to reuse the cached results of e.
+// It reports false if the results of e cannot be reused by the query.
+func (e *cacheEntry) delta(o *cacheEntry) (plan.BoundsSpec, bool) {
+	if e.slice == nil || o.slice == nil {
+		return plan.BoundsSpec{}, false
+	}
+	cached, bounds := *e.slice, *o.slice
+	if cached.Start > bounds.Start || bounds.Start >= cached.Stop || cached.Stop >= bounds.Stop {
+		return plan.BoundsSpec{}, false
+	}
+	start := cached.Stop
+	if e.windows != nil {
+		// The first cached window is clipped by the cached bounds,
+		// it is only the first window of the query if the query starts at the same time or at its end.
+		if bounds.Start != cached.Start && bounds.Start.Truncate(e.windows.every) != bounds.Start {
+			return plan.BoundsSpec{}, false
+		}
+		// The last cached window is clipped by the cached bounds, it is executed again.
+		start = cached.Stop.Truncate(e.windows.every)
+		if start <= bounds.Start {
+			return plan.BoundsSpec{}, false
+		}
+	}
+	return plan.BoundsSpec{Start: start, Stop: bounds.Stop}, true
+}
+
+func (e *cacheEntry) hasBounds(bounds map[plan.ProcedureID]plan.BoundsSpec) bool {
+	if len(e.bounds) != len(bounds) {
+		return false
+	}
+	for id, b := range e.bounds {
+		if o, ok := bounds[id]; !ok || o != b {
+			return false
+		}
+	}
+	return true
+}
+
+// invalidatedBy reports whether the points written to the bucket of the organization from start to stop
+// may be read by the query of the entry.
+func (e *cacheEntry) invalidatedBy(orgID platform.ID, bucket *platform.Bucket, start, stop values.Time) bool {
+	if !bytes.Equal(e.orgID, orgID) {
+		return false
+	}
+	read := false
+	for _, f := range e.buckets {
+		if (f.ID != nil && bytes.Equal(*f.ID, bucket.ID)) || (f.Name != nil && *f.Name == bucket.Name) {
+			read = true
+			break
+		}
+	}
+	if !read {
+		return false
+	}
+	for _, b := range e.bounds {
+		if start < b.Stop && stop >= b.Start {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheLookup is the lookup of the cached results of a query.
+// Unless the query reads the cached results, its results are recorded as they are read,
+// and they are cached once they have all been read.
+type cacheLookup struct {
+	c *Cache
+	// entry is the entry of the results of the query.
+	entry *cacheEntry
+	// hit is the entry whose results are reused by the query, it is nil if the results were not cached.
+	hit *cacheEntry
+	// delta is the time slice the query is executed over when it reuses a part of the results of hit.
+	delta *plan.BoundsSpec
+	// remaining holds the names of the results that have not been recorded yet.
+	remaining map[string]bool
+}
+
+// full reports whether the query reads the cached results instead of being executed.
+func (l *cacheLookup) full() bool {
+	return l.hit != nil && l.delta == nil
+}
+
+// slice bounds the procedures of the plan to the time slice the query is executed over.
+func (l *cacheLookup) slice(p *plan.PlanSpec) {
+	for _, pr := range p.Procedures {
+		pr.Bounds = &plan.BoundsSpec{
+			Start: l.delta.Start,
+			Stop:  l.delta.Stop,
+		}
+	}
+}
+
+// results returns the cached results, their tables are allocated from a as they are read.
+func (l *cacheLookup) results(a *execute.Allocator) map[string]query.Result {
+	results := make(map[string]query.Result, len(l.hit.results))
+	for name, tables := range l.hit.results {
+		results[name] = &cachedResult{
+			name:   name,
+			tables: tables,
+			alloc:  a,
+		}
+	}
+	return results
+}
+
+// wrap returns the results of the executed query,
+// they are merged with the cached results reused by the query and recorded as they are read.
+func (l *cacheLookup) wrap(results map[string]query.Result, a *execute.Allocator) map[string]query.Result {
+	wrapped := make(map[string]query.Result, len(results))
+	for name, r := range results {
+		wrapped[name] = &recordingResult{
+			Result: r,
+			name:   name,
+			l:      l,
+			alloc:  a,
+		}
+	}
+	return wrapped
+}
+
+// record records the encoded tables of a result, and caches the results once they have all been recorded.
+func (l *cacheLookup) record(name string, tables [][]byte, size int64) {
+	l.c.mu.Lock()
+	defer l.c.mu.Unlock()
+	if _, ok := l.c.pending[l]; !ok || !l.remaining[name] {
+		return
+	}
+	delete(l.remaining, name)
+	l.entry.results[name] = tables
+	l.entry.size += size
+	if l.entry.size > l.c.maxBytes {
+		delete(l.c.pending, l)
+		return
+	}
+	if len(l.remaining) == 0 {
+		delete(l.c.pending, l)
+		l.c.store(l.entry)
+	}
+}
+
+// close stops recording the results of the query.
+func (l *cacheLookup) close() {
+	l.c.mu.Lock()
+	defer l.c.mu.Unlock()
+	delete(l.c.pending, l)
+}
+
+// cachedResult is a result read from the cache.
+type cachedResult struct {
+	name   string
+	tables [][]byte
+	alloc  *execute.Allocator
+}
+
+func (r *cachedResult) Name() string {
+	return r.name
+}
+
+func (r *cachedResult) Tables() query.TableIterator {
+	return r
+}
+
+func (r *cachedResult) Do(f func(query.Table) error) error {
+	for _, data := range r.tables {
+		tbl, err := execute.DecodeTable(bytes.NewReader(data), r.alloc)
+		if err != nil {
+			return err
+		}
+		if err := f(tbl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordingResult is the result of an executed query whose results are cached.
+// Its tables are recorded as they are read, after they are merged with the cached tables the query reuses.
+type recordingResult struct {
+	query.Result
+	// name is the name of the result in the results of the query.
+	name  string
+	l     *cacheLookup
+	alloc *execute.Allocator
+}
+
+func (r *recordingResult) Tables() query.TableIterator {
+	return r
+}
+
+func (r *recordingResult) Do(f func(query.Table) error) error {
+	var (
+		tables   [][]byte
+		size     int64
+		tooLarge bool
+	)
+	process := func(tbl query.Table) error {
+		if !tooLarge {
+			// The table is encoded before it is read, since tables can be read only once.
+			tbl = execute.CacheOneTimeTable(tbl, r.alloc)
+			var buf bytes.Buffer
+			if err := execute.EncodeTable(&buf, tbl); err != nil {
+				return err
+			}
+			size += int64(buf.Len())
+			if size > r.l.c.maxBytes {
+				tables, tooLarge = nil, true
+			} else {
+				tables = append(tables, buf.Bytes())
+			}
+		}
+		return f(tbl)
+	}
+
+	var err error
+	if r.l.delta != nil {
+		err = r.merge(process)
+	} else {
+		err = r.Result.Tables().Do(process)
+	}
+	if err != nil {
+		return err
+	}
+	if tooLarge {
+		r.l.close()
+		return nil
+	}
+	r.l.record(r.name, tables, size)
+	return nil
+}
+
+// merge merges the tables of the result over the time slice with the cached tables of the result,
+// into the tables of the result over the bounds of the query.
+func (r *recordingResult) merge(f func(query.Table) error) error {
+	if r.l.entry.windowed[r.name] {
+		return r.mergeWindows(f)
+	}
+	m := &sliceMerger{
+		bounds:   *r.l.entry.slice,
+		alloc:    r.alloc,
+		builders: execute.NewGroupLookup(),
+	}
+	for _, data := range r.l.hit.results[r.name] {
+		tbl, err := execute.DecodeTable(bytes.NewReader(data), r.alloc)
+		if err != nil {
+			return err
+		}
+		// The rows of the slice are read again from its start.
+		if err := m.add(tbl, r.l.delta.Start); err != nil {
+			return err
+		}
+	}
+	if err := r.Result.Tables().Do(func(tbl query.Table) error {
+		return m.add(tbl, r.l.delta.Stop)
+	}); err != nil {
+		return err
+	}
+	return m.do(f)
+}
+
+// mergeWindows reads the cached tables of the windows that start within the bounds of the query before the time slice,
+// followed by the tables of the windows of the time slice.
+func (r *recordingResult) mergeWindows(f func(query.Table) error) error {
+	startCol := r.l.hit.windows.startCol
+	for _, data := range r.l.hit.results[r.name] {
+		tbl, err := execute.DecodeTable(bytes.NewReader(data), r.alloc)
+		if err != nil {
+			return err
+		}
+		key := tbl.Key()
+		j := boundsColIdx(startCol, key.Cols())
+		if j < 0 {
+			return fmt.Errorf("cannot merge the cached tables of group %v, their group key has no %s column", key, startCol)
+		}
+		if start := key.ValueTime(j); start < r.l.entry.slice.Start || start >= r.l.delta.Start {
+			continue
+		}
+		if err := f(tbl); err != nil {
+			return err
+		}
+	}
+	return r.Result.Tables().Do(f)
+}
+
+// sliceMerger merges the tables of the time slices of a result into the tables of the bounds of the query,
+// by concatenating the rows of the tables with the same group key once their _start and _stop columns are the bounds.
+// The rows of the tables before the bounds are dropped.
+type sliceMerger struct {
+	bounds   plan.BoundsSpec
+	alloc    *execute.Allocator
+	builders *execute.GroupLookup
+}
+
+// add adds the rows of the table before stop.
+func (m *sliceMerger) add(tbl query.Table, stop values.Time) error {
+	key := m.key(tbl.Key())
+	var builder *execute.ColListTableBuilder
+	if b, ok := m.builders.Lookup(key); ok {
+		builder = b.(*execute.ColListTableBuilder)
+		if !execute.EqualCols(builder.Cols(), tbl.Cols()) {
+			return fmt.Errorf("cannot merge the tables of group %v with the cached tables, their columns differ", key)
+		}
+	} else {
+		builder = execute.NewColListTableBuilder(key, m.alloc)
+		execute.AddTableCols(tbl, builder)
+		m.builders.Set(key, builder)
+	}
+
+	cols := tbl.Cols()
+	timeIdx := boundsColIdx(execute.DefaultTimeColLabel, cols)
+	startIdx := boundsColIdx(execute.DefaultStartColLabel, cols)
+	stopIdx := boundsColIdx(execute.DefaultStopColLabel, cols)
+	return tbl.Do(func(cr query.ColReader) error {
+		for i, l := 0, cr.Len(); i < l; i++ {
+			if timeIdx >= 0 && !execute.IsNull(i, timeIdx, cr) {
+				if t := cr.Times(timeIdx)[i]; t < m.bounds.Start || t >= stop {
+					continue
+				}
+			}
+			execute.AppendRecord(i, cr, builder)
+			n := builder.NRows() - 1
+			if startIdx >= 0 {
+				builder.SetTime(n, startIdx, m.bounds.Start)
+			}
+			if stopIdx >= 0 {
+				builder.SetTime(n, stopIdx, m.bounds.Stop)
+			}
+		}
+		return nil
+	})
+}
+
+// key returns the group key of the tables over the bounds of the query.
+func (m *sliceMerger) key(key query.GroupKey) query.GroupKey {
+	cols := key.Cols()
+	vs := make([]values.Value, len(cols))
+	for j := range cols {
+		switch j {
+		case boundsColIdx(execute.DefaultStartColLabel, cols):
+			vs[j] = values.NewTimeValue(m.bounds.Start)
+		case boundsColIdx(execute.DefaultStopColLabel, cols):
+			vs[j] = values.NewTimeValue(m.bounds.Stop)
+		default:
+			vs[j] = key.Value(j)
+		}
+	}
+	return execute.NewGroupKey(cols, vs)
+}
+
+// do calls f with the merged tables that have rows, in the order of their group keys.
+func (m *sliceMerger) do(f func(query.Table) error) error {
+	var err error
+	m.builders.Range(func(key query.GroupKey, value interface{}) {
+		builder := value.(*execute.ColListTableBuilder)
+		if err != nil || builder.NRows() == 0 {
+			return
+		}
+		var tbl query.Table
+		if tbl, err = builder.Table(); err != nil {
+			return
+		}
+		err = f(tbl)
+	})
+	return err
+}
+
+// boundsColIdx returns the index of the time column with the label, or -1 if there is none.
+func boundsColIdx(label string, cols []query.ColMeta) int {
+	j := execute.ColIdx(label, cols)
+	if j < 0 || cols[j].Type != query.TTime {
+		return -1
+	}
+	return j
+}
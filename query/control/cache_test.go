@@ -0,0 +1,345 @@
+package control
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/EMCECS/influx"
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/execute"
+	"github.com/EMCECS/influx/query/execute/executetest"
+	"github.com/EMCECS/influx/query/mock"
+	"github.com/EMCECS/influx/query/plan"
+	"github.com/EMCECS/influx/query/values"
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+var cacheEpoch = time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// cacheTestData returns the tables of a point per minute and host read over the bounds.
+func cacheTestData(bounds plan.BoundsSpec) []*executetest.Table {
+	var tables []*executetest.Table
+	for _, host := range []string{"a", "b"} {
+		tbl := &executetest.Table{
+			KeyCols: []string{"_start", "_stop", "host"},
+			ColMeta: []query.ColMeta{
+				{Label: "_start", Type: query.TTime},
+				{Label: "_stop", Type: query.TTime},
+				{Label: "_time", Type: query.TTime},
+				{Label: "host", Type: query.TString},
+				{Label: "_value", Type: query.TFloat},
+			},
+		}
+		for i := 0; i < 20; i++ {
+			t := values.ConvertTime(cacheEpoch.Add(time.Duration(i) * time.Minute))
+			if t < bounds.Start || t >= bounds.Stop {
+				continue
+			}
+			tbl.Data = append(tbl.Data, []interface{}{bounds.Start, bounds.Stop, t, host, float64(i)})
+		}
+		if len(tbl.Data) > 0 {
+			tables = append(tables, tbl)
+		}
+	}
+	return tables
+}
+
+// cacheTestWindowData returns the tables of the mean of the test data over windows of every, clipped by the bounds.
+func cacheTestWindowData(every time.Duration) func(bounds plan.BoundsSpec) []*executetest.Table {
+	return func(bounds plan.BoundsSpec) []*executetest.Table {
+		var tables []*executetest.Table
+		for _, host := range []string{"a", "b"} {
+			for start := bounds.Start.Truncate(values.Duration(every)); start < bounds.Stop; start += values.Time(every) {
+				window := plan.BoundsSpec{Start: start, Stop: start + values.Time(every)}
+				if window.Start < bounds.Start {
+					window.Start = bounds.Start
+				}
+				if window.Stop > bounds.Stop {
+					window.Stop = bounds.Stop
+				}
+				var sum, n float64
+				for _, tbl := range cacheTestData(window) {
+					for _, row := range tbl.Data {
+						if row[3] == host {
+							sum += row[4].(float64)
+							n++
+						}
+					}
+				}
+				if n == 0 {
+					continue
+				}
+				tables = append(tables, &executetest.Table{
+					KeyCols: []string{"_start", "_stop", "host"},
+					ColMeta: []query.ColMeta{
+						{Label: "_start", Type: query.TTime},
+						{Label: "_stop", Type: query.TTime},
+						{Label: "host", Type: query.TString},
+						{Label: "_value", Type: query.TFloat},
+					},
+					Data: [][]interface{}{
+						{window.Start, window.Stop, host, sum / n},
+					},
+				})
+			}
+		}
+		return tables
+	}
+}
+
+// cacheTestExecutor returns an executor reading the data over the bounds of the plan,
+// and the bounds of its executions.
+func cacheTestExecutor(data func(bounds plan.BoundsSpec) []*executetest.Table) (*mock.Executor, *[]plan.BoundsSpec) {
+	var executions []plan.BoundsSpec
+	executor := mock.NewExecutor()
+	executor.ExecuteFn = func(_ context.Context, _ platform.ID, p *plan.PlanSpec, _ *execute.Allocator) (map[string]query.Result, error) {
+		var bounds plan.BoundsSpec
+		for _, pr := range p.Procedures {
+			if pr.Bounds != nil {
+				bounds = *pr.Bounds
+			}
+		}
+		executions = append(executions, bounds)
+		return map[string]query.Result{
+			"_result": executetest.NewResult(data(bounds)),
+		}, nil
+	}
+	return executor, &executions
+}
+
+func cacheTestQuery(t *testing.T, ctrl *Controller, script string, now time.Time) []*executetest.Table {
+	t.Helper()
+	req := &query.Request{
+		OrganizationID: platform.ID("a"),
+		Compiler: &mock.Compiler{
+			CompileFn: func(ctx context.Context) (*query.Spec, error) {
+				return query.Compile(ctx, script, now)
+			},
+		},
+	}
+	q, err := ctrl.Query(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer q.Done()
+	results, ok := <-q.Ready()
+	if !ok {
+		t.Fatalf("unexpected error: %s", q.Err())
+	}
+	var tables []*executetest.Table
+	if err := results["_result"].Tables().Do(func(tbl query.Table) error {
+		got, err := executetest.ConvertTable(tbl)
+		if err != nil {
+			return err
+		}
+		tables = append(tables, got)
+		return nil
+	}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	executetest.NormalizeTables(tables)
+	return tables
+}
+
+func counterValue(t *testing.T, c *prometheus.CounterVec) int {
+	t.Helper()
+	counter, err := c.GetMetricWithLabelValues(platform.ID("a").String())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	metric := &dto.Metric{}
+	if err := counter.Write(metric); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return int(metric.Counter.GetValue())
+}
+
+func bounds(start, stop time.Duration) plan.BoundsSpec {
+	return plan.BoundsSpec{
+		Start: values.ConvertTime(cacheEpoch.Add(start)),
+		Stop:  values.ConvertTime(cacheEpoch.Add(stop)),
+	}
+}
+
+func TestController_Cache(t *testing.T) {
+	const script = `from(bucket: "telegraf") |> range(start: -5m) |> filter(fn: (r) => r._value > 0.0)`
+	executor, executions := cacheTestExecutor(cacheTestData)
+	ctrl := New(Config{Cache: NewCache(1 << 20)})
+	ctrl.executor = executor
+
+	steps := []struct {
+		name string
+		now  time.Duration
+		// invalidate are the times of the points written before the query.
+		invalidate []time.Duration
+		// executed are the bounds the query is executed over, if it is executed.
+		executed *plan.BoundsSpec
+		hits     int
+		partial  int
+		misses   int
+	}{
+		{
+			name:     "miss",
+			now:      10 * time.Minute,
+			executed: &plan.BoundsSpec{},
+			misses:   1,
+		},
+		{
+			name:   "hit",
+			now:    10 * time.Minute,
+			hits:   1,
+			misses: 1,
+		},
+		{
+			name:     "partial hit",
+			now:      12 * time.Minute,
+			executed: func() *plan.BoundsSpec { b := bounds(10*time.Minute, 12*time.Minute); return &b }(),
+			hits:     1,
+			partial:  1,
+			misses:   1,
+		},
+		{
+			name:       "write after the bounds",
+			now:        12 * time.Minute,
+			invalidate: []time.Duration{12 * time.Minute, 13 * time.Minute},
+			hits:       2,
+			partial:    1,
+			misses:     1,
+		},
+		{
+			name:       "write within the bounds",
+			now:        12 * time.Minute,
+			invalidate: []time.Duration{11 * time.Minute, 11 * time.Minute},
+			executed:   func() *plan.BoundsSpec { b := bounds(7*time.Minute, 12*time.Minute); return &b }(),
+			hits:       2,
+			partial:    1,
+			misses:     2,
+		},
+	}
+	for _, step := range steps {
+		if step.invalidate != nil {
+			ctrl.cache.Invalidate(
+				platform.ID("a"),
+				&platform.Bucket{Name: "telegraf"},
+				cacheEpoch.Add(step.invalidate[0]),
+				cacheEpoch.Add(step.invalidate[1]),
+			)
+		}
+		n := len(*executions)
+		got := cacheTestQuery(t, ctrl, script, cacheEpoch.Add(step.now))
+
+		// The results are always those of the query executed over its whole bounds.
+		want := cacheTestData(bounds(step.now-5*time.Minute, step.now))
+		executetest.NormalizeTables(want)
+		if !cmp.Equal(want, got) {
+			t.Errorf("%s: unexpected results -want/+got\n%s", step.name, cmp.Diff(want, got))
+		}
+
+		switch {
+		case step.executed == nil && len(*executions) != n:
+			t.Errorf("%s: unexpected execution over %v", step.name, (*executions)[n])
+		case step.executed != nil && len(*executions) != n+1:
+			t.Errorf("%s: expected the query to be executed", step.name)
+		case step.executed != nil && *step.executed != (plan.BoundsSpec{}) && (*executions)[n] != *step.executed:
+			t.Errorf("%s: unexpected execution bounds: got %v want %v", step.name, (*executions)[n], *step.executed)
+		}
+		if got := counterValue(t, ctrl.metrics.cacheHits); got != step.hits {
+			t.Errorf("%s: unexpected hits: got %d want %d", step.name, got, step.hits)
+		}
+		if got := counterValue(t, ctrl.metrics.cachePartialHits); got != step.partial {
+			t.Errorf("%s: unexpected partial hits: got %d want %d", step.name, got, step.partial)
+		}
+		if got := counterValue(t, ctrl.metrics.cacheMisses); got != step.misses {
+			t.Errorf("%s: unexpected misses: got %d want %d", step.name, got, step.misses)
+		}
+	}
+}
+
+func TestController_Cache_NotSliceable(t *testing.T) {
+	// The mean of the bounds cannot be computed from the mean of their slices.
+	const script = `from(bucket: "telegraf") |> range(start: -5m) |> mean()`
+	executor, executions := cacheTestExecutor(cacheTestData)
+	ctrl := New(Config{Cache: NewCache(1 << 20)})
+	ctrl.executor = executor
+
+	for _, now := range []time.Duration{10 * time.Minute, 10 * time.Minute, 12 * time.Minute} {
+		cacheTestQuery(t, ctrl, script, cacheEpoch.Add(now))
+	}
+	want := []plan.BoundsSpec{
+		bounds(5*time.Minute, 10*time.Minute),
+		bounds(7*time.Minute, 12*time.Minute),
+	}
+	if !cmp.Equal(want, *executions) {
+		t.Errorf("unexpected executions -want/+got\n%s", cmp.Diff(want, *executions))
+	}
+	if got := counterValue(t, ctrl.metrics.cachePartialHits); got != 0 {
+		t.Errorf("unexpected partial hits: %d", got)
+	}
+}
+
+func TestController_Cache_Windows(t *testing.T) {
+	const script = `from(bucket: "telegraf") |> range(start: -6m) |> window(every: 2m) |> mean()`
+	data := cacheTestWindowData(2 * time.Minute)
+	executor, executions := cacheTestExecutor(data)
+	ctrl := New(Config{Cache: NewCache(1 << 20)})
+	ctrl.executor = executor
+
+	for _, now := range []time.Duration{10 * time.Minute, 13 * time.Minute, 14 * time.Minute, 14 * time.Minute} {
+		got := cacheTestQuery(t, ctrl, script, cacheEpoch.Add(now))
+		want := data(bounds(now-6*time.Minute, now))
+		executetest.NormalizeTables(want)
+		// The cached windows are read before the windows of the slice.
+		sort.Sort(executetest.SortedTables(want))
+		sort.Sort(executetest.SortedTables(got))
+		if !cmp.Equal(want, got) {
+			t.Errorf("%v: unexpected results -want/+got\n%s", now, cmp.Diff(want, got))
+		}
+	}
+	want := []plan.BoundsSpec{
+		bounds(4*time.Minute, 10*time.Minute),
+		// The query starts within the first window, which is clipped by the cached bounds.
+		bounds(7*time.Minute, 13*time.Minute),
+		// The last cached window, which is clipped by the cached bounds, is executed again.
+		bounds(12*time.Minute, 14*time.Minute),
+	}
+	if !cmp.Equal(want, *executions) {
+		t.Errorf("unexpected executions -want/+got\n%s", cmp.Diff(want, *executions))
+	}
+	if got := counterValue(t, ctrl.metrics.cachePartialHits); got != 1 {
+		t.Errorf("unexpected partial hits: %d", got)
+	}
+}
+
+func TestController_Cache_Eviction(t *testing.T) {
+	scripts := []string{
+		`from(bucket: "telegraf") |> range(start: -5m) |> mean()`,
+		`from(bucket: "telegraf") |> range(start: -5m) |> max()`,
+	}
+	executor, executions := cacheTestExecutor(cacheTestData)
+	cache := NewCache(1 << 20)
+	ctrl := New(Config{Cache: cache})
+	ctrl.executor = executor
+
+	now := cacheEpoch.Add(10 * time.Minute)
+	cacheTestQuery(t, ctrl, scripts[0], now)
+	size := cache.Size()
+	if size == 0 {
+		t.Fatal("expected the results to be cached")
+	}
+
+	// The cache only holds the results of a single query.
+	cache.maxBytes = size
+	cacheTestQuery(t, ctrl, scripts[1], now)
+	if got := cache.Size(); got > size {
+		t.Errorf("unexpected cache size: got %d want at most %d", got, size)
+	}
+	cacheTestQuery(t, ctrl, scripts[1], now)
+	cacheTestQuery(t, ctrl, scripts[0], now)
+	if got, want := len(*executions), 3; got != want {
+		t.Errorf("unexpected number of executions: got %d want %d", got, want)
+	}
+}
@@ -21,14 +21,18 @@
 package control
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"log"
 	"math"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/EMCECS/influx"
+	kerrors "github.com/EMCECS/influx/kit/errors"
 	"github.com/EMCECS/influx/query"
 	"github.com/EMCECS/influx/query/execute"
 	"github.com/EMCECS/influx/query/plan"
@@ -60,6 +64,22 @@ type Controller struct {
 	maxConcurrency       int
 	availableConcurrency int
 	availableMemory      int64
+
+	liveInterval   time.Duration
+	writes         *execute.WriteNotifier
+	maxLiveQueries int64
+	liveQueries    int64
+
+	spillDir string
+
+	cache *Cache
+
+	orgs  platform.OrganizationService
+	queue *fairQueue
+
+	executeTimeout    time.Duration
+	maxExecuteTimeout time.Duration
+	queueTimeout      time.Duration
 }
 
 type Config struct {
@@ -69,6 +89,37 @@ type Config struct {
 	PlannerOptions       []plan.Option
 	Logger               *zap.Logger
 	Verbose              bool
+
+	// LiveInterval is the interval at which live queries read new data when no write is notified,
+	// execute.DefaultLiveInterval if zero.
+	LiveInterval time.Duration
+	// WriteNotifier notifies live queries that data has been written, it may be nil.
+	WriteNotifier *execute.WriteNotifier
+	// MaxLiveQueries is the maximum number of live queries that run at once, live queries over it are rejected.
+	// Live queries are not limited if it is zero.
+	MaxLiveQueries int
+
+	// SpillDir is the directory of the temporary files where queries spill buffered tables
+	// as they near their memory quota. Queries do not spill when it is empty.
+	SpillDir string
+
+	// Cache caches the results of queries, it may be nil.
+	Cache *Cache
+
+	// OrganizationService looks up the query quotas of organizations.
+	// Queries are not limited per organization if it is nil.
+	OrganizationService platform.OrganizationService
+
+	// ExecuteTimeout is the maximum duration queries execute for, unless their request sets its own timeout.
+	// It does not apply to live queries. Queries execute until they finish if it is zero.
+	ExecuteTimeout time.Duration
+	// MaxExecuteTimeout is the longest timeout a request may set, longer timeouts are shortened to it.
+	// It is ExecuteTimeout if zero, so that requests may only shorten the default timeout.
+	// Request timeouts are not limited if both are zero.
+	MaxExecuteTimeout time.Duration
+	// QueueTimeout is the maximum duration queries wait in the queue before they execute.
+	// Queries wait until resources are available if it is zero.
+	QueueTimeout time.Duration
 }
 
 type QueryID uint64
@@ -78,6 +129,10 @@ func New(c Config) *Controller {
 	if logger == nil {
 		logger = zap.NewNop()
 	}
+	maxExecuteTimeout := c.MaxExecuteTimeout
+	if maxExecuteTimeout == 0 {
+		maxExecuteTimeout = c.ExecuteTimeout
+	}
 	ctrl := &Controller{
 		newQueries:           make(chan *Query),
 		queries:              make(map[QueryID]*Query),
@@ -92,6 +147,16 @@ func New(c Config) *Controller {
 		logger:               logger,
 		metrics:              newControllerMetrics(),
 		verbose:              c.Verbose,
+		liveInterval:         c.LiveInterval,
+		writes:               c.WriteNotifier,
+		maxLiveQueries:       int64(c.MaxLiveQueries),
+		spillDir:             c.SpillDir,
+		cache:                c.Cache,
+		orgs:                 c.OrganizationService,
+		queue:                newFairQueue(),
+		executeTimeout:       c.ExecuteTimeout,
+		maxExecuteTimeout:    maxExecuteTimeout,
+		queueTimeout:         c.QueueTimeout,
 	}
 	go ctrl.run()
 	return ctrl
@@ -100,18 +165,77 @@ func New(c Config) *Controller {
 // Query submits a query for execution returning immediately.
 // Done must be called on any returned Query objects.
 func (c *Controller) Query(ctx context.Context, req *query.Request) (query.Query, error) {
+	var quotas platform.QueryQuotas
+	if c.orgs != nil {
+		o, err := c.orgs.FindOrganizationByID(ctx, req.OrganizationID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to find organization %s", req.OrganizationID)
+		}
+		if o.QueryQuotas != nil {
+			quotas = *o.QueryQuotas
+		}
+	}
 	q := c.createQuery(ctx, req.OrganizationID)
+	q.quotas = quotas
+	q.explain = req.Explain
+	q.profile = req.Profile
+	q.live = req.Live
+	if q.live && !c.startLive() {
+		q.parentSpan.Finish()
+		return nil, kerrors.Forbiddenf("live query rejected, the server runs at most %d live queries", c.maxLiveQueries)
+	}
+	q.timeout = req.Timeout
+	if q.timeout <= 0 && !q.live {
+		q.timeout = c.executeTimeout
+	}
+	if c.maxExecuteTimeout > 0 && q.timeout > c.maxExecuteTimeout {
+		q.timeout = c.maxExecuteTimeout
+	}
+	q.text = fluxText(req.Compiler)
 	if err := c.compileQuery(q, req.Compiler); err != nil {
 		q.parentSpan.Finish()
+		c.endLive(q)
 		return nil, err
 	}
 	if err := c.enqueueQuery(q); err != nil {
 		q.parentSpan.Finish()
+		c.endLive(q)
 		return nil, err
 	}
 	return q, nil
 }
 
+// startLive counts a live query, it reports false if the maximum number of live queries already run.
+// Live queries are exempt from the execute timeout and hold their resources until they are canceled,
+// so they are limited separately from the queue.
+func (c *Controller) startLive() bool {
+	n := atomic.AddInt64(&c.liveQueries, 1)
+	if c.maxLiveQueries > 0 && n > c.maxLiveQueries {
+		atomic.AddInt64(&c.liveQueries, -1)
+		return false
+	}
+	return true
+}
+
+// endLive stops counting the query if it is live.
+func (c *Controller) endLive(q *Query) {
+	if q.live {
+		atomic.AddInt64(&c.liveQueries, -1)
+	}
+}
+
+// fluxText returns the Flux text of the query compiled by the compiler, if it compiles Flux.
+func fluxText(compiler query.Compiler) string {
+	switch c := compiler.(type) {
+	case query.FluxCompiler:
+		return c.Query
+	case *query.FluxCompiler:
+		return c.Query
+	default:
+		return ""
+	}
+}
+
 func (c *Controller) createQuery(ctx context.Context, orgID platform.ID) *Query {
 	id := c.nextID()
 	labelValues := []string{
@@ -143,7 +267,8 @@ func (c *Controller) compileQuery(q *Query, compiler query.Compiler) error {
 	if !q.tryCompile() {
 		return errors.New("failed to transition query to compiling state")
 	}
-	spec, err := compiler.Compile(q.compilingCtx)
+	ctx := query.ContextWithSpecExecutor(q.compilingCtx, specExecutor{c: c, orgID: q.orgID, timeout: q.timeout})
+	spec, err := compiler.Compile(ctx)
 	if err != nil {
 		return errors.Wrap(err, "failed to compile query")
 	}
@@ -158,6 +283,39 @@ func (c *Controller) compileQuery(q *Query, compiler query.Compiler) error {
 	return nil
 }
 
+// specExecutor executes the specs of the tables read while a query is compiled, such as by tableFind.
+// The specs are executed as queries of the controller, so they are queued and limited by the same
+// resources, quotas and timeout as the query they are read by.
+type specExecutor struct {
+	c       *Controller
+	orgID   platform.ID
+	timeout time.Duration
+}
+
+func (e specExecutor) ExecuteSpec(ctx context.Context, spec *query.Spec) (map[string]query.Result, error) {
+	q, err := e.c.Query(ctx, &query.Request{
+		OrganizationID: e.orgID,
+		Compiler:       query.SpecCompiler{Spec: spec},
+		Timeout:        e.timeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// The results are read until the context is done, the query holds its resources until then.
+	go func() {
+		<-ctx.Done()
+		q.Done()
+	}()
+	results, ok := <-q.Ready()
+	if !ok {
+		if err := q.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("query was canceled")
+	}
+	return results, nil
+}
+
 func (c *Controller) enqueueQuery(q *Query) error {
 	if c.verbose {
 		log.Println("query", query.Formatted(&q.spec, query.FmtJSON))
@@ -169,16 +327,41 @@ func (c *Controller) enqueueQuery(q *Query) error {
 		q.queueSpan.Finish()
 		return errors.Wrap(err, "invalid query")
 	}
+	ctx := q.parentCtx
+	var cancel context.CancelFunc
+	if c.queueTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.queueTimeout)
+	}
 	// Add query to the queue
 	select {
 	case c.newQueries <- q:
+		if cancel != nil {
+			go q.timeoutOn(ctx, cancel, queueTimeoutError(c.queueTimeout), Queueing, Planning, Requeueing)
+		}
 		return nil
-	case <-q.parentCtx.Done():
+	case <-ctx.Done():
 		q.queueSpan.Finish()
-		return q.parentCtx.Err()
+		if cancel != nil {
+			cancel()
+		}
+		if err := q.parentCtx.Err(); err != nil {
+			return err
+		}
+		c.metrics.timedOut.WithLabelValues(q.labelValues...).Inc()
+		return queueTimeoutError(c.queueTimeout)
 	}
 }
 
+// queueTimeoutError is the error of a query that waited in the queue for longer than the timeout.
+func queueTimeoutError(d time.Duration) error {
+	return kerrors.Timeoutf("query timed out after waiting %s in the queue", d)
+}
+
+// executeTimeoutError is the error of a query that executed for longer than the timeout.
+func executeTimeoutError(d time.Duration) error {
+	return kerrors.Timeoutf("query timed out after executing for %s", d)
+}
+
 func (c *Controller) nextID() QueryID {
 	c.queriesMu.Lock()
 	defer c.queriesMu.Unlock()
@@ -201,19 +384,63 @@ func (c *Controller) Queries() []*Query {
 	return queries
 }
 
+// FindRunningQueries returns the active queries, only those of the organization if orgID is not nil.
+func (c *Controller) FindRunningQueries(ctx context.Context, orgID *platform.ID) ([]*query.RunningQuery, error) {
+	queries := c.Queries()
+	sort.Slice(queries, func(i, j int) bool {
+		return queries[i].id < queries[j].id
+	})
+	running := make([]*query.RunningQuery, 0, len(queries))
+	for _, q := range queries {
+		if orgID != nil && !bytes.Equal(q.orgID, *orgID) {
+			continue
+		}
+		running = append(running, q.running())
+	}
+	return running, nil
+}
+
+// FindRunningQueryByID returns a single active query by ID.
+func (c *Controller) FindRunningQueryByID(ctx context.Context, id uint64) (*query.RunningQuery, error) {
+	c.queriesMu.RLock()
+	q := c.queries[QueryID(id)]
+	c.queriesMu.RUnlock()
+	if q == nil {
+		return nil, kerrors.Errorf(kerrors.NotFound, "query %d not found", id)
+	}
+	return q.running(), nil
+}
+
+// CancelRunningQuery cancels an active query by ID.
+func (c *Controller) CancelRunningQuery(ctx context.Context, id uint64) error {
+	c.queriesMu.RLock()
+	_, ok := c.queries[QueryID(id)]
+	c.queriesMu.RUnlock()
+	if !ok {
+		return kerrors.Errorf(kerrors.NotFound, "query %d not found", id)
+	}
+	select {
+	case c.cancelRequest <- QueryID(id):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (c *Controller) run() {
-	pq := newPriorityQueue()
 	for {
 		select {
 		// Wait for resources to free
 		case q := <-c.queryDone:
 			c.free(q)
+			c.endLive(q)
 			c.queriesMu.Lock()
 			delete(c.queries, q.id)
 			c.queriesMu.Unlock()
 		// Wait for new queries
 		case q := <-c.newQueries:
-			pq.Push(q)
+			q.queued = time.Now()
+			c.queue.Push(q)
 			c.queriesMu.Lock()
 			c.queries[q.id] = q
 			c.queriesMu.Unlock()
@@ -222,19 +449,43 @@ func (c *Controller) run() {
 			c.queriesMu.RLock()
 			q := c.queries[id]
 			c.queriesMu.RUnlock()
-			q.Cancel()
+			if q != nil {
+				q.Cancel()
+			}
 		}
 
-		// Peek at head of priority queue
-		q := pq.Peek()
-		if q != nil {
+		c.dispatch()
+		c.queue.depths(func(id platform.ID, n int) {
+			c.metrics.queueDepth.WithLabelValues(id.String()).Set(float64(n))
+		})
+	}
+}
+
+// dispatch processes the queries at the head of the queues of the organizations,
+// serving the organizations in the order of the fair queue until no query can be popped.
+// A query that does not fit within the quotas of its organization does not hold back the other organizations,
+// but one that does not fit within the available resources does, so that large queries are not starved by smaller ones.
+func (c *Controller) dispatch() {
+	for {
+		popped := false
+		for _, o := range c.queue.Orgs() {
+			q := o.Peek()
 			pop, err := c.processQuery(q)
 			if pop {
-				pq.Pop()
+				o.Pop()
+				popped = true
 			}
 			if err != nil {
 				go q.setErr(err)
 			}
+			if pop || c.queue.fits(q) {
+				// The order of the organizations changes once a query is popped,
+				// and none of the following organizations is served before a query that waits for resources.
+				break
+			}
+		}
+		if !popped {
+			return
 		}
 	}
 }
@@ -264,23 +515,48 @@ func (c *Controller) processQuery(q *Query) (pop bool, err error) {
 		if err != nil {
 			return true, errors.Wrap(err, "failed to create logical plan")
 		}
+		lp.Live = q.live
 		if c.verbose {
 			log.Println("logical plan", plan.Formatted(lp))
 		}
+		var explanation *plan.Explanation
+		if q.explain {
+			explanation = plan.NewExplanation(&q.spec, lp)
+		}
 
 		p, err := c.pplanner.Plan(lp, nil)
 		if err != nil {
 			return true, errors.Wrap(err, "failed to create physical plan")
 		}
 		q.plan = p
+		if c.maxConcurrency > 0 {
+			p.LimitConcurrency(c.maxConcurrency)
+		}
 		q.concurrency = p.Resources.ConcurrencyQuota
 		if q.concurrency > c.maxConcurrency {
 			q.concurrency = c.maxConcurrency
 		}
 		q.memory = p.Resources.MemoryBytesQuota
+		// The quotas lower the parallelism of the plan, so they apply before it is explained.
+		if err := limitToQuotas(q); err != nil {
+			return true, err
+		}
+		if q.explain {
+			explanation.ExplainPhysical(p)
+			return true, c.explainQuery(q, explanation)
+		}
 		if c.verbose {
 			log.Println("physical plan", plan.Formatted(q.plan))
 		}
+		if c.cache != nil && !q.live && !q.profile {
+			q.cached = c.cache.lookup(q.orgID, &q.spec, p)
+			c.countLookup(q)
+			if q.cached != nil && q.cached.full() {
+				// The cached results are read without executing the query.
+				q.concurrency = 0
+				q.memory = 0
+			}
+		}
 	}
 
 	// Check if we have enough resources
@@ -295,11 +571,56 @@ func (c *Controller) processQuery(q *Query) (pop bool, err error) {
 		if !q.tryExec() {
 			return true, errors.New("failed to transition query into executing state")
 		}
-		q.alloc = new(execute.Allocator)
-		r, err := c.executor.Execute(q.executeCtx, q.orgID, q.plan, q.alloc)
+		if q.cached != nil {
+			// The cached results are decoded outside of the executor, which otherwise sets the limit.
+			q.alloc.Limit = q.plan.Resources.MemoryBytesQuota
+			if q.cached.full() {
+				q.setResults(q.cached.results(q.alloc))
+				return pop, nil
+			}
+			if q.cached.delta != nil {
+				// Only the time slice following the cached results is executed.
+				q.cached.slice(q.plan)
+			}
+		}
+		ctx := q.executeCtx
+		if q.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, q.timeout)
+			go q.timeoutOn(ctx, cancel, executeTimeoutError(q.timeout), Executing)
+		}
+		if q.profile {
+			// Identify the procedures by the operations they were created from.
+			names := make(map[plan.ProcedureID]string, len(q.spec.Operations))
+			for _, o := range q.spec.Operations {
+				names[plan.ProcedureIDFromOperationID(o.ID)] = string(o.ID)
+			}
+			q.profiler = execute.NewProfiler(names)
+			ctx = execute.ContextWithProfiler(ctx, q.profiler)
+		}
+		if q.live {
+			ctx = execute.ContextWithLive(ctx, &execute.Live{
+				Interval: c.liveInterval,
+				Writes:   c.writes,
+			})
+		}
+		if c.spillDir != "" {
+			spilled := c.metrics.spilledBytes.WithLabelValues(q.labelValues...)
+			q.spiller = &execute.Spiller{
+				Dir: c.spillDir,
+				OnSpill: func(n int64) {
+					spilled.Add(float64(n))
+				},
+			}
+			ctx = execute.ContextWithSpiller(ctx, q.spiller)
+		}
+		r, err := c.executor.Execute(ctx, q.orgID, q.plan, q.alloc)
 		if err != nil {
 			return true, errors.Wrap(err, "failed to execute query")
 		}
+		if q.cached != nil {
+			r = q.cached.wrap(r, q.alloc)
+		}
 		q.setResults(r)
 	} else {
 		// update state to queueing
@@ -310,8 +631,62 @@ func (c *Controller) processQuery(q *Query) (pop bool, err error) {
 	return pop, nil
 }
 
+// explainQuery delivers the explanation as the result of the query instead of executing it.
+// The query consumes no resources.
+func (c *Controller) explainQuery(q *Query, e *plan.Explanation) error {
+	if !q.tryExec() {
+		return errors.New("failed to transition query into executing state")
+	}
+	q.alloc.Limit = math.MaxInt64
+	r, err := newExplainResult(e, q.alloc)
+	if err != nil {
+		return errors.Wrap(err, "failed to explain query")
+	}
+	q.setResults(map[string]query.Result{ExplainResultName: r})
+	return nil
+}
+
+// countLookup counts the lookup of the cached results of the query.
+func (c *Controller) countLookup(q *Query) {
+	switch {
+	case q.cached == nil:
+		// The results of the query are not cached.
+	case q.cached.full():
+		c.metrics.cacheHits.WithLabelValues(q.labelValues...).Inc()
+	case q.cached.delta != nil:
+		c.metrics.cachePartialHits.WithLabelValues(q.labelValues...).Inc()
+	default:
+		c.metrics.cacheMisses.WithLabelValues(q.labelValues...).Inc()
+	}
+}
+
+// limitToQuotas limits the resources of the query to the quotas of its organization.
+// The query is rejected if it explicitly requests more resources than its organization allows.
+func limitToQuotas(q *Query) error {
+	quotas := q.quotas
+	if n := quotas.ConcurrencyQuota; n > 0 {
+		if req := q.spec.Resources.ConcurrencyQuota; req > n {
+			return kerrors.Forbiddenf("query requests a concurrency of %d, exceeding the quota of %d of organization %s", req, n, q.orgID)
+		}
+		if q.concurrency > n {
+			q.concurrency = n
+		}
+		q.plan.LimitConcurrency(n)
+	}
+	if n := quotas.MemoryBytesQuota; n > 0 {
+		if req := q.spec.Resources.MemoryBytesQuota; req > n {
+			return kerrors.Forbiddenf("query requests %d bytes of memory, exceeding the quota of %d bytes of organization %s", req, n, q.orgID)
+		}
+		if q.memory > n {
+			q.memory = n
+			q.plan.Resources.MemoryBytesQuota = n
+		}
+	}
+	return nil
+}
+
 func (c *Controller) check(q *Query) bool {
-	return c.availableConcurrency >= q.concurrency && (q.memory == math.MaxInt64 || c.availableMemory >= q.memory)
+	return c.availableConcurrency >= q.concurrency && (q.memory == math.MaxInt64 || c.availableMemory >= q.memory) && c.queue.fits(q)
 }
 func (c *Controller) consume(q *Query) {
 	c.availableConcurrency -= q.concurrency
@@ -319,14 +694,33 @@ func (c *Controller) consume(q *Query) {
 	if q.memory != math.MaxInt64 {
 		c.availableMemory -= q.memory
 	}
+
+	c.queue.consume(q)
+	q.consumed = true
+	c.metrics.queueWaitDur.WithLabelValues(q.labelValues...).Observe(time.Since(q.queued).Seconds())
 }
 
 func (c *Controller) free(q *Query) {
-	c.availableConcurrency += q.concurrency
+	if q.spiller != nil {
+		if err := q.spiller.Close(); err != nil {
+			c.logger.Info("Failed to remove spilled tables", zap.Error(err))
+		}
+	}
 
+	if q.cached != nil {
+		q.cached.close()
+	}
+
+	if !q.consumed {
+		// The query was done before it executed.
+		return
+	}
+
+	c.availableConcurrency += q.concurrency
 	if q.memory != math.MaxInt64 {
 		c.availableMemory += q.memory
 	}
+	c.queue.free(q)
 }
 
 // PrometheusCollectors satisifies the prom.PrometheusCollector interface.
@@ -346,6 +740,20 @@ type Query struct {
 
 	spec query.Spec
 	now  time.Time
+	// text is the Flux text of the query, it is empty if the query was not compiled from Flux.
+	text string
+
+	// explain reports whether the query is planned but not executed.
+	explain bool
+	// profile reports whether the execution of the query is profiled.
+	profile  bool
+	profiler *execute.Profiler
+	// live reports whether the query keeps running as new data is written.
+	live bool
+	// spiller spills the tables buffered by the query to disk, it is nil if the query does not spill.
+	spiller *execute.Spiller
+	// cached is the lookup of the cached results of the query, it is nil if the results are not cached.
+	cached *cacheLookup
 
 	err error
 
@@ -374,6 +782,14 @@ type Query struct {
 
 	concurrency int
 	memory      int64
+	// quotas are the query quotas of the organization of the query.
+	quotas platform.QueryQuotas
+	// queued is the time the query was queued at.
+	queued time.Time
+	// consumed reports whether the resources of the query were consumed, so they are freed when it is done.
+	consumed bool
+	// timeout is the maximum duration the query executes for, it executes until it finishes if zero.
+	timeout time.Duration
 
 	alloc *execute.Allocator
 }
@@ -396,6 +812,23 @@ func (q *Query) Concurrency() int {
 	return q.concurrency
 }
 
+// running describes the query as it runs.
+func (q *Query) running() *query.RunningQuery {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	r := &query.RunningQuery{
+		ID:             uint64(q.id),
+		OrganizationID: q.orgID,
+		State:          q.state.String(),
+		Elapsed:        time.Since(q.now),
+		Query:          q.text,
+	}
+	if q.alloc != nil {
+		r.Allocated = q.alloc.Allocated()
+	}
+	return r
+}
+
 // Cancel will stop the query execution.
 func (q *Query) Cancel() {
 	q.mu.Lock()
@@ -414,7 +847,7 @@ func (q *Query) Cancel() {
 	// that has called defer q.Done()
 	q.finish()
 
-	if q.state != Errored {
+	if q.state != Errored && q.state != TimedOut {
 		q.state = Canceled
 	}
 }
@@ -445,6 +878,9 @@ func (q *Query) finish() {
 	case Canceled:
 		// The query has already been finished in the call to Cancel.
 		return
+	case TimedOut:
+		// The query has already been finished in the call to timeoutOn.
+		return
 	case Finished:
 		// The query has already finished
 		return
@@ -464,6 +900,9 @@ func (q *Query) Done() {
 
 	q.finish()
 
+	// Release the contexts of the query, along with their deadlines.
+	q.cancel()
+
 	q.state = Finished
 }
 
@@ -494,6 +933,12 @@ func (q *Query) Statistics() query.Statistics {
 	if q.alloc != nil {
 		stats.MaxAllocated = q.alloc.Max()
 	}
+	if q.profiler != nil {
+		stats.Operators = q.profiler.Profiles()
+	}
+	if q.spiller != nil {
+		stats.SpilledBytes = q.spiller.BytesSpilled()
+	}
 	return stats
 }
 
@@ -507,7 +952,7 @@ func (q *Query) State() State {
 
 func (q *Query) isOK() bool {
 	q.mu.Lock()
-	ok := q.state != Canceled && q.state != Errored && q.state != Finished
+	ok := q.state != Canceled && q.state != Errored && q.state != Finished && q.state != TimedOut
 	q.mu.Unlock()
 	return ok
 }
@@ -522,6 +967,11 @@ func (q *Query) Err() error {
 func (q *Query) setErr(err error) {
 	q.mu.Lock()
 	defer q.mu.Unlock()
+	if q.state == TimedOut {
+		// The query already ended with the timeout error,
+		// the error is a consequence of the timeout.
+		return
+	}
 	q.err = err
 
 	// Finish the query immediately.
@@ -532,6 +982,29 @@ func (q *Query) setErr(err error) {
 	q.state = Errored
 }
 
+// timeoutOn waits for the context to be done, and times out the query with the error
+// if the context reached its deadline while the query is in one of the states.
+func (q *Query) timeoutOn(ctx context.Context, cancel context.CancelFunc, err error, states ...State) {
+	defer cancel()
+	<-ctx.Done()
+	if ctx.Err() != context.DeadlineExceeded {
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, s := range states {
+		if q.state == s {
+			q.err = err
+			q.cancel()
+			q.finish()
+			q.state = TimedOut
+			q.c.metrics.timedOut.WithLabelValues(q.labelValues...).Inc()
+			return
+		}
+	}
+}
+
 func (q *Query) setResults(r map[string]query.Result) {
 	q.mu.Lock()
 	if q.state == Executing {
@@ -581,9 +1054,13 @@ func (q *Query) tryQueue() bool {
 }
 
 // tryRequeue attempts to transition the query into the Requeueing state.
+// A query that is already requeueing stays in that state while it waits for resources.
 func (q *Query) tryRequeue() bool {
 	q.mu.Lock()
 	defer q.mu.Unlock()
+	if q.state == Requeueing {
+		return true
+	}
 	if q.state == Planning {
 		q.planSpan.Finish()
 
@@ -639,6 +1116,9 @@ func (q *Query) tryExec() bool {
 			q.c.metrics.executing.WithLabelValues(q.labelValues...),
 		)
 
+		// The allocator is read by the running queries while the query executes.
+		q.alloc = new(execute.Allocator)
+
 		q.state = Executing
 		return true
 	}
@@ -658,6 +1138,7 @@ const (
 	Errored
 	Finished
 	Canceled
+	TimedOut
 )
 
 func (s State) String() string {
@@ -680,6 +1161,8 @@ func (s State) String() string {
 		return "finished"
 	case Canceled:
 		return "canceled"
+	case TimedOut:
+		return "timed out"
 	default:
 		return "unknown"
 	}
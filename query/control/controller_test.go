@@ -2,13 +2,19 @@ package control
 
 import (
 	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/EMCECS/influx"
+	kerrors "github.com/EMCECS/influx/kit/errors"
 	"github.com/EMCECS/influx/query"
 	_ "github.com/EMCECS/influx/query/builtin"
 	"github.com/EMCECS/influx/query/execute"
+	"github.com/EMCECS/influx/query/execute/executetest"
 	"github.com/EMCECS/influx/query/mock"
 	"github.com/EMCECS/influx/query/plan"
 	"github.com/pkg/errors"
@@ -59,6 +65,61 @@ func TestController_CompileQuery_Failure(t *testing.T) {
 	}
 }
 
+func TestController_CompileQuery_TableFind(t *testing.T) {
+	var (
+		orgIDs   []platform.ID
+		deadline []bool
+	)
+	executor := mock.NewExecutor()
+	executor.ExecuteFn = func(ctx context.Context, orgID platform.ID, _ *plan.PlanSpec, _ *execute.Allocator) (map[string]query.Result, error) {
+		orgIDs = append(orgIDs, orgID)
+		_, ok := ctx.Deadline()
+		deadline = append(deadline, ok)
+		return map[string]query.Result{
+			"_result": executetest.NewResult([]*executetest.Table{{
+				ColMeta: []query.ColMeta{{Label: "_value", Type: query.TFloat}},
+				Data:    [][]interface{}{{2.5}},
+			}}),
+		}, nil
+	}
+	compiler := &mock.Compiler{
+		CompileFn: func(ctx context.Context) (*query.Spec, error) {
+			return query.Compile(ctx, `
+m = from(bucket: "telegraf") |> range(start: -5m) |> mean() |> tableFind(fn: (key) => true) |> getRecord(idx: 0)
+from(bucket: "telegraf") |> range(start: -5m) |> filter(fn: (r) => r._value > m._value)`, time.Now())
+		},
+	}
+
+	ctrl := New(Config{ExecuteTimeout: time.Minute})
+	ctrl.executor = executor
+	req := &query.Request{
+		OrganizationID: platform.ID("a"),
+		Compiler:       compiler,
+	}
+
+	q, err := ctrl.Query(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	<-q.Ready()
+	q.Done()
+	if err := q.Err(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The spec of tableFind is executed while compiling, before the query itself.
+	if got, want := orgIDs, []platform.ID{platform.ID("a"), platform.ID("a")}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected executions: got=%v want=%v", got, want)
+	}
+	// The spec of tableFind is executed as a query of the controller, with the timeout of the query.
+	if got, want := deadline, []bool{true, true}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected deadlines: got=%v want=%v", got, want)
+	}
+	if got, want := len(q.Spec().Operations), 3; got != want {
+		t.Fatalf("unexpected number of operations: got=%d want=%d", got, want)
+	}
+}
+
 func TestController_EnqueueQuery_Failure(t *testing.T) {
 	compiler := &mock.Compiler{
 		CompileFn: func(ctx context.Context) (*query.Spec, error) {
@@ -149,6 +210,126 @@ func TestController_ExecuteQuery_Failure(t *testing.T) {
 	}
 }
 
+func TestController_ExplainQuery(t *testing.T) {
+	executor := mock.NewExecutor()
+	executor.ExecuteFn = func(context.Context, platform.ID, *plan.PlanSpec, *execute.Allocator) (map[string]query.Result, error) {
+		return nil, errors.New("explained query was executed")
+	}
+
+	ctrl := New(Config{})
+	ctrl.executor = executor
+	req := &query.Request{
+		OrganizationID: platform.ID("a"),
+		Compiler:       mockCompiler,
+		Explain:        true,
+	}
+
+	q, err := ctrl.Query(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer q.Done()
+
+	results, ok := <-q.Ready()
+	if !ok {
+		t.Fatalf("unexpected error: %s", q.Err())
+	}
+	if len(results) != 1 {
+		t.Fatalf("unexpected number of results: got %d want 1", len(results))
+	}
+	r, ok := results[ExplainResultName]
+	if !ok {
+		t.Fatalf("missing result %q", ExplainResultName)
+	}
+	e, err := ReadExplanation(r)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The range is pushed down into the storage read.
+	if got, want := e.PushedDown, []string{"range1"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected pushed down procedures: got=%v want=%v", got, want)
+	}
+	if got, want := e.Results, map[string]string{"_result": "mean2"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected results: got=%v want=%v", got, want)
+	}
+}
+
+func TestController_ExplainQueryQuotas(t *testing.T) {
+	ctrl := New(Config{
+		ConcurrencyQuota: 4,
+		OrganizationService: organizationService{
+			quotas: map[string]*platform.QueryQuotas{"a": {ConcurrencyQuota: 1}},
+		},
+	})
+	req := &query.Request{
+		OrganizationID: platform.ID("a"),
+		Compiler:       mockCompiler,
+		Explain:        true,
+	}
+
+	q, err := ctrl.Query(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer q.Done()
+
+	results, ok := <-q.Ready()
+	if !ok {
+		t.Fatalf("unexpected error: %s", q.Err())
+	}
+	e, err := ReadExplanation(results[ExplainResultName])
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The parallelism of the plan is limited to the concurrency quota of the organization,
+	// and procedures that are not partitioned have no parallelism.
+	for _, n := range e.Physical {
+		if n.Name == "mean2" {
+			if got, want := n.Parallelism, 0; got != want {
+				t.Fatalf("unexpected parallelism: got %d want %d", got, want)
+			}
+			return
+		}
+	}
+	t.Fatal("missing mean procedure in the physical plan")
+}
+
+func TestController_ProfileQuery(t *testing.T) {
+	for _, profile := range []bool{false, true} {
+		executor := mock.NewExecutor()
+		executor.ExecuteFn = func(ctx context.Context, _ platform.ID, _ *plan.PlanSpec, _ *execute.Allocator) (map[string]query.Result, error) {
+			if got := execute.ProfilerFromContext(ctx) != nil; got != profile {
+				return nil, fmt.Errorf("unexpected profiler in context: got=%v want=%v", got, profile)
+			}
+			return map[string]query.Result{}, nil
+		}
+
+		ctrl := New(Config{})
+		ctrl.executor = executor
+		req := &query.Request{
+			OrganizationID: platform.ID("a"),
+			Compiler:       mockCompiler,
+			Profile:        profile,
+		}
+
+		q, err := ctrl.Query(context.Background(), req)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if _, ok := <-q.Ready(); !ok {
+			t.Fatalf("unexpected error: %s", q.Err())
+		}
+		q.Done()
+
+		// The profiler is not started by the mock executor, so there are no operators.
+		if ops := q.Statistics().Operators; len(ops) != 0 {
+			t.Fatalf("unexpected operators: %v", ops)
+		}
+	}
+}
+
 func TestController_CancelQuery(t *testing.T) {
 	executor := mock.NewExecutor()
 	executor.ExecuteFn = func(context.Context, platform.ID, *plan.PlanSpec, *execute.Allocator) (map[string]query.Result, error) {
@@ -240,3 +421,458 @@ func TestController_BlockedExecutor(t *testing.T) {
 		t.Fatalf("unexpected error: got=%q want=%q", got, want)
 	}
 }
+
+func TestController_RunningQueries(t *testing.T) {
+	executor := mock.NewExecutor()
+	executor.ExecuteFn = func(_ context.Context, _ platform.ID, _ *plan.PlanSpec, alloc *execute.Allocator) (map[string]query.Result, error) {
+		// The executor sets the limit of the allocator.
+		alloc.Limit = math.MaxInt64
+		alloc.Floats(0, 8)
+		return map[string]query.Result{}, nil
+	}
+
+	ctrl := New(Config{})
+	ctrl.executor = executor
+	const text = `from(bucket: "telegraf") |> range(start: -5m) |> mean()`
+	req := &query.Request{
+		OrganizationID: platform.ID("a"),
+		Compiler:       query.FluxCompiler{Query: text},
+	}
+
+	q, err := ctrl.Query(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer q.Done()
+	if _, ok := <-q.Ready(); !ok {
+		t.Fatalf("unexpected error: %s", q.Err())
+	}
+
+	running, err := ctrl.FindRunningQueries(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(running) != 1 {
+		t.Fatalf("unexpected number of running queries: got %d want 1", len(running))
+	}
+	r := running[0]
+	if r.ID != uint64(q.(*Query).ID()) ||
+		string(r.OrganizationID) != "a" ||
+		r.State != Executing.String() ||
+		r.Elapsed <= 0 ||
+		r.Allocated != 64 ||
+		r.Query != text {
+		t.Fatalf("unexpected running query: %+v", r)
+	}
+
+	other := platform.ID("b")
+	if running, err := ctrl.FindRunningQueries(context.Background(), &other); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if len(running) != 0 {
+		t.Fatalf("unexpected running queries of another organization: %v", running)
+	}
+
+	if _, err := ctrl.FindRunningQueryByID(context.Background(), r.ID+1); err == nil {
+		t.Fatal("expected error finding an unknown query")
+	}
+	if err := ctrl.CancelRunningQuery(context.Background(), r.ID+1); err == nil {
+		t.Fatal("expected error canceling an unknown query")
+	}
+
+	if err := ctrl.CancelRunningQuery(context.Background(), r.ID); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// The query is canceled by the run loop of the controller.
+	for i := 0; q.(*Query).State() != Canceled; i++ {
+		if i == 1000 {
+			t.Fatalf("unexpected state after cancel: %v", q.(*Query).State())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// organizationService finds organizations with query quotas.
+type organizationService struct {
+	platform.OrganizationService
+	quotas map[string]*platform.QueryQuotas
+}
+
+func (s organizationService) FindOrganizationByID(ctx context.Context, id platform.ID) (*platform.Organization, error) {
+	return &platform.Organization{ID: id, QueryQuotas: s.quotas[string(id)]}, nil
+}
+
+func TestController_OrganizationQuotas(t *testing.T) {
+	testCases := []struct {
+		name      string
+		quotas    platform.QueryQuotas
+		resources query.ResourceManagement
+		// want are the resources the query is executed with.
+		want query.ResourceManagement
+		// parallelism is the parallelism of the mean procedure.
+		parallelism int
+		// err is the error of the query, if it is rejected.
+		err string
+	}{
+		{
+			name:        "limited to quotas",
+			quotas:      platform.QueryQuotas{ConcurrencyQuota: 1, MemoryBytesQuota: 1024},
+			want:        query.ResourceManagement{ConcurrencyQuota: 1, MemoryBytesQuota: 1024},
+			parallelism: 1,
+		},
+		{
+			name:        "within quotas",
+			quotas:      platform.QueryQuotas{ConcurrencyQuota: 4, MemoryBytesQuota: 1024},
+			resources:   query.ResourceManagement{ConcurrencyQuota: 2, MemoryBytesQuota: 512},
+			want:        query.ResourceManagement{ConcurrencyQuota: 2, MemoryBytesQuota: 512},
+			parallelism: 2,
+		},
+		{
+			name:      "concurrency above quota",
+			quotas:    platform.QueryQuotas{ConcurrencyQuota: 1},
+			resources: query.ResourceManagement{ConcurrencyQuota: 2},
+			err:       "query requests a concurrency of 2, exceeding the quota of 1 of organization 61",
+		},
+		{
+			name:      "memory above quota",
+			quotas:    platform.QueryQuotas{MemoryBytesQuota: 1024},
+			resources: query.ResourceManagement{MemoryBytesQuota: 2048},
+			err:       "query requests 2048 bytes of memory, exceeding the quota of 1024 bytes of organization 61",
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				got         query.ResourceManagement
+				parallelism int
+			)
+			executor := mock.NewExecutor()
+			executor.ExecuteFn = func(_ context.Context, _ platform.ID, p *plan.PlanSpec, _ *execute.Allocator) (map[string]query.Result, error) {
+				got = p.Resources
+				parallelism = p.Procedures[plan.ProcedureIDFromOperationID("mean2")].Parallelism
+				return map[string]query.Result{}, nil
+			}
+
+			ctrl := New(Config{
+				ConcurrencyQuota: 4,
+				MemoryBytesQuota: 4096,
+				OrganizationService: organizationService{
+					quotas: map[string]*platform.QueryQuotas{"a": &tc.quotas},
+				},
+			})
+			ctrl.executor = executor
+			req := &query.Request{
+				OrganizationID: platform.ID("a"),
+				Compiler: &mock.Compiler{
+					CompileFn: func(ctx context.Context) (*query.Spec, error) {
+						spec, err := mockCompiler.Compile(ctx)
+						if err != nil {
+							return nil, err
+						}
+						spec.Resources = tc.resources
+						return spec, nil
+					},
+				},
+			}
+
+			q, err := ctrl.Query(context.Background(), req)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			<-q.Ready()
+			q.Done()
+
+			if tc.err != "" {
+				if err, ok := q.Err().(kerrors.Error); !ok {
+					t.Fatalf("expected error %q, got %v", tc.err, q.Err())
+				} else if err.Err != tc.err || err.Reference != kerrors.Forbidden {
+					t.Fatalf("unexpected error: got %q (%d) want %q (%d)", err.Err, err.Reference, tc.err, kerrors.Forbidden)
+				}
+				return
+			}
+			if err := q.Err(); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got != tc.want {
+				t.Fatalf("unexpected resources: got %+v want %+v", got, tc.want)
+			}
+			if parallelism != tc.parallelism {
+				t.Fatalf("unexpected parallelism: got %d want %d", parallelism, tc.parallelism)
+			}
+		})
+	}
+}
+
+func TestController_FairQueue(t *testing.T) {
+	var executed []string
+	executor := mock.NewExecutor()
+	executor.ExecuteFn = func(_ context.Context, orgID platform.ID, _ *plan.PlanSpec, _ *execute.Allocator) (map[string]query.Result, error) {
+		executed = append(executed, string(orgID))
+		return map[string]query.Result{}, nil
+	}
+
+	// The controller executes a single query at a time, so the others wait in the queues of their organizations.
+	ctrl := New(Config{
+		ConcurrencyQuota: 1,
+		OrganizationService: organizationService{
+			quotas: map[string]*platform.QueryQuotas{"b": {Weight: 2}},
+		},
+	})
+	ctrl.executor = executor
+	submit := func(orgID string) query.Query {
+		q, err := ctrl.Query(context.Background(), &query.Request{
+			OrganizationID: platform.ID(orgID),
+			Compiler:       mockCompiler,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		return q
+	}
+
+	first := submit("a")
+	<-first.Ready()
+	var queued []query.Query
+	for _, orgID := range []string{"a", "a", "a", "b", "b", "b"} {
+		queued = append(queued, submit(orgID))
+	}
+
+	for _, orgID := range []string{"a", "b"} {
+		gauge := ctrl.metrics.queueDepth.WithLabelValues(platform.ID(orgID).String())
+		for i := 0; ; i++ {
+			metric := &dto.Metric{}
+			if err := gauge.Write(metric); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if metric.Gauge.GetValue() == 3 {
+				break
+			}
+			if i == 1000 {
+				t.Fatalf("unexpected queue depth of organization %s: got %v want 3", orgID, metric.Gauge.GetValue())
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// Each query frees the resources it consumes for the next one once it is done.
+	var wg sync.WaitGroup
+	for _, q := range queued {
+		wg.Add(1)
+		go func(q query.Query) {
+			defer wg.Done()
+			<-q.Ready()
+			q.Done()
+		}(q)
+	}
+	first.Done()
+	wg.Wait()
+
+	// Organization b waits as long as organization a after executing twice as many queries.
+	if got, want := executed, []string{"a", "b", "b", "a", "b", "a", "a"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected execution order: got %v want %v", got, want)
+	}
+
+	for orgID, want := range map[string]uint64{"a": 4, "b": 3} {
+		histogram := ctrl.metrics.queueWaitDur.WithLabelValues(platform.ID(orgID).String()).(prometheus.Histogram)
+		metric := &dto.Metric{}
+		if err := histogram.Write(metric); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got := metric.Histogram.GetSampleCount(); got != want {
+			t.Fatalf("unexpected number of waits of organization %s: got %d want %d", orgID, got, want)
+		}
+	}
+}
+
+func TestController_ExecuteTimeout(t *testing.T) {
+	testCases := []struct {
+		name    string
+		config  time.Duration
+		max     time.Duration
+		request time.Duration
+		want    string
+	}{
+		{
+			name:   "default timeout",
+			config: 10 * time.Millisecond,
+			want:   "query timed out after executing for 10ms",
+		},
+		{
+			name:    "request timeout",
+			config:  time.Hour,
+			request: 20 * time.Millisecond,
+			want:    "query timed out after executing for 20ms",
+		},
+		{
+			name:    "request timeout longer than the default",
+			config:  10 * time.Millisecond,
+			request: 1000 * time.Hour,
+			want:    "query timed out after executing for 10ms",
+		},
+		{
+			name:    "request timeout longer than the maximum",
+			config:  10 * time.Millisecond,
+			max:     30 * time.Millisecond,
+			request: 1000 * time.Hour,
+			want:    "query timed out after executing for 30ms",
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			executed := make(chan context.Context, 1)
+			executor := mock.NewExecutor()
+			executor.ExecuteFn = func(ctx context.Context, _ platform.ID, _ *plan.PlanSpec, _ *execute.Allocator) (map[string]query.Result, error) {
+				executed <- ctx
+				return map[string]query.Result{}, nil
+			}
+
+			ctrl := New(Config{ExecuteTimeout: tc.config, MaxExecuteTimeout: tc.max})
+			ctrl.executor = executor
+			req := &query.Request{
+				OrganizationID: platform.ID("a"),
+				Compiler:       mockCompiler,
+				Timeout:        tc.request,
+			}
+
+			q, err := ctrl.Query(context.Background(), req)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			defer q.Done()
+			<-q.Ready()
+
+			// The execution of the query ends once its deadline passes.
+			ctx := <-executed
+			if _, ok := ctx.Deadline(); !ok {
+				t.Fatal("expected the query to execute with a deadline")
+			}
+			<-ctx.Done()
+			for i := 0; q.(*Query).State() != TimedOut; i++ {
+				if i == 1000 {
+					t.Fatalf("unexpected state after timeout: %v", q.(*Query).State())
+				}
+				time.Sleep(time.Millisecond)
+			}
+
+			if err, ok := q.Err().(kerrors.Error); !ok {
+				t.Fatalf("expected error %q, got %v", tc.want, q.Err())
+			} else if err.Err != tc.want || err.Reference != kerrors.Timeout {
+				t.Fatalf("unexpected error: got %q (%d) want %q (%d)", err.Err, err.Reference, tc.want, kerrors.Timeout)
+			}
+
+			counter := ctrl.metrics.timedOut.WithLabelValues(req.OrganizationID.String())
+			metric := &dto.Metric{}
+			if err := counter.Write(metric); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got := metric.Counter.GetValue(); got != 1 {
+				t.Fatalf("unexpected number of timed out queries: got %v want 1", got)
+			}
+		})
+	}
+}
+
+func TestController_QueueTimeout(t *testing.T) {
+	executor := mock.NewExecutor()
+	executor.ExecuteFn = func(context.Context, platform.ID, *plan.PlanSpec, *execute.Allocator) (map[string]query.Result, error) {
+		return map[string]query.Result{}, nil
+	}
+
+	// The controller executes a single query at a time, so the second query waits in the queue.
+	ctrl := New(Config{
+		ConcurrencyQuota: 1,
+		QueueTimeout:     10 * time.Millisecond,
+	})
+	ctrl.executor = executor
+	req := &query.Request{
+		OrganizationID: platform.ID("a"),
+		Compiler:       mockCompiler,
+	}
+
+	first, err := ctrl.Query(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer first.Done()
+	<-first.Ready()
+
+	q, err := ctrl.Query(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer q.Done()
+	if _, ok := <-q.Ready(); ok {
+		t.Fatal("expected the query to time out before it executes")
+	}
+
+	if got := q.(*Query).State(); got != TimedOut {
+		t.Fatalf("unexpected state after timeout: %v", got)
+	}
+	want := "query timed out after waiting 10ms in the queue"
+	if err, ok := q.Err().(kerrors.Error); !ok {
+		t.Fatalf("expected error %q, got %v", want, q.Err())
+	} else if err.Err != want || err.Reference != kerrors.Timeout {
+		t.Fatalf("unexpected error: got %q (%d) want %q (%d)", err.Err, err.Reference, want, kerrors.Timeout)
+	}
+
+	// The query that executes does not time out.
+	time.Sleep(10 * time.Millisecond)
+	if got := first.(*Query).State(); got != Executing {
+		t.Fatalf("unexpected state of the executing query: %v", got)
+	}
+}
+
+func TestController_MaxLiveQueries(t *testing.T) {
+	executor := mock.NewExecutor()
+	executor.ExecuteFn = func(context.Context, platform.ID, *plan.PlanSpec, *execute.Allocator) (map[string]query.Result, error) {
+		return map[string]query.Result{}, nil
+	}
+
+	ctrl := New(Config{MaxLiveQueries: 1})
+	ctrl.executor = executor
+	live := &query.Request{
+		OrganizationID: platform.ID("a"),
+		Compiler:       mockCompiler,
+		Live:           true,
+	}
+
+	first, err := ctrl.Query(context.Background(), live)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	<-first.Ready()
+
+	// The second live query is rejected while the first one runs.
+	want := "live query rejected, the server runs at most 1 live queries"
+	if _, err := ctrl.Query(context.Background(), live); err == nil {
+		t.Fatal("expected the live query to be rejected")
+	} else if err, ok := err.(kerrors.Error); !ok || err.Err != want || err.Reference != kerrors.Forbidden {
+		t.Fatalf("unexpected error: got %v want %q", err, want)
+	}
+
+	// Queries that are not live are not limited.
+	q, err := ctrl.Query(context.Background(), &query.Request{
+		OrganizationID: platform.ID("a"),
+		Compiler:       mockCompiler,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	<-q.Ready()
+	q.Done()
+
+	// A live query runs once the first one is done.
+	first.Done()
+	for i := 0; ; i++ {
+		q, err := ctrl.Query(context.Background(), live)
+		if err == nil {
+			q.Done()
+			break
+		}
+		if i == 1000 {
+			t.Fatalf("unexpected error after the live query is done: %s", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
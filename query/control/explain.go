@@ -0,0 +1,76 @@
+package control
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/execute"
+	"github.com/EMCECS/influx/query/plan"
+)
+
+const (
+	// ExplainResultName is the name of the only result of an explained query.
+	ExplainResultName = "_explain"
+	// ExplainColumn is the column of the explain result that contains the explanation encoded as JSON.
+	ExplainColumn = "_explain"
+)
+
+// explainResult is a result with a single table containing an explanation.
+type explainResult struct {
+	tbl query.Table
+}
+
+func newExplainResult(e *plan.Explanation, alloc *execute.Allocator) (*explainResult, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	b := execute.NewColListTableBuilder(execute.NewGroupKey(nil, nil), alloc)
+	b.AddCol(query.ColMeta{Label: ExplainColumn, Type: query.TString})
+	b.AppendString(0, string(data))
+	tbl, err := b.Table()
+	if err != nil {
+		return nil, err
+	}
+	return &explainResult{tbl: tbl}, nil
+}
+
+func (r *explainResult) Name() string {
+	return ExplainResultName
+}
+
+func (r *explainResult) Tables() query.TableIterator {
+	return r
+}
+
+func (r *explainResult) Do(f func(query.Table) error) error {
+	return f(r.tbl)
+}
+
+// ReadExplanation decodes the explanation from the result of an explained query.
+func ReadExplanation(r query.Result) (*plan.Explanation, error) {
+	var e *plan.Explanation
+	err := r.Tables().Do(func(tbl query.Table) error {
+		j := execute.ColIdx(ExplainColumn, tbl.Cols())
+		if j < 0 {
+			return fmt.Errorf("explain result is missing the %q column", ExplainColumn)
+		}
+		return tbl.Do(func(cr query.ColReader) error {
+			for _, s := range cr.Strings(j) {
+				e = new(plan.Explanation)
+				if err := json.Unmarshal([]byte(s), e); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return nil, fmt.Errorf("explain result contains no explanation")
+	}
+	return e, nil
+}
@@ -17,6 +17,17 @@ type controllerMetrics struct {
 	requeueingDur *prometheus.HistogramVec
 	planningDur   *prometheus.HistogramVec
 	executingDur  *prometheus.HistogramVec
+
+	spilledBytes *prometheus.CounterVec
+
+	cacheHits        *prometheus.CounterVec
+	cachePartialHits *prometheus.CounterVec
+	cacheMisses      *prometheus.CounterVec
+
+	queueDepth   *prometheus.GaugeVec
+	queueWaitDur *prometheus.HistogramVec
+
+	timedOut *prometheus.CounterVec
 }
 
 func newControllerMetrics() *controllerMetrics {
@@ -117,6 +128,56 @@ func newControllerMetrics() *controllerMetrics {
 			Help:      "Histogram of times spent executing queries",
 			Buckets:   prometheus.ExponentialBuckets(1e-3, 5, 7),
 		}, labels),
+
+		spilledBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "spilled_bytes_total",
+			Help:      "Number of bytes of buffered tables spilled to disk by queries",
+		}, labels),
+
+		cacheHits: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "cache_hits_total",
+			Help:      "Number of queries whose results were read from the result cache",
+		}, labels),
+
+		cachePartialHits: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "cache_partial_hits_total",
+			Help:      "Number of queries executed over the time slice following the cached results they reuse",
+		}, labels),
+
+		cacheMisses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "cache_misses_total",
+			Help:      "Number of cacheable queries whose results were not cached",
+		}, labels),
+
+		queueDepth: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "queue_depth",
+			Help:      "Number of queries waiting in the queue of the organization",
+		}, labels),
+
+		queueWaitDur: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "queue_wait_duration_seconds",
+			Help:      "Histogram of times queries waited in the queue of their organization before executing",
+			Buckets:   prometheus.ExponentialBuckets(1e-3, 5, 7),
+		}, labels),
+
+		timedOut: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "timed_out_total",
+			Help:      "Number of queries that timed out waiting in the queue or executing",
+		}, labels),
 	}
 }
 
@@ -136,5 +197,16 @@ func (cm *controllerMetrics) PrometheusCollectors() []prometheus.Collector {
 		cm.requeueingDur,
 		cm.planningDur,
 		cm.executingDur,
+
+		cm.spilledBytes,
+
+		cm.cacheHits,
+		cm.cachePartialHits,
+		cm.cacheMisses,
+
+		cm.queueDepth,
+		cm.queueWaitDur,
+
+		cm.timedOut,
 	}
 }
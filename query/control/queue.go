@@ -1,14 +1,24 @@
 package control
 
-import "container/heap"
+import (
+	"container/heap"
+	"math"
+	"sort"
+
+	"github.com/EMCECS/influx"
+)
 
 // priorityQueue implements heap.Interface and holds Query objects.
+// Queries with the same priority are ordered by the order they were submitted in.
 type priorityQueue []*Query
 
 func (pq priorityQueue) Len() int { return len(pq) }
 
 func (pq priorityQueue) Less(i, j int) bool {
-	return pq[i].spec.Resources.Priority < pq[j].spec.Resources.Priority
+	if pi, pj := pq[i].spec.Resources.Priority, pq[j].spec.Resources.Priority; pi != pj {
+		return pi < pj
+	}
+	return pq[i].id < pq[j].id
 }
 
 func (pq priorityQueue) Swap(i, j int) {
@@ -38,6 +48,10 @@ func newPriorityQueue() *PriorityQueue {
 	}
 }
 
+func (p *PriorityQueue) Len() int {
+	return p.queue.Len()
+}
+
 func (p *PriorityQueue) Push(q *Query) {
 	heap.Push(&p.queue, q)
 }
@@ -65,3 +79,146 @@ func (p *PriorityQueue) Pop() *Query {
 		}
 	}
 }
+
+// orgQueue is the queue of the queries of an organization,
+// along with the resources consumed by the queries of the organization that are executing.
+type orgQueue struct {
+	*PriorityQueue
+
+	id     platform.ID
+	quotas platform.QueryQuotas
+
+	// vtime is the virtual time of the organization,
+	// it advances by the concurrency of each query the organization executes divided by its weight.
+	vtime float64
+
+	executing   int
+	concurrency int
+	memory      int64
+}
+
+// weight returns the weight of the organization, one by default.
+func (o *orgQueue) weight() float64 {
+	if o.quotas.Weight <= 0 {
+		return 1
+	}
+	return float64(o.quotas.Weight)
+}
+
+// fits reports whether the resources of the query fit within the quotas of the organization,
+// along with the resources of the queries of the organization that are executing.
+func (o *orgQueue) fits(q *Query) bool {
+	if n := o.quotas.ConcurrencyQuota; n > 0 && o.concurrency+q.concurrency > n {
+		return false
+	}
+	if n := o.quotas.MemoryBytesQuota; n > 0 && q.memory != math.MaxInt64 && o.memory+q.memory > n {
+		return false
+	}
+	return true
+}
+
+// fairQueue holds a queue of queries for each organization, and serves the organizations by weighted fair queuing.
+// Each organization has a virtual time, which advances by the concurrency of each query it executes divided by its weight,
+// and the organization with the earliest virtual time is served first.
+// So the organizations waiting for resources are given shares of the resources proportional to their weights,
+// regardless of the number of queries each one queues.
+type fairQueue struct {
+	orgs map[string]*orgQueue
+
+	// vtime is the latest virtual time an organization was served at.
+	// Organizations that start queueing again start from it, so they do not make up for the time they did not queue.
+	vtime float64
+}
+
+func newFairQueue() *fairQueue {
+	return &fairQueue{
+		orgs: make(map[string]*orgQueue),
+	}
+}
+
+// org returns the queue of the organization.
+func (fq *fairQueue) org(id platform.ID) *orgQueue {
+	o, ok := fq.orgs[string(id)]
+	if !ok {
+		o = &orgQueue{
+			PriorityQueue: newPriorityQueue(),
+			id:            id,
+			vtime:         fq.vtime,
+		}
+		fq.orgs[string(id)] = o
+	}
+	return o
+}
+
+// Push queues the query in the queue of its organization.
+func (fq *fairQueue) Push(q *Query) {
+	o := fq.org(q.orgID)
+	o.quotas = q.quotas
+	if o.Peek() == nil && o.vtime < fq.vtime {
+		o.vtime = fq.vtime
+	}
+	o.Push(q)
+}
+
+// Orgs returns the organizations with queued queries, in the order they are served.
+func (fq *fairQueue) Orgs() []*orgQueue {
+	orgs := make([]*orgQueue, 0, len(fq.orgs))
+	for _, o := range fq.orgs {
+		if o.Peek() != nil {
+			orgs = append(orgs, o)
+		}
+	}
+	sort.Slice(orgs, func(i, j int) bool {
+		if orgs[i].vtime != orgs[j].vtime {
+			return orgs[i].vtime < orgs[j].vtime
+		}
+		return string(orgs[i].id) < string(orgs[j].id)
+	})
+	return orgs
+}
+
+// fits reports whether the resources of the query fit within the quotas of its organization.
+func (fq *fairQueue) fits(q *Query) bool {
+	return fq.org(q.orgID).fits(q)
+}
+
+// consume accounts for the resources of a query of the organization that starts executing.
+func (fq *fairQueue) consume(q *Query) {
+	o := fq.org(q.orgID)
+	if o.vtime > fq.vtime {
+		fq.vtime = o.vtime
+	}
+	cost := q.concurrency
+	if cost < 1 {
+		cost = 1
+	}
+	o.vtime += float64(cost) / o.weight()
+
+	o.executing++
+	o.concurrency += q.concurrency
+	if q.memory != math.MaxInt64 {
+		o.memory += q.memory
+	}
+}
+
+// free accounts for the resources of a query of the organization that is done executing.
+func (fq *fairQueue) free(q *Query) {
+	o := fq.org(q.orgID)
+	o.executing--
+	o.concurrency -= q.concurrency
+	if q.memory != math.MaxInt64 {
+		o.memory -= q.memory
+	}
+}
+
+// depths calls f with the number of queries queued by each organization,
+// and removes the queues of the organizations that hold no query and none of whose queries executes.
+func (fq *fairQueue) depths(f func(id platform.ID, n int)) {
+	for k, o := range fq.orgs {
+		o.Peek()
+		f(o.id, o.Len())
+		if o.Len() == 0 && o.executing == 0 {
+			delete(fq.orgs, k)
+		}
+	}
+}
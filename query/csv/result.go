@@ -12,6 +12,7 @@ import (
 	"time"
 	"unicode/utf8"
 
+	kerrors "github.com/EMCECS/influx/kit/errors"
 	"github.com/EMCECS/influx/query"
 	"github.com/EMCECS/influx/query/execute"
 	"github.com/EMCECS/influx/query/iocounter"
@@ -583,6 +584,12 @@ func (d *tableDecoder) appendRecord(record []string) error {
 			}
 			continue
 		}
+		if record[j] == "" && c.Type != query.TString {
+			// An empty cell without a default value is null.
+			// Empty strings cannot be distinguished from null and are decoded as empty strings.
+			d.builder.AppendNil(j)
+			continue
+		}
 		if err := decodeValueInto(j, c, record[j], d.builder); err != nil {
 			return err
 		}
@@ -813,8 +820,11 @@ func (e *ResultEncoder) EncodeError(w io.Writer, err error) error {
 	}
 
 	writer.Write([]string{"error", "reference"})
-	// TODO: Add referenced code
-	writer.Write([]string{err.Error(), ""})
+	if e, ok := err.(kerrors.Error); ok {
+		writer.Write([]string{e.Err, strconv.Itoa(e.Reference)})
+	} else {
+		writer.Write([]string{err.Error(), ""})
+	}
 	writer.Flush()
 	return writer.Error()
 }
@@ -1035,7 +1045,11 @@ func encodeValue(value values.Value, c colMeta) (string, error) {
 	}
 }
 
+// encodeValueFrom encodes the value of row i of column j, null values are encoded as empty cells.
 func encodeValueFrom(i, j int, c colMeta, cr query.ColReader) (string, error) {
+	if execute.IsNull(i, j, cr) {
+		return "", nil
+	}
 	switch c.Type {
 	case query.TBool:
 		return strconv.FormatBool(cr.Bools(j)[i]), nil
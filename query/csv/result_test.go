@@ -8,6 +8,7 @@ import (
 
 	"github.com/andreyvit/diff"
 	"github.com/google/go-cmp/cmp"
+	kerrors "github.com/EMCECS/influx/kit/errors"
 	"github.com/EMCECS/influx/query"
 	"github.com/EMCECS/influx/query/csv"
 	"github.com/EMCECS/influx/query/execute/executetest"
@@ -68,6 +69,52 @@ var symetricalTestCases = []TestCase{
 			}},
 		},
 	},
+	{
+		name:          "single table with nulls",
+		encoderConfig: csv.DefaultEncoderConfig(),
+		encoded: toCRLF(`#datatype,string,long,dateTime:RFC3339,dateTime:RFC3339,dateTime:RFC3339,string,string,double,long
+#group,false,false,true,true,false,true,true,false,false
+#default,_result,,,,,,,,
+,result,table,_start,_stop,_time,_measurement,host,_value,count
+,,0,2018-04-17T00:00:00Z,2018-04-17T00:05:00Z,2018-04-17T00:00:00Z,cpu,A,,1
+,,0,2018-04-17T00:00:00Z,2018-04-17T00:05:00Z,2018-04-17T00:00:01Z,cpu,A,43,
+`),
+		result: &executetest.Result{
+			Nm: "_result",
+			Tbls: []*executetest.Table{{
+				KeyCols: []string{"_start", "_stop", "_measurement", "host"},
+				ColMeta: []query.ColMeta{
+					{Label: "_start", Type: query.TTime},
+					{Label: "_stop", Type: query.TTime},
+					{Label: "_time", Type: query.TTime},
+					{Label: "_measurement", Type: query.TString},
+					{Label: "host", Type: query.TString},
+					{Label: "_value", Type: query.TFloat},
+					{Label: "count", Type: query.TInt},
+				},
+				Data: [][]interface{}{
+					{
+						values.ConvertTime(time.Date(2018, 4, 17, 0, 0, 0, 0, time.UTC)),
+						values.ConvertTime(time.Date(2018, 4, 17, 0, 5, 0, 0, time.UTC)),
+						values.ConvertTime(time.Date(2018, 4, 17, 0, 0, 0, 0, time.UTC)),
+						"cpu",
+						"A",
+						nil,
+						int64(1),
+					},
+					{
+						values.ConvertTime(time.Date(2018, 4, 17, 0, 0, 0, 0, time.UTC)),
+						values.ConvertTime(time.Date(2018, 4, 17, 0, 5, 0, 0, time.UTC)),
+						values.ConvertTime(time.Date(2018, 4, 17, 0, 0, 1, 0, time.UTC)),
+						"cpu",
+						"A",
+						43.0,
+						nil,
+					},
+				},
+			}},
+		},
+	},
 	{
 		name:          "single empty table",
 		encoderConfig: csv.DefaultEncoderConfig(),
@@ -676,6 +723,16 @@ func TestMultiResultEncoder(t *testing.T) {
 			},
 			encoded: toCRLF(`error,reference
 test error,
+`),
+		},
+		{
+			name:   "error results with reference",
+			config: csv.DefaultEncoderConfig(),
+			results: errorResultIterator{
+				Error: kerrors.Timeoutf("query timed out after executing for 1s"),
+			},
+			encoded: toCRLF(`error,reference
+query timed out after executing for 1s,6
 `),
 		},
 		{
@@ -42,6 +42,17 @@ func DefaultAggregateSignature() semantic.FunctionSignature {
 	}
 }
 
+// Parallel reports that aggregates can process their tables in parallel,
+// each table is aggregated into a table with the same group key.
+func (c AggregateConfig) Parallel() bool {
+	return true
+}
+
+// PreservesKey reports that aggregates process each table into a table with the same group key.
+func (c AggregateConfig) PreservesKey() bool {
+	return true
+}
+
 func (c AggregateConfig) Copy() AggregateConfig {
 	nc := c
 	if c.Columns != nil {
@@ -117,6 +128,7 @@ func (t *aggregateTransformation) Process(id DatasetID, tbl query.Table) error {
 	builderColMap := make([]int, len(t.config.Columns))
 	tableColMap := make([]int, len(t.config.Columns))
 	aggregates := make([]ValueFunc, len(t.config.Columns))
+	counts := make([]int, len(t.config.Columns))
 
 	cols := tbl.Cols()
 	for j, label := range t.config.Columns {
@@ -168,6 +180,10 @@ func (t *aggregateTransformation) Process(id DatasetID, tbl query.Table) error {
 			tj := tableColMap[j]
 			c := tbl.Cols()[tj]
 
+			// Null values are not aggregated.
+			cr := SkipNulls(cr, tj)
+			counts[j] += cr.Len()
+
 			switch c.Type {
 			case query.TBool:
 				vf.(DoBoolAgg).DoBool(cr.Bools(tj))
@@ -187,6 +203,13 @@ func (t *aggregateTransformation) Process(id DatasetID, tbl query.Table) error {
 	})
 	for j, vf := range aggregates {
 		bj := builderColMap[j]
+		if counts[j] == 0 {
+			if e, ok := vf.(EmptyValueFunc); !ok || !e.HasEmptyValue() {
+				// There are no values to aggregate.
+				builder.AppendNil(bj)
+				continue
+			}
+		}
 		// Append aggregated value
 		switch vf.Type() {
 		case query.TBool:
@@ -251,6 +274,13 @@ type Aggregate interface {
 type ValueFunc interface {
 	Type() query.DataType
 }
+
+// EmptyValueFunc is implemented by aggregates that have a value when there are no values to aggregate,
+// for example a count of zero. Otherwise the aggregate of no values is null.
+type EmptyValueFunc interface {
+	ValueFunc
+	HasEmptyValue() bool
+}
 type DoBoolAgg interface {
 	ValueFunc
 	DoBool([]bool)
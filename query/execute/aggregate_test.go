@@ -414,6 +414,98 @@ func TestAggregate_Process(t *testing.T) {
 				},
 			}},
 		},
+		{
+			name:   "single with nulls",
+			config: execute.DefaultAggregateConfig,
+			agg:    sumAgg,
+			data: []*executetest.Table{{
+				KeyCols: []string{"_start", "_stop"},
+				ColMeta: []query.ColMeta{
+					{Label: "_start", Type: query.TTime},
+					{Label: "_stop", Type: query.TTime},
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TFloat},
+				},
+				Data: [][]interface{}{
+					{execute.Time(0), execute.Time(100), execute.Time(0), 1.0},
+					{execute.Time(0), execute.Time(100), execute.Time(10), nil},
+					{execute.Time(0), execute.Time(100), execute.Time(20), 2.0},
+					{execute.Time(0), execute.Time(100), execute.Time(30), nil},
+				},
+			}},
+			want: []*executetest.Table{{
+				KeyCols: []string{"_start", "_stop"},
+				ColMeta: []query.ColMeta{
+					{Label: "_start", Type: query.TTime},
+					{Label: "_stop", Type: query.TTime},
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TFloat},
+				},
+				Data: [][]interface{}{
+					{execute.Time(0), execute.Time(100), execute.Time(100), 3.0},
+				},
+			}},
+		},
+		{
+			name:   "only nulls",
+			config: execute.DefaultAggregateConfig,
+			agg:    sumAgg,
+			data: []*executetest.Table{{
+				KeyCols: []string{"_start", "_stop"},
+				ColMeta: []query.ColMeta{
+					{Label: "_start", Type: query.TTime},
+					{Label: "_stop", Type: query.TTime},
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TFloat},
+				},
+				Data: [][]interface{}{
+					{execute.Time(0), execute.Time(100), execute.Time(0), nil},
+					{execute.Time(0), execute.Time(100), execute.Time(10), nil},
+				},
+			}},
+			want: []*executetest.Table{{
+				KeyCols: []string{"_start", "_stop"},
+				ColMeta: []query.ColMeta{
+					{Label: "_start", Type: query.TTime},
+					{Label: "_stop", Type: query.TTime},
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TFloat},
+				},
+				Data: [][]interface{}{
+					{execute.Time(0), execute.Time(100), execute.Time(100), nil},
+				},
+			}},
+		},
+		{
+			name:   "count only nulls",
+			config: execute.DefaultAggregateConfig,
+			agg:    countAgg,
+			data: []*executetest.Table{{
+				KeyCols: []string{"_start", "_stop"},
+				ColMeta: []query.ColMeta{
+					{Label: "_start", Type: query.TTime},
+					{Label: "_stop", Type: query.TTime},
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TFloat},
+				},
+				Data: [][]interface{}{
+					{execute.Time(0), execute.Time(100), execute.Time(0), nil},
+					{execute.Time(0), execute.Time(100), execute.Time(10), nil},
+				},
+			}},
+			want: []*executetest.Table{{
+				KeyCols: []string{"_start", "_stop"},
+				ColMeta: []query.ColMeta{
+					{Label: "_start", Type: query.TTime},
+					{Label: "_stop", Type: query.TTime},
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TInt},
+				},
+				Data: [][]interface{}{
+					{execute.Time(0), execute.Time(100), execute.Time(100), int64(0)},
+				},
+			}},
+		},
 	}
 	for _, tc := range testCases {
 		tc := tc
@@ -21,6 +21,18 @@ type Allocator struct {
 	Limit          int64
 	bytesAllocated int64
 	maxAllocated   int64
+
+	// parent is the allocator that enforces the limit, if this is a child allocator.
+	parent *Allocator
+}
+
+// newChildAllocator returns an allocator that tracks its own allocations,
+// while accounting for them and enforcing the limit on the parent.
+func newChildAllocator(parent *Allocator) *Allocator {
+	return &Allocator{
+		Limit:  parent.Limit,
+		parent: parent,
+	}
 }
 
 func (a *Allocator) count(n, size int) (c int64) {
@@ -36,6 +48,14 @@ func (a *Allocator) count(n, size int) (c int64) {
 // Free informs the allocator that memory has been freed.
 func (a *Allocator) Free(n, size int) {
 	a.count(-n, size)
+	if a.parent != nil {
+		a.parent.Free(n, size)
+	}
+}
+
+// Allocated reports the amount of memory currently allocated.
+func (a *Allocator) Allocated() int64 {
+	return atomic.LoadInt64(&a.bytesAllocated)
 }
 
 // Max reports the maximum amount of allocated memory at any point in the query.
@@ -44,6 +64,12 @@ func (a *Allocator) Max() int64 {
 }
 
 func (a *Allocator) account(n, size int) {
+	if a.parent != nil {
+		// The parent panics if the limit is reached.
+		a.parent.account(n, size)
+		a.count(n, size)
+		return
+	}
 	if want := a.count(n, size); want > a.Limit {
 		allocated := a.count(-n, size)
 		panic(AllocError{
@@ -0,0 +1,476 @@
+package execute
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/semantic"
+	"github.com/EMCECS/influx/query/values"
+	"github.com/pkg/errors"
+)
+
+// tableChunkSize is the maximum number of rows of the chunks a table is encoded in.
+const tableChunkSize = 1024
+
+// EncodeTable writes the table to w in a binary format that is read back by DecodeTable.
+//
+// The group key and the columns of the table are written first, followed by its rows in chunks,
+// so that the table can be read back one chunk at a time.
+// Each chunk starts with its number of rows, and the table ends with an empty chunk.
+// The values of a chunk are written column by column, each column preceded by its null mask if it has nulls.
+func EncodeTable(w io.Writer, tbl query.Table) error {
+	bw := bufio.NewWriter(w)
+	e := &tableEncoder{w: bw}
+	if err := e.encode(tbl); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// DecodeTable reads a table written by EncodeTable, allocating its data from a.
+func DecodeTable(r io.Reader, a *Allocator) (query.Table, error) {
+	d := newTableDecoder(r)
+	key, cols, err := d.header()
+	if err != nil {
+		return nil, err
+	}
+	builder := NewColListTableBuilder(key, a)
+	for _, c := range cols {
+		builder.AddCol(c)
+	}
+	for {
+		chunk, err := d.chunk(key, cols, a)
+		if err != nil {
+			builder.ClearData()
+			return nil, err
+		}
+		if chunk == nil {
+			return builder.RawTable(), nil
+		}
+		AppendCols(chunk, builder)
+		chunk.free()
+	}
+}
+
+// tableEncoder writes the binary format of tables.
+// The first error is kept, and later writes are skipped.
+type tableEncoder struct {
+	w   io.Writer
+	buf [binary.MaxVarintLen64]byte
+	err error
+}
+
+func (e *tableEncoder) encode(tbl query.Table) error {
+	e.header(tbl.Key(), tbl.Cols())
+	_, err := e.rows(tbl)
+	return err
+}
+
+// rows writes the rows of the table in chunks, followed by the empty chunk that ends the table.
+// It returns the number of rows written.
+func (e *tableEncoder) rows(tbl query.Table) (int, error) {
+	nrows := 0
+	err := tbl.Do(func(cr query.ColReader) error {
+		for i := 0; i < cr.Len() && e.err == nil; i += tableChunkSize {
+			stop := i + tableChunkSize
+			if stop > cr.Len() {
+				stop = cr.Len()
+			}
+			e.chunk(cr, i, stop)
+		}
+		nrows += cr.Len()
+		return e.err
+	})
+	if err != nil {
+		return 0, err
+	}
+	e.uvarint(0)
+	return nrows, e.err
+}
+
+func (e *tableEncoder) header(key query.GroupKey, cols []query.ColMeta) {
+	e.cols(key.Cols())
+	for j, c := range key.Cols() {
+		v := key.Value(j)
+		if v.Type() == semantic.Nil {
+			e.bool(true)
+			continue
+		}
+		e.bool(false)
+		switch c.Type {
+		case query.TBool:
+			e.bool(v.Bool())
+		case query.TInt:
+			e.varint(v.Int())
+		case query.TUInt:
+			e.uvarint(v.UInt())
+		case query.TFloat:
+			e.float(v.Float())
+		case query.TString:
+			e.string(v.Str())
+		case query.TTime:
+			e.varint(int64(v.Time()))
+		default:
+			PanicUnknownType(c.Type)
+		}
+	}
+	e.cols(cols)
+}
+
+func (e *tableEncoder) cols(cols []query.ColMeta) {
+	e.uvarint(uint64(len(cols)))
+	for _, c := range cols {
+		e.string(c.Label)
+		e.uvarint(uint64(c.Type))
+	}
+}
+
+// chunk writes the rows of cr from start to stop.
+func (e *tableEncoder) chunk(cr query.ColReader, start, stop int) {
+	e.uvarint(uint64(stop - start))
+	for j, c := range cr.Cols() {
+		nulls := cr.Nulls(j)
+		hasNulls := false
+		if nulls != nil {
+			for _, null := range nulls[start:stop] {
+				if null {
+					hasNulls = true
+					break
+				}
+			}
+		}
+		e.bool(hasNulls)
+		if hasNulls {
+			for _, null := range nulls[start:stop] {
+				e.bool(null)
+			}
+		}
+		switch c.Type {
+		case query.TBool:
+			for _, v := range cr.Bools(j)[start:stop] {
+				e.bool(v)
+			}
+		case query.TInt:
+			for _, v := range cr.Ints(j)[start:stop] {
+				e.varint(v)
+			}
+		case query.TUInt:
+			for _, v := range cr.UInts(j)[start:stop] {
+				e.uvarint(v)
+			}
+		case query.TFloat:
+			for _, v := range cr.Floats(j)[start:stop] {
+				e.float(v)
+			}
+		case query.TString:
+			for _, v := range cr.Strings(j)[start:stop] {
+				e.string(v)
+			}
+		case query.TTime:
+			for _, v := range cr.Times(j)[start:stop] {
+				e.varint(int64(v))
+			}
+		default:
+			PanicUnknownType(c.Type)
+		}
+	}
+}
+
+func (e *tableEncoder) write(p []byte) {
+	if e.err != nil {
+		return
+	}
+	_, e.err = e.w.Write(p)
+}
+
+func (e *tableEncoder) bool(v bool) {
+	e.buf[0] = 0
+	if v {
+		e.buf[0] = 1
+	}
+	e.write(e.buf[:1])
+}
+
+func (e *tableEncoder) uvarint(v uint64) {
+	n := binary.PutUvarint(e.buf[:], v)
+	e.write(e.buf[:n])
+}
+
+func (e *tableEncoder) varint(v int64) {
+	n := binary.PutVarint(e.buf[:], v)
+	e.write(e.buf[:n])
+}
+
+func (e *tableEncoder) float(v float64) {
+	binary.LittleEndian.PutUint64(e.buf[:8], math.Float64bits(v))
+	e.write(e.buf[:8])
+}
+
+func (e *tableEncoder) string(v string) {
+	e.uvarint(uint64(len(v)))
+	if e.err != nil {
+		return
+	}
+	_, e.err = io.WriteString(e.w, v)
+}
+
+// tableDecoder reads the binary format of tables.
+type tableDecoder struct {
+	r   *bufio.Reader
+	buf []byte
+}
+
+func newTableDecoder(r io.Reader) *tableDecoder {
+	return &tableDecoder{
+		r: bufio.NewReader(r),
+	}
+}
+
+func wrapDecodingError(err error) error {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return errors.Wrap(err, "failed to decode table")
+}
+
+func (d *tableDecoder) header() (query.GroupKey, []query.ColMeta, error) {
+	keyCols, err := d.cols()
+	if err != nil {
+		return nil, nil, err
+	}
+	vs := make([]values.Value, len(keyCols))
+	for j, c := range keyCols {
+		null, err := d.bool()
+		if err != nil {
+			return nil, nil, err
+		}
+		if null {
+			vs[j] = values.Null
+			continue
+		}
+		switch c.Type {
+		case query.TBool:
+			v, err := d.bool()
+			if err != nil {
+				return nil, nil, err
+			}
+			vs[j] = values.NewBoolValue(v)
+		case query.TInt:
+			v, err := d.varint()
+			if err != nil {
+				return nil, nil, err
+			}
+			vs[j] = values.NewIntValue(v)
+		case query.TUInt:
+			v, err := d.uvarint()
+			if err != nil {
+				return nil, nil, err
+			}
+			vs[j] = values.NewUIntValue(v)
+		case query.TFloat:
+			v, err := d.float()
+			if err != nil {
+				return nil, nil, err
+			}
+			vs[j] = values.NewFloatValue(v)
+		case query.TString:
+			v, err := d.string()
+			if err != nil {
+				return nil, nil, err
+			}
+			vs[j] = values.NewStringValue(v)
+		case query.TTime:
+			v, err := d.varint()
+			if err != nil {
+				return nil, nil, err
+			}
+			vs[j] = values.NewTimeValue(Time(v))
+		}
+	}
+	cols, err := d.cols()
+	if err != nil {
+		return nil, nil, err
+	}
+	return NewGroupKey(keyCols, vs), cols, nil
+}
+
+func (d *tableDecoder) cols() ([]query.ColMeta, error) {
+	n, err := d.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	cols := make([]query.ColMeta, n)
+	for j := range cols {
+		label, err := d.string()
+		if err != nil {
+			return nil, err
+		}
+		typ, err := d.uvarint()
+		if err != nil {
+			return nil, err
+		}
+		cols[j] = query.ColMeta{Label: label, Type: query.DataType(typ)}
+		switch cols[j].Type {
+		case query.TBool, query.TInt, query.TUInt, query.TFloat, query.TString, query.TTime:
+		default:
+			return nil, wrapDecodingError(fmt.Errorf("column %q has unknown type %v", label, cols[j].Type))
+		}
+	}
+	return cols, nil
+}
+
+// chunk reads the next chunk of rows, allocating them from a.
+// It returns nil once the table has been read.
+// The data of the chunk must be freed once it has been read.
+func (d *tableDecoder) chunk(key query.GroupKey, cols []query.ColMeta, a *Allocator) (*ColListTable, error) {
+	n, err := d.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	l := int(n)
+	t := &ColListTable{
+		key:     key,
+		colMeta: cols,
+		cols:    make([]column, len(cols)),
+		nulls:   make([][]bool, len(cols)),
+		nrows:   l,
+		alloc:   a,
+	}
+	if err := d.chunkCols(t, l); err != nil {
+		t.free()
+		return nil, err
+	}
+	return t, nil
+}
+
+func (d *tableDecoder) chunkCols(t *ColListTable, l int) error {
+	a := t.alloc
+	for j, c := range t.colMeta {
+		hasNulls, err := d.bool()
+		if err != nil {
+			return err
+		}
+		if hasNulls {
+			t.nulls[j] = a.Bools(l, l)
+			for i := range t.nulls[j] {
+				if t.nulls[j][i], err = d.bool(); err != nil {
+					return err
+				}
+			}
+		}
+		switch c.Type {
+		case query.TBool:
+			col := &boolColumn{ColMeta: c, data: a.Bools(l, l), alloc: a}
+			t.cols[j] = col
+			for i := range col.data {
+				if col.data[i], err = d.bool(); err != nil {
+					return err
+				}
+			}
+		case query.TInt:
+			col := &intColumn{ColMeta: c, data: a.Ints(l, l), alloc: a}
+			t.cols[j] = col
+			for i := range col.data {
+				if col.data[i], err = d.varint(); err != nil {
+					return err
+				}
+			}
+		case query.TUInt:
+			col := &uintColumn{ColMeta: c, data: a.UInts(l, l), alloc: a}
+			t.cols[j] = col
+			for i := range col.data {
+				if col.data[i], err = d.uvarint(); err != nil {
+					return err
+				}
+			}
+		case query.TFloat:
+			col := &floatColumn{ColMeta: c, data: a.Floats(l, l), alloc: a}
+			t.cols[j] = col
+			for i := range col.data {
+				if col.data[i], err = d.float(); err != nil {
+					return err
+				}
+			}
+		case query.TString:
+			col := &stringColumn{ColMeta: c, data: a.Strings(l, l), alloc: a}
+			t.cols[j] = col
+			for i := range col.data {
+				if col.data[i], err = d.string(); err != nil {
+					return err
+				}
+			}
+		case query.TTime:
+			col := &timeColumn{ColMeta: c, data: a.Times(l, l), alloc: a}
+			t.cols[j] = col
+			for i := range col.data {
+				v, err := d.varint()
+				if err != nil {
+					return err
+				}
+				col.data[i] = Time(v)
+			}
+		}
+	}
+	return nil
+}
+
+func (d *tableDecoder) bool() (bool, error) {
+	b, err := d.r.ReadByte()
+	if err != nil {
+		return false, wrapDecodingError(err)
+	}
+	return b != 0, nil
+}
+
+func (d *tableDecoder) uvarint() (uint64, error) {
+	v, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return 0, wrapDecodingError(err)
+	}
+	return v, nil
+}
+
+func (d *tableDecoder) varint() (int64, error) {
+	v, err := binary.ReadVarint(d.r)
+	if err != nil {
+		return 0, wrapDecodingError(err)
+	}
+	return v, nil
+}
+
+func (d *tableDecoder) float() (float64, error) {
+	if err := d.read(8); err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(d.buf)), nil
+}
+
+func (d *tableDecoder) string() (string, error) {
+	n, err := d.uvarint()
+	if err != nil {
+		return "", err
+	}
+	if err := d.read(int(n)); err != nil {
+		return "", err
+	}
+	return string(d.buf), nil
+}
+
+// read reads the next n bytes into buf.
+func (d *tableDecoder) read(n int) error {
+	if cap(d.buf) < n {
+		d.buf = make([]byte, n)
+	}
+	d.buf = d.buf[:n]
+	if _, err := io.ReadFull(d.r, d.buf); err != nil {
+		return wrapDecodingError(err)
+	}
+	return nil
+}
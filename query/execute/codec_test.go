@@ -0,0 +1,134 @@
+package execute_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/execute"
+	"github.com/EMCECS/influx/query/execute/executetest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEncodeDecodeTable(t *testing.T) {
+	testCases := []struct {
+		name  string
+		table *executetest.Table
+	}{
+		{
+			name: "all types",
+			table: &executetest.Table{
+				KeyCols: []string{"_start", "t", "b"},
+				ColMeta: []query.ColMeta{
+					{Label: "_start", Type: query.TTime},
+					{Label: "_time", Type: query.TTime},
+					{Label: "t", Type: query.TString},
+					{Label: "b", Type: query.TBool},
+					{Label: "i", Type: query.TInt},
+					{Label: "u", Type: query.TUInt},
+					{Label: "f", Type: query.TFloat},
+					{Label: "s", Type: query.TString},
+				},
+				Data: [][]interface{}{
+					{execute.Time(0), execute.Time(1), "a", true, int64(-1), uint64(1), 1.5, "x"},
+					{execute.Time(0), execute.Time(2), "a", true, int64(1 << 40), uint64(1 << 63), -0.25, ""},
+				},
+			},
+		},
+		{
+			name: "nulls",
+			table: &executetest.Table{
+				KeyCols:   []string{"t"},
+				KeyValues: []interface{}{"a"},
+				ColMeta: []query.ColMeta{
+					{Label: "_time", Type: query.TTime},
+					{Label: "t", Type: query.TString},
+					{Label: "b", Type: query.TBool},
+					{Label: "i", Type: query.TInt},
+					{Label: "f", Type: query.TFloat},
+					{Label: "s", Type: query.TString},
+				},
+				Data: [][]interface{}{
+					{execute.Time(1), "a", nil, int64(1), nil, "x"},
+					{nil, "a", false, nil, 2.0, nil},
+				},
+			},
+		},
+		{
+			name: "empty",
+			table: &executetest.Table{
+				KeyCols:   []string{"t"},
+				KeyValues: []interface{}{"a"},
+				ColMeta: []query.ColMeta{
+					{Label: "t", Type: query.TString},
+					{Label: "_value", Type: query.TFloat},
+				},
+			},
+		},
+		{
+			name: "chunks",
+			table: func() *executetest.Table {
+				tbl := &executetest.Table{
+					ColMeta: []query.ColMeta{
+						{Label: "_time", Type: query.TTime},
+						{Label: "_value", Type: query.TInt},
+					},
+				}
+				for i := 0; i < 2500; i++ {
+					var v interface{}
+					if i%7 != 0 {
+						v = int64(i)
+					}
+					tbl.Data = append(tbl.Data, []interface{}{execute.Time(i), v})
+				}
+				return tbl
+			}(),
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			tc.table.Normalize()
+
+			var buf bytes.Buffer
+			if err := execute.EncodeTable(&buf, tc.table); err != nil {
+				t.Fatal(err)
+			}
+			tbl, err := execute.DecodeTable(&buf, executetest.UnlimitedAllocator)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := executetest.ConvertTable(tbl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got.Normalize()
+			if !cmp.Equal(tc.table, got) {
+				t.Errorf("unexpected table -want/+got\n%s", cmp.Diff(tc.table, got))
+			}
+		})
+	}
+}
+
+func TestDecodeTable_Truncated(t *testing.T) {
+	tbl := &executetest.Table{
+		ColMeta: []query.ColMeta{
+			{Label: "_time", Type: query.TTime},
+			{Label: "_value", Type: query.TString},
+		},
+		Data: [][]interface{}{
+			{execute.Time(1), "abc"},
+			{execute.Time(2), "def"},
+		},
+	}
+	tbl.Normalize()
+	var buf bytes.Buffer
+	if err := execute.EncodeTable(&buf, tbl); err != nil {
+		t.Fatal(err)
+	}
+	for n := 0; n < buf.Len(); n++ {
+		if _, err := execute.DecodeTable(bytes.NewReader(buf.Bytes()[:n]), executetest.UnlimitedAllocator); err == nil {
+			t.Fatalf("expected error decoding the first %d of %d bytes", n, buf.Len())
+		}
+	}
+}
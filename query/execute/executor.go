@@ -66,6 +66,11 @@ type executionState struct {
 
 	dispatcher *poolDispatcher
 	logger     *zap.Logger
+
+	// profiler records the profile of each node, it is nil if the execution is not profiled.
+	profiler *Profiler
+	// spiller spills the rows buffered by transformations to disk, it is nil if spilling is disabled.
+	spiller *Spiller
 }
 
 func (e *executor) Execute(ctx context.Context, orgID platform.ID, p *plan.PlanSpec, a *Allocator) (map[string]query.Result, error) {
@@ -100,6 +105,14 @@ func (e *executor) createExecutionState(ctx context.Context, orgID platform.ID,
 		results:   make(map[string]query.Result, len(p.Results)),
 		// TODO(nathanielc): Have the planner specify the dispatcher throughput
 		dispatcher: newPoolDispatcher(10, e.logger),
+		profiler:   ProfilerFromContext(ctx),
+		spiller:    SpillerFromContext(ctx),
+	}
+	if es.profiler != nil {
+		if _, ok := p.Results[query.ProfilerResultName]; ok {
+			return nil, fmt.Errorf("result name %q is reserved for the profiler", query.ProfilerResultName)
+		}
+		es.profiler.start(p, a)
 	}
 	nodes := make(map[plan.ProcedureID]Node, len(p.Procedures))
 	for name, yield := range p.Results {
@@ -108,12 +121,24 @@ func (e *executor) createExecutionState(ctx context.Context, orgID platform.ID,
 			return nil, err
 		}
 		r := newResult(name, yield)
-		ds.AddTransformation(r)
+		ds.AddTransformation(es.consumer(r, DatasetID(yield.ID), nil))
 		es.results[name] = r
 	}
+	if es.profiler != nil {
+		es.results[query.ProfilerResultName] = &profilerResult{p: es.profiler}
+	}
 	return es, nil
 }
 
+// consumer prepares the transformation t to consume the output of the parent node.
+// in is the profile of the node of t.
+func (es *executionState) consumer(t Transformation, parent DatasetID, in *nodeProfile) Transformation {
+	if es.profiler == nil {
+		return t
+	}
+	return es.profiler.consumer(t, parent, in)
+}
+
 // DefaultTriggerSpec defines the triggering that should be used for datasets
 // whose parent transformation is not a windowing transformation.
 var DefaultTriggerSpec = query.AfterWatermarkTriggerSpec{}
@@ -141,6 +166,15 @@ func (es *executionState) createNode(ctx context.Context, pr *plan.Procedure, no
 	ec := executionContext{
 		es:            es,
 		streamContext: streamContext,
+		alloc:         es.alloc,
+	}
+	var profile *nodeProfile
+	if es.profiler != nil {
+		profile = es.profiler.byID[DatasetID(pr.ID)]
+	}
+	if profile != nil {
+		// Allocations are tracked per node.
+		ec.alloc = profile.alloc
 	}
 
 	if len(pr.Parents) > 0 {
@@ -156,6 +190,9 @@ func (es *executionState) createNode(ctx context.Context, pr *plan.Procedure, no
 		if err != nil {
 			return nil, err
 		}
+		if profile != nil {
+			s = &profiledSource{Source: s, profile: profile}
+		}
 		es.sources = append(es.sources, s)
 		nodes[pr.ID] = s
 		return s, nil
@@ -166,19 +203,39 @@ func (es *executionState) createNode(ctx context.Context, pr *plan.Procedure, no
 		return nil, fmt.Errorf("unsupported procedure %v", pr.Spec.Kind())
 	}
 
-	// Create the transformation
-	t, ds, err := createT(DatasetID(pr.ID), AccumulatingMode, pr.Spec, ec)
-	if err != nil {
-		return nil, err
-	}
-	nodes[pr.ID] = ds
-
 	// Setup triggering
 	var ts query.TriggerSpec = DefaultTriggerSpec
 	if t, ok := pr.Spec.(triggeringSpec); ok {
 		ts = t.TriggerSpec()
 	}
-	ds.SetTriggerSpec(ts)
+
+	// Create the transformation, or a copy of it for each partition of its tables when it is parallel.
+	var node Node
+	var transformations []Transformation
+	if pr.Parallelism > 1 {
+		m := newPartitionMerge(DatasetID(pr.ID), pr.Parallelism)
+		transformations = make([]Transformation, pr.Parallelism)
+		for i := range transformations {
+			// Each copy gets its own spec, since transformations may modify the spec they are created from.
+			t, ds, err := createT(DatasetID(pr.ID), AccumulatingMode, pr.Spec.Copy(), ec)
+			if err != nil {
+				return nil, err
+			}
+			ds.SetTriggerSpec(ts)
+			ds.AddTransformation(m.partition(i))
+			transformations[i] = t
+		}
+		node = m
+	} else {
+		t, ds, err := createT(DatasetID(pr.ID), AccumulatingMode, pr.Spec, ec)
+		if err != nil {
+			return nil, err
+		}
+		ds.SetTriggerSpec(ts)
+		transformations = []Transformation{t}
+		node = ds
+	}
+	nodes[pr.ID] = node
 
 	// Recurse creating parents
 	for _, parentID := range pr.Parents {
@@ -186,17 +243,29 @@ func (es *executionState) createNode(ctx context.Context, pr *plan.Procedure, no
 		if err != nil {
 			return nil, err
 		}
-		transport := newConescutiveTransport(es.dispatcher, t)
-		es.transports = append(es.transports, transport)
-		parent.AddTransformation(transport)
+		transports := make([]Transformation, len(transformations))
+		for i, t := range transformations {
+			transport := newConescutiveTransport(es.dispatcher, t)
+			transport.profile = profile
+			es.transports = append(es.transports, transport)
+			transports[i] = transport
+		}
+		consumer := transports[0]
+		if len(transports) > 1 {
+			consumer = newPartitioner(transports)
+		}
+		parent.AddTransformation(es.consumer(consumer, DatasetID(parentID), profile))
 	}
 
-	return ds, nil
+	return node, nil
 }
 
 func (es *executionState) abort(err error) {
 	for _, r := range es.results {
-		r.(*result).abort(err)
+		// The profiler result is produced once the execution has finished regardless of errors.
+		if r, ok := r.(*result); ok {
+			r.abort(err)
+		}
 	}
 }
 
@@ -243,6 +312,9 @@ func (es *executionState) do(ctx context.Context) {
 		if err != nil {
 			es.abort(err)
 		}
+		if es.profiler != nil {
+			es.profiler.finish()
+		}
 	}()
 }
 
@@ -251,6 +323,7 @@ type executionContext struct {
 	es            *executionState
 	parents       []DatasetID
 	streamContext streamContext
+	alloc         *Allocator
 }
 
 // Satisfy the ExecutionContext interface
@@ -271,7 +344,11 @@ func (ec executionContext) StreamContext() StreamContext {
 }
 
 func (ec executionContext) Allocator() *Allocator {
-	return ec.es.alloc
+	return ec.alloc
+}
+
+func (ec executionContext) Spiller() *Spiller {
+	return ec.es.spiller
 }
 
 func (ec executionContext) Parents() []DatasetID {
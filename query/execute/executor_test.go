@@ -526,6 +526,161 @@ func TestExecutor_Execute(t *testing.T) {
 				}},
 			},
 		},
+		{
+			name: "parallel aggregate",
+			plan: &plan.PlanSpec{
+				Now: epoch.Add(5),
+				Resources: query.ResourceManagement{
+					ConcurrencyQuota: 3,
+					MemoryBytesQuota: math.MaxInt64,
+				},
+				Procedures: map[plan.ProcedureID]*plan.Procedure{
+					plan.ProcedureIDFromOperationID("from"): {
+						ID: plan.ProcedureIDFromOperationID("from"),
+						Spec: newTestFromProcedureSource(
+							[]*executetest.Table{
+								&executetest.Table{
+									KeyCols: []string{"_start", "_stop", "t"},
+									ColMeta: []query.ColMeta{
+										{Label: "_start", Type: query.TTime},
+										{Label: "_stop", Type: query.TTime},
+										{Label: "_time", Type: query.TTime},
+										{Label: "t", Type: query.TString},
+										{Label: "_value", Type: query.TFloat},
+									},
+									Data: [][]interface{}{
+										{execute.Time(0), execute.Time(5), execute.Time(0), "a", 1.0},
+										{execute.Time(0), execute.Time(5), execute.Time(1), "a", 2.0},
+									},
+								},
+								&executetest.Table{
+									KeyCols: []string{"_start", "_stop", "t"},
+									ColMeta: []query.ColMeta{
+										{Label: "_start", Type: query.TTime},
+										{Label: "_stop", Type: query.TTime},
+										{Label: "_time", Type: query.TTime},
+										{Label: "t", Type: query.TString},
+										{Label: "_value", Type: query.TFloat},
+									},
+									Data: [][]interface{}{
+										{execute.Time(0), execute.Time(5), execute.Time(0), "b", 3.0},
+										{execute.Time(0), execute.Time(5), execute.Time(1), "b", 4.0},
+										{execute.Time(0), execute.Time(5), execute.Time(2), "b", 5.0},
+									},
+								},
+								&executetest.Table{
+									KeyCols: []string{"_start", "_stop", "t"},
+									ColMeta: []query.ColMeta{
+										{Label: "_start", Type: query.TTime},
+										{Label: "_stop", Type: query.TTime},
+										{Label: "_time", Type: query.TTime},
+										{Label: "t", Type: query.TString},
+										{Label: "_value", Type: query.TFloat},
+									},
+									Data: [][]interface{}{
+										{execute.Time(0), execute.Time(5), execute.Time(0), "c", 6.0},
+									},
+								},
+								&executetest.Table{
+									KeyCols: []string{"_start", "_stop", "t"},
+									ColMeta: []query.ColMeta{
+										{Label: "_start", Type: query.TTime},
+										{Label: "_stop", Type: query.TTime},
+										{Label: "_time", Type: query.TTime},
+										{Label: "t", Type: query.TString},
+										{Label: "_value", Type: query.TFloat},
+									},
+									Data: [][]interface{}{
+										{execute.Time(0), execute.Time(5), execute.Time(0), "d", 7.0},
+										{execute.Time(0), execute.Time(5), execute.Time(1), "d", 8.0},
+									},
+								},
+							},
+						),
+						Bounds: &plan.BoundsSpec{
+							Start: values.ConvertTime(time.Unix(0, 1)),
+							Stop:  values.ConvertTime(time.Unix(0, 5)),
+						},
+						Parents:  nil,
+						Children: []plan.ProcedureID{plan.ProcedureIDFromOperationID("sum")},
+					},
+					plan.ProcedureIDFromOperationID("sum"): {
+						ID: plan.ProcedureIDFromOperationID("sum"),
+						Spec: &functions.SumProcedureSpec{
+							AggregateConfig: execute.DefaultAggregateConfig,
+						},
+						Parents: []plan.ProcedureID{
+							plan.ProcedureIDFromOperationID("from"),
+						},
+						Bounds: &plan.BoundsSpec{
+							Start: values.ConvertTime(time.Unix(0, 1)),
+							Stop:  values.ConvertTime(time.Unix(0, 5)),
+						},
+						Children:    nil,
+						Parallelism: 3,
+					},
+				},
+				Results: map[string]plan.YieldSpec{
+					plan.DefaultYieldName: {ID: plan.ProcedureIDFromOperationID("sum")},
+				},
+			},
+			want: map[string][]*executetest.Table{
+				plan.DefaultYieldName: []*executetest.Table{
+					{
+						KeyCols: []string{"_start", "_stop", "t"},
+						ColMeta: []query.ColMeta{
+							{Label: "_start", Type: query.TTime},
+							{Label: "_stop", Type: query.TTime},
+							{Label: "t", Type: query.TString},
+							{Label: "_time", Type: query.TTime},
+							{Label: "_value", Type: query.TFloat},
+						},
+						Data: [][]interface{}{
+							{execute.Time(0), execute.Time(5), "a", execute.Time(5), 3.0},
+						},
+					},
+					{
+						KeyCols: []string{"_start", "_stop", "t"},
+						ColMeta: []query.ColMeta{
+							{Label: "_start", Type: query.TTime},
+							{Label: "_stop", Type: query.TTime},
+							{Label: "t", Type: query.TString},
+							{Label: "_time", Type: query.TTime},
+							{Label: "_value", Type: query.TFloat},
+						},
+						Data: [][]interface{}{
+							{execute.Time(0), execute.Time(5), "b", execute.Time(5), 12.0},
+						},
+					},
+					{
+						KeyCols: []string{"_start", "_stop", "t"},
+						ColMeta: []query.ColMeta{
+							{Label: "_start", Type: query.TTime},
+							{Label: "_stop", Type: query.TTime},
+							{Label: "t", Type: query.TString},
+							{Label: "_time", Type: query.TTime},
+							{Label: "_value", Type: query.TFloat},
+						},
+						Data: [][]interface{}{
+							{execute.Time(0), execute.Time(5), "c", execute.Time(5), 6.0},
+						},
+					},
+					{
+						KeyCols: []string{"_start", "_stop", "t"},
+						ColMeta: []query.ColMeta{
+							{Label: "_start", Type: query.TTime},
+							{Label: "_stop", Type: query.TTime},
+							{Label: "t", Type: query.TString},
+							{Label: "_time", Type: query.TTime},
+							{Label: "_value", Type: query.TFloat},
+						},
+						Data: [][]interface{}{
+							{execute.Time(0), execute.Time(5), "d", execute.Time(5), 15.0},
+						},
+					},
+				},
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -0,0 +1,75 @@
+package execute
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultLiveInterval is the interval at which the sources of a live query read new data when no write is notified.
+const DefaultLiveInterval = 10 * time.Second
+
+// Live configures the sources of a live query.
+// The sources of a live query do not stop at the time the query started,
+// they keep reading the data written since their last read until the stop of their bounds,
+// advancing their watermark as they go.
+// A live query whose bounds have no stop runs until it is cancelled.
+type Live struct {
+	// Interval is the interval at which sources read new data when no write is notified.
+	Interval time.Duration
+	// Writes notifies the sources that new data has been written, it may be nil.
+	Writes *WriteNotifier
+}
+
+type liveContextKey struct{}
+
+// ContextWithLive returns a context that makes the sources of the execution live.
+func ContextWithLive(ctx context.Context, l *Live) context.Context {
+	return context.WithValue(ctx, liveContextKey{}, l)
+}
+
+// LiveFromContext returns the live configuration of the context, or nil if the execution is not live.
+func LiveFromContext(ctx context.Context) *Live {
+	l, _ := ctx.Value(liveContextKey{}).(*Live)
+	return l
+}
+
+// WriteNotifier notifies the sources of live queries that data has been written.
+type WriteNotifier struct {
+	mu   sync.Mutex
+	subs map[chan struct{}]struct{}
+}
+
+// NewWriteNotifier creates a new WriteNotifier.
+func NewWriteNotifier() *WriteNotifier {
+	return &WriteNotifier{
+		subs: make(map[chan struct{}]struct{}),
+	}
+}
+
+// Notify notifies every subscriber that data has been written.
+// Notifications are coalesced for subscribers that have not received the previous one.
+func (n *WriteNotifier) Notify() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for ch := range n.subs {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel that receives the notifications,
+// and a function that must be called once the subscriber no longer receives them.
+func (n *WriteNotifier) Subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	n.mu.Lock()
+	n.subs[ch] = struct{}{}
+	n.mu.Unlock()
+	return ch, func() {
+		n.mu.Lock()
+		delete(n.subs, ch)
+		n.mu.Unlock()
+	}
+}
@@ -0,0 +1,44 @@
+package execute_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/EMCECS/influx/query/execute"
+)
+
+func TestWriteNotifier(t *testing.T) {
+	n := execute.NewWriteNotifier()
+	a, unsubscribeA := n.Subscribe()
+	b, unsubscribeB := n.Subscribe()
+	defer unsubscribeB()
+
+	// Notifications are coalesced until they are received.
+	n.Notify()
+	n.Notify()
+	for name, ch := range map[string]<-chan struct{}{"a": a, "b": b} {
+		select {
+		case <-ch:
+		case <-time.After(time.Second):
+			t.Fatalf("subscriber %s was not notified", name)
+		}
+		select {
+		case <-ch:
+			t.Fatalf("subscriber %s was notified twice", name)
+		default:
+		}
+	}
+
+	unsubscribeA()
+	n.Notify()
+	select {
+	case <-a:
+		t.Fatal("unsubscribed subscriber was notified")
+	default:
+	}
+	select {
+	case <-b:
+	case <-time.After(time.Second):
+		t.Fatal("subscriber b was not notified")
+	}
+}
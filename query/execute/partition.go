@@ -0,0 +1,323 @@
+package execute
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/EMCECS/influx/query"
+)
+
+// partitioner routes the tables of a stream to the partitions of a parallel transformation by group key,
+// the other messages of the stream are sent to every partition.
+// The tables with the same group key are always routed to the same partition.
+type partitioner struct {
+	partitions []Transformation
+}
+
+func newPartitioner(partitions []Transformation) *partitioner {
+	return &partitioner{
+		partitions: partitions,
+	}
+}
+
+func (p *partitioner) partition(key query.GroupKey) Transformation {
+	return p.partitions[hashGroupKey(key)%uint64(len(p.partitions))]
+}
+
+func (p *partitioner) RetractTable(id DatasetID, key query.GroupKey) error {
+	return p.partition(key).RetractTable(id, key)
+}
+
+func (p *partitioner) Process(id DatasetID, tbl query.Table) error {
+	return p.partition(tbl.Key()).Process(id, tbl)
+}
+
+func (p *partitioner) UpdateWatermark(id DatasetID, t Time) error {
+	for _, pt := range p.partitions {
+		if err := pt.UpdateWatermark(id, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *partitioner) UpdateProcessingTime(id DatasetID, t Time) error {
+	for _, pt := range p.partitions {
+		if err := pt.UpdateProcessingTime(id, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *partitioner) Finish(id DatasetID, err error) {
+	for _, pt := range p.partitions {
+		pt.Finish(id, err)
+	}
+}
+
+// hashGroupKey hashes the values of a group key.
+func hashGroupKey(key query.GroupKey) uint64 {
+	h := fnv.New64a()
+	var buf [binary.MaxVarintLen64]byte
+	for j, c := range key.Cols() {
+		var n int
+		switch c.Type {
+		case query.TBool:
+			if key.ValueBool(j) {
+				buf[0] = 1
+			} else {
+				buf[0] = 0
+			}
+			n = 1
+		case query.TInt:
+			n = binary.PutVarint(buf[:], key.ValueInt(j))
+		case query.TUInt:
+			n = binary.PutUvarint(buf[:], key.ValueUInt(j))
+		case query.TFloat:
+			n = binary.PutUvarint(buf[:], math.Float64bits(key.ValueFloat(j)))
+		case query.TString:
+			h.Write([]byte(key.ValueString(j)))
+		case query.TTime:
+			n = binary.PutVarint(buf[:], int64(key.ValueTime(j)))
+		}
+		h.Write(buf[:n])
+	}
+	return h.Sum64()
+}
+
+// partitionMerge merges the output streams of the partitions of a parallel transformation into a single stream.
+//
+// The partitions receive the same watermarks, processing times and finish from the partitioner,
+// which act as barriers in their output streams.
+// Once every partition has reached a barrier, the tables they produced before it are sent sorted by group key,
+// which is the order in which a single transformation produces its triggered tables, followed by the barrier.
+// Tables are thus never sent after a watermark that should have followed them.
+type partitionMerge struct {
+	id DatasetID
+	ts []Transformation
+
+	mu sync.Mutex
+	// pending holds the output of each partition that has not been sent yet.
+	pending  [][]partitionMessage
+	finished bool
+}
+
+// partitionMessage is a message produced by a partition.
+// It holds a table or a retraction, or it is a barrier.
+type partitionMessage struct {
+	barrier Message
+
+	table   query.Table
+	retract query.GroupKey
+}
+
+func (m partitionMessage) key() query.GroupKey {
+	if m.table != nil {
+		return m.table.Key()
+	}
+	return m.retract
+}
+
+func newPartitionMerge(id DatasetID, n int) *partitionMerge {
+	return &partitionMerge{
+		id:      id,
+		pending: make([][]partitionMessage, n),
+	}
+}
+
+func (m *partitionMerge) AddTransformation(t Transformation) {
+	m.ts = append(m.ts, t)
+}
+
+// partition returns the transformation that receives the output of the partition i.
+func (m *partitionMerge) partition(i int) Transformation {
+	return &partitionOutput{m: m, i: i}
+}
+
+func (m *partitionMerge) push(i int, msg partitionMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.finished {
+		return nil
+	}
+	m.pending[i] = append(m.pending[i], msg)
+	if msg.barrier == nil {
+		return nil
+	}
+	return m.flush()
+}
+
+// flush sends the output of the partitions up to each barrier all partitions have reached.
+func (m *partitionMerge) flush() error {
+	for !m.finished {
+		ends := make([]int, len(m.pending))
+		finished := true
+		for i, msgs := range m.pending {
+			ends[i] = -1
+			for j, msg := range msgs {
+				if msg.barrier != nil {
+					ends[i] = j
+					break
+				}
+			}
+			if ends[i] < 0 {
+				return nil
+			}
+			if _, ok := msgs[ends[i]].barrier.(FinishMsg); !ok {
+				finished = false
+			}
+		}
+
+		// The output up to the barrier is removed from the pending output before it is sent,
+		// so that it is not released again should sending it fail.
+		var msgs []partitionMessage
+		var barrier Message
+		for i, end := range ends {
+			msgs = append(msgs, m.pending[i][:end]...)
+			b := m.pending[i][end].barrier
+			if _, ok := b.(FinishMsg); ok && !finished {
+				// A finished partition has passed every barrier of the other partitions.
+				m.pending[i] = m.pending[i][end:]
+				continue
+			}
+			m.pending[i] = m.pending[i][end+1:]
+			barrier = earliestBarrier(barrier, b)
+		}
+		sort.SliceStable(msgs, func(i, j int) bool {
+			return msgs[i].key().Less(msgs[j].key())
+		})
+		for _, msg := range msgs {
+			if err := m.send(msg); err != nil {
+				return err
+			}
+		}
+		if finished {
+			m.finish(nil)
+			return nil
+		}
+		if err := m.send(partitionMessage{barrier: barrier}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// earliestBarrier returns the barrier with the earliest time.
+// The partitions reach the same barriers, their times only differ if their transformation changes them.
+func earliestBarrier(a, b Message) Message {
+	switch a := a.(type) {
+	case nil:
+		return b
+	case UpdateWatermarkMsg:
+		if b, ok := b.(UpdateWatermarkMsg); ok && b.WatermarkTime() < a.WatermarkTime() {
+			return b
+		}
+	case UpdateProcessingTimeMsg:
+		if b, ok := b.(UpdateProcessingTimeMsg); ok && b.ProcessingTime() < a.ProcessingTime() {
+			return b
+		}
+	}
+	return a
+}
+
+func (m *partitionMerge) send(msg partitionMessage) error {
+	switch b := msg.barrier.(type) {
+	case nil:
+	case UpdateWatermarkMsg:
+		for _, t := range m.ts {
+			if err := t.UpdateWatermark(m.id, b.WatermarkTime()); err != nil {
+				return err
+			}
+		}
+		return nil
+	case UpdateProcessingTimeMsg:
+		for _, t := range m.ts {
+			if err := t.UpdateProcessingTime(m.id, b.ProcessingTime()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if msg.table == nil {
+		for _, t := range m.ts {
+			if err := t.RetractTable(m.id, msg.retract); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	// The reference of the partition is handed over to the transformations.
+	msg.table.RefCount(len(m.ts) - 1)
+	for _, t := range m.ts {
+		if err := t.Process(m.id, msg.table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// finish finishes the transformations, the pending output of the partitions is discarded.
+func (m *partitionMerge) finish(err error) {
+	m.finished = true
+	for i, msgs := range m.pending {
+		for _, msg := range msgs {
+			if msg.table != nil {
+				msg.table.RefCount(-1)
+			}
+		}
+		m.pending[i] = nil
+	}
+	for _, t := range m.ts {
+		t.Finish(m.id, err)
+	}
+}
+
+// partitionOutput is the transformation receiving the output of a partition of a parallel transformation.
+type partitionOutput struct {
+	m *partitionMerge
+	i int
+}
+
+func (o *partitionOutput) RetractTable(id DatasetID, key query.GroupKey) error {
+	return o.m.push(o.i, partitionMessage{retract: key})
+}
+
+func (o *partitionOutput) Process(id DatasetID, tbl query.Table) error {
+	return o.m.push(o.i, partitionMessage{table: tbl})
+}
+
+func (o *partitionOutput) UpdateWatermark(id DatasetID, t Time) error {
+	return o.m.push(o.i, partitionMessage{barrier: &updateWatermarkMsg{
+		srcMessage: srcMessage(id),
+		time:       t,
+	}})
+}
+
+func (o *partitionOutput) UpdateProcessingTime(id DatasetID, t Time) error {
+	return o.m.push(o.i, partitionMessage{barrier: &updateProcessingTimeMsg{
+		srcMessage: srcMessage(id),
+		time:       t,
+	}})
+}
+
+func (o *partitionOutput) Finish(id DatasetID, err error) {
+	if err == nil {
+		err = o.m.push(o.i, partitionMessage{barrier: &finishMsg{
+			srcMessage: srcMessage(id),
+		}})
+		if err == nil {
+			return
+		}
+	}
+	// An error finishes the stream right away.
+	o.m.mu.Lock()
+	defer o.m.mu.Unlock()
+	if !o.m.finished {
+		o.m.finish(err)
+	}
+}
@@ -0,0 +1,239 @@
+package execute
+
+import (
+	"context"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/plan"
+)
+
+// Profiler records the profile of each node of an executed plan.
+// A profiler is used for a single execution, see ContextWithProfiler.
+type Profiler struct {
+	names map[plan.ProcedureID]string
+
+	nodes []*nodeProfile
+	byID  map[DatasetID]*nodeProfile
+
+	finished chan struct{}
+}
+
+// NewProfiler creates a profiler.
+// The names identify the procedures in the profiles, procedures without a name are identified by their ID.
+func NewProfiler(names map[plan.ProcedureID]string) *Profiler {
+	return &Profiler{
+		names:    names,
+		finished: make(chan struct{}),
+	}
+}
+
+type profilerContextKey struct{}
+
+// ContextWithProfiler returns a context that makes the executor profile the execution with p.
+func ContextWithProfiler(ctx context.Context, p *Profiler) context.Context {
+	return context.WithValue(ctx, profilerContextKey{}, p)
+}
+
+// ProfilerFromContext returns the profiler of the context, or nil if the execution is not profiled.
+func ProfilerFromContext(ctx context.Context) *Profiler {
+	p, _ := ctx.Value(profilerContextKey{}).(*Profiler)
+	return p
+}
+
+// nodeProfile holds the counters of a single node.
+// The counters are updated atomically as the node may be processed concurrently.
+type nodeProfile struct {
+	id   string
+	kind plan.ProcedureKind
+
+	alloc *Allocator
+
+	tablesIn  int64
+	rowsIn    int64
+	tablesOut int64
+	rowsOut   int64
+	process   int64
+	wait      int64
+
+	// consumed reports whether a consumer counts the output of the node.
+	consumed bool
+}
+
+func (p *nodeProfile) addProcess(d time.Duration) {
+	atomic.AddInt64(&p.process, int64(d))
+}
+
+func (p *nodeProfile) addWait(d time.Duration) {
+	atomic.AddInt64(&p.wait, int64(d))
+}
+
+// start creates a profile for each procedure of the plan.
+func (p *Profiler) start(pl *plan.PlanSpec, alloc *Allocator) {
+	p.nodes = make([]*nodeProfile, 0, len(pl.Order))
+	p.byID = make(map[DatasetID]*nodeProfile, len(pl.Order))
+	for _, id := range pl.Order {
+		pr, ok := pl.Procedures[id]
+		if !ok {
+			continue
+		}
+		name, ok := p.names[id]
+		if !ok {
+			name = id.String()
+		}
+		n := &nodeProfile{
+			id:    name,
+			kind:  pr.Spec.Kind(),
+			alloc: newChildAllocator(alloc),
+		}
+		p.nodes = append(p.nodes, n)
+		p.byID[DatasetID(id)] = n
+	}
+}
+
+// finish marks the execution as finished.
+func (p *Profiler) finish() {
+	close(p.finished)
+}
+
+// consumer wraps a transformation that consumes the output of the parent node.
+// The transformation counts its input for the given profile, and the output of the parent
+// if it is the first consumer of the parent.
+func (p *Profiler) consumer(t Transformation, parent DatasetID, in *nodeProfile) Transformation {
+	pt := &profiledTransformation{
+		Transformation: t,
+		in:             in,
+	}
+	if out := p.byID[parent]; out != nil && !out.consumed {
+		out.consumed = true
+		pt.out = out
+	}
+	return pt
+}
+
+// Profiles reports the profile of each node in the order of the plan.
+// The profiles are not complete until the execution has finished and its results have been read.
+func (p *Profiler) Profiles() []query.OperatorProfile {
+	profiles := make([]query.OperatorProfile, len(p.nodes))
+	for i, n := range p.nodes {
+		profiles[i] = query.OperatorProfile{
+			ID:              n.id,
+			Kind:            string(n.kind),
+			TablesIn:        atomic.LoadInt64(&n.tablesIn),
+			RowsIn:          atomic.LoadInt64(&n.rowsIn),
+			TablesOut:       atomic.LoadInt64(&n.tablesOut),
+			RowsOut:         atomic.LoadInt64(&n.rowsOut),
+			ProcessDuration: time.Duration(atomic.LoadInt64(&n.process)),
+			WaitDuration:    time.Duration(atomic.LoadInt64(&n.wait)),
+			MaxAllocated:    n.alloc.Max(),
+		}
+	}
+	return profiles
+}
+
+// profiledTransformation counts the tables and rows passed to a transformation.
+type profiledTransformation struct {
+	Transformation
+	in, out *nodeProfile
+}
+
+func (t *profiledTransformation) Process(id DatasetID, tbl query.Table) error {
+	if t.in != nil {
+		atomic.AddInt64(&t.in.tablesIn, 1)
+	}
+	if t.out != nil {
+		atomic.AddInt64(&t.out.tablesOut, 1)
+	}
+	return t.Transformation.Process(id, &profiledTable{
+		Table: tbl,
+		in:    t.in,
+		out:   t.out,
+	})
+}
+
+// profiledTable counts the rows of a table as they are read.
+type profiledTable struct {
+	query.Table
+	in, out *nodeProfile
+}
+
+func (t *profiledTable) Do(f func(query.ColReader) error) error {
+	return t.Table.Do(func(cr query.ColReader) error {
+		n := int64(cr.Len())
+		if t.in != nil {
+			atomic.AddInt64(&t.in.rowsIn, n)
+		}
+		if t.out != nil {
+			atomic.AddInt64(&t.out.rowsOut, n)
+		}
+		return f(cr)
+	})
+}
+
+// profilerResult is the result containing the profiles.
+// Its table is produced once the execution has finished.
+// The rows out of the nodes producing the other results are counted as those results are read,
+// so the profiler result is read last, see query.NewMapResultIterator.
+type profilerResult struct {
+	p *Profiler
+}
+
+var profilerCols = []query.ColMeta{
+	{Label: "id", Type: query.TString},
+	{Label: "kind", Type: query.TString},
+	{Label: "tables_in", Type: query.TInt},
+	{Label: "rows_in", Type: query.TInt},
+	{Label: "tables_out", Type: query.TInt},
+	{Label: "rows_out", Type: query.TInt},
+	{Label: "process_duration", Type: query.TInt},
+	{Label: "wait_duration", Type: query.TInt},
+	{Label: "max_allocated", Type: query.TInt},
+}
+
+func (r *profilerResult) Name() string {
+	return query.ProfilerResultName
+}
+
+func (r *profilerResult) Tables() query.TableIterator {
+	return r
+}
+
+func (r *profilerResult) Do(f func(query.Table) error) error {
+	<-r.p.finished
+
+	// The profile is small, it is not accounted for in the memory of the query.
+	b := NewColListTableBuilder(NewGroupKey(nil, nil), &Allocator{Limit: math.MaxInt64})
+	for _, c := range profilerCols {
+		b.AddCol(c)
+	}
+	for _, p := range r.p.Profiles() {
+		b.AppendString(0, p.ID)
+		b.AppendString(1, p.Kind)
+		b.AppendInt(2, p.TablesIn)
+		b.AppendInt(3, p.RowsIn)
+		b.AppendInt(4, p.TablesOut)
+		b.AppendInt(5, p.RowsOut)
+		b.AppendInt(6, int64(p.ProcessDuration))
+		b.AppendInt(7, int64(p.WaitDuration))
+		b.AppendInt(8, p.MaxAllocated)
+	}
+	tbl, err := b.Table()
+	if err != nil {
+		return err
+	}
+	return f(tbl)
+}
+
+// profiledSource records the time spent running a source.
+type profiledSource struct {
+	Source
+	profile *nodeProfile
+}
+
+func (s *profiledSource) Run(ctx context.Context) {
+	start := time.Now()
+	s.Source.Run(ctx)
+	s.profile.addProcess(time.Since(start))
+}
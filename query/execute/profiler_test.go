@@ -0,0 +1,158 @@
+package execute_test
+
+import (
+	"context"
+	"math"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/execute"
+	"github.com/EMCECS/influx/query/execute/executetest"
+	"github.com/EMCECS/influx/query/functions"
+	"github.com/EMCECS/influx/query/plan"
+	"github.com/EMCECS/influx/query/values"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestExecutor_Profile(t *testing.T) {
+	bounds := &plan.BoundsSpec{
+		Start: values.ConvertTime(time.Unix(0, 1)),
+		Stop:  values.ConvertTime(time.Unix(0, 5)),
+	}
+	from := plan.ProcedureIDFromOperationID("from")
+	sum := plan.ProcedureIDFromOperationID("sum")
+	p := &plan.PlanSpec{
+		Now: epoch.Add(5),
+		Resources: query.ResourceManagement{
+			ConcurrencyQuota: 1,
+			MemoryBytesQuota: math.MaxInt64,
+		},
+		Procedures: map[plan.ProcedureID]*plan.Procedure{
+			from: {
+				ID: from,
+				Spec: newTestFromProcedureSource([]*executetest.Table{
+					{
+						KeyCols: []string{"_start", "_stop", "t"},
+						ColMeta: []query.ColMeta{
+							{Label: "_start", Type: query.TTime},
+							{Label: "_stop", Type: query.TTime},
+							{Label: "_time", Type: query.TTime},
+							{Label: "t", Type: query.TString},
+							{Label: "_value", Type: query.TFloat},
+						},
+						Data: [][]interface{}{
+							{execute.Time(0), execute.Time(5), execute.Time(0), "a", 1.0},
+							{execute.Time(0), execute.Time(5), execute.Time(1), "a", 2.0},
+							{execute.Time(0), execute.Time(5), execute.Time(2), "a", 3.0},
+						},
+					},
+					{
+						KeyCols: []string{"_start", "_stop", "t"},
+						ColMeta: []query.ColMeta{
+							{Label: "_start", Type: query.TTime},
+							{Label: "_stop", Type: query.TTime},
+							{Label: "_time", Type: query.TTime},
+							{Label: "t", Type: query.TString},
+							{Label: "_value", Type: query.TFloat},
+						},
+						Data: [][]interface{}{
+							{execute.Time(0), execute.Time(5), execute.Time(3), "b", 4.0},
+							{execute.Time(0), execute.Time(5), execute.Time(4), "b", 5.0},
+						},
+					},
+				}),
+				Bounds:   bounds,
+				Children: []plan.ProcedureID{sum},
+			},
+			sum: {
+				ID: sum,
+				Spec: &functions.SumProcedureSpec{
+					AggregateConfig: execute.DefaultAggregateConfig,
+				},
+				Parents: []plan.ProcedureID{from},
+				Bounds:  bounds,
+			},
+		},
+		Order: []plan.ProcedureID{from, sum},
+		Results: map[string]plan.YieldSpec{
+			plan.DefaultYieldName: {ID: sum},
+		},
+	}
+
+	profiler := execute.NewProfiler(map[plan.ProcedureID]string{
+		from: "from",
+		sum:  "sum",
+	})
+	ctx := execute.ContextWithProfiler(context.Background(), profiler)
+	exe := execute.NewExecutor(nil, zaptest.NewLogger(t))
+	results, err := exe.Execute(ctx, orgID, p, executetest.UnlimitedAllocator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := results[query.ProfilerResultName]; !ok {
+		t.Fatalf("missing %q result", query.ProfilerResultName)
+	}
+
+	// Read the profiler result last so that the rows of the other results are counted.
+	names := make([]string, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return names[j] == query.ProfilerResultName
+	})
+	var profiles []*executetest.Table
+	for _, name := range names {
+		if err := results[name].Tables().Do(func(tbl query.Table) error {
+			cb, err := executetest.ConvertTable(tbl)
+			if err != nil {
+				return err
+			}
+			if name == query.ProfilerResultName {
+				profiles = append(profiles, cb)
+			}
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []query.OperatorProfile{
+		{
+			ID:        "from",
+			Kind:      "from-test",
+			TablesOut: 2,
+			RowsOut:   5,
+		},
+		{
+			ID:        "sum",
+			Kind:      "sum",
+			TablesIn:  2,
+			RowsIn:    5,
+			TablesOut: 2,
+			RowsOut:   2,
+		},
+	}
+	// The durations and allocations depend on the machine running the test.
+	opts := cmpopts.IgnoreFields(query.OperatorProfile{}, "ProcessDuration", "WaitDuration", "MaxAllocated")
+	got := profiler.Profiles()
+	if !cmp.Equal(want, got, opts) {
+		t.Errorf("unexpected profiles -want/+got\n%s", cmp.Diff(want, got, opts))
+	}
+
+	if len(profiles) != 1 {
+		t.Fatalf("unexpected number of profiler tables, got %d want 1", len(profiles))
+	}
+	for i, row := range profiles[0].Data {
+		if id, kind := row[0], row[1]; id != want[i].ID || kind != want[i].Kind {
+			t.Errorf("unexpected profiler row %d: got %v %v want %v %v", i, id, kind, want[i].ID, want[i].Kind)
+		}
+		if rowsOut := row[5]; rowsOut != want[i].RowsOut {
+			t.Errorf("unexpected rows out for %v: got %v want %d", row[0], rowsOut, want[i].RowsOut)
+		}
+	}
+}
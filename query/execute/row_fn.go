@@ -112,7 +112,14 @@ func ConvertFromKind(k semantic.Kind) query.DataType {
 	}
 }
 
+// eval evaluates the function for the row.
+// The result is null if any of the values referenced by the function is null.
 func (f *rowFn) eval(row int, cr query.ColReader) (values.Value, error) {
+	for _, r := range f.references {
+		if IsNull(row, f.recordCols[r], cr) {
+			return values.Null, nil
+		}
+	}
 	for _, r := range f.references {
 		f.record.Set(r, ValueForRow(row, f.recordCols[r], cr))
 	}
@@ -150,6 +157,10 @@ func (f *RowPredicateFn) Eval(row int, cr query.ColReader) (bool, error) {
 	if err != nil {
 		return false, err
 	}
+	if v.Type() == semantic.Nil {
+		// A null predicate does not hold.
+		return false, nil
+	}
 	return v.Bool(), nil
 }
 
@@ -158,6 +169,7 @@ type RowMapFn struct {
 
 	isWrap  bool
 	wrapObj *Record
+	nullObj *Record
 }
 
 func NewRowMapFn(fn *semantic.FunctionExpression) (*RowMapFn, error) {
@@ -182,6 +194,10 @@ func (f *RowMapFn) Prepare(cols []query.ColMeta) error {
 			DefaultValueColLabel: f.preparedFn.Type(),
 		}))
 	}
+	f.nullObj = NewRecord(f.Type())
+	for k := range f.Type().Properties() {
+		f.nullObj.Set(k, values.Null)
+	}
 	return nil
 }
 
@@ -197,6 +213,10 @@ func (f *RowMapFn) Eval(row int, cr query.ColReader) (values.Object, error) {
 	if err != nil {
 		return nil, err
 	}
+	if v.Type() == semantic.Nil {
+		// Every property of a null result is null.
+		return f.nullObj, nil
+	}
 	if f.isWrap {
 		f.wrapObj.Set(DefaultValueColLabel, v)
 		return f.wrapObj, nil
@@ -204,7 +224,11 @@ func (f *RowMapFn) Eval(row int, cr query.ColReader) (values.Object, error) {
 	return v.Object(), nil
 }
 
+// ValueForRow returns the value of row i of column j, null values are values.Null.
 func ValueForRow(i, j int, cr query.ColReader) values.Value {
+	if IsNull(i, j, cr) {
+		return values.Null
+	}
 	t := cr.Cols()[j].Type
 	switch t {
 	case query.TString:
@@ -227,6 +251,8 @@ func ValueForRow(i, j int, cr query.ColReader) values.Value {
 
 func AppendValue(builder TableBuilder, j int, v values.Value) {
 	switch k := v.Type().Kind(); k {
+	case semantic.Nil:
+		builder.AppendNil(j)
 	case semantic.Bool:
 		builder.AppendBool(j, v.Bool())
 	case semantic.Int:
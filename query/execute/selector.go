@@ -18,6 +18,17 @@ type SelectorConfig struct {
 	Column string `json:"column"`
 }
 
+// Parallel reports that selectors can process their tables in parallel,
+// the rows of each table are selected into a table with the same group key.
+func (c SelectorConfig) Parallel() bool {
+	return true
+}
+
+// PreservesKey reports that selectors select the rows of each table into a table with the same group key.
+func (c SelectorConfig) PreservesKey() bool {
+	return true
+}
+
 func (c *SelectorConfig) ReadArgs(args query.Arguments) error {
 	if col, ok, err := args.GetString("column"); err != nil {
 		return err
@@ -135,6 +146,8 @@ func (t *indexSelectorTransformation) Process(id DatasetID, tbl query.Table) err
 	}
 
 	return tbl.Do(func(cr query.ColReader) error {
+		// Null values are never selected.
+		cr = SkipNulls(cr, valueIdx)
 		switch valueCol.Type {
 		case query.TBool:
 			selected := s.(DoBoolIndexSelector).DoBool(cr.Bools(valueIdx))
@@ -190,6 +203,8 @@ func (t *rowSelectorTransformation) Process(id DatasetID, tbl query.Table) error
 	}
 
 	tbl.Do(func(cr query.ColReader) error {
+		// Null values are never selected.
+		cr = SkipNulls(cr, valueIdx)
 		switch valueCol.Type {
 		case query.TBool:
 			rower.(DoBoolRowSelector).DoBool(cr.Bools(valueIdx), cr)
@@ -218,6 +233,10 @@ func (t *indexSelectorTransformation) appendSelected(selected []int, builder Tab
 	cols := builder.Cols()
 	for j, c := range cols {
 		for _, i := range selected {
+			if IsNull(i, j, cr) {
+				builder.AppendNil(j)
+				continue
+			}
 			switch c.Type {
 			case query.TBool:
 				builder.AppendBool(j, cr.Bools(j)[i])
@@ -243,6 +262,10 @@ func (t *rowSelectorTransformation) appendRows(builder TableBuilder, rows []Row)
 	for j, c := range cols {
 		for _, row := range rows {
 			v := row.Values[j]
+			if v == nil {
+				builder.AppendNil(j)
+				continue
+			}
 			switch c.Type {
 			case query.TBool:
 				builder.AppendBool(j, v.(bool))
@@ -319,6 +342,7 @@ type DoStringRowSelector interface {
 	DoString(vs []string, cr query.ColReader)
 }
 
+// Row is a row read from a column reader, null values are nil.
 type Row struct {
 	Values []interface{}
 }
@@ -327,6 +351,9 @@ func ReadRow(i int, cr query.ColReader) (row Row) {
 	cols := cr.Cols()
 	row.Values = make([]interface{}, len(cols))
 	for j, c := range cols {
+		if IsNull(i, j, cr) {
+			continue
+		}
 		switch c.Type {
 		case query.TBool:
 			row.Values[j] = cr.Bools(j)[i]
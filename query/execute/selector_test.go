@@ -174,6 +174,39 @@ func TestRowSelector_Process(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "single with nulls",
+			config: execute.SelectorConfig{
+				Column: "_value",
+			},
+			data: []*executetest.Table{{
+				KeyCols: []string{"_start", "_stop"},
+				ColMeta: []query.ColMeta{
+					{Label: "_start", Type: query.TTime},
+					{Label: "_stop", Type: query.TTime},
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TFloat},
+				},
+				Data: [][]interface{}{
+					{execute.Time(0), execute.Time(100), execute.Time(1), nil},
+					{execute.Time(0), execute.Time(100), execute.Time(10), 1.0},
+					{execute.Time(0), execute.Time(100), execute.Time(20), nil},
+					{execute.Time(0), execute.Time(100), execute.Time(30), 3.0},
+				},
+			}},
+			want: []*executetest.Table{{
+				KeyCols: []string{"_start", "_stop"},
+				ColMeta: []query.ColMeta{
+					{Label: "_start", Type: query.TTime},
+					{Label: "_stop", Type: query.TTime},
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TFloat},
+				},
+				Data: [][]interface{}{
+					{execute.Time(0), execute.Time(100), execute.Time(10), 1.0},
+				},
+			}},
+		},
 	}
 	for _, tc := range testCases {
 		tc := tc
@@ -332,6 +365,39 @@ func TestIndexSelector_Process(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "single with nulls",
+			config: execute.SelectorConfig{
+				Column: "_value",
+			},
+			data: []*executetest.Table{{
+				KeyCols: []string{"_start", "_stop"},
+				ColMeta: []query.ColMeta{
+					{Label: "_start", Type: query.TTime},
+					{Label: "_stop", Type: query.TTime},
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TFloat},
+				},
+				Data: [][]interface{}{
+					{execute.Time(0), execute.Time(100), execute.Time(1), nil},
+					{execute.Time(0), execute.Time(100), execute.Time(10), 1.0},
+					{execute.Time(0), execute.Time(100), execute.Time(20), nil},
+					{execute.Time(0), execute.Time(100), execute.Time(30), 3.0},
+				},
+			}},
+			want: []*executetest.Table{{
+				KeyCols: []string{"_start", "_stop"},
+				ColMeta: []query.ColMeta{
+					{Label: "_start", Type: query.TTime},
+					{Label: "_stop", Type: query.TTime},
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TFloat},
+				},
+				Data: [][]interface{}{
+					{execute.Time(0), execute.Time(100), execute.Time(10), 1.0},
+				},
+			}},
+		},
 	}
 	for _, tc := range testCases {
 		tc := tc
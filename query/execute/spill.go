@@ -0,0 +1,539 @@
+package execute
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/iocounter"
+)
+
+// DefaultSpillThreshold is the fraction of the memory limit of a query above which its buffered tables are spilled.
+const DefaultSpillThreshold = 0.8
+
+// Spiller spills the tables buffered by the transformations of a query to a temporary file,
+// once the memory allocated by the query nears its limit.
+// The spilled tables are read back from the file one chunk at a time.
+// A Spiller must be closed once the results of the query have been read, which removes its file.
+type Spiller struct {
+	// Dir is the directory of the temporary file, the default directory for temporary files if empty.
+	Dir string
+	// Threshold is the fraction of the memory limit above which tables are spilled, DefaultSpillThreshold if zero.
+	Threshold float64
+	// OnSpill is called with the number of bytes written each time a table is spilled, it may be nil.
+	OnSpill func(n int64)
+
+	mu      sync.Mutex
+	store   *TableStore
+	closed  bool
+	spilled int64
+}
+
+type spillerContextKey struct{}
+
+// ContextWithSpiller returns a context that lets the transformations of the execution spill their tables with s.
+func ContextWithSpiller(ctx context.Context, s *Spiller) context.Context {
+	return context.WithValue(ctx, spillerContextKey{}, s)
+}
+
+// SpillerFromContext returns the spiller of the context, or nil if the tables of the execution are not spilled.
+func SpillerFromContext(ctx context.Context) *Spiller {
+	s, _ := ctx.Value(spillerContextKey{}).(*Spiller)
+	return s
+}
+
+// ShouldSpill reports whether the memory allocated by the query of a nears its limit.
+// A nil Spiller never spills.
+func (s *Spiller) ShouldSpill(a *Allocator) bool {
+	if s == nil {
+		return false
+	}
+	for a.parent != nil {
+		a = a.parent
+	}
+	if a.Limit <= 0 {
+		return false
+	}
+	threshold := s.Threshold
+	if threshold <= 0 {
+		threshold = DefaultSpillThreshold
+	}
+	return float64(atomic.LoadInt64(&a.bytesAllocated)) >= threshold*float64(a.Limit)
+}
+
+// Spill writes the table to the temporary file,
+// and returns a table that reads it back one chunk at a time, allocating the chunks from a.
+func (s *Spiller) Spill(tbl query.Table, a *Allocator) (query.Table, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, errors.New("cannot spill table, the spiller is closed")
+	}
+	if s.store == nil {
+		store, err := NewTableStore(s.Dir)
+		if err != nil {
+			return nil, err
+		}
+		s.store = store
+	}
+	t, n, err := s.store.Write(tbl, a)
+	if err != nil {
+		return nil, err
+	}
+	atomic.AddInt64(&s.spilled, n)
+	if s.OnSpill != nil {
+		s.OnSpill(n)
+	}
+	return t, nil
+}
+
+// BytesSpilled reports the number of bytes spilled.
+func (s *Spiller) BytesSpilled() int64 {
+	return atomic.LoadInt64(&s.spilled)
+}
+
+// Close removes the temporary file, the spilled tables can no longer be read.
+func (s *Spiller) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.store == nil {
+		return nil
+	}
+	err := s.store.Close()
+	s.store = nil
+	return err
+}
+
+// TableStore is a temporary file that tables are written to, so that they can be read back without being held in memory.
+// Writes must not be concurrent, reads may be concurrent with each other and with writes.
+type TableStore struct {
+	f    *os.File
+	size int64
+}
+
+// NewTableStore creates a table store in a new temporary file in dir,
+// or in the default directory for temporary files if dir is empty.
+func NewTableStore(dir string) (*TableStore, error) {
+	f, err := ioutil.TempFile(dir, "influx-spill-")
+	if err != nil {
+		return nil, err
+	}
+	return &TableStore{f: f}, nil
+}
+
+// Write appends the table to the file, and returns a table that reads it back one chunk at a time,
+// allocating the chunks from a, along with the number of bytes written.
+func (s *TableStore) Write(tbl query.Table, a *Allocator) (query.Table, int64, error) {
+	bw := bufio.NewWriter(&offsetWriter{f: s.f, off: s.size})
+	w := &iocounter.Writer{Writer: bw}
+	e := &tableEncoder{w: w}
+	e.header(tbl.Key(), tbl.Cols())
+	headerSize := w.Count()
+	nrows, err := e.rows(tbl)
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err != nil {
+		// The table may be partly written, the next table is written after it.
+		s.size += w.Count()
+		return nil, 0, err
+	}
+	n := w.Count()
+	t := &storedTable{
+		key:   tbl.Key(),
+		cols:  tbl.Cols(),
+		nrows: nrows,
+		r:     io.NewSectionReader(s.f, s.size+headerSize, n-headerSize),
+		alloc: a,
+	}
+	s.size += n
+	return t, n, nil
+}
+
+// Close closes and removes the file.
+func (s *TableStore) Close() error {
+	err := s.f.Close()
+	if rerr := os.Remove(s.f.Name()); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// offsetWriter writes to a file at an offset, regardless of the offset of the file.
+type offsetWriter struct {
+	f   *os.File
+	off int64
+}
+
+func (w *offsetWriter) Write(p []byte) (int, error) {
+	n, err := w.f.WriteAt(p, w.off)
+	w.off += int64(n)
+	return n, err
+}
+
+// storedTable is a table read back from a TableStore one chunk at a time.
+type storedTable struct {
+	key   query.GroupKey
+	cols  []query.ColMeta
+	nrows int
+	// r reads the chunks of the table, which follow its header.
+	r     *io.SectionReader
+	alloc *Allocator
+}
+
+func (t *storedTable) Key() query.GroupKey {
+	return t.key
+}
+
+func (t *storedTable) Cols() []query.ColMeta {
+	return t.cols
+}
+
+func (t *storedTable) Empty() bool {
+	return t.nrows == 0
+}
+
+// RefCount does nothing, the table is removed along with its store.
+func (t *storedTable) RefCount(n int) {}
+
+func (t *storedTable) Do(f func(query.ColReader) error) error {
+	cr := t.chunks()
+	defer cr.close()
+	for {
+		chunk, err := cr.next()
+		if err != nil {
+			return err
+		}
+		if chunk == nil {
+			return nil
+		}
+		if err := f(chunk); err != nil {
+			return err
+		}
+	}
+}
+
+func (t *storedTable) chunks() *chunkReader {
+	return &chunkReader{
+		t: t,
+		d: newTableDecoder(io.NewSectionReader(t.r, 0, t.r.Size())),
+	}
+}
+
+// ChunkIterator reads the chunks of a spilled table one at a time, only the last chunk read is held in memory.
+type ChunkIterator struct {
+	cr *chunkReader
+}
+
+// NewChunkIterator returns an iterator over the chunks of a table returned by Spiller.Spill.
+// It reports false if the table was not spilled.
+func NewChunkIterator(tbl query.Table) (*ChunkIterator, bool) {
+	t, ok := tbl.(*storedTable)
+	if !ok {
+		return nil, false
+	}
+	return &ChunkIterator{cr: t.chunks()}, true
+}
+
+// Next frees the previous chunk, and reads the next one.
+// It returns nil once the table has been read.
+func (it *ChunkIterator) Next() (*ColListTable, error) {
+	return it.cr.next()
+}
+
+// Close frees the last chunk read.
+func (it *ChunkIterator) Close() {
+	it.cr.close()
+}
+
+// chunkReader reads the chunks of a stored table, only the last chunk read is held in memory.
+type chunkReader struct {
+	t     *storedTable
+	d     *tableDecoder
+	chunk *ColListTable
+}
+
+// next frees the previous chunk, and reads the next one.
+// It returns nil once the table has been read.
+func (r *chunkReader) next() (*ColListTable, error) {
+	r.close()
+	chunk, err := r.d.chunk(r.t.key, r.t.cols, r.t.alloc)
+	if err != nil {
+		return nil, err
+	}
+	r.chunk = chunk
+	return chunk, nil
+}
+
+func (r *chunkReader) close() {
+	if r.chunk != nil {
+		r.chunk.free()
+		r.chunk = nil
+	}
+}
+
+// rowCombine is how the rows of the merged runs of a spilled table
+// that have the same values for the columns the rows are sorted by are combined.
+type rowCombine int
+
+const (
+	// combineNone keeps all of the rows.
+	combineNone rowCombine = iota
+	// combineFirst keeps the first of the rows.
+	combineFirst
+	// combineLast combines the rows into a row of the last of their values that are not null,
+	// the values that are null in all of the rows are zero values.
+	combineLast
+)
+
+// spilledTable is a table whose rows were partly spilled.
+// Its spilled runs are read back before its remaining rows,
+// or merged with them when the rows are sorted.
+type spilledTable struct {
+	runs []*storedTable
+	mem  *ColListTable
+
+	// sortCols are the indexes of the columns the rows are sorted by, nil if they are not sorted.
+	sortCols []int
+	desc     bool
+	// combine is how the rows of the runs with the same values for the sort columns are combined.
+	// The rows of each run must have distinct values for them unless the rows are all kept.
+	combine rowCombine
+}
+
+func newSpilledTable(runs []*storedTable, mem *ColListTable, sortCols []string, desc bool, combine rowCombine) (*spilledTable, error) {
+	for _, r := range runs {
+		// The runs of combined rows may lack the columns added after they were spilled.
+		if !EqualCols(r.cols, mem.colMeta) && (combine != combineLast || !EqualCols(r.cols, mem.colMeta[:len(r.cols)])) {
+			return nil, fmt.Errorf("spilled table with key %v has different columns than its remaining rows", mem.key)
+		}
+	}
+	t := &spilledTable{
+		runs:    runs,
+		mem:     mem,
+		desc:    desc,
+		combine: combine,
+	}
+	if sortCols != nil {
+		t.sortCols = make([]int, 0, len(sortCols))
+		for _, label := range sortCols {
+			if j := ColIdx(label, mem.colMeta); j >= 0 {
+				t.sortCols = append(t.sortCols, j)
+			}
+		}
+	}
+	return t, nil
+}
+
+func (t *spilledTable) Key() query.GroupKey {
+	return t.mem.key
+}
+
+func (t *spilledTable) Cols() []query.ColMeta {
+	return t.mem.colMeta
+}
+
+func (t *spilledTable) Empty() bool {
+	for _, r := range t.runs {
+		if !r.Empty() {
+			return false
+		}
+	}
+	return t.mem.Empty()
+}
+
+// RefCount frees the remaining rows once the count goes to zero.
+func (t *spilledTable) RefCount(n int) {
+	t.mem.RefCount(n)
+}
+
+func (t *spilledTable) Do(f func(query.ColReader) error) error {
+	if t.sortCols != nil {
+		return t.merge(f)
+	}
+	for _, r := range t.runs {
+		if err := r.Do(f); err != nil {
+			return err
+		}
+	}
+	if t.mem.Empty() {
+		return nil
+	}
+	return f(t.mem)
+}
+
+// mergeSource is the current chunk of one of the sorted sources of a merge.
+type mergeSource struct {
+	chunk *ColListTable
+	i     int
+	// cr reads the next chunks, it is nil for the remaining rows which are a single chunk.
+	cr *chunkReader
+}
+
+// merge calls f with chunks of rows merged in order from the sorted runs and remaining rows.
+func (t *spilledTable) merge(f func(query.ColReader) error) error {
+	sources := make([]*mergeSource, 0, len(t.runs)+1)
+	defer func() {
+		for _, s := range sources {
+			if s.cr != nil {
+				s.cr.close()
+			}
+		}
+	}()
+	for _, r := range t.runs {
+		cr := r.chunks()
+		chunk, err := cr.next()
+		if err != nil {
+			cr.close()
+			return err
+		}
+		if chunk != nil {
+			sources = append(sources, &mergeSource{chunk: chunk, cr: cr})
+		}
+	}
+	if !t.mem.Empty() {
+		sources = append(sources, &mergeSource{chunk: t.mem})
+	}
+
+	builder := NewColListTableBuilder(t.mem.key, t.mem.alloc)
+	for _, c := range t.mem.colMeta {
+		builder.AddCol(c)
+	}
+	defer builder.ClearData()
+	// same holds the indexes of the sources whose current rows are combined, in the order of the sources.
+	same := make([]int, 0, len(sources))
+	for len(sources) > 0 {
+		m := 0
+		for k := 1; k < len(sources); k++ {
+			if lessRows(sources[k].chunk, sources[k].i, sources[m].chunk, sources[m].i, t.sortCols, t.desc) {
+				m = k
+			}
+		}
+		same = append(same[:0], m)
+		if t.combine != combineNone {
+			same = same[:0]
+			for k, s := range sources {
+				// No row sorts before the row of m.
+				if k == m || !lessRows(sources[m].chunk, sources[m].i, s.chunk, s.i, t.sortCols, t.desc) {
+					same = append(same, k)
+				}
+			}
+		}
+
+		s := sources[same[0]]
+		AppendRecordForCols(s.i, s.chunk, builder, s.chunk.colMeta)
+		for j := len(s.chunk.colMeta); j < len(builder.Cols()); j++ {
+			builder.AppendNil(j)
+		}
+		if t.combine == combineLast {
+			n := builder.NRows() - 1
+			for _, k := range same[1:] {
+				o := sources[k]
+				for j := range o.chunk.colMeta {
+					if !IsNull(o.i, j, o.chunk) {
+						builder.SetValue(n, j, ValueForRow(o.i, j, o.chunk))
+					}
+				}
+			}
+		}
+
+		// The sources are advanced from the last, so that the indexes of the others remain valid.
+		for k := len(same) - 1; k >= 0; k-- {
+			m := same[k]
+			s := sources[m]
+			s.i++
+			if s.i < s.chunk.Len() {
+				continue
+			}
+			var next *ColListTable
+			if s.cr != nil {
+				chunk, err := s.cr.next()
+				if err != nil {
+					return err
+				}
+				next = chunk
+			}
+			if next == nil {
+				sources = append(sources[:m], sources[m+1:]...)
+			} else {
+				s.chunk, s.i = next, 0
+			}
+		}
+		if builder.NRows() == tableChunkSize || len(sources) == 0 {
+			if t.combine == combineLast {
+				// The values that are null in all of the rows are zero values.
+				builder.RawTable().clearNulls()
+			}
+			if err := f(builder.RawTable()); err != nil {
+				return err
+			}
+			builder.ClearData()
+		}
+	}
+	return nil
+}
+
+// lessRows reports whether row x of a sorts before row y of b by the columns cols,
+// in the order that the rows of a table builder are sorted.
+func lessRows(a *ColListTable, x int, b *ColListTable, y int, cols []int, desc bool) bool {
+	for _, j := range cols {
+		// Nulls are sorted after all other values.
+		xNull := a.nulls[j] != nil && a.nulls[j][x]
+		yNull := b.nulls[j] != nil && b.nulls[j][y]
+		if xNull != yNull {
+			return yNull != desc
+		}
+		if xNull {
+			continue
+		}
+		if c := compareValues(a.cols[j], x, b.cols[j], y); c != 0 {
+			return (c < 0) != desc
+		}
+	}
+	return false
+}
+
+// compareValues compares value x of column a with value y of column b, which have the same type.
+func compareValues(a column, x int, b column, y int) int {
+	switch a := a.(type) {
+	case *boolColumn:
+		vx, vy := a.data[x], b.(*boolColumn).data[y]
+		switch {
+		case vx == vy:
+			return 0
+		case vy:
+			return -1
+		}
+		return 1
+	case *intColumn:
+		return compareOrdered(a.data[x] < b.(*intColumn).data[y], a.data[x] == b.(*intColumn).data[y])
+	case *uintColumn:
+		return compareOrdered(a.data[x] < b.(*uintColumn).data[y], a.data[x] == b.(*uintColumn).data[y])
+	case *floatColumn:
+		return compareOrdered(a.data[x] < b.(*floatColumn).data[y], a.data[x] == b.(*floatColumn).data[y])
+	case *stringColumn:
+		return compareOrdered(a.data[x] < b.(*stringColumn).data[y], a.data[x] == b.(*stringColumn).data[y])
+	case *timeColumn:
+		return compareOrdered(a.data[x] < b.(*timeColumn).data[y], a.data[x] == b.(*timeColumn).data[y])
+	default:
+		panic(fmt.Errorf("unexpected column type %T", a))
+	}
+}
+
+func compareOrdered(less, equal bool) int {
+	switch {
+	case equal:
+		return 0
+	case less:
+		return -1
+	}
+	return 1
+}
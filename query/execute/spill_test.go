@@ -0,0 +1,358 @@
+package execute_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/execute"
+	"github.com/EMCECS/influx/query/execute/executetest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTableStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := execute.NewTableStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*executetest.Table{
+		{
+			KeyCols: []string{"t"},
+			ColMeta: []query.ColMeta{
+				{Label: "_time", Type: query.TTime},
+				{Label: "t", Type: query.TString},
+				{Label: "_value", Type: query.TFloat},
+			},
+			Data: [][]interface{}{
+				{execute.Time(1), "a", 1.0},
+				{execute.Time(2), "a", nil},
+			},
+		},
+		{
+			KeyCols: []string{"t"},
+			ColMeta: []query.ColMeta{
+				{Label: "_time", Type: query.TTime},
+				{Label: "t", Type: query.TString},
+				{Label: "_value", Type: query.TFloat},
+			},
+			Data: [][]interface{}{
+				{execute.Time(3), "b", 3.0},
+			},
+		},
+	}
+	stored := make([]query.Table, len(want))
+	for i, tbl := range want {
+		tbl.Normalize()
+		st, n, err := store.Write(tbl, executetest.UnlimitedAllocator)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n <= 0 {
+			t.Errorf("unexpected number of bytes written: %d", n)
+		}
+		stored[i] = st
+	}
+
+	// The stored tables can be read any number of times, in any order.
+	for _, i := range []int{1, 0, 0} {
+		got, err := executetest.ConvertTable(stored[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		got.Normalize()
+		if !cmp.Equal(want[i], got) {
+			t.Errorf("unexpected table %d -want/+got\n%s", i, cmp.Diff(want[i], got))
+		}
+	}
+
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if files, err := ioutil.ReadDir(dir); err != nil {
+		t.Fatal(err)
+	} else if len(files) != 0 {
+		t.Errorf("expected the file of the store to be removed, found %d files", len(files))
+	}
+}
+
+func TestTableBuilderCache_Spill(t *testing.T) {
+	cols := []query.ColMeta{
+		{Label: "_time", Type: query.TTime},
+		{Label: "t", Type: query.TString},
+		{Label: "_value", Type: query.TInt},
+	}
+	// The rows are appended to the cache in chunks, and spilled after each chunk.
+	chunks := [][][]interface{}{
+		{
+			{execute.Time(1), "a", int64(5)},
+			{execute.Time(2), "a", nil},
+			{execute.Time(3), "a", int64(1)},
+		},
+		{
+			{execute.Time(4), "a", int64(4)},
+			{execute.Time(5), "a", int64(2)},
+		},
+		{
+			{execute.Time(6), "a", int64(3)},
+			{execute.Time(7), "a", int64(1)},
+		},
+	}
+	testCases := []struct {
+		name     string
+		sortCols []string
+		desc     bool
+		want     [][]interface{}
+	}{
+		{
+			name: "unsorted",
+			want: [][]interface{}{
+				{execute.Time(1), "a", int64(5)},
+				{execute.Time(2), "a", nil},
+				{execute.Time(3), "a", int64(1)},
+				{execute.Time(4), "a", int64(4)},
+				{execute.Time(5), "a", int64(2)},
+				{execute.Time(6), "a", int64(3)},
+				{execute.Time(7), "a", int64(1)},
+				{execute.Time(8), "a", int64(0)},
+			},
+		},
+		{
+			name:     "sorted",
+			sortCols: []string{"_value"},
+			want: [][]interface{}{
+				{execute.Time(8), "a", int64(0)},
+				{execute.Time(3), "a", int64(1)},
+				{execute.Time(7), "a", int64(1)},
+				{execute.Time(5), "a", int64(2)},
+				{execute.Time(6), "a", int64(3)},
+				{execute.Time(4), "a", int64(4)},
+				{execute.Time(1), "a", int64(5)},
+				{execute.Time(2), "a", nil},
+			},
+		},
+		{
+			name:     "sorted desc",
+			sortCols: []string{"_value", "_time"},
+			desc:     true,
+			want: [][]interface{}{
+				{execute.Time(2), "a", nil},
+				{execute.Time(1), "a", int64(5)},
+				{execute.Time(4), "a", int64(4)},
+				{execute.Time(6), "a", int64(3)},
+				{execute.Time(5), "a", int64(2)},
+				{execute.Time(7), "a", int64(1)},
+				{execute.Time(3), "a", int64(1)},
+				{execute.Time(8), "a", int64(0)},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "spill")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			spiller := &execute.Spiller{
+				Dir: dir,
+				// Spill as soon as any memory is allocated.
+				Threshold: 1e-12,
+			}
+			defer spiller.Close()
+
+			cache := execute.NewTableBuilderCache(&execute.Allocator{Limit: 1 << 30})
+			cache.SetTriggerSpec(execute.DefaultTriggerSpec)
+			cache.SetSpiller(spiller)
+			if tc.sortCols != nil {
+				cache.SetSortOrder(tc.sortCols, tc.desc)
+			}
+
+			var key query.GroupKey
+			for _, data := range chunks {
+				tbl := &executetest.Table{
+					KeyCols: []string{"t"},
+					ColMeta: cols,
+					Data:    data,
+				}
+				tbl.Normalize()
+				key = tbl.Key()
+				builder, created := cache.TableBuilder(key)
+				if created {
+					execute.AddTableCols(tbl, builder)
+				}
+				execute.AppendTable(tbl, builder)
+				if err := execute.SpillIfNeeded(cache); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if spiller.BytesSpilled() == 0 {
+				t.Fatal("expected tables to be spilled")
+			}
+
+			// The rows left in memory are read after the spilled rows, or merged with them when sorted.
+			builder, _ := cache.TableBuilder(key)
+			builder.AppendTime(0, execute.Time(8))
+			builder.AppendString(1, "a")
+			builder.AppendInt(2, 0)
+			if tc.sortCols != nil {
+				builder.Sort(tc.sortCols, tc.desc)
+			}
+			want := &executetest.Table{
+				KeyCols: []string{"t"},
+				ColMeta: cols,
+				Data:    tc.want,
+			}
+			want.Normalize()
+
+			tbl, err := cache.Table(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := executetest.ConvertTable(tbl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got.Normalize()
+			if !cmp.Equal(want, got) {
+				t.Errorf("unexpected table -want/+got\n%s", cmp.Diff(want, got))
+			}
+		})
+	}
+}
+
+func TestTableBuilderCache_SpillCombined(t *testing.T) {
+	// Each chunk is appended to the builder, after adding its missing columns, and spilled.
+	type chunk struct {
+		cols []query.ColMeta
+		data [][]interface{}
+	}
+	testCases := []struct {
+		name     string
+		combined bool
+		chunks   []chunk
+		// mem are the rows left in memory.
+		mem  [][]interface{}
+		cols []query.ColMeta
+		want [][]interface{}
+	}{
+		{
+			name: "distinct",
+			chunks: []chunk{
+				{
+					cols: []query.ColMeta{{Label: "t", Type: query.TString}, {Label: "_value", Type: query.TInt}},
+					data: [][]interface{}{{"a", int64(3)}, {"a", int64(1)}, {"a", int64(2)}},
+				},
+				{
+					cols: []query.ColMeta{{Label: "t", Type: query.TString}, {Label: "_value", Type: query.TInt}},
+					data: [][]interface{}{{"a", int64(4)}, {"a", int64(2)}},
+				},
+			},
+			mem:  [][]interface{}{{"a", int64(5)}, {"a", int64(1)}},
+			cols: []query.ColMeta{{Label: "t", Type: query.TString}, {Label: "_value", Type: query.TInt}},
+			want: [][]interface{}{{"a", int64(1)}, {"a", int64(2)}, {"a", int64(3)}, {"a", int64(4)}, {"a", int64(5)}},
+		},
+		{
+			name:     "combined",
+			combined: true,
+			chunks: []chunk{
+				{
+					cols: []query.ColMeta{{Label: "_time", Type: query.TTime}, {Label: "t", Type: query.TString}, {Label: "x", Type: query.TFloat}},
+					data: [][]interface{}{{execute.Time(2), "a", 1.0}, {execute.Time(1), "a", 2.0}},
+				},
+				{
+					// The column y is added after the first rows are spilled.
+					cols: []query.ColMeta{{Label: "_time", Type: query.TTime}, {Label: "t", Type: query.TString}, {Label: "x", Type: query.TFloat}, {Label: "y", Type: query.TFloat}},
+					data: [][]interface{}{{execute.Time(1), "a", nil, 10.0}, {execute.Time(3), "a", 3.0, nil}},
+				},
+			},
+			mem:  [][]interface{}{{execute.Time(2), "a", nil, 20.0}},
+			cols: []query.ColMeta{{Label: "_time", Type: query.TTime}, {Label: "t", Type: query.TString}, {Label: "x", Type: query.TFloat}, {Label: "y", Type: query.TFloat}},
+			want: [][]interface{}{
+				{execute.Time(1), "a", 2.0, 10.0},
+				{execute.Time(2), "a", 1.0, 20.0},
+				{execute.Time(3), "a", 3.0, 0.0},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "spill")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			spiller := &execute.Spiller{
+				Dir:       dir,
+				Threshold: 1e-12,
+			}
+			defer spiller.Close()
+
+			cache := execute.NewTableBuilderCache(&execute.Allocator{Limit: 1 << 30})
+			cache.SetTriggerSpec(execute.DefaultTriggerSpec)
+			cache.SetSpiller(spiller)
+			sortCol := tc.cols[0].Label
+			if tc.combined {
+				cache.SetCombinedRows([]string{sortCol})
+			} else {
+				cache.SetDistinctRows([]string{execute.DefaultValueColLabel})
+			}
+
+			var key query.GroupKey
+			appendChunk := func(c chunk) {
+				tbl := &executetest.Table{
+					KeyCols: []string{"t"},
+					ColMeta: c.cols,
+					Data:    c.data,
+				}
+				tbl.Normalize()
+				key = tbl.Key()
+				builder, _ := cache.TableBuilder(key)
+				for _, col := range c.cols[len(builder.Cols()):] {
+					builder.AddCol(col)
+				}
+				execute.AppendTable(tbl, builder)
+			}
+			for _, c := range tc.chunks {
+				appendChunk(c)
+				if err := execute.SpillIfNeeded(cache); err != nil {
+					t.Fatal(err)
+				}
+			}
+			appendChunk(chunk{cols: tc.cols, data: tc.mem})
+			if spiller.BytesSpilled() == 0 {
+				t.Fatal("expected tables to be spilled")
+			}
+
+			want := &executetest.Table{
+				KeyCols: []string{"t"},
+				ColMeta: tc.cols,
+				Data:    tc.want,
+			}
+			want.Normalize()
+
+			tbl, err := cache.Table(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := executetest.ConvertTable(tbl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got.Normalize()
+			if !cmp.Equal(want, got) {
+				t.Errorf("unexpected table -want/+got\n%s", cmp.Diff(want, got))
+			}
+		})
+	}
+}
@@ -160,6 +160,12 @@ func AppendCols(cr query.ColReader, builder TableBuilder) {
 // AppendCol append a column from cr onto builder
 // The indexes bj and cj are builder and col reader indexes respectively.
 func AppendCol(bj, cj int, cr query.ColReader, builder TableBuilder) {
+	if cr.Nulls(cj) != nil {
+		for i, l := 0, cr.Len(); i < l; i++ {
+			appendValue(i, bj, cj, cr, builder)
+		}
+		return
+	}
 	c := cr.Cols()[cj]
 	switch c.Type {
 	case query.TBool:
@@ -179,9 +185,128 @@ func AppendCol(bj, cj int, cr query.ColReader, builder TableBuilder) {
 	}
 }
 
+// appendValue appends the value of row i of column cj from cr onto column bj of builder.
+func appendValue(i, bj, cj int, cr query.ColReader, builder TableBuilder) {
+	if IsNull(i, cj, cr) {
+		builder.AppendNil(bj)
+		return
+	}
+	c := cr.Cols()[cj]
+	switch c.Type {
+	case query.TBool:
+		builder.AppendBool(bj, cr.Bools(cj)[i])
+	case query.TInt:
+		builder.AppendInt(bj, cr.Ints(cj)[i])
+	case query.TUInt:
+		builder.AppendUInt(bj, cr.UInts(cj)[i])
+	case query.TFloat:
+		builder.AppendFloat(bj, cr.Floats(cj)[i])
+	case query.TString:
+		builder.AppendString(bj, cr.Strings(cj)[i])
+	case query.TTime:
+		builder.AppendTime(bj, cr.Times(cj)[i])
+	default:
+		PanicUnknownType(c.Type)
+	}
+}
+
+// IsNull reports whether the value of row i of column j is null.
+func IsNull(i, j int, cr query.ColReader) bool {
+	nulls := cr.Nulls(j)
+	return nulls != nil && nulls[i]
+}
+
+// SkipNulls returns a column reader of the rows of cr where the value of column j is not null.
+func SkipNulls(cr query.ColReader, j int) query.ColReader {
+	nulls := cr.Nulls(j)
+	if nulls == nil {
+		return cr
+	}
+	rows := make([]int, 0, len(nulls))
+	for i, null := range nulls {
+		if !null {
+			rows = append(rows, i)
+		}
+	}
+	return &rowsColReader{ColReader: cr, rows: rows}
+}
+
+// rowsColReader reads the given rows of a column reader.
+type rowsColReader struct {
+	query.ColReader
+	rows []int
+}
+
+func (r *rowsColReader) Len() int {
+	return len(r.rows)
+}
+func (r *rowsColReader) Bools(j int) []bool {
+	vs := r.ColReader.Bools(j)
+	out := make([]bool, len(r.rows))
+	for k, i := range r.rows {
+		out[k] = vs[i]
+	}
+	return out
+}
+func (r *rowsColReader) Ints(j int) []int64 {
+	vs := r.ColReader.Ints(j)
+	out := make([]int64, len(r.rows))
+	for k, i := range r.rows {
+		out[k] = vs[i]
+	}
+	return out
+}
+func (r *rowsColReader) UInts(j int) []uint64 {
+	vs := r.ColReader.UInts(j)
+	out := make([]uint64, len(r.rows))
+	for k, i := range r.rows {
+		out[k] = vs[i]
+	}
+	return out
+}
+func (r *rowsColReader) Floats(j int) []float64 {
+	vs := r.ColReader.Floats(j)
+	out := make([]float64, len(r.rows))
+	for k, i := range r.rows {
+		out[k] = vs[i]
+	}
+	return out
+}
+func (r *rowsColReader) Strings(j int) []string {
+	vs := r.ColReader.Strings(j)
+	out := make([]string, len(r.rows))
+	for k, i := range r.rows {
+		out[k] = vs[i]
+	}
+	return out
+}
+func (r *rowsColReader) Times(j int) []Time {
+	vs := r.ColReader.Times(j)
+	out := make([]Time, len(r.rows))
+	for k, i := range r.rows {
+		out[k] = vs[i]
+	}
+	return out
+}
+func (r *rowsColReader) Nulls(j int) []bool {
+	vs := r.ColReader.Nulls(j)
+	if vs == nil {
+		return nil
+	}
+	out := make([]bool, len(r.rows))
+	for k, i := range r.rows {
+		out[k] = vs[i]
+	}
+	return out
+}
+
 // AppendRecord appends the record from cr onto builder assuming matching columns.
 func AppendRecord(i int, cr query.ColReader, builder TableBuilder) {
 	for j, c := range builder.Cols() {
+		if IsNull(i, j, cr) {
+			builder.AppendNil(j)
+			continue
+		}
 		switch c.Type {
 		case query.TBool:
 			builder.AppendBool(j, cr.Bools(j)[i])
@@ -204,6 +329,10 @@ func AppendRecord(i int, cr query.ColReader, builder TableBuilder) {
 // AppendMappedRecord appends the records from cr onto builder, using colMap as a map of builder index to cr index.
 func AppendMappedRecord(i int, cr query.ColReader, builder TableBuilder, colMap []int) {
 	for j, c := range builder.Cols() {
+		if IsNull(i, colMap[j], cr) {
+			builder.AppendNil(j)
+			continue
+		}
 		switch c.Type {
 		case query.TBool:
 			builder.AppendBool(j, cr.Bools(colMap[j])[i])
@@ -243,6 +372,10 @@ func ColMap(colMap []int, builder TableBuilder, cr query.ColReader) []int {
 // AppendRecordForCols appends the only the columns provided from cr onto builder.
 func AppendRecordForCols(i int, cr query.ColReader, builder TableBuilder, cols []query.ColMeta) {
 	for j, c := range cols {
+		if IsNull(i, j, cr) {
+			builder.AppendNil(j)
+			continue
+		}
 		switch c.Type {
 		case query.TBool:
 			builder.AppendBool(j, cr.Bools(j)[i])
@@ -305,6 +438,19 @@ func HasCol(label string, cols []query.ColMeta) bool {
 	return ColIdx(label, cols) >= 0
 }
 
+// EqualCols reports whether the columns a and b have the same labels and types in the same order.
+func EqualCols(a, b []query.ColMeta) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for j := range a {
+		if a[j] != b[j] {
+			return false
+		}
+	}
+	return true
+}
+
 // TableBuilder builds tables that can be used multiple times
 type TableBuilder interface {
 	Key() query.GroupKey
@@ -338,6 +484,11 @@ type TableBuilder interface {
 	AppendTime(j int, value Time)
 	AppendValue(j int, value values.Value)
 
+	// SetNil sets the value at the specified coordinates to null.
+	SetNil(i, j int)
+	// AppendNil adds a null value to the end of column j.
+	AppendNil(j int)
+
 	// AppendBools and similar functions will append multiple values to column j.  As above,
 	// it will set the numer of rows in the table to the size of the new column.  It's the
 	// caller's job to make sure that the expected number of rows in each column is equal.
@@ -379,7 +530,7 @@ type ColListTableBuilder struct {
 
 func NewColListTableBuilder(key query.GroupKey, a *Allocator) *ColListTableBuilder {
 	return &ColListTableBuilder{
-		table: &ColListTable{key: key},
+		table: &ColListTable{key: key, alloc: a},
 		alloc: a,
 	}
 }
@@ -399,141 +550,163 @@ func (b ColListTableBuilder) Cols() []query.ColMeta {
 }
 
 func (b ColListTableBuilder) AddCol(c query.ColMeta) int {
+	b.table.colMeta = append(b.table.colMeta, c)
+	b.table.cols = append(b.table.cols, newColumn(c, b.alloc))
+	b.table.nulls = append(b.table.nulls, nil)
+	return len(b.table.cols) - 1
+}
+
+func newColumn(c query.ColMeta, a *Allocator) column {
 	var col column
 	switch c.Type {
 	case query.TBool:
 		col = &boolColumn{
 			ColMeta: c,
-			alloc:   b.alloc,
+			alloc:   a,
 		}
 	case query.TInt:
 		col = &intColumn{
 			ColMeta: c,
-			alloc:   b.alloc,
+			alloc:   a,
 		}
 	case query.TUInt:
 		col = &uintColumn{
 			ColMeta: c,
-			alloc:   b.alloc,
+			alloc:   a,
 		}
 	case query.TFloat:
 		col = &floatColumn{
 			ColMeta: c,
-			alloc:   b.alloc,
+			alloc:   a,
 		}
 	case query.TString:
 		col = &stringColumn{
 			ColMeta: c,
-			alloc:   b.alloc,
+			alloc:   a,
 		}
 	case query.TTime:
 		col = &timeColumn{
 			ColMeta: c,
-			alloc:   b.alloc,
+			alloc:   a,
 		}
 	default:
 		PanicUnknownType(c.Type)
 	}
-	b.table.colMeta = append(b.table.colMeta, c)
-	b.table.cols = append(b.table.cols, col)
-	return len(b.table.cols) - 1
+	return col
 }
 
 func (b ColListTableBuilder) SetBool(i int, j int, value bool) {
 	b.checkColType(j, query.TBool)
 	b.table.cols[j].(*boolColumn).data[i] = value
+	b.setNotNull(i, j)
 }
 func (b ColListTableBuilder) AppendBool(j int, value bool) {
 	b.checkColType(j, query.TBool)
 	col := b.table.cols[j].(*boolColumn)
 	col.data = b.alloc.AppendBools(col.data, value)
 	b.table.nrows = len(col.data)
+	b.extendNulls(j, len(col.data))
 }
 func (b ColListTableBuilder) AppendBools(j int, values []bool) {
 	b.checkColType(j, query.TBool)
 	col := b.table.cols[j].(*boolColumn)
 	col.data = b.alloc.AppendBools(col.data, values...)
 	b.table.nrows = len(col.data)
+	b.extendNulls(j, len(col.data))
 }
 func (b ColListTableBuilder) GrowBools(j, n int) {
 	b.checkColType(j, query.TBool)
 	col := b.table.cols[j].(*boolColumn)
 	col.data = b.alloc.GrowBools(col.data, n)
 	b.table.nrows = len(col.data)
+	b.extendNulls(j, len(col.data))
 }
 
 func (b ColListTableBuilder) SetInt(i int, j int, value int64) {
 	b.checkColType(j, query.TInt)
 	b.table.cols[j].(*intColumn).data[i] = value
+	b.setNotNull(i, j)
 }
 func (b ColListTableBuilder) AppendInt(j int, value int64) {
 	b.checkColType(j, query.TInt)
 	col := b.table.cols[j].(*intColumn)
 	col.data = b.alloc.AppendInts(col.data, value)
 	b.table.nrows = len(col.data)
+	b.extendNulls(j, len(col.data))
 }
 func (b ColListTableBuilder) AppendInts(j int, values []int64) {
 	b.checkColType(j, query.TInt)
 	col := b.table.cols[j].(*intColumn)
 	col.data = b.alloc.AppendInts(col.data, values...)
 	b.table.nrows = len(col.data)
+	b.extendNulls(j, len(col.data))
 }
 func (b ColListTableBuilder) GrowInts(j, n int) {
 	b.checkColType(j, query.TInt)
 	col := b.table.cols[j].(*intColumn)
 	col.data = b.alloc.GrowInts(col.data, n)
 	b.table.nrows = len(col.data)
+	b.extendNulls(j, len(col.data))
 }
 
 func (b ColListTableBuilder) SetUInt(i int, j int, value uint64) {
 	b.checkColType(j, query.TUInt)
 	b.table.cols[j].(*uintColumn).data[i] = value
+	b.setNotNull(i, j)
 }
 func (b ColListTableBuilder) AppendUInt(j int, value uint64) {
 	b.checkColType(j, query.TUInt)
 	col := b.table.cols[j].(*uintColumn)
 	col.data = b.alloc.AppendUInts(col.data, value)
 	b.table.nrows = len(col.data)
+	b.extendNulls(j, len(col.data))
 }
 func (b ColListTableBuilder) AppendUInts(j int, values []uint64) {
 	b.checkColType(j, query.TUInt)
 	col := b.table.cols[j].(*uintColumn)
 	col.data = b.alloc.AppendUInts(col.data, values...)
 	b.table.nrows = len(col.data)
+	b.extendNulls(j, len(col.data))
 }
 func (b ColListTableBuilder) GrowUInts(j, n int) {
 	b.checkColType(j, query.TUInt)
 	col := b.table.cols[j].(*uintColumn)
 	col.data = b.alloc.GrowUInts(col.data, n)
 	b.table.nrows = len(col.data)
+	b.extendNulls(j, len(col.data))
 }
 
 func (b ColListTableBuilder) SetFloat(i int, j int, value float64) {
 	b.checkColType(j, query.TFloat)
 	b.table.cols[j].(*floatColumn).data[i] = value
+	b.setNotNull(i, j)
 }
 func (b ColListTableBuilder) AppendFloat(j int, value float64) {
 	b.checkColType(j, query.TFloat)
 	col := b.table.cols[j].(*floatColumn)
 	col.data = b.alloc.AppendFloats(col.data, value)
 	b.table.nrows = len(col.data)
+	b.extendNulls(j, len(col.data))
 }
 func (b ColListTableBuilder) AppendFloats(j int, values []float64) {
 	b.checkColType(j, query.TFloat)
 	col := b.table.cols[j].(*floatColumn)
 	col.data = b.alloc.AppendFloats(col.data, values...)
 	b.table.nrows = len(col.data)
+	b.extendNulls(j, len(col.data))
 }
 func (b ColListTableBuilder) GrowFloats(j, n int) {
 	b.checkColType(j, query.TFloat)
 	col := b.table.cols[j].(*floatColumn)
 	col.data = b.alloc.GrowFloats(col.data, n)
 	b.table.nrows = len(col.data)
+	b.extendNulls(j, len(col.data))
 }
 
 func (b ColListTableBuilder) SetString(i int, j int, value string) {
 	b.checkColType(j, query.TString)
 	b.table.cols[j].(*stringColumn).data[i] = value
+	b.setNotNull(i, j)
 }
 func (b ColListTableBuilder) AppendString(j int, value string) {
 	meta := b.table.cols[j].Meta()
@@ -541,45 +714,54 @@ func (b ColListTableBuilder) AppendString(j int, value string) {
 	col := b.table.cols[j].(*stringColumn)
 	col.data = b.alloc.AppendStrings(col.data, value)
 	b.table.nrows = len(col.data)
+	b.extendNulls(j, len(col.data))
 }
 func (b ColListTableBuilder) AppendStrings(j int, values []string) {
 	b.checkColType(j, query.TString)
 	col := b.table.cols[j].(*stringColumn)
 	col.data = b.alloc.AppendStrings(col.data, values...)
 	b.table.nrows = len(col.data)
+	b.extendNulls(j, len(col.data))
 }
 func (b ColListTableBuilder) GrowStrings(j, n int) {
 	b.checkColType(j, query.TString)
 	col := b.table.cols[j].(*stringColumn)
 	col.data = b.alloc.GrowStrings(col.data, n)
 	b.table.nrows = len(col.data)
+	b.extendNulls(j, len(col.data))
 }
 
 func (b ColListTableBuilder) SetTime(i int, j int, value Time) {
 	b.checkColType(j, query.TTime)
 	b.table.cols[j].(*timeColumn).data[i] = value
+	b.setNotNull(i, j)
 }
 func (b ColListTableBuilder) AppendTime(j int, value Time) {
 	b.checkColType(j, query.TTime)
 	col := b.table.cols[j].(*timeColumn)
 	col.data = b.alloc.AppendTimes(col.data, value)
 	b.table.nrows = len(col.data)
+	b.extendNulls(j, len(col.data))
 }
 func (b ColListTableBuilder) AppendTimes(j int, values []Time) {
 	b.checkColType(j, query.TTime)
 	col := b.table.cols[j].(*timeColumn)
 	col.data = b.alloc.AppendTimes(col.data, values...)
 	b.table.nrows = len(col.data)
+	b.extendNulls(j, len(col.data))
 }
 func (b ColListTableBuilder) GrowTimes(j, n int) {
 	b.checkColType(j, query.TTime)
 	col := b.table.cols[j].(*timeColumn)
 	col.data = b.alloc.GrowTimes(col.data, n)
 	b.table.nrows = len(col.data)
+	b.extendNulls(j, len(col.data))
 }
 
 func (b ColListTableBuilder) SetValue(i, j int, v values.Value) {
 	switch v.Type() {
+	case semantic.Nil:
+		b.SetNil(i, j)
 	case semantic.Bool:
 		b.SetBool(i, j, v.Bool())
 	case semantic.Int:
@@ -599,6 +781,8 @@ func (b ColListTableBuilder) SetValue(i, j int, v values.Value) {
 
 func (b ColListTableBuilder) AppendValue(j int, v values.Value) {
 	switch v.Type() {
+	case semantic.Nil:
+		b.AppendNil(j)
 	case semantic.Bool:
 		b.AppendBool(j, v.Bool())
 	case semantic.Int:
@@ -616,6 +800,77 @@ func (b ColListTableBuilder) AppendValue(j int, v values.Value) {
 	}
 }
 
+func (b ColListTableBuilder) SetNil(i, j int) {
+	switch c := b.table.cols[j].(type) {
+	case *boolColumn:
+		c.data[i] = false
+	case *intColumn:
+		c.data[i] = 0
+	case *uintColumn:
+		c.data[i] = 0
+	case *floatColumn:
+		c.data[i] = 0
+	case *stringColumn:
+		c.data[i] = ""
+	case *timeColumn:
+		c.data[i] = 0
+	}
+	n := b.table.cols[j].Len()
+	if b.table.nulls[j] == nil {
+		b.table.nulls[j] = b.growNulls(nil, n)
+	}
+	b.table.nulls[j][i] = true
+}
+
+func (b ColListTableBuilder) AppendNil(j int) {
+	switch c := b.table.cols[j].(type) {
+	case *boolColumn:
+		c.data = b.alloc.AppendBools(c.data, false)
+	case *intColumn:
+		c.data = b.alloc.AppendInts(c.data, 0)
+	case *uintColumn:
+		c.data = b.alloc.AppendUInts(c.data, 0)
+	case *floatColumn:
+		c.data = b.alloc.AppendFloats(c.data, 0)
+	case *stringColumn:
+		c.data = b.alloc.AppendStrings(c.data, "")
+	case *timeColumn:
+		c.data = b.alloc.AppendTimes(c.data, 0)
+	}
+	n := b.table.cols[j].Len()
+	b.table.nrows = n
+	b.table.nulls[j] = b.growNulls(b.table.nulls[j], n)
+	b.table.nulls[j][n-1] = true
+}
+
+// extendNulls extends the null mask of column j, if it has one, to n rows that are not null.
+func (b ColListTableBuilder) extendNulls(j, n int) {
+	if b.table.nulls[j] != nil {
+		b.table.nulls[j] = b.growNulls(b.table.nulls[j], n)
+	}
+}
+
+// setNotNull marks the value at the specified coordinates as not null.
+func (b ColListTableBuilder) setNotNull(i, j int) {
+	if nulls := b.table.nulls[j]; nulls != nil {
+		nulls[i] = false
+	}
+}
+
+// growNulls grows the null mask to n rows, the added rows are not null.
+func (b ColListTableBuilder) growNulls(nulls []bool, n int) []bool {
+	l := len(nulls)
+	if n <= l {
+		return nulls
+	}
+	nulls = b.alloc.GrowBools(nulls, n-l)
+	// The grown slice may reuse memory of cleared rows.
+	for i := l; i < n; i++ {
+		nulls[i] = false
+	}
+	return nulls
+}
+
 func (b ColListTableBuilder) checkColType(j int, typ query.DataType) {
 	CheckColType(b.table.colMeta[j], typ)
 }
@@ -646,20 +901,34 @@ func (b ColListTableBuilder) ClearData() {
 	for _, c := range b.table.cols {
 		c.Clear()
 	}
+	b.table.clearNulls()
 	b.table.nrows = 0
 }
 
+// release clears the data of the builder like ClearData,
+// and drops the memory backing its columns instead of keeping it for the next rows.
+func (b ColListTableBuilder) release() {
+	b.ClearData()
+	for j, c := range b.table.cols {
+		b.table.cols[j] = newColumn(c.Meta(), b.alloc)
+	}
+}
+
 func (b ColListTableBuilder) Sort(cols []string, desc bool) {
+	sortColListTable(b.table, cols, desc)
+}
+
+func sortColListTable(t *ColListTable, cols []string, desc bool) {
 	colIdxs := make([]int, len(cols))
 	for i, label := range cols {
-		for j, c := range b.table.colMeta {
+		for j, c := range t.colMeta {
 			if c.Label == label {
 				colIdxs[i] = j
 				break
 			}
 		}
 	}
-	s := colListTableSorter{cols: colIdxs, desc: desc, b: b.table}
+	s := colListTableSorter{cols: colIdxs, desc: desc, b: t}
 	sort.Sort(s)
 }
 
@@ -671,6 +940,9 @@ type ColListTable struct {
 	colMeta []query.ColMeta
 	cols    []column
 	nrows   int
+	// nulls holds the null mask of each column, it is nil for columns without nulls.
+	nulls [][]bool
+	alloc *Allocator
 
 	refCount int32
 }
@@ -681,6 +953,26 @@ func (t *ColListTable) RefCount(n int) {
 		for _, c := range t.cols {
 			c.Clear()
 		}
+		t.clearNulls()
+	}
+}
+
+// free frees the data of the table.
+func (t *ColListTable) free() {
+	for _, c := range t.cols {
+		if c != nil {
+			c.Clear()
+		}
+	}
+	t.clearNulls()
+}
+
+func (t *ColListTable) clearNulls() {
+	for j, nulls := range t.nulls {
+		if nulls != nil {
+			t.alloc.Free(len(nulls), boolSize)
+			t.nulls[j] = nil
+		}
 	}
 }
 
@@ -730,6 +1022,9 @@ func (t *ColListTable) Times(j int) []Time {
 	CheckColType(t.colMeta[j], query.TTime)
 	return t.cols[j].(*timeColumn).data
 }
+func (t *ColListTable) Nulls(j int) []bool {
+	return t.nulls[j]
+}
 
 func (t *ColListTable) Copy() *ColListTable {
 	cpy := new(ColListTable)
@@ -744,6 +1039,16 @@ func (t *ColListTable) Copy() *ColListTable {
 		cpy.cols[i] = c.Copy()
 	}
 
+	cpy.alloc = t.alloc
+	cpy.nulls = make([][]bool, len(t.nulls))
+	for j, nulls := range t.nulls {
+		if nulls != nil {
+			l := len(nulls)
+			cpy.nulls[j] = t.alloc.Bools(l, l)
+			copy(cpy.nulls[j], nulls)
+		}
+	}
+
 	return cpy
 }
 
@@ -752,6 +1057,10 @@ func (t *ColListTable) GetRow(row int) values.Object {
 	record := values.NewObject()
 	var val values.Value
 	for j, col := range t.colMeta {
+		if t.nulls[j] != nil && t.nulls[j][row] {
+			record.Set(col.Label, values.Null)
+			continue
+		}
 		switch col.Type {
 		case query.TBool:
 			val = values.NewBoolValue(t.cols[j].(*boolColumn).data[row])
@@ -783,6 +1092,16 @@ func (c colListTableSorter) Len() int {
 
 func (c colListTableSorter) Less(x int, y int) (less bool) {
 	for _, j := range c.cols {
+		// Nulls are sorted after all other values.
+		if nulls := c.b.nulls[j]; nulls != nil {
+			if nulls[x] != nulls[y] {
+				less = nulls[y]
+				break
+			}
+			if nulls[x] {
+				continue
+			}
+		}
 		if !c.b.cols[j].Equal(x, y) {
 			less = c.b.cols[j].Less(x, y)
 			break
@@ -798,10 +1117,16 @@ func (c colListTableSorter) Swap(x int, y int) {
 	for _, col := range c.b.cols {
 		col.Swap(x, y)
 	}
+	for _, nulls := range c.b.nulls {
+		if nulls != nil {
+			nulls[x], nulls[y] = nulls[y], nulls[x]
+		}
+	}
 }
 
 type column interface {
 	Meta() query.ColMeta
+	Len() int
 	Clear()
 	Copy() column
 	Equal(i, j int) bool
@@ -819,6 +1144,10 @@ func (c *boolColumn) Meta() query.ColMeta {
 	return c.ColMeta
 }
 
+func (c *boolColumn) Len() int {
+	return len(c.data)
+}
+
 func (c *boolColumn) Clear() {
 	c.alloc.Free(len(c.data), boolSize)
 	c.data = c.data[0:0]
@@ -856,6 +1185,10 @@ func (c *intColumn) Meta() query.ColMeta {
 	return c.ColMeta
 }
 
+func (c *intColumn) Len() int {
+	return len(c.data)
+}
+
 func (c *intColumn) Clear() {
 	c.alloc.Free(len(c.data), int64Size)
 	c.data = c.data[0:0]
@@ -890,6 +1223,10 @@ func (c *uintColumn) Meta() query.ColMeta {
 	return c.ColMeta
 }
 
+func (c *uintColumn) Len() int {
+	return len(c.data)
+}
+
 func (c *uintColumn) Clear() {
 	c.alloc.Free(len(c.data), uint64Size)
 	c.data = c.data[0:0]
@@ -924,6 +1261,10 @@ func (c *floatColumn) Meta() query.ColMeta {
 	return c.ColMeta
 }
 
+func (c *floatColumn) Len() int {
+	return len(c.data)
+}
+
 func (c *floatColumn) Clear() {
 	c.alloc.Free(len(c.data), float64Size)
 	c.data = c.data[0:0]
@@ -958,6 +1299,10 @@ func (c *stringColumn) Meta() query.ColMeta {
 	return c.ColMeta
 }
 
+func (c *stringColumn) Len() int {
+	return len(c.data)
+}
+
 func (c *stringColumn) Clear() {
 	c.alloc.Free(len(c.data), stringSize)
 	c.data = c.data[0:0]
@@ -993,6 +1338,10 @@ func (c *timeColumn) Meta() query.ColMeta {
 	return c.ColMeta
 }
 
+func (c *timeColumn) Len() int {
+	return len(c.data)
+}
+
 func (c *timeColumn) Clear() {
 	c.alloc.Free(len(c.data), timeSize)
 	c.data = c.data[0:0]
@@ -1024,11 +1373,29 @@ type TableBuilderCache interface {
 	ForEachBuilder(f func(query.GroupKey, TableBuilder))
 }
 
+// SpillIfNeeded spills the rows of the builders of the cache to disk,
+// if the cache spills its rows and the memory allocated by the query nears its limit.
+func SpillIfNeeded(c TableBuilderCache) error {
+	if s, ok := c.(interface {
+		SpillIfNeeded() error
+	}); ok {
+		return s.SpillIfNeeded()
+	}
+	return nil
+}
+
 type tableBuilderCache struct {
 	tables *GroupLookup
 	alloc  *Allocator
 
 	triggerSpec query.TriggerSpec
+
+	spiller *Spiller
+	// sortCols are the columns the rows of the builders are sorted by, nil if they are not sorted.
+	sortCols []string
+	desc     bool
+	// combine is how the spilled rows with the same values for sortCols are combined.
+	combine rowCombine
 }
 
 func NewTableBuilderCache(a *Allocator) *tableBuilderCache {
@@ -1041,26 +1408,112 @@ func NewTableBuilderCache(a *Allocator) *tableBuilderCache {
 type tableState struct {
 	builder TableBuilder
 	trigger Trigger
+	// spilled are the rows of the builder that have been spilled.
+	spilled []*storedTable
+}
+
+// nrows reports the number of rows of the table, including the spilled ones.
+func (b *tableState) nrows() int {
+	n := b.builder.NRows()
+	for _, r := range b.spilled {
+		n += r.nrows
+	}
+	return n
 }
 
 func (d *tableBuilderCache) SetTriggerSpec(ts query.TriggerSpec) {
 	d.triggerSpec = ts
 }
 
+// SetSpiller lets the rows of the builders be spilled with s by SpillIfNeeded, s may be nil.
+// The table of a builder reads its spilled rows back before its remaining rows.
+func (d *tableBuilderCache) SetSpiller(s *Spiller) {
+	d.spiller = s
+}
+
+// SetSortOrder declares that the rows of the builders are sorted by the columns cols,
+// so that they are sorted before being spilled, and that the table of a builder merges them back in order.
+func (d *tableBuilderCache) SetSortOrder(cols []string, desc bool) {
+	d.sortCols = cols
+	d.desc = desc
+}
+
+// SetDistinctRows declares that the rows of each builder have distinct values for the columns cols,
+// so that they are sorted by cols before being spilled,
+// and that the table of a builder merges them back in order, keeping a single row of the rows spilled with the same values.
+func (d *tableBuilderCache) SetDistinctRows(cols []string) {
+	d.sortCols = cols
+	d.desc = false
+	d.combine = combineFirst
+}
+
+// SetCombinedRows declares that the rows of each builder have distinct values for the columns cols,
+// and that their null values are values that have not been set.
+// The rows are sorted by cols before being spilled, and the columns of a builder may be added after its rows are spilled.
+// The table of a builder merges the rows back in order, combining the rows spilled with the same values
+// into a row of the last of their values that are set. The values that are never set are zero values.
+func (d *tableBuilderCache) SetCombinedRows(cols []string) {
+	d.sortCols = cols
+	d.desc = false
+	d.combine = combineLast
+}
+
+// SpillIfNeeded spills the rows of every builder and clears them, if the memory allocated by the query nears its limit.
+func (d *tableBuilderCache) SpillIfNeeded() (err error) {
+	if !d.spiller.ShouldSpill(d.alloc) {
+		return nil
+	}
+	d.tables.Range(func(key query.GroupKey, value interface{}) {
+		b := value.(*tableState)
+		if err != nil || b.builder.NRows() == 0 {
+			return
+		}
+		// The builders of the cache are always column list builders.
+		builder := b.builder.(*ColListTableBuilder)
+		if d.sortCols != nil {
+			builder.Sort(d.sortCols, d.desc)
+		}
+		var tbl query.Table
+		if tbl, err = d.spiller.Spill(builder.RawTable(), d.alloc); err != nil {
+			return
+		}
+		b.spilled = append(b.spilled, tbl.(*storedTable))
+		builder.release()
+	})
+	return err
+}
+
 func (d *tableBuilderCache) Table(key query.GroupKey) (query.Table, error) {
 	b, ok := d.lookupState(key)
 	if !ok {
 		return nil, fmt.Errorf("table not found with key %v", key)
 	}
-	return b.builder.Table()
+	tbl, err := b.builder.Table()
+	if err != nil || (len(b.spilled) == 0 && d.combine != combineLast) {
+		return tbl, err
+	}
+	mem, ok := tbl.(*ColListTable)
+	if !ok {
+		return nil, fmt.Errorf("cannot read spilled rows back with table of type %T", tbl)
+	}
+	if len(b.spilled) == 0 {
+		// The values that have not been set are zero values.
+		mem.clearNulls()
+		return mem, nil
+	}
+	if d.combine != combineNone {
+		// The remaining rows are merged in order with the spilled rows.
+		sortColListTable(mem, d.sortCols, d.desc)
+	}
+	return newSpilledTable(b.spilled, mem, d.sortCols, d.desc, d.combine)
 }
 
-func (d *tableBuilderCache) lookupState(key query.GroupKey) (tableState, bool) {
+func (d *tableBuilderCache) lookupState(key query.GroupKey) (*tableState, bool) {
 	v, ok := d.tables.Lookup(key)
 	if !ok {
-		return tableState{}, false
+		return nil, false
 	}
-	return v.(tableState), true
+	return v.(*tableState), true
 }
 
 // TableBuilder will return the builder for the specified table.
@@ -1070,7 +1523,7 @@ func (d *tableBuilderCache) TableBuilder(key query.GroupKey) (TableBuilder, bool
 	if !ok {
 		builder := NewColListTableBuilder(key, d.alloc)
 		t := NewTriggerFromSpec(d.triggerSpec)
-		b = tableState{
+		b = &tableState{
 			builder: builder,
 			trigger: t,
 		}
@@ -1081,7 +1534,7 @@ func (d *tableBuilderCache) TableBuilder(key query.GroupKey) (TableBuilder, bool
 
 func (d *tableBuilderCache) ForEachBuilder(f func(query.GroupKey, TableBuilder)) {
 	d.tables.Range(func(key query.GroupKey, value interface{}) {
-		f(key, value.(tableState).builder)
+		f(key, value.(*tableState).builder)
 	})
 }
 
@@ -1089,13 +1542,14 @@ func (d *tableBuilderCache) DiscardTable(key query.GroupKey) {
 	b, ok := d.lookupState(key)
 	if ok {
 		b.builder.ClearData()
+		b.spilled = nil
 	}
 }
 
 func (d *tableBuilderCache) ExpireTable(key query.GroupKey) {
 	b, ok := d.tables.Delete(key)
 	if ok {
-		b.(tableState).builder.ClearData()
+		b.(*tableState).builder.ClearData()
 	}
 }
 
@@ -1107,10 +1561,10 @@ func (d *tableBuilderCache) ForEach(f func(query.GroupKey)) {
 
 func (d *tableBuilderCache) ForEachWithContext(f func(query.GroupKey, Trigger, TableContext)) {
 	d.tables.Range(func(key query.GroupKey, value interface{}) {
-		b := value.(tableState)
+		b := value.(*tableState)
 		f(key, b.trigger, TableContext{
 			Key:   key,
-			Count: b.builder.NRows(),
+			Count: b.nrows(),
 		})
 	})
 }
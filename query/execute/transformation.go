@@ -28,6 +28,8 @@ type Administration interface {
 	ResolveTime(qt query.Time) Time
 	StreamContext() StreamContext
 	Allocator() *Allocator
+	// Spiller returns the spiller of the execution, or nil if buffered rows cannot be spilled to disk.
+	Spiller() *Spiller
 	Parents() []DatasetID
 	ConvertID(plan.ProcedureID) DatasetID
 
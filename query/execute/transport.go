@@ -3,6 +3,7 @@ package execute
 import (
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/EMCECS/influx/query"
 )
@@ -26,6 +27,11 @@ type consecutiveTransport struct {
 
 	schedulerState int32
 	inflight       int32
+
+	// profile records the process and wait durations of the transformation, it is nil if not profiled.
+	profile *nodeProfile
+	// scheduled is the time in nanoseconds at which the transport was last scheduled.
+	scheduled int64
 }
 
 func newConescutiveTransport(dispatcher Dispatcher, t Transformation) *consecutiveTransport {
@@ -134,6 +140,9 @@ const (
 // schedule indicates that there is work available to schedule.
 func (t *consecutiveTransport) schedule() {
 	if t.tryTransition(idle, running) {
+		if t.profile != nil {
+			atomic.StoreInt64(&t.scheduled, time.Now().UnixNano())
+		}
 		t.dispatcher.Schedule(t.processMessages)
 	}
 }
@@ -149,11 +158,14 @@ func (t *consecutiveTransport) transition(new int32) {
 }
 
 func (t *consecutiveTransport) processMessages(throughput int) {
+	if t.profile != nil {
+		t.profile.addWait(time.Duration(time.Now().UnixNano() - atomic.LoadInt64(&t.scheduled)))
+	}
 PROCESS:
 	i := 0
 	for m := t.messages.Pop(); m != nil; m = t.messages.Pop() {
 		atomic.AddInt32(&t.inflight, -1)
-		if f, err := processMessage(t.t, m); err != nil || f {
+		if f, err := t.process(m); err != nil || f {
 			// Set the error if there was any
 			t.setErr(err)
 
@@ -188,6 +200,19 @@ PROCESS:
 	}
 }
 
+// process processes the message on the transformation,
+// recording the time spent if the transport is profiled.
+func (t *consecutiveTransport) process(m Message) (bool, error) {
+	if t.profile == nil {
+		return processMessage(t.t, m)
+	}
+	start := time.Now()
+	defer func() {
+		t.profile.addProcess(time.Since(start))
+	}()
+	return processMessage(t.t, m)
+}
+
 // processMessage processes the message on t.
 // The return value is true if the message was a FinishMsg.
 func processMessage(t Transformation, m Message) (finished bool, err error) {
@@ -1,8 +1,93 @@
 package execute
 
+import (
+	"time"
+
+	"github.com/EMCECS/influx/query/values"
+)
+
 type Window struct {
 	Every  Duration
 	Period Duration
 	Round  Duration
 	Start  Time
+	// EveryMonths and PeriodMonths are calendar months added to Every and Period.
+	EveryMonths  int64
+	PeriodMonths int64
+	// Location is the time zone in which the window boundaries are computed, nil means UTC.
+	Location *time.Location
+}
+
+// IsCalendar reports whether the length of the windows depends on the calendar,
+// either because they are measured in months or because they follow the clock of a time zone.
+func (w Window) IsCalendar() bool {
+	return w.EveryMonths != 0 || w.PeriodMonths != 0 || (w.Location != nil && w.Location != time.UTC)
+}
+
+// Truncate returns the latest window boundary at or before t.
+// Window boundaries are Start moved by a whole number of Every and EveryMonths,
+// measured on the wall clock of the window location.
+func (w Window) Truncate(t Time) Time {
+	step := values.CalendarDuration{Months: w.EveryMonths, Duration: w.Every}.Approximate()
+	if step <= 0 {
+		return t
+	}
+	n := int64(t-w.Start) / int64(step)
+	if t < w.Start {
+		n--
+	}
+	// The approximation is off near daylight saving time changes and for months of different lengths.
+	for w.boundary(n) > t {
+		n--
+	}
+	for w.boundary(n+1) <= t {
+		n++
+	}
+	return w.boundary(n)
+}
+
+// NextBoundary returns the earliest window boundary after the window boundary b.
+func (w Window) NextBoundary(b Time) Time {
+	next := w.addWall(b, w.EveryMonths, w.Every)
+	for next <= b {
+		// The wall clock time does not exist in the location, skip to the following boundary.
+		next = w.addWall(next, w.EveryMonths, w.Every)
+	}
+	return next
+}
+
+// StartOf returns the start of the window that stops at stop.
+func (w Window) StartOf(stop Time) Time {
+	return w.addWall(stop, -w.PeriodMonths, -w.Period)
+}
+
+// boundary returns Start moved by n windows.
+func (w Window) boundary(n int64) Time {
+	return w.addWall(w.Start, n*w.EveryMonths, Duration(n)*w.Every)
+}
+
+// addWall adds months and d to the wall clock time of t in the window location.
+func (w Window) addWall(t Time, months int64, d Duration) Time {
+	loc := w.location()
+	wall := time.Unix(0, int64(t)).In(loc)
+	// Read the wall clock as if it were UTC so that adding a day always adds 24 hours.
+	utc := time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), wall.Nanosecond(), time.UTC)
+	utc = utc.AddDate(0, int(months), 0).Add(time.Duration(d))
+	return Time(time.Date(utc.Year(), utc.Month(), utc.Day(), utc.Hour(), utc.Minute(), utc.Second(), utc.Nanosecond(), loc).UnixNano())
+}
+
+func (w Window) location() *time.Location {
+	if w.Location == nil {
+		return time.UTC
+	}
+	return w.Location
+}
+
+// LocalEpoch returns the midnight of January 1st, 1970 in the time zone loc, nil means UTC.
+// It aligns calendar windows that have no explicit start with the local days, months and years.
+func LocalEpoch(loc *time.Location) Time {
+	if loc == nil {
+		loc = time.UTC
+	}
+	return Time(time.Date(1970, time.January, 1, 0, 0, 0, 0, loc).UnixNano())
 }
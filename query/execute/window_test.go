@@ -0,0 +1,132 @@
+package execute_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/EMCECS/influx/query/execute"
+)
+
+func TestWindow_CalendarBounds(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	date := func(year int, month time.Month, day, hour int, loc *time.Location) execute.Time {
+		return execute.Time(time.Date(year, month, day, hour, 0, 0, 0, loc).UnixNano())
+	}
+	testCases := []struct {
+		name  string
+		w     execute.Window
+		t     execute.Time
+		start execute.Time
+		stop  execute.Time
+	}{
+		{
+			name: "month",
+			w: execute.Window{
+				EveryMonths:  1,
+				PeriodMonths: 1,
+				Start:        execute.LocalEpoch(nil),
+			},
+			t:     date(2018, time.February, 15, 10, time.UTC),
+			start: date(2018, time.February, 1, 0, time.UTC),
+			stop:  date(2018, time.March, 1, 0, time.UTC),
+		},
+		{
+			name: "quarter",
+			w: execute.Window{
+				EveryMonths:  3,
+				PeriodMonths: 3,
+				Start:        execute.LocalEpoch(nil),
+			},
+			t:     date(2018, time.May, 10, 0, time.UTC),
+			start: date(2018, time.April, 1, 0, time.UTC),
+			stop:  date(2018, time.July, 1, 0, time.UTC),
+		},
+		{
+			name: "year on a boundary",
+			w: execute.Window{
+				EveryMonths:  12,
+				PeriodMonths: 12,
+				Start:        execute.LocalEpoch(nil),
+			},
+			t:     date(2016, time.January, 1, 0, time.UTC),
+			start: date(2016, time.January, 1, 0, time.UTC),
+			stop:  date(2017, time.January, 1, 0, time.UTC),
+		},
+		{
+			name: "month before start",
+			w: execute.Window{
+				EveryMonths:  1,
+				PeriodMonths: 1,
+				Start:        execute.LocalEpoch(nil),
+			},
+			t:     date(1969, time.December, 31, 23, time.UTC),
+			start: date(1969, time.December, 1, 0, time.UTC),
+			stop:  date(1970, time.January, 1, 0, time.UTC),
+		},
+		{
+			name: "day in location",
+			w: execute.Window{
+				Every:    execute.Duration(24 * time.Hour),
+				Period:   execute.Duration(24 * time.Hour),
+				Start:    execute.LocalEpoch(newYork),
+				Location: newYork,
+			},
+			t:     date(2018, time.July, 4, 22, newYork),
+			start: date(2018, time.July, 4, 0, newYork),
+			stop:  date(2018, time.July, 5, 0, newYork),
+		},
+		{
+			name: "day when daylight saving time starts",
+			w: execute.Window{
+				Every:    execute.Duration(24 * time.Hour),
+				Period:   execute.Duration(24 * time.Hour),
+				Start:    execute.LocalEpoch(newYork),
+				Location: newYork,
+			},
+			t:     date(2018, time.March, 11, 12, newYork),
+			start: date(2018, time.March, 11, 0, newYork),
+			stop:  date(2018, time.March, 12, 0, newYork),
+		},
+		{
+			name: "month in location",
+			w: execute.Window{
+				EveryMonths:  1,
+				PeriodMonths: 1,
+				Start:        execute.LocalEpoch(newYork),
+				Location:     newYork,
+			},
+			t:     date(2018, time.November, 15, 0, newYork),
+			start: date(2018, time.November, 1, 0, newYork),
+			stop:  date(2018, time.December, 1, 0, newYork),
+		},
+		{
+			name: "week with a day period",
+			w: execute.Window{
+				Every:  execute.Duration(7 * 24 * time.Hour),
+				Period: execute.Duration(24 * time.Hour),
+				// January 1st, 2018 was a Monday.
+				Start:    date(2018, time.January, 1, 0, newYork),
+				Location: newYork,
+			},
+			t:     date(2018, time.January, 10, 12, newYork),
+			start: date(2018, time.January, 14, 0, newYork),
+			stop:  date(2018, time.January, 15, 0, newYork),
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			if !tc.w.IsCalendar() {
+				t.Fatal("expected a calendar window")
+			}
+			stop := tc.w.NextBoundary(tc.w.Truncate(tc.t))
+			start := tc.w.StartOf(stop)
+			if start != tc.start || stop != tc.stop {
+				t.Errorf("unexpected bounds: want [%v, %v), got [%v, %v)", tc.start, tc.stop, start, stop)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+// Package fluxtest runs Flux test files.
+//
+// A Flux test file is a Flux script whose name ends with _test.flux.
+// Each call to assertEquals in the script is a test case.
+// Test cases compare the tables they are given and fail with the differences if the tables are not equal.
+//
+//	inData = "
+//	#datatype,string,long,dateTime:RFC3339,double,string
+//	...
+//	"
+//	outData = "..."
+//	fromCSV(csv: inData)
+//	    |> sum()
+//	    |> assertEquals(name: "sum", want: fromCSV(csv: outData))
+package fluxtest
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/EMCECS/influx"
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/functions"
+	"github.com/EMCECS/influx/query/interpreter"
+	"github.com/EMCECS/influx/query/parser"
+	"github.com/EMCECS/influx/query/semantic"
+	"github.com/pkg/errors"
+)
+
+// FileSuffix is the suffix of the names of Flux test files.
+const FileSuffix = "_test.flux"
+
+// Discover returns the Flux test files found in the given paths.
+// Directories are searched recursively, files are returned as is.
+func Discover(paths ...string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && strings.HasSuffix(info.Name(), FileSuffix) {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Case is the result of a single assertion.
+type Case struct {
+	Name     string
+	Duration time.Duration
+	// Failure is the difference between the tables if the assertion failed.
+	Failure string
+	// Err is any error, other than a failed assertion, that prevented the case from completing.
+	Err error
+}
+
+// Passed reports whether the case completed without failure.
+func (c *Case) Passed() bool {
+	return c.Failure == "" && c.Err == nil
+}
+
+// FileResult is the result of running all cases of a test file.
+type FileResult struct {
+	File     string
+	Duration time.Duration
+	Cases    []*Case
+	// Err is any error that prevented the cases of the file from running.
+	Err error
+}
+
+// Passed reports whether the file and all of its cases completed without failure.
+func (r *FileResult) Passed() bool {
+	if r.Err != nil {
+		return false
+	}
+	for _, c := range r.Cases {
+		if !c.Passed() {
+			return false
+		}
+	}
+	return true
+}
+
+// Runner runs Flux test files against a query service.
+type Runner struct {
+	Service        query.AsyncQueryService
+	OrganizationID platform.ID
+}
+
+// Run runs each of the files in order.
+func (r *Runner) Run(ctx context.Context, files []string) []*FileResult {
+	results := make([]*FileResult, len(files))
+	for i, f := range files {
+		results[i] = r.RunFile(ctx, f)
+	}
+	return results
+}
+
+// RunFile runs each assertion of the file as a separate query.
+func (r *Runner) RunFile(ctx context.Context, file string) *FileResult {
+	start := time.Now()
+	result := &FileResult{File: file}
+	defer func() {
+		result.Duration = time.Since(start)
+	}()
+
+	src, err := ioutil.ReadFile(file)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	assertions, itrp, err := r.eval(string(src))
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	for _, a := range assertions {
+		c := &Case{Name: a.Spec.(*functions.AssertEqualsOpSpec).Name}
+		caseStart := time.Now()
+		err := r.query(ctx, query.ToSpec(itrp, a))
+		c.Duration = time.Since(caseStart)
+		if ae, ok := errors.Cause(err).(*functions.AssertionError); ok {
+			c.Failure = ae.Diff
+		} else {
+			c.Err = err
+		}
+		result.Cases = append(result.Cases, c)
+	}
+	return result
+}
+
+// eval evaluates the script and returns the assertions it makes.
+func (r *Runner) eval(src string) ([]*query.TableObject, *interpreter.Interpreter, error) {
+	astProg, err := parser.NewAST(src)
+	if err != nil {
+		return nil, nil, err
+	}
+	_, decls := query.BuiltIns()
+	semProg, err := semantic.New(astProg, decls)
+	if err != nil {
+		return nil, nil, err
+	}
+	itrp := query.NewInterpreter()
+	if err := itrp.Eval(semProg); err != nil {
+		return nil, nil, err
+	}
+
+	// A call that is also an expression statement is reported twice.
+	var assertions []*query.TableObject
+	seen := make(map[*query.TableObject]bool)
+	for _, v := range itrp.SideEffects() {
+		if t, ok := v.(*query.TableObject); ok && t.Kind == functions.AssertEqualsKind && !seen[t] {
+			seen[t] = true
+			assertions = append(assertions, t)
+		}
+	}
+	if len(assertions) == 0 {
+		return nil, nil, errors.New("no assertions found, use assertEquals to compare tables")
+	}
+	return assertions, itrp, nil
+}
+
+// query runs the spec and reads all of its results.
+func (r *Runner) query(ctx context.Context, spec *query.Spec) error {
+	q, err := r.Service.Query(ctx, &query.Request{
+		OrganizationID: r.OrganizationID,
+		Compiler: query.SpecCompiler{
+			Spec: spec,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	defer q.Done()
+
+	results, ok := <-q.Ready()
+	if !ok {
+		return q.Err()
+	}
+	for _, res := range results {
+		err := res.Tables().Do(func(tbl query.Table) error {
+			return tbl.Do(func(query.ColReader) error { return nil })
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return q.Err()
+}
@@ -0,0 +1,132 @@
+package fluxtest_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"math"
+	"strings"
+	"testing"
+
+	_ "github.com/EMCECS/influx/query/builtin"
+	"github.com/EMCECS/influx/query/control"
+	"github.com/EMCECS/influx/query/execute"
+	"github.com/EMCECS/influx/query/fluxtest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func run(t *testing.T) []*fluxtest.FileResult {
+	t.Helper()
+	files, err := fluxtest.Discover("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"testdata/max_test.flux", "testdata/syntax_error_test.flux"}; !cmp.Equal(want, files) {
+		t.Fatalf("unexpected files -want/+got\n%s", cmp.Diff(want, files))
+	}
+	r := &fluxtest.Runner{
+		Service: control.New(control.Config{
+			ConcurrencyQuota:     1,
+			MemoryBytesQuota:     math.MaxInt64,
+			ExecutorDependencies: make(execute.Dependencies),
+		}),
+	}
+	results := r.Run(context.Background(), files)
+	// Zero the durations so that the reports are stable.
+	for _, res := range results {
+		res.Duration = 0
+		for _, c := range res.Cases {
+			c.Duration = 0
+		}
+	}
+	return results
+}
+
+func TestRunner(t *testing.T) {
+	results := run(t)
+
+	max := results[0]
+	if max.Err != nil {
+		t.Fatal(max.Err)
+	}
+	if len(max.Cases) != 2 {
+		t.Fatalf("unexpected number of cases: got %d want 2", len(max.Cases))
+	}
+	if c := max.Cases[0]; c.Name != "max" || !c.Passed() {
+		t.Errorf("expected case max to pass, got failure %q error %v", c.Failure, c.Err)
+	}
+	if c := max.Cases[1]; c.Name != "min" || c.Err != nil || c.Failure == "" {
+		t.Errorf("expected case min to fail, got failure %q error %v", c.Failure, c.Err)
+	}
+	if max.Passed() {
+		t.Error("expected file with a failing case to fail")
+	}
+
+	if results[1].Err == nil {
+		t.Error("expected error for file with syntax error")
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	results := run(t)
+	var buf bytes.Buffer
+	if err := fluxtest.WriteText(&buf, results, true); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		"--- PASS: max (0.000s)\n",
+		"--- FAIL: min (0.000s)\n",
+		"FAIL\ttestdata/max_test.flux\t0.000s\n",
+		"FAIL\ttestdata/syntax_error_test.flux\t0.000s\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected report to contain %q, got:\n%s", want, got)
+		}
+	}
+	if !strings.HasSuffix(got, "\nFAIL\n") {
+		t.Errorf("expected report to end with FAIL, got:\n%s", got)
+	}
+	// The differing rows are marked with - and + in the _diff column.
+	if !strings.Contains(got, "_diff") {
+		t.Errorf("expected report to contain the table diff, got:\n%s", got)
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	results := run(t)
+	var buf bytes.Buffer
+	if err := fluxtest.WriteJUnit(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+
+	var suites struct {
+		Suites []struct {
+			Name     string `xml:"name,attr"`
+			Tests    int    `xml:"tests,attr"`
+			Failures int    `xml:"failures,attr"`
+			Errors   int    `xml:"errors,attr"`
+			Cases    []struct {
+				Name    string    `xml:"name,attr"`
+				Failure *struct{} `xml:"failure"`
+				Error   *struct{} `xml:"error"`
+			} `xml:"testcase"`
+		} `xml:"testsuite"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &suites); err != nil {
+		t.Fatal(err)
+	}
+	if len(suites.Suites) != 2 {
+		t.Fatalf("unexpected number of suites: got %d want 2", len(suites.Suites))
+	}
+	s := suites.Suites[0]
+	if s.Name != "testdata/max_test.flux" || s.Tests != 2 || s.Failures != 1 || s.Errors != 0 {
+		t.Errorf("unexpected suite %+v", s)
+	}
+	if s.Cases[0].Failure != nil || s.Cases[1].Failure == nil {
+		t.Errorf("unexpected cases %+v", s.Cases)
+	}
+	if s := suites.Suites[1]; s.Tests != 1 || s.Errors != 1 {
+		t.Errorf("unexpected suite %+v", s)
+	}
+}
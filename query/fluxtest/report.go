@@ -0,0 +1,154 @@
+package fluxtest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// WriteText writes a human readable report of the results.
+// Passing cases are only listed when verbose is set.
+func WriteText(w io.Writer, results []*FileResult, verbose bool) error {
+	passed := true
+	for _, r := range results {
+		if r.Err != nil {
+			passed = false
+			if _, err := fmt.Fprintf(w, "FAIL\t%s\t%s\n%s\n", r.File, seconds(r.Duration), indent(r.Err.Error())); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, c := range r.Cases {
+			var err error
+			switch {
+			case c.Err != nil:
+				_, err = fmt.Fprintf(w, "--- ERROR: %s (%s)\n%s\n", c.Name, seconds(c.Duration), indent(c.Err.Error()))
+			case c.Failure != "":
+				_, err = fmt.Fprintf(w, "--- FAIL: %s (%s)\n%s\n", c.Name, seconds(c.Duration), indent(c.Failure))
+			case verbose:
+				_, err = fmt.Fprintf(w, "--- PASS: %s (%s)\n", c.Name, seconds(c.Duration))
+			}
+			if err != nil {
+				return err
+			}
+		}
+		status := "ok  "
+		if !r.Passed() {
+			status = "FAIL"
+			passed = false
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\n", status, r.File, seconds(r.Duration)); err != nil {
+			return err
+		}
+	}
+	status := "PASS"
+	if !passed {
+		status = "FAIL"
+	}
+	_, err := fmt.Fprintln(w, status)
+	return err
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3fs", d.Seconds())
+}
+
+func indent(s string) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	for i, l := range lines {
+		lines[i] = "    " + l
+	}
+	return strings.Join(lines, "\n")
+}
+
+// The JUnit XML types follow the format understood by common CI servers.
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+}
+
+type junitMessage struct {
+	Message  string `xml:"message,attr"`
+	Contents string `xml:",chardata"`
+}
+
+// WriteJUnit writes the results as JUnit XML, with a test suite for each file.
+// A file that could not be run is reported as a single erroring test case.
+func WriteJUnit(w io.Writer, results []*FileResult) error {
+	suites := junitTestSuites{
+		Suites: make([]junitTestSuite, 0, len(results)),
+	}
+	for _, r := range results {
+		s := junitTestSuite{
+			Name: r.File,
+			Time: fmt.Sprintf("%.3f", r.Duration.Seconds()),
+		}
+		if r.Err != nil {
+			s.Tests = 1
+			s.Errors = 1
+			s.Cases = append(s.Cases, junitTestCase{
+				Name:      r.File,
+				ClassName: r.File,
+				Time:      s.Time,
+				Error: &junitMessage{
+					Message:  "failed to run test file",
+					Contents: r.Err.Error(),
+				},
+			})
+		}
+		for _, c := range r.Cases {
+			tc := junitTestCase{
+				Name:      c.Name,
+				ClassName: r.File,
+				Time:      fmt.Sprintf("%.3f", c.Duration.Seconds()),
+			}
+			switch {
+			case c.Err != nil:
+				s.Errors++
+				tc.Error = &junitMessage{
+					Message:  "error running test",
+					Contents: c.Err.Error(),
+				}
+			case c.Failure != "":
+				s.Failures++
+				tc.Failure = &junitMessage{
+					Message:  "tables are not equal",
+					Contents: c.Failure,
+				}
+			}
+			s.Tests++
+			s.Cases = append(s.Cases, tc)
+		}
+		suites.Suites = append(suites.Suites, s)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,20 @@
+package functions
+
+import "github.com/EMCECS/influx/query"
+
+func init() {
+	query.RegisterBuiltIn("aggregateWindow", aggregateWindowBuiltIn)
+}
+
+var aggregateWindowBuiltIn = `
+// aggregateWindow downsamples data by applying fn to each window of time.
+// The time of each aggregated row is the stop of its window, and the windows are merged back
+// into the tables of the input. Windows may be calendar durations such as 1mo and 1y,
+// and they are aligned on the local midnight of location.
+// fn is called with the windowed tables as its table parameter, like the builtin aggregates and selectors.
+aggregateWindow = (every, fn, createEmpty=true, location="UTC", table=<-) =>
+	fn(table: table |> window(every:every, createEmpty:createEmpty, location:location))
+		|> drop(columns:["_time"])
+		|> duplicate(column:"_stop", as:"_time")
+		|> window(every:inf)
+`
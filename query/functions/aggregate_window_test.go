@@ -0,0 +1,144 @@
+package functions_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/execute"
+	"github.com/EMCECS/influx/query/functions"
+	"github.com/EMCECS/influx/query/querytest"
+)
+
+func TestAggregateWindow_NewQuery(t *testing.T) {
+	window := func(s functions.WindowOpSpec) *functions.WindowOpSpec {
+		s.TimeCol = execute.DefaultTimeColLabel
+		s.StartColLabel = execute.DefaultStartColLabel
+		s.StopColLabel = execute.DefaultStopColLabel
+		return &s
+	}
+	tests := []querytest.NewQueryTestCase{
+		{
+			Name: "aggregate window",
+			Raw:  `from(bucket:"mybucket") |> aggregateWindow(every:1mo, fn:mean, location:"Europe/Paris")`,
+			Want: &query.Spec{
+				Operations: []*query.Operation{
+					{
+						ID: "from0",
+						Spec: &functions.FromOpSpec{
+							Bucket: "mybucket",
+						},
+					},
+					{
+						ID: "window1",
+						Spec: window(functions.WindowOpSpec{
+							EveryMonths:  1,
+							PeriodMonths: 1,
+							Location:     "Europe/Paris",
+							CreateEmpty:  true,
+						}),
+					},
+					{
+						ID: "mean2",
+						Spec: &functions.MeanOpSpec{
+							AggregateConfig: execute.DefaultAggregateConfig,
+						},
+					},
+					{
+						ID: "drop3",
+						Spec: &functions.DropOpSpec{
+							Cols: []string{"_time"},
+						},
+					},
+					{
+						ID: "duplicate4",
+						Spec: &functions.DuplicateOpSpec{
+							Col: "_stop",
+							As:  "_time",
+						},
+					},
+					{
+						ID: "window5",
+						Spec: window(functions.WindowOpSpec{
+							Every:  query.Duration(math.MaxInt64),
+							Period: query.Duration(math.MaxInt64),
+						}),
+					},
+				},
+				Edges: []query.Edge{
+					{Parent: "from0", Child: "window1"},
+					{Parent: "window1", Child: "mean2"},
+					{Parent: "mean2", Child: "drop3"},
+					{Parent: "drop3", Child: "duplicate4"},
+					{Parent: "duplicate4", Child: "window5"},
+				},
+			},
+		},
+		{
+			Name: "aggregate window with selector",
+			Raw:  `from(bucket:"mybucket") |> aggregateWindow(every:1h, fn:last, createEmpty:false)`,
+			Want: &query.Spec{
+				Operations: []*query.Operation{
+					{
+						ID: "from0",
+						Spec: &functions.FromOpSpec{
+							Bucket: "mybucket",
+						},
+					},
+					{
+						ID: "window1",
+						Spec: window(functions.WindowOpSpec{
+							Every:    query.Duration(time.Hour),
+							Period:   query.Duration(time.Hour),
+							Location: "UTC",
+						}),
+					},
+					{
+						ID:   "last2",
+						Spec: &functions.LastOpSpec{},
+					},
+					{
+						ID: "drop3",
+						Spec: &functions.DropOpSpec{
+							Cols: []string{"_time"},
+						},
+					},
+					{
+						ID: "duplicate4",
+						Spec: &functions.DuplicateOpSpec{
+							Col: "_stop",
+							As:  "_time",
+						},
+					},
+					{
+						ID: "window5",
+						Spec: window(functions.WindowOpSpec{
+							Every:  query.Duration(math.MaxInt64),
+							Period: query.Duration(math.MaxInt64),
+						}),
+					},
+				},
+				Edges: []query.Edge{
+					{Parent: "from0", Child: "window1"},
+					{Parent: "window1", Child: "last2"},
+					{Parent: "last2", Child: "drop3"},
+					{Parent: "drop3", Child: "duplicate4"},
+					{Parent: "duplicate4", Child: "window5"},
+				},
+			},
+		},
+		{
+			Name:    "aggregate window without fn",
+			Raw:     `from(bucket:"mybucket") |> aggregateWindow(every:1h)`,
+			WantErr: true,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			querytest.NewQueryTestHelper(t, tc)
+		})
+	}
+}
@@ -0,0 +1,515 @@
+package functions
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/execute"
+	"github.com/EMCECS/influx/query/plan"
+	"github.com/EMCECS/influx/query/semantic"
+)
+
+// The anomaly detectors are the functions of the anomaly package, for example anomaly.mad().
+const (
+	AnomalyPackage = "anomaly"
+
+	AnomalyMADKind      = "anomalyMAD"
+	AnomalyZScoreKind   = "anomalyZScore"
+	AnomalySeasonalKind = "anomalySeasonal"
+
+	DefaultAnomalyColLabel = "_anomaly"
+	DefaultScoreColLabel   = "_score"
+
+	// madScale scales the median absolute deviation to the standard deviation of normally distributed values.
+	madScale = 1.4826
+)
+
+// AnomalyConfig is the configuration common to the anomaly detectors.
+// Each detector scores the values of Column, and records whose score is above Threshold are anomalies.
+type AnomalyConfig struct {
+	Threshold float64 `json:"threshold"`
+	Column    string  `json:"column"`
+	// As is the boolean column that flags the anomalies.
+	As string `json:"as"`
+	// Score is the float column of the scores.
+	Score string `json:"score"`
+}
+
+var DefaultAnomalyConfig = AnomalyConfig{
+	Threshold: 3,
+	Column:    execute.DefaultValueColLabel,
+	As:        DefaultAnomalyColLabel,
+	Score:     DefaultScoreColLabel,
+}
+
+func (c *AnomalyConfig) ReadArgs(args query.Arguments) error {
+	*c = DefaultAnomalyConfig
+	if threshold, ok, err := args.GetFloat("threshold"); err != nil {
+		return err
+	} else if ok {
+		if threshold < 0 {
+			return errors.New("threshold must not be negative")
+		}
+		c.Threshold = threshold
+	}
+	if col, ok, err := args.GetString("column"); err != nil {
+		return err
+	} else if ok {
+		c.Column = col
+	}
+	if as, ok, err := args.GetString("as"); err != nil {
+		return err
+	} else if ok {
+		c.As = as
+	}
+	if score, ok, err := args.GetString("score"); err != nil {
+		return err
+	} else if ok {
+		c.Score = score
+	}
+	if c.As == c.Score {
+		return fmt.Errorf("as and score must be different columns, got %q", c.As)
+	}
+	return nil
+}
+
+// AnomalyMADOpSpec scores values by their distance to the median of the table,
+// in units of the median absolute deviation scaled to a standard deviation.
+type AnomalyMADOpSpec struct {
+	AnomalyConfig
+}
+
+// AnomalyZScoreOpSpec scores values by their distance to the mean of the Window values before them,
+// in units of standard deviation. A zero Window uses the mean of the whole table.
+type AnomalyZScoreOpSpec struct {
+	Window int64 `json:"window"`
+	AnomalyConfig
+}
+
+// AnomalySeasonalOpSpec decomposes the values into trend, seasonal and residual components,
+// and scores the residuals like AnomalyMADOpSpec. Seasonality is the number of values in a season.
+type AnomalySeasonalOpSpec struct {
+	Seasonality int64 `json:"seasonality"`
+	AnomalyConfig
+}
+
+func anomalySignature() semantic.FunctionSignature {
+	sig := query.DefaultFunctionSignature()
+	sig.Params["threshold"] = semantic.Float
+	sig.Params["column"] = semantic.String
+	sig.Params["as"] = semantic.String
+	sig.Params["score"] = semantic.String
+	return sig
+}
+
+func init() {
+	madSignature := anomalySignature()
+	zscoreSignature := anomalySignature()
+	zscoreSignature.Params["window"] = semantic.Int
+	seasonalSignature := anomalySignature()
+	seasonalSignature.Params["seasonality"] = semantic.Int
+
+	query.RegisterPackageFunction(AnomalyPackage, "mad", createAnomalyMADOpSpec, madSignature)
+	query.RegisterPackageFunction(AnomalyPackage, "zscore", createAnomalyZScoreOpSpec, zscoreSignature)
+	query.RegisterPackageFunction(AnomalyPackage, "seasonal", createAnomalySeasonalOpSpec, seasonalSignature)
+	query.RegisterOpSpec(AnomalyMADKind, newAnomalyMADOp)
+	query.RegisterOpSpec(AnomalyZScoreKind, newAnomalyZScoreOp)
+	query.RegisterOpSpec(AnomalySeasonalKind, newAnomalySeasonalOp)
+	plan.RegisterProcedureSpec(AnomalyMADKind, newAnomalyProcedure, AnomalyMADKind)
+	plan.RegisterProcedureSpec(AnomalyZScoreKind, newAnomalyProcedure, AnomalyZScoreKind)
+	plan.RegisterProcedureSpec(AnomalySeasonalKind, newAnomalyProcedure, AnomalySeasonalKind)
+	execute.RegisterTransformation(AnomalyMADKind, createAnomalyTransformation)
+	execute.RegisterTransformation(AnomalyZScoreKind, createAnomalyTransformation)
+	execute.RegisterTransformation(AnomalySeasonalKind, createAnomalyTransformation)
+}
+
+func createAnomalyMADOpSpec(args query.Arguments, a *query.Administration) (query.OperationSpec, error) {
+	if err := a.AddParentFromArgs(args); err != nil {
+		return nil, err
+	}
+	spec := new(AnomalyMADOpSpec)
+	if err := spec.AnomalyConfig.ReadArgs(args); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+func createAnomalyZScoreOpSpec(args query.Arguments, a *query.Administration) (query.OperationSpec, error) {
+	if err := a.AddParentFromArgs(args); err != nil {
+		return nil, err
+	}
+	spec := new(AnomalyZScoreOpSpec)
+	if window, ok, err := args.GetInt("window"); err != nil {
+		return nil, err
+	} else if ok {
+		if window < 2 {
+			return nil, fmt.Errorf("window must be at least 2, got %d", window)
+		}
+		spec.Window = window
+	}
+	if err := spec.AnomalyConfig.ReadArgs(args); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+func createAnomalySeasonalOpSpec(args query.Arguments, a *query.Administration) (query.OperationSpec, error) {
+	if err := a.AddParentFromArgs(args); err != nil {
+		return nil, err
+	}
+	spec := new(AnomalySeasonalOpSpec)
+	seasonality, err := args.GetRequiredInt("seasonality")
+	if err != nil {
+		return nil, err
+	}
+	if seasonality < 2 {
+		return nil, fmt.Errorf("seasonality must be at least 2, got %d", seasonality)
+	}
+	spec.Seasonality = seasonality
+	if err := spec.AnomalyConfig.ReadArgs(args); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+func newAnomalyMADOp() query.OperationSpec {
+	return new(AnomalyMADOpSpec)
+}
+
+func newAnomalyZScoreOp() query.OperationSpec {
+	return new(AnomalyZScoreOpSpec)
+}
+
+func newAnomalySeasonalOp() query.OperationSpec {
+	return new(AnomalySeasonalOpSpec)
+}
+
+func (s *AnomalyMADOpSpec) Kind() query.OperationKind {
+	return AnomalyMADKind
+}
+
+func (s *AnomalyZScoreOpSpec) Kind() query.OperationKind {
+	return AnomalyZScoreKind
+}
+
+func (s *AnomalySeasonalOpSpec) Kind() query.OperationKind {
+	return AnomalySeasonalKind
+}
+
+type AnomalyProcedureSpec struct {
+	// Method is the kind of the detector.
+	Method      string
+	Window      int64
+	Seasonality int64
+	AnomalyConfig
+}
+
+func newAnomalyProcedure(qs query.OperationSpec, pa plan.Administration) (plan.ProcedureSpec, error) {
+	switch spec := qs.(type) {
+	case *AnomalyMADOpSpec:
+		return &AnomalyProcedureSpec{
+			Method:        AnomalyMADKind,
+			AnomalyConfig: spec.AnomalyConfig,
+		}, nil
+	case *AnomalyZScoreOpSpec:
+		return &AnomalyProcedureSpec{
+			Method:        AnomalyZScoreKind,
+			Window:        spec.Window,
+			AnomalyConfig: spec.AnomalyConfig,
+		}, nil
+	case *AnomalySeasonalOpSpec:
+		return &AnomalyProcedureSpec{
+			Method:        AnomalySeasonalKind,
+			Seasonality:   spec.Seasonality,
+			AnomalyConfig: spec.AnomalyConfig,
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid spec type %T", qs)
+	}
+}
+
+func (s *AnomalyProcedureSpec) Kind() plan.ProcedureKind {
+	return plan.ProcedureKind(s.Method)
+}
+func (s *AnomalyProcedureSpec) Copy() plan.ProcedureSpec {
+	ns := new(AnomalyProcedureSpec)
+	*ns = *s
+	return ns
+}
+
+func createAnomalyTransformation(id execute.DatasetID, mode execute.AccumulationMode, spec plan.ProcedureSpec, a execute.Administration) (execute.Transformation, execute.Dataset, error) {
+	s, ok := spec.(*AnomalyProcedureSpec)
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid spec type %T", spec)
+	}
+	cache := execute.NewTableBuilderCache(a.Allocator())
+	d := execute.NewDataset(id, mode, cache)
+	t := NewAnomalyTransformation(d, cache, a.Allocator(), s)
+	return t, d, nil
+}
+
+type anomalyTransformation struct {
+	d     execute.Dataset
+	cache execute.TableBuilderCache
+	alloc *execute.Allocator
+
+	method      string
+	window      int
+	seasonality int
+	config      AnomalyConfig
+}
+
+func NewAnomalyTransformation(d execute.Dataset, cache execute.TableBuilderCache, alloc *execute.Allocator, spec *AnomalyProcedureSpec) *anomalyTransformation {
+	return &anomalyTransformation{
+		d:           d,
+		cache:       cache,
+		alloc:       alloc,
+		method:      spec.Method,
+		window:      int(spec.Window),
+		seasonality: int(spec.Seasonality),
+		config:      spec.AnomalyConfig,
+	}
+}
+
+func (t *anomalyTransformation) RetractTable(id execute.DatasetID, key query.GroupKey) error {
+	return t.d.RetractTable(key)
+}
+
+func (t *anomalyTransformation) Process(id execute.DatasetID, tbl query.Table) error {
+	cols := tbl.Cols()
+	valueIdx := execute.ColIdx(t.config.Column, cols)
+	if valueIdx < 0 {
+		return fmt.Errorf("column %q does not exist", t.config.Column)
+	}
+	valueType := cols[valueIdx].Type
+	switch valueType {
+	case query.TInt, query.TUInt, query.TFloat:
+	default:
+		return fmt.Errorf("anomaly detection does not support %v", valueType)
+	}
+	for _, label := range []string{t.config.As, t.config.Score} {
+		if execute.ColIdx(label, cols) >= 0 {
+			return fmt.Errorf("column %q already exists", label)
+		}
+	}
+
+	builder, created := t.cache.TableBuilder(tbl.Key())
+	if !created {
+		return fmt.Errorf("anomaly detection found duplicate table with key: %v", tbl.Key())
+	}
+	for _, c := range cols {
+		builder.AddCol(c)
+	}
+	asIdx := builder.AddCol(query.ColMeta{
+		Label: t.config.As,
+		Type:  query.TBool,
+	})
+	scoreIdx := builder.AddCol(query.ColMeta{
+		Label: t.config.Score,
+		Type:  query.TFloat,
+	})
+
+	// Copy the records and buffer the non null values with their row,
+	// the anomalies are known once every value has been read.
+	var (
+		vs   []float64
+		rows []int64
+	)
+	defer func() {
+		t.alloc.Free(cap(vs), 8)
+		t.alloc.Free(cap(rows), 8)
+	}()
+	err := tbl.Do(func(cr query.ColReader) error {
+		for i := 0; i < cr.Len(); i++ {
+			for j := range cols {
+				execute.AppendValue(builder, j, execute.ValueForRow(i, j, cr))
+			}
+			builder.AppendNil(asIdx)
+			builder.AppendNil(scoreIdx)
+			if execute.IsNull(i, valueIdx, cr) {
+				continue
+			}
+			var v float64
+			switch valueType {
+			case query.TInt:
+				v = float64(cr.Ints(valueIdx)[i])
+			case query.TUInt:
+				v = float64(cr.UInts(valueIdx)[i])
+			case query.TFloat:
+				v = cr.Floats(valueIdx)[i]
+			}
+			vs = t.alloc.AppendFloats(vs, v)
+			rows = t.alloc.AppendInts(rows, int64(builder.NRows()-1))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	scores := t.alloc.Floats(len(vs), len(vs))
+	defer t.alloc.Free(len(scores), 8)
+	switch t.method {
+	case AnomalyZScoreKind:
+		zscores(vs, t.window, scores)
+	case AnomalySeasonalKind:
+		t.seasonalScores(vs, scores)
+	default:
+		t.madScores(vs, scores)
+	}
+	for k, row := range rows {
+		score := scores[k]
+		if math.IsNaN(score) {
+			// There are not enough values to score this one.
+			continue
+		}
+		builder.SetFloat(int(row), scoreIdx, score)
+		builder.SetBool(int(row), asIdx, score > t.config.Threshold)
+	}
+	return nil
+}
+
+func (t *anomalyTransformation) UpdateWatermark(id execute.DatasetID, mark execute.Time) error {
+	return t.d.UpdateWatermark(mark)
+}
+func (t *anomalyTransformation) UpdateProcessingTime(id execute.DatasetID, pt execute.Time) error {
+	return t.d.UpdateProcessingTime(pt)
+}
+func (t *anomalyTransformation) Finish(id execute.DatasetID, err error) {
+	t.d.Finish(err)
+}
+
+// madScores sets the scores of vs to their distance to the median, in scaled median absolute deviations.
+func (t *anomalyTransformation) madScores(vs, scores []float64) {
+	if len(vs) == 0 {
+		return
+	}
+	sorted := t.alloc.Floats(len(vs), len(vs))
+	defer t.alloc.Free(len(sorted), 8)
+
+	copy(sorted, vs)
+	median := sortedMedian(sorted)
+	for i, v := range vs {
+		sorted[i] = math.Abs(v - median)
+	}
+	mad := sortedMedian(sorted) * madScale
+	for i, v := range vs {
+		scores[i] = deviations(math.Abs(v-median), mad)
+	}
+}
+
+// seasonalScores removes the trend and the seasonal component from vs, and scores the residuals with madScores.
+// The values are assumed to be at regular intervals, and at least two seasons are needed.
+func (t *anomalyTransformation) seasonalScores(vs, scores []float64) {
+	m := t.seasonality
+	if m < 2 || len(vs) < 2*m {
+		for i := range scores {
+			scores[i] = math.NaN()
+		}
+		return
+	}
+	residuals := t.alloc.Floats(len(vs), len(vs))
+	defer t.alloc.Free(len(residuals), 8)
+
+	// The trend is the centered moving average over a season, extended to the edges of the series.
+	half := m / 2
+	for i := half; i < len(vs)-half; i++ {
+		var sum float64
+		if m%2 == 1 {
+			for _, v := range vs[i-half : i+half+1] {
+				sum += v
+			}
+		} else {
+			sum = (vs[i-half] + vs[i+half]) / 2
+			for _, v := range vs[i-half+1 : i+half] {
+				sum += v
+			}
+		}
+		residuals[i] = sum / float64(m)
+	}
+	for i := 0; i < half; i++ {
+		residuals[i] = residuals[half]
+		residuals[len(vs)-1-i] = residuals[len(vs)-1-half]
+	}
+	for i, v := range vs {
+		residuals[i] = v - residuals[i]
+	}
+
+	// The seasonal component is the mean of the detrended values at the same position in each season,
+	// centered so that it sums to zero.
+	seasonal := make([]float64, m)
+	counts := make([]int, m)
+	for i, r := range residuals {
+		seasonal[i%m] += r
+		counts[i%m]++
+	}
+	var mean float64
+	for k := range seasonal {
+		seasonal[k] /= float64(counts[k])
+		mean += seasonal[k] / float64(m)
+	}
+	for i := range residuals {
+		residuals[i] -= seasonal[i%m] - mean
+	}
+	t.madScores(residuals, scores)
+}
+
+// zscores sets the scores of vs to their distance to the mean of the window values before them, in standard deviations.
+// The values before the first full window have no score. A zero window uses the mean of all values.
+func zscores(vs []float64, window int, scores []float64) {
+	if window <= 0 {
+		var sum, sumSq float64
+		for _, v := range vs {
+			sum += v
+			sumSq += v * v
+		}
+		n := float64(len(vs))
+		mean := sum / n
+		stddev := math.Sqrt(math.Max(0, sumSq/n-mean*mean))
+		for i, v := range vs {
+			scores[i] = deviations(math.Abs(v-mean), stddev)
+		}
+		return
+	}
+	var sum, sumSq float64
+	for i, v := range vs {
+		if i < window {
+			scores[i] = math.NaN()
+		} else {
+			n := float64(window)
+			mean := sum / n
+			stddev := math.Sqrt(math.Max(0, sumSq/n-mean*mean))
+			scores[i] = deviations(math.Abs(v-mean), stddev)
+			old := vs[i-window]
+			sum -= old
+			sumSq -= old * old
+		}
+		sum += v
+		sumSq += v * v
+	}
+}
+
+// deviations returns d in units of the deviation dev.
+// Without deviation, any distance is infinitely far.
+func deviations(d, dev float64) float64 {
+	if dev == 0 {
+		if d == 0 {
+			return 0
+		}
+		return math.Inf(1)
+	}
+	return d / dev
+}
+
+// sortedMedian sorts vs and returns their median.
+func sortedMedian(vs []float64) float64 {
+	sort.Float64s(vs)
+	n := len(vs)
+	if n%2 == 1 {
+		return vs[n/2]
+	}
+	return (vs[n/2-1] + vs[n/2]) / 2
+}
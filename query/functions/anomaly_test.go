@@ -0,0 +1,300 @@
+package functions_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/execute"
+	"github.com/EMCECS/influx/query/execute/executetest"
+	"github.com/EMCECS/influx/query/functions"
+	"github.com/EMCECS/influx/query/querytest"
+)
+
+func TestAnomaly_NewQuery(t *testing.T) {
+	tests := []querytest.NewQueryTestCase{
+		{
+			Name: "mad",
+			Raw:  `from(bucket:"mydb") |> anomaly.mad(threshold:2.5)`,
+			Want: &query.Spec{
+				Operations: []*query.Operation{
+					{
+						ID: "from0",
+						Spec: &functions.FromOpSpec{
+							Bucket: "mydb",
+						},
+					},
+					{
+						ID: "anomalyMAD1",
+						Spec: &functions.AnomalyMADOpSpec{
+							AnomalyConfig: functions.AnomalyConfig{
+								Threshold: 2.5,
+								Column:    "_value",
+								As:        "_anomaly",
+								Score:     "_score",
+							},
+						},
+					},
+				},
+				Edges: []query.Edge{
+					{Parent: "from0", Child: "anomalyMAD1"},
+				},
+			},
+		},
+		{
+			Name: "zscore",
+			Raw:  `from(bucket:"mydb") |> anomaly.zscore(window:10, column:"load", as:"alert")`,
+			Want: &query.Spec{
+				Operations: []*query.Operation{
+					{
+						ID: "from0",
+						Spec: &functions.FromOpSpec{
+							Bucket: "mydb",
+						},
+					},
+					{
+						ID: "anomalyZScore1",
+						Spec: &functions.AnomalyZScoreOpSpec{
+							Window: 10,
+							AnomalyConfig: functions.AnomalyConfig{
+								Threshold: 3,
+								Column:    "load",
+								As:        "alert",
+								Score:     "_score",
+							},
+						},
+					},
+				},
+				Edges: []query.Edge{
+					{Parent: "from0", Child: "anomalyZScore1"},
+				},
+			},
+		},
+		{
+			Name: "seasonal",
+			Raw:  `from(bucket:"mydb") |> anomaly.seasonal(seasonality:24)`,
+			Want: &query.Spec{
+				Operations: []*query.Operation{
+					{
+						ID: "from0",
+						Spec: &functions.FromOpSpec{
+							Bucket: "mydb",
+						},
+					},
+					{
+						ID: "anomalySeasonal1",
+						Spec: &functions.AnomalySeasonalOpSpec{
+							Seasonality:   24,
+							AnomalyConfig: functions.DefaultAnomalyConfig,
+						},
+					},
+				},
+				Edges: []query.Edge{
+					{Parent: "from0", Child: "anomalySeasonal1"},
+				},
+			},
+		},
+		{
+			Name:    "seasonal without seasonality",
+			Raw:     `from(bucket:"mydb") |> anomaly.seasonal()`,
+			WantErr: true,
+		},
+		{
+			Name:    "same anomaly and score columns",
+			Raw:     `from(bucket:"mydb") |> anomaly.mad(as:"x", score:"x")`,
+			WantErr: true,
+		},
+		{
+			Name:    "unknown detector",
+			Raw:     `from(bucket:"mydb") |> anomaly.foo()`,
+			WantErr: true,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			querytest.NewQueryTestHelper(t, tc)
+		})
+	}
+}
+
+func TestAnomalyOperation_Marshaling(t *testing.T) {
+	data := []byte(`{"id":"anomaly","kind":"anomalyZScore","spec":{"window":5,"threshold":2,"column":"_value","as":"_anomaly","score":"_score"}}`)
+	op := &query.Operation{
+		ID: "anomaly",
+		Spec: &functions.AnomalyZScoreOpSpec{
+			Window: 5,
+			AnomalyConfig: functions.AnomalyConfig{
+				Threshold: 2,
+				Column:    "_value",
+				As:        "_anomaly",
+				Score:     "_score",
+			},
+		},
+	}
+	querytest.OperationMarshalingTestHelper(t, data, op)
+}
+
+func TestAnomaly_Process(t *testing.T) {
+	inCols := []query.ColMeta{
+		{Label: "_time", Type: query.TTime},
+		{Label: "_value", Type: query.TFloat},
+	}
+	outCols := []query.ColMeta{
+		{Label: "_time", Type: query.TTime},
+		{Label: "_value", Type: query.TFloat},
+		{Label: "_anomaly", Type: query.TBool},
+		{Label: "_score", Type: query.TFloat},
+	}
+	// series returns the table of vs at consecutive times.
+	series := func(vs ...interface{}) *executetest.Table {
+		tbl := &executetest.Table{ColMeta: inCols}
+		for i, v := range vs {
+			tbl.Data = append(tbl.Data, []interface{}{execute.Time(i + 1), v})
+		}
+		return tbl
+	}
+	testCases := []struct {
+		name    string
+		spec    *functions.AnomalyProcedureSpec
+		data    []query.Table
+		want    []*executetest.Table
+		wantErr error
+	}{
+		{
+			name: "mad",
+			spec: &functions.AnomalyProcedureSpec{
+				Method:        functions.AnomalyMADKind,
+				AnomalyConfig: functions.DefaultAnomalyConfig,
+			},
+			data: []query.Table{series(1.0, 2.0, nil, 3.0, 4.0, 100.0)},
+			want: []*executetest.Table{{
+				ColMeta: outCols,
+				Data: [][]interface{}{
+					{execute.Time(1), 1.0, false, 2 / 1.4826},
+					{execute.Time(2), 2.0, false, 1 / 1.4826},
+					{execute.Time(3), nil, nil, nil},
+					{execute.Time(4), 3.0, false, 0.0},
+					{execute.Time(5), 4.0, false, 1 / 1.4826},
+					{execute.Time(6), 100.0, true, 97 / 1.4826},
+				},
+			}},
+		},
+		{
+			name: "zscore with window",
+			spec: &functions.AnomalyProcedureSpec{
+				Method:        functions.AnomalyZScoreKind,
+				Window:        2,
+				AnomalyConfig: functions.DefaultAnomalyConfig,
+			},
+			data: []query.Table{series(1.0, 3.0, 5.0, 7.0, 20.0)},
+			want: []*executetest.Table{{
+				ColMeta: outCols,
+				Data: [][]interface{}{
+					{execute.Time(1), 1.0, nil, nil},
+					{execute.Time(2), 3.0, nil, nil},
+					{execute.Time(3), 5.0, false, 3.0},
+					{execute.Time(4), 7.0, false, 3.0},
+					{execute.Time(5), 20.0, true, 14.0},
+				},
+			}},
+		},
+		{
+			name: "zscore of table",
+			spec: &functions.AnomalyProcedureSpec{
+				Method: functions.AnomalyZScoreKind,
+				AnomalyConfig: functions.AnomalyConfig{
+					Threshold: 1,
+					Column:    "_value",
+					As:        "_anomaly",
+					Score:     "_score",
+				},
+			},
+			data: []query.Table{series(2.0, 4.0, 4.0, 4.0, 5.0, 5.0, 7.0, 9.0)},
+			want: []*executetest.Table{{
+				ColMeta: outCols,
+				Data: [][]interface{}{
+					{execute.Time(1), 2.0, true, 1.5},
+					{execute.Time(2), 4.0, false, 0.5},
+					{execute.Time(3), 4.0, false, 0.5},
+					{execute.Time(4), 4.0, false, 0.5},
+					{execute.Time(5), 5.0, false, 0.0},
+					{execute.Time(6), 5.0, false, 0.0},
+					{execute.Time(7), 7.0, false, 1.0},
+					{execute.Time(8), 9.0, true, 2.0},
+				},
+			}},
+		},
+		{
+			name: "seasonal",
+			spec: &functions.AnomalyProcedureSpec{
+				Method:        functions.AnomalySeasonalKind,
+				Seasonality:   2,
+				AnomalyConfig: functions.DefaultAnomalyConfig,
+			},
+			data: []query.Table{series(0.0, 10.0, 0.0, 10.0, 0.0, 40.0, 0.0, 10.0, 0.0, 10.0)},
+			want: []*executetest.Table{{
+				ColMeta: outCols,
+				Data: [][]interface{}{
+					{execute.Time(1), 0.0, false, 4 / 1.4826},
+					{execute.Time(2), 10.0, false, 0.0},
+					{execute.Time(3), 0.0, false, 4 / 1.4826},
+					{execute.Time(4), 10.0, false, 0.0},
+					{execute.Time(5), 0.0, false, 1 / 1.4826},
+					{execute.Time(6), 40.0, true, 10 / 1.4826},
+					{execute.Time(7), 0.0, false, 1 / 1.4826},
+					{execute.Time(8), 10.0, false, 0.0},
+					{execute.Time(9), 0.0, false, 4 / 1.4826},
+					{execute.Time(10), 10.0, false, 0.0},
+				},
+			}},
+		},
+		{
+			name: "seasonal with less than two seasons",
+			spec: &functions.AnomalyProcedureSpec{
+				Method:        functions.AnomalySeasonalKind,
+				Seasonality:   4,
+				AnomalyConfig: functions.DefaultAnomalyConfig,
+			},
+			data: []query.Table{series(1.0, 2.0, 3.0)},
+			want: []*executetest.Table{{
+				ColMeta: outCols,
+				Data: [][]interface{}{
+					{execute.Time(1), 1.0, nil, nil},
+					{execute.Time(2), 2.0, nil, nil},
+					{execute.Time(3), 3.0, nil, nil},
+				},
+			}},
+		},
+		{
+			name: "existing anomaly column",
+			spec: &functions.AnomalyProcedureSpec{
+				Method: functions.AnomalyMADKind,
+				AnomalyConfig: functions.AnomalyConfig{
+					Threshold: 3,
+					Column:    "_value",
+					As:        "_time",
+					Score:     "_score",
+				},
+			},
+			data:    []query.Table{series(1.0)},
+			want:    []*executetest.Table(nil),
+			wantErr: errors.New(`column "_time" already exists`),
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			executetest.ProcessTestHelper(
+				t,
+				tc.data,
+				tc.want,
+				tc.wantErr,
+				func(d execute.Dataset, c execute.TableBuilderCache) execute.Transformation {
+					return functions.NewAnomalyTransformation(d, c, executetest.UnlimitedAllocator, tc.spec)
+				},
+			)
+		})
+	}
+}
@@ -0,0 +1,351 @@
+package functions
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/execute"
+	"github.com/EMCECS/influx/query/interpreter"
+	"github.com/EMCECS/influx/query/plan"
+	"github.com/EMCECS/influx/query/semantic"
+)
+
+const AsofJoinKind = "asofJoin"
+
+// Directions in which an as-of join looks for the row of the right table.
+const (
+	AsofBackward = "backward"
+	AsofForward  = "forward"
+	AsofNearest  = "nearest"
+)
+
+// AsofJoinOpSpec joins each row of the left table with the row of the right table closest in time.
+type AsofJoinOpSpec struct {
+	// On is a list of columns that must be equal in the joined rows, in addition to the time.
+	On []string `json:"on"`
+	// TableNames are the names of the left and right tables, the tables are ordered as they are declared.
+	TableNames map[query.OperationID]string `json:"tableNames"`
+	// Tolerance is the maximum time difference between joined rows, zero means unlimited.
+	Tolerance query.Duration `json:"tolerance"`
+	// Direction is either backward, forward or nearest.
+	Direction string `json:"direction"`
+	// tableNames maps each TableObject being joined to the parameter that holds it.
+	tableNames map[*query.TableObject]string
+}
+
+var asofJoinSignature = semantic.FunctionSignature{
+	Params: map[string]semantic.Type{
+		"tables":    semantic.Object,
+		"on":        semantic.NewArrayType(semantic.String),
+		"tolerance": semantic.Duration,
+		"direction": semantic.String,
+	},
+	ReturnType:   query.TableObjectType,
+	PipeArgument: "tables",
+}
+
+func init() {
+	query.RegisterFunction(AsofJoinKind, createAsofJoinOpSpec, asofJoinSignature)
+	query.RegisterOpSpec(AsofJoinKind, newAsofJoinOp)
+	plan.RegisterProcedureSpec(AsofJoinKind, newAsofJoinProcedure, AsofJoinKind)
+	execute.RegisterTransformation(AsofJoinKind, createAsofJoinTransformation)
+}
+
+func createAsofJoinOpSpec(args query.Arguments, a *query.Administration) (query.OperationSpec, error) {
+	spec := &AsofJoinOpSpec{
+		TableNames: make(map[query.OperationID]string),
+		Direction:  AsofBackward,
+	}
+
+	if array, ok, err := args.GetArray("on", semantic.String); err != nil {
+		return nil, err
+	} else if ok {
+		spec.On, err = interpreter.ToStringArray(array)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if tolerance, ok, err := args.GetDuration("tolerance"); err != nil {
+		return nil, err
+	} else if ok {
+		if tolerance < 0 {
+			return nil, errors.New("tolerance must not be negative")
+		}
+		spec.Tolerance = tolerance
+	}
+
+	if direction, ok, err := args.GetString("direction"); err != nil {
+		return nil, err
+	} else if ok {
+		switch direction {
+		case AsofBackward, AsofForward, AsofNearest:
+			spec.Direction = direction
+		default:
+			return nil, fmt.Errorf("%s is not a valid as-of join direction", direction)
+		}
+	}
+
+	tableNames, err := addJoinParents(args, a)
+	if err != nil {
+		return nil, err
+	}
+	if len(tableNames) != 2 {
+		return nil, fmt.Errorf("asofJoin requires exactly two tables, got %d", len(tableNames))
+	}
+	spec.tableNames = tableNames
+
+	return spec, nil
+}
+
+func (s *AsofJoinOpSpec) IDer(ider query.IDer) {
+	for p, k := range s.tableNames {
+		s.TableNames[ider.ID(p)] = k
+	}
+}
+
+func newAsofJoinOp() query.OperationSpec {
+	return new(AsofJoinOpSpec)
+}
+
+func (s *AsofJoinOpSpec) Kind() query.OperationKind {
+	return AsofJoinKind
+}
+
+type AsofJoinProcedureSpec struct {
+	On         []string                    `json:"keys"`
+	TableNames map[plan.ProcedureID]string `json:"table_names"`
+	Tolerance  query.Duration              `json:"tolerance"`
+	Direction  string                      `json:"direction"`
+}
+
+func newAsofJoinProcedure(qs query.OperationSpec, pa plan.Administration) (plan.ProcedureSpec, error) {
+	spec, ok := qs.(*AsofJoinOpSpec)
+	if !ok {
+		return nil, fmt.Errorf("invalid spec type %T", qs)
+	}
+
+	p := &AsofJoinProcedureSpec{
+		On:         spec.On,
+		TableNames: procedureTableNames(spec.TableNames, pa),
+		Tolerance:  spec.Tolerance,
+		Direction:  spec.Direction,
+	}
+	sort.Strings(p.On)
+	return p, nil
+}
+
+func (s *AsofJoinProcedureSpec) Kind() plan.ProcedureKind {
+	return AsofJoinKind
+}
+func (s *AsofJoinProcedureSpec) Copy() plan.ProcedureSpec {
+	ns := *s
+
+	ns.On = make([]string, len(s.On))
+	copy(ns.On, s.On)
+
+	ns.TableNames = make(map[plan.ProcedureID]string, len(s.TableNames))
+	for id, name := range s.TableNames {
+		ns.TableNames[id] = name
+	}
+
+	return &ns
+}
+
+func (s *AsofJoinProcedureSpec) ParentChanged(old, new plan.ProcedureID) {
+	if v, ok := s.TableNames[old]; ok {
+		delete(s.TableNames, old)
+		s.TableNames[new] = v
+	}
+}
+
+func createAsofJoinTransformation(id execute.DatasetID, mode execute.AccumulationMode, spec plan.ProcedureSpec, a execute.Administration) (execute.Transformation, execute.Dataset, error) {
+	s, ok := spec.(*AsofJoinProcedureSpec)
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid spec type %T", spec)
+	}
+	parents := a.Parents()
+	if len(parents) != 2 {
+		return nil, nil, errors.New("as-of joins must have two parents")
+	}
+
+	cache := NewAsofJoinCache(a.Allocator(), parents, datasetTableNames(s.TableNames, a), s)
+	cache.SetSpiller(a.Spiller())
+	d := execute.NewDataset(id, mode, cache)
+	t := NewAsofJoinTransformation(d, cache, s, parents)
+	return t, d, nil
+}
+
+func NewAsofJoinTransformation(d execute.Dataset, cache *MergeJoinCache, spec *AsofJoinProcedureSpec, parents []execute.DatasetID) execute.Transformation {
+	return newMergeJoinTransformation(d, cache, spec.On, parents)
+}
+
+// asofJoin holds the parameters of an as-of join.
+type asofJoin struct {
+	timeCol   string
+	tolerance execute.Duration
+	direction string
+	// on holds the columns that must be equal in the joined rows, without the time column.
+	on map[string]bool
+}
+
+// NewAsofJoinCache constructs a MergeJoinCache that joins each row of the left table
+// with the row of the right table closest in time.
+// The tables are buffered as for a left join on the columns of the spec and the time column,
+// so the time column of the output is the time of the left rows.
+func NewAsofJoinCache(alloc *execute.Allocator, datasetIDs []execute.DatasetID, tableNames map[execute.DatasetID]string, spec *AsofJoinProcedureSpec) *MergeJoinCache {
+	asof := &asofJoin{
+		timeCol:   execute.DefaultTimeColLabel,
+		tolerance: execute.Duration(spec.Tolerance),
+		direction: spec.Direction,
+		on:        make(map[string]bool, len(spec.On)),
+	}
+	if asof.direction == "" {
+		asof.direction = AsofBackward
+	}
+	for _, k := range spec.On {
+		if k != asof.timeCol {
+			asof.on[k] = true
+		}
+	}
+
+	key := make([]string, 0, len(asof.on)+1)
+	for k := range asof.on {
+		key = append(key, k)
+	}
+	key = append(key, asof.timeCol)
+
+	c := NewMergeJoinCache(alloc, datasetIDs, tableNames, key, "left")
+	c.asof = asof
+	return c
+}
+
+// asofJoin joins the rows of the left table with the rows of the right table
+// having equal values for the join columns and the closest time.
+// Left rows without a right row within the tolerance are kept with null values for the right columns.
+func (c *MergeJoinCache) asofJoin(keys preJoinGroupKeys, tables []*joinInput) (query.Table, error) {
+	groupKey := c.postJoinGroupKey(keys)
+	builder := execute.NewColListTableBuilder(groupKey, c.alloc)
+	for _, column := range c.schema.columns {
+		builder.AddCol(column)
+	}
+
+	if tables[0] == nil {
+		return builder.Table()
+	}
+	on := c.sortCols()
+	left, err := newJoinReader(tables[0], on, c.asof.on, c.alloc)
+	if err != nil {
+		return nil, err
+	}
+	defer left.close()
+	var right *joinReader
+	if tables[1] != nil {
+		if right, err = newJoinReader(tables[1], on, c.asof.on, c.alloc); err != nil {
+			return nil, err
+		}
+		defer right.close()
+	}
+
+	readers := make([]*execute.ColListTable, 2)
+	appended := make([]bool, len(c.schema.columns))
+	for !left.set.Empty() {
+		// Move the right rows up to the rows with the same join columns as the left rows.
+		for right != nil && !right.set.Empty() && right.key.Less(left.key) {
+			if err := right.next(); err != nil {
+				return nil, err
+			}
+		}
+		matches := right != nil && !right.set.Empty() && right.key.Equal(left.key)
+
+		leftTimes, err := c.asofTimes(left.table)
+		if err != nil {
+			return nil, err
+		}
+		var rightTimes []execute.Time
+		readers[0], readers[1] = left.table, nil
+		if matches {
+			if rightTimes, err = c.asofTimes(right.table); err != nil {
+				return nil, err
+			}
+			readers[1] = right.table
+		}
+
+		for l := left.set.Start; l < left.set.Stop; l++ {
+			r := -1
+			if matches {
+				r = c.asofRow(leftTimes[l], rightTimes, right.set)
+			}
+			if r < 0 {
+				c.appendRow(builder, readers, []int{0}, []int{l}, appended)
+			} else {
+				c.appendRow(builder, readers, []int{0, 1}, []int{l, r}, appended)
+			}
+		}
+		if err := left.next(); err != nil {
+			return nil, err
+		}
+	}
+
+	return builder.Table()
+}
+
+// asofTimes returns the times of the rows of a table.
+func (c *MergeJoinCache) asofTimes(table *execute.ColListTable) ([]execute.Time, error) {
+	j := execute.ColIdx(c.asof.timeCol, table.Cols())
+	if j < 0 {
+		return nil, fmt.Errorf("as-of join: missing time column %q", c.asof.timeCol)
+	}
+	if typ := table.Cols()[j].Type; typ != query.TTime {
+		return nil, fmt.Errorf("as-of join: time column %q has type %v", c.asof.timeCol, typ)
+	}
+	return table.Times(j), nil
+}
+
+// asofRow returns the index of the right row closest in time to t in the given direction
+// within the tolerance, or -1 if there is none. The right rows of the set are sorted by time.
+func (c *MergeJoinCache) asofRow(t execute.Time, times []execute.Time, set subset) int {
+	// The index of the first right row at or after t.
+	i := set.Start + sort.Search(set.Stop-set.Start, func(i int) bool {
+		return times[set.Start+i] >= t
+	})
+
+	backward, forward := -1, -1
+	if i > set.Start {
+		backward = i - 1
+	}
+	if i < set.Stop {
+		forward = i
+		if times[i] == t {
+			// An exact match is the closest row in all directions.
+			backward = i
+		}
+	}
+
+	r := -1
+	switch c.asof.direction {
+	case AsofBackward:
+		r = backward
+	case AsofForward:
+		r = forward
+	case AsofNearest:
+		r = backward
+		if r < 0 || (forward >= 0 && times[forward]-t < t-times[backward]) {
+			r = forward
+		}
+	}
+	if r < 0 {
+		return -1
+	}
+
+	diff := t - times[r]
+	if diff < 0 {
+		diff = -diff
+	}
+	if c.asof.tolerance > 0 && execute.Duration(diff) > c.asof.tolerance {
+		return -1
+	}
+	return r
+}
@@ -0,0 +1,393 @@
+package functions_test
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/execute"
+	"github.com/EMCECS/influx/query/execute/executetest"
+	"github.com/EMCECS/influx/query/functions"
+	"github.com/EMCECS/influx/query/plan"
+	"github.com/EMCECS/influx/query/plan/plantest"
+	"github.com/EMCECS/influx/query/querytest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAsofJoin_NewQuery(t *testing.T) {
+	tests := []querytest.NewQueryTestCase{
+		{
+			Name: "as-of join",
+			Raw: `
+				a = from(bucket:"dbA")
+				b = from(bucket:"dbB")
+				asofJoin(tables:{a:a,b:b}, on:["host"], tolerance:1s, direction:"nearest")`,
+			Want: &query.Spec{
+				Operations: []*query.Operation{
+					{
+						ID: "from0",
+						Spec: &functions.FromOpSpec{
+							Bucket: "dbA",
+						},
+					},
+					{
+						ID: "from1",
+						Spec: &functions.FromOpSpec{
+							Bucket: "dbB",
+						},
+					},
+					{
+						ID: "asofJoin2",
+						Spec: &functions.AsofJoinOpSpec{
+							On:         []string{"host"},
+							TableNames: map[query.OperationID]string{"from0": "a", "from1": "b"},
+							Tolerance:  query.Duration(time.Second),
+							Direction:  "nearest",
+						},
+					},
+				},
+				Edges: []query.Edge{
+					{Parent: "from0", Child: "asofJoin2"},
+					{Parent: "from1", Child: "asofJoin2"},
+				},
+			},
+		},
+		{
+			Name: "as-of join defaults",
+			Raw: `
+				a = from(bucket:"dbA")
+				b = from(bucket:"dbB")
+				asofJoin(tables:{a:a,b:b})`,
+			Want: &query.Spec{
+				Operations: []*query.Operation{
+					{
+						ID: "from0",
+						Spec: &functions.FromOpSpec{
+							Bucket: "dbA",
+						},
+					},
+					{
+						ID: "from1",
+						Spec: &functions.FromOpSpec{
+							Bucket: "dbB",
+						},
+					},
+					{
+						ID: "asofJoin2",
+						Spec: &functions.AsofJoinOpSpec{
+							TableNames: map[query.OperationID]string{"from0": "a", "from1": "b"},
+							Direction:  "backward",
+						},
+					},
+				},
+				Edges: []query.Edge{
+					{Parent: "from0", Child: "asofJoin2"},
+					{Parent: "from1", Child: "asofJoin2"},
+				},
+			},
+		},
+		{
+			Name: "as-of join with unknown direction",
+			Raw: `
+				a = from(bucket:"dbA")
+				b = from(bucket:"dbB")
+				asofJoin(tables:{a:a,b:b}, direction:"sideways")`,
+			WantErr: true,
+		},
+		{
+			Name: "as-of join with three tables",
+			Raw: `
+				a = from(bucket:"dbA")
+				b = from(bucket:"dbB")
+				c = from(bucket:"dbC")
+				asofJoin(tables:{a:a,b:b,c:c})`,
+			WantErr: true,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			querytest.NewQueryTestHelper(t, tc)
+		})
+	}
+}
+
+func TestAsofJoinOperation_Marshaling(t *testing.T) {
+	data := []byte(`{
+		"id":"asofJoin",
+		"kind":"asofJoin",
+		"spec":{
+			"on":["host"],
+			"tableNames":{"a0":"a","b1":"b"},
+			"tolerance":"5s",
+			"direction":"forward"
+		}
+	}`)
+	op := &query.Operation{
+		ID: "asofJoin",
+		Spec: &functions.AsofJoinOpSpec{
+			On:         []string{"host"},
+			TableNames: map[query.OperationID]string{"a0": "a", "b1": "b"},
+			Tolerance:  query.Duration(5 * time.Second),
+			Direction:  "forward",
+		},
+	}
+	querytest.OperationMarshalingTestHelper(t, data, op)
+}
+
+func TestAsofJoin_Process(t *testing.T) {
+	parentID0 := plantest.RandomProcedureID()
+	parentID1 := plantest.RandomProcedureID()
+	tableNames := map[plan.ProcedureID]string{
+		parentID0: "a",
+		parentID1: "b",
+	}
+	left := []*executetest.Table{
+		{
+			ColMeta: []query.ColMeta{
+				{Label: "_time", Type: query.TTime},
+				{Label: "_value", Type: query.TFloat},
+			},
+			Data: [][]interface{}{
+				{execute.Time(10), 1.0},
+				{execute.Time(20), 2.0},
+				{execute.Time(30), 3.0},
+			},
+		},
+	}
+	right := []*executetest.Table{
+		{
+			ColMeta: []query.ColMeta{
+				{Label: "_time", Type: query.TTime},
+				{Label: "_value", Type: query.TFloat},
+			},
+			Data: [][]interface{}{
+				{execute.Time(4), 10.0},
+				{execute.Time(12), 20.0},
+				{execute.Time(30), 30.0},
+			},
+		},
+	}
+	cols := []query.ColMeta{
+		{Label: "_time", Type: query.TTime},
+		{Label: "a__value", Type: query.TFloat},
+		{Label: "b__value", Type: query.TFloat},
+	}
+	testCases := []struct {
+		name  string
+		spec  *functions.AsofJoinProcedureSpec
+		data0 []*executetest.Table // data from parent 0
+		data1 []*executetest.Table // data from parent 1
+		want  []*executetest.Table
+	}{
+		{
+			name: "backward",
+			spec: &functions.AsofJoinProcedureSpec{
+				TableNames: tableNames,
+				Direction:  "backward",
+			},
+			data0: left,
+			data1: right,
+			want: []*executetest.Table{
+				{
+					ColMeta: cols,
+					Data: [][]interface{}{
+						{execute.Time(10), 1.0, 10.0},
+						{execute.Time(20), 2.0, 20.0},
+						{execute.Time(30), 3.0, 30.0},
+					},
+				},
+			},
+		},
+		{
+			name: "forward",
+			spec: &functions.AsofJoinProcedureSpec{
+				TableNames: tableNames,
+				Direction:  "forward",
+			},
+			data0: left,
+			data1: right,
+			want: []*executetest.Table{
+				{
+					ColMeta: cols,
+					Data: [][]interface{}{
+						{execute.Time(10), 1.0, 20.0},
+						{execute.Time(20), 2.0, 30.0},
+						{execute.Time(30), 3.0, 30.0},
+					},
+				},
+			},
+		},
+		{
+			name: "nearest",
+			spec: &functions.AsofJoinProcedureSpec{
+				TableNames: tableNames,
+				Direction:  "nearest",
+			},
+			data0: left,
+			data1: right,
+			want: []*executetest.Table{
+				{
+					ColMeta: cols,
+					Data: [][]interface{}{
+						{execute.Time(10), 1.0, 20.0},
+						{execute.Time(20), 2.0, 20.0},
+						{execute.Time(30), 3.0, 30.0},
+					},
+				},
+			},
+		},
+		{
+			name: "backward with tolerance",
+			spec: &functions.AsofJoinProcedureSpec{
+				TableNames: tableNames,
+				Direction:  "backward",
+				Tolerance:  5,
+			},
+			data0: left,
+			data1: right,
+			want: []*executetest.Table{
+				{
+					ColMeta: cols,
+					Data: [][]interface{}{
+						{execute.Time(10), 1.0, nil},
+						{execute.Time(20), 2.0, nil},
+						{execute.Time(30), 3.0, 30.0},
+					},
+				},
+			},
+		},
+		{
+			name: "on tags",
+			spec: &functions.AsofJoinProcedureSpec{
+				On:         []string{"host"},
+				TableNames: tableNames,
+				Direction:  "backward",
+			},
+			data0: []*executetest.Table{
+				{
+					KeyCols: []string{"host"},
+					ColMeta: []query.ColMeta{
+						{Label: "_time", Type: query.TTime},
+						{Label: "_value", Type: query.TFloat},
+						{Label: "host", Type: query.TString},
+					},
+					Data: [][]interface{}{
+						{execute.Time(10), 1.0, "A"},
+						{execute.Time(20), 2.0, "A"},
+					},
+				},
+				{
+					KeyCols: []string{"host"},
+					ColMeta: []query.ColMeta{
+						{Label: "_time", Type: query.TTime},
+						{Label: "_value", Type: query.TFloat},
+						{Label: "host", Type: query.TString},
+					},
+					Data: [][]interface{}{
+						{execute.Time(10), 3.0, "B"},
+					},
+				},
+			},
+			data1: []*executetest.Table{
+				{
+					KeyCols: []string{"host"},
+					ColMeta: []query.ColMeta{
+						{Label: "_time", Type: query.TTime},
+						{Label: "_value", Type: query.TFloat},
+						{Label: "host", Type: query.TString},
+					},
+					Data: [][]interface{}{
+						{execute.Time(5), 10.0, "A"},
+						{execute.Time(15), 20.0, "A"},
+					},
+				},
+				{
+					KeyCols: []string{"host"},
+					ColMeta: []query.ColMeta{
+						{Label: "_time", Type: query.TTime},
+						{Label: "_value", Type: query.TFloat},
+						{Label: "host", Type: query.TString},
+					},
+					Data: [][]interface{}{
+						{execute.Time(5), 30.0, "C"},
+					},
+				},
+			},
+			want: []*executetest.Table{
+				{
+					KeyCols: []string{"host"},
+					ColMeta: []query.ColMeta{
+						{Label: "_time", Type: query.TTime},
+						{Label: "a__value", Type: query.TFloat},
+						{Label: "b__value", Type: query.TFloat},
+						{Label: "host", Type: query.TString},
+					},
+					Data: [][]interface{}{
+						{execute.Time(10), 1.0, 10.0, "A"},
+						{execute.Time(20), 2.0, 20.0, "A"},
+					},
+				},
+				{
+					KeyCols: []string{"host"},
+					ColMeta: []query.ColMeta{
+						{Label: "_time", Type: query.TTime},
+						{Label: "a__value", Type: query.TFloat},
+						{Label: "b__value", Type: query.TFloat},
+						{Label: "host", Type: query.TString},
+					},
+					Data: [][]interface{}{
+						{execute.Time(10), 3.0, nil, "B"},
+					},
+				},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			parents := []execute.DatasetID{execute.DatasetID(parentID0), execute.DatasetID(parentID1)}
+
+			tableNames := make(map[execute.DatasetID]string, len(tc.spec.TableNames))
+			for pid, name := range tc.spec.TableNames {
+				tableNames[execute.DatasetID(pid)] = name
+			}
+
+			d := executetest.NewDataset(executetest.RandomDatasetID())
+			c := functions.NewAsofJoinCache(executetest.UnlimitedAllocator, parents, tableNames, tc.spec)
+			c.SetTriggerSpec(execute.DefaultTriggerSpec)
+			jt := functions.NewAsofJoinTransformation(d, c, tc.spec, parents)
+
+			for _, tbl := range tc.data0 {
+				if err := jt.Process(parents[0], tbl); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, tbl := range tc.data1 {
+				if err := jt.Process(parents[1], tbl); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, id := range parents {
+				jt.Finish(id, nil)
+			}
+
+			got, err := executetest.TablesFromCache(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			executetest.NormalizeTables(got)
+			executetest.NormalizeTables(tc.want)
+
+			sort.Sort(executetest.SortedTables(got))
+			sort.Sort(executetest.SortedTables(tc.want))
+
+			if !cmp.Equal(tc.want, got) {
+				t.Errorf("unexpected tables -want/+got\n%s", cmp.Diff(tc.want, got))
+			}
+		})
+	}
+}
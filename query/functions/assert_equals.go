@@ -0,0 +1,65 @@
+package functions
+
+import (
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/semantic"
+)
+
+const AssertEqualsKind = "assertEquals"
+
+// AssertEqualsOpSpec fails the query if the tables of two streams are not equal.
+type AssertEqualsOpSpec struct {
+	Name string            `json:"name"`
+	Got  query.OperationID `json:"got"`
+	Want query.OperationID `json:"want"`
+
+	got, want *query.TableObject
+}
+
+var assertEqualsSignature = semantic.FunctionSignature{
+	Params: map[string]semantic.Type{
+		"name": semantic.String,
+		"got":  query.TableObjectType,
+		"want": query.TableObjectType,
+	},
+	ReturnType:   query.TableObjectType,
+	PipeArgument: "got",
+}
+
+func init() {
+	// Assertions are side effects so that they are executed without an explicit yield.
+	query.RegisterFunctionWithSideEffect(AssertEqualsKind, createAssertEqualsOpSpec, assertEqualsSignature)
+	query.RegisterOpSpec(AssertEqualsKind, newAssertEqualsOp)
+	// The procedure for assertEquals is the diff procedure, see diff.go.
+}
+
+func createAssertEqualsOpSpec(args query.Arguments, a *query.Administration) (query.OperationSpec, error) {
+	name, ok, err := args.GetString("name")
+	if err != nil {
+		return nil, err
+	} else if !ok {
+		name = AssertEqualsKind
+	}
+	got, want, err := diffParents(args, a)
+	if err != nil {
+		return nil, err
+	}
+	return &AssertEqualsOpSpec{
+		Name: name,
+		got:  got,
+		want: want,
+	}, nil
+}
+
+func (s *AssertEqualsOpSpec) IDer(ider query.IDer) {
+	s.Got = ider.ID(s.got)
+	s.Want = ider.ID(s.want)
+}
+
+func newAssertEqualsOp() query.OperationSpec {
+	return new(AssertEqualsOpSpec)
+}
+
+func (s *AssertEqualsOpSpec) Kind() query.OperationKind {
+	return AssertEqualsKind
+}
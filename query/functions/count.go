@@ -146,6 +146,9 @@ func (a *CountAgg) DoString(vs []string) {
 func (a *CountAgg) Type() query.DataType {
 	return query.TInt
 }
+func (a *CountAgg) HasEmptyValue() bool {
+	return true
+}
 func (a *CountAgg) ValueInt() int64 {
 	return a.count
 }
@@ -0,0 +1,445 @@
+package functions
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/execute"
+	"github.com/EMCECS/influx/query/plan"
+	"github.com/EMCECS/influx/query/semantic"
+	"github.com/EMCECS/influx/query/values"
+	"github.com/pkg/errors"
+)
+
+const DiffKind = "diff"
+
+// DiffColumn is the column added by diff to mark whether a row is only in the wanted ("-") or the actual ("+") tables.
+const DiffColumn = "_diff"
+
+// DiffOpSpec compares the tables of two streams.
+type DiffOpSpec struct {
+	Got  query.OperationID `json:"got"`
+	Want query.OperationID `json:"want"`
+
+	got, want *query.TableObject
+}
+
+var diffSignature = semantic.FunctionSignature{
+	Params: map[string]semantic.Type{
+		"got":  query.TableObjectType,
+		"want": query.TableObjectType,
+	},
+	ReturnType:   query.TableObjectType,
+	PipeArgument: "got",
+}
+
+func init() {
+	query.RegisterFunction(DiffKind, createDiffOpSpec, diffSignature)
+	query.RegisterOpSpec(DiffKind, newDiffOp)
+	plan.RegisterProcedureSpec(DiffKind, newDiffProcedure, DiffKind, AssertEqualsKind)
+	execute.RegisterTransformation(DiffKind, createDiffTransformation)
+}
+
+func createDiffOpSpec(args query.Arguments, a *query.Administration) (query.OperationSpec, error) {
+	got, want, err := diffParents(args, a)
+	if err != nil {
+		return nil, err
+	}
+	return &DiffOpSpec{
+		got:  got,
+		want: want,
+	}, nil
+}
+
+// diffParents reads the got and want tables from the arguments and adds them as parents.
+func diffParents(args query.Arguments, a *query.Administration) (got, want *query.TableObject, err error) {
+	for _, name := range []string{"got", "want"} {
+		v, err := args.GetRequiredObject(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		t, ok := v.(*query.TableObject)
+		if !ok {
+			return nil, nil, fmt.Errorf("argument %q is not a table object: got %T", name, v)
+		}
+		if name == "got" {
+			got = t
+		} else {
+			want = t
+		}
+	}
+	if got == want {
+		return nil, nil, errors.New("got and want must be different streams")
+	}
+	a.AddParent(got)
+	a.AddParent(want)
+	return got, want, nil
+}
+
+func (s *DiffOpSpec) IDer(ider query.IDer) {
+	s.Got = ider.ID(s.got)
+	s.Want = ider.ID(s.want)
+}
+
+func newDiffOp() query.OperationSpec {
+	return new(DiffOpSpec)
+}
+
+func (s *DiffOpSpec) Kind() query.OperationKind {
+	return DiffKind
+}
+
+// DiffProcedureSpec compares the tables of two parents.
+// If Assert is set the procedure fails when the tables differ instead of producing the differences.
+type DiffProcedureSpec struct {
+	Got  plan.ProcedureID `json:"got"`
+	Want plan.ProcedureID `json:"want"`
+
+	Assert bool   `json:"assert"`
+	Name   string `json:"name"`
+}
+
+func newDiffProcedure(qs query.OperationSpec, pa plan.Administration) (plan.ProcedureSpec, error) {
+	switch spec := qs.(type) {
+	case *DiffOpSpec:
+		return &DiffProcedureSpec{
+			Got:  pa.ConvertID(spec.Got),
+			Want: pa.ConvertID(spec.Want),
+		}, nil
+	case *AssertEqualsOpSpec:
+		return &DiffProcedureSpec{
+			Got:    pa.ConvertID(spec.Got),
+			Want:   pa.ConvertID(spec.Want),
+			Assert: true,
+			Name:   spec.Name,
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid spec type %T", qs)
+	}
+}
+
+func (s *DiffProcedureSpec) Kind() plan.ProcedureKind {
+	return DiffKind
+}
+
+func (s *DiffProcedureSpec) Copy() plan.ProcedureSpec {
+	ns := *s
+	return &ns
+}
+
+func (s *DiffProcedureSpec) ParentChanged(old, new plan.ProcedureID) {
+	if s.Got == old {
+		s.Got = new
+	}
+	if s.Want == old {
+		s.Want = new
+	}
+}
+
+// TimeBounds reports unlimited bounds so that tables read without a range, such as inline CSV, can be compared.
+// Any bounds of the parents still apply since the bounds are intersected.
+func (s *DiffProcedureSpec) TimeBounds() query.Bounds {
+	return query.Bounds{
+		Start: query.MinTime,
+		Stop:  query.MaxTime,
+	}
+}
+
+// AssertionError is reported when the tables passed to assertEquals are not equal.
+type AssertionError struct {
+	Name string
+	// Diff is the human readable difference between the tables.
+	Diff string
+}
+
+func (e *AssertionError) Error() string {
+	return fmt.Sprintf("test %s: tables are not equal\n%s", e.Name, e.Diff)
+}
+
+func createDiffTransformation(id execute.DatasetID, mode execute.AccumulationMode, spec plan.ProcedureSpec, a execute.Administration) (execute.Transformation, execute.Dataset, error) {
+	s, ok := spec.(*DiffProcedureSpec)
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid spec type %T", spec)
+	}
+	cache := execute.NewTableBuilderCache(a.Allocator())
+	d := execute.NewDataset(id, mode, cache)
+	t := NewDiffTransformation(d, cache, a.Allocator(), s, a.ConvertID(s.Got), a.ConvertID(s.Want))
+	return t, d, nil
+}
+
+type diffTransformation struct {
+	mu sync.Mutex
+
+	d     execute.Dataset
+	cache execute.TableBuilderCache
+	alloc *execute.Allocator
+
+	assert bool
+	name   string
+
+	gotID, wantID execute.DatasetID
+	tables        map[execute.DatasetID]*execute.GroupLookup
+	parentState   map[execute.DatasetID]*diffParentState
+	err           error
+}
+
+type diffParentState struct {
+	mark       execute.Time
+	processing execute.Time
+	finished   bool
+}
+
+// bufferedTable holds the rows of a table read from one of the parents.
+type bufferedTable struct {
+	key  query.GroupKey
+	cols []query.ColMeta
+	rows [][]values.Value
+}
+
+func NewDiffTransformation(d execute.Dataset, cache execute.TableBuilderCache, alloc *execute.Allocator, spec *DiffProcedureSpec, gotID, wantID execute.DatasetID) *diffTransformation {
+	return &diffTransformation{
+		d:      d,
+		cache:  cache,
+		alloc:  alloc,
+		assert: spec.Assert,
+		name:   spec.Name,
+		gotID:  gotID,
+		wantID: wantID,
+		tables: map[execute.DatasetID]*execute.GroupLookup{
+			gotID:  execute.NewGroupLookup(),
+			wantID: execute.NewGroupLookup(),
+		},
+		parentState: map[execute.DatasetID]*diffParentState{
+			gotID:  new(diffParentState),
+			wantID: new(diffParentState),
+		},
+	}
+}
+
+func (t *diffTransformation) RetractTable(id execute.DatasetID, key query.GroupKey) error {
+	panic("not implemented")
+}
+
+func (t *diffTransformation) Process(id execute.DatasetID, tbl query.Table) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	lookup, ok := t.tables[id]
+	if !ok {
+		return fmt.Errorf("unexpected parent %v", id)
+	}
+	var buf *bufferedTable
+	if v, ok := lookup.Lookup(tbl.Key()); ok {
+		buf = v.(*bufferedTable)
+	} else {
+		buf = &bufferedTable{
+			key:  tbl.Key(),
+			cols: tbl.Cols(),
+		}
+		lookup.Set(tbl.Key(), buf)
+	}
+	if !execute.EqualCols(buf.cols, tbl.Cols()) {
+		return fmt.Errorf("tables with group key %v have different columns", tbl.Key())
+	}
+	return tbl.Do(func(cr query.ColReader) error {
+		for i := 0; i < cr.Len(); i++ {
+			row := make([]values.Value, len(buf.cols))
+			for j := range buf.cols {
+				row[j] = execute.ValueForRow(i, j, cr)
+			}
+			buf.rows = append(buf.rows, row)
+		}
+		return nil
+	})
+}
+
+func (t *diffTransformation) UpdateWatermark(id execute.DatasetID, mark execute.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.parentState[id].mark = mark
+
+	min := execute.Time(math.MaxInt64)
+	for _, state := range t.parentState {
+		if state.mark < min {
+			min = state.mark
+		}
+	}
+	return t.d.UpdateWatermark(min)
+}
+
+func (t *diffTransformation) UpdateProcessingTime(id execute.DatasetID, pt execute.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.parentState[id].processing = pt
+
+	min := execute.Time(math.MaxInt64)
+	for _, state := range t.parentState {
+		if state.processing < min {
+			min = state.processing
+		}
+	}
+	return t.d.UpdateProcessingTime(min)
+}
+
+func (t *diffTransformation) Finish(id execute.DatasetID, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err != nil && t.err == nil {
+		t.err = err
+	}
+	t.parentState[id].finished = true
+	for _, state := range t.parentState {
+		if !state.finished {
+			return
+		}
+	}
+	if t.err != nil {
+		t.d.Finish(t.err)
+		return
+	}
+	t.d.Finish(t.compare())
+}
+
+// compare compares the buffered tables of both parents once they have finished.
+// Differences are appended to the output tables, or reported as an error when asserting.
+func (t *diffTransformation) compare() error {
+	got, want := t.tables[t.gotID], t.tables[t.wantID]
+
+	var diffs []*bufferedTable
+	var err error
+	want.Range(func(key query.GroupKey, v interface{}) {
+		if err != nil {
+			return
+		}
+		w := v.(*bufferedTable)
+		var g *bufferedTable
+		if v, ok := got.Lookup(key); ok {
+			g = v.(*bufferedTable)
+		}
+		var d *bufferedTable
+		d, err = diffTables(w, g)
+		if d != nil {
+			diffs = append(diffs, d)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	got.Range(func(key query.GroupKey, v interface{}) {
+		if _, ok := want.Lookup(key); ok {
+			return
+		}
+		d, _ := diffTables(nil, v.(*bufferedTable))
+		diffs = append(diffs, d)
+	})
+
+	if !t.assert {
+		for _, d := range diffs {
+			t.appendTable(d)
+		}
+		return nil
+	}
+	if len(diffs) > 0 {
+		var buf bytes.Buffer
+		for _, d := range diffs {
+			builder := execute.NewColListTableBuilder(d.key, t.alloc)
+			appendRows(d, builder)
+			tbl, _ := builder.Table()
+			if _, err := execute.NewFormatter(tbl, nil).WriteTo(&buf); err != nil {
+				return err
+			}
+		}
+		return &AssertionError{Name: t.name, Diff: buf.String()}
+	}
+	// The tables are equal, pass them on unchanged.
+	got.Range(func(key query.GroupKey, v interface{}) {
+		t.appendTable(v.(*bufferedTable))
+	})
+	return nil
+}
+
+// appendTable appends the buffered rows to the output table with the same group key.
+func (t *diffTransformation) appendTable(buf *bufferedTable) {
+	builder, _ := t.cache.TableBuilder(buf.key)
+	appendRows(buf, builder)
+}
+
+func appendRows(buf *bufferedTable, builder execute.TableBuilder) {
+	if builder.NCols() == 0 {
+		for _, c := range buf.cols {
+			builder.AddCol(c)
+		}
+	}
+	for _, row := range buf.rows {
+		for j, v := range row {
+			execute.AppendValue(builder, j, v)
+		}
+	}
+}
+
+// diffTables returns the rows that differ between the wanted and the actual table, or nil if the tables are equal.
+// Either table may be nil if no table with the group key exists in the stream.
+func diffTables(want, got *bufferedTable) (*bufferedTable, error) {
+	if want != nil && got != nil && !execute.EqualCols(want.cols, got.cols) {
+		return nil, fmt.Errorf("tables with group key %v have different columns: want %s got %s", want.key, formatCols(want.cols), formatCols(got.cols))
+	}
+	template := want
+	if template == nil {
+		template = got
+	}
+	d := &bufferedTable{
+		key:  template.key,
+		cols: append([]query.ColMeta{{Label: DiffColumn, Type: query.TString}}, template.cols...),
+	}
+	add := func(marker string, row []values.Value) {
+		d.rows = append(d.rows, append([]values.Value{values.NewStringValue(marker)}, row...))
+	}
+
+	var wantRows, gotRows [][]values.Value
+	if want != nil {
+		wantRows = want.rows
+	}
+	if got != nil {
+		gotRows = got.rows
+	}
+	for i := 0; i < len(wantRows) || i < len(gotRows); i++ {
+		switch {
+		case i >= len(gotRows):
+			add("-", wantRows[i])
+		case i >= len(wantRows):
+			add("+", gotRows[i])
+		case !equalRows(wantRows[i], gotRows[i]):
+			add("-", wantRows[i])
+			add("+", gotRows[i])
+		}
+	}
+	// A table that exists in only one of the streams is a difference even when it is empty.
+	if len(d.rows) == 0 && want != nil && got != nil {
+		return nil, nil
+	}
+	return d, nil
+}
+
+func equalRows(a, b []values.Value) bool {
+	for j := range a {
+		if !a[j].Equal(b[j]) {
+			return false
+		}
+	}
+	return true
+}
+
+func formatCols(cols []query.ColMeta) string {
+	s := make([]string, len(cols))
+	for i, c := range cols {
+		s[i] = c.Label + ":" + c.Type.String()
+	}
+	return "[" + strings.Join(s, ", ") + "]"
+}
@@ -0,0 +1,314 @@
+package functions_test
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/execute"
+	"github.com/EMCECS/influx/query/execute/executetest"
+	"github.com/EMCECS/influx/query/functions"
+	"github.com/EMCECS/influx/query/plan/plantest"
+	"github.com/EMCECS/influx/query/querytest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDiff_NewQuery(t *testing.T) {
+	tests := []querytest.NewQueryTestCase{
+		{
+			Name: "diff",
+			Raw: `
+				want = fromCSV(csv: "a")
+				fromCSV(csv: "b") |> diff(want: want)`,
+			Want: &query.Spec{
+				Operations: []*query.Operation{
+					{
+						ID:   "fromCSV0",
+						Spec: &functions.FromCSVOpSpec{CSV: "a"},
+					},
+					{
+						ID:   "fromCSV1",
+						Spec: &functions.FromCSVOpSpec{CSV: "b"},
+					},
+					{
+						ID: "diff2",
+						Spec: &functions.DiffOpSpec{
+							Got:  "fromCSV1",
+							Want: "fromCSV0",
+						},
+					},
+				},
+				Edges: []query.Edge{
+					{Parent: "fromCSV1", Child: "diff2"},
+					{Parent: "fromCSV0", Child: "diff2"},
+				},
+			},
+		},
+		{
+			Name: "assertEquals",
+			Raw: `
+				want = fromCSV(csv: "a")
+				fromCSV(csv: "b") |> assertEquals(name: "test", want: want)`,
+			Want: &query.Spec{
+				Operations: []*query.Operation{
+					{
+						ID:   "fromCSV0",
+						Spec: &functions.FromCSVOpSpec{CSV: "a"},
+					},
+					{
+						ID:   "fromCSV1",
+						Spec: &functions.FromCSVOpSpec{CSV: "b"},
+					},
+					{
+						ID: "assertEquals2",
+						Spec: &functions.AssertEqualsOpSpec{
+							Name: "test",
+							Got:  "fromCSV1",
+							Want: "fromCSV0",
+						},
+					},
+				},
+				Edges: []query.Edge{
+					{Parent: "fromCSV1", Child: "assertEquals2"},
+					{Parent: "fromCSV0", Child: "assertEquals2"},
+				},
+			},
+		},
+		{
+			Name: "assertEquals without name",
+			Raw:  `fromCSV(csv: "b") |> assertEquals(want: fromCSV(csv: "a"))`,
+			Want: &query.Spec{
+				Operations: []*query.Operation{
+					{
+						ID:   "fromCSV0",
+						Spec: &functions.FromCSVOpSpec{CSV: "b"},
+					},
+					{
+						ID:   "fromCSV1",
+						Spec: &functions.FromCSVOpSpec{CSV: "a"},
+					},
+					{
+						ID: "assertEquals2",
+						Spec: &functions.AssertEqualsOpSpec{
+							Name: "assertEquals",
+							Got:  "fromCSV0",
+							Want: "fromCSV1",
+						},
+					},
+				},
+				Edges: []query.Edge{
+					{Parent: "fromCSV0", Child: "assertEquals2"},
+					{Parent: "fromCSV1", Child: "assertEquals2"},
+				},
+			},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			querytest.NewQueryTestHelper(t, tc)
+		})
+	}
+}
+
+func TestDiff_Process(t *testing.T) {
+	gotID := executetest.RandomDatasetID()
+	wantID := executetest.RandomDatasetID()
+	cols := []query.ColMeta{
+		{Label: "_time", Type: query.TTime},
+		{Label: "t0", Type: query.TString},
+		{Label: "_value", Type: query.TFloat},
+	}
+	diffCols := append([]query.ColMeta{{Label: "_diff", Type: query.TString}}, cols...)
+
+	testCases := []struct {
+		name   string
+		assert bool
+		got    []*executetest.Table
+		want   []*executetest.Table
+		result []*executetest.Table
+		// failure is the assertion error, empty if the assertion succeeds.
+		failure bool
+	}{
+		{
+			name: "equal",
+			got: []*executetest.Table{{
+				KeyCols: []string{"t0"},
+				ColMeta: cols,
+				Data: [][]interface{}{
+					{execute.Time(1), "a", 1.0},
+					{execute.Time(2), "a", 2.0},
+				},
+			}},
+			want: []*executetest.Table{{
+				KeyCols: []string{"t0"},
+				ColMeta: cols,
+				Data: [][]interface{}{
+					{execute.Time(1), "a", 1.0},
+					{execute.Time(2), "a", 2.0},
+				},
+			}},
+			result: []*executetest.Table(nil),
+		},
+		{
+			name: "different values",
+			got: []*executetest.Table{{
+				KeyCols: []string{"t0"},
+				ColMeta: cols,
+				Data: [][]interface{}{
+					{execute.Time(1), "a", 1.0},
+					{execute.Time(2), "a", 2.5},
+					{execute.Time(3), "a", 3.0},
+				},
+			}},
+			want: []*executetest.Table{{
+				KeyCols: []string{"t0"},
+				ColMeta: cols,
+				Data: [][]interface{}{
+					{execute.Time(1), "a", 1.0},
+					{execute.Time(2), "a", 2.0},
+				},
+			}},
+			result: []*executetest.Table{{
+				KeyCols: []string{"t0"},
+				ColMeta: diffCols,
+				Data: [][]interface{}{
+					{"-", execute.Time(2), "a", 2.0},
+					{"+", execute.Time(2), "a", 2.5},
+					{"+", execute.Time(3), "a", 3.0},
+				},
+			}},
+		},
+		{
+			name: "missing table",
+			got: []*executetest.Table{{
+				KeyCols: []string{"t0"},
+				ColMeta: cols,
+				Data: [][]interface{}{
+					{execute.Time(1), "a", 1.0},
+				},
+			}},
+			want: []*executetest.Table{
+				{
+					KeyCols: []string{"t0"},
+					ColMeta: cols,
+					Data: [][]interface{}{
+						{execute.Time(1), "a", 1.0},
+					},
+				},
+				{
+					KeyCols: []string{"t0"},
+					ColMeta: cols,
+					Data: [][]interface{}{
+						{execute.Time(1), "b", 1.0},
+					},
+				},
+			},
+			result: []*executetest.Table{{
+				KeyCols: []string{"t0"},
+				ColMeta: diffCols,
+				Data: [][]interface{}{
+					{"-", execute.Time(1), "b", 1.0},
+				},
+			}},
+		},
+		{
+			name:   "assert equal",
+			assert: true,
+			got: []*executetest.Table{{
+				KeyCols: []string{"t0"},
+				ColMeta: cols,
+				Data: [][]interface{}{
+					{execute.Time(1), "a", 1.0},
+				},
+			}},
+			want: []*executetest.Table{{
+				KeyCols: []string{"t0"},
+				ColMeta: cols,
+				Data: [][]interface{}{
+					{execute.Time(1), "a", 1.0},
+				},
+			}},
+			result: []*executetest.Table{{
+				KeyCols: []string{"t0"},
+				ColMeta: cols,
+				Data: [][]interface{}{
+					{execute.Time(1), "a", 1.0},
+				},
+			}},
+		},
+		{
+			name:   "assert not equal",
+			assert: true,
+			got: []*executetest.Table{{
+				KeyCols: []string{"t0"},
+				ColMeta: cols,
+				Data: [][]interface{}{
+					{execute.Time(1), "a", 1.0},
+				},
+			}},
+			want: []*executetest.Table{{
+				KeyCols: []string{"t0"},
+				ColMeta: cols,
+				Data: [][]interface{}{
+					{execute.Time(1), "a", 2.0},
+				},
+			}},
+			result:  []*executetest.Table(nil),
+			failure: true,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			spec := &functions.DiffProcedureSpec{
+				Got:    plantest.RandomProcedureID(),
+				Want:   plantest.RandomProcedureID(),
+				Assert: tc.assert,
+				Name:   tc.name,
+			}
+			d := executetest.NewDataset(executetest.RandomDatasetID())
+			c := execute.NewTableBuilderCache(executetest.UnlimitedAllocator)
+			c.SetTriggerSpec(execute.DefaultTriggerSpec)
+			dt := functions.NewDiffTransformation(d, c, executetest.UnlimitedAllocator, spec, gotID, wantID)
+
+			for _, tbl := range tc.want {
+				if err := dt.Process(wantID, tbl); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, tbl := range tc.got {
+				if err := dt.Process(gotID, tbl); err != nil {
+					t.Fatal(err)
+				}
+			}
+			dt.Finish(wantID, nil)
+			if d.Finished {
+				t.Fatal("finished before all parents finished")
+			}
+			dt.Finish(gotID, nil)
+
+			_, failed := d.FinishedErr.(*functions.AssertionError)
+			if failed != tc.failure {
+				t.Fatalf("unexpected assertion failure: %v", d.FinishedErr)
+			}
+			if !failed && d.FinishedErr != nil {
+				t.Fatal(d.FinishedErr)
+			}
+
+			got, err := executetest.TablesFromCache(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			executetest.NormalizeTables(got)
+			executetest.NormalizeTables(tc.result)
+			sort.Sort(executetest.SortedTables(got))
+			sort.Sort(executetest.SortedTables(tc.result))
+
+			if !cmp.Equal(tc.result, got) {
+				t.Errorf("unexpected tables -want/+got\n%s", cmp.Diff(tc.result, got))
+			}
+		})
+	}
+}
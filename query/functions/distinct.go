@@ -120,6 +120,10 @@ func createDistinctTransformation(id execute.DatasetID, mode execute.Accumulatio
 		return nil, nil, fmt.Errorf("invalid spec type %T", spec)
 	}
 	cache := execute.NewTableBuilderCache(a.Allocator())
+	// The distinct values are spilled as sorted runs near the memory quota,
+	// and merged back without the values repeated by the runs when the tables are read.
+	cache.SetSpiller(a.Spiller())
+	cache.SetDistinctRows([]string{execute.DefaultValueColLabel})
 	d := execute.NewDataset(id, mode, cache)
 	t := NewDistinctTransformation(d, cache, s)
 	return t, d, nil
@@ -206,23 +210,29 @@ func (t *distinctTransformation) Process(id execute.DatasetID, tbl query.Table)
 		stringDistinct map[string]bool
 		timeDistinct   map[execute.Time]bool
 	)
-	switch col.Type {
-	case query.TBool:
-		boolDistinct = make(map[bool]bool)
-	case query.TInt:
-		intDistinct = make(map[int64]bool)
-	case query.TUInt:
-		uintDistinct = make(map[uint64]bool)
-	case query.TFloat:
-		floatDistinct = make(map[float64]bool)
-	case query.TString:
-		stringDistinct = make(map[string]bool)
-	case query.TTime:
-		timeDistinct = make(map[execute.Time]bool)
+	reset := func() {
+		switch col.Type {
+		case query.TBool:
+			boolDistinct = make(map[bool]bool)
+		case query.TInt:
+			intDistinct = make(map[int64]bool)
+		case query.TUInt:
+			uintDistinct = make(map[uint64]bool)
+		case query.TFloat:
+			floatDistinct = make(map[float64]bool)
+		case query.TString:
+			stringDistinct = make(map[string]bool)
+		case query.TTime:
+			timeDistinct = make(map[execute.Time]bool)
+		}
 	}
+	reset()
 
 	return tbl.Do(func(cr query.ColReader) error {
 		l := cr.Len()
+		if l == 0 {
+			return nil
+		}
 		for i := 0; i < l; i++ {
 			// Check distinct
 			switch col.Type {
@@ -272,6 +282,13 @@ func (t *distinctTransformation) Process(id execute.DatasetID, tbl query.Table)
 
 			execute.AppendKeyValues(tbl.Key(), builder)
 		}
+		if err := execute.SpillIfNeeded(t.cache); err != nil {
+			return err
+		}
+		if builder.NRows() == 0 {
+			// The values have been spilled, the values of the next run are only distinct from each other.
+			reset()
+		}
 		return nil
 	})
 }
@@ -0,0 +1,370 @@
+package functions
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/execute"
+	"github.com/EMCECS/influx/query/interpreter"
+	"github.com/EMCECS/influx/query/plan"
+	"github.com/EMCECS/influx/query/semantic"
+)
+
+const (
+	ExponentialMovingAverageKind = "exponentialMovingAverage"
+	DoubleEMAKind                = "doubleEMA"
+	TripleEMAKind                = "tripleEMA"
+)
+
+// ExponentialMovingAverageOpSpec computes the exponential moving average of the values of columns over n rows.
+type ExponentialMovingAverageOpSpec struct {
+	N       int64    `json:"n"`
+	Columns []string `json:"columns"`
+}
+
+// DoubleEMAOpSpec computes the double exponential moving average, 2*EMA - EMA(EMA).
+type DoubleEMAOpSpec struct {
+	ExponentialMovingAverageOpSpec
+}
+
+// TripleEMAOpSpec computes the triple exponential moving average, 3*EMA - 3*EMA(EMA) + EMA(EMA(EMA)).
+type TripleEMAOpSpec struct {
+	ExponentialMovingAverageOpSpec
+}
+
+var exponentialMovingAverageSignature = query.DefaultFunctionSignature()
+
+func init() {
+	exponentialMovingAverageSignature.Params["n"] = semantic.Int
+	exponentialMovingAverageSignature.Params["columns"] = semantic.NewArrayType(semantic.String)
+
+	query.RegisterFunction(ExponentialMovingAverageKind, createExponentialMovingAverageOpSpec, exponentialMovingAverageSignature)
+	query.RegisterFunction(DoubleEMAKind, createDoubleEMAOpSpec, exponentialMovingAverageSignature)
+	query.RegisterFunction(TripleEMAKind, createTripleEMAOpSpec, exponentialMovingAverageSignature)
+	query.RegisterOpSpec(ExponentialMovingAverageKind, newExponentialMovingAverageOp)
+	query.RegisterOpSpec(DoubleEMAKind, newDoubleEMAOp)
+	query.RegisterOpSpec(TripleEMAKind, newTripleEMAOp)
+	plan.RegisterProcedureSpec(ExponentialMovingAverageKind, newExponentialMovingAverageProcedure, ExponentialMovingAverageKind)
+	plan.RegisterProcedureSpec(DoubleEMAKind, newExponentialMovingAverageProcedure, DoubleEMAKind)
+	plan.RegisterProcedureSpec(TripleEMAKind, newExponentialMovingAverageProcedure, TripleEMAKind)
+	execute.RegisterTransformation(ExponentialMovingAverageKind, createExponentialMovingAverageTransformation)
+	execute.RegisterTransformation(DoubleEMAKind, createExponentialMovingAverageTransformation)
+	execute.RegisterTransformation(TripleEMAKind, createExponentialMovingAverageTransformation)
+}
+
+func createExponentialMovingAverageOpSpec(args query.Arguments, a *query.Administration) (query.OperationSpec, error) {
+	spec := new(ExponentialMovingAverageOpSpec)
+	if err := spec.readArgs(args, a); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+func createDoubleEMAOpSpec(args query.Arguments, a *query.Administration) (query.OperationSpec, error) {
+	spec := new(DoubleEMAOpSpec)
+	if err := spec.readArgs(args, a); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+func createTripleEMAOpSpec(args query.Arguments, a *query.Administration) (query.OperationSpec, error) {
+	spec := new(TripleEMAOpSpec)
+	if err := spec.readArgs(args, a); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+func (s *ExponentialMovingAverageOpSpec) readArgs(args query.Arguments, a *query.Administration) error {
+	if err := a.AddParentFromArgs(args); err != nil {
+		return err
+	}
+
+	n, err := args.GetRequiredInt("n")
+	if err != nil {
+		return err
+	}
+	if n <= 0 {
+		return fmt.Errorf("n must be positive, got %d", n)
+	}
+	s.N = n
+
+	if cols, ok, err := args.GetArray("columns", semantic.String); err != nil {
+		return err
+	} else if ok {
+		columns, err := interpreter.ToStringArray(cols)
+		if err != nil {
+			return err
+		}
+		s.Columns = columns
+	} else {
+		s.Columns = []string{execute.DefaultValueColLabel}
+	}
+	return nil
+}
+
+func newExponentialMovingAverageOp() query.OperationSpec {
+	return new(ExponentialMovingAverageOpSpec)
+}
+
+func newDoubleEMAOp() query.OperationSpec {
+	return new(DoubleEMAOpSpec)
+}
+
+func newTripleEMAOp() query.OperationSpec {
+	return new(TripleEMAOpSpec)
+}
+
+func (s *ExponentialMovingAverageOpSpec) Kind() query.OperationKind {
+	return ExponentialMovingAverageKind
+}
+
+func (s *DoubleEMAOpSpec) Kind() query.OperationKind {
+	return DoubleEMAKind
+}
+
+func (s *TripleEMAOpSpec) Kind() query.OperationKind {
+	return TripleEMAKind
+}
+
+type ExponentialMovingAverageProcedureSpec struct {
+	N       int64    `json:"n"`
+	Columns []string `json:"columns"`
+	// Order is the number of times the average is applied, 1 for the EMA, 2 for the double EMA and 3 for the triple EMA.
+	Order int `json:"order"`
+}
+
+func newExponentialMovingAverageProcedure(qs query.OperationSpec, pa plan.Administration) (plan.ProcedureSpec, error) {
+	var (
+		spec  ExponentialMovingAverageOpSpec
+		order int
+	)
+	switch s := qs.(type) {
+	case *ExponentialMovingAverageOpSpec:
+		spec, order = *s, 1
+	case *DoubleEMAOpSpec:
+		spec, order = s.ExponentialMovingAverageOpSpec, 2
+	case *TripleEMAOpSpec:
+		spec, order = s.ExponentialMovingAverageOpSpec, 3
+	default:
+		return nil, fmt.Errorf("invalid spec type %T", qs)
+	}
+
+	return &ExponentialMovingAverageProcedureSpec{
+		N:       spec.N,
+		Columns: spec.Columns,
+		Order:   order,
+	}, nil
+}
+
+func (s *ExponentialMovingAverageProcedureSpec) Kind() plan.ProcedureKind {
+	switch s.Order {
+	case 2:
+		return DoubleEMAKind
+	case 3:
+		return TripleEMAKind
+	default:
+		return ExponentialMovingAverageKind
+	}
+}
+func (s *ExponentialMovingAverageProcedureSpec) Copy() plan.ProcedureSpec {
+	ns := new(ExponentialMovingAverageProcedureSpec)
+	*ns = *s
+	if s.Columns != nil {
+		ns.Columns = make([]string, len(s.Columns))
+		copy(ns.Columns, s.Columns)
+	}
+	return ns
+}
+
+func createExponentialMovingAverageTransformation(id execute.DatasetID, mode execute.AccumulationMode, spec plan.ProcedureSpec, a execute.Administration) (execute.Transformation, execute.Dataset, error) {
+	s, ok := spec.(*ExponentialMovingAverageProcedureSpec)
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid spec type %T", spec)
+	}
+	cache := execute.NewTableBuilderCache(a.Allocator())
+	d := execute.NewDataset(id, mode, cache)
+	t := NewExponentialMovingAverageTransformation(d, cache, s)
+	return t, d, nil
+}
+
+type exponentialMovingAverageTransformation struct {
+	d     execute.Dataset
+	cache execute.TableBuilderCache
+
+	n       int64
+	columns []string
+	order   int
+}
+
+func NewExponentialMovingAverageTransformation(d execute.Dataset, cache execute.TableBuilderCache, spec *ExponentialMovingAverageProcedureSpec) *exponentialMovingAverageTransformation {
+	order := spec.Order
+	if order == 0 {
+		order = 1
+	}
+	return &exponentialMovingAverageTransformation{
+		d:       d,
+		cache:   cache,
+		n:       spec.N,
+		columns: spec.Columns,
+		order:   order,
+	}
+}
+
+func (t *exponentialMovingAverageTransformation) RetractTable(id execute.DatasetID, key query.GroupKey) error {
+	return t.d.RetractTable(key)
+}
+
+func (t *exponentialMovingAverageTransformation) Process(id execute.DatasetID, tbl query.Table) error {
+	if t.n <= 0 {
+		return errors.New("exponential moving average requires a positive n")
+	}
+	cols := tbl.Cols()
+	averages := make([]*emaChain, len(cols))
+	for _, label := range t.columns {
+		j := execute.ColIdx(label, cols)
+		if j < 0 {
+			return fmt.Errorf("column %q does not exist", label)
+		}
+		if tbl.Key().HasCol(label) {
+			return fmt.Errorf("cannot average column %q that is part of the group key", label)
+		}
+		switch typ := cols[j].Type; typ {
+		case query.TInt, query.TUInt, query.TFloat:
+		default:
+			return fmt.Errorf("exponential moving average does not support %v", typ)
+		}
+		averages[j] = newEMAChain(t.n, t.order)
+	}
+
+	builder, created := t.cache.TableBuilder(tbl.Key())
+	if !created {
+		return fmt.Errorf("exponential moving average found duplicate table with key: %v", tbl.Key())
+	}
+	for j, c := range cols {
+		if averages[j] != nil {
+			c.Type = query.TFloat
+		}
+		builder.AddCol(c)
+	}
+
+	avgs := make([]float64, len(cols))
+	nulls := make([]bool, len(cols))
+	return tbl.Do(func(cr query.ColReader) error {
+		for i := 0; i < cr.Len(); i++ {
+			ready := true
+			for j, c := range cols {
+				avg := averages[j]
+				if avg == nil {
+					continue
+				}
+				nulls[j] = execute.IsNull(i, j, cr)
+				if !nulls[j] {
+					var v float64
+					switch c.Type {
+					case query.TInt:
+						v = float64(cr.Ints(j)[i])
+					case query.TUInt:
+						v = float64(cr.UInts(j)[i])
+					case query.TFloat:
+						v = cr.Floats(j)[i]
+					}
+					avgs[j] = avg.update(v)
+				}
+				ready = ready && avg.ready()
+			}
+			// The first rows are dropped until every column has enough values for its average.
+			if !ready {
+				continue
+			}
+			for j := range cols {
+				switch {
+				case averages[j] == nil:
+					execute.AppendValue(builder, j, execute.ValueForRow(i, j, cr))
+				case nulls[j]:
+					builder.AppendNil(j)
+				default:
+					builder.AppendFloat(j, avgs[j])
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (t *exponentialMovingAverageTransformation) UpdateWatermark(id execute.DatasetID, mark execute.Time) error {
+	return t.d.UpdateWatermark(mark)
+}
+func (t *exponentialMovingAverageTransformation) UpdateProcessingTime(id execute.DatasetID, pt execute.Time) error {
+	return t.d.UpdateProcessingTime(pt)
+}
+func (t *exponentialMovingAverageTransformation) Finish(id execute.DatasetID, err error) {
+	t.d.Finish(err)
+}
+
+// ema is the exponential moving average of a series of values.
+// The average of the first n values is their mean,
+// each following value v moves the average by 2/(n+1) * (v - average).
+type ema struct {
+	n     int64
+	count int64
+	sum   float64
+	value float64
+}
+
+// update adds a value to the series and reports whether the average is defined.
+func (e *ema) update(v float64) bool {
+	if e.count < e.n {
+		e.count++
+		e.sum += v
+		e.value = e.sum / float64(e.count)
+		return e.count == e.n
+	}
+	alpha := 2 / float64(e.n+1)
+	e.value += alpha * (v - e.value)
+	return true
+}
+
+// emaChain computes an exponential moving average of order 1, 2 or 3,
+// where each average of the chain averages the values of the previous one.
+type emaChain struct {
+	emas []ema
+}
+
+func newEMAChain(n int64, order int) *emaChain {
+	c := &emaChain{
+		emas: make([]ema, order),
+	}
+	for i := range c.emas {
+		c.emas[i].n = n
+	}
+	return c
+}
+
+// update adds a value to the series and returns the average of the chain.
+// The result is only meaningful once the chain is ready.
+func (c *emaChain) update(v float64) float64 {
+	for i := range c.emas {
+		if !c.emas[i].update(v) {
+			break
+		}
+		v = c.emas[i].value
+	}
+	e := c.emas
+	switch len(e) {
+	case 2:
+		return 2*e[0].value - e[1].value
+	case 3:
+		return 3*e[0].value - 3*e[1].value + e[2].value
+	default:
+		return e[0].value
+	}
+}
+
+// ready reports whether the last average of the chain is defined.
+func (c *emaChain) ready() bool {
+	last := c.emas[len(c.emas)-1]
+	return last.count == last.n
+}
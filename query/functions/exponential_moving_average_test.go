@@ -0,0 +1,288 @@
+package functions_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/execute"
+	"github.com/EMCECS/influx/query/execute/executetest"
+	"github.com/EMCECS/influx/query/functions"
+	"github.com/EMCECS/influx/query/querytest"
+)
+
+func TestExponentialMovingAverage_NewQuery(t *testing.T) {
+	tests := []querytest.NewQueryTestCase{
+		{
+			Name: "exponential moving average",
+			Raw:  `from(bucket:"mydb") |> exponentialMovingAverage(n:5)`,
+			Want: &query.Spec{
+				Operations: []*query.Operation{
+					{
+						ID: "from0",
+						Spec: &functions.FromOpSpec{
+							Bucket: "mydb",
+						},
+					},
+					{
+						ID: "exponentialMovingAverage1",
+						Spec: &functions.ExponentialMovingAverageOpSpec{
+							N:       5,
+							Columns: []string{"_value"},
+						},
+					},
+				},
+				Edges: []query.Edge{
+					{Parent: "from0", Child: "exponentialMovingAverage1"},
+				},
+			},
+		},
+		{
+			Name: "triple exponential moving average with columns",
+			Raw:  `from(bucket:"mydb") |> tripleEMA(n:3, columns:["a","b"])`,
+			Want: &query.Spec{
+				Operations: []*query.Operation{
+					{
+						ID: "from0",
+						Spec: &functions.FromOpSpec{
+							Bucket: "mydb",
+						},
+					},
+					{
+						ID: "tripleEMA1",
+						Spec: &functions.TripleEMAOpSpec{
+							ExponentialMovingAverageOpSpec: functions.ExponentialMovingAverageOpSpec{
+								N:       3,
+								Columns: []string{"a", "b"},
+							},
+						},
+					},
+				},
+				Edges: []query.Edge{
+					{Parent: "from0", Child: "tripleEMA1"},
+				},
+			},
+		},
+		{
+			Name:    "missing n",
+			Raw:     `from(bucket:"mydb") |> doubleEMA()`,
+			WantErr: true,
+		},
+		{
+			Name:    "non positive n",
+			Raw:     `from(bucket:"mydb") |> doubleEMA(n:0)`,
+			WantErr: true,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			querytest.NewQueryTestHelper(t, tc)
+		})
+	}
+}
+
+func TestExponentialMovingAverageOperation_Marshaling(t *testing.T) {
+	data := []byte(`{"id":"doubleEMA","kind":"doubleEMA","spec":{"n":3,"columns":["_value"]}}`)
+	op := &query.Operation{
+		ID: "doubleEMA",
+		Spec: &functions.DoubleEMAOpSpec{
+			ExponentialMovingAverageOpSpec: functions.ExponentialMovingAverageOpSpec{
+				N:       3,
+				Columns: []string{"_value"},
+			},
+		},
+	}
+	querytest.OperationMarshalingTestHelper(t, data, op)
+}
+
+func TestExponentialMovingAverage_PassThrough(t *testing.T) {
+	executetest.TransformationPassThroughTestHelper(t, func(d execute.Dataset, c execute.TableBuilderCache) execute.Transformation {
+		s := functions.NewExponentialMovingAverageTransformation(
+			d,
+			c,
+			&functions.ExponentialMovingAverageProcedureSpec{N: 1},
+		)
+		return s
+	})
+}
+
+func TestExponentialMovingAverage_Process(t *testing.T) {
+	testCases := []struct {
+		name    string
+		spec    *functions.ExponentialMovingAverageProcedureSpec
+		data    []query.Table
+		want    []*executetest.Table
+		wantErr error
+	}{
+		{
+			name: "ema",
+			spec: &functions.ExponentialMovingAverageProcedureSpec{
+				N:       3,
+				Columns: []string{"_value"},
+				Order:   1,
+			},
+			data: []query.Table{&executetest.Table{
+				ColMeta: []query.ColMeta{
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TFloat},
+				},
+				Data: [][]interface{}{
+					{execute.Time(1), 1.0},
+					{execute.Time(2), 2.0},
+					{execute.Time(3), 3.0},
+					{execute.Time(4), 4.0},
+					{execute.Time(5), 5.0},
+				},
+			}},
+			want: []*executetest.Table{{
+				ColMeta: []query.ColMeta{
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TFloat},
+				},
+				Data: [][]interface{}{
+					{execute.Time(3), 2.0},
+					{execute.Time(4), 3.0},
+					{execute.Time(5), 4.0},
+				},
+			}},
+		},
+		{
+			name: "double ema int",
+			spec: &functions.ExponentialMovingAverageProcedureSpec{
+				N:       3,
+				Columns: []string{"_value"},
+				Order:   2,
+			},
+			data: []query.Table{&executetest.Table{
+				ColMeta: []query.ColMeta{
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TInt},
+				},
+				Data: [][]interface{}{
+					{execute.Time(1), int64(1)},
+					{execute.Time(2), int64(2)},
+					{execute.Time(3), int64(3)},
+					{execute.Time(4), int64(4)},
+					{execute.Time(5), int64(5)},
+					{execute.Time(6), int64(6)},
+				},
+			}},
+			want: []*executetest.Table{{
+				ColMeta: []query.ColMeta{
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TFloat},
+				},
+				Data: [][]interface{}{
+					{execute.Time(5), 5.0},
+					{execute.Time(6), 6.0},
+				},
+			}},
+		},
+		{
+			name: "triple ema",
+			spec: &functions.ExponentialMovingAverageProcedureSpec{
+				N:       3,
+				Columns: []string{"_value"},
+				Order:   3,
+			},
+			data: []query.Table{&executetest.Table{
+				ColMeta: []query.ColMeta{
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TUInt},
+				},
+				Data: [][]interface{}{
+					{execute.Time(1), uint64(1)},
+					{execute.Time(2), uint64(2)},
+					{execute.Time(3), uint64(3)},
+					{execute.Time(4), uint64(4)},
+					{execute.Time(5), uint64(5)},
+					{execute.Time(6), uint64(6)},
+					{execute.Time(7), uint64(7)},
+					{execute.Time(8), uint64(8)},
+				},
+			}},
+			want: []*executetest.Table{{
+				ColMeta: []query.ColMeta{
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TFloat},
+				},
+				Data: [][]interface{}{
+					{execute.Time(7), 7.0},
+					{execute.Time(8), 8.0},
+				},
+			}},
+		},
+		{
+			name: "ema with nulls",
+			spec: &functions.ExponentialMovingAverageProcedureSpec{
+				N:       3,
+				Columns: []string{"_value"},
+				Order:   1,
+			},
+			data: []query.Table{&executetest.Table{
+				KeyCols: []string{"t1"},
+				ColMeta: []query.ColMeta{
+					{Label: "t1", Type: query.TString},
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TFloat},
+				},
+				Data: [][]interface{}{
+					{"a", execute.Time(1), 1.0},
+					{"a", execute.Time(2), nil},
+					{"a", execute.Time(3), 2.0},
+					{"a", execute.Time(4), 3.0},
+					{"a", execute.Time(5), nil},
+					{"a", execute.Time(6), 6.0},
+				},
+			}},
+			want: []*executetest.Table{{
+				KeyCols: []string{"t1"},
+				ColMeta: []query.ColMeta{
+					{Label: "t1", Type: query.TString},
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TFloat},
+				},
+				Data: [][]interface{}{
+					{"a", execute.Time(4), 2.0},
+					{"a", execute.Time(5), nil},
+					{"a", execute.Time(6), 4.0},
+				},
+			}},
+		},
+		{
+			name: "string column",
+			spec: &functions.ExponentialMovingAverageProcedureSpec{
+				N:       3,
+				Columns: []string{"_value"},
+				Order:   1,
+			},
+			data: []query.Table{&executetest.Table{
+				ColMeta: []query.ColMeta{
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TString},
+				},
+				Data: [][]interface{}{
+					{execute.Time(1), "a"},
+				},
+			}},
+			want:    []*executetest.Table(nil),
+			wantErr: errors.New("exponential moving average does not support string"),
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			executetest.ProcessTestHelper(
+				t,
+				tc.data,
+				tc.want,
+				tc.wantErr,
+				func(d execute.Dataset, c execute.TableBuilderCache) execute.Transformation {
+					return functions.NewExponentialMovingAverageTransformation(d, c, tc.spec)
+				},
+			)
+		})
+	}
+}
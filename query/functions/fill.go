@@ -0,0 +1,307 @@
+package functions
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/execute"
+	"github.com/EMCECS/influx/query/plan"
+	"github.com/EMCECS/influx/query/semantic"
+	"github.com/EMCECS/influx/query/values"
+)
+
+const FillKind = "fill"
+
+// FillOpSpec replaces the null values of a column,
+// either with a constant value or with the previous value of the column.
+type FillOpSpec struct {
+	Column string `json:"column"`
+	// Value is the literal replacing null values, it is nil when UsePrevious is set.
+	Value       semantic.Literal `json:"value,omitempty"`
+	UsePrevious bool             `json:"usePrevious,omitempty"`
+}
+
+var fillSignature = query.DefaultFunctionSignature()
+
+func init() {
+	fillSignature.Params["column"] = semantic.String
+	fillSignature.Params["usePrevious"] = semantic.Bool
+	// The value may be of any type the column can be filled with.
+	fillSignature.Params["value"] = semantic.Invalid
+
+	query.RegisterFunction(FillKind, createFillOpSpec, fillSignature)
+	query.RegisterOpSpec(FillKind, newFillOp)
+	plan.RegisterProcedureSpec(FillKind, newFillProcedure, FillKind)
+	execute.RegisterTransformation(FillKind, createFillTransformation)
+}
+
+func createFillOpSpec(args query.Arguments, a *query.Administration) (query.OperationSpec, error) {
+	if err := a.AddParentFromArgs(args); err != nil {
+		return nil, err
+	}
+
+	spec := &FillOpSpec{
+		Column: execute.DefaultValueColLabel,
+	}
+	if col, ok, err := args.GetString("column"); err != nil {
+		return nil, err
+	} else if ok {
+		spec.Column = col
+	}
+
+	if usePrevious, ok, err := args.GetBool("usePrevious"); err != nil {
+		return nil, err
+	} else if ok {
+		spec.UsePrevious = usePrevious
+	}
+
+	value, ok := args.Get("value")
+	switch {
+	case ok && spec.UsePrevious:
+		return nil, errors.New("fill requires either a value or usePrevious, not both")
+	case ok:
+		lit, err := literalFromValue(value)
+		if err != nil {
+			return nil, err
+		}
+		spec.Value = lit
+	case !spec.UsePrevious:
+		return nil, errors.New("fill requires either a value or usePrevious")
+	}
+	return spec, nil
+}
+
+// literalFromValue returns the literal of a fill value.
+func literalFromValue(v values.Value) (semantic.Literal, error) {
+	switch v.Type().Kind() {
+	case semantic.Bool:
+		return &semantic.BooleanLiteral{Value: v.Bool()}, nil
+	case semantic.Int:
+		return &semantic.IntegerLiteral{Value: v.Int()}, nil
+	case semantic.UInt:
+		return &semantic.UnsignedIntegerLiteral{Value: v.UInt()}, nil
+	case semantic.Float:
+		return &semantic.FloatLiteral{Value: v.Float()}, nil
+	case semantic.String:
+		return &semantic.StringLiteral{Value: v.Str()}, nil
+	case semantic.Time:
+		return &semantic.DateTimeLiteral{Value: v.Time().Time()}, nil
+	default:
+		return nil, fmt.Errorf("cannot fill with a value of type %v", v.Type())
+	}
+}
+
+// valueFromLiteral returns the fill value of a literal.
+func valueFromLiteral(lit semantic.Literal) (values.Value, error) {
+	switch lit := lit.(type) {
+	case *semantic.BooleanLiteral:
+		return values.NewBoolValue(lit.Value), nil
+	case *semantic.IntegerLiteral:
+		return values.NewIntValue(lit.Value), nil
+	case *semantic.UnsignedIntegerLiteral:
+		return values.NewUIntValue(lit.Value), nil
+	case *semantic.FloatLiteral:
+		return values.NewFloatValue(lit.Value), nil
+	case *semantic.StringLiteral:
+		return values.NewStringValue(lit.Value), nil
+	case *semantic.DateTimeLiteral:
+		return values.NewTimeValue(values.ConvertTime(lit.Value)), nil
+	default:
+		return nil, fmt.Errorf("cannot fill with a literal of type %T", lit)
+	}
+}
+
+func (s *FillOpSpec) UnmarshalJSON(data []byte) error {
+	raw := struct {
+		Column      string          `json:"column"`
+		Value       json.RawMessage `json:"value"`
+		UsePrevious bool            `json:"usePrevious"`
+	}{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	s.Column = raw.Column
+	s.UsePrevious = raw.UsePrevious
+	s.Value = nil
+	if len(raw.Value) > 0 {
+		node, err := semantic.UnmarshalNode(raw.Value)
+		if err != nil {
+			return err
+		}
+		lit, ok := node.(semantic.Literal)
+		if !ok {
+			return fmt.Errorf("fill value must be a literal, got %T", node)
+		}
+		s.Value = lit
+	}
+	return nil
+}
+
+func newFillOp() query.OperationSpec {
+	return new(FillOpSpec)
+}
+
+func (s *FillOpSpec) Kind() query.OperationKind {
+	return FillKind
+}
+
+type FillProcedureSpec struct {
+	Column string
+	// Value replaces null values, it is nil when UsePrevious is set.
+	Value       values.Value
+	UsePrevious bool
+}
+
+func newFillProcedure(qs query.OperationSpec, pa plan.Administration) (plan.ProcedureSpec, error) {
+	s, ok := qs.(*FillOpSpec)
+	if !ok {
+		return nil, fmt.Errorf("invalid spec type %T", qs)
+	}
+	p := &FillProcedureSpec{
+		Column:      s.Column,
+		UsePrevious: s.UsePrevious,
+	}
+	if s.Value != nil {
+		v, err := valueFromLiteral(s.Value)
+		if err != nil {
+			return nil, err
+		}
+		p.Value = v
+	}
+	return p, nil
+}
+
+func (s *FillProcedureSpec) Kind() plan.ProcedureKind {
+	return FillKind
+}
+func (s *FillProcedureSpec) Copy() plan.ProcedureSpec {
+	ns := *s
+	return &ns
+}
+
+func createFillTransformation(id execute.DatasetID, mode execute.AccumulationMode, spec plan.ProcedureSpec, a execute.Administration) (execute.Transformation, execute.Dataset, error) {
+	s, ok := spec.(*FillProcedureSpec)
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid spec type %T", spec)
+	}
+	cache := execute.NewTableBuilderCache(a.Allocator())
+	d := execute.NewDataset(id, mode, cache)
+	t := NewFillTransformation(d, cache, s)
+	return t, d, nil
+}
+
+type fillTransformation struct {
+	d     execute.Dataset
+	cache execute.TableBuilderCache
+
+	column      string
+	value       values.Value
+	usePrevious bool
+}
+
+func NewFillTransformation(d execute.Dataset, cache execute.TableBuilderCache, spec *FillProcedureSpec) execute.Transformation {
+	return &fillTransformation{
+		d:           d,
+		cache:       cache,
+		column:      spec.Column,
+		value:       spec.Value,
+		usePrevious: spec.UsePrevious,
+	}
+}
+
+func (t *fillTransformation) RetractTable(id execute.DatasetID, key query.GroupKey) error {
+	return t.d.RetractTable(key)
+}
+
+func (t *fillTransformation) Process(id execute.DatasetID, tbl query.Table) error {
+	builder, created := t.cache.TableBuilder(tbl.Key())
+	if !created {
+		return fmt.Errorf("fill found duplicate table with key: %v", tbl.Key())
+	}
+	execute.AddTableCols(tbl, builder)
+
+	// The values of group key columns are never null, so tables are passed through
+	// when the column is part of the group key or missing.
+	idx := execute.ColIdx(t.column, tbl.Cols())
+	if idx < 0 || tbl.Key().HasCol(t.column) {
+		execute.AppendTable(tbl, builder)
+		return nil
+	}
+	typ := tbl.Cols()[idx].Type
+
+	fill := t.value
+	if fill != nil {
+		v, err := convertFillValue(fill, typ)
+		if err != nil {
+			return fmt.Errorf("cannot fill column %q: %v", t.column, err)
+		}
+		fill = v
+	}
+
+	return tbl.Do(func(cr query.ColReader) error {
+		for j := range cr.Cols() {
+			if j == idx {
+				continue
+			}
+			execute.AppendCol(j, j, cr, builder)
+		}
+		for i, l := 0, cr.Len(); i < l; i++ {
+			if !execute.IsNull(i, idx, cr) {
+				v := execute.ValueForRow(i, idx, cr)
+				if t.usePrevious {
+					// The previous value is kept for the following rows of the table.
+					fill = v
+				}
+				builder.AppendValue(idx, v)
+			} else if fill != nil {
+				builder.AppendValue(idx, fill)
+			} else {
+				// There is no previous value yet.
+				builder.AppendNil(idx)
+			}
+		}
+		return nil
+	})
+}
+
+// convertFillValue converts the fill value to the type of the column.
+// Numeric values are converted between numeric types, other values must have the type of the column.
+func convertFillValue(v values.Value, typ query.DataType) (values.Value, error) {
+	k := v.Type().Kind()
+	if execute.ConvertFromKind(k) == typ {
+		return v, nil
+	}
+	var f float64
+	switch k {
+	case semantic.Int:
+		f = float64(v.Int())
+	case semantic.UInt:
+		f = float64(v.UInt())
+	case semantic.Float:
+		f = v.Float()
+	default:
+		return nil, fmt.Errorf("fill value of type %v does not match column type %v", k, typ)
+	}
+	switch typ {
+	case query.TInt:
+		return values.NewIntValue(int64(f)), nil
+	case query.TUInt:
+		return values.NewUIntValue(uint64(f)), nil
+	case query.TFloat:
+		return values.NewFloatValue(f), nil
+	default:
+		return nil, fmt.Errorf("fill value of type %v does not match column type %v", k, typ)
+	}
+}
+
+func (t *fillTransformation) UpdateWatermark(id execute.DatasetID, mark execute.Time) error {
+	return t.d.UpdateWatermark(mark)
+}
+func (t *fillTransformation) UpdateProcessingTime(id execute.DatasetID, pt execute.Time) error {
+	return t.d.UpdateProcessingTime(pt)
+}
+func (t *fillTransformation) Finish(id execute.DatasetID, err error) {
+	t.d.Finish(err)
+}
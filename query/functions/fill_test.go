@@ -0,0 +1,244 @@
+package functions_test
+
+import (
+	"testing"
+
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/execute"
+	"github.com/EMCECS/influx/query/execute/executetest"
+	"github.com/EMCECS/influx/query/functions"
+	"github.com/EMCECS/influx/query/querytest"
+	"github.com/EMCECS/influx/query/semantic"
+	"github.com/EMCECS/influx/query/values"
+)
+
+func TestFill_NewQuery(t *testing.T) {
+	tests := []querytest.NewQueryTestCase{
+		{
+			Name: "fill with value",
+			Raw:  `fromCSV(csv: "a") |> fill(value: 0.0)`,
+			Want: &query.Spec{
+				Operations: []*query.Operation{
+					{
+						ID:   "fromCSV0",
+						Spec: &functions.FromCSVOpSpec{CSV: "a"},
+					},
+					{
+						ID: "fill1",
+						Spec: &functions.FillOpSpec{
+							Column: "_value",
+							Value:  &semantic.FloatLiteral{Value: 0},
+						},
+					},
+				},
+				Edges: []query.Edge{
+					{Parent: "fromCSV0", Child: "fill1"},
+				},
+			},
+		},
+		{
+			Name: "fill with previous",
+			Raw:  `fromCSV(csv: "a") |> fill(column: "host", usePrevious: true)`,
+			Want: &query.Spec{
+				Operations: []*query.Operation{
+					{
+						ID:   "fromCSV0",
+						Spec: &functions.FromCSVOpSpec{CSV: "a"},
+					},
+					{
+						ID: "fill1",
+						Spec: &functions.FillOpSpec{
+							Column:      "host",
+							UsePrevious: true,
+						},
+					},
+				},
+				Edges: []query.Edge{
+					{Parent: "fromCSV0", Child: "fill1"},
+				},
+			},
+		},
+		{
+			Name:    "fill without value",
+			Raw:     `fromCSV(csv: "a") |> fill()`,
+			WantErr: true,
+		},
+		{
+			Name:    "fill with value and previous",
+			Raw:     `fromCSV(csv: "a") |> fill(value: 1, usePrevious: true)`,
+			WantErr: true,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			querytest.NewQueryTestHelper(t, tc)
+		})
+	}
+}
+
+func TestFillOperation_Marshaling(t *testing.T) {
+	data := []byte(`{"id":"fill","kind":"fill","spec":{"column":"_value","value":{"type":"IntegerLiteral","value":"1"}}}`)
+	op := &query.Operation{
+		ID: "fill",
+		Spec: &functions.FillOpSpec{
+			Column: "_value",
+			Value:  &semantic.IntegerLiteral{Value: 1},
+		},
+	}
+	querytest.OperationMarshalingTestHelper(t, data, op)
+}
+
+func TestFill_Process(t *testing.T) {
+	cols := []query.ColMeta{
+		{Label: "t1", Type: query.TString},
+		{Label: "_time", Type: query.TTime},
+		{Label: "_value", Type: query.TFloat},
+	}
+	testCases := []struct {
+		name string
+		spec *functions.FillProcedureSpec
+		data []query.Table
+		want []*executetest.Table
+	}{
+		{
+			name: "value",
+			spec: &functions.FillProcedureSpec{
+				Column: "_value",
+				Value:  values.NewFloatValue(-1),
+			},
+			data: []query.Table{&executetest.Table{
+				KeyCols: []string{"t1"},
+				ColMeta: cols,
+				Data: [][]interface{}{
+					{"a", execute.Time(1), 2.0},
+					{"a", execute.Time(2), nil},
+					{"a", execute.Time(3), 3.0},
+				},
+			}},
+			want: []*executetest.Table{{
+				KeyCols: []string{"t1"},
+				ColMeta: cols,
+				Data: [][]interface{}{
+					{"a", execute.Time(1), 2.0},
+					{"a", execute.Time(2), -1.0},
+					{"a", execute.Time(3), 3.0},
+				},
+			}},
+		},
+		{
+			name: "integer value in float column",
+			spec: &functions.FillProcedureSpec{
+				Column: "_value",
+				Value:  values.NewIntValue(0),
+			},
+			data: []query.Table{&executetest.Table{
+				KeyCols: []string{"t1"},
+				ColMeta: cols,
+				Data: [][]interface{}{
+					{"a", execute.Time(1), nil},
+					{"a", execute.Time(2), 1.0},
+				},
+			}},
+			want: []*executetest.Table{{
+				KeyCols: []string{"t1"},
+				ColMeta: cols,
+				Data: [][]interface{}{
+					{"a", execute.Time(1), 0.0},
+					{"a", execute.Time(2), 1.0},
+				},
+			}},
+		},
+		{
+			name: "previous",
+			spec: &functions.FillProcedureSpec{
+				Column:      "_value",
+				UsePrevious: true,
+			},
+			data: []query.Table{
+				&executetest.Table{
+					KeyCols: []string{"t1"},
+					ColMeta: cols,
+					Data: [][]interface{}{
+						{"a", execute.Time(1), nil},
+						{"a", execute.Time(2), 1.0},
+						{"a", execute.Time(3), nil},
+						{"a", execute.Time(4), nil},
+						{"a", execute.Time(5), 5.0},
+					},
+				},
+				&executetest.Table{
+					KeyCols: []string{"t1"},
+					ColMeta: cols,
+					Data: [][]interface{}{
+						{"b", execute.Time(1), nil},
+						{"b", execute.Time(2), 2.0},
+					},
+				},
+			},
+			want: []*executetest.Table{
+				{
+					KeyCols: []string{"t1"},
+					ColMeta: cols,
+					Data: [][]interface{}{
+						{"a", execute.Time(1), nil},
+						{"a", execute.Time(2), 1.0},
+						{"a", execute.Time(3), 1.0},
+						{"a", execute.Time(4), 1.0},
+						{"a", execute.Time(5), 5.0},
+					},
+				},
+				{
+					KeyCols: []string{"t1"},
+					ColMeta: cols,
+					Data: [][]interface{}{
+						{"b", execute.Time(1), nil},
+						{"b", execute.Time(2), 2.0},
+					},
+				},
+			},
+		},
+		{
+			name: "string column",
+			spec: &functions.FillProcedureSpec{
+				Column: "host",
+				Value:  values.NewStringValue("unknown"),
+			},
+			data: []query.Table{&executetest.Table{
+				ColMeta: []query.ColMeta{
+					{Label: "_time", Type: query.TTime},
+					{Label: "host", Type: query.TString},
+				},
+				Data: [][]interface{}{
+					{execute.Time(1), "A"},
+					{execute.Time(2), nil},
+				},
+			}},
+			want: []*executetest.Table{{
+				ColMeta: []query.ColMeta{
+					{Label: "_time", Type: query.TTime},
+					{Label: "host", Type: query.TString},
+				},
+				Data: [][]interface{}{
+					{execute.Time(1), "A"},
+					{execute.Time(2), "unknown"},
+				},
+			}},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			executetest.ProcessTestHelper(
+				t,
+				tc.data,
+				tc.want,
+				nil,
+				func(d execute.Dataset, c execute.TableBuilderCache) execute.Transformation {
+					return functions.NewFillTransformation(d, c, tc.spec)
+				},
+			)
+		})
+	}
+}
@@ -80,6 +80,16 @@ func (s *FilterProcedureSpec) Copy() plan.ProcedureSpec {
 	return ns
 }
 
+// Parallel reports that the rows of each table are filtered independently of the other tables.
+func (s *FilterProcedureSpec) Parallel() bool {
+	return true
+}
+
+// Sliceable reports that the rows of each time slice are filtered independently of the other slices.
+func (s *FilterProcedureSpec) Sliceable() bool {
+	return true
+}
+
 func (s *FilterProcedureSpec) PushDownRules() []plan.PushDownRule {
 	return []plan.PushDownRule{
 		{
@@ -133,6 +133,12 @@ func (s *FromProcedureSpec) Kind() plan.ProcedureKind {
 func (s *FromProcedureSpec) TimeBounds() query.Bounds {
 	return s.Bounds
 }
+
+// Sliceable reports whether the series are read as they are stored,
+// without the operations pushed down that depend on all the rows of the bounds.
+func (s *FromProcedureSpec) Sliceable() bool {
+	return !s.DescendingSet && !s.LimitSet && !s.WindowSet && !s.GroupingSet && !s.AggregateSet
+}
 func (s *FromProcedureSpec) Copy() plan.ProcedureSpec {
 	ns := new(FromProcedureSpec)
 
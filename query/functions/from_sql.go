@@ -0,0 +1,415 @@
+package functions
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/execute"
+	"github.com/EMCECS/influx/query/plan"
+	"github.com/EMCECS/influx/query/semantic"
+	"github.com/EMCECS/influx/query/values"
+	"github.com/pkg/errors"
+)
+
+const FromSQLKind = "fromSQL"
+
+type FromSQLOpSpec struct {
+	DriverName     string `json:"driverName"`
+	DataSourceName string `json:"dataSourceName"`
+	Query          string `json:"query"`
+}
+
+var fromSQLSignature = semantic.FunctionSignature{
+	Params: map[string]semantic.Type{
+		"driverName":     semantic.String,
+		"dataSourceName": semantic.String,
+		"query":          semantic.String,
+	},
+	ReturnType: query.TableObjectType,
+}
+
+func init() {
+	query.RegisterFunction(FromSQLKind, createFromSQLOpSpec, fromSQLSignature)
+	query.RegisterOpSpec(FromSQLKind, newFromSQLOp)
+	plan.RegisterProcedureSpec(FromSQLKind, newFromSQLProcedure, FromSQLKind)
+	execute.RegisterSource(FromSQLKind, createFromSQLSource)
+}
+
+func createFromSQLOpSpec(args query.Arguments, a *query.Administration) (query.OperationSpec, error) {
+	spec := new(FromSQLOpSpec)
+	var err error
+	if spec.DriverName, err = args.GetRequiredString("driverName"); err != nil {
+		return nil, err
+	}
+	if err := validateSQLDriver(spec.DriverName); err != nil {
+		return nil, err
+	}
+	if spec.DataSourceName, err = args.GetRequiredString("dataSourceName"); err != nil {
+		return nil, err
+	}
+	if spec.Query, err = args.GetRequiredString("query"); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+func newFromSQLOp() query.OperationSpec {
+	return new(FromSQLOpSpec)
+}
+
+func (s *FromSQLOpSpec) Kind() query.OperationKind {
+	return FromSQLKind
+}
+
+type FromSQLProcedureSpec struct {
+	DriverName     string
+	DataSourceName string
+	Query          string
+}
+
+func newFromSQLProcedure(qs query.OperationSpec, pa plan.Administration) (plan.ProcedureSpec, error) {
+	spec, ok := qs.(*FromSQLOpSpec)
+	if !ok {
+		return nil, fmt.Errorf("invalid spec type %T", qs)
+	}
+
+	return &FromSQLProcedureSpec{
+		DriverName:     spec.DriverName,
+		DataSourceName: spec.DataSourceName,
+		Query:          spec.Query,
+	}, nil
+}
+
+func (s *FromSQLProcedureSpec) Kind() plan.ProcedureKind {
+	return FromSQLKind
+}
+
+func (s *FromSQLProcedureSpec) Copy() plan.ProcedureSpec {
+	ns := new(FromSQLProcedureSpec)
+	*ns = *s
+	return ns
+}
+
+func createFromSQLSource(prSpec plan.ProcedureSpec, dsid execute.DatasetID, a execute.Administration) (execute.Source, error) {
+	spec, ok := prSpec.(*FromSQLProcedureSpec)
+	if !ok {
+		return nil, fmt.Errorf("invalid spec type %T", prSpec)
+	}
+	if err := allowSQLDataSource(a, FromSQLKind, spec.DriverName, spec.DataSourceName); err != nil {
+		return nil, err
+	}
+	return NewSQLSource(spec, dsid, a.Allocator()), nil
+}
+
+// SQLSource reads the result of a SQL query as a single table with an empty group key.
+type SQLSource struct {
+	id    execute.DatasetID
+	spec  *FromSQLProcedureSpec
+	alloc *execute.Allocator
+	ts    []execute.Transformation
+}
+
+func NewSQLSource(spec *FromSQLProcedureSpec, id execute.DatasetID, alloc *execute.Allocator) *SQLSource {
+	return &SQLSource{
+		id:    id,
+		spec:  spec,
+		alloc: alloc,
+	}
+}
+
+func (s *SQLSource) AddTransformation(t execute.Transformation) {
+	s.ts = append(s.ts, t)
+}
+
+func (s *SQLSource) Run(ctx context.Context) {
+	tbl, err := s.read(ctx)
+	if err == nil {
+		for _, t := range s.ts {
+			if err = t.Process(s.id, tbl); err != nil {
+				break
+			}
+		}
+	}
+	for _, t := range s.ts {
+		t.Finish(s.id, err)
+	}
+}
+
+func (s *SQLSource) read(ctx context.Context) (query.Table, error) {
+	db, err := sql.Open(s.spec.DriverName, s.spec.DataSourceName)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, s.spec.Query)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to run sql query")
+	}
+	defer rows.Close()
+
+	colTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	builder := execute.NewColListTableBuilder(execute.NewGroupKey(nil, nil), s.alloc)
+	types := make([]query.DataType, len(colTypes))
+	for j, ct := range colTypes {
+		types[j] = sqlColumnType(ct)
+		builder.AddCol(query.ColMeta{
+			Label: ct.Name(),
+			Type:  types[j],
+		})
+	}
+
+	row := make([]interface{}, len(colTypes))
+	dest := make([]interface{}, len(colTypes))
+	for j := range row {
+		dest[j] = &row[j]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		for j, v := range row {
+			if err := appendSQLValue(builder, j, types[j], v); err != nil {
+				return nil, errors.Wrapf(err, "column %q", colTypes[j].Name())
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return builder.Table()
+}
+
+// AnySQLDataSource allows any data source name of a driver in SQLDependencies.
+const AnySQLDataSource = "*"
+
+// SQLDependencies are the SQL databases that fromSQL and toSQL may connect to.
+// The functions connect to databases chosen by the query text,
+// so they fail to execute unless the server injects SQLDependencies.
+type SQLDependencies struct {
+	// DataSources maps the name of each allowed driver to its allowed data source names.
+	// The AnySQLDataSource name allows any data source name of the driver.
+	DataSources map[string][]string
+}
+
+func (d SQLDependencies) Validate() error {
+	for name := range d.DataSources {
+		if err := validateSQLDriver(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Allow returns an error if the data source name of the driver is not allowed.
+func (d SQLDependencies) Allow(driverName, dataSourceName string) error {
+	dsns, ok := d.DataSources[driverName]
+	if !ok {
+		return fmt.Errorf("sql driver %q is not allowed", driverName)
+	}
+	for _, dsn := range dsns {
+		if dsn == AnySQLDataSource || dsn == dataSourceName {
+			return nil
+		}
+	}
+	return fmt.Errorf("sql data source %q is not allowed for driver %q", dataSourceName, driverName)
+}
+
+// InjectSQLDependencies enables fromSQL and toSQL for the databases of deps.
+func InjectSQLDependencies(depsMap execute.Dependencies, deps SQLDependencies) error {
+	if err := deps.Validate(); err != nil {
+		return err
+	}
+	depsMap[FromSQLKind] = deps
+	depsMap[ToSQLKind] = deps
+	return nil
+}
+
+// allowSQLDataSource returns an error unless the function of kind may connect to the data source.
+func allowSQLDataSource(a execute.Administration, kind, driverName, dataSourceName string) error {
+	deps, ok := a.Dependencies()[kind].(SQLDependencies)
+	if !ok {
+		return fmt.Errorf("%s is not enabled on this server", kind)
+	}
+	return deps.Allow(driverName, dataSourceName)
+}
+
+// validateSQLDriver checks that a driver with the name is registered with database/sql.
+func validateSQLDriver(name string) error {
+	drivers := sql.Drivers()
+	if i := sort.SearchStrings(drivers, name); i == len(drivers) || drivers[i] != name {
+		return fmt.Errorf("sql driver %q is not registered, available drivers are %s", name, strings.Join(drivers, ", "))
+	}
+	return nil
+}
+
+// sqlColumnType maps the type of a SQL column to a Flux type.
+// Types that are not known by name are mapped from the type the driver scans them into,
+// and fall back to strings.
+func sqlColumnType(ct *sql.ColumnType) query.DataType {
+	name := strings.ToUpper(ct.DatabaseTypeName())
+	if i := strings.IndexByte(name, '('); i >= 0 {
+		name = strings.TrimSpace(name[:i])
+	}
+	unsigned := strings.HasPrefix(name, "UNSIGNED ")
+	name = strings.TrimPrefix(name, "UNSIGNED ")
+	switch name {
+	case "INT", "INTEGER", "TINYINT", "SMALLINT", "MEDIUMINT", "BIGINT",
+		"INT2", "INT4", "INT8", "SERIAL", "BIGSERIAL":
+		if unsigned {
+			return query.TUInt
+		}
+		return query.TInt
+	case "REAL", "FLOAT", "DOUBLE", "DOUBLE PRECISION", "FLOAT4", "FLOAT8", "NUMERIC", "DECIMAL":
+		return query.TFloat
+	case "BOOL", "BOOLEAN":
+		return query.TBool
+	case "DATE", "DATETIME", "TIMESTAMP", "TIMESTAMPTZ":
+		return query.TTime
+	case "TEXT", "VARCHAR", "CHAR", "BPCHAR", "NVARCHAR", "NCHAR", "CLOB", "UUID", "JSON", "JSONB":
+		return query.TString
+	}
+	if st := ct.ScanType(); st != nil {
+		switch st.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return query.TInt
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return query.TUInt
+		case reflect.Float32, reflect.Float64:
+			return query.TFloat
+		case reflect.Bool:
+			return query.TBool
+		}
+		if st == reflect.TypeOf(time.Time{}) {
+			return query.TTime
+		}
+	}
+	return query.TString
+}
+
+// sqlTimeLayouts are the layouts of the times that drivers return as text.
+var sqlTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// appendSQLValue appends a value scanned from a SQL row to the column j of type typ.
+func appendSQLValue(b execute.TableBuilder, j int, typ query.DataType, v interface{}) error {
+	if v == nil {
+		b.AppendNil(j)
+		return nil
+	}
+	// Drivers may return any type as text.
+	var text string
+	switch s := v.(type) {
+	case []byte:
+		text = string(s)
+		v = text
+	case string:
+		text = s
+	}
+
+	switch typ {
+	case query.TInt:
+		switch n := v.(type) {
+		case int64:
+			b.AppendInt(j, n)
+			return nil
+		case string:
+			i, err := strconv.ParseInt(text, 10, 64)
+			if err != nil {
+				return err
+			}
+			b.AppendInt(j, i)
+			return nil
+		}
+	case query.TUInt:
+		switch n := v.(type) {
+		case int64:
+			b.AppendUInt(j, uint64(n))
+			return nil
+		case uint64:
+			b.AppendUInt(j, n)
+			return nil
+		case string:
+			u, err := strconv.ParseUint(text, 10, 64)
+			if err != nil {
+				return err
+			}
+			b.AppendUInt(j, u)
+			return nil
+		}
+	case query.TFloat:
+		switch n := v.(type) {
+		case float64:
+			b.AppendFloat(j, n)
+			return nil
+		case float32:
+			b.AppendFloat(j, float64(n))
+			return nil
+		case int64:
+			b.AppendFloat(j, float64(n))
+			return nil
+		case string:
+			f, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return err
+			}
+			b.AppendFloat(j, f)
+			return nil
+		}
+	case query.TBool:
+		switch n := v.(type) {
+		case bool:
+			b.AppendBool(j, n)
+			return nil
+		case int64:
+			b.AppendBool(j, n != 0)
+			return nil
+		case string:
+			t, err := strconv.ParseBool(text)
+			if err != nil {
+				return err
+			}
+			b.AppendBool(j, t)
+			return nil
+		}
+	case query.TTime:
+		switch n := v.(type) {
+		case time.Time:
+			b.AppendTime(j, values.ConvertTime(n))
+			return nil
+		case string:
+			for _, layout := range sqlTimeLayouts {
+				if t, err := time.Parse(layout, text); err == nil {
+					b.AppendTime(j, values.ConvertTime(t))
+					return nil
+				}
+			}
+			return fmt.Errorf("cannot parse time %q", text)
+		}
+	case query.TString:
+		switch n := v.(type) {
+		case string:
+			b.AppendString(j, n)
+		case time.Time:
+			b.AppendString(j, n.Format(time.RFC3339Nano))
+		default:
+			b.AppendString(j, fmt.Sprint(n))
+		}
+		return nil
+	}
+	return fmt.Errorf("cannot convert %T to %v", v, typ)
+}
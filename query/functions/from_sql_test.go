@@ -0,0 +1,240 @@
+package functions_test
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/execute"
+	"github.com/EMCECS/influx/query/execute/executetest"
+	"github.com/EMCECS/influx/query/functions"
+	_ "github.com/EMCECS/influx/query/functions/sqldrivers"
+	"github.com/EMCECS/influx/query/querytest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFromSQL_NewQuery(t *testing.T) {
+	tests := []querytest.NewQueryTestCase{
+		{
+			Name: "fromSQL",
+			Raw:  `fromSQL(driverName:"sqlite3", dataSourceName:"file:inventory.db", query:"SELECT * FROM hosts")`,
+			Want: &query.Spec{
+				Operations: []*query.Operation{
+					{
+						ID: "fromSQL0",
+						Spec: &functions.FromSQLOpSpec{
+							DriverName:     "sqlite3",
+							DataSourceName: "file:inventory.db",
+							Query:          "SELECT * FROM hosts",
+						},
+					},
+				},
+			},
+		},
+		{
+			Name:    "unknown driver",
+			Raw:     `fromSQL(driverName:"oracle", dataSourceName:"x", query:"SELECT 1")`,
+			WantErr: true,
+		},
+		{
+			Name:    "no query",
+			Raw:     `fromSQL(driverName:"postgres", dataSourceName:"postgres://localhost/db")`,
+			WantErr: true,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			querytest.NewQueryTestHelper(t, tc)
+		})
+	}
+}
+
+func TestFromSQLOperation_Marshaling(t *testing.T) {
+	data := []byte(`{"id":"fromSQL","kind":"fromSQL","spec":{"driverName":"mysql","dataSourceName":"user@/db","query":"SELECT name FROM hosts"}}`)
+	op := &query.Operation{
+		ID: "fromSQL",
+		Spec: &functions.FromSQLOpSpec{
+			DriverName:     "mysql",
+			DataSourceName: "user@/db",
+			Query:          "SELECT name FROM hosts",
+		},
+	}
+	querytest.OperationMarshalingTestHelper(t, data, op)
+}
+
+// tableCollector is a transformation that converts the tables it processes.
+type tableCollector struct {
+	tables []*executetest.Table
+	err    error
+}
+
+func (c *tableCollector) RetractTable(id execute.DatasetID, key query.GroupKey) error {
+	return nil
+}
+func (c *tableCollector) Process(id execute.DatasetID, tbl query.Table) error {
+	t, err := executetest.ConvertTable(tbl)
+	if err != nil {
+		return err
+	}
+	c.tables = append(c.tables, t)
+	return nil
+}
+func (c *tableCollector) UpdateWatermark(id execute.DatasetID, t execute.Time) error {
+	return nil
+}
+func (c *tableCollector) UpdateProcessingTime(id execute.DatasetID, t execute.Time) error {
+	return nil
+}
+func (c *tableCollector) Finish(id execute.DatasetID, err error) {
+	c.err = err
+}
+
+// newSQLiteDB creates a SQLite database in a temporary directory and runs the statements in it.
+func newSQLiteDB(t *testing.T, stmts ...string) (string, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dsn := filepath.Join(dir, "test.db")
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dsn, func() { os.RemoveAll(dir) }
+}
+
+func TestSQLSource_Run(t *testing.T) {
+	dsn, cleanup := newSQLiteDB(t,
+		`CREATE TABLE hosts (name VARCHAR(64), cores INTEGER, load REAL, active BOOLEAN, installed DATETIME)`,
+		`INSERT INTO hosts VALUES ('a', 4, 0.5, 1, '2018-05-01T10:00:00Z')`,
+		`INSERT INTO hosts VALUES ('b', NULL, 1.25, 0, NULL)`,
+	)
+	defer cleanup()
+
+	spec := &functions.FromSQLProcedureSpec{
+		DriverName:     "sqlite3",
+		DataSourceName: dsn,
+		Query:          "SELECT * FROM hosts ORDER BY name",
+	}
+	src := functions.NewSQLSource(spec, executetest.RandomDatasetID(), executetest.UnlimitedAllocator)
+	c := new(tableCollector)
+	src.AddTransformation(c)
+	src.Run(context.Background())
+	if c.err != nil {
+		t.Fatal(c.err)
+	}
+
+	want := []*executetest.Table{{
+		ColMeta: []query.ColMeta{
+			{Label: "name", Type: query.TString},
+			{Label: "cores", Type: query.TInt},
+			{Label: "load", Type: query.TFloat},
+			{Label: "active", Type: query.TBool},
+			{Label: "installed", Type: query.TTime},
+		},
+		Data: [][]interface{}{
+			{"a", int64(4), 0.5, true, execute.Time(time.Date(2018, 5, 1, 10, 0, 0, 0, time.UTC).UnixNano())},
+			{"b", nil, 1.25, false, nil},
+		},
+	}}
+	executetest.NormalizeTables(want)
+	executetest.NormalizeTables(c.tables)
+	if !cmp.Equal(want, c.tables) {
+		t.Errorf("unexpected tables -want/+got\n%s", cmp.Diff(want, c.tables))
+	}
+}
+
+func TestSQLSource_RunError(t *testing.T) {
+	dsn, cleanup := newSQLiteDB(t)
+	defer cleanup()
+
+	spec := &functions.FromSQLProcedureSpec{
+		DriverName:     "sqlite3",
+		DataSourceName: dsn,
+		Query:          "SELECT * FROM missing",
+	}
+	src := functions.NewSQLSource(spec, executetest.RandomDatasetID(), executetest.UnlimitedAllocator)
+	c := new(tableCollector)
+	src.AddTransformation(c)
+	src.Run(context.Background())
+	if c.err == nil {
+		t.Fatal("expected error, got none")
+	}
+	if len(c.tables) != 0 {
+		t.Errorf("unexpected tables: %v", c.tables)
+	}
+}
+
+func TestSQLDependencies_Allow(t *testing.T) {
+	deps := functions.SQLDependencies{
+		DataSources: map[string][]string{
+			"sqlite3":  {"file:metrics.db"},
+			"postgres": {functions.AnySQLDataSource},
+		},
+	}
+	if err := deps.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		name           string
+		driverName     string
+		dataSourceName string
+		wantErr        bool
+	}{
+		{
+			name:           "allowed data source",
+			driverName:     "sqlite3",
+			dataSourceName: "file:metrics.db",
+		},
+		{
+			name:           "other data source",
+			driverName:     "sqlite3",
+			dataSourceName: "file:/etc/passwd",
+			wantErr:        true,
+		},
+		{
+			name:           "any data source",
+			driverName:     "postgres",
+			dataSourceName: "postgres://localhost/metrics",
+		},
+		{
+			name:           "other driver",
+			driverName:     "mysql",
+			dataSourceName: "user@/db",
+			wantErr:        true,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			if err := deps.Allow(tc.driverName, tc.dataSourceName); (err != nil) != tc.wantErr {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestSQLDependencies_Validate(t *testing.T) {
+	deps := functions.SQLDependencies{
+		DataSources: map[string][]string{
+			"oracle": {functions.AnySQLDataSource},
+		},
+	}
+	if err := deps.Validate(); err == nil {
+		t.Fatal("expected error for unregistered driver")
+	}
+}
@@ -257,6 +257,7 @@ func createGroupTransformation(id execute.DatasetID, mode execute.AccumulationMo
 		return nil, nil, fmt.Errorf("invalid spec type %T", spec)
 	}
 	cache := execute.NewTableBuilderCache(a.Allocator())
+	cache.SetSpiller(a.Spiller())
 	d := execute.NewDataset(id, mode, cache)
 	t := NewGroupTransformation(d, cache, s)
 	return t, d, nil
@@ -315,7 +316,7 @@ func (t *groupTransformation) Process(id execute.DatasetID, tbl query.Table) err
 			colMap = execute.ColMap(colMap, builder, cr)
 			execute.AppendMappedRecord(i, cr, builder, colMap)
 		}
-		return nil
+		return execute.SpillIfNeeded(t.cache)
 	})
 }
 
@@ -0,0 +1,477 @@
+package functions
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/execute"
+	"github.com/EMCECS/influx/query/plan"
+	"github.com/EMCECS/influx/query/semantic"
+)
+
+const HoltWintersKind = "holtWinters"
+
+// MaxHoltWintersN is the largest number of values holtWinters may forecast.
+const MaxHoltWintersN = 1000000
+
+// HoltWintersOpSpec forecasts the values of a column with the Holt-Winters method.
+type HoltWintersOpSpec struct {
+	// N is the number of values to forecast.
+	N int64 `json:"n"`
+	// Seasonality is the number of values in a season, zero means the values are not seasonal.
+	Seasonality int64 `json:"seasonality"`
+	// Interval is the time between two values of the series, values are bucketed by interval.
+	Interval query.Duration `json:"interval"`
+	// WithFit adds the values fitted by the model for the input times to the output.
+	WithFit    bool   `json:"withFit"`
+	Column     string `json:"column"`
+	TimeColumn string `json:"timeColumn"`
+}
+
+var holtWintersSignature = query.DefaultFunctionSignature()
+
+func init() {
+	holtWintersSignature.Params["n"] = semantic.Int
+	holtWintersSignature.Params["seasonality"] = semantic.Int
+	holtWintersSignature.Params["interval"] = semantic.Duration
+	holtWintersSignature.Params["withFit"] = semantic.Bool
+	holtWintersSignature.Params["column"] = semantic.String
+	holtWintersSignature.Params["timeColumn"] = semantic.String
+
+	query.RegisterFunction(HoltWintersKind, createHoltWintersOpSpec, holtWintersSignature)
+	query.RegisterOpSpec(HoltWintersKind, newHoltWintersOp)
+	plan.RegisterProcedureSpec(HoltWintersKind, newHoltWintersProcedure, HoltWintersKind)
+	execute.RegisterTransformation(HoltWintersKind, createHoltWintersTransformation)
+}
+
+func createHoltWintersOpSpec(args query.Arguments, a *query.Administration) (query.OperationSpec, error) {
+	if err := a.AddParentFromArgs(args); err != nil {
+		return nil, err
+	}
+
+	spec := &HoltWintersOpSpec{
+		Column:     execute.DefaultValueColLabel,
+		TimeColumn: execute.DefaultTimeColLabel,
+	}
+
+	n, err := args.GetRequiredInt("n")
+	if err != nil {
+		return nil, err
+	}
+	if n <= 0 {
+		return nil, fmt.Errorf("n must be positive, got %d", n)
+	}
+	if n > MaxHoltWintersN {
+		return nil, fmt.Errorf("n must not be greater than %d, got %d", MaxHoltWintersN, n)
+	}
+	spec.N = n
+
+	if seasonality, ok, err := args.GetInt("seasonality"); err != nil {
+		return nil, err
+	} else if ok {
+		if seasonality < 0 {
+			return nil, fmt.Errorf("seasonality must not be negative, got %d", seasonality)
+		}
+		spec.Seasonality = seasonality
+	}
+
+	interval, err := args.GetRequiredDuration("interval")
+	if err != nil {
+		return nil, err
+	}
+	if interval <= 0 {
+		return nil, errors.New("interval must be positive")
+	}
+	spec.Interval = interval
+
+	if withFit, ok, err := args.GetBool("withFit"); err != nil {
+		return nil, err
+	} else if ok {
+		spec.WithFit = withFit
+	}
+	if col, ok, err := args.GetString("column"); err != nil {
+		return nil, err
+	} else if ok {
+		spec.Column = col
+	}
+	if col, ok, err := args.GetString("timeColumn"); err != nil {
+		return nil, err
+	} else if ok {
+		spec.TimeColumn = col
+	}
+	return spec, nil
+}
+
+func newHoltWintersOp() query.OperationSpec {
+	return new(HoltWintersOpSpec)
+}
+
+func (s *HoltWintersOpSpec) Kind() query.OperationKind {
+	return HoltWintersKind
+}
+
+type HoltWintersProcedureSpec struct {
+	N           int64
+	Seasonality int64
+	Interval    query.Duration
+	WithFit     bool
+	Column      string
+	TimeColumn  string
+}
+
+func newHoltWintersProcedure(qs query.OperationSpec, pa plan.Administration) (plan.ProcedureSpec, error) {
+	spec, ok := qs.(*HoltWintersOpSpec)
+	if !ok {
+		return nil, fmt.Errorf("invalid spec type %T", qs)
+	}
+
+	return &HoltWintersProcedureSpec{
+		N:           spec.N,
+		Seasonality: spec.Seasonality,
+		Interval:    spec.Interval,
+		WithFit:     spec.WithFit,
+		Column:      spec.Column,
+		TimeColumn:  spec.TimeColumn,
+	}, nil
+}
+
+func (s *HoltWintersProcedureSpec) Kind() plan.ProcedureKind {
+	return HoltWintersKind
+}
+func (s *HoltWintersProcedureSpec) Copy() plan.ProcedureSpec {
+	ns := new(HoltWintersProcedureSpec)
+	*ns = *s
+	return ns
+}
+
+func createHoltWintersTransformation(id execute.DatasetID, mode execute.AccumulationMode, spec plan.ProcedureSpec, a execute.Administration) (execute.Transformation, execute.Dataset, error) {
+	s, ok := spec.(*HoltWintersProcedureSpec)
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid spec type %T", spec)
+	}
+	cache := execute.NewTableBuilderCache(a.Allocator())
+	d := execute.NewDataset(id, mode, cache)
+	t := NewHoltWintersTransformation(d, cache, a.Allocator(), s)
+	return t, d, nil
+}
+
+type holtWintersTransformation struct {
+	d     execute.Dataset
+	cache execute.TableBuilderCache
+	alloc *execute.Allocator
+
+	n           int
+	seasonality int
+	interval    execute.Duration
+	withFit     bool
+	column      string
+	timeColumn  string
+}
+
+func NewHoltWintersTransformation(d execute.Dataset, cache execute.TableBuilderCache, alloc *execute.Allocator, spec *HoltWintersProcedureSpec) *holtWintersTransformation {
+	return &holtWintersTransformation{
+		d:           d,
+		cache:       cache,
+		alloc:       alloc,
+		n:           int(spec.N),
+		seasonality: int(spec.Seasonality),
+		interval:    execute.Duration(spec.Interval),
+		withFit:     spec.WithFit,
+		column:      spec.Column,
+		timeColumn:  spec.TimeColumn,
+	}
+}
+
+func (t *holtWintersTransformation) RetractTable(id execute.DatasetID, key query.GroupKey) error {
+	return t.d.RetractTable(key)
+}
+
+func (t *holtWintersTransformation) Process(id execute.DatasetID, tbl query.Table) error {
+	if t.interval <= 0 {
+		return errors.New("holtWinters requires a positive interval")
+	}
+	cols := tbl.Cols()
+	valueIdx := execute.ColIdx(t.column, cols)
+	if valueIdx < 0 {
+		return fmt.Errorf("column %q does not exist", t.column)
+	}
+	timeIdx := execute.ColIdx(t.timeColumn, cols)
+	if timeIdx < 0 {
+		return fmt.Errorf("time column %q does not exist", t.timeColumn)
+	}
+	if typ := cols[timeIdx].Type; typ != query.TTime {
+		return fmt.Errorf("time column %q has type %v", t.timeColumn, typ)
+	}
+	if tbl.Key().HasCol(t.column) || tbl.Key().HasCol(t.timeColumn) {
+		return errors.New("holtWinters cannot forecast columns that are part of the group key")
+	}
+	valueType := cols[valueIdx].Type
+	switch valueType {
+	case query.TInt, query.TUInt, query.TFloat:
+	default:
+		return fmt.Errorf("holtWinters does not support %v", valueType)
+	}
+
+	builder, created := t.cache.TableBuilder(tbl.Key())
+	if !created {
+		return fmt.Errorf("holtWinters found duplicate table with key: %v", tbl.Key())
+	}
+
+	execute.AddTableKeyCols(tbl.Key(), builder)
+	builderTimeIdx := builder.AddCol(query.ColMeta{
+		Label: t.timeColumn,
+		Type:  query.TTime,
+	})
+	builderValueIdx := builder.AddCol(query.ColMeta{
+		Label: t.column,
+		Type:  query.TFloat,
+	})
+
+	// Buffer the non null values of the table, accounting for them with the allocator.
+	var (
+		times []execute.Time
+		vs    []float64
+	)
+	defer func() {
+		t.alloc.Free(cap(times), 8)
+		t.alloc.Free(cap(vs), 8)
+	}()
+	err := tbl.Do(func(cr query.ColReader) error {
+		for i := 0; i < cr.Len(); i++ {
+			if execute.IsNull(i, valueIdx, cr) || execute.IsNull(i, timeIdx, cr) {
+				continue
+			}
+			var v float64
+			switch valueType {
+			case query.TInt:
+				v = float64(cr.Ints(valueIdx)[i])
+			case query.TUInt:
+				v = float64(cr.UInts(valueIdx)[i])
+			case query.TFloat:
+				v = cr.Floats(valueIdx)[i]
+			}
+			times = t.alloc.AppendTimes(times, cr.Times(timeIdx)[i])
+			vs = t.alloc.AppendFloats(vs, v)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(times) == 0 {
+		return nil
+	}
+
+	// Bucket the values by interval, missing values are NaN.
+	start, stop := times[0], times[0]
+	for _, tm := range times {
+		if tm < start {
+			start = tm
+		}
+		if tm > stop {
+			stop = tm
+		}
+	}
+	start = start.Truncate(t.interval)
+	l := int((stop-start)/execute.Time(t.interval)) + 1
+	y := t.alloc.Floats(l, l)
+	defer t.alloc.Free(l, 8)
+	for k := range y {
+		y[k] = math.NaN()
+	}
+	for i, tm := range times {
+		k := int((tm - start) / execute.Time(t.interval))
+		if math.IsNaN(y[k]) {
+			// Keep the first value of each interval.
+			y[k] = vs[i]
+		}
+	}
+
+	hw := &holtWinters{y: y, m: t.seasonality}
+	if l < 2 || l < hw.m {
+		// There are not enough values to fit the model.
+		return nil
+	}
+	hw.seasonal = t.alloc.Floats(hw.m, hw.m)
+	defer t.alloc.Free(hw.m, 8)
+	forecast := t.alloc.Floats(l+t.n, l+t.n)
+	defer t.alloc.Free(l+t.n, 8)
+	hw.run(hw.fit(), t.n, forecast)
+
+	first := l
+	if t.withFit {
+		first = 0
+	}
+	for k := first; k < len(forecast); k++ {
+		execute.AppendKeyValues(tbl.Key(), builder)
+		builder.AppendTime(builderTimeIdx, start+execute.Time(k)*execute.Time(t.interval))
+		builder.AppendFloat(builderValueIdx, forecast[k])
+	}
+	return nil
+}
+
+func (t *holtWintersTransformation) UpdateWatermark(id execute.DatasetID, mark execute.Time) error {
+	return t.d.UpdateWatermark(mark)
+}
+func (t *holtWintersTransformation) UpdateProcessingTime(id execute.DatasetID, pt execute.Time) error {
+	return t.d.UpdateProcessingTime(pt)
+}
+func (t *holtWintersTransformation) Finish(id execute.DatasetID, err error) {
+	t.d.Finish(err)
+}
+
+// holtWinters is the additive Holt-Winters model of a series of values at regular intervals.
+// The level, trend and seasonal components of the model are smoothed by alpha, beta and gamma.
+// Without seasonality the model is Holt's linear trend method.
+type holtWinters struct {
+	// y is the series, missing values are NaN.
+	y []float64
+	// m is the number of values in a season, zero means no seasonality.
+	m int
+	// seasonal holds the m seasonal components while the model runs.
+	seasonal []float64
+}
+
+// holtWintersParams are the smoothing parameters of the level, trend and seasonal components.
+type holtWintersParams [3]float64
+
+// fit returns the smoothing parameters that minimize the sum of the squared errors of the
+// one step ahead forecasts. A coarse grid search is refined by a pattern search.
+func (hw *holtWinters) fit() holtWintersParams {
+	dims := 2
+	if hw.m > 0 {
+		dims = 3
+	}
+
+	var best holtWintersParams
+	minSSE := math.Inf(1)
+	const gridSteps = 10
+	var p holtWintersParams
+	var search func(d int)
+	search = func(d int) {
+		if d == dims {
+			if sse := hw.run(p, 0, nil); sse < minSSE {
+				minSSE, best = sse, p
+			}
+			return
+		}
+		for i := 0; i <= gridSteps; i++ {
+			p[d] = float64(i) / gridSteps
+			search(d + 1)
+		}
+	}
+	search(0)
+
+	for step := 0.5 / gridSteps; step > 1e-4; step /= 2 {
+		for improved := true; improved; {
+			improved = false
+			for d := 0; d < dims; d++ {
+				for _, delta := range []float64{-step, step} {
+					p := best
+					p[d] = math.Min(1, math.Max(0, p[d]+delta))
+					if sse := hw.run(p, 0, nil); sse < minSSE {
+						minSSE, best = sse, p
+						improved = true
+					}
+				}
+			}
+		}
+	}
+	return best
+}
+
+// run applies the model with the parameters p to the series and returns the sum of the squared errors
+// of the one step ahead forecasts. When out is not nil, it receives the fitted values of the series
+// followed by h forecasted values.
+func (hw *holtWinters) run(p holtWintersParams, h int, out []float64) float64 {
+	alpha, beta, gamma := p[0], p[1], p[2]
+	y, m := hw.y, hw.m
+
+	level, trend, seasonal := hw.initial()
+	// Start one step before the first value, so that its forecast is the first value.
+	level -= trend
+
+	season := func(t int) float64 {
+		if m == 0 {
+			return 0
+		}
+		return seasonal[t%m]
+	}
+
+	sse := 0.0
+	for t, v := range y {
+		f := level + trend + season(t)
+		if out != nil {
+			out[t] = f
+		}
+		if math.IsNaN(v) {
+			// Use the forecast in place of missing values.
+			v = f
+		} else {
+			sse += (v - f) * (v - f)
+		}
+		prevLevel := level
+		level = alpha*(v-season(t)) + (1-alpha)*(level+trend)
+		trend = beta*(level-prevLevel) + (1-beta)*trend
+		if m > 0 {
+			seasonal[t%m] = gamma*(v-level) + (1-gamma)*seasonal[t%m]
+		}
+	}
+	last := len(y) - 1
+	for k := 1; k <= h; k++ {
+		out[last+k] = level + float64(k)*trend + season(last+k)
+	}
+	return sse
+}
+
+// initial returns the initial level, trend and seasonal components of the model.
+// The series starts with a value that is not NaN.
+func (hw *holtWinters) initial() (level, trend float64, seasonal []float64) {
+	y, m := hw.y, hw.m
+	if m == 0 {
+		level = y[0]
+		if len(y) > 1 && !math.IsNaN(y[1]) {
+			trend = y[1] - y[0]
+		}
+		return level, trend, nil
+	}
+
+	level = seasonMean(y[:m])
+	if len(y) >= 2*m {
+		n := 0
+		for i := 0; i < m; i++ {
+			if !math.IsNaN(y[i]) && !math.IsNaN(y[m+i]) {
+				trend += (y[m+i] - y[i]) / float64(m)
+				n++
+			}
+		}
+		if n > 0 {
+			trend /= float64(n)
+		}
+	}
+	seasonal = hw.seasonal
+	for i := range seasonal {
+		seasonal[i] = 0
+		if !math.IsNaN(y[i]) {
+			seasonal[i] = y[i] - level
+		}
+	}
+	return level, trend, seasonal
+}
+
+// seasonMean returns the mean of the values of a season that are not NaN.
+func seasonMean(y []float64) float64 {
+	sum, n := 0.0, 0
+	for _, v := range y {
+		if !math.IsNaN(v) {
+			sum += v
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
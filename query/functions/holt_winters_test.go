@@ -0,0 +1,266 @@
+package functions_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/execute"
+	"github.com/EMCECS/influx/query/execute/executetest"
+	"github.com/EMCECS/influx/query/functions"
+	"github.com/EMCECS/influx/query/querytest"
+)
+
+func TestHoltWinters_NewQuery(t *testing.T) {
+	tests := []querytest.NewQueryTestCase{
+		{
+			Name: "holt winters",
+			Raw:  `from(bucket:"mydb") |> holtWinters(n:10, seasonality:4, interval:1m, withFit:true)`,
+			Want: &query.Spec{
+				Operations: []*query.Operation{
+					{
+						ID: "from0",
+						Spec: &functions.FromOpSpec{
+							Bucket: "mydb",
+						},
+					},
+					{
+						ID: "holtWinters1",
+						Spec: &functions.HoltWintersOpSpec{
+							N:           10,
+							Seasonality: 4,
+							Interval:    query.Duration(time.Minute),
+							WithFit:     true,
+							Column:      "_value",
+							TimeColumn:  "_time",
+						},
+					},
+				},
+				Edges: []query.Edge{
+					{Parent: "from0", Child: "holtWinters1"},
+				},
+			},
+		},
+		{
+			Name:    "missing interval",
+			Raw:     `from(bucket:"mydb") |> holtWinters(n:10)`,
+			WantErr: true,
+		},
+		{
+			Name:    "n too large",
+			Raw:     `from(bucket:"mydb") |> holtWinters(n:1000001, interval:1m)`,
+			WantErr: true,
+		},
+		{
+			Name:    "negative seasonality",
+			Raw:     `from(bucket:"mydb") |> holtWinters(n:10, seasonality:-1, interval:1m)`,
+			WantErr: true,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			querytest.NewQueryTestHelper(t, tc)
+		})
+	}
+}
+
+func TestHoltWintersOperation_Marshaling(t *testing.T) {
+	data := []byte(`{"id":"holtWinters","kind":"holtWinters","spec":{"n":3,"seasonality":2,"interval":"1h","withFit":true,"column":"_value","timeColumn":"_time"}}`)
+	op := &query.Operation{
+		ID: "holtWinters",
+		Spec: &functions.HoltWintersOpSpec{
+			N:           3,
+			Seasonality: 2,
+			Interval:    query.Duration(time.Hour),
+			WithFit:     true,
+			Column:      "_value",
+			TimeColumn:  "_time",
+		},
+	}
+	querytest.OperationMarshalingTestHelper(t, data, op)
+}
+
+func TestHoltWinters_Process(t *testing.T) {
+	s := func(n int64) execute.Time {
+		return execute.Time(n * int64(time.Second))
+	}
+	testCases := []struct {
+		name    string
+		spec    *functions.HoltWintersProcedureSpec
+		data    []query.Table
+		want    []*executetest.Table
+		wantErr error
+	}{
+		{
+			name: "linear trend",
+			spec: &functions.HoltWintersProcedureSpec{
+				N:          3,
+				Interval:   query.Duration(time.Second),
+				Column:     "_value",
+				TimeColumn: "_time",
+			},
+			data: []query.Table{&executetest.Table{
+				KeyCols: []string{"t1"},
+				ColMeta: []query.ColMeta{
+					{Label: "t1", Type: query.TString},
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TFloat},
+				},
+				Data: [][]interface{}{
+					{"a", s(0), 1.0},
+					{"a", s(1), 2.0},
+					{"a", s(2), 3.0},
+					{"a", s(3), 4.0},
+					{"a", s(4), 5.0},
+				},
+			}},
+			want: []*executetest.Table{{
+				KeyCols: []string{"t1"},
+				ColMeta: []query.ColMeta{
+					{Label: "t1", Type: query.TString},
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TFloat},
+				},
+				Data: [][]interface{}{
+					{"a", s(5), 6.0},
+					{"a", s(6), 7.0},
+					{"a", s(7), 8.0},
+				},
+			}},
+		},
+		{
+			name: "seasonal with fit",
+			spec: &functions.HoltWintersProcedureSpec{
+				N:           2,
+				Seasonality: 2,
+				Interval:    query.Duration(time.Second),
+				WithFit:     true,
+				Column:      "_value",
+				TimeColumn:  "_time",
+			},
+			data: []query.Table{&executetest.Table{
+				ColMeta: []query.ColMeta{
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TInt},
+				},
+				Data: [][]interface{}{
+					{s(0), int64(1)},
+					{s(1), int64(3)},
+					{s(2), int64(1)},
+					{s(3), int64(3)},
+					{s(4), int64(1)},
+					{s(5), int64(3)},
+				},
+			}},
+			want: []*executetest.Table{{
+				ColMeta: []query.ColMeta{
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TFloat},
+				},
+				Data: [][]interface{}{
+					{s(0), 1.0},
+					{s(1), 3.0},
+					{s(2), 1.0},
+					{s(3), 3.0},
+					{s(4), 1.0},
+					{s(5), 3.0},
+					{s(6), 1.0},
+					{s(7), 3.0},
+				},
+			}},
+		},
+		{
+			name: "missing and unaligned values",
+			spec: &functions.HoltWintersProcedureSpec{
+				N:          1,
+				Interval:   query.Duration(time.Second),
+				Column:     "_value",
+				TimeColumn: "_time",
+			},
+			data: []query.Table{&executetest.Table{
+				ColMeta: []query.ColMeta{
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TFloat},
+				},
+				Data: [][]interface{}{
+					{s(10), 1.0},
+					{s(11), 2.0},
+					{s(11) + execute.Time(time.Millisecond), 100.0},
+					{s(12), nil},
+					{s(13), 4.0},
+					{s(14), 5.0},
+				},
+			}},
+			want: []*executetest.Table{{
+				ColMeta: []query.ColMeta{
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TFloat},
+				},
+				Data: [][]interface{}{
+					{s(15), 6.0},
+				},
+			}},
+		},
+		{
+			name: "not enough values",
+			spec: &functions.HoltWintersProcedureSpec{
+				N:          1,
+				Interval:   query.Duration(time.Second),
+				Column:     "_value",
+				TimeColumn: "_time",
+			},
+			data: []query.Table{&executetest.Table{
+				ColMeta: []query.ColMeta{
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TFloat},
+				},
+				Data: [][]interface{}{
+					{s(1), 1.0},
+				},
+			}},
+			want: []*executetest.Table{{
+				ColMeta: []query.ColMeta{
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TFloat},
+				},
+			}},
+		},
+		{
+			name: "string column",
+			spec: &functions.HoltWintersProcedureSpec{
+				N:          1,
+				Interval:   query.Duration(time.Second),
+				Column:     "_value",
+				TimeColumn: "_time",
+			},
+			data: []query.Table{&executetest.Table{
+				ColMeta: []query.ColMeta{
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TString},
+				},
+				Data: [][]interface{}{
+					{s(1), "a"},
+				},
+			}},
+			want:    []*executetest.Table(nil),
+			wantErr: errors.New("holtWinters does not support string"),
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			executetest.ProcessTestHelper(
+				t,
+				tc.data,
+				tc.want,
+				tc.wantErr,
+				func(d execute.Dataset, c execute.TableBuilderCache) execute.Transformation {
+					return functions.NewHoltWintersTransformation(d, c, executetest.UnlimitedAllocator, tc.spec)
+				},
+			)
+		})
+	}
+}
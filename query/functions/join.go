@@ -21,6 +21,9 @@ const MergeJoinKind = "merge-join"
 // All supported join types in Flux
 var methods map[string]bool = map[string]bool{
 	"inner": true,
+	"left":  true,
+	"right": true,
+	"full":  true,
 }
 
 type JoinOpSpec struct {
@@ -31,45 +34,14 @@ type JoinOpSpec struct {
 	// TODO(nathanielc): Change this to a map of parent operation IDs to names.
 	// Then make it possible for the transformation to map operation IDs to parent IDs.
 	TableNames map[query.OperationID]string `json:"tableNames"`
-	// Method is a the type of join to perform
+	// Method is a the type of join to perform.
+	// The tables are ordered as they are declared, a left join keeps all rows of the first table
+	// and a right join keeps all rows of the last table.
 	Method string `json:"method"`
 	// tableNames maps each TableObject being joined to the parameter that holds it.
 	tableNames map[*query.TableObject]string
 }
 
-type params struct {
-	vars []string
-	vals []*query.TableObject
-}
-
-type joinParams params
-
-func newJoinParams(capacity int) *joinParams {
-	params := &joinParams{
-		vars: make([]string, 0, capacity),
-		vals: make([]*query.TableObject, 0, capacity),
-	}
-	return params
-}
-
-func (params *joinParams) add(newVar string, newVal *query.TableObject) {
-	params.vars = append(params.vars, newVar)
-	params.vals = append(params.vals, newVal)
-}
-
-// joinParams implements the Sort interface in order
-// to build the query spec in a consistent manner.
-func (params *joinParams) Len() int {
-	return len(params.vals)
-}
-func (params *joinParams) Swap(i, j int) {
-	params.vars[i], params.vars[j] = params.vars[j], params.vars[i]
-	params.vals[i], params.vals[j] = params.vals[j], params.vals[i]
-}
-func (params *joinParams) Less(i, j int) bool {
-	return params.vars[i] < params.vars[j]
-}
-
 var joinSignature = semantic.FunctionSignature{
 	Params: map[string]semantic.Type{
 		"tables": semantic.Object,
@@ -91,7 +63,6 @@ func init() {
 func createJoinOpSpec(args query.Arguments, a *query.Administration) (query.OperationSpec, error) {
 	spec := &JoinOpSpec{
 		TableNames: make(map[query.OperationID]string),
-		tableNames: make(map[*query.TableObject]string),
 	}
 
 	// On specifies the columns to join on. If 'on' is not present in the arguments
@@ -123,12 +94,26 @@ func createJoinOpSpec(args query.Arguments, a *query.Administration) (query.Oper
 		return nil, errors.New("cross product and 'on' are mutually exclusive")
 	}
 
+	tableNames, err := addJoinParents(args, a)
+	if err != nil {
+		return nil, err
+	}
+	spec.tableNames = tableNames
+
+	return spec, nil
+}
+
+// addJoinParents adds the tables of the join arguments as parents of the operation.
+// It returns the name of each table.
+func addJoinParents(args query.Arguments, a *query.Administration) (map[*query.TableObject]string, error) {
 	tables, err := args.GetRequiredObject("tables")
 	if err != nil {
 		return nil, err
 	}
 
-	joinParams := newJoinParams(tables.Len())
+	// The parents are added in the order the tables are declared,
+	// which is the order of the tables of the join method.
+	tableNames := make(map[*query.TableObject]string, tables.Len())
 	tables.Range(func(k string, t values.Value) {
 		if err != nil {
 			return
@@ -142,21 +127,13 @@ func createJoinOpSpec(args query.Arguments, a *query.Administration) (query.Oper
 			return
 		}
 		p := t.(*query.TableObject)
-		joinParams.add(k, p)
-		spec.tableNames[p] = k
+		a.AddParent(p)
+		tableNames[p] = k
 	})
 	if err != nil {
 		return nil, err
 	}
-
-	// Add parents in a consistent manner by sorting
-	// based on their corresponding function parameter.
-	sort.Sort(joinParams)
-	for _, p := range joinParams.vals {
-		a.AddParent(p)
-	}
-
-	return spec, nil
+	return tableNames, nil
 }
 
 func (t *JoinOpSpec) IDer(ider query.IDer) {
@@ -176,6 +153,7 @@ func (s *JoinOpSpec) Kind() query.OperationKind {
 type MergeJoinProcedureSpec struct {
 	On         []string                    `json:"keys"`
 	TableNames map[plan.ProcedureID]string `json:"table_names"`
+	Method     string                      `json:"method"`
 }
 
 func newMergeJoinProcedure(qs query.OperationSpec, pa plan.Administration) (plan.ProcedureSpec, error) {
@@ -184,20 +162,25 @@ func newMergeJoinProcedure(qs query.OperationSpec, pa plan.Administration) (plan
 		return nil, fmt.Errorf("invalid spec type %T", qs)
 	}
 
-	tableNames := make(map[plan.ProcedureID]string, len(spec.TableNames))
-	for qid, name := range spec.TableNames {
-		pid := pa.ConvertID(qid)
-		tableNames[pid] = name
-	}
-
 	p := &MergeJoinProcedureSpec{
 		On:         spec.On,
-		TableNames: tableNames,
+		TableNames: procedureTableNames(spec.TableNames, pa),
+		Method:     spec.Method,
 	}
 	sort.Strings(p.On)
 	return p, nil
 }
 
+// procedureTableNames converts the table names of the operation parents to the table names of the procedure parents.
+func procedureTableNames(names map[query.OperationID]string, pa plan.Administration) map[plan.ProcedureID]string {
+	tableNames := make(map[plan.ProcedureID]string, len(names))
+	for qid, name := range names {
+		pid := pa.ConvertID(qid)
+		tableNames[pid] = name
+	}
+	return tableNames
+}
+
 func (s *MergeJoinProcedureSpec) Kind() plan.ProcedureKind {
 	return MergeJoinKind
 }
@@ -207,6 +190,12 @@ func (s *MergeJoinProcedureSpec) Copy() plan.ProcedureSpec {
 	ns.On = make([]string, len(s.On))
 	copy(ns.On, s.On)
 
+	ns.TableNames = make(map[plan.ProcedureID]string, len(s.TableNames))
+	for id, name := range s.TableNames {
+		ns.TableNames[id] = name
+	}
+	ns.Method = s.Method
+
 	return ns
 }
 
@@ -223,21 +212,25 @@ func createMergeJoinTransformation(id execute.DatasetID, mode execute.Accumulati
 		return nil, nil, fmt.Errorf("invalid spec type %T", spec)
 	}
 	parents := a.Parents()
-	if len(parents) != 2 {
-		//TODO(nathanielc): Support n-way joins
-		return nil, nil, errors.New("joins currently must only have two parents")
+	if len(parents) < 2 {
+		return nil, nil, errors.New("joins must have at least two parents")
 	}
 
-	tableNames := make(map[execute.DatasetID]string, len(s.TableNames))
-	for pid, name := range s.TableNames {
+	cache := NewMergeJoinCache(a.Allocator(), parents, datasetTableNames(s.TableNames, a), s.On, s.Method)
+	cache.SetSpiller(a.Spiller())
+	d := execute.NewDataset(id, mode, cache)
+	t := NewMergeJoinTransformation(d, cache, s, parents)
+	return t, d, nil
+}
+
+// datasetTableNames converts the table names of the procedure parents to the table names of the parent datasets.
+func datasetTableNames(names map[plan.ProcedureID]string, a execute.Administration) map[execute.DatasetID]string {
+	tableNames := make(map[execute.DatasetID]string, len(names))
+	for pid, name := range names {
 		id := a.ConvertID(pid)
 		tableNames[id] = name
 	}
-
-	cache := NewMergeJoinCache(a.Allocator(), parents, tableNames, s.On)
-	d := execute.NewDataset(id, mode, cache)
-	t := NewMergeJoinTransformation(d, cache, s, parents, tableNames)
-	return t, d, nil
+	return tableNames
 }
 
 type mergeJoinTransformation struct {
@@ -248,23 +241,21 @@ type mergeJoinTransformation struct {
 	d     execute.Dataset
 	cache *MergeJoinCache
 
-	leftID, rightID     execute.DatasetID
-	leftName, rightName string
-
 	parentState map[execute.DatasetID]*mergeJoinParentState
 
 	keys []string
 }
 
-func NewMergeJoinTransformation(d execute.Dataset, cache *MergeJoinCache, spec *MergeJoinProcedureSpec, parents []execute.DatasetID, tableNames map[execute.DatasetID]string) *mergeJoinTransformation {
+func NewMergeJoinTransformation(d execute.Dataset, cache *MergeJoinCache, spec *MergeJoinProcedureSpec, parents []execute.DatasetID) *mergeJoinTransformation {
+	return newMergeJoinTransformation(d, cache, spec.On, parents)
+}
+
+func newMergeJoinTransformation(d execute.Dataset, cache *MergeJoinCache, keys []string, parents []execute.DatasetID) *mergeJoinTransformation {
 	t := &mergeJoinTransformation{
-		d:         d,
-		cache:     cache,
-		keys:      spec.On,
-		leftID:    parents[0],
-		rightID:   parents[1],
-		leftName:  tableNames[parents[0]],
-		rightName: tableNames[parents[1]],
+		d:       d,
+		cache:   cache,
+		keys:    keys,
+		parents: parents,
 	}
 	t.parentState = make(map[execute.DatasetID]*mergeJoinParentState)
 	for _, id := range parents {
@@ -293,13 +284,13 @@ func (t *mergeJoinTransformation) Process(id execute.DatasetID, tbl query.Table)
 	t.cache.insertIntoBuffer(id, tbl)
 
 	// Check if enough data sources have been seen to produce an output schema
-	if !t.cache.isBufferEmpty(t.leftID) && !t.cache.isBufferEmpty(t.rightID) && !t.cache.postJoinSchemaBuilt() {
+	if !t.cache.postJoinSchemaBuilt() && !t.cache.isAnyBufferEmpty() {
 		t.cache.buildPostJoinSchema()
 	}
 
 	// Register any new output group keys that can be constructed from the new table
 	t.cache.registerKey(id, tbl.Key())
-	return nil
+	return t.cache.spillIfNeeded()
 }
 
 func (t *mergeJoinTransformation) UpdateWatermark(id execute.DatasetID, mark execute.Time) error {
@@ -346,6 +337,8 @@ func (t *mergeJoinTransformation) Finish(id execute.DatasetID, err error) {
 	}
 
 	if finished {
+		// Tables without a match are only known once all streams have finished.
+		t.cache.registerUnmatchedKeys()
 		t.d.Finish(nil)
 	}
 }
@@ -368,8 +361,9 @@ func (t *mergeJoinTransformation) Finish(id execute.DatasetID, err error) {
 // tables:          All output tables are materialized and stored in this
 //                  map before being sent to downstream operators.
 type MergeJoinCache struct {
-	leftID  execute.DatasetID
-	rightID execute.DatasetID
+	// ids are the incoming streams in the order of their tables in the join.
+	ids    []execute.DatasetID
+	method string
 
 	names   map[execute.DatasetID]string
 	schemas map[execute.DatasetID]schema
@@ -385,6 +379,9 @@ type MergeJoinCache struct {
 	postJoinKeys  *execute.GroupLookup
 	reverseLookup map[query.GroupKey]preJoinGroupKeys
 
+	// asof is set when the tables are joined on the closest time, see NewAsofJoinCache.
+	asof *asofJoin
+
 	tables      map[query.GroupKey]query.Table
 	alloc       *execute.Allocator
 	triggerSpec query.TriggerSpec
@@ -395,22 +392,77 @@ type streamBuffer struct {
 	consumed map[values.Value]int
 	ready    map[values.Value]bool
 	stale    map[query.GroupKey]bool
-	last     values.Value
-	alloc    *execute.Allocator
+	// matched holds the group keys of the tables that were joined with a table of every other stream.
+	matched map[query.GroupKey]bool
+	last    values.Value
+	alloc   *execute.Allocator
+
+	// spilled holds the tables spilled to disk sorted by the join columns, their builders are empty.
+	spilled map[query.GroupKey]query.Table
+	// spilledRows holds the number of rows of the spilled tables.
+	spilledRows map[query.GroupKey]int
+	spiller     *execute.Spiller
 }
 
 func newStreamBuffer(alloc *execute.Allocator) *streamBuffer {
 	return &streamBuffer{
-		data:     make(map[query.GroupKey]*execute.ColListTableBuilder),
-		consumed: make(map[values.Value]int),
-		ready:    make(map[values.Value]bool),
-		stale:    make(map[query.GroupKey]bool),
-		alloc:    alloc,
+		data:        make(map[query.GroupKey]*execute.ColListTableBuilder),
+		consumed:    make(map[values.Value]int),
+		ready:       make(map[values.Value]bool),
+		stale:       make(map[query.GroupKey]bool),
+		matched:     make(map[query.GroupKey]bool),
+		alloc:       alloc,
+		spilled:     make(map[query.GroupKey]query.Table),
+		spilledRows: make(map[query.GroupKey]int),
 	}
 }
 
-func (buf *streamBuffer) table(key query.GroupKey) *execute.ColListTableBuilder {
-	return buf.data[key]
+// joinInput is a table buffered by a stream, whose rows are either held by its builder or spilled.
+type joinInput struct {
+	builder *execute.ColListTableBuilder
+	// spilled is the table of the rows spilled sorted by the join columns, it is nil if the rows were not spilled.
+	spilled query.Table
+	nrows   int
+}
+
+// table returns the buffered table with the key, or nil if there is none.
+func (buf *streamBuffer) table(key query.GroupKey) *joinInput {
+	builder, ok := buf.data[key]
+	if !ok {
+		return nil
+	}
+	if tbl, ok := buf.spilled[key]; ok {
+		return &joinInput{builder: builder, spilled: tbl, nrows: buf.spilledRows[key]}
+	}
+	return &joinInput{builder: builder, nrows: builder.NRows()}
+}
+
+// spillIfNeeded spills the buffered tables sorted by the columns cols,
+// if the memory allocated by the query nears its limit.
+func (buf *streamBuffer) spillIfNeeded(cols []string) error {
+	if !buf.spiller.ShouldSpill(buf.alloc) {
+		return nil
+	}
+	for key, builder := range buf.data {
+		if builder.NRows() == 0 {
+			continue
+		}
+		// The rows are joined as they are read back in order.
+		builder.Sort(cols, false)
+		tbl, err := buf.spiller.Spill(builder.RawTable(), buf.alloc)
+		if err != nil {
+			return err
+		}
+		buf.spilled[key] = tbl
+		buf.spilledRows[key] = builder.NRows()
+
+		// Replace the builder so that the memory of its rows is released.
+		empty := execute.NewColListTableBuilder(key, buf.alloc)
+		execute.AddTableCols(tbl, empty)
+		builder.ClearData()
+		buf.data[key] = empty
+	}
+	return nil
 }
 
 func (buf *streamBuffer) insert(table query.Table) {
@@ -423,6 +475,8 @@ func (buf *streamBuffer) insert(table query.Table) {
 
 	// Insert this table into the buffer
 	buf.data[table.Key()] = builder
+	delete(buf.spilled, table.Key())
+	delete(buf.spilledRows, table.Key())
 
 	if len(table.Key().Cols()) > 0 {
 		leftKeyValue := table.Key().Value(0)
@@ -454,6 +508,8 @@ func (buf *streamBuffer) evict(key query.GroupKey) {
 	if builder, ok := buf.data[key]; ok {
 		builder.ClearData()
 		delete(buf.data, key)
+		delete(buf.spilled, key)
+		delete(buf.spilledRows, key)
 	}
 }
 
@@ -476,9 +532,9 @@ type tableCol struct {
 	table, col string
 }
 
-type preJoinGroupKeys struct {
-	left, right query.GroupKey
-}
+// preJoinGroupKeys are the group keys of the tables joined into a single output table,
+// in the order of the streams. A key is nil when the stream has no table in the join.
+type preJoinGroupKeys []query.GroupKey
 
 type schema struct {
 	key     []query.ColMeta
@@ -498,10 +554,12 @@ func (s schema) Swap(i int, j int) {
 }
 
 // NewMergeJoinCache constructs a new instance of a MergeJoinCache
-func NewMergeJoinCache(alloc *execute.Allocator, datasetIDs []execute.DatasetID, tableNames map[execute.DatasetID]string, key []string) *MergeJoinCache {
-	// Join currently only accepts two data sources(streams) as input
-	if len(datasetIDs) != 2 {
-		panic("Join only accepts two data sources")
+func NewMergeJoinCache(alloc *execute.Allocator, datasetIDs []execute.DatasetID, tableNames map[execute.DatasetID]string, key []string, method string) *MergeJoinCache {
+	if len(datasetIDs) < 2 {
+		panic("Join requires at least two data sources")
+	}
+	if method == "" {
+		method = "inner"
 	}
 
 	names := make(map[execute.DatasetID]string, len(datasetIDs))
@@ -522,10 +580,10 @@ func NewMergeJoinCache(alloc *execute.Allocator, datasetIDs []execute.DatasetID,
 	}
 
 	return &MergeJoinCache{
+		ids:           datasetIDs,
+		method:        method,
 		on:            on,
 		intersection:  intersection,
-		leftID:        datasetIDs[0],
-		rightID:       datasetIDs[1],
 		names:         names,
 		schemas:       schemas,
 		buffers:       buffers,
@@ -536,7 +594,15 @@ func NewMergeJoinCache(alloc *execute.Allocator, datasetIDs []execute.DatasetID,
 	}
 }
 
-// Table joins the two tables associated with a single output group key and returns the resulting table
+// SetSpiller lets the buffered tables be spilled with s when the memory allocated by the query nears its limit.
+// A spilled table is read back one set of rows with the same join columns at a time as it is joined, s may be nil.
+func (c *MergeJoinCache) SetSpiller(s *execute.Spiller) {
+	for _, buf := range c.buffers {
+		buf.spiller = s
+	}
+}
+
+// Table joins the tables associated with a single output group key and returns the resulting table
 func (c *MergeJoinCache) Table(key query.GroupKey) (query.Table, error) {
 	preJoinGroupKeys, ok := c.reverseLookup[key]
 
@@ -545,18 +611,12 @@ func (c *MergeJoinCache) Table(key query.GroupKey) (query.Table, error) {
 	}
 
 	if _, ok := c.tables[key]; !ok {
-
-		left := c.buffers[c.leftID].table(preJoinGroupKeys.left)
-		if left == nil {
-			return nil, fmt.Errorf("No table in left join buffer with key: %v", key)
-		}
-
-		right := c.buffers[c.rightID].table(preJoinGroupKeys.right)
-		if left == nil {
-			return nil, fmt.Errorf("No table in right join buffer with key: %v", key)
+		tables, err := c.inputs(preJoinGroupKeys)
+		if err != nil {
+			return nil, err
 		}
 
-		table, err := c.join(left, right)
+		table, err := c.join(preJoinGroupKeys, tables)
 		if err != nil {
 			return nil, fmt.Errorf("Table with group key (%v) could not be fetched", key)
 		}
@@ -566,6 +626,23 @@ func (c *MergeJoinCache) Table(key query.GroupKey) (query.Table, error) {
 	return c.tables[key], nil
 }
 
+// inputs returns the buffered tables of the pre-join group keys.
+// The table of a stream without a table in the join is nil.
+func (c *MergeJoinCache) inputs(keys preJoinGroupKeys) ([]*joinInput, error) {
+	tables := make([]*joinInput, len(keys))
+	for i, key := range keys {
+		if key == nil {
+			continue
+		}
+		id := c.ids[i]
+		tables[i] = c.buffers[id].table(key)
+		if tables[i] == nil {
+			return nil, fmt.Errorf("No table in %s join buffer with key: %v", c.names[id], key)
+		}
+	}
+	return tables, nil
+}
+
 // ForEach iterates over each table in the output stream
 func (c *MergeJoinCache) ForEach(f func(query.GroupKey)) {
 	c.postJoinKeys.Range(func(key query.GroupKey, value interface{}) {
@@ -574,13 +651,13 @@ func (c *MergeJoinCache) ForEach(f func(query.GroupKey)) {
 
 			preJoinGroupKeys := c.reverseLookup[key]
 
-			leftKey := preJoinGroupKeys.left
-			rightKey := preJoinGroupKeys.right
-
-			leftBuilder := c.buffers[c.leftID].table(leftKey)
-			rightBuilder := c.buffers[c.rightID].table(rightKey)
+			tables, err := c.inputs(preJoinGroupKeys)
+			if err != nil {
+				c.DiscardTable(key)
+				return
+			}
 
-			table, err := c.join(leftBuilder, rightBuilder)
+			table, err := c.join(preJoinGroupKeys, tables)
 			if err != nil || table.Empty() {
 				c.DiscardTable(key)
 				return
@@ -600,15 +677,15 @@ func (c *MergeJoinCache) ForEachWithContext(f func(query.GroupKey, execute.Trigg
 
 		preJoinGroupKeys := c.reverseLookup[key]
 
-		leftKey := preJoinGroupKeys.left
-		rightKey := preJoinGroupKeys.right
-
-		leftBuilder := c.buffers[c.leftID].table(leftKey)
-		rightBuilder := c.buffers[c.rightID].table(rightKey)
+		tables, err := c.inputs(preJoinGroupKeys)
+		if err != nil {
+			c.DiscardTable(key)
+			return
+		}
 
 		if _, ok := c.tables[key]; !ok {
 
-			table, err := c.join(leftBuilder, rightBuilder)
+			table, err := c.join(preJoinGroupKeys, tables)
 
 			if err != nil || table.Empty() {
 				c.DiscardTable(key)
@@ -618,12 +695,16 @@ func (c *MergeJoinCache) ForEachWithContext(f func(query.GroupKey, execute.Trigg
 			c.tables[key] = table
 		}
 
-		leftsize := leftBuilder.NRows()
-		rightsize := rightBuilder.NRows()
+		count := 0
+		for _, table := range tables {
+			if table != nil {
+				count += table.nrows
+			}
+		}
 
 		ctx := execute.TableContext{
 			Key:   key,
-			Count: leftsize + rightsize,
+			Count: count,
 		}
 
 		f(key, trigger, ctx)
@@ -647,24 +728,35 @@ func (c *MergeJoinCache) ExpireTable(key query.GroupKey) {
 	// Clear any stale data
 	preJoinGroupKeys := c.reverseLookup[key]
 
-	leftBuffer := c.buffers[c.leftID]
-	rightBuffer := c.buffers[c.rightID]
-
-	leftBuffer.expire(preJoinGroupKeys.left)
-	rightBuffer.expire(preJoinGroupKeys.right)
+	for i, id := range c.ids {
+		if i < len(preJoinGroupKeys) && preJoinGroupKeys[i] != nil {
+			c.buffers[id].expire(preJoinGroupKeys[i])
+		}
+	}
 
 	if c.canEvictTables() {
+		for _, id := range c.ids {
+			id := id
+			c.buffers[id].clear(func(key query.GroupKey) bool {
+				return c.consumedByOthers(id, key.Value(0))
+			})
+		}
+	}
+}
 
-		leftBuffer.clear(func(key query.GroupKey) bool {
-			return rightBuffer.ready[key.Value(0)] &&
-				rightBuffer.consumed[key.Value(0)] == 0
-		})
-
-		rightBuffer.clear(func(key query.GroupKey) bool {
-			return leftBuffer.ready[key.Value(0)] &&
-				leftBuffer.consumed[key.Value(0)] == 0
-		})
+// consumedByOthers reports whether all streams other than id have consumed
+// their tables with the value as the first group key value.
+func (c *MergeJoinCache) consumedByOthers(id execute.DatasetID, v values.Value) bool {
+	for _, other := range c.ids {
+		if other == id {
+			continue
+		}
+		buf := c.buffers[other]
+		if !buf.ready[v] || buf.consumed[v] != 0 {
+			return false
+		}
 	}
+	return true
 }
 
 // SetTriggerSpec sets the trigger rule for this cache
@@ -675,10 +767,19 @@ func (c *MergeJoinCache) SetTriggerSpec(spec query.TriggerSpec) {
 // Currently tables are the smallest unit of data that can be evicted from the join's internal
 // buffers. This is the rule that specifies whether a data cache can early evict tables.
 func (c *MergeJoinCache) canEvictTables() bool {
-	leftKey := c.schemas[c.leftID].key
-	rightKey := c.schemas[c.rightID].key
-	return len(leftKey) > 0 && len(rightKey) > 0 &&
-		leftKey[0].Label == rightKey[0].Label && c.on[leftKey[0].Label]
+	var label string
+	for _, id := range c.ids {
+		key := c.schemas[id].key
+		if len(key) == 0 {
+			return false
+		}
+		if label == "" {
+			label = key[0].Label
+		} else if key[0].Label != label {
+			return false
+		}
+	}
+	return c.on[label]
 }
 
 // insertIntoBuffer adds the rows of an incoming table to one of the Join's internal buffers
@@ -710,106 +811,238 @@ func (c *MergeJoinCache) insertIntoBuffer(id execute.DatasetID, tbl query.Table)
 	c.buffers[id].insert(tbl)
 }
 
+// spillIfNeeded spills the buffered tables if the memory allocated by the query nears its limit.
+// The tables are spilled sorted by the join columns, so they are only spilled once the join columns are known.
+func (c *MergeJoinCache) spillIfNeeded() error {
+	if !c.postJoinSchemaBuilt() {
+		return nil
+	}
+	cols := c.sortCols()
+	for _, id := range c.ids {
+		if err := c.buffers[id].spillIfNeeded(cols); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sortCols returns the columns the tables are sorted by to be joined.
+func (c *MergeJoinCache) sortCols() []string {
+	if c.asof != nil {
+		on := make([]string, 0, len(c.asof.on)+1)
+		for k := range c.asof.on {
+			on = append(on, k)
+		}
+		sort.Strings(on)
+		// Within rows with equal join columns, the rows are sorted by time.
+		return append(on, c.asof.timeCol)
+	}
+	on := make([]string, 0, len(c.on))
+	for k := range c.on {
+		on = append(on, k)
+	}
+	sort.Strings(on)
+	return on
+}
+
 // registerKey takes a group key from the input stream associated with id and joins
-// it with all other group keys from the opposing input stream. If it is determined
+// it with all combinations of group keys from the other input streams. If it is determined
 // that two group keys will not join (due to having different values on a join column)
 // they are skipped.
 func (c *MergeJoinCache) registerKey(id execute.DatasetID, key query.GroupKey) {
-	var empty struct{}
-	switch id {
-
-	case c.leftID:
-
-		c.buffers[c.rightID].iterate(func(groupKey query.GroupKey) {
+	keys := make(preJoinGroupKeys, len(c.ids))
 
-			keys := map[execute.DatasetID]query.GroupKey{
-				c.leftID:  key,
-				c.rightID: groupKey,
-			}
-
-			for k := range c.intersection {
-				if !key.LabelValue(k).Equal(groupKey.LabelValue(k)) {
-					return
-				}
+	var register func(i int)
+	register = func(i int) {
+		if i == len(c.ids) {
+			for j, k := range keys {
+				c.buffers[c.ids[j]].matched[k] = true
 			}
-
-			outputGroupKey := c.postJoinGroupKey(keys)
-			c.postJoinKeys.Set(outputGroupKey, empty)
-
-			c.reverseLookup[outputGroupKey] = preJoinGroupKeys{
-				left:  key,
-				right: groupKey,
+			c.registerPostJoinKey(keys)
+			return
+		}
+		if c.ids[i] == id {
+			keys[i] = key
+			register(i + 1)
+			return
+		}
+		c.buffers[c.ids[i]].iterate(func(groupKey query.GroupKey) {
+			if !c.joinsKeys(key, groupKey) {
+				return
 			}
+			keys[i] = groupKey
+			register(i + 1)
 		})
+	}
+	register(0)
+}
 
-	case c.rightID:
-
-		c.buffers[c.leftID].iterate(func(groupKey query.GroupKey) {
+// registerUnmatchedKeys registers the output group keys of the tables
+// that could not be joined with a table of every other stream.
+// For outer joins, such a table is joined with the tables of the streams it joins with,
+// the columns of the other streams are null.
+func (c *MergeJoinCache) registerUnmatchedKeys() {
+	if c.method == "inner" {
+		return
+	}
+	if !c.postJoinSchemaBuilt() {
+		c.buildPostJoinSchema()
+	}
 
-			keys := map[execute.DatasetID]query.GroupKey{
-				c.leftID:  groupKey,
-				c.rightID: key,
+	keys := make(preJoinGroupKeys, len(c.ids))
+	for s, id := range c.ids {
+		buf := c.buffers[id]
+		for key := range buf.data {
+			if buf.matched[key] {
+				continue
 			}
 
-			for k := range c.intersection {
-				if !key.LabelValue(k).Equal(groupKey.LabelValue(k)) {
+			// Each combination is registered once, from its first unmatched key,
+			// so the tables of the previous streams must have been matched.
+			var register func(i int)
+			register = func(i int) {
+				if i == len(c.ids) {
+					if c.keepsKeys(keys) {
+						c.registerPostJoinKey(keys)
+					}
+					return
+				}
+				if i == s {
+					keys[i] = key
+					register(i + 1)
 					return
 				}
+				other := c.buffers[c.ids[i]]
+				joins := false
+				other.iterate(func(groupKey query.GroupKey) {
+					if !c.joinsKeys(key, groupKey) {
+						return
+					}
+					joins = true
+					if i < s && !other.matched[groupKey] {
+						return
+					}
+					keys[i] = groupKey
+					register(i + 1)
+				})
+				// The stream is only missing from the join if none of its tables joins.
+				if !joins {
+					keys[i] = nil
+					register(i + 1)
+				}
 			}
+			register(0)
+		}
+	}
+}
 
-			outputGroupKey := c.postJoinGroupKey(keys)
-			c.postJoinKeys.Set(outputGroupKey, empty)
+// keepsKeys reports whether the join method keeps the rows of tables joined from the keys.
+func (c *MergeJoinCache) keepsKeys(keys preJoinGroupKeys) bool {
+	switch c.method {
+	case "left":
+		return keys[0] != nil
+	case "right":
+		return keys[len(keys)-1] != nil
+	case "full":
+		return true
+	default:
+		return false
+	}
+}
 
-			c.reverseLookup[outputGroupKey] = preJoinGroupKeys{
-				left:  groupKey,
-				right: key,
-			}
-		})
+// keepsRows reports whether the join method keeps the rows joined from the streams with the given indexes.
+func (c *MergeJoinCache) keepsRows(streams []int) bool {
+	switch c.method {
+	case "left":
+		return streams[0] == 0
+	case "right":
+		return streams[len(streams)-1] == len(c.ids)-1
+	case "full":
+		return true
+	default:
+		return len(streams) == len(c.ids)
+	}
+}
+
+// joinsKeys reports whether tables with the two group keys can be joined.
+func (c *MergeJoinCache) joinsKeys(a, b query.GroupKey) bool {
+	for k := range c.intersection {
+		if !a.LabelValue(k).Equal(b.LabelValue(k)) {
+			return false
+		}
 	}
+	return true
 }
 
-func (c *MergeJoinCache) isBufferEmpty(id execute.DatasetID) bool {
-	return len(c.buffers[id].data) == 0
+func (c *MergeJoinCache) registerPostJoinKey(keys preJoinGroupKeys) {
+	keys = append(preJoinGroupKeys(nil), keys...)
+	outputGroupKey := c.postJoinGroupKey(keys)
+	c.postJoinKeys.Set(outputGroupKey, struct{}{})
+	c.reverseLookup[outputGroupKey] = keys
+}
+
+func (c *MergeJoinCache) isAnyBufferEmpty() bool {
+	for _, id := range c.ids {
+		if len(c.buffers[id].data) == 0 {
+			return true
+		}
+	}
+	return false
 }
 
 func (c *MergeJoinCache) postJoinSchemaBuilt() bool {
 	return c.schemaMap != nil
 }
 
+// buildPostJoinSchema builds the schema of the output tables from the schemas of the streams.
+// Streams without any table do not contribute to the schema.
 func (c *MergeJoinCache) buildPostJoinSchema() {
-	left := c.schemas[c.leftID].columns
-	right := c.schemas[c.rightID].columns
-
-	// Find column names shared between the two tables
-	shared := make(map[string]bool, len(left))
-	for _, leftColumn := range left {
-		for _, rightColumn := range right {
+	schemas := make([][]query.ColMeta, 0, len(c.ids))
+	for _, id := range c.ids {
+		if s, ok := c.schemas[id]; ok {
+			schemas = append(schemas, s.columns)
+		}
+	}
 
-			if leftColumn.Label == rightColumn.Label {
-				shared[leftColumn.Label] = true
-				break
-			}
+	// Find column names shared between the tables, and the ones common to all of them
+	counts := make(map[string]int)
+	ncols := 0
+	for _, columns := range schemas {
+		for _, column := range columns {
+			counts[column.Label]++
+		}
+		ncols += len(columns)
+	}
+	shared := make(map[string]bool, len(counts))
+	common := make(map[string]bool, len(counts))
+	for label, n := range counts {
+		if n > 1 {
+			shared[label] = true
+		}
+		if n == len(schemas) {
+			common[label] = true
 		}
 	}
 
 	if len(c.on) == 0 {
-		c.on = shared
+		c.on = common
 	}
 
-	ncols := len(left) + len(right)
-
 	c.schema = schema{
-		columns: make([]query.ColMeta, 0, ncols-len(c.on)),
-		key:     make([]query.ColMeta, 0, ncols-len(c.on)),
+		columns: make([]query.ColMeta, 0, ncols),
+		key:     make([]query.ColMeta, 0, ncols),
 	}
 
-	c.colIndex = make(map[query.ColMeta]int, ncols-len(c.on))
+	c.colIndex = make(map[query.ColMeta]int, ncols)
 	c.schemaMap = make(map[tableCol]query.ColMeta, ncols)
-	added := make(map[string]bool, ncols-len(c.on))
+	added := make(map[string]bool, ncols)
 
 	// Build schema for output table
-	addColumnsToSchema(c.names[c.leftID], left, added, shared, c.on, &c.schema, c.schemaMap)
-	addColumnsToSchema(c.names[c.rightID], right, added, shared, c.on, &c.schema, c.schemaMap)
+	for _, id := range c.ids {
+		if s, ok := c.schemas[id]; ok {
+			addColumnsToSchema(c.names[id], s.columns, added, shared, c.on, &c.schema, c.schemaMap)
+		}
+	}
 
 	// Give schema an order
 	sort.Sort(c.schema)
@@ -818,28 +1051,11 @@ func (c *MergeJoinCache) buildPostJoinSchema() {
 	}
 }
 
-func (c *MergeJoinCache) join(left, right *execute.ColListTableBuilder) (query.Table, error) {
-	// Determine sort order for the joining tables
-	on := make([]string, len(c.on))
-
-	for k := range c.on {
-		on = append(on, k)
-	}
-
-	// Sort input tables
-	left.Sort(on, false)
-	right.Sort(on, false)
-
-	var leftSet, rightSet subset
-	var leftKey, rightKey query.GroupKey
-
-	leftTable, rightTable := left.RawTable(), right.RawTable()
-	leftSet, leftKey = c.advance(leftSet.Stop, leftTable)
-	rightSet, rightKey = c.advance(rightSet.Stop, rightTable)
-
-	keys := map[execute.DatasetID]query.GroupKey{
-		c.leftID:  left.Key(),
-		c.rightID: right.Key(),
+// join merges the tables of the pre-join group keys, the tables of streams
+// missing from the join are nil.
+func (c *MergeJoinCache) join(keys preJoinGroupKeys, tables []*joinInput) (query.Table, error) {
+	if c.asof != nil {
+		return c.asofJoin(keys, tables)
 	}
 
 	// Instantiate a builder for the output table
@@ -850,56 +1066,218 @@ func (c *MergeJoinCache) join(left, right *execute.ColListTableBuilder) (query.T
 		builder.AddCol(column)
 	}
 
-	// Perform sort merge join
-	for !leftSet.Empty() && !rightSet.Empty() {
-		if leftKey.Equal(rightKey) {
-
-			for l := leftSet.Start; l < leftSet.Stop; l++ {
-				for r := rightSet.Start; r < rightSet.Stop; r++ {
-
-					leftRecord := leftTable.GetRow(l)
-					rightRecord := rightTable.GetRow(r)
-
-					leftRecord.Range(func(columnName string, columnVal values.Value) {
-						column := tableCol{
-							table: c.names[c.leftID],
-							col:   columnName,
-						}
-						newColumn := c.schemaMap[column]
-						newColumnIdx := c.colIndex[newColumn]
-						execute.AppendValue(builder, newColumnIdx, columnVal)
-					})
-
-					rightRecord.Range(func(columnName string, columnVal values.Value) {
-						column := tableCol{
-							table: c.names[c.rightID],
-							col:   columnName,
-						}
-						newColumn := c.schemaMap[column]
-						newColumnIdx := c.colIndex[newColumn]
-
-						// No need to append value if column is part of the join key.
-						// Because value already appended when iterating over left record.
-						if !c.on[newColumn.Label] {
-							execute.AppendValue(builder, newColumnIdx, columnVal)
-						}
-					})
-				}
+	// Read the input tables sorted by the join columns
+	on := c.sortCols()
+	inputs := make([]*joinReader, len(tables))
+	defer func() {
+		for _, r := range inputs {
+			if r != nil {
+				r.close()
+			}
+		}
+	}()
+	readers := make([]*execute.ColListTable, len(tables))
+	sets := make([]subset, len(tables))
+	for i, table := range tables {
+		if table == nil {
+			continue
+		}
+		r, err := newJoinReader(table, on, c.on, c.alloc)
+		if err != nil {
+			return nil, err
+		}
+		inputs[i] = r
+	}
+
+	// Perform sort merge join, each step joins the rows of all tables
+	// with the least value for the join columns
+	streams := make([]int, 0, len(tables))
+	for {
+		var min query.GroupKey
+		for _, r := range inputs {
+			if r == nil || r.set.Empty() {
+				continue
+			}
+			if min == nil || r.key.Less(min) {
+				min = r.key
+			}
+		}
+		if min == nil {
+			break
+		}
+
+		streams = streams[:0]
+		for i, r := range inputs {
+			if r != nil && !r.set.Empty() && r.key.Equal(min) {
+				streams = append(streams, i)
+				readers[i], sets[i] = r.table, r.set
+			}
+		}
+
+		if c.keepsRows(streams) {
+			c.appendRows(builder, readers, sets, streams)
+		}
+
+		for _, i := range streams {
+			if err := inputs[i].next(); err != nil {
+				return nil, err
 			}
-			leftSet, leftKey = c.advance(leftSet.Stop, leftTable)
-			rightSet, rightKey = c.advance(rightSet.Stop, rightTable)
-		} else if leftKey.Less(rightKey) {
-			leftSet, leftKey = c.advance(leftSet.Stop, leftTable)
-		} else {
-			rightSet, rightKey = c.advance(rightSet.Stop, rightTable)
 		}
 	}
 
 	return builder.Table()
 }
 
-// postJoinGroupKey produces a new group key value from a left and a right group key value
-func (c *MergeJoinCache) postJoinGroupKey(keys map[execute.DatasetID]query.GroupKey) query.GroupKey {
+// joinReader reads the rows of a buffered table sorted by the join columns,
+// one set of rows with the same values for the join columns at a time.
+type joinReader struct {
+	on map[string]bool
+	// table holds the rows of the set, it is the whole table when the table was not spilled.
+	table *execute.ColListTable
+	set   subset
+	// key is the values of the join columns of the set, it is nil once the table has been read.
+	key query.GroupKey
+
+	// chunks reads the chunks of a spilled table, the rows of the set are copied from them to builder.
+	// It is nil when the table was not spilled.
+	chunks  *execute.ChunkIterator
+	chunk   *execute.ColListTable
+	i       int
+	done    bool
+	builder *execute.ColListTableBuilder
+}
+
+// newJoinReader returns a reader of the table sorted by the columns cols, which reads the sets of rows with the same values for on.
+func newJoinReader(t *joinInput, cols []string, on map[string]bool, alloc *execute.Allocator) (*joinReader, error) {
+	r := &joinReader{on: on}
+	if t.spilled == nil {
+		t.builder.Sort(cols, false)
+		r.table = t.builder.RawTable()
+		r.set, r.key = advance(0, r.table, on)
+		return r, nil
+	}
+	chunks, ok := execute.NewChunkIterator(t.spilled)
+	if !ok {
+		return nil, fmt.Errorf("cannot read spilled table of type %T", t.spilled)
+	}
+	r.chunks = chunks
+	r.builder = execute.NewColListTableBuilder(t.spilled.Key(), alloc)
+	execute.AddTableCols(t.spilled, r.builder)
+	return r, r.next()
+}
+
+// next reads the next set of rows.
+func (r *joinReader) next() error {
+	if r.chunks == nil {
+		r.set, r.key = advance(r.set.Stop, r.table, r.on)
+		return nil
+	}
+	r.builder.ClearData()
+	r.key = nil
+	for !r.done {
+		if r.chunk == nil || r.i == r.chunk.Len() {
+			chunk, err := r.chunks.Next()
+			if err != nil {
+				return err
+			}
+			if chunk == nil {
+				r.chunk, r.done = nil, true
+				break
+			}
+			r.chunk, r.i = chunk, 0
+			continue
+		}
+		key := rowKey(r.i, r.chunk, r.on)
+		if r.key == nil {
+			r.key = key
+		} else if !key.Equal(r.key) {
+			break
+		}
+		execute.AppendRecord(r.i, r.chunk, r.builder)
+		r.i++
+	}
+	r.table = r.builder.RawTable()
+	r.set = subset{Start: 0, Stop: r.builder.NRows()}
+	return nil
+}
+
+// close frees the rows held by the reader.
+func (r *joinReader) close() {
+	if r.chunks != nil {
+		r.chunks.Close()
+		r.builder.ClearData()
+	}
+}
+
+// appendRows appends the cross product of the rows of the streams with the given indexes.
+func (c *MergeJoinCache) appendRows(builder *execute.ColListTableBuilder, readers []*execute.ColListTable, sets []subset, streams []int) {
+	rows := make([]int, len(streams))
+	for k, i := range streams {
+		rows[k] = sets[i].Start
+	}
+	appended := make([]bool, len(c.schema.columns))
+	for {
+		c.appendRow(builder, readers, streams, rows, appended)
+
+		// Move to the next combination of rows
+		k := len(rows) - 1
+		for ; k >= 0; k-- {
+			rows[k]++
+			if rows[k] < sets[streams[k]].Stop {
+				break
+			}
+			rows[k] = sets[streams[k]].Start
+		}
+		if k < 0 {
+			return
+		}
+	}
+}
+
+// appendRow appends a row joining the given row of each of the streams with the given indexes.
+// The appended slice is used to track the columns appended to the builder, it has a length
+// equal to the number of columns of the schema.
+func (c *MergeJoinCache) appendRow(builder *execute.ColListTableBuilder, readers []*execute.ColListTable, streams, rows []int, appended []bool) {
+	for j := range appended {
+		appended[j] = false
+	}
+	for k, i := range streams {
+		name := c.names[c.ids[i]]
+		readers[i].GetRow(rows[k]).Range(func(columnName string, columnVal values.Value) {
+			column := tableCol{
+				table: name,
+				col:   columnName,
+			}
+			newColumn := c.schemaMap[column]
+			newColumnIdx := c.colIndex[newColumn]
+
+			// No need to append value if column is part of the join key.
+			// Because value already appended when iterating over a previous record.
+			if appended[newColumnIdx] {
+				return
+			}
+			appended[newColumnIdx] = true
+			execute.AppendValue(builder, newColumnIdx, columnVal)
+		})
+	}
+
+	// The columns of the streams without a matching row are null,
+	// except for the group key columns which keep the value of the key.
+	key := builder.Key()
+	for j, column := range c.schema.columns {
+		if appended[j] {
+			continue
+		}
+		if idx := execute.ColIdx(column.Label, key.Cols()); idx >= 0 {
+			execute.AppendValue(builder, j, key.Value(idx))
+		} else {
+			builder.AppendNil(j)
+		}
+	}
+}
+
+// postJoinGroupKey produces a new group key value from the group keys of the joined tables
+func (c *MergeJoinCache) postJoinGroupKey(keys preJoinGroupKeys) query.GroupKey {
 	key := groupKey{
 		cols: make([]query.ColMeta, 0, len(keys)*5),
 		vals: make([]values.Value, 0, len(keys)*5),
@@ -907,11 +1285,14 @@ func (c *MergeJoinCache) postJoinGroupKey(keys map[execute.DatasetID]query.Group
 
 	added := make(map[string]bool, len(keys)*5)
 
-	for id, groupKey := range keys {
+	for i, groupKey := range keys {
+		if groupKey == nil {
+			continue
+		}
 		for j, column := range groupKey.Cols() {
 
 			tableAndColumn := tableCol{
-				table: c.names[id],
+				table: c.names[c.ids[i]],
 				col:   column.Label,
 			}
 
@@ -932,22 +1313,37 @@ func (c *MergeJoinCache) postJoinGroupKey(keys map[execute.DatasetID]query.Group
 	return execute.NewGroupKey(key.cols, key.vals)
 }
 
-// advance advances the row pointer of a sorted table that is being joined
-func (c *MergeJoinCache) advance(offset int, table query.ColReader) (subset, query.GroupKey) {
+// advance advances the row pointer of a sorted table that is being joined on the given columns
+func advance(offset int, table query.ColReader, on map[string]bool) (subset, query.GroupKey) {
 	if n := table.Len(); n == offset {
 		return subset{Start: n, Stop: n}, nil
 	}
 	start := offset
-	key := execute.GroupKeyForRowOn(start, table, c.on)
+	key := rowKey(start, table, on)
 	sequence := subset{Start: start}
 	offset++
-	for offset < table.Len() && equalRowKeys(start, offset, table, c.on) {
+	for offset < table.Len() && equalRowKeys(start, offset, table, on) {
 		offset++
 	}
 	sequence.Stop = offset
 	return sequence, key
 }
 
+// rowKey returns the values of the join columns of a row, sorted by column label
+// so that the keys of tables with different column orders can be compared.
+func rowKey(i int, table query.ColReader, on map[string]bool) query.GroupKey {
+	k := execute.GroupKeyForRowOn(i, table, on)
+	key := groupKey{
+		cols: k.Cols(),
+		vals: make([]values.Value, len(k.Cols())),
+	}
+	for j := range key.cols {
+		key.vals[j] = k.Value(j)
+	}
+	sort.Sort(key)
+	return execute.NewGroupKey(key.cols, key.vals)
+}
+
 type subset struct {
 	Start int
 	Stop  int
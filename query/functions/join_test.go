@@ -152,6 +152,93 @@ func TestJoin_NewQuery(t *testing.T) {
 				},
 			},
 		},
+		{
+			Name: "three-way left join",
+			Raw: `
+				a = from(bucket:"dbA")
+				b = from(bucket:"dbB")
+				c = from(bucket:"dbC")
+				join(tables:{a:a,b:b,c:c}, on:["host"], method:"left")`,
+			Want: &query.Spec{
+				Operations: []*query.Operation{
+					{
+						ID: "from0",
+						Spec: &functions.FromOpSpec{
+							Bucket: "dbA",
+						},
+					},
+					{
+						ID: "from1",
+						Spec: &functions.FromOpSpec{
+							Bucket: "dbB",
+						},
+					},
+					{
+						ID: "from2",
+						Spec: &functions.FromOpSpec{
+							Bucket: "dbC",
+						},
+					},
+					{
+						ID: "join3",
+						Spec: &functions.JoinOpSpec{
+							On:         []string{"host"},
+							TableNames: map[query.OperationID]string{"from0": "a", "from1": "b", "from2": "c"},
+							Method:     "left",
+						},
+					},
+				},
+				Edges: []query.Edge{
+					{Parent: "from0", Child: "join3"},
+					{Parent: "from1", Child: "join3"},
+					{Parent: "from2", Child: "join3"},
+				},
+			},
+		},
+		{
+			Name: "left join in declared order",
+			Raw: `
+				b = from(bucket:"dbB")
+				a = from(bucket:"dbA")
+				join(tables:{b:b,a:a}, on:["host"], method:"left")`,
+			Want: &query.Spec{
+				Operations: []*query.Operation{
+					{
+						ID: "from0",
+						Spec: &functions.FromOpSpec{
+							Bucket: "dbB",
+						},
+					},
+					{
+						ID: "from1",
+						Spec: &functions.FromOpSpec{
+							Bucket: "dbA",
+						},
+					},
+					{
+						ID: "join2",
+						Spec: &functions.JoinOpSpec{
+							On:         []string{"host"},
+							TableNames: map[query.OperationID]string{"from0": "b", "from1": "a"},
+							Method:     "left",
+						},
+					},
+				},
+				// The first parent is the left table.
+				Edges: []query.Edge{
+					{Parent: "from0", Child: "join2"},
+					{Parent: "from1", Child: "join2"},
+				},
+			},
+		},
+		{
+			Name: "join with unknown method",
+			Raw: `
+				a = from(bucket:"dbA")
+				b = from(bucket:"dbB")
+				join(tables:{a:a,b:b}, on:["host"], method:"outer")`,
+			WantErr: true,
+		},
 	}
 	for _, tc := range tests {
 		tc := tc
@@ -184,16 +271,23 @@ func TestJoinOperation_Marshaling(t *testing.T) {
 func TestMergeJoin_Process(t *testing.T) {
 	parentID0 := plantest.RandomProcedureID()
 	parentID1 := plantest.RandomProcedureID()
+	parentID2 := plantest.RandomProcedureID()
 	tableNames := map[plan.ProcedureID]string{
 		parentID0: "a",
 		parentID1: "b",
 	}
+	threeTableNames := map[plan.ProcedureID]string{
+		parentID0: "a",
+		parentID1: "b",
+		parentID2: "c",
+	}
 	testCases := []struct {
 		skip  bool
 		name  string
 		spec  *functions.MergeJoinProcedureSpec
 		data0 []*executetest.Table // data from parent 0
 		data1 []*executetest.Table // data from parent 1
+		data2 []*executetest.Table // data from parent 2, only for joins of three tables
 		want  []*executetest.Table
 	}{
 		{
@@ -1287,56 +1381,517 @@ func TestMergeJoin_Process(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "left with missing values",
+			spec: &functions.MergeJoinProcedureSpec{
+				On:         []string{"_time"},
+				TableNames: tableNames,
+				Method:     "left",
+			},
+			data0: []*executetest.Table{
+				{
+					ColMeta: []query.ColMeta{
+						{Label: "_time", Type: query.TTime},
+						{Label: "_value", Type: query.TFloat},
+					},
+					Data: [][]interface{}{
+						{execute.Time(1), 1.0},
+						{execute.Time(2), 2.0},
+						{execute.Time(3), 3.0},
+					},
+				},
+			},
+			data1: []*executetest.Table{
+				{
+					ColMeta: []query.ColMeta{
+						{Label: "_time", Type: query.TTime},
+						{Label: "_value", Type: query.TFloat},
+					},
+					Data: [][]interface{}{
+						{execute.Time(1), 10.0},
+						{execute.Time(3), 30.0},
+						{execute.Time(4), 40.0},
+					},
+				},
+			},
+			want: []*executetest.Table{
+				{
+					ColMeta: []query.ColMeta{
+						{Label: "_time", Type: query.TTime},
+						{Label: "a__value", Type: query.TFloat},
+						{Label: "b__value", Type: query.TFloat},
+					},
+					Data: [][]interface{}{
+						{execute.Time(1), 1.0, 10.0},
+						{execute.Time(2), 2.0, nil},
+						{execute.Time(3), 3.0, 30.0},
+					},
+				},
+			},
+		},
+		{
+			name: "right with missing values",
+			spec: &functions.MergeJoinProcedureSpec{
+				On:         []string{"_time"},
+				TableNames: tableNames,
+				Method:     "right",
+			},
+			data0: []*executetest.Table{
+				{
+					ColMeta: []query.ColMeta{
+						{Label: "_time", Type: query.TTime},
+						{Label: "_value", Type: query.TFloat},
+					},
+					Data: [][]interface{}{
+						{execute.Time(1), 1.0},
+						{execute.Time(2), 2.0},
+						{execute.Time(3), 3.0},
+					},
+				},
+			},
+			data1: []*executetest.Table{
+				{
+					ColMeta: []query.ColMeta{
+						{Label: "_time", Type: query.TTime},
+						{Label: "_value", Type: query.TFloat},
+					},
+					Data: [][]interface{}{
+						{execute.Time(1), 10.0},
+						{execute.Time(3), 30.0},
+						{execute.Time(4), 40.0},
+					},
+				},
+			},
+			want: []*executetest.Table{
+				{
+					ColMeta: []query.ColMeta{
+						{Label: "_time", Type: query.TTime},
+						{Label: "a__value", Type: query.TFloat},
+						{Label: "b__value", Type: query.TFloat},
+					},
+					Data: [][]interface{}{
+						{execute.Time(1), 1.0, 10.0},
+						{execute.Time(3), 3.0, 30.0},
+						{execute.Time(4), nil, 40.0},
+					},
+				},
+			},
+		},
+		{
+			name: "full with missing values",
+			spec: &functions.MergeJoinProcedureSpec{
+				On:         []string{"_time"},
+				TableNames: tableNames,
+				Method:     "full",
+			},
+			data0: []*executetest.Table{
+				{
+					ColMeta: []query.ColMeta{
+						{Label: "_time", Type: query.TTime},
+						{Label: "_value", Type: query.TFloat},
+					},
+					Data: [][]interface{}{
+						{execute.Time(1), 1.0},
+						{execute.Time(2), 2.0},
+						{execute.Time(3), 3.0},
+					},
+				},
+			},
+			data1: []*executetest.Table{
+				{
+					ColMeta: []query.ColMeta{
+						{Label: "_time", Type: query.TTime},
+						{Label: "_value", Type: query.TFloat},
+					},
+					Data: [][]interface{}{
+						{execute.Time(1), 10.0},
+						{execute.Time(3), 30.0},
+						{execute.Time(4), 40.0},
+					},
+				},
+			},
+			want: []*executetest.Table{
+				{
+					ColMeta: []query.ColMeta{
+						{Label: "_time", Type: query.TTime},
+						{Label: "a__value", Type: query.TFloat},
+						{Label: "b__value", Type: query.TFloat},
+					},
+					Data: [][]interface{}{
+						{execute.Time(1), 1.0, 10.0},
+						{execute.Time(2), 2.0, nil},
+						{execute.Time(3), 3.0, 30.0},
+						{execute.Time(4), nil, 40.0},
+					},
+				},
+			},
+		},
+		{
+			name: "left with unmatched tables",
+			spec: &functions.MergeJoinProcedureSpec{
+				On:         []string{"_time", "host"},
+				TableNames: tableNames,
+				Method:     "left",
+			},
+			data0: []*executetest.Table{
+				{
+					KeyCols: []string{"host"},
+					ColMeta: []query.ColMeta{
+						{Label: "_time", Type: query.TTime},
+						{Label: "_value", Type: query.TFloat},
+						{Label: "host", Type: query.TString},
+					},
+					Data: [][]interface{}{
+						{execute.Time(1), 1.0, "A"},
+						{execute.Time(2), 1.5, "A"},
+					},
+				},
+				{
+					KeyCols: []string{"host"},
+					ColMeta: []query.ColMeta{
+						{Label: "_time", Type: query.TTime},
+						{Label: "_value", Type: query.TFloat},
+						{Label: "host", Type: query.TString},
+					},
+					Data: [][]interface{}{
+						{execute.Time(1), 2.0, "B"},
+					},
+				},
+			},
+			data1: []*executetest.Table{
+				{
+					KeyCols: []string{"host"},
+					ColMeta: []query.ColMeta{
+						{Label: "_time", Type: query.TTime},
+						{Label: "_value", Type: query.TFloat},
+						{Label: "host", Type: query.TString},
+					},
+					Data: [][]interface{}{
+						{execute.Time(1), 10.0, "A"},
+					},
+				},
+				{
+					KeyCols: []string{"host"},
+					ColMeta: []query.ColMeta{
+						{Label: "_time", Type: query.TTime},
+						{Label: "_value", Type: query.TFloat},
+						{Label: "host", Type: query.TString},
+					},
+					Data: [][]interface{}{
+						{execute.Time(1), 30.0, "C"},
+					},
+				},
+			},
+			want: []*executetest.Table{
+				{
+					KeyCols: []string{"host"},
+					ColMeta: []query.ColMeta{
+						{Label: "_time", Type: query.TTime},
+						{Label: "a__value", Type: query.TFloat},
+						{Label: "b__value", Type: query.TFloat},
+						{Label: "host", Type: query.TString},
+					},
+					Data: [][]interface{}{
+						{execute.Time(1), 1.0, 10.0, "A"},
+						{execute.Time(2), 1.5, nil, "A"},
+					},
+				},
+				{
+					KeyCols: []string{"host"},
+					ColMeta: []query.ColMeta{
+						{Label: "_time", Type: query.TTime},
+						{Label: "a__value", Type: query.TFloat},
+						{Label: "b__value", Type: query.TFloat},
+						{Label: "host", Type: query.TString},
+					},
+					Data: [][]interface{}{
+						{execute.Time(1), 2.0, nil, "B"},
+					},
+				},
+			},
+		},
+		{
+			name: "full with unmatched tables",
+			spec: &functions.MergeJoinProcedureSpec{
+				On:         []string{"_time", "host"},
+				TableNames: tableNames,
+				Method:     "full",
+			},
+			data0: []*executetest.Table{
+				{
+					KeyCols: []string{"host"},
+					ColMeta: []query.ColMeta{
+						{Label: "_time", Type: query.TTime},
+						{Label: "_value", Type: query.TFloat},
+						{Label: "host", Type: query.TString},
+					},
+					Data: [][]interface{}{
+						{execute.Time(1), 1.0, "A"},
+					},
+				},
+				{
+					KeyCols: []string{"host"},
+					ColMeta: []query.ColMeta{
+						{Label: "_time", Type: query.TTime},
+						{Label: "_value", Type: query.TFloat},
+						{Label: "host", Type: query.TString},
+					},
+					Data: [][]interface{}{
+						{execute.Time(1), 2.0, "B"},
+					},
+				},
+			},
+			data1: []*executetest.Table{
+				{
+					KeyCols: []string{"host"},
+					ColMeta: []query.ColMeta{
+						{Label: "_time", Type: query.TTime},
+						{Label: "_value", Type: query.TFloat},
+						{Label: "host", Type: query.TString},
+					},
+					Data: [][]interface{}{
+						{execute.Time(1), 10.0, "A"},
+					},
+				},
+				{
+					KeyCols: []string{"host"},
+					ColMeta: []query.ColMeta{
+						{Label: "_time", Type: query.TTime},
+						{Label: "_value", Type: query.TFloat},
+						{Label: "host", Type: query.TString},
+					},
+					Data: [][]interface{}{
+						{execute.Time(1), 30.0, "C"},
+					},
+				},
+			},
+			want: []*executetest.Table{
+				{
+					KeyCols: []string{"host"},
+					ColMeta: []query.ColMeta{
+						{Label: "_time", Type: query.TTime},
+						{Label: "a__value", Type: query.TFloat},
+						{Label: "b__value", Type: query.TFloat},
+						{Label: "host", Type: query.TString},
+					},
+					Data: [][]interface{}{
+						{execute.Time(1), 1.0, 10.0, "A"},
+					},
+				},
+				{
+					KeyCols: []string{"host"},
+					ColMeta: []query.ColMeta{
+						{Label: "_time", Type: query.TTime},
+						{Label: "a__value", Type: query.TFloat},
+						{Label: "b__value", Type: query.TFloat},
+						{Label: "host", Type: query.TString},
+					},
+					Data: [][]interface{}{
+						{execute.Time(1), 2.0, nil, "B"},
+					},
+				},
+				{
+					KeyCols: []string{"host"},
+					ColMeta: []query.ColMeta{
+						{Label: "_time", Type: query.TTime},
+						{Label: "a__value", Type: query.TFloat},
+						{Label: "b__value", Type: query.TFloat},
+						{Label: "host", Type: query.TString},
+					},
+					Data: [][]interface{}{
+						{execute.Time(1), nil, 30.0, "C"},
+					},
+				},
+			},
+		},
+		{
+			name: "inner with three tables",
+			spec: &functions.MergeJoinProcedureSpec{
+				On:         []string{"_time"},
+				TableNames: threeTableNames,
+			},
+			data0: []*executetest.Table{
+				{
+					ColMeta: []query.ColMeta{
+						{Label: "_time", Type: query.TTime},
+						{Label: "_value", Type: query.TFloat},
+					},
+					Data: [][]interface{}{
+						{execute.Time(1), 1.0},
+						{execute.Time(2), 2.0},
+						{execute.Time(3), 3.0},
+					},
+				},
+			},
+			data1: []*executetest.Table{
+				{
+					ColMeta: []query.ColMeta{
+						{Label: "_time", Type: query.TTime},
+						{Label: "_value", Type: query.TFloat},
+					},
+					Data: [][]interface{}{
+						{execute.Time(1), 10.0},
+						{execute.Time(2), 20.0},
+					},
+				},
+			},
+			data2: []*executetest.Table{
+				{
+					ColMeta: []query.ColMeta{
+						{Label: "_time", Type: query.TTime},
+						{Label: "_value", Type: query.TFloat},
+					},
+					Data: [][]interface{}{
+						{execute.Time(2), 200.0},
+						{execute.Time(3), 300.0},
+					},
+				},
+			},
+			want: []*executetest.Table{
+				{
+					ColMeta: []query.ColMeta{
+						{Label: "_time", Type: query.TTime},
+						{Label: "a__value", Type: query.TFloat},
+						{Label: "b__value", Type: query.TFloat},
+						{Label: "c__value", Type: query.TFloat},
+					},
+					Data: [][]interface{}{
+						{execute.Time(2), 2.0, 20.0, 200.0},
+					},
+				},
+			},
+		},
+		{
+			name: "full with three tables",
+			spec: &functions.MergeJoinProcedureSpec{
+				On:         []string{"_time"},
+				TableNames: threeTableNames,
+				Method:     "full",
+			},
+			data0: []*executetest.Table{
+				{
+					ColMeta: []query.ColMeta{
+						{Label: "_time", Type: query.TTime},
+						{Label: "_value", Type: query.TFloat},
+					},
+					Data: [][]interface{}{
+						{execute.Time(1), 1.0},
+						{execute.Time(2), 2.0},
+					},
+				},
+			},
+			data1: []*executetest.Table{
+				{
+					ColMeta: []query.ColMeta{
+						{Label: "_time", Type: query.TTime},
+						{Label: "_value", Type: query.TFloat},
+					},
+					Data: [][]interface{}{
+						{execute.Time(1), 10.0},
+					},
+				},
+			},
+			data2: []*executetest.Table{
+				{
+					ColMeta: []query.ColMeta{
+						{Label: "_time", Type: query.TTime},
+						{Label: "_value", Type: query.TFloat},
+					},
+					Data: [][]interface{}{
+						{execute.Time(2), 200.0},
+						{execute.Time(3), 300.0},
+					},
+				},
+			},
+			want: []*executetest.Table{
+				{
+					ColMeta: []query.ColMeta{
+						{Label: "_time", Type: query.TTime},
+						{Label: "a__value", Type: query.TFloat},
+						{Label: "b__value", Type: query.TFloat},
+						{Label: "c__value", Type: query.TFloat},
+					},
+					Data: [][]interface{}{
+						{execute.Time(1), 1.0, 10.0, nil},
+						{execute.Time(2), 2.0, nil, 200.0},
+						{execute.Time(3), nil, nil, 300.0},
+					},
+				},
+			},
+		},
 	}
 	for _, tc := range testCases {
 		tc := tc
-		t.Run(tc.name, func(t *testing.T) {
-			if tc.skip {
-				t.Skip()
+		// Every case is also run with the buffered tables spilled as soon as they are inserted.
+		for _, spill := range []bool{false, true} {
+			spill := spill
+			name := tc.name
+			if spill {
+				name += " spilled"
 			}
-			parents := []execute.DatasetID{execute.DatasetID(parentID0), execute.DatasetID(parentID1)}
+			t.Run(name, func(t *testing.T) {
+				if tc.skip {
+					t.Skip()
+				}
+				parents := []execute.DatasetID{execute.DatasetID(parentID0), execute.DatasetID(parentID1)}
+				data := [][]*executetest.Table{tc.data0, tc.data1}
+				if tc.data2 != nil {
+					parents = append(parents, execute.DatasetID(parentID2))
+					data = append(data, tc.data2)
+				}
 
-			tableNames := make(map[execute.DatasetID]string, len(tc.spec.TableNames))
-			for pid, name := range tc.spec.TableNames {
-				tableNames[execute.DatasetID(pid)] = name
-			}
+				tableNames := make(map[execute.DatasetID]string, len(tc.spec.TableNames))
+				for pid, name := range tc.spec.TableNames {
+					tableNames[execute.DatasetID(pid)] = name
+				}
 
-			d := executetest.NewDataset(executetest.RandomDatasetID())
-			c := functions.NewMergeJoinCache(executetest.UnlimitedAllocator, parents, tableNames, tc.spec.On)
-			c.SetTriggerSpec(execute.DefaultTriggerSpec)
-			jt := functions.NewMergeJoinTransformation(d, c, tc.spec, parents, tableNames)
+				d := executetest.NewDataset(executetest.RandomDatasetID())
+				alloc := executetest.UnlimitedAllocator
+				var spiller *execute.Spiller
+				if spill {
+					alloc = &execute.Allocator{Limit: 1 << 30}
+					spiller = &execute.Spiller{Threshold: 1e-12}
+					defer spiller.Close()
+				}
+				c := functions.NewMergeJoinCache(alloc, parents, tableNames, tc.spec.On, tc.spec.Method)
+				c.SetTriggerSpec(execute.DefaultTriggerSpec)
+				c.SetSpiller(spiller)
+				jt := functions.NewMergeJoinTransformation(d, c, tc.spec, parents)
 
-			l := len(tc.data0)
-			if len(tc.data1) > l {
-				l = len(tc.data1)
-			}
-			for i := 0; i < l; i++ {
-				if i < len(tc.data0) {
-					if err := jt.Process(parents[0], tc.data0[i]); err != nil {
-						t.Fatal(err)
+				l := 0
+				for _, tables := range data {
+					if len(tables) > l {
+						l = len(tables)
 					}
 				}
-				if i < len(tc.data1) {
-					if err := jt.Process(parents[1], tc.data1[i]); err != nil {
-						t.Fatal(err)
+				for i := 0; i < l; i++ {
+					for p, tables := range data {
+						if i < len(tables) {
+							if err := jt.Process(parents[p], tables[i]); err != nil {
+								t.Fatal(err)
+							}
+						}
 					}
 				}
-			}
+				for _, id := range parents {
+					jt.Finish(id, nil)
+				}
 
-			got, err := executetest.TablesFromCache(c)
-			if err != nil {
-				t.Fatal(err)
-			}
+				got, err := executetest.TablesFromCache(c)
+				if err != nil {
+					t.Fatal(err)
+				}
 
-			executetest.NormalizeTables(got)
-			executetest.NormalizeTables(tc.want)
+				executetest.NormalizeTables(got)
+				executetest.NormalizeTables(tc.want)
 
-			sort.Sort(executetest.SortedTables(got))
-			sort.Sort(executetest.SortedTables(tc.want))
+				sort.Sort(executetest.SortedTables(got))
+				sort.Sort(executetest.SortedTables(tc.want))
 
-			if !cmp.Equal(tc.want, got) {
-				t.Errorf("unexpected tables -want/+got\n%s", cmp.Diff(tc.want, got))
-			}
-		})
+				if !cmp.Equal(tc.want, got) {
+					t.Errorf("unexpected tables -want/+got\n%s", cmp.Diff(tc.want, got))
+				}
+			})
+		}
 	}
 }
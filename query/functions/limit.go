@@ -87,6 +87,11 @@ func (s *LimitProcedureSpec) Copy() plan.ProcedureSpec {
 	return ns
 }
 
+// Parallel reports that the rows of each table are limited independently of the other tables.
+func (s *LimitProcedureSpec) Parallel() bool {
+	return true
+}
+
 func (s *LimitProcedureSpec) PushDownRules() []plan.PushDownRule {
 	return []plan.PushDownRule{{
 		Root:    FromKind,
@@ -216,6 +221,13 @@ func (cr sliceColReader) Times(j int) []execute.Time {
 	return cr.ColReader.Times(j)[cr.start:cr.stop]
 }
 
+func (cr sliceColReader) Nulls(j int) []bool {
+	if nulls := cr.ColReader.Nulls(j); nulls != nil {
+		return nulls[cr.start:cr.stop]
+	}
+	return nil
+}
+
 func (t *limitTransformation) UpdateWatermark(id execute.DatasetID, mark execute.Time) error {
 	return t.d.UpdateWatermark(mark)
 }
@@ -182,6 +182,10 @@ func (t *mapTransformation) Process(id execute.DatasetID, tbl query.Table) error
 			}
 			for j, c := range builder.Cols() {
 				v, ok := m.Get(c.Label)
+				if idx := execute.ColIdx(c.Label, key.Cols()); ok && idx >= 0 && v.Type() == semantic.Nil {
+					// The value of a group key column is never null.
+					v = key.Value(idx)
+				}
 				if !ok {
 					if idx := execute.ColIdx(c.Label, tbl.Key().Cols()); t.mergeKey && idx >= 0 {
 						v = tbl.Key().Value(idx)
@@ -206,7 +210,8 @@ func groupKeyForObject(i int, cr query.ColReader, obj values.Object, on map[stri
 		}
 		cols = append(cols, c)
 		v, ok := obj.Get(c.Label)
-		if ok {
+		// Group key values cannot be null, the value of the row is kept instead.
+		if ok && v.Type() != semantic.Nil {
 			vs = append(vs, v)
 		} else {
 			switch c.Type {
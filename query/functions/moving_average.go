@@ -0,0 +1,18 @@
+package functions
+
+import "github.com/EMCECS/influx/query"
+
+func init() {
+	query.RegisterBuiltIn("moving-average", movingAverageBuiltIn)
+}
+
+var movingAverageBuiltIn = `
+// movingAverage computes the mean of the last n records of columns, for each record from the n-th one.
+movingAverage = (n, columns=["_value"], table=<-) =>
+	table |> rolling(n:n, fn:mean, columns:columns)
+
+// timedMovingAverage computes the mean of column over the period before each multiple of every.
+// The time of each record is the stop of its period.
+timedMovingAverage = (every, period, column="_value", table=<-) =>
+	table |> rolling(every:every, period:period, fn:mean, columns:[column])
+`
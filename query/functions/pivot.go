@@ -136,6 +136,9 @@ func createPivotTransformation(id execute.DatasetID, mode execute.AccumulationMo
 	}
 
 	cache := execute.NewTableBuilderCache(a.Allocator())
+	// The pivoted rows are spilled as runs sorted by the row key near the memory quota,
+	// and the rows of the runs with the same row key are combined when the tables are read.
+	cache.SetSpiller(a.Spiller())
 	d := execute.NewDataset(id, mode, cache)
 	t := NewPivotTransformation(d, cache, s)
 	return t, d, nil
@@ -148,8 +151,9 @@ type pivotTransformation struct {
 	// for each table, we need to store a map to keep track of which rows/columns have already been created.
 	colKeyMaps map[string]map[string]int
 	rowKeyMaps map[string]map[string]int
-	nextCol    int
-	nextRow    int
+	// combined reports whether the cache combines the spilled rows with the same row key,
+	// the values of the rows that have not been set are then null instead of zero values.
+	combined bool
 }
 
 func NewPivotTransformation(d execute.Dataset, cache execute.TableBuilderCache, spec *PivotProcedureSpec) *pivotTransformation {
@@ -160,6 +164,12 @@ func NewPivotTransformation(d execute.Dataset, cache execute.TableBuilderCache,
 		colKeyMaps: make(map[string]map[string]int),
 		rowKeyMaps: make(map[string]map[string]int),
 	}
+	if c, ok := cache.(interface {
+		SetCombinedRows(cols []string)
+	}); ok {
+		c.SetCombinedRows(spec.RowKey)
+		t.combined = true
+	}
 	return t
 }
 
@@ -231,11 +241,12 @@ func (t *pivotTransformation) Process(id execute.DatasetID, tbl query.Table) err
 		}
 		t.colKeyMaps[groupKeyString] = make(map[string]int)
 		t.rowKeyMaps[groupKeyString] = make(map[string]int)
-		t.nextCol = len(cols)
-		t.nextRow = 0
 	}
 
-	tbl.Do(func(cr query.ColReader) error {
+	return tbl.Do(func(cr query.ColReader) error {
+		if cr.Len() == 0 {
+			return nil
+		}
 		for row := 0; row < cr.Len(); row++ {
 			rowKey := ""
 			colKey := ""
@@ -260,10 +271,14 @@ func (t *pivotTransformation) Process(id execute.DatasetID, tbl query.Table) err
 					Label: colKey,
 					Type:  valueColType,
 				}
-				builder.AddCol(newCol)
-				growColumn(builder, newCol.Type, t.nextCol, builder.NRows())
-				t.colKeyMaps[groupKeyString][colKey] = t.nextCol
-				t.nextCol++
+				j := builder.AddCol(newCol)
+				growColumn(builder, newCol.Type, j, builder.NRows())
+				if t.combined {
+					for i := 0; i < builder.NRows(); i++ {
+						builder.SetNil(i, j)
+					}
+				}
+				t.colKeyMaps[groupKeyString][colKey] = j
 			}
 			//  1.  if we've not seen rowKey before, then we need to append a new row, with copied values for the
 			//  existing columns, as well as zero values for the pivoted columns.
@@ -274,12 +289,15 @@ func (t *pivotTransformation) Process(id execute.DatasetID, tbl query.Table) err
 				}
 
 				// zero-out the known key columns we've already discovered.
+				n := builder.NRows() - 1
 				for _, v := range t.colKeyMaps[groupKeyString] {
 					growColumn(builder, valueColType, v, 1)
+					if t.combined {
+						builder.SetNil(n, v)
+					}
 				}
 
-				t.rowKeyMaps[groupKeyString][rowKey] = t.nextRow
-				t.nextRow++
+				t.rowKeyMaps[groupKeyString][rowKey] = n
 			}
 
 			// at this point, we've created, added and back-filled all the columns we know about
@@ -290,10 +308,17 @@ func (t *pivotTransformation) Process(id execute.DatasetID, tbl query.Table) err
 				t.colKeyMaps[groupKeyString][colKey])
 
 		}
+		if err := execute.SpillIfNeeded(t.cache); err != nil {
+			return err
+		}
+		if builder.NRows() == 0 {
+			// The rows of every table have been spilled, the rows of the next run start anew.
+			for key := range t.rowKeyMaps {
+				t.rowKeyMaps[key] = make(map[string]int)
+			}
+		}
 		return nil
 	})
-
-	return nil
 }
 
 func growColumn(builder execute.TableBuilder, colType query.DataType, colIdx, nRows int) {
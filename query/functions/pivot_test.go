@@ -10,6 +10,7 @@ import (
 	"github.com/EMCECS/influx/query/execute/executetest"
 	"github.com/EMCECS/influx/query/functions"
 	"github.com/EMCECS/influx/query/querytest"
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestPivot_NewQuery(t *testing.T) {
@@ -491,3 +492,84 @@ func TestPivot_Process(t *testing.T) {
 		})
 	}
 }
+
+func TestPivot_ProcessSpilled(t *testing.T) {
+	spec := &functions.PivotProcedureSpec{
+		RowKey:   []string{"_time"},
+		ColKey:   []string{"_field"},
+		ValueCol: "_value",
+	}
+	data := []query.Table{
+		&executetest.Table{
+			KeyCols: []string{"_measurement", "_field"},
+			ColMeta: []query.ColMeta{
+				{Label: "_time", Type: query.TTime},
+				{Label: "_value", Type: query.TFloat},
+				{Label: "_measurement", Type: query.TString},
+				{Label: "_field", Type: query.TString},
+			},
+			Data: [][]interface{}{
+				{execute.Time(3), 1.0, "m1", "f1"},
+				{execute.Time(1), 2.0, "m1", "f1"},
+			},
+		},
+		&executetest.Table{
+			KeyCols: []string{"_measurement", "_field"},
+			ColMeta: []query.ColMeta{
+				{Label: "_time", Type: query.TTime},
+				{Label: "_value", Type: query.TFloat},
+				{Label: "_measurement", Type: query.TString},
+				{Label: "_field", Type: query.TString},
+			},
+			Data: [][]interface{}{
+				{execute.Time(2), 3.0, "m1", "f2"},
+				{execute.Time(1), 4.0, "m1", "f2"},
+			},
+		},
+	}
+	// The rows spilled after each table are combined by time, the values that are never set are zero.
+	want := []*executetest.Table{
+		{
+			KeyCols: []string{"_measurement"},
+			ColMeta: []query.ColMeta{
+				{Label: "_time", Type: query.TTime},
+				{Label: "_measurement", Type: query.TString},
+				{Label: "f1", Type: query.TFloat},
+				{Label: "f2", Type: query.TFloat},
+			},
+			Data: [][]interface{}{
+				{execute.Time(1), "m1", 2.0, 4.0},
+				{execute.Time(2), "m1", 0.0, 3.0},
+				{execute.Time(3), "m1", 1.0, 0.0},
+			},
+		},
+	}
+
+	spiller := &execute.Spiller{Threshold: 1e-12}
+	defer spiller.Close()
+	d := executetest.NewDataset(executetest.RandomDatasetID())
+	c := execute.NewTableBuilderCache(&execute.Allocator{Limit: 1 << 30})
+	c.SetTriggerSpec(execute.DefaultTriggerSpec)
+	c.SetSpiller(spiller)
+	tx := functions.NewPivotTransformation(d, c, spec)
+
+	parentID := executetest.RandomDatasetID()
+	for _, tbl := range data {
+		if err := tx.Process(parentID, tbl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if spiller.BytesSpilled() == 0 {
+		t.Fatal("expected tables to be spilled")
+	}
+
+	got, err := executetest.TablesFromCache(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	executetest.NormalizeTables(got)
+	executetest.NormalizeTables(want)
+	if !cmp.Equal(want, got) {
+		t.Errorf("unexpected tables -want/+got\n%s", cmp.Diff(want, got))
+	}
+}
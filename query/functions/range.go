@@ -162,6 +162,14 @@ func (s *RangeProcedureSpec) TimeBounds() query.Bounds {
 	return s.Bounds
 }
 
+// Sliceable reports whether the rows are bounded by their time,
+// and the bounds are recorded in the default columns.
+func (s *RangeProcedureSpec) Sliceable() bool {
+	return s.TimeCol == execute.DefaultTimeColLabel &&
+		s.StartCol == execute.DefaultStartColLabel &&
+		s.StopCol == execute.DefaultStopColLabel
+}
+
 func createRangeTransformation(id execute.DatasetID, mode execute.AccumulationMode, spec plan.ProcedureSpec, a execute.Administration) (execute.Transformation, execute.Dataset, error) {
 	s, ok := spec.(*RangeProcedureSpec)
 	if !ok {
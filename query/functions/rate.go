@@ -0,0 +1,525 @@
+package functions
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/execute"
+	"github.com/EMCECS/influx/query/interpreter"
+	"github.com/EMCECS/influx/query/plan"
+	"github.com/EMCECS/influx/query/semantic"
+)
+
+const (
+	RateKind  = "rate"
+	IRateKind = "irate"
+	DeltaKind = "delta"
+)
+
+// RateOpSpec computes the per unit rate of increase of counters over the window of each table.
+// Counter resets are detected when a value is lower than the previous one.
+type RateOpSpec struct {
+	Unit query.Duration `json:"unit"`
+	// Extrapolate extends the increase to the bounds of the window, otherwise the rate is measured between the first and last values.
+	Extrapolate bool     `json:"extrapolate"`
+	Columns     []string `json:"columns"`
+	TimeSrc     string   `json:"timeSrc"`
+}
+
+// IRateOpSpec computes the per unit rate of increase of counters between the last two values of each table.
+type IRateOpSpec struct {
+	Unit    query.Duration `json:"unit"`
+	Columns []string       `json:"columns"`
+	TimeSrc string         `json:"timeSrc"`
+}
+
+// DeltaOpSpec computes the difference between the first and last values of gauges over the window of each table.
+type DeltaOpSpec struct {
+	Extrapolate bool     `json:"extrapolate"`
+	Columns     []string `json:"columns"`
+	TimeSrc     string   `json:"timeSrc"`
+}
+
+var (
+	rateSignature  = query.DefaultFunctionSignature()
+	iRateSignature = query.DefaultFunctionSignature()
+	deltaSignature = query.DefaultFunctionSignature()
+)
+
+func init() {
+	for _, sig := range []semantic.FunctionSignature{rateSignature, iRateSignature, deltaSignature} {
+		sig.Params["columns"] = semantic.NewArrayType(semantic.String)
+		sig.Params["timeSrc"] = semantic.String
+	}
+	rateSignature.Params["unit"] = semantic.Duration
+	rateSignature.Params["extrapolate"] = semantic.Bool
+	iRateSignature.Params["unit"] = semantic.Duration
+	deltaSignature.Params["extrapolate"] = semantic.Bool
+
+	query.RegisterFunction(RateKind, createRateOpSpec, rateSignature)
+	query.RegisterFunction(IRateKind, createIRateOpSpec, iRateSignature)
+	query.RegisterFunction(DeltaKind, createDeltaOpSpec, deltaSignature)
+	query.RegisterOpSpec(RateKind, newRateOp)
+	query.RegisterOpSpec(IRateKind, newIRateOp)
+	query.RegisterOpSpec(DeltaKind, newDeltaOp)
+	plan.RegisterProcedureSpec(RateKind, newRateProcedure, RateKind)
+	plan.RegisterProcedureSpec(IRateKind, newRateProcedure, IRateKind)
+	plan.RegisterProcedureSpec(DeltaKind, newRateProcedure, DeltaKind)
+	execute.RegisterTransformation(RateKind, createRateTransformation)
+	execute.RegisterTransformation(IRateKind, createRateTransformation)
+	execute.RegisterTransformation(DeltaKind, createRateTransformation)
+}
+
+func createRateOpSpec(args query.Arguments, a *query.Administration) (query.OperationSpec, error) {
+	if err := a.AddParentFromArgs(args); err != nil {
+		return nil, err
+	}
+	spec := new(RateOpSpec)
+	var err error
+	if spec.Unit, err = readRateUnit(args); err != nil {
+		return nil, err
+	}
+	if spec.Extrapolate, err = readRateExtrapolate(args); err != nil {
+		return nil, err
+	}
+	if spec.Columns, spec.TimeSrc, err = readRateColumns(args); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+func createIRateOpSpec(args query.Arguments, a *query.Administration) (query.OperationSpec, error) {
+	if err := a.AddParentFromArgs(args); err != nil {
+		return nil, err
+	}
+	spec := new(IRateOpSpec)
+	var err error
+	if spec.Unit, err = readRateUnit(args); err != nil {
+		return nil, err
+	}
+	if spec.Columns, spec.TimeSrc, err = readRateColumns(args); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+func createDeltaOpSpec(args query.Arguments, a *query.Administration) (query.OperationSpec, error) {
+	if err := a.AddParentFromArgs(args); err != nil {
+		return nil, err
+	}
+	spec := new(DeltaOpSpec)
+	var err error
+	if spec.Extrapolate, err = readRateExtrapolate(args); err != nil {
+		return nil, err
+	}
+	if spec.Columns, spec.TimeSrc, err = readRateColumns(args); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+func readRateUnit(args query.Arguments) (query.Duration, error) {
+	unit, ok, err := args.GetDuration("unit")
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		//Default is 1s
+		return query.Duration(time.Second), nil
+	}
+	if unit <= 0 {
+		return 0, errors.New("unit must be positive")
+	}
+	return unit, nil
+}
+
+func readRateExtrapolate(args query.Arguments) (bool, error) {
+	extrapolate, ok, err := args.GetBool("extrapolate")
+	if err != nil {
+		return false, err
+	}
+	return extrapolate || !ok, nil
+}
+
+func readRateColumns(args query.Arguments) ([]string, string, error) {
+	columns := []string{execute.DefaultValueColLabel}
+	if cols, ok, err := args.GetArray("columns", semantic.String); err != nil {
+		return nil, "", err
+	} else if ok {
+		if columns, err = interpreter.ToStringArray(cols); err != nil {
+			return nil, "", err
+		}
+	}
+	timeSrc := execute.DefaultTimeColLabel
+	if timeCol, ok, err := args.GetString("timeSrc"); err != nil {
+		return nil, "", err
+	} else if ok {
+		timeSrc = timeCol
+	}
+	return columns, timeSrc, nil
+}
+
+func newRateOp() query.OperationSpec {
+	return new(RateOpSpec)
+}
+
+func newIRateOp() query.OperationSpec {
+	return new(IRateOpSpec)
+}
+
+func newDeltaOp() query.OperationSpec {
+	return new(DeltaOpSpec)
+}
+
+func (s *RateOpSpec) Kind() query.OperationKind {
+	return RateKind
+}
+
+func (s *IRateOpSpec) Kind() query.OperationKind {
+	return IRateKind
+}
+
+func (s *DeltaOpSpec) Kind() query.OperationKind {
+	return DeltaKind
+}
+
+type RateProcedureSpec struct {
+	// Method is one of rate, irate or delta.
+	Method      string
+	Unit        query.Duration
+	Extrapolate bool
+	Columns     []string
+	TimeCol     string
+}
+
+func newRateProcedure(qs query.OperationSpec, pa plan.Administration) (plan.ProcedureSpec, error) {
+	switch spec := qs.(type) {
+	case *RateOpSpec:
+		return &RateProcedureSpec{
+			Method:      RateKind,
+			Unit:        spec.Unit,
+			Extrapolate: spec.Extrapolate,
+			Columns:     spec.Columns,
+			TimeCol:     spec.TimeSrc,
+		}, nil
+	case *IRateOpSpec:
+		return &RateProcedureSpec{
+			Method:  IRateKind,
+			Unit:    spec.Unit,
+			Columns: spec.Columns,
+			TimeCol: spec.TimeSrc,
+		}, nil
+	case *DeltaOpSpec:
+		return &RateProcedureSpec{
+			Method:      DeltaKind,
+			Extrapolate: spec.Extrapolate,
+			Columns:     spec.Columns,
+			TimeCol:     spec.TimeSrc,
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid spec type %T", qs)
+	}
+}
+
+func (s *RateProcedureSpec) Kind() plan.ProcedureKind {
+	return plan.ProcedureKind(s.Method)
+}
+func (s *RateProcedureSpec) Copy() plan.ProcedureSpec {
+	ns := new(RateProcedureSpec)
+	*ns = *s
+	if s.Columns != nil {
+		ns.Columns = make([]string, len(s.Columns))
+		copy(ns.Columns, s.Columns)
+	}
+	return ns
+}
+
+func createRateTransformation(id execute.DatasetID, mode execute.AccumulationMode, spec plan.ProcedureSpec, a execute.Administration) (execute.Transformation, execute.Dataset, error) {
+	s, ok := spec.(*RateProcedureSpec)
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid spec type %T", spec)
+	}
+	cache := execute.NewTableBuilderCache(a.Allocator())
+	d := execute.NewDataset(id, mode, cache)
+	t := NewRateTransformation(d, cache, s)
+	return t, d, nil
+}
+
+type rateTransformation struct {
+	d     execute.Dataset
+	cache execute.TableBuilderCache
+
+	method      string
+	unit        execute.Duration
+	extrapolate bool
+	columns     []string
+	timeCol     string
+}
+
+func NewRateTransformation(d execute.Dataset, cache execute.TableBuilderCache, spec *RateProcedureSpec) *rateTransformation {
+	unit := execute.Duration(spec.Unit)
+	if unit <= 0 {
+		unit = execute.Duration(time.Second)
+	}
+	return &rateTransformation{
+		d:           d,
+		cache:       cache,
+		method:      spec.Method,
+		unit:        unit,
+		extrapolate: spec.Extrapolate && spec.Method != IRateKind,
+		columns:     spec.Columns,
+		timeCol:     spec.TimeCol,
+	}
+}
+
+func (t *rateTransformation) RetractTable(id execute.DatasetID, key query.GroupKey) error {
+	return t.d.RetractTable(key)
+}
+
+func (t *rateTransformation) Process(id execute.DatasetID, tbl query.Table) error {
+	key := tbl.Key()
+	cols := tbl.Cols()
+
+	timeIdx := execute.ColIdx(t.timeCol, cols)
+	if timeIdx < 0 {
+		return fmt.Errorf("time column %q does not exist", t.timeCol)
+	}
+	if typ := cols[timeIdx].Type; typ != query.TTime {
+		return fmt.Errorf("time column %q has type %v", t.timeCol, typ)
+	}
+	if key.HasCol(t.timeCol) {
+		return fmt.Errorf("time column %q is part of the group key", t.timeCol)
+	}
+
+	// The window of the table is given by its start and stop columns.
+	startIdx := execute.ColIdx(execute.DefaultStartColLabel, key.Cols())
+	stopIdx := execute.ColIdx(execute.DefaultStopColLabel, key.Cols())
+	hasBounds := startIdx >= 0 && stopIdx >= 0 &&
+		key.Cols()[startIdx].Type == query.TTime && key.Cols()[stopIdx].Type == query.TTime
+	if t.extrapolate && !hasBounds {
+		return fmt.Errorf("%s requires the %q and %q columns in the group key to extrapolate", t.method, execute.DefaultStartColLabel, execute.DefaultStopColLabel)
+	}
+
+	colMap := make([]int, len(t.columns))
+	for j, label := range t.columns {
+		idx := execute.ColIdx(label, cols)
+		if idx < 0 {
+			return fmt.Errorf("column %q does not exist", label)
+		}
+		if key.HasCol(label) {
+			return fmt.Errorf("cannot compute the %s of column %q that is part of the group key", t.method, label)
+		}
+		switch typ := cols[idx].Type; typ {
+		case query.TInt, query.TUInt, query.TFloat:
+		default:
+			return fmt.Errorf("%s does not support %v", t.method, typ)
+		}
+		colMap[j] = idx
+	}
+
+	builder, created := t.cache.TableBuilder(key)
+	if !created {
+		return fmt.Errorf("%s found duplicate table with key: %v", t.method, key)
+	}
+	execute.AddTableKeyCols(key, builder)
+	timeOut := builder.AddCol(query.ColMeta{
+		Label: t.timeCol,
+		Type:  query.TTime,
+	})
+	outs := make([]int, len(t.columns))
+	for j, label := range t.columns {
+		outs[j] = builder.AddCol(query.ColMeta{
+			Label: label,
+			Type:  query.TFloat,
+		})
+	}
+
+	samples := make([]rateSamples, len(t.columns))
+	err := tbl.Do(func(cr query.ColReader) error {
+		for j, idx := range colMap {
+			s := &samples[j]
+			for i := 0; i < cr.Len(); i++ {
+				if execute.IsNull(i, idx, cr) || execute.IsNull(i, timeIdx, cr) {
+					continue
+				}
+				var v float64
+				switch cols[idx].Type {
+				case query.TInt:
+					v = float64(cr.Ints(idx)[i])
+				case query.TUInt:
+					v = float64(cr.UInts(idx)[i])
+				case query.TFloat:
+					v = cr.Floats(idx)[i]
+				}
+				if err := s.add(cr.Times(timeIdx)[i], v); err != nil {
+					return fmt.Errorf("%s requires the table to be sorted by %q", t.method, t.timeCol)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	execute.AppendKeyValues(key, builder)
+	if hasBounds {
+		builder.AppendTime(timeOut, key.ValueTime(stopIdx))
+	} else {
+		// Without a window, the result is at the time of the last value.
+		var last execute.Time
+		for _, s := range samples {
+			if s.count > 0 && s.lastTime > last {
+				last = s.lastTime
+			}
+		}
+		builder.AppendTime(timeOut, last)
+	}
+	for j, s := range samples {
+		var (
+			v  float64
+			ok bool
+		)
+		switch t.method {
+		case IRateKind:
+			v, ok = s.irate(t.unit)
+		case DeltaKind:
+			v, ok = s.extrapolatedDelta(t.window(key, startIdx, stopIdx))
+		default:
+			if v, ok = s.extrapolatedDelta(t.window(key, startIdx, stopIdx)); ok {
+				v /= t.perUnit(s, key, startIdx, stopIdx)
+			}
+		}
+		if !ok {
+			builder.AppendNil(outs[j])
+			continue
+		}
+		builder.AppendFloat(outs[j], v)
+	}
+	return nil
+}
+
+// rateWindow is the window of a table and whether the delta is for a counter.
+type rateWindow struct {
+	start, stop execute.Time
+	counter     bool
+	extrapolate bool
+}
+
+func (t *rateTransformation) window(key query.GroupKey, startIdx, stopIdx int) rateWindow {
+	w := rateWindow{
+		counter:     t.method == RateKind,
+		extrapolate: t.extrapolate,
+	}
+	if t.extrapolate {
+		w.start = key.ValueTime(startIdx)
+		w.stop = key.ValueTime(stopIdx)
+	}
+	return w
+}
+
+// perUnit returns the number of units the rate is measured over,
+// the whole window when extrapolating and the time between the first and last values otherwise.
+func (t *rateTransformation) perUnit(s rateSamples, key query.GroupKey, startIdx, stopIdx int) float64 {
+	if t.extrapolate {
+		return float64(key.ValueTime(stopIdx)-key.ValueTime(startIdx)) / float64(t.unit)
+	}
+	return float64(s.lastTime-s.firstTime) / float64(t.unit)
+}
+
+func (t *rateTransformation) UpdateWatermark(id execute.DatasetID, mark execute.Time) error {
+	return t.d.UpdateWatermark(mark)
+}
+func (t *rateTransformation) UpdateProcessingTime(id execute.DatasetID, pt execute.Time) error {
+	return t.d.UpdateProcessingTime(pt)
+}
+func (t *rateTransformation) Finish(id execute.DatasetID, err error) {
+	t.d.Finish(err)
+}
+
+// rateSamples summarizes the values of a column in time order.
+type rateSamples struct {
+	count               int
+	first, prev, last   float64
+	firstTime, prevTime execute.Time
+	lastTime            execute.Time
+	// resets is the sum of the values before each counter reset.
+	resets float64
+}
+
+var errRateUnsorted = errors.New("values are not sorted by time")
+
+func (s *rateSamples) add(tm execute.Time, v float64) error {
+	if s.count == 0 {
+		s.first, s.firstTime = v, tm
+	} else {
+		if tm < s.lastTime {
+			return errRateUnsorted
+		}
+		if v < s.last {
+			// The counter was reset, it increased by at least v since the last value.
+			s.resets += s.last
+		}
+		s.prev, s.prevTime = s.last, s.lastTime
+	}
+	s.last, s.lastTime = v, tm
+	s.count++
+	return nil
+}
+
+// extrapolatedDelta returns the difference between the last and first values, corrected for counter resets,
+// and extrapolated to the bounds of the window as Prometheus does.
+// The difference is extrapolated to a bound when the first or last value is close to it,
+// otherwise it is extrapolated by half the average interval between values.
+// Counters are not extrapolated below zero.
+func (s rateSamples) extrapolatedDelta(w rateWindow) (float64, bool) {
+	if s.count < 2 || s.lastTime == s.firstTime {
+		return 0, false
+	}
+	delta := s.last - s.first
+	if w.counter {
+		delta += s.resets
+	}
+	if !w.extrapolate {
+		return delta, true
+	}
+
+	sampled := float64(s.lastTime - s.firstTime)
+	toStart := float64(s.firstTime - w.start)
+	toEnd := float64(w.stop - s.lastTime)
+	if w.counter && delta > 0 && s.first >= 0 {
+		// The counter was zero at most this long before the first value.
+		if toZero := sampled * (s.first / delta); toZero < toStart {
+			toStart = toZero
+		}
+	}
+
+	average := sampled / float64(s.count-1)
+	threshold := average * 1.1
+	interval := sampled
+	if toStart < threshold {
+		interval += toStart
+	} else {
+		interval += average / 2
+	}
+	if toEnd < threshold {
+		interval += toEnd
+	} else {
+		interval += average / 2
+	}
+	return delta * (interval / sampled), true
+}
+
+// irate returns the per unit rate between the last two values, a lower last value is a counter reset.
+func (s rateSamples) irate(unit execute.Duration) (float64, bool) {
+	if s.count < 2 || s.lastTime == s.prevTime {
+		return 0, false
+	}
+	delta := s.last - s.prev
+	if s.last < s.prev {
+		delta = s.last
+	}
+	return delta / (float64(s.lastTime-s.prevTime) / float64(unit)), true
+}
@@ -0,0 +1,326 @@
+package functions_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/execute"
+	"github.com/EMCECS/influx/query/execute/executetest"
+	"github.com/EMCECS/influx/query/functions"
+	"github.com/EMCECS/influx/query/querytest"
+)
+
+func TestRate_NewQuery(t *testing.T) {
+	tests := []querytest.NewQueryTestCase{
+		{
+			Name: "rate",
+			Raw:  `from(bucket:"mydb") |> rate(unit:1m)`,
+			Want: &query.Spec{
+				Operations: []*query.Operation{
+					{
+						ID: "from0",
+						Spec: &functions.FromOpSpec{
+							Bucket: "mydb",
+						},
+					},
+					{
+						ID: "rate1",
+						Spec: &functions.RateOpSpec{
+							Unit:        query.Duration(time.Minute),
+							Extrapolate: true,
+							Columns:     []string{"_value"},
+							TimeSrc:     "_time",
+						},
+					},
+				},
+				Edges: []query.Edge{
+					{Parent: "from0", Child: "rate1"},
+				},
+			},
+		},
+		{
+			Name: "irate",
+			Raw:  `from(bucket:"mydb") |> irate(columns:["a"])`,
+			Want: &query.Spec{
+				Operations: []*query.Operation{
+					{
+						ID: "from0",
+						Spec: &functions.FromOpSpec{
+							Bucket: "mydb",
+						},
+					},
+					{
+						ID: "irate1",
+						Spec: &functions.IRateOpSpec{
+							Unit:    query.Duration(time.Second),
+							Columns: []string{"a"},
+							TimeSrc: "_time",
+						},
+					},
+				},
+				Edges: []query.Edge{
+					{Parent: "from0", Child: "irate1"},
+				},
+			},
+		},
+		{
+			Name: "delta without extrapolation",
+			Raw:  `from(bucket:"mydb") |> delta(extrapolate:false)`,
+			Want: &query.Spec{
+				Operations: []*query.Operation{
+					{
+						ID: "from0",
+						Spec: &functions.FromOpSpec{
+							Bucket: "mydb",
+						},
+					},
+					{
+						ID: "delta1",
+						Spec: &functions.DeltaOpSpec{
+							Columns: []string{"_value"},
+							TimeSrc: "_time",
+						},
+					},
+				},
+				Edges: []query.Edge{
+					{Parent: "from0", Child: "delta1"},
+				},
+			},
+		},
+		{
+			Name:    "negative unit",
+			Raw:     `from(bucket:"mydb") |> rate(unit:-1s)`,
+			WantErr: true,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			querytest.NewQueryTestHelper(t, tc)
+		})
+	}
+}
+
+func TestRateOperation_Marshaling(t *testing.T) {
+	data := []byte(`{"id":"rate","kind":"rate","spec":{"unit":"1s","extrapolate":true,"columns":["_value"],"timeSrc":"_time"}}`)
+	op := &query.Operation{
+		ID: "rate",
+		Spec: &functions.RateOpSpec{
+			Unit:        query.Duration(time.Second),
+			Extrapolate: true,
+			Columns:     []string{"_value"},
+			TimeSrc:     "_time",
+		},
+	}
+	querytest.OperationMarshalingTestHelper(t, data, op)
+}
+
+// The expected values of the windowed tests are the outputs of Prometheus for the same series.
+func TestRate_Process(t *testing.T) {
+	m := func(n int64) execute.Time {
+		return execute.Time(n * int64(time.Minute))
+	}
+	cols := []query.ColMeta{
+		{Label: "_start", Type: query.TTime},
+		{Label: "_stop", Type: query.TTime},
+		{Label: "path", Type: query.TString},
+		{Label: "_time", Type: query.TTime},
+		{Label: "_value", Type: query.TFloat},
+	}
+	keyCols := []string{"_start", "_stop", "path"}
+	// series returns the table of the values every 5m from the start time.
+	series := func(start, stop execute.Time, path string, first execute.Time, vs ...float64) *executetest.Table {
+		tbl := &executetest.Table{
+			KeyCols: keyCols,
+			ColMeta: cols,
+		}
+		for i, v := range vs {
+			tbl.Data = append(tbl.Data, []interface{}{start, stop, path, first + m(int64(5*i)), v})
+		}
+		return tbl
+	}
+	result := func(start, stop execute.Time, path string, v interface{}) *executetest.Table {
+		return &executetest.Table{
+			KeyCols: keyCols,
+			ColMeta: cols,
+			Data: [][]interface{}{
+				{start, stop, path, stop, v},
+			},
+		}
+	}
+	testCases := []struct {
+		name    string
+		spec    *functions.RateProcedureSpec
+		data    []query.Table
+		want    []*executetest.Table
+		wantErr error
+	}{
+		{
+			name: "rate with counter reset",
+			spec: &functions.RateProcedureSpec{
+				Method:      functions.RateKind,
+				Unit:        query.Duration(time.Second),
+				Extrapolate: true,
+				Columns:     []string{"_value"},
+				TimeCol:     "_time",
+			},
+			data: []query.Table{
+				series(m(0), m(50), "/foo", m(0), 0, 10, 20, 30, 40, 50, 60, 70, 80, 90, 100),
+				series(m(0), m(50), "/bar", m(0), 0, 10, 20, 30, 40, 50, 0, 10, 20, 30, 40),
+			},
+			want: []*executetest.Table{
+				result(m(0), m(50), "/foo", 100.0/3000),
+				result(m(0), m(50), "/bar", 90.0/3000),
+			},
+		},
+		{
+			name: "rate extrapolated to zero",
+			spec: &functions.RateProcedureSpec{
+				Method:      functions.RateKind,
+				Unit:        query.Duration(time.Second),
+				Extrapolate: true,
+				Columns:     []string{"_value"},
+				TimeCol:     "_time",
+			},
+			data: []query.Table{
+				series(m(-50), m(50), "/foo", m(0), 0, 10, 20, 30, 40, 50, 60, 70, 80, 90, 100),
+			},
+			want: []*executetest.Table{
+				result(m(-50), m(50), "/foo", 100.0/6000),
+			},
+		},
+		{
+			name: "rate extrapolated to window bounds",
+			spec: &functions.RateProcedureSpec{
+				Method:      functions.RateKind,
+				Unit:        query.Duration(time.Second),
+				Extrapolate: true,
+				Columns:     []string{"_value"},
+				TimeCol:     "_time",
+			},
+			data: []query.Table{
+				series(m(0), m(50), "/foo", m(10), 10, 20, 30, 40, 50, 60, 70),
+			},
+			want: []*executetest.Table{
+				result(m(0), m(50), "/foo", 0.025),
+			},
+		},
+		{
+			name: "irate",
+			spec: &functions.RateProcedureSpec{
+				Method:  functions.IRateKind,
+				Unit:    query.Duration(time.Second),
+				Columns: []string{"_value"},
+				TimeCol: "_time",
+			},
+			data: []query.Table{
+				series(m(-20), m(30), "/foo", m(0), 0, 10, 20, 30, 40, 50, 60),
+				series(m(-20), m(30), "/bar", m(0), 0, 10, 20, 30, 40, 50, 0),
+			},
+			want: []*executetest.Table{
+				result(m(-20), m(30), "/foo", 10.0/300),
+				result(m(-20), m(30), "/bar", 0.0),
+			},
+		},
+		{
+			name: "delta",
+			spec: &functions.RateProcedureSpec{
+				Method:      functions.DeltaKind,
+				Extrapolate: true,
+				Columns:     []string{"_value"},
+				TimeCol:     "_time",
+			},
+			data: []query.Table{
+				series(m(0), m(20), "/foo", m(0), 0, 50, 100, 150, 200),
+				series(m(0), m(20), "/bar", m(0), 200, 150, 100, 50, 0),
+			},
+			want: []*executetest.Table{
+				result(m(0), m(20), "/foo", 200.0),
+				result(m(0), m(20), "/bar", -200.0),
+			},
+		},
+		{
+			name: "single value",
+			spec: &functions.RateProcedureSpec{
+				Method:      functions.RateKind,
+				Unit:        query.Duration(time.Second),
+				Extrapolate: true,
+				Columns:     []string{"_value"},
+				TimeCol:     "_time",
+			},
+			data: []query.Table{
+				series(m(0), m(20), "/foo", m(0), 1),
+			},
+			want: []*executetest.Table{
+				result(m(0), m(20), "/foo", nil),
+			},
+		},
+		{
+			name: "rate without window",
+			spec: &functions.RateProcedureSpec{
+				Method:  functions.RateKind,
+				Unit:    query.Duration(time.Minute),
+				Columns: []string{"_value"},
+				TimeCol: "_time",
+			},
+			data: []query.Table{&executetest.Table{
+				ColMeta: []query.ColMeta{
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TInt},
+				},
+				Data: [][]interface{}{
+					{execute.Time(0), int64(5)},
+					{execute.Time(10 * time.Second), int64(10)},
+					{execute.Time(20 * time.Second), int64(2)},
+				},
+			}},
+			want: []*executetest.Table{{
+				ColMeta: []query.ColMeta{
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TFloat},
+				},
+				Data: [][]interface{}{
+					{execute.Time(20 * time.Second), 21.0},
+				},
+			}},
+		},
+		{
+			name: "extrapolate without window",
+			spec: &functions.RateProcedureSpec{
+				Method:      functions.RateKind,
+				Unit:        query.Duration(time.Second),
+				Extrapolate: true,
+				Columns:     []string{"_value"},
+				TimeCol:     "_time",
+			},
+			data: []query.Table{&executetest.Table{
+				ColMeta: []query.ColMeta{
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TFloat},
+				},
+				Data: [][]interface{}{
+					{execute.Time(0), 1.0},
+				},
+			}},
+			want:    []*executetest.Table(nil),
+			wantErr: errors.New(`rate requires the "_start" and "_stop" columns in the group key to extrapolate`),
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			executetest.ProcessTestHelper(
+				t,
+				tc.data,
+				tc.want,
+				tc.wantErr,
+				func(d execute.Dataset, c execute.TableBuilderCache) execute.Transformation {
+					return functions.NewRateTransformation(d, c, tc.spec)
+				},
+			)
+		})
+	}
+}
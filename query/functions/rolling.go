@@ -0,0 +1,736 @@
+package functions
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/execute"
+	"github.com/EMCECS/influx/query/interpreter"
+	"github.com/EMCECS/influx/query/plan"
+	"github.com/EMCECS/influx/query/semantic"
+	"github.com/EMCECS/influx/query/values"
+)
+
+const RollingKind = "rolling"
+
+// RollingOpSpec applies an aggregate to a window that slides over the records of each table.
+// The window is either the last N records, or the records within Period of each record.
+// When Every is set, the window slides by Every instead and one record is produced per window.
+type RollingOpSpec struct {
+	N          int64          `json:"n"`
+	Period     query.Duration `json:"period"`
+	Every      query.Duration `json:"every"`
+	Columns    []string       `json:"columns"`
+	TimeColumn string         `json:"timeColumn"`
+	// Fn is the aggregate operation applied to each window.
+	Fn query.Operation `json:"fn"`
+}
+
+var rollingSignature = query.DefaultFunctionSignature()
+
+func init() {
+	rollingSignature.Params["n"] = semantic.Int
+	rollingSignature.Params["period"] = semantic.Duration
+	rollingSignature.Params["every"] = semantic.Duration
+	rollingSignature.Params["fn"] = semantic.Function
+	rollingSignature.Params["columns"] = semantic.NewArrayType(semantic.String)
+	rollingSignature.Params["timeColumn"] = semantic.String
+
+	query.RegisterFunction(RollingKind, createRollingOpSpec, rollingSignature)
+	query.RegisterOpSpec(RollingKind, newRollingOp)
+	plan.RegisterProcedureSpec(RollingKind, newRollingProcedure, RollingKind)
+	execute.RegisterTransformation(RollingKind, createRollingTransformation)
+}
+
+func createRollingOpSpec(args query.Arguments, a *query.Administration) (query.OperationSpec, error) {
+	if err := a.AddParentFromArgs(args); err != nil {
+		return nil, err
+	}
+
+	spec := &RollingOpSpec{
+		Columns:    []string{execute.DefaultValueColLabel},
+		TimeColumn: execute.DefaultTimeColLabel,
+	}
+
+	n, nOK, err := args.GetInt("n")
+	if err != nil {
+		return nil, err
+	}
+	period, periodOK, err := args.GetDuration("period")
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case nOK == periodOK:
+		return nil, errors.New("rolling requires exactly one of n or period")
+	case nOK && n <= 0:
+		return nil, fmt.Errorf("n must be positive, got %d", n)
+	case periodOK && period <= 0:
+		return nil, errors.New("period must be positive")
+	}
+	spec.N = n
+	spec.Period = period
+
+	if every, ok, err := args.GetDuration("every"); err != nil {
+		return nil, err
+	} else if ok {
+		if !periodOK {
+			return nil, errors.New("every requires a period")
+		}
+		if every <= 0 {
+			return nil, errors.New("every must be positive")
+		}
+		spec.Every = every
+	}
+
+	if cols, ok, err := args.GetArray("columns", semantic.String); err != nil {
+		return nil, err
+	} else if ok {
+		columns, err := interpreter.ToStringArray(cols)
+		if err != nil {
+			return nil, err
+		}
+		spec.Columns = columns
+	}
+	if col, ok, err := args.GetString("timeColumn"); err != nil {
+		return nil, err
+	} else if ok {
+		spec.TimeColumn = col
+	}
+
+	f, err := args.GetRequiredFunction("fn")
+	if err != nil {
+		return nil, err
+	}
+	if spec.Fn, err = rollingFunction(f); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// rollingFunction calls fn with a placeholder table to find the aggregate operation it applies.
+func rollingFunction(fn values.Function) (query.Operation, error) {
+	in := &query.TableObject{
+		Kind:    RollingKind,
+		Parents: values.NewArray(semantic.EmptyObject),
+	}
+	args := values.NewObject()
+	args.Set(query.TableParameter, in)
+	v, err := fn.Call(args)
+	if err != nil {
+		return query.Operation{}, err
+	}
+	out, ok := v.(*query.TableObject)
+	if !ok || out.Parents.Len() != 1 || out.Parents.Get(0) != in {
+		return query.Operation{}, errors.New("rolling fn must apply a single aggregate to its table")
+	}
+	if _, err := rollingAggregate(out.Spec); err != nil {
+		return query.Operation{}, err
+	}
+	return query.Operation{
+		ID:   query.OperationID(out.Kind),
+		Spec: out.Spec,
+	}, nil
+}
+
+// rollingAggregate returns the aggregate of an aggregate operation spec.
+func rollingAggregate(spec query.OperationSpec) (execute.Aggregate, error) {
+	switch s := spec.(type) {
+	case *CountOpSpec:
+		return new(CountAgg), nil
+	case *MeanOpSpec:
+		return new(MeanAgg), nil
+	case *SumOpSpec:
+		return new(SumAgg), nil
+	case *StddevOpSpec:
+		return new(StddevAgg), nil
+	case *SkewOpSpec:
+		return new(SkewAgg), nil
+	case *SpreadOpSpec:
+		return new(SpreadAgg), nil
+	case *PercentileOpSpec:
+		switch s.Method {
+		case methodExactMean:
+			return &ExactPercentileAgg{Quantile: s.Percentile}, nil
+		case methodExactSelector:
+			return nil, fmt.Errorf("rolling does not support percentile method %q", s.Method)
+		default:
+			return &PercentileAgg{Quantile: s.Percentile, Compression: s.Compression}, nil
+		}
+	case nil:
+		return nil, errors.New("rolling requires an aggregate fn")
+	default:
+		return nil, fmt.Errorf("rolling does not support %s, fn must apply an aggregate", spec.Kind())
+	}
+}
+
+func newRollingOp() query.OperationSpec {
+	return new(RollingOpSpec)
+}
+
+func (s *RollingOpSpec) Kind() query.OperationKind {
+	return RollingKind
+}
+
+type RollingProcedureSpec struct {
+	N          int64
+	Period     query.Duration
+	Every      query.Duration
+	Columns    []string
+	TimeColumn string
+	Fn         query.OperationSpec
+}
+
+func newRollingProcedure(qs query.OperationSpec, pa plan.Administration) (plan.ProcedureSpec, error) {
+	spec, ok := qs.(*RollingOpSpec)
+	if !ok {
+		return nil, fmt.Errorf("invalid spec type %T", qs)
+	}
+
+	return &RollingProcedureSpec{
+		N:          spec.N,
+		Period:     spec.Period,
+		Every:      spec.Every,
+		Columns:    spec.Columns,
+		TimeColumn: spec.TimeColumn,
+		Fn:         spec.Fn.Spec,
+	}, nil
+}
+
+func (s *RollingProcedureSpec) Kind() plan.ProcedureKind {
+	return RollingKind
+}
+func (s *RollingProcedureSpec) Copy() plan.ProcedureSpec {
+	ns := new(RollingProcedureSpec)
+	*ns = *s
+	if s.Columns != nil {
+		ns.Columns = make([]string, len(s.Columns))
+		copy(ns.Columns, s.Columns)
+	}
+	return ns
+}
+
+func createRollingTransformation(id execute.DatasetID, mode execute.AccumulationMode, spec plan.ProcedureSpec, a execute.Administration) (execute.Transformation, execute.Dataset, error) {
+	s, ok := spec.(*RollingProcedureSpec)
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid spec type %T", spec)
+	}
+	cache := execute.NewTableBuilderCache(a.Allocator())
+	d := execute.NewDataset(id, mode, cache)
+	t, err := NewRollingTransformation(d, cache, a.Allocator(), s)
+	if err != nil {
+		return nil, nil, err
+	}
+	return t, d, nil
+}
+
+type rollingTransformation struct {
+	d     execute.Dataset
+	cache execute.TableBuilderCache
+	alloc *execute.Allocator
+	agg   execute.Aggregate
+
+	n          int
+	period     execute.Duration
+	every      execute.Duration
+	columns    []string
+	timeColumn string
+}
+
+func NewRollingTransformation(d execute.Dataset, cache execute.TableBuilderCache, alloc *execute.Allocator, spec *RollingProcedureSpec) (*rollingTransformation, error) {
+	agg, err := rollingAggregate(spec.Fn)
+	if err != nil {
+		return nil, err
+	}
+	return &rollingTransformation{
+		d:          d,
+		cache:      cache,
+		alloc:      alloc,
+		agg:        agg,
+		n:          int(spec.N),
+		period:     execute.Duration(spec.Period),
+		every:      execute.Duration(spec.Every),
+		columns:    spec.Columns,
+		timeColumn: spec.TimeColumn,
+	}, nil
+}
+
+func (t *rollingTransformation) RetractTable(id execute.DatasetID, key query.GroupKey) error {
+	return t.d.RetractTable(key)
+}
+
+func (t *rollingTransformation) Process(id execute.DatasetID, tbl query.Table) error {
+	if t.n <= 0 && t.period <= 0 {
+		return errors.New("rolling requires a positive n or period")
+	}
+	key := tbl.Key()
+	cols := tbl.Cols()
+
+	timeIdx := -1
+	if t.period > 0 {
+		timeIdx = execute.ColIdx(t.timeColumn, cols)
+		if timeIdx < 0 {
+			return fmt.Errorf("time column %q does not exist", t.timeColumn)
+		}
+		if typ := cols[timeIdx].Type; typ != query.TTime {
+			return fmt.Errorf("time column %q has type %v", t.timeColumn, typ)
+		}
+	}
+
+	w := &rollingWindow{alloc: t.alloc}
+	defer w.free()
+	aggregated := make([]*rollingColumn, len(cols))
+	for _, label := range t.columns {
+		j := execute.ColIdx(label, cols)
+		if j < 0 {
+			return fmt.Errorf("column %q does not exist", label)
+		}
+		if key.HasCol(label) {
+			return errors.New("cannot aggregate columns that are part of the group key")
+		}
+		c := &rollingColumn{
+			idx:     j,
+			typ:     cols[j].Type,
+			running: rollingRunning(t.agg, cols[j].Type),
+		}
+		vf, _ := c.aggregate(t.agg)
+		if vf == nil {
+			return fmt.Errorf("unsupported aggregate column type %v", c.typ)
+		}
+		c.outType = vf.Type()
+		aggregated[j] = c
+		w.columns = append(w.columns, c)
+	}
+
+	builder, created := t.cache.TableBuilder(key)
+	if !created {
+		return fmt.Errorf("rolling found duplicate table with key: %v", key)
+	}
+
+	if t.every > 0 {
+		if key.HasCol(t.timeColumn) {
+			return fmt.Errorf("time column %q is part of the group key", t.timeColumn)
+		}
+		return t.processWindows(tbl, builder, w, timeIdx)
+	}
+
+	for j, c := range cols {
+		if rc := aggregated[j]; rc != nil {
+			c.Type = rc.outType
+			rc.out = j
+		}
+		builder.AddCol(c)
+	}
+	return tbl.Do(func(cr query.ColReader) error {
+		for i := 0; i < cr.Len(); i++ {
+			var tm execute.Time
+			if timeIdx >= 0 {
+				if execute.IsNull(i, timeIdx, cr) {
+					// Records without a time do not belong to any window.
+					continue
+				}
+				tm = cr.Times(timeIdx)[i]
+				if w.len() > 0 && tm < w.last() {
+					return fmt.Errorf("rolling requires the table to be sorted by %q", t.timeColumn)
+				}
+			}
+			w.push(i, tm, cr)
+			if t.n > 0 {
+				if w.len() > t.n {
+					w.drop(w.len() - t.n)
+				}
+				if w.len() < t.n {
+					continue
+				}
+			} else {
+				w.dropBefore(tm - execute.Time(t.period) + 1)
+			}
+			for j := range cols {
+				if rc := aggregated[j]; rc != nil {
+					vf, count := rc.aggregate(t.agg)
+					appendRollingValue(builder, j, vf, count)
+				} else {
+					execute.AppendValue(builder, j, execute.ValueForRow(i, j, cr))
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// processWindows produces one record for each window stop that is a multiple of every.
+// Each window contains the records from period before its stop, up to but excluding its stop.
+func (t *rollingTransformation) processWindows(tbl query.Table, builder execute.TableBuilder, w *rollingWindow, timeIdx int) error {
+	key := tbl.Key()
+	execute.AddTableKeyCols(key, builder)
+	timeOut := builder.AddCol(query.ColMeta{
+		Label: t.timeColumn,
+		Type:  query.TTime,
+	})
+	for _, c := range w.columns {
+		c.out = builder.AddCol(query.ColMeta{
+			Label: tbl.Cols()[c.idx].Label,
+			Type:  c.outType,
+		})
+	}
+
+	period, every := execute.Time(t.period), execute.Time(t.every)
+	var stop execute.Time
+	appendWindow := func() {
+		w.dropBefore(stop - period)
+		execute.AppendKeyValues(key, builder)
+		builder.AppendTime(timeOut, stop)
+		for _, c := range w.columns {
+			vf, count := c.aggregate(t.agg)
+			appendRollingValue(builder, c.out, vf, count)
+		}
+		stop += every
+	}
+
+	started := false
+	var last execute.Time
+	err := tbl.Do(func(cr query.ColReader) error {
+		for i := 0; i < cr.Len(); i++ {
+			if execute.IsNull(i, timeIdx, cr) {
+				continue
+			}
+			tm := cr.Times(timeIdx)[i]
+			if !started {
+				stop = tm.Truncate(t.every) + every
+				started = true
+			} else if tm < last {
+				return fmt.Errorf("rolling requires the table to be sorted by %q", t.timeColumn)
+			}
+			// Every record before tm has been read, so the windows that stop at or before tm are complete.
+			for stop <= tm {
+				appendWindow()
+			}
+			w.push(i, tm, cr)
+			last = tm
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for started && stop-period <= last {
+		appendWindow()
+	}
+	return nil
+}
+
+func (t *rollingTransformation) UpdateWatermark(id execute.DatasetID, mark execute.Time) error {
+	return t.d.UpdateWatermark(mark)
+}
+func (t *rollingTransformation) UpdateProcessingTime(id execute.DatasetID, pt execute.Time) error {
+	return t.d.UpdateProcessingTime(pt)
+}
+func (t *rollingTransformation) Finish(id execute.DatasetID, err error) {
+	t.d.Finish(err)
+}
+
+// rollingWindow buffers the records of the current window, in order.
+// The buffers are accounted for by the allocator. The records before start have left the window,
+// they are removed from the buffers once they take up half of them.
+type rollingWindow struct {
+	alloc   *execute.Allocator
+	start   int
+	times   []execute.Time
+	columns []*rollingColumn
+}
+
+func (w *rollingWindow) len() int {
+	return len(w.times) - w.start
+}
+
+func (w *rollingWindow) last() execute.Time {
+	return w.times[len(w.times)-1]
+}
+
+func (w *rollingWindow) push(i int, tm execute.Time, cr query.ColReader) {
+	if w.start > 0 && 2*w.start >= len(w.times) {
+		w.compact()
+	}
+	w.times = w.alloc.AppendTimes(w.times, tm)
+	for _, c := range w.columns {
+		c.push(w.alloc, i, cr)
+	}
+}
+
+// drop removes the k oldest records of the window.
+func (w *rollingWindow) drop(k int) {
+	w.start += k
+	for _, c := range w.columns {
+		c.drop(k)
+	}
+}
+
+// dropBefore removes the records with a time before start.
+func (w *rollingWindow) dropBefore(start execute.Time) {
+	k := 0
+	for w.start+k < len(w.times) && w.times[w.start+k] < start {
+		k++
+	}
+	w.drop(k)
+}
+
+// compact moves the records of the window to the front of the buffers.
+func (w *rollingWindow) compact() {
+	n := copy(w.times, w.times[w.start:])
+	w.times = w.times[:n]
+	w.start = 0
+	for _, c := range w.columns {
+		c.compact()
+	}
+}
+
+// free informs the allocator that the buffers of the window are no longer used.
+func (w *rollingWindow) free() {
+	w.alloc.Free(cap(w.times), 8)
+	for _, c := range w.columns {
+		c.free(w.alloc)
+	}
+}
+
+// rollingRunning reports whether the aggregate of a column of type typ can be kept up to date
+// as values enter and leave the window, instead of being applied to every value of the window.
+func rollingRunning(agg execute.Aggregate, typ query.DataType) bool {
+	switch agg.(type) {
+	case *CountAgg:
+		return true
+	case *SumAgg, *MeanAgg:
+		return typ == query.TInt || typ == query.TUInt || typ == query.TFloat
+	default:
+		return false
+	}
+}
+
+// rollingColumn buffers the values of an aggregated column in the current window.
+// The window holds the values from start to the end of the buffers.
+type rollingColumn struct {
+	// idx is the index of the column in the input table and out its index in the output table.
+	idx, out int
+	typ      query.DataType
+	outType  query.DataType
+
+	// running is set when the aggregate is computed from count and the sums below.
+	running bool
+	// count is the number of non null values in the window.
+	count int64
+	// sumInt, sumUInt and sumFloat are the sum of the non null values of the window, by type.
+	sumInt   int64
+	sumUInt  uint64
+	sumFloat float64
+
+	start   int
+	nulls   []bool
+	bools   []bool
+	ints    []int64
+	uints   []uint64
+	floats  []float64
+	strings []string
+}
+
+func (c *rollingColumn) push(alloc *execute.Allocator, i int, cr query.ColReader) {
+	null := execute.IsNull(i, c.idx, cr)
+	c.nulls = alloc.AppendBools(c.nulls, null)
+	if !null {
+		c.count++
+	}
+	switch c.typ {
+	case query.TBool:
+		var v bool
+		if !null {
+			v = cr.Bools(c.idx)[i]
+		}
+		c.bools = alloc.AppendBools(c.bools, v)
+	case query.TInt:
+		var v int64
+		if !null {
+			v = cr.Ints(c.idx)[i]
+		}
+		c.ints = alloc.AppendInts(c.ints, v)
+		c.sumInt += v
+	case query.TUInt:
+		var v uint64
+		if !null {
+			v = cr.UInts(c.idx)[i]
+		}
+		c.uints = alloc.AppendUInts(c.uints, v)
+		c.sumUInt += v
+	case query.TFloat:
+		var v float64
+		if !null {
+			v = cr.Floats(c.idx)[i]
+		}
+		c.floats = alloc.AppendFloats(c.floats, v)
+		c.sumFloat += v
+	case query.TString:
+		var v string
+		if !null {
+			v = cr.Strings(c.idx)[i]
+		}
+		c.strings = alloc.AppendStrings(c.strings, v)
+	}
+}
+
+// drop removes the k oldest values of the window. Null values are buffered as zero values,
+// so they do not change the sums.
+func (c *rollingColumn) drop(k int) {
+	for i := c.start; i < c.start+k; i++ {
+		if !c.nulls[i] {
+			c.count--
+		}
+		switch c.typ {
+		case query.TInt:
+			c.sumInt -= c.ints[i]
+		case query.TUInt:
+			c.sumUInt -= c.uints[i]
+		case query.TFloat:
+			c.sumFloat -= c.floats[i]
+		case query.TString:
+			// Release the dropped strings.
+			c.strings[i] = ""
+		}
+	}
+	c.start += k
+}
+
+// compact moves the values of the window to the front of the buffers.
+func (c *rollingColumn) compact() {
+	n := copy(c.nulls, c.nulls[c.start:])
+	c.nulls = c.nulls[:n]
+	switch c.typ {
+	case query.TBool:
+		c.bools = c.bools[:copy(c.bools, c.bools[c.start:])]
+	case query.TInt:
+		c.ints = c.ints[:copy(c.ints, c.ints[c.start:])]
+	case query.TUInt:
+		c.uints = c.uints[:copy(c.uints, c.uints[c.start:])]
+	case query.TFloat:
+		c.floats = c.floats[:copy(c.floats, c.floats[c.start:])]
+		// Sum the values again so that rounding errors do not accumulate in the running sum.
+		c.sumFloat = 0
+		for _, v := range c.floats {
+			c.sumFloat += v
+		}
+	case query.TString:
+		c.strings = c.strings[:copy(c.strings, c.strings[c.start:])]
+	}
+	c.start = 0
+}
+
+func (c *rollingColumn) free(alloc *execute.Allocator) {
+	alloc.Free(cap(c.nulls), 1)
+	alloc.Free(cap(c.bools), 1)
+	alloc.Free(cap(c.ints), 8)
+	alloc.Free(cap(c.uints), 8)
+	alloc.Free(cap(c.floats), 8)
+	alloc.Free(cap(c.strings), 16)
+}
+
+// aggregate applies agg to the non null values of the window and returns the aggregate
+// and the number of values it aggregated. The aggregate is nil if agg does not support the column type.
+func (c *rollingColumn) aggregate(agg execute.Aggregate) (execute.ValueFunc, int) {
+	if c.running {
+		return c.runningAggregate(agg), int(c.count)
+	}
+	var vf execute.ValueFunc
+	switch c.typ {
+	case query.TBool:
+		if a := agg.NewBoolAgg(); a != nil {
+			vf = a
+			c.runs(func(i, j int) { a.DoBool(c.bools[i:j]) })
+		}
+	case query.TInt:
+		if a := agg.NewIntAgg(); a != nil {
+			vf = a
+			c.runs(func(i, j int) { a.DoInt(c.ints[i:j]) })
+		}
+	case query.TUInt:
+		if a := agg.NewUIntAgg(); a != nil {
+			vf = a
+			c.runs(func(i, j int) { a.DoUInt(c.uints[i:j]) })
+		}
+	case query.TFloat:
+		if a := agg.NewFloatAgg(); a != nil {
+			vf = a
+			c.runs(func(i, j int) { a.DoFloat(c.floats[i:j]) })
+		}
+	case query.TString:
+		if a := agg.NewStringAgg(); a != nil {
+			vf = a
+			c.runs(func(i, j int) { a.DoString(c.strings[i:j]) })
+		}
+	}
+	if vf == nil {
+		return nil, 0
+	}
+	return vf, int(c.count)
+}
+
+// runningAggregate returns the aggregate of the window from its count and sums.
+func (c *rollingColumn) runningAggregate(agg execute.Aggregate) execute.ValueFunc {
+	switch agg.(type) {
+	case *CountAgg:
+		return &CountAgg{count: c.count}
+	case *MeanAgg:
+		mean := &MeanAgg{count: float64(c.count)}
+		switch c.typ {
+		case query.TInt:
+			mean.sum = float64(c.sumInt)
+		case query.TUInt:
+			mean.sum = float64(c.sumUInt)
+		case query.TFloat:
+			mean.sum = c.sumFloat
+		}
+		return mean
+	}
+	switch c.typ {
+	case query.TInt:
+		return &SumIntAgg{sum: c.sumInt}
+	case query.TUInt:
+		return &SumUIntAgg{sum: c.sumUInt}
+	default:
+		return &SumFloatAgg{sum: c.sumFloat}
+	}
+}
+
+// runs calls f with the bounds of each run of non null values in the window.
+func (c *rollingColumn) runs(f func(i, j int)) {
+	start := c.start
+	for k := c.start; k < len(c.nulls); k++ {
+		if c.nulls[k] {
+			if start < k {
+				f(start, k)
+			}
+			start = k + 1
+		}
+	}
+	if start < len(c.nulls) {
+		f(start, len(c.nulls))
+	}
+}
+
+// appendRollingValue appends the value of an aggregate of count values, or null when the aggregate has no value.
+func appendRollingValue(builder execute.TableBuilder, j int, vf execute.ValueFunc, count int) {
+	if count == 0 {
+		if e, ok := vf.(execute.EmptyValueFunc); !ok || !e.HasEmptyValue() {
+			builder.AppendNil(j)
+			return
+		}
+	}
+	switch vf.Type() {
+	case query.TBool:
+		builder.AppendBool(j, vf.(execute.BoolValueFunc).ValueBool())
+	case query.TInt:
+		builder.AppendInt(j, vf.(execute.IntValueFunc).ValueInt())
+	case query.TUInt:
+		builder.AppendUInt(j, vf.(execute.UIntValueFunc).ValueUInt())
+	case query.TFloat:
+		builder.AppendFloat(j, vf.(execute.FloatValueFunc).ValueFloat())
+	case query.TString:
+		builder.AppendString(j, vf.(execute.StringValueFunc).ValueString())
+	}
+}
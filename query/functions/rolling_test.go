@@ -0,0 +1,596 @@
+package functions_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/execute"
+	"github.com/EMCECS/influx/query/execute/executetest"
+	"github.com/EMCECS/influx/query/functions"
+	"github.com/EMCECS/influx/query/querytest"
+)
+
+func TestRolling_NewQuery(t *testing.T) {
+	mean := query.Operation{
+		ID: "mean",
+		Spec: &functions.MeanOpSpec{
+			AggregateConfig: execute.DefaultAggregateConfig,
+		},
+	}
+	tests := []querytest.NewQueryTestCase{
+		{
+			Name: "rolling count",
+			Raw:  `from(bucket:"mydb") |> rolling(n:5, fn:sum, columns:["a"])`,
+			Want: &query.Spec{
+				Operations: []*query.Operation{
+					{
+						ID: "from0",
+						Spec: &functions.FromOpSpec{
+							Bucket: "mydb",
+						},
+					},
+					{
+						ID: "rolling1",
+						Spec: &functions.RollingOpSpec{
+							N:          5,
+							Columns:    []string{"a"},
+							TimeColumn: "_time",
+							Fn: query.Operation{
+								ID: "sum",
+								Spec: &functions.SumOpSpec{
+									AggregateConfig: execute.DefaultAggregateConfig,
+								},
+							},
+						},
+					},
+				},
+				Edges: []query.Edge{
+					{Parent: "from0", Child: "rolling1"},
+				},
+			},
+		},
+		{
+			Name: "rolling period with function",
+			Raw:  `from(bucket:"mydb") |> rolling(period:5m, fn:(table=<-) => table |> percentile(percentile:0.9, method:"exact_mean"))`,
+			Want: &query.Spec{
+				Operations: []*query.Operation{
+					{
+						ID: "from0",
+						Spec: &functions.FromOpSpec{
+							Bucket: "mydb",
+						},
+					},
+					{
+						ID: "rolling1",
+						Spec: &functions.RollingOpSpec{
+							Period:     query.Duration(5 * time.Minute),
+							Columns:    []string{"_value"},
+							TimeColumn: "_time",
+							Fn: query.Operation{
+								ID: "percentile",
+								Spec: &functions.PercentileOpSpec{
+									Percentile:      0.9,
+									Method:          "exact_mean",
+									AggregateConfig: execute.DefaultAggregateConfig,
+								},
+							},
+						},
+					},
+				},
+				Edges: []query.Edge{
+					{Parent: "from0", Child: "rolling1"},
+				},
+			},
+		},
+		{
+			Name: "moving average",
+			Raw:  `from(bucket:"mydb") |> movingAverage(n:3)`,
+			Want: &query.Spec{
+				Operations: []*query.Operation{
+					{
+						ID: "from0",
+						Spec: &functions.FromOpSpec{
+							Bucket: "mydb",
+						},
+					},
+					{
+						ID: "rolling1",
+						Spec: &functions.RollingOpSpec{
+							N:          3,
+							Columns:    []string{"_value"},
+							TimeColumn: "_time",
+							Fn:         mean,
+						},
+					},
+				},
+				Edges: []query.Edge{
+					{Parent: "from0", Child: "rolling1"},
+				},
+			},
+		},
+		{
+			Name: "timed moving average",
+			Raw:  `from(bucket:"mydb") |> timedMovingAverage(every:1m, period:5m, column:"x")`,
+			Want: &query.Spec{
+				Operations: []*query.Operation{
+					{
+						ID: "from0",
+						Spec: &functions.FromOpSpec{
+							Bucket: "mydb",
+						},
+					},
+					{
+						ID: "rolling1",
+						Spec: &functions.RollingOpSpec{
+							Period:     query.Duration(5 * time.Minute),
+							Every:      query.Duration(time.Minute),
+							Columns:    []string{"x"},
+							TimeColumn: "_time",
+							Fn:         mean,
+						},
+					},
+				},
+				Edges: []query.Edge{
+					{Parent: "from0", Child: "rolling1"},
+				},
+			},
+		},
+		{
+			Name:    "n and period",
+			Raw:     `from(bucket:"mydb") |> rolling(n:5, period:5m, fn:mean)`,
+			WantErr: true,
+		},
+		{
+			Name:    "every without period",
+			Raw:     `from(bucket:"mydb") |> rolling(n:5, every:5m, fn:mean)`,
+			WantErr: true,
+		},
+		{
+			Name:    "selector",
+			Raw:     `from(bucket:"mydb") |> rolling(n:5, fn:last)`,
+			WantErr: true,
+		},
+		{
+			Name:    "missing fn",
+			Raw:     `from(bucket:"mydb") |> rolling(n:5)`,
+			WantErr: true,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			querytest.NewQueryTestHelper(t, tc)
+		})
+	}
+}
+
+func TestRollingOperation_Marshaling(t *testing.T) {
+	data := []byte(`{"id":"rolling","kind":"rolling","spec":{"n":3,"columns":["_value"],"timeColumn":"_time","fn":{"id":"count","kind":"count","spec":{"columns":["_value"]}}}}`)
+	op := &query.Operation{
+		ID: "rolling",
+		Spec: &functions.RollingOpSpec{
+			N:          3,
+			Columns:    []string{"_value"},
+			TimeColumn: "_time",
+			Fn: query.Operation{
+				ID: "count",
+				Spec: &functions.CountOpSpec{
+					AggregateConfig: execute.AggregateConfig{
+						Columns: []string{"_value"},
+					},
+				},
+			},
+		},
+	}
+	querytest.OperationMarshalingTestHelper(t, data, op)
+}
+
+func TestRolling_Process(t *testing.T) {
+	s := func(n int64) execute.Time {
+		return execute.Time(n * int64(time.Second))
+	}
+	testCases := []struct {
+		name    string
+		spec    *functions.RollingProcedureSpec
+		data    []query.Table
+		want    []*executetest.Table
+		wantErr error
+	}{
+		{
+			name: "mean of n",
+			spec: &functions.RollingProcedureSpec{
+				N:       3,
+				Columns: []string{"_value"},
+				Fn:      &functions.MeanOpSpec{},
+			},
+			data: []query.Table{&executetest.Table{
+				KeyCols: []string{"t1"},
+				ColMeta: []query.ColMeta{
+					{Label: "t1", Type: query.TString},
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TFloat},
+				},
+				Data: [][]interface{}{
+					{"a", s(1), 1.0},
+					{"a", s(2), 2.0},
+					{"a", s(3), 3.0},
+					{"a", s(4), 4.0},
+					{"a", s(5), 5.0},
+				},
+			}},
+			want: []*executetest.Table{{
+				KeyCols: []string{"t1"},
+				ColMeta: []query.ColMeta{
+					{Label: "t1", Type: query.TString},
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TFloat},
+				},
+				Data: [][]interface{}{
+					{"a", s(3), 2.0},
+					{"a", s(4), 3.0},
+					{"a", s(5), 4.0},
+				},
+			}},
+		},
+		{
+			name: "sum of n with nulls",
+			spec: &functions.RollingProcedureSpec{
+				N:       2,
+				Columns: []string{"_value"},
+				Fn:      &functions.SumOpSpec{},
+			},
+			data: []query.Table{&executetest.Table{
+				ColMeta: []query.ColMeta{
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TInt},
+				},
+				Data: [][]interface{}{
+					{s(1), int64(1)},
+					{s(2), nil},
+					{s(3), nil},
+					{s(4), int64(3)},
+					{s(5), int64(4)},
+				},
+			}},
+			want: []*executetest.Table{{
+				ColMeta: []query.ColMeta{
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TInt},
+				},
+				Data: [][]interface{}{
+					{s(2), int64(1)},
+					{s(3), nil},
+					{s(4), int64(3)},
+					{s(5), int64(7)},
+				},
+			}},
+		},
+		{
+			name: "sum of n uints",
+			spec: &functions.RollingProcedureSpec{
+				N:       2,
+				Columns: []string{"_value"},
+				Fn:      &functions.SumOpSpec{},
+			},
+			data: []query.Table{&executetest.Table{
+				ColMeta: []query.ColMeta{
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TUInt},
+				},
+				Data: [][]interface{}{
+					{s(1), uint64(1)},
+					{s(2), uint64(2)},
+					{s(3), uint64(3)},
+					{s(4), uint64(4)},
+					{s(5), uint64(5)},
+					{s(6), uint64(6)},
+				},
+			}},
+			want: []*executetest.Table{{
+				ColMeta: []query.ColMeta{
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TUInt},
+				},
+				Data: [][]interface{}{
+					{s(2), uint64(3)},
+					{s(3), uint64(5)},
+					{s(4), uint64(7)},
+					{s(5), uint64(9)},
+					{s(6), uint64(11)},
+				},
+			}},
+		},
+		{
+			name: "count of n strings with nulls",
+			spec: &functions.RollingProcedureSpec{
+				N:       2,
+				Columns: []string{"_value"},
+				Fn:      &functions.CountOpSpec{},
+			},
+			data: []query.Table{&executetest.Table{
+				ColMeta: []query.ColMeta{
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TString},
+				},
+				Data: [][]interface{}{
+					{s(1), "a"},
+					{s(2), nil},
+					{s(3), "b"},
+					{s(4), "c"},
+					{s(5), nil},
+				},
+			}},
+			want: []*executetest.Table{{
+				ColMeta: []query.ColMeta{
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TInt},
+				},
+				Data: [][]interface{}{
+					{s(2), int64(1)},
+					{s(3), int64(1)},
+					{s(4), int64(2)},
+					{s(5), int64(1)},
+				},
+			}},
+		},
+		{
+			name: "spread of n",
+			spec: &functions.RollingProcedureSpec{
+				N:       2,
+				Columns: []string{"_value"},
+				Fn:      &functions.SpreadOpSpec{},
+			},
+			data: []query.Table{&executetest.Table{
+				ColMeta: []query.ColMeta{
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TFloat},
+				},
+				Data: [][]interface{}{
+					{s(1), 1.0},
+					{s(2), 4.0},
+					{s(3), 2.0},
+					{s(4), 8.0},
+					{s(5), 3.0},
+				},
+			}},
+			want: []*executetest.Table{{
+				ColMeta: []query.ColMeta{
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TFloat},
+				},
+				Data: [][]interface{}{
+					{s(2), 3.0},
+					{s(3), 2.0},
+					{s(4), 6.0},
+					{s(5), 5.0},
+				},
+			}},
+		},
+		{
+			name: "count over period",
+			spec: &functions.RollingProcedureSpec{
+				Period:     query.Duration(3 * time.Second),
+				Columns:    []string{"_value"},
+				TimeColumn: "_time",
+				Fn:         &functions.CountOpSpec{},
+			},
+			data: []query.Table{&executetest.Table{
+				ColMeta: []query.ColMeta{
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TFloat},
+				},
+				Data: [][]interface{}{
+					{s(0), 1.0},
+					{s(1), 1.0},
+					{s(2), 1.0},
+					{s(4), 1.0},
+					{s(5), 1.0},
+				},
+			}},
+			want: []*executetest.Table{{
+				ColMeta: []query.ColMeta{
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TInt},
+				},
+				Data: [][]interface{}{
+					{s(0), int64(1)},
+					{s(1), int64(2)},
+					{s(2), int64(3)},
+					{s(4), int64(2)},
+					{s(5), int64(2)},
+				},
+			}},
+		},
+		{
+			name: "mean over period every",
+			spec: &functions.RollingProcedureSpec{
+				Period:     query.Duration(4 * time.Second),
+				Every:      query.Duration(2 * time.Second),
+				Columns:    []string{"_value"},
+				TimeColumn: "_time",
+				Fn:         &functions.MeanOpSpec{},
+			},
+			data: []query.Table{&executetest.Table{
+				KeyCols: []string{"t1"},
+				ColMeta: []query.ColMeta{
+					{Label: "t1", Type: query.TString},
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TInt},
+				},
+				Data: [][]interface{}{
+					{"a", s(1), int64(1)},
+					{"a", s(2), int64(2)},
+					{"a", s(3), int64(3)},
+					{"a", s(4), int64(4)},
+					{"a", s(5), int64(5)},
+					{"a", s(6), int64(6)},
+				},
+			}},
+			want: []*executetest.Table{{
+				KeyCols: []string{"t1"},
+				ColMeta: []query.ColMeta{
+					{Label: "t1", Type: query.TString},
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TFloat},
+				},
+				Data: [][]interface{}{
+					{"a", s(2), 1.0},
+					{"a", s(4), 2.0},
+					{"a", s(6), 3.5},
+					{"a", s(8), 5.0},
+					{"a", s(10), 6.0},
+				},
+			}},
+		},
+		{
+			name: "empty windows",
+			spec: &functions.RollingProcedureSpec{
+				Period:     query.Duration(time.Second),
+				Every:      query.Duration(time.Second),
+				Columns:    []string{"_value"},
+				TimeColumn: "_time",
+				Fn:         &functions.MeanOpSpec{},
+			},
+			data: []query.Table{&executetest.Table{
+				ColMeta: []query.ColMeta{
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TFloat},
+				},
+				Data: [][]interface{}{
+					{s(0), 1.0},
+					{s(2), 3.0},
+				},
+			}},
+			want: []*executetest.Table{{
+				ColMeta: []query.ColMeta{
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TFloat},
+				},
+				Data: [][]interface{}{
+					{s(1), 1.0},
+					{s(2), nil},
+					{s(3), 3.0},
+				},
+			}},
+		},
+		{
+			name: "unsorted",
+			spec: &functions.RollingProcedureSpec{
+				Period:     query.Duration(3 * time.Second),
+				Columns:    []string{"_value"},
+				TimeColumn: "_time",
+				Fn:         &functions.SumOpSpec{},
+			},
+			data: []query.Table{&executetest.Table{
+				ColMeta: []query.ColMeta{
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TFloat},
+				},
+				Data: [][]interface{}{
+					{s(2), 1.0},
+					{s(1), 1.0},
+				},
+			}},
+			want: []*executetest.Table{{
+				ColMeta: []query.ColMeta{
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TFloat},
+				},
+				Data: [][]interface{}{
+					{s(2), 1.0},
+				},
+			}},
+			wantErr: errors.New(`rolling requires the table to be sorted by "_time"`),
+		},
+		{
+			name: "unsupported type",
+			spec: &functions.RollingProcedureSpec{
+				N:       3,
+				Columns: []string{"_value"},
+				Fn:      &functions.MeanOpSpec{},
+			},
+			data: []query.Table{&executetest.Table{
+				ColMeta: []query.ColMeta{
+					{Label: "_time", Type: query.TTime},
+					{Label: "_value", Type: query.TString},
+				},
+				Data: [][]interface{}{
+					{s(1), "a"},
+				},
+			}},
+			want:    []*executetest.Table(nil),
+			wantErr: errors.New("unsupported aggregate column type string"),
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			executetest.ProcessTestHelper(
+				t,
+				tc.data,
+				tc.want,
+				tc.wantErr,
+				func(d execute.Dataset, c execute.TableBuilderCache) execute.Transformation {
+					tx, err := functions.NewRollingTransformation(d, c, executetest.UnlimitedAllocator, tc.spec)
+					if err != nil {
+						t.Fatal(err)
+					}
+					return tx
+				},
+			)
+		})
+	}
+}
+
+func TestRolling_Allocator(t *testing.T) {
+	alloc := &execute.Allocator{Limit: math.MaxInt64}
+	spec := &functions.RollingProcedureSpec{
+		N:       2,
+		Columns: []string{"_value"},
+		Fn:      &functions.MeanOpSpec{},
+	}
+	data := []query.Table{&executetest.Table{
+		ColMeta: []query.ColMeta{
+			{Label: "_value", Type: query.TFloat},
+		},
+		Data: [][]interface{}{
+			{1.0},
+			{2.0},
+			{3.0},
+			{4.0},
+		},
+	}}
+	want := []*executetest.Table{{
+		ColMeta: []query.ColMeta{
+			{Label: "_value", Type: query.TFloat},
+		},
+		Data: [][]interface{}{
+			{1.5},
+			{2.5},
+			{3.5},
+		},
+	}}
+	executetest.ProcessTestHelper(
+		t,
+		data,
+		want,
+		nil,
+		func(d execute.Dataset, c execute.TableBuilderCache) execute.Transformation {
+			tx, err := functions.NewRollingTransformation(d, c, alloc, spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return tx
+		},
+	)
+	if alloc.Max() == 0 {
+		t.Error("expected the window to be allocated")
+	}
+	if got := alloc.Allocated(); got != 0 {
+		t.Errorf("expected the window to be freed, %d bytes are still allocated", got)
+	}
+}
@@ -102,6 +102,9 @@ func createSortTransformation(id execute.DatasetID, mode execute.AccumulationMod
 		return nil, nil, fmt.Errorf("invalid spec type %T", spec)
 	}
 	cache := execute.NewTableBuilderCache(a.Allocator())
+	// Sorted runs are spilled near the memory quota, and merged back when the tables are read.
+	cache.SetSpiller(a.Spiller())
+	cache.SetSortOrder(s.Cols, s.Desc)
 	d := execute.NewDataset(id, mode, cache)
 	t := NewSortTransformation(d, cache, s)
 	return t, d, nil
@@ -142,7 +145,14 @@ func (t *sortTransformation) Process(id execute.DatasetID, tbl query.Table) erro
 		return fmt.Errorf("sort found duplicate table with key: %v", tbl.Key())
 	}
 	execute.AddTableCols(tbl, builder)
-	execute.AppendTable(tbl, builder)
+	if len(tbl.Cols()) > 0 {
+		if err := tbl.Do(func(cr query.ColReader) error {
+			execute.AppendCols(cr, builder)
+			return execute.SpillIfNeeded(t.cache)
+		}); err != nil {
+			return err
+		}
+	}
 
 	builder.Sort(t.cols, t.desc)
 	return nil
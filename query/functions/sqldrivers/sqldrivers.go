@@ -0,0 +1,9 @@
+// Package sqldrivers registers the SQL drivers used by fromSQL and toSQL with database/sql.
+// Programs opt into the drivers by importing the package for its side effects.
+// The sqlite3 driver requires cgo and is only registered when cgo is enabled.
+package sqldrivers
+
+import (
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+)
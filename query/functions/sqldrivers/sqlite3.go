@@ -0,0 +1,5 @@
+// +build cgo
+
+package sqldrivers
+
+import _ "github.com/mattn/go-sqlite3"
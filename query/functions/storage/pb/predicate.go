@@ -126,6 +126,9 @@ func toStoragePredicate(n semantic.Expression, objectName string) (*ostorage.Nod
 			},
 		}, nil
 	case *semantic.DurationLiteral:
+		if n.Months != 0 {
+			return nil, errors.New("calendar duration literals not supported in storage predicates")
+		}
 		return nil, errors.New("duration literals not supported in storage predicates")
 	case *semantic.DateTimeLiteral:
 		return nil, errors.New("time literals not supported in storage predicates")
@@ -0,0 +1,51 @@
+package pb_test
+
+import (
+	"testing"
+
+	"github.com/EMCECS/influx/query/ast"
+	"github.com/EMCECS/influx/query/functions/storage/pb"
+	"github.com/EMCECS/influx/query/semantic"
+)
+
+func TestToStoragePredicate_Duration(t *testing.T) {
+	testCases := []struct {
+		name string
+		lit  *semantic.DurationLiteral
+		want string
+	}{
+		{
+			name: "fixed",
+			lit:  &semantic.DurationLiteral{Value: 1},
+			want: "right hand side: duration literals not supported in storage predicates",
+		},
+		{
+			name: "calendar",
+			lit:  &semantic.DurationLiteral{Months: 1},
+			want: "right hand side: calendar duration literals not supported in storage predicates",
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			f := &semantic.FunctionExpression{
+				Params: []*semantic.FunctionParam{{Key: &semantic.Identifier{Name: "r"}}},
+				Body: &semantic.BinaryExpression{
+					Operator: ast.EqualOperator,
+					Left: &semantic.MemberExpression{
+						Object:   &semantic.IdentifierExpression{Name: "r"},
+						Property: "_value",
+					},
+					Right: tc.lit,
+				},
+			}
+			_, err := pb.ToStoragePredicate(f)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if got := err.Error(); got != tc.want {
+				t.Fatalf("unexpected error: got %q want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -552,6 +552,11 @@ func (t *table) Times(j int) []execute.Time {
 	return t.colBufs[j].([]execute.Time)
 }
 
+// Nulls reports no nulls, the storage engine does not store missing values.
+func (t *table) Nulls(j int) []bool {
+	return nil
+}
+
 // readTags populates b.tags with the provided tags
 func (t *table) readTags(tags []ostorage.Tag) {
 	for j := range t.tags {
@@ -5,6 +5,7 @@ import (
 	"context"
 	"log"
 	"math"
+	"time"
 
 	"github.com/EMCECS/influx"
 	"github.com/EMCECS/influx/query"
@@ -100,28 +101,84 @@ func (s *source) Run(ctx context.Context) {
 }
 
 func (s *source) run(ctx context.Context) error {
+	if live := execute.LiveFromContext(ctx); live != nil {
+		return s.runLive(ctx, live)
+	}
 	//TODO(nathanielc): Pass through context to actual network I/O.
 	for tables, mark, ok := s.next(ctx); ok; tables, mark, ok = s.next(ctx) {
-		err := tables.Do(func(tbl query.Table) error {
-			for _, t := range s.ts {
-				if err := t.Process(s.id, tbl); err != nil {
-					return err
-				}
-				//TODO(nathanielc): Also add mechanism to send UpdateProcessingTime calls, when no data is arriving.
-				// This is probably not needed for this source, but other sources should do so.
-				if err := t.UpdateProcessingTime(s.id, execute.Now()); err != nil {
-					return err
-				}
+		if err := s.process(tables, mark); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runLive reads the data up to now, then reads the data written since the last read
+// each time a write is notified or the live interval elapses, until the stop of the bounds.
+// The watermark is advanced to the stop of each read.
+func (s *source) runLive(ctx context.Context, live *execute.Live) error {
+	var writes <-chan struct{}
+	if live.Writes != nil {
+		ch, unsubscribe := live.Writes.Subscribe()
+		defer unsubscribe()
+		writes = ch
+	}
+	interval := live.Interval
+	if interval <= 0 {
+		interval = execute.DefaultLiveInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	start := s.bounds.Start
+	for {
+		stop := execute.Now()
+		if stop > s.bounds.Stop {
+			stop = s.bounds.Stop
+		}
+		if stop > start {
+			tables, err := s.reader.Read(ctx, s.readSpec, start, stop)
+			if err != nil {
+				return err
+			}
+			if err := s.process(tables, stop); err != nil {
+				return err
 			}
+			start = stop
+		}
+		if start >= s.bounds.Stop {
 			return nil
-		})
-		if err != nil {
-			return err
 		}
+		select {
+		case <-writes:
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// process sends the tables to the transformations, then advances their watermark to mark.
+func (s *source) process(tables query.TableIterator, mark execute.Time) error {
+	err := tables.Do(func(tbl query.Table) error {
 		for _, t := range s.ts {
-			if err := t.UpdateWatermark(s.id, mark); err != nil {
+			if err := t.Process(s.id, tbl); err != nil {
 				return err
 			}
+			//TODO(nathanielc): Also add mechanism to send UpdateProcessingTime calls, when no data is arriving.
+			// This is probably not needed for this source, but other sources should do so.
+			if err := t.UpdateProcessingTime(s.id, execute.Now()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, t := range s.ts {
+		if err := t.UpdateWatermark(s.id, mark); err != nil {
+			return err
 		}
 	}
 	return nil
@@ -0,0 +1,204 @@
+package functions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/execute"
+	"github.com/EMCECS/influx/query/interpreter"
+	"github.com/EMCECS/influx/query/semantic"
+	"github.com/EMCECS/influx/query/values"
+)
+
+// tableFind, getColumn and getRecord extract values from tables while a script is compiled,
+// so that the result of one query can be a parameter of another.
+// For example, the mean of a series used as the threshold of a filter:
+//
+//	m = from(bucket:"telegraf") |> range(start:-1h) |> mean() |> tableFind(fn:(key) => true) |> getRecord(idx:0)
+//	from(bucket:"telegraf") |> range(start:-1h) |> filter(fn:(r) => r._value > m._value)
+func init() {
+	tableFindSignature := semantic.FunctionSignature{
+		Params: map[string]semantic.Type{
+			query.TableParameter: query.TableObjectType,
+			"fn":                 semantic.Function,
+		},
+		ReturnType:   semantic.EmptyObject,
+		PipeArgument: query.TableParameter,
+	}
+	query.RegisterContextFunction("tableFind", tableFind, tableFindSignature)
+
+	getColumnSignature := semantic.FunctionSignature{
+		Params: map[string]semantic.Type{
+			query.TableParameter: semantic.EmptyObject,
+			"column":             semantic.String,
+		},
+		ReturnType:   semantic.NewArrayType(semantic.Invalid),
+		PipeArgument: query.TableParameter,
+	}
+	query.RegisterBuiltInValue("getColumn", values.NewFunction(
+		"getColumn",
+		semantic.NewFunctionType(getColumnSignature),
+		func(args values.Object) (values.Value, error) {
+			return interpreter.DoFunctionCall(getColumn, args)
+		},
+		false,
+	))
+
+	getRecordSignature := semantic.FunctionSignature{
+		Params: map[string]semantic.Type{
+			query.TableParameter: semantic.EmptyObject,
+			"idx":                semantic.Int,
+		},
+		ReturnType:   semantic.EmptyObject,
+		PipeArgument: query.TableParameter,
+	}
+	query.RegisterBuiltInValue("getRecord", values.NewFunction(
+		"getRecord",
+		semantic.NewFunctionType(getRecordSignature),
+		func(args values.Object) (values.Value, error) {
+			return interpreter.DoFunctionCall(getRecord, args)
+		},
+		false,
+	))
+}
+
+// keyObject is the group key of a tableValue, the alias names the embedded field apart from the Object method.
+type keyObject = values.Object
+
+// tableValue is a table read during a compilation.
+// As an object, its properties are the columns of its group key.
+type tableValue struct {
+	keyObject
+	cols []query.ColMeta
+	// rows are the values of the records, null values are values.Null.
+	rows [][]values.Value
+}
+
+func (t *tableValue) Object() values.Object {
+	return t
+}
+
+// tableFind executes the table object piped into it,
+// and returns the first table whose group key satisfies fn.
+func tableFind(ctx context.Context, args query.Arguments) (values.Value, error) {
+	obj, err := args.GetRequiredObject(query.TableParameter)
+	if err != nil {
+		return nil, err
+	}
+	to, ok := obj.(*query.TableObject)
+	if !ok {
+		return nil, fmt.Errorf("tableFind expects a table stream, got %v", obj.Type())
+	}
+	fn, err := args.GetRequiredFunction("fn")
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	result, err := query.ExecuteTableObject(ctx, to)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every table is read so that the execution completes.
+	var found *tableValue
+	err = result.Tables().Do(func(tbl query.Table) error {
+		if found != nil {
+			return nil
+		}
+		key := tbl.Key()
+		keyObj := values.NewObject()
+		for j, c := range key.Cols() {
+			keyObj.Set(c.Label, key.Value(j))
+		}
+		fnArgs := values.NewObject()
+		fnArgs.Set("key", keyObj)
+		match, err := fn.Call(fnArgs)
+		if err != nil {
+			return err
+		}
+		if match.Type() != semantic.Bool {
+			return fmt.Errorf("tableFind fn must return a bool, got %v", match.Type())
+		}
+		if !match.Bool() {
+			return nil
+		}
+		found = &tableValue{
+			keyObject: keyObj,
+			cols:      tbl.Cols(),
+		}
+		return tbl.Do(func(cr query.ColReader) error {
+			for i := 0; i < cr.Len(); i++ {
+				row := make([]values.Value, len(found.cols))
+				for j := range found.cols {
+					row[j] = execute.ValueForRow(i, j, cr)
+				}
+				found.rows = append(found.rows, row)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, errors.New("no table found")
+	}
+	return found, nil
+}
+
+func tableArgument(args interpreter.Arguments) (*tableValue, error) {
+	obj, err := args.GetRequiredObject(query.TableParameter)
+	if err != nil {
+		return nil, err
+	}
+	t, ok := obj.(*tableValue)
+	if !ok {
+		return nil, errors.New("expected a table extracted with tableFind")
+	}
+	return t, nil
+}
+
+// getColumn returns the values of a column of a table as an array.
+func getColumn(args interpreter.Arguments) (values.Value, error) {
+	t, err := tableArgument(args)
+	if err != nil {
+		return nil, err
+	}
+	label, err := args.GetRequiredString("column")
+	if err != nil {
+		return nil, err
+	}
+	j := execute.ColIdx(label, t.cols)
+	if j < 0 {
+		return nil, fmt.Errorf("column %q does not exist", label)
+	}
+	elements := make([]values.Value, len(t.rows))
+	for i, row := range t.rows {
+		elements[i] = row[j]
+	}
+	return values.NewArrayWithBacking(execute.ConvertToKind(t.cols[j].Type), elements), nil
+}
+
+// getRecord returns a record of a table as an object.
+func getRecord(args interpreter.Arguments) (values.Value, error) {
+	t, err := tableArgument(args)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := args.GetRequiredInt("idx")
+	if err != nil {
+		return nil, err
+	}
+	if idx < 0 || idx >= int64(len(t.rows)) {
+		return nil, fmt.Errorf("index %d out of bounds, the table has %d records", idx, len(t.rows))
+	}
+	record := values.NewObject()
+	for j, c := range t.cols {
+		record.Set(c.Label, t.rows[idx][j])
+	}
+	return record, nil
+}
@@ -0,0 +1,183 @@
+package functions_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/ast"
+	"github.com/EMCECS/influx/query/execute/executetest"
+	"github.com/EMCECS/influx/query/functions"
+	"github.com/EMCECS/influx/query/querytest"
+	"github.com/EMCECS/influx/query/semantic"
+)
+
+// resultSpecExecutor returns the same tables for every spec it executes.
+type resultSpecExecutor struct {
+	tables []*executetest.Table
+}
+
+func (e resultSpecExecutor) ExecuteSpec(ctx context.Context, spec *query.Spec) (map[string]query.Result, error) {
+	return map[string]query.Result{
+		"_result": executetest.NewResult(e.tables),
+	}, nil
+}
+
+func TestTableFind_NewQuery(t *testing.T) {
+	executor := resultSpecExecutor{
+		tables: []*executetest.Table{
+			{
+				KeyCols: []string{"host"},
+				ColMeta: []query.ColMeta{
+					{Label: "host", Type: query.TString},
+					{Label: "_value", Type: query.TFloat},
+				},
+				Data: [][]interface{}{
+					{"a", 1.0},
+				},
+			},
+			{
+				KeyCols: []string{"host"},
+				ColMeta: []query.ColMeta{
+					{Label: "host", Type: query.TString},
+					{Label: "_value", Type: query.TFloat},
+				},
+				Data: [][]interface{}{
+					{"b", 2.0},
+					{"b", nil},
+					{"b", 4.0},
+				},
+			},
+		},
+	}
+	// filterSpec returns the spec of a filter on values greater than v.
+	filterSpec := func(v semantic.Expression) *query.Spec {
+		return &query.Spec{
+			Operations: []*query.Operation{
+				{
+					ID: "from0",
+					Spec: &functions.FromOpSpec{
+						Bucket: "mydb",
+					},
+				},
+				{
+					ID: "range1",
+					Spec: &functions.RangeOpSpec{
+						Start:    query.Time{Relative: -1 * time.Hour, IsRelative: true},
+						Stop:     query.Now,
+						TimeCol:  "_time",
+						StartCol: "_start",
+						StopCol:  "_stop",
+					},
+				},
+				{
+					ID: "filter2",
+					Spec: &functions.FilterOpSpec{
+						Fn: &semantic.FunctionExpression{
+							Params: []*semantic.FunctionParam{{Key: &semantic.Identifier{Name: "r"}}},
+							Body: &semantic.BinaryExpression{
+								Operator: ast.GreaterThanOperator,
+								Left: &semantic.MemberExpression{
+									Object:   &semantic.IdentifierExpression{Name: "r"},
+									Property: "_value",
+								},
+								Right: v,
+							},
+						},
+					},
+				},
+			},
+			Edges: []query.Edge{
+				{Parent: "from0", Child: "range1"},
+				{Parent: "range1", Child: "filter2"},
+			},
+		}
+	}
+	tests := []querytest.NewQueryTestCase{
+		{
+			Name: "getRecord",
+			Raw: `m = from(bucket:"mydb") |> range(start:-1h) |> mean() |> tableFind(fn:(key) => key.host == "b") |> getRecord(idx:0)
+from(bucket:"mydb") |> range(start:-1h) |> filter(fn:(r) => r._value > m._value)`,
+			Want:         filterSpec(&semantic.FloatLiteral{Value: 2}),
+			SpecExecutor: executor,
+		},
+		{
+			Name: "getColumn",
+			Raw: `vs = from(bucket:"mydb") |> range(start:-1h) |> tableFind(fn:(key) => key.host == "b") |> getColumn(column:"_value")
+from(bucket:"mydb") |> range(start:-1h) |> filter(fn:(r) => r._value > vs[2])`,
+			Want:         filterSpec(&semantic.FloatLiteral{Value: 4}),
+			SpecExecutor: executor,
+		},
+		{
+			Name: "group key of table",
+			Raw: `t = from(bucket:"mydb") |> range(start:-1h) |> tableFind(fn:(key) => key.host != "b")
+from(bucket:"mydb") |> range(start:-1h) |> filter(fn:(r) => r.host == t.host)`,
+			SpecExecutor: executor,
+		},
+		{
+			Name:         "no table found",
+			Raw:          `from(bucket:"mydb") |> range(start:-1h) |> tableFind(fn:(key) => key.host == "c")`,
+			WantErr:      true,
+			SpecExecutor: executor,
+		},
+		{
+			Name:         "record out of bounds",
+			Raw:          `from(bucket:"mydb") |> range(start:-1h) |> tableFind(fn:(key) => true) |> getRecord(idx:1)`,
+			WantErr:      true,
+			SpecExecutor: executor,
+		},
+		{
+			Name:         "missing column",
+			Raw:          `from(bucket:"mydb") |> range(start:-1h) |> tableFind(fn:(key) => true) |> getColumn(column:"x")`,
+			WantErr:      true,
+			SpecExecutor: executor,
+		},
+		{
+			Name:    "without spec executor",
+			Raw:     `from(bucket:"mydb") |> range(start:-1h) |> tableFind(fn:(key) => true)`,
+			WantErr: true,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			querytest.NewQueryTestHelper(t, tc)
+		})
+	}
+}
+
+func TestTableFind_Now(t *testing.T) {
+	var got *query.Spec
+	executor := specExecutorFunc(func(ctx context.Context, spec *query.Spec) (map[string]query.Result, error) {
+		got = spec
+		return map[string]query.Result{
+			"_result": executetest.NewResult([]*executetest.Table{{
+				ColMeta: []query.ColMeta{{Label: "_value", Type: query.TInt}},
+				Data:    [][]interface{}{{int64(1)}},
+			}}),
+		}, nil
+	})
+	now := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	ctx := query.ContextWithSpecExecutor(context.Background(), executor)
+	_, err := query.Compile(ctx, `from(bucket:"mydb") |> range(start:-1h) |> tableFind(fn:(key) => true)`, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil {
+		t.Fatal("spec was not executed")
+	}
+	if !got.Now.Equal(now) {
+		t.Errorf("unexpected now: want %v, got %v", now, got.Now)
+	}
+	if want := 2; len(got.Operations) != want {
+		t.Errorf("unexpected number of operations: want %d, got %d", want, len(got.Operations))
+	}
+}
+
+type specExecutorFunc func(ctx context.Context, spec *query.Spec) (map[string]query.Result, error)
+
+func (f specExecutorFunc) ExecuteSpec(ctx context.Context, spec *query.Spec) (map[string]query.Result, error) {
+	return f(ctx, spec)
+}
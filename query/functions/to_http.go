@@ -241,6 +241,11 @@ func (o *ToHTTPProcedureSpec) Copy() plan.ProcedureSpec {
 	return res
 }
 
+// SideEffect reports that the tables are sent to the URL.
+func (o *ToHTTPProcedureSpec) SideEffect() bool {
+	return true
+}
+
 func newToHTTPProcedure(qs query.OperationSpec, a plan.Administration) (plan.ProcedureSpec, error) {
 	spec, ok := qs.(*ToHTTPOpSpec)
 	if !ok && spec != nil {
@@ -205,6 +205,12 @@ func (o *ToKafkaProcedureSpec) Copy() plan.ProcedureSpec {
 	}
 	return res
 }
+
+// SideEffect reports that the tables are written to the Kafka topic.
+func (o *ToKafkaProcedureSpec) SideEffect() bool {
+	return true
+}
+
 func newToKafkaProcedure(qs query.OperationSpec, a plan.Administration) (plan.ProcedureSpec, error) {
 	spec, ok := qs.(*ToKafkaOpSpec)
 	if !ok && spec != nil {
@@ -0,0 +1,307 @@
+package functions
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/execute"
+	"github.com/EMCECS/influx/query/plan"
+	"github.com/EMCECS/influx/query/semantic"
+	"github.com/pkg/errors"
+)
+
+const (
+	ToSQLKind = "toSQL"
+	// DefaultToSQLBatchSize is the default number of records inserted by each statement.
+	DefaultToSQLBatchSize = 1000
+)
+
+// ToSQLOpSpec writes the records of the tables into a SQL table.
+// The SQL table is named by TableName since table is the name of the piped argument.
+type ToSQLOpSpec struct {
+	DriverName     string `json:"driverName"`
+	DataSourceName string `json:"dataSourceName"`
+	TableName      string `json:"tableName"`
+	BatchSize      int    `json:"batchSize"`
+}
+
+var toSQLSignature = query.DefaultFunctionSignature()
+
+func init() {
+	toSQLSignature.Params["driverName"] = semantic.String
+	toSQLSignature.Params["dataSourceName"] = semantic.String
+	toSQLSignature.Params["tableName"] = semantic.String
+	toSQLSignature.Params["batchSize"] = semantic.Int
+
+	query.RegisterFunctionWithSideEffect(ToSQLKind, createToSQLOpSpec, toSQLSignature)
+	query.RegisterOpSpec(ToSQLKind, newToSQLOp)
+	plan.RegisterProcedureSpec(ToSQLKind, newToSQLProcedure, ToSQLKind)
+	execute.RegisterTransformation(ToSQLKind, createToSQLTransformation)
+}
+
+func createToSQLOpSpec(args query.Arguments, a *query.Administration) (query.OperationSpec, error) {
+	if err := a.AddParentFromArgs(args); err != nil {
+		return nil, err
+	}
+	spec := new(ToSQLOpSpec)
+	var err error
+	if spec.DriverName, err = args.GetRequiredString("driverName"); err != nil {
+		return nil, err
+	}
+	if err := validateSQLDriver(spec.DriverName); err != nil {
+		return nil, err
+	}
+	if spec.DataSourceName, err = args.GetRequiredString("dataSourceName"); err != nil {
+		return nil, err
+	}
+	if spec.TableName, err = args.GetRequiredString("tableName"); err != nil {
+		return nil, err
+	}
+	spec.BatchSize = DefaultToSQLBatchSize
+	if batchSize, ok, err := args.GetInt("batchSize"); err != nil {
+		return nil, err
+	} else if ok {
+		if batchSize <= 0 {
+			return nil, fmt.Errorf("batchSize must be positive, got %d", batchSize)
+		}
+		spec.BatchSize = int(batchSize)
+	}
+	return spec, nil
+}
+
+func newToSQLOp() query.OperationSpec {
+	return new(ToSQLOpSpec)
+}
+
+func (s *ToSQLOpSpec) Kind() query.OperationKind {
+	return ToSQLKind
+}
+
+type ToSQLProcedureSpec struct {
+	DriverName     string
+	DataSourceName string
+	TableName      string
+	BatchSize      int
+}
+
+func newToSQLProcedure(qs query.OperationSpec, pa plan.Administration) (plan.ProcedureSpec, error) {
+	spec, ok := qs.(*ToSQLOpSpec)
+	if !ok {
+		return nil, fmt.Errorf("invalid spec type %T", qs)
+	}
+	return &ToSQLProcedureSpec{
+		DriverName:     spec.DriverName,
+		DataSourceName: spec.DataSourceName,
+		TableName:      spec.TableName,
+		BatchSize:      spec.BatchSize,
+	}, nil
+}
+
+func (s *ToSQLProcedureSpec) Kind() plan.ProcedureKind {
+	return ToSQLKind
+}
+
+func (s *ToSQLProcedureSpec) Copy() plan.ProcedureSpec {
+	ns := new(ToSQLProcedureSpec)
+	*ns = *s
+	return ns
+}
+
+// SideEffect reports that the tables are inserted into the SQL table.
+func (s *ToSQLProcedureSpec) SideEffect() bool {
+	return true
+}
+
+func createToSQLTransformation(id execute.DatasetID, mode execute.AccumulationMode, spec plan.ProcedureSpec, a execute.Administration) (execute.Transformation, execute.Dataset, error) {
+	s, ok := spec.(*ToSQLProcedureSpec)
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid spec type %T", spec)
+	}
+	if err := allowSQLDataSource(a, ToSQLKind, s.DriverName, s.DataSourceName); err != nil {
+		return nil, nil, err
+	}
+	cache := execute.NewTableBuilderCache(a.Allocator())
+	d := execute.NewDataset(id, mode, cache)
+	t, err := NewToSQLTransformation(d, cache, s)
+	if err != nil {
+		return nil, nil, err
+	}
+	return t, d, nil
+}
+
+// ToSQLTransformation inserts the records of each table into a SQL table in a transaction,
+// and passes the tables through unchanged.
+type ToSQLTransformation struct {
+	d     execute.Dataset
+	cache execute.TableBuilderCache
+	spec  *ToSQLProcedureSpec
+	db    *sql.DB
+}
+
+// NewToSQLTransformation opens the database of the spec, which is closed when the transformation finishes.
+func NewToSQLTransformation(d execute.Dataset, cache execute.TableBuilderCache, spec *ToSQLProcedureSpec) (*ToSQLTransformation, error) {
+	db, err := sql.Open(spec.DriverName, spec.DataSourceName)
+	if err != nil {
+		return nil, err
+	}
+	return &ToSQLTransformation{
+		d:     d,
+		cache: cache,
+		spec:  spec,
+		db:    db,
+	}, nil
+}
+
+func (t *ToSQLTransformation) RetractTable(id execute.DatasetID, key query.GroupKey) error {
+	return t.d.RetractTable(key)
+}
+
+func (t *ToSQLTransformation) Process(id execute.DatasetID, tbl query.Table) (err error) {
+	builder, created := t.cache.TableBuilder(tbl.Key())
+	if !created {
+		return fmt.Errorf("toSQL found duplicate table with key: %v", tbl.Key())
+	}
+	execute.AddTableCols(tbl, builder)
+
+	cols := tbl.Cols()
+	if len(cols) == 0 {
+		return fmt.Errorf("toSQL cannot insert a table with no columns: %v", tbl.Key())
+	}
+	placeholder := sqlPlaceholder(t.spec.DriverName)
+	quote := sqlIdentifierQuote(t.spec.DriverName)
+	var prefix strings.Builder
+	prefix.WriteString("INSERT INTO ")
+	prefix.WriteString(quote(t.spec.TableName))
+	prefix.WriteString(" (")
+	for j, c := range cols {
+		if j > 0 {
+			prefix.WriteString(", ")
+		}
+		prefix.WriteString(quote(c.Label))
+	}
+	prefix.WriteString(") VALUES ")
+
+	tx, err := t.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "failed to begin sql transaction")
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	args := make([]interface{}, 0, t.spec.BatchSize*len(cols))
+	flush := func() error {
+		n := len(args) / len(cols)
+		if n == 0 {
+			return nil
+		}
+		var stmt strings.Builder
+		stmt.WriteString(prefix.String())
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				stmt.WriteString(", ")
+			}
+			stmt.WriteByte('(')
+			for j := range cols {
+				if j > 0 {
+					stmt.WriteString(", ")
+				}
+				stmt.WriteString(placeholder(i*len(cols) + j + 1))
+			}
+			stmt.WriteByte(')')
+		}
+		if _, err := tx.Exec(stmt.String(), args...); err != nil {
+			return errors.Wrap(err, "failed to insert records")
+		}
+		args = args[:0]
+		return nil
+	}
+
+	err = tbl.Do(func(cr query.ColReader) error {
+		execute.AppendCols(cr, builder)
+		for i := 0; i < cr.Len(); i++ {
+			for j, c := range cols {
+				args = append(args, sqlArg(i, j, c.Type, cr))
+			}
+			if len(args) == t.spec.BatchSize*len(cols) {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return flush()
+}
+
+func (t *ToSQLTransformation) UpdateWatermark(id execute.DatasetID, pt execute.Time) error {
+	return t.d.UpdateWatermark(pt)
+}
+func (t *ToSQLTransformation) UpdateProcessingTime(id execute.DatasetID, pt execute.Time) error {
+	return t.d.UpdateProcessingTime(pt)
+}
+func (t *ToSQLTransformation) Finish(id execute.DatasetID, err error) {
+	if cerr := t.db.Close(); err == nil && cerr != nil {
+		err = cerr
+	}
+	t.d.Finish(err)
+}
+
+// sqlArg returns the value of the row i of the column j as an argument of a SQL statement.
+func sqlArg(i, j int, typ query.DataType, cr query.ColReader) interface{} {
+	if execute.IsNull(i, j, cr) {
+		return nil
+	}
+	switch typ {
+	case query.TBool:
+		return cr.Bools(j)[i]
+	case query.TInt:
+		return cr.Ints(j)[i]
+	case query.TUInt:
+		return cr.UInts(j)[i]
+	case query.TFloat:
+		return cr.Floats(j)[i]
+	case query.TString:
+		return cr.Strings(j)[i]
+	case query.TTime:
+		return cr.Times(j)[i].Time().UTC()
+	default:
+		execute.PanicUnknownType(typ)
+		return nil
+	}
+}
+
+// sqlPlaceholder returns the function that formats the n-th parameter of a statement for the driver.
+func sqlPlaceholder(driverName string) func(n int) string {
+	switch driverName {
+	case "postgres", "pgx":
+		return func(n int) string {
+			return "$" + strconv.Itoa(n)
+		}
+	default:
+		return func(int) string {
+			return "?"
+		}
+	}
+}
+
+// sqlIdentifierQuote returns the function that quotes table and column names for the driver.
+func sqlIdentifierQuote(driverName string) func(id string) string {
+	q := `"`
+	if driverName == "mysql" {
+		q = "`"
+	}
+	return func(id string) string {
+		return q + strings.Replace(id, q, q+q, -1) + q
+	}
+}
@@ -0,0 +1,202 @@
+package functions_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/execute"
+	"github.com/EMCECS/influx/query/execute/executetest"
+	"github.com/EMCECS/influx/query/functions"
+	"github.com/EMCECS/influx/query/querytest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestToSQL_NewQuery(t *testing.T) {
+	tests := []querytest.NewQueryTestCase{
+		{
+			Name: "toSQL",
+			Raw:  `from(bucket:"mydb") |> toSQL(driverName:"postgres", dataSourceName:"postgres://localhost/metrics", tableName:"cpu")`,
+			Want: &query.Spec{
+				Operations: []*query.Operation{
+					{
+						ID: "from0",
+						Spec: &functions.FromOpSpec{
+							Bucket: "mydb",
+						},
+					},
+					{
+						ID: "toSQL1",
+						Spec: &functions.ToSQLOpSpec{
+							DriverName:     "postgres",
+							DataSourceName: "postgres://localhost/metrics",
+							TableName:      "cpu",
+							BatchSize:      functions.DefaultToSQLBatchSize,
+						},
+					},
+				},
+				Edges: []query.Edge{
+					{Parent: "from0", Child: "toSQL1"},
+				},
+			},
+		},
+		{
+			Name:    "no table name",
+			Raw:     `from(bucket:"mydb") |> toSQL(driverName:"sqlite3", dataSourceName:"file:metrics.db")`,
+			WantErr: true,
+		},
+		{
+			Name:    "negative batch size",
+			Raw:     `from(bucket:"mydb") |> toSQL(driverName:"sqlite3", dataSourceName:"file:metrics.db", tableName:"cpu", batchSize:-1)`,
+			WantErr: true,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			querytest.NewQueryTestHelper(t, tc)
+		})
+	}
+}
+
+func TestToSQLOperation_Marshaling(t *testing.T) {
+	data := []byte(`{"id":"toSQL","kind":"toSQL","spec":{"driverName":"sqlite3","dataSourceName":"file:metrics.db","tableName":"cpu","batchSize":100}}`)
+	op := &query.Operation{
+		ID: "toSQL",
+		Spec: &functions.ToSQLOpSpec{
+			DriverName:     "sqlite3",
+			DataSourceName: "file:metrics.db",
+			TableName:      "cpu",
+			BatchSize:      100,
+		},
+	}
+	querytest.OperationMarshalingTestHelper(t, data, op)
+}
+
+func TestToSQL_Process(t *testing.T) {
+	dsn, cleanup := newSQLiteDB(t,
+		`CREATE TABLE cpu (_time DATETIME, host TEXT, _value REAL, "user name" TEXT)`,
+	)
+	defer cleanup()
+
+	data := []*executetest.Table{{
+		KeyCols: []string{"host"},
+		ColMeta: []query.ColMeta{
+			{Label: "_time", Type: query.TTime},
+			{Label: "host", Type: query.TString},
+			{Label: "_value", Type: query.TFloat},
+			{Label: "user name", Type: query.TString},
+		},
+		Data: [][]interface{}{
+			{execute.Time(1e9), "a", 1.5, "x"},
+			{execute.Time(2e9), "a", 2.5, nil},
+			{execute.Time(3e9), "a", nil, "y"},
+		},
+	}}
+	spec := &functions.ToSQLProcedureSpec{
+		DriverName:     "sqlite3",
+		DataSourceName: dsn,
+		TableName:      "cpu",
+		BatchSize:      2,
+	}
+	executetest.ProcessTestHelper(
+		t,
+		[]query.Table{data[0]},
+		data,
+		nil,
+		func(d execute.Dataset, c execute.TableBuilderCache) execute.Transformation {
+			tr, err := functions.NewToSQLTransformation(d, c, spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return tr
+		},
+	)
+
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	rows, err := db.Query(`SELECT strftime('%s', _time), host, _value, "user name" FROM cpu ORDER BY _time`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got [][]interface{}
+	for rows.Next() {
+		var (
+			ts, host string
+			value    sql.NullFloat64
+			user     sql.NullString
+		)
+		if err := rows.Scan(&ts, &host, &value, &user); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, []interface{}{ts, host, value, user})
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]interface{}{
+		{"1", "a", sql.NullFloat64{Float64: 1.5, Valid: true}, sql.NullString{String: "x", Valid: true}},
+		{"2", "a", sql.NullFloat64{Float64: 2.5, Valid: true}, sql.NullString{}},
+		{"3", "a", sql.NullFloat64{}, sql.NullString{String: "y", Valid: true}},
+	}
+	if !cmp.Equal(want, got) {
+		t.Errorf("unexpected rows -want/+got\n%s", cmp.Diff(want, got))
+	}
+}
+
+func TestToSQL_ProcessError(t *testing.T) {
+	dsn, cleanup := newSQLiteDB(t)
+	defer cleanup()
+
+	spec := &functions.ToSQLProcedureSpec{
+		DriverName:     "sqlite3",
+		DataSourceName: dsn,
+		TableName:      "missing",
+		BatchSize:      10,
+	}
+	d := executetest.NewDataset(executetest.RandomDatasetID())
+	c := execute.NewTableBuilderCache(executetest.UnlimitedAllocator)
+	c.SetTriggerSpec(execute.DefaultTriggerSpec)
+	tr, err := functions.NewToSQLTransformation(d, c, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbl := &executetest.Table{
+		ColMeta: []query.ColMeta{
+			{Label: "_value", Type: query.TFloat},
+		},
+		Data: [][]interface{}{
+			{1.0},
+		},
+	}
+	if err := tr.Process(executetest.RandomDatasetID(), tbl); err == nil {
+		t.Fatal("expected error, got none")
+	}
+}
+
+func TestToSQL_ProcessNoColumns(t *testing.T) {
+	dsn, cleanup := newSQLiteDB(t)
+	defer cleanup()
+
+	spec := &functions.ToSQLProcedureSpec{
+		DriverName:     "sqlite3",
+		DataSourceName: dsn,
+		TableName:      "cpu",
+		BatchSize:      10,
+	}
+	d := executetest.NewDataset(executetest.RandomDatasetID())
+	c := execute.NewTableBuilderCache(executetest.UnlimitedAllocator)
+	c.SetTriggerSpec(execute.DefaultTriggerSpec)
+	tr, err := functions.NewToSQLTransformation(d, c, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Process(executetest.RandomDatasetID(), &executetest.Table{}); err == nil {
+		t.Fatal("expected error, got none")
+	}
+}
@@ -3,6 +3,7 @@ package functions
 import (
 	"fmt"
 	"math"
+	"time"
 
 	"github.com/EMCECS/influx/query"
 	"github.com/EMCECS/influx/query/execute"
@@ -24,6 +25,11 @@ type WindowOpSpec struct {
 	StopColLabel  string            `json:"stop_col_label"`
 	StartColLabel string            `json:"start_col_label"`
 	CreateEmpty   bool              `json:"createEmpty"`
+	// EveryMonths and PeriodMonths are calendar months added to Every and Period.
+	EveryMonths  int64 `json:"everyMonths,omitempty"`
+	PeriodMonths int64 `json:"periodMonths,omitempty"`
+	// Location is the name of the time zone in which the windows are aligned, the default is UTC.
+	Location string `json:"location,omitempty"`
 }
 
 var infinityVar = values.NewDurationValue(math.MaxInt64)
@@ -39,6 +45,7 @@ func init() {
 	windowSignature.Params["startColLabel"] = semantic.String
 	windowSignature.Params["stopColLabel"] = semantic.String
 	windowSignature.Params["createEmpty"] = semantic.Bool
+	windowSignature.Params["location"] = semantic.String
 
 	query.RegisterFunction(WindowKind, createWindowOpSpec, windowSignature)
 	query.RegisterOpSpec(WindowKind, newWindowOp)
@@ -53,19 +60,27 @@ func createWindowOpSpec(args query.Arguments, a *query.Administration) (query.Op
 	}
 
 	spec := new(WindowOpSpec)
-	every, everySet, err := args.GetDuration("every")
+	every, everySet, err := args.GetCalendarDuration("every")
 	if err != nil {
 		return nil, err
 	}
 	if everySet {
-		spec.Every = query.Duration(every)
+		if every.Months < 0 || every.Duration < 0 {
+			return nil, errors.New("window every must not be negative")
+		}
+		spec.Every = query.Duration(every.Duration)
+		spec.EveryMonths = every.Months
 	}
-	period, periodSet, err := args.GetDuration("period")
+	period, periodSet, err := args.GetCalendarDuration("period")
 	if err != nil {
 		return nil, err
 	}
 	if periodSet {
-		spec.Period = period
+		if period.Months < 0 || period.Duration < 0 {
+			return nil, errors.New("window period must not be negative")
+		}
+		spec.Period = query.Duration(period.Duration)
+		spec.PeriodMonths = period.Months
 	}
 	if round, ok, err := args.GetDuration("round"); err != nil {
 		return nil, err
@@ -110,13 +125,23 @@ func createWindowOpSpec(args query.Arguments, a *query.Administration) (query.Op
 	} else {
 		spec.CreateEmpty = false
 	}
+	if location, ok, err := args.GetString("location"); err != nil {
+		return nil, err
+	} else if ok {
+		if _, err := time.LoadLocation(location); err != nil {
+			return nil, errors.Wrap(err, "window location")
+		}
+		spec.Location = location
+	}
 
 	// Apply defaults
 	if !everySet {
 		spec.Every = spec.Period
+		spec.EveryMonths = spec.PeriodMonths
 	}
 	if !periodSet {
 		spec.Period = spec.Every
+		spec.PeriodMonths = spec.EveryMonths
 	}
 	return spec, nil
 }
@@ -145,10 +170,13 @@ func newWindowProcedure(qs query.OperationSpec, pa plan.Administration) (plan.Pr
 	}
 	p := &WindowProcedureSpec{
 		Window: plan.WindowSpec{
-			Every:  s.Every,
-			Period: s.Period,
-			Round:  s.Round,
-			Start:  s.Start,
+			Every:        s.Every,
+			Period:       s.Period,
+			Round:        s.Round,
+			Start:        s.Start,
+			EveryMonths:  s.EveryMonths,
+			PeriodMonths: s.PeriodMonths,
+			Location:     s.Location,
 		},
 		Triggering:    s.Triggering,
 		TimeCol:       s.TimeCol,
@@ -172,6 +200,19 @@ func (s *WindowProcedureSpec) Copy() plan.ProcedureSpec {
 	return ns
 }
 
+// WindowSliceable returns the duration of the windows when they follow each other from the Unix epoch,
+// without a start, a period that differs from every, rounding, calendar months or a time zone.
+func (s *WindowProcedureSpec) WindowSliceable() (values.Duration, string, bool) {
+	w := s.Window
+	if w.Every <= 0 || w.Period != w.Every || w.Round != 0 || !w.Start.IsZero() {
+		return 0, "", false
+	}
+	if w.EveryMonths != 0 || w.PeriodMonths != 0 || (w.Location != "" && w.Location != "UTC") {
+		return 0, "", false
+	}
+	return values.Duration(w.Every), s.StartColLabel, true
+}
+
 func (s *WindowProcedureSpec) TriggerSpec() query.TriggerSpec {
 	return s.Triggering
 }
@@ -183,28 +224,44 @@ func createWindowTransformation(id execute.DatasetID, mode execute.AccumulationM
 	}
 	cache := execute.NewTableBuilderCache(a.Allocator())
 	d := execute.NewDataset(id, mode, cache)
-	var start execute.Time
-	if s.Window.Start.IsZero() {
-		start = a.ResolveTime(query.Now).Truncate(execute.Duration(s.Window.Every))
-	} else {
-		start = a.ResolveTime(s.Window.Start)
+	w := execute.Window{
+		Every:        execute.Duration(s.Window.Every),
+		Period:       execute.Duration(s.Window.Period),
+		Round:        execute.Duration(s.Window.Round),
+		EveryMonths:  s.Window.EveryMonths,
+		PeriodMonths: s.Window.PeriodMonths,
+	}
+	if s.Window.Location != "" {
+		loc, err := time.LoadLocation(s.Window.Location)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "window location")
+		}
+		w.Location = loc
+	}
+	switch {
+	case !s.Window.Start.IsZero():
+		w.Start = a.ResolveTime(s.Window.Start)
+	case w.IsCalendar():
+		// Calendar windows are aligned with the local days, months and years.
+		w.Start = execute.LocalEpoch(w.Location)
+	default:
+		w.Start = a.ResolveTime(query.Now).Truncate(w.Every)
 	}
 
 	bounds := a.StreamContext().Bounds()
 	if bounds == nil {
 		return nil, nil, errors.New("nil bounds passed to window")
 	}
+	if s.CreateEmpty && bounds.Stop == execute.MaxTime {
+		// The bounds of a live query have no stop.
+		return nil, nil, errors.New("cannot create empty windows within bounds without a stop")
+	}
 
 	t := NewFixedWindowTransformation(
 		d,
 		cache,
 		*bounds,
-		execute.Window{
-			Every:  execute.Duration(s.Window.Every),
-			Period: execute.Duration(s.Window.Period),
-			Round:  execute.Duration(s.Window.Round),
-			Start:  start,
-		},
+		w,
 		s.TimeCol,
 		s.StartColLabel,
 		s.StopColLabel,
@@ -238,7 +295,10 @@ func NewFixedWindowTransformation(
 	stopColLabel string,
 	createEmpty bool,
 ) execute.Transformation {
-	offset := execute.Duration(w.Start - w.Start.Truncate(w.Every))
+	var offset execute.Duration
+	if !w.IsCalendar() {
+		offset = execute.Duration(w.Start - w.Start.Truncate(w.Every))
+	}
 	t := &fixedWindowTransformation{
 		d:             d,
 		cache:         cache,
@@ -387,6 +447,14 @@ func (t *fixedWindowTransformation) newWindowGroupKey(tbl query.Table, keyCols [
 }
 
 func (t *fixedWindowTransformation) generateInitialBounds(boundsStart, boundsStop execute.Time) (execute.Time, execute.Time) {
+	if t.w.IsCalendar() {
+		stop := t.w.Truncate(boundsStart)
+		if boundsStop >= stop {
+			stop = t.w.NextBoundary(stop)
+		}
+		return t.w.StartOf(stop), stop
+	}
+
 	stop := boundsStart.Truncate(t.w.Every) + execute.Time(t.offset)
 	if boundsStop >= stop {
 		stop += execute.Time(t.w.Every)
@@ -426,8 +494,7 @@ func (t *fixedWindowTransformation) getWindowBounds(now execute.Time) []execute.
 		t.clipBounds(&bnds)
 		bounds = append(bounds, bnds)
 
-		stop += execute.Time(t.w.Every)
-		start += execute.Time(t.w.Every)
+		start, stop = t.nextBounds(start, stop)
 	}
 
 	return bounds
@@ -453,12 +520,20 @@ func (t *fixedWindowTransformation) generateWindowsWithinBounds() {
 		t.clipBounds(&bnds)
 		bounds = append(bounds, bnds)
 
-		start += execute.Time(t.w.Every)
-		stop += execute.Time(t.w.Every)
+		start, stop = t.nextBounds(start, stop)
 	}
 	t.allBounds = bounds
 }
 
+// nextBounds returns the bounds of the window following the window with the given bounds.
+func (t *fixedWindowTransformation) nextBounds(start, stop execute.Time) (execute.Time, execute.Time) {
+	if t.w.IsCalendar() {
+		stop = t.w.NextBoundary(stop)
+		return t.w.StartOf(stop), stop
+	}
+	return start + execute.Time(t.w.Every), stop + execute.Time(t.w.Every)
+}
+
 func (t *fixedWindowTransformation) UpdateWatermark(id execute.DatasetID, mark execute.Time) error {
 	return t.d.UpdateWatermark(mark)
 }
@@ -49,6 +49,45 @@ func TestWindow_NewQuery(t *testing.T) {
 				},
 			},
 		},
+		{
+			Name: "from with calendar window",
+			Raw:  `from(bucket:"mybucket") |> window(every:1mo, period:1y2d, location:"America/New_York")`,
+			Want: &query.Spec{
+				Operations: []*query.Operation{
+					{
+						ID: "from0",
+						Spec: &functions.FromOpSpec{
+							Bucket: "mybucket",
+						},
+					},
+					{
+						ID: "window1",
+						Spec: &functions.WindowOpSpec{
+							EveryMonths:   1,
+							Period:        query.Duration(48 * time.Hour),
+							PeriodMonths:  12,
+							Location:      "America/New_York",
+							TimeCol:       execute.DefaultTimeColLabel,
+							StartColLabel: execute.DefaultStartColLabel,
+							StopColLabel:  execute.DefaultStopColLabel,
+						},
+					},
+				},
+				Edges: []query.Edge{
+					{Parent: "from0", Child: "window1"},
+				},
+			},
+		},
+		{
+			Name:    "window with unknown location",
+			Raw:     `from(bucket:"mybucket") |> window(every:1d, location:"Nowhere/Special")`,
+			WantErr: true,
+		},
+		{
+			Name:    "window with negative every",
+			Raw:     `from(bucket:"mybucket") |> window(every:-1mo)`,
+			WantErr: true,
+		},
 	}
 	for _, tc := range tests {
 		tc := tc
@@ -78,6 +117,20 @@ func TestWindowOperation_Marshaling(t *testing.T) {
 	querytest.OperationMarshalingTestHelper(t, data, op)
 }
 
+func TestWindowOperation_MarshalingCalendar(t *testing.T) {
+	data := []byte(`{"id":"window","kind":"window","spec":{"every":"0s","period":"24h","everyMonths":1,"location":"Europe/Paris"}}`)
+	op := &query.Operation{
+		ID: "window",
+		Spec: &functions.WindowOpSpec{
+			EveryMonths: 1,
+			Period:      query.Duration(24 * time.Hour),
+			Location:    "Europe/Paris",
+		},
+	}
+
+	querytest.OperationMarshalingTestHelper(t, data, op)
+}
+
 func TestFixedWindow_PassThrough(t *testing.T) {
 	executetest.TransformationPassThroughTestHelper(t, func(d execute.Dataset, c execute.TableBuilderCache) execute.Transformation {
 		fw := functions.NewFixedWindowTransformation(
@@ -842,3 +895,131 @@ func TestFixedWindow_Process(t *testing.T) {
 		})
 	}
 }
+
+func TestCalendarWindow_Process(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	date := func(month time.Month, day, hour int) execute.Time {
+		return execute.Time(time.Date(2018, month, day, hour, 0, 0, 0, newYork).UnixNano())
+	}
+	cols := []query.ColMeta{
+		{Label: "_start", Type: query.TTime},
+		{Label: "_stop", Type: query.TTime},
+		{Label: "_time", Type: query.TTime},
+		{Label: "_value", Type: query.TFloat},
+	}
+	testCases := []struct {
+		name        string
+		w           execute.Window
+		bounds      execute.Bounds
+		createEmpty bool
+		data        [][]interface{}
+		want        []*executetest.Table
+	}{
+		{
+			name: "days aligned on local midnight",
+			w: execute.Window{
+				Every:    execute.Duration(24 * time.Hour),
+				Period:   execute.Duration(24 * time.Hour),
+				Start:    execute.LocalEpoch(newYork),
+				Location: newYork,
+			},
+			// Daylight saving time starts on March 11th.
+			bounds: execute.Bounds{
+				Start: date(time.March, 10, 12),
+				Stop:  date(time.March, 12, 12),
+			},
+			createEmpty: true,
+			data: [][]interface{}{
+				{date(time.March, 10, 12), date(time.March, 12, 12), date(time.March, 10, 22), 1.0},
+				{date(time.March, 10, 12), date(time.March, 12, 12), date(time.March, 11, 23), 2.0},
+			},
+			want: []*executetest.Table{
+				{
+					KeyCols: []string{"_start", "_stop"},
+					ColMeta: cols,
+					Data: [][]interface{}{
+						{date(time.March, 10, 12), date(time.March, 11, 0), date(time.March, 10, 22), 1.0},
+					},
+				},
+				{
+					KeyCols: []string{"_start", "_stop"},
+					ColMeta: cols,
+					Data: [][]interface{}{
+						{date(time.March, 11, 0), date(time.March, 12, 0), date(time.March, 11, 23), 2.0},
+					},
+				},
+				newEmptyWindowTable(date(time.March, 12, 0), date(time.March, 12, 12), cols),
+			},
+		},
+		{
+			name: "months",
+			w: execute.Window{
+				EveryMonths:  1,
+				PeriodMonths: 1,
+				Start:        execute.LocalEpoch(newYork),
+				Location:     newYork,
+			},
+			bounds: execute.Bounds{
+				Start: date(time.January, 1, 0),
+				Stop:  date(time.April, 1, 0),
+			},
+			data: [][]interface{}{
+				{date(time.January, 1, 0), date(time.April, 1, 0), date(time.January, 31, 23), 1.0},
+				{date(time.January, 1, 0), date(time.April, 1, 0), date(time.February, 1, 0), 2.0},
+				{date(time.January, 1, 0), date(time.April, 1, 0), date(time.March, 31, 12), 3.0},
+			},
+			want: []*executetest.Table{
+				{
+					KeyCols: []string{"_start", "_stop"},
+					ColMeta: cols,
+					Data: [][]interface{}{
+						{date(time.January, 1, 0), date(time.February, 1, 0), date(time.January, 31, 23), 1.0},
+					},
+				},
+				{
+					KeyCols: []string{"_start", "_stop"},
+					ColMeta: cols,
+					Data: [][]interface{}{
+						{date(time.February, 1, 0), date(time.March, 1, 0), date(time.February, 1, 0), 2.0},
+					},
+				},
+				{
+					KeyCols: []string{"_start", "_stop"},
+					ColMeta: cols,
+					Data: [][]interface{}{
+						{date(time.March, 1, 0), date(time.April, 1, 0), date(time.March, 31, 12), 3.0},
+					},
+				},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			executetest.ProcessTestHelper(
+				t,
+				[]query.Table{&executetest.Table{
+					ColMeta: cols,
+					Data:    tc.data,
+				}},
+				tc.want,
+				nil,
+				func(d execute.Dataset, c execute.TableBuilderCache) execute.Transformation {
+					return functions.NewFixedWindowTransformation(
+						d,
+						c,
+						tc.bounds,
+						tc.w,
+						execute.DefaultTimeColLabel,
+						execute.DefaultStartColLabel,
+						execute.DefaultStopColLabel,
+						tc.createEmpty,
+					)
+				},
+			)
+		})
+	}
+}
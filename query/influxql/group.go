@@ -243,6 +243,15 @@ func (gr *groupInfo) createCursor(t *transpilerState) (cursor, error) {
 				}, cur.ID()),
 				cursor: cur,
 			}
+
+			// Empty windows produce null values, replace them as requested by the fill option.
+			// The fill is applied once the windows are merged so the previous value carries
+			// across windows.
+			if c, err := gr.fill(t, cur); err != nil {
+				return nil, err
+			} else {
+				cur = c
+			}
 		}
 	} else {
 		// If we do not have a function, but we have a field option,
@@ -367,6 +376,16 @@ func (gr *groupInfo) group(t *transpilerState, in cursor) (cursor, error) {
 			StopColLabel:  execute.DefaultStopColLabel,
 		}
 
+		// Empty windows are only created when they are filled with a value.
+		// With fill(null) they would produce a row for every window of the time range,
+		// which is unbounded for queries ending at now(), so they are omitted as with fill(none).
+		if gr.call != nil {
+			switch t.stmt.Fill {
+			case influxql.NumberFill, influxql.PreviousFill:
+				windowOp.CreateEmpty = true
+			}
+		}
+
 		if !windowStart.IsZero() {
 			windowOp.Start = query.Time{Absolute: windowStart}
 		}
@@ -379,6 +398,40 @@ func (gr *groupInfo) group(t *transpilerState, in cursor) (cursor, error) {
 
 func (c *groupCursor) ID() query.OperationID { return c.id }
 
+// fill replaces the null values produced by the function call of the group
+// according to the fill option of the statement.
+func (gr *groupInfo) fill(t *transpilerState, in cursor) (cursor, error) {
+	value, ok := in.Value(gr.call)
+	if !ok {
+		return nil, fmt.Errorf("undefined variable: %s", gr.call)
+	}
+
+	spec := &functions.FillOpSpec{Column: value}
+	switch t.stmt.Fill {
+	case influxql.NullFill, influxql.NoFill:
+		return in, nil
+	case influxql.NumberFill:
+		switch v := t.stmt.FillValue.(type) {
+		case int64:
+			spec.Value = &semantic.IntegerLiteral{Value: v}
+		case float64:
+			spec.Value = &semantic.FloatLiteral{Value: v}
+		default:
+			return nil, fmt.Errorf("unsupported fill value of type %T", v)
+		}
+	case influxql.PreviousFill:
+		spec.UsePrevious = true
+	case influxql.LinearFill:
+		return nil, errors.New("unimplemented: fill(linear)")
+	default:
+		return nil, fmt.Errorf("unknown fill option: %v", t.stmt.Fill)
+	}
+	return &groupCursor{
+		id:     t.op("fill", spec, in.ID()),
+		cursor: in,
+	}, nil
+}
+
 // tagsCursor is a pseudo-cursor that can be used to access tags within the cursor.
 type tagsCursor struct {
 	cursor
@@ -359,3 +359,9 @@ func (r *queryTable) Strings(j int) []string {
 func (r *queryTable) Times(j int) []values.Time {
 	return r.cols[j].([]values.Time)
 }
+
+// Nulls reports that the columns contain no nulls.
+// It is used to implement query.ColReader.
+func (r *queryTable) Nulls(j int) []bool {
+	return nil
+}
@@ -0,0 +1,188 @@
+package spectests
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/ast"
+	"github.com/EMCECS/influx/query/execute"
+	"github.com/EMCECS/influx/query/functions"
+	"github.com/EMCECS/influx/query/semantic"
+)
+
+func init() {
+	RegisterFixture(
+		FillTest(`fill(0)`, &functions.FillOpSpec{
+			Column: execute.DefaultValueColLabel,
+			Value:  &semantic.IntegerLiteral{Value: 0},
+		}),
+		FillTest(`fill(previous)`, &functions.FillOpSpec{
+			Column:      execute.DefaultValueColLabel,
+			UsePrevious: true,
+		}),
+	)
+}
+
+// FillTest creates a fixture for a windowed mean using the fill option.
+func FillTest(option string, fill *functions.FillOpSpec) Fixture {
+	_, file, line, _ := runtime.Caller(1)
+	fixture := &fixture{
+		stmt: fmt.Sprintf(`SELECT mean(value) FROM db0..cpu WHERE time >= now() - 10m GROUP BY time(1m) %s`, option),
+		spec: &query.Spec{
+			Operations: []*query.Operation{
+				{
+					ID: "from0",
+					Spec: &functions.FromOpSpec{
+						BucketID: bucketID,
+					},
+				},
+				{
+					ID: "range0",
+					Spec: &functions.RangeOpSpec{
+						Start:    query.Time{Absolute: Now().Add(-10 * time.Minute)},
+						Stop:     query.Time{Absolute: Now()},
+						TimeCol:  execute.DefaultTimeColLabel,
+						StartCol: execute.DefaultStartColLabel,
+						StopCol:  execute.DefaultStopColLabel,
+					},
+				},
+				{
+					ID: "filter0",
+					Spec: &functions.FilterOpSpec{
+						Fn: &semantic.FunctionExpression{
+							Params: []*semantic.FunctionParam{
+								{Key: &semantic.Identifier{Name: "r"}},
+							},
+							Body: &semantic.LogicalExpression{
+								Operator: ast.AndOperator,
+								Left: &semantic.BinaryExpression{
+									Operator: ast.EqualOperator,
+									Left: &semantic.MemberExpression{
+										Object: &semantic.IdentifierExpression{
+											Name: "r",
+										},
+										Property: "_measurement",
+									},
+									Right: &semantic.StringLiteral{
+										Value: "cpu",
+									},
+								},
+								Right: &semantic.BinaryExpression{
+									Operator: ast.EqualOperator,
+									Left: &semantic.MemberExpression{
+										Object: &semantic.IdentifierExpression{
+											Name: "r",
+										},
+										Property: "_field",
+									},
+									Right: &semantic.StringLiteral{
+										Value: "value",
+									},
+								},
+							},
+						},
+					},
+				},
+				{
+					ID: "group0",
+					Spec: &functions.GroupOpSpec{
+						By: []string{"_measurement", "_start"},
+					},
+				},
+				{
+					ID: "window0",
+					Spec: &functions.WindowOpSpec{
+						Every:         query.Duration(time.Minute),
+						Period:        query.Duration(time.Minute),
+						TimeCol:       execute.DefaultTimeColLabel,
+						StartColLabel: execute.DefaultStartColLabel,
+						StopColLabel:  execute.DefaultStopColLabel,
+						CreateEmpty:   true,
+					},
+				},
+				{
+					ID: "mean0",
+					Spec: &functions.MeanOpSpec{
+						AggregateConfig: execute.AggregateConfig{
+							TimeSrc: execute.DefaultStartColLabel,
+							TimeDst: execute.DefaultTimeColLabel,
+							Columns: []string{execute.DefaultValueColLabel},
+						},
+					},
+				},
+				{
+					ID: "window1",
+					Spec: &functions.WindowOpSpec{
+						Every:         query.Duration(math.MaxInt64),
+						Period:        query.Duration(math.MaxInt64),
+						TimeCol:       execute.DefaultTimeColLabel,
+						StartColLabel: execute.DefaultStartColLabel,
+						StopColLabel:  execute.DefaultStopColLabel,
+					},
+				},
+				{
+					ID:   "fill0",
+					Spec: fill,
+				},
+				{
+					ID: "map0",
+					Spec: &functions.MapOpSpec{
+						Fn: &semantic.FunctionExpression{
+							Params: []*semantic.FunctionParam{{
+								Key: &semantic.Identifier{Name: "r"},
+							}},
+							Body: &semantic.ObjectExpression{
+								Properties: []*semantic.Property{
+									{
+										Key: &semantic.Identifier{Name: "_time"},
+										Value: &semantic.MemberExpression{
+											Object: &semantic.IdentifierExpression{
+												Name: "r",
+											},
+											Property: "_time",
+										},
+									},
+									{
+										Key: &semantic.Identifier{Name: "mean"},
+										Value: &semantic.MemberExpression{
+											Object: &semantic.IdentifierExpression{
+												Name: "r",
+											},
+											Property: "_value",
+										},
+									},
+								},
+							},
+						},
+						MergeKey: true,
+					},
+				},
+				{
+					ID: "yield0",
+					Spec: &functions.YieldOpSpec{
+						Name: "0",
+					},
+				},
+			},
+			Edges: []query.Edge{
+				{Parent: "from0", Child: "range0"},
+				{Parent: "range0", Child: "filter0"},
+				{Parent: "filter0", Child: "group0"},
+				{Parent: "group0", Child: "window0"},
+				{Parent: "window0", Child: "mean0"},
+				{Parent: "mean0", Child: "window1"},
+				{Parent: "window1", Child: "fill0"},
+				{Parent: "fill0", Child: "map0"},
+				{Parent: "map0", Child: "yield0"},
+			},
+			Now: Now(),
+		},
+		file: filepath.Base(file),
+		line: line,
+	}
+	return fixture
+}
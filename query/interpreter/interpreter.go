@@ -3,6 +3,7 @@ package interpreter
 import (
 	"fmt"
 	"regexp"
+	"strconv"
 
 	"github.com/EMCECS/influx/query/ast"
 	"github.com/EMCECS/influx/query/semantic"
@@ -177,6 +178,9 @@ func (itrp *Interpreter) doExpression(expr semantic.Expression, scope *Scope) (v
 		if err != nil {
 			return nil, err
 		}
+		if obj.Type().Kind() == semantic.Array {
+			return arrayIndex(obj.Array(), e.Property)
+		}
 		v, ok := obj.Object().Get(e.Property)
 		if !ok {
 			return nil, fmt.Errorf("object has no property %q", e.Property)
@@ -202,7 +206,11 @@ func (itrp *Interpreter) doExpression(expr semantic.Expression, scope *Scope) (v
 			case semantic.Float:
 				return values.NewFloatValue(-v.Float()), nil
 			case semantic.Duration:
-				return values.NewDurationValue(-v.Duration()), nil
+				d := values.CalendarDurationOf(v)
+				return values.NewCalendarDurationValue(values.CalendarDuration{
+					Months:   -d.Months,
+					Duration: -d.Duration,
+				}), nil
 			default:
 				return nil, fmt.Errorf("operand to unary expression is not a number value, got %v", v.Type())
 			}
@@ -269,6 +277,7 @@ func (itrp *Interpreter) doExpression(expr semantic.Expression, scope *Scope) (v
 		return &function{
 			e:     e,
 			scope: scope.Nest(),
+			itrp:  itrp,
 		}, nil
 	default:
 		return nil, fmt.Errorf("unsupported expression %T", expr)
@@ -314,7 +323,10 @@ func (itrp *Interpreter) doLiteral(lit semantic.Literal) (values.Value, error) {
 	case *semantic.DateTimeLiteral:
 		return values.NewTimeValue(values.Time(l.Value.UnixNano())), nil
 	case *semantic.DurationLiteral:
-		return values.NewDurationValue(values.Duration(l.Value)), nil
+		return values.NewCalendarDurationValue(values.CalendarDuration{
+			Months:   l.Months,
+			Duration: values.Duration(l.Value),
+		}), nil
 	case *semantic.FloatLiteral:
 		return values.NewFloatValue(l.Value), nil
 	case *semantic.IntegerLiteral:
@@ -332,6 +344,18 @@ func (itrp *Interpreter) doLiteral(lit semantic.Literal) (values.Value, error) {
 	}
 }
 
+// arrayIndex returns the element of the array at the index given by a member property, as in a[0].
+func arrayIndex(arr values.Array, property string) (values.Value, error) {
+	i, err := strconv.Atoi(property)
+	if err != nil {
+		return nil, fmt.Errorf("array index must be an integer, got %q", property)
+	}
+	if i < 0 || i >= arr.Len() {
+		return nil, fmt.Errorf("array index %d out of bounds [0:%d]", i, arr.Len())
+	}
+	return arr.Get(i), nil
+}
+
 func functionName(call *semantic.CallExpression) string {
 	switch callee := call.Callee.(type) {
 	case *semantic.IdentifierExpression:
@@ -812,10 +836,62 @@ func (f function) resolveIdentifiers(n semantic.Node) (semantic.Node, error) {
 			return nil, err
 		}
 		n.Value = node.(semantic.Expression)
+	case *semantic.MemberExpression:
+		// Members of values in scope resolve to the value of the member, such as m._value.
+		v, ok, err := f.scopeValue(n)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return resolveValue(v)
+		}
+		node, err := f.resolveIdentifiers(n.Object)
+		if err != nil {
+			return nil, err
+		}
+		n.Object = node.(semantic.Expression)
 	}
 	return n, nil
 }
 
+// scopeValue returns the value of an identifier or of a member of an identifier in scope.
+// It reports false for the parameters of the function and their members.
+func (f function) scopeValue(e semantic.Expression) (values.Value, bool, error) {
+	switch e := e.(type) {
+	case *semantic.IdentifierExpression:
+		for _, p := range f.e.Params {
+			if e.Name == p.Key.Name {
+				return nil, false, nil
+			}
+		}
+		v, ok := f.scope.Lookup(e.Name)
+		if !ok {
+			return nil, false, fmt.Errorf("name %q does not exist in scope", e.Name)
+		}
+		return v, true, nil
+	case *semantic.MemberExpression:
+		obj, ok, err := f.scopeValue(e.Object)
+		if err != nil || !ok {
+			return nil, ok, err
+		}
+		switch obj.Type().Kind() {
+		case semantic.Array:
+			v, err := arrayIndex(obj.Array(), e.Property)
+			return v, err == nil, err
+		case semantic.Object:
+			v, ok := obj.Object().Get(e.Property)
+			if !ok {
+				return nil, false, fmt.Errorf("object has no property %q", e.Property)
+			}
+			return v, true, nil
+		default:
+			return nil, false, fmt.Errorf("cannot access property %q of %v", e.Property, obj.Type())
+		}
+	default:
+		return nil, false, nil
+	}
+}
+
 func resolveValue(v values.Value) (semantic.Node, error) {
 	switch k := v.Type().Kind(); k {
 	case semantic.String:
@@ -847,8 +923,10 @@ func resolveValue(v values.Value) (semantic.Node, error) {
 			Value: v.Regexp(),
 		}, nil
 	case semantic.Duration:
+		d := values.CalendarDurationOf(v)
 		return &semantic.DurationLiteral{
-			Value: v.Duration().Duration(),
+			Value:  d.Duration.Duration(),
+			Months: d.Months,
 		}, nil
 	case semantic.Function:
 		resolver, ok := v.Function().(Resolver)
@@ -0,0 +1,31 @@
+package json
+
+import (
+	"net/http"
+
+	"github.com/EMCECS/influx/query"
+)
+
+const DialectType = "json"
+
+// AddDialectMappings adds the json specific dialect mappings.
+func AddDialectMappings(mappings query.DialectMappings) error {
+	return mappings.Add(DialectType, func() query.Dialect {
+		return new(Dialect)
+	})
+}
+
+// Dialect describes the output format of queries in JSON.
+type Dialect struct{}
+
+func (d Dialect) SetHeaders(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("Transfer-Encoding", "chunked")
+}
+
+func (d Dialect) Encoder() query.MultiResultEncoder {
+	return NewMultiResultEncoder()
+}
+func (d Dialect) DialectType() query.DialectType {
+	return DialectType
+}
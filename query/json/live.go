@@ -0,0 +1,83 @@
+package json
+
+import (
+	"io"
+
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/iocounter"
+)
+
+// LiveMultiResultEncoder encodes the results of a live query, whose tables are delivered as they are triggered.
+// Each table is written as a result of its own with a single call to Write,
+// so that each table is a message when w is a message based connection such as a WebSocket.
+// The tables of a result are numbered across its messages.
+type LiveMultiResultEncoder struct {
+	e *ResultEncoder
+}
+
+// NewLiveMultiResultEncoder creates a new LiveMultiResultEncoder.
+func NewLiveMultiResultEncoder() *LiveMultiResultEncoder {
+	return &LiveMultiResultEncoder{
+		e: NewResultEncoder(),
+	}
+}
+
+// Encode writes the tables of the results to w as they are delivered.
+// An error of the query ends the encoding and is written as the error property of the result it ended, or on its own.
+func (e *LiveMultiResultEncoder) Encode(w io.Writer, results query.ResultIterator) (int64, error) {
+	wc := &iocounter.Writer{Writer: w}
+	var buf []byte
+	write := func() error {
+		_, err := wc.Write(buf)
+		buf = buf[:0]
+		if err != nil {
+			return wrapEncodingError(err)
+		}
+		return nil
+	}
+	for results.More() {
+		result := results.Next()
+		tableID := 0
+		err := result.Tables().Do(func(tbl query.Table) error {
+			buf = appendResultHeader(buf, result.Name())
+			buf = appendTableHeader(buf, tableID, tbl)
+			buf = append(buf, `,"data":[`...)
+			first := true
+			if err := tbl.Do(func(cr query.ColReader) error {
+				buf = appendRows(buf, cr, first)
+				first = first && cr.Len() == 0
+				return nil
+			}); err != nil {
+				buf = buf[:0]
+				return err
+			}
+			buf = append(buf, "]}]}\n"...)
+			tableID++
+			return write()
+		})
+		if err != nil {
+			if query.IsEncoderError(err) {
+				return wc.Count(), err
+			}
+			results.Cancel()
+			buf = appendResultHeader(buf, result.Name())
+			buf = append(buf, `],"error":`...)
+			buf = appendString(buf, err.Error())
+			buf = append(buf, "}\n"...)
+			err := write()
+			return wc.Count(), err
+		}
+	}
+	if err := results.Err(); err != nil {
+		err := e.e.EncodeError(wc, err)
+		return wc.Count(), err
+	}
+	return wc.Count(), nil
+}
+
+// appendResultHeader appends the properties of a result before its tables.
+func appendResultHeader(buf []byte, name string) []byte {
+	buf = append(buf, `{"result":`...)
+	buf = appendString(buf, name)
+	return append(buf, `,"tables":[`...)
+}
@@ -0,0 +1,103 @@
+package json_test
+
+import (
+	"testing"
+
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/execute/executetest"
+	"github.com/EMCECS/influx/query/json"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+// messageWriter records each call to Write as a message.
+type messageWriter struct {
+	messages []string
+}
+
+func (w *messageWriter) Write(p []byte) (int, error) {
+	w.messages = append(w.messages, string(p))
+	return len(p), nil
+}
+
+func TestLiveMultiResultEncoder(t *testing.T) {
+	tables := []*executetest.Table{
+		{
+			KeyCols: []string{"host"},
+			ColMeta: []query.ColMeta{
+				{Label: "host", Type: query.TString},
+				{Label: "_value", Type: query.TInt},
+			},
+			Data: [][]interface{}{
+				{"A", int64(1)},
+				{"A", int64(2)},
+			},
+		},
+		{
+			KeyCols: []string{"host"},
+			ColMeta: []query.ColMeta{
+				{Label: "host", Type: query.TString},
+				{Label: "_value", Type: query.TInt},
+			},
+			Data: [][]interface{}{
+				{"B", int64(3)},
+			},
+		},
+	}
+	testCases := []struct {
+		name     string
+		results  query.ResultIterator
+		messages []string
+	}{
+		{
+			name: "tables",
+			results: query.NewSliceResultIterator([]query.Result{
+				&executetest.Result{Nm: "_result", Tbls: tables},
+			}),
+			messages: []string{
+				`{"result":"_result","tables":[{"table":0,"key":{"host":"A"},"columns":[{"label":"host","datatype":"string","group":true},{"label":"_value","datatype":"long","group":false}],"data":[["A",1],["A",2]]}]}
+`,
+				`{"result":"_result","tables":[{"table":1,"key":{"host":"B"},"columns":[{"label":"host","datatype":"string","group":true},{"label":"_value","datatype":"long","group":false}],"data":[["B",3]]}]}
+`,
+			},
+		},
+		{
+			name: "error in result",
+			results: query.NewSliceResultIterator([]query.Result{
+				&executetest.Result{Nm: "_result", Tbls: tables, Err: errors.New("execution failed")},
+			}),
+			messages: []string{
+				`{"result":"_result","tables":[],"error":"execution failed"}
+`,
+			},
+		},
+		{
+			name:    "error in query",
+			results: errorResultIterator{Error: errors.New("query failed")},
+			messages: []string{
+				`{"error":"query failed"}
+`,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			w := new(messageWriter)
+			n, err := json.NewLiveMultiResultEncoder().Encode(w, tc.results)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !cmp.Equal(tc.messages, w.messages) {
+				t.Errorf("unexpected messages -want/+got:\n%s", cmp.Diff(tc.messages, w.messages))
+			}
+			var count int64
+			for _, m := range tc.messages {
+				count += int64(len(m))
+			}
+			if n != count {
+				t.Errorf("unexpected count of bytes written: got %d want %d", n, count)
+			}
+		})
+	}
+}
@@ -0,0 +1,552 @@
+// Package json contains the json result encoders and decoders.
+//
+// Each result is encoded as a JSON object on its own line,
+// its tables carry their group key, the metadata of their columns and their rows:
+//
+//	{"result":"_result","tables":[{"table":0,"key":{"host":"A"},"columns":[{"label":"host","datatype":"string","group":true},{"label":"_value","datatype":"double","group":false}],"data":[["A",42]]}]}
+//
+// Missing values are null, times are RFC3339 strings with nanoseconds
+// and floats that are not numbers are the strings "NaN", "+Inf" and "-Inf".
+// An error that ends a result is its "error" property,
+// and an error that ends the query is an object with only an "error" property.
+package json
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/execute"
+	"github.com/EMCECS/influx/query/iocounter"
+	"github.com/EMCECS/influx/query/semantic"
+	"github.com/EMCECS/influx/query/values"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ContentType is the media type of the json dialect.
+	ContentType = "application/json"
+
+	stringDatatype = "string"
+	timeDatatype   = "dateTime"
+	floatDatatype  = "double"
+	boolDatatype   = "boolean"
+	intDatatype    = "long"
+	uintDatatype   = "unsignedLong"
+)
+
+// encodedResult is the JSON representation of a result, or of the error of a query when Result is nil.
+type encodedResult struct {
+	Result *string        `json:"result,omitempty"`
+	Tables []encodedTable `json:"tables,omitempty"`
+	Error  string         `json:"error,omitempty"`
+}
+
+type encodedTable struct {
+	Table   int                        `json:"table"`
+	Key     map[string]json.RawMessage `json:"key"`
+	Columns []encodedColumn            `json:"columns"`
+	Data    [][]json.RawMessage        `json:"data"`
+}
+
+type encodedColumn struct {
+	Label    string `json:"label"`
+	Datatype string `json:"datatype"`
+	Group    bool   `json:"group"`
+}
+
+// ResultEncoder encodes a result as a single line of JSON.
+type ResultEncoder struct{}
+
+// NewResultEncoder creates a new ResultEncoder.
+func NewResultEncoder() *ResultEncoder {
+	return new(ResultEncoder)
+}
+
+type jsonEncoderError struct {
+	msg string
+}
+
+func (e *jsonEncoderError) Error() string {
+	return e.msg
+}
+
+func (e *jsonEncoderError) IsEncoderError() bool {
+	return true
+}
+
+func wrapEncodingError(err error) error {
+	return errors.Wrap(&jsonEncoderError{msg: err.Error()}, "json encoder error")
+}
+
+// Encode writes the result to w.
+// Tables are written as they are read, so the result is not held in memory.
+// If reading the result fails, the error is written as the error property of the result and returned.
+func (e *ResultEncoder) Encode(w io.Writer, result query.Result) (int64, error) {
+	wc := &iocounter.Writer{Writer: w}
+	err := e.encode(wc, result)
+	return wc.Count(), err
+}
+
+func (e *ResultEncoder) encode(w io.Writer, result query.Result) error {
+	buf := appendResultHeader(nil, result.Name())
+	flush := func() error {
+		_, err := w.Write(buf)
+		buf = buf[:0]
+		if err != nil {
+			return wrapEncodingError(err)
+		}
+		return nil
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+
+	tableID := 0
+	// inTable reports whether the data of a table is open when the result ends.
+	inTable := false
+	err := result.Tables().Do(func(tbl query.Table) error {
+		if tableID > 0 {
+			buf = append(buf, ',')
+		}
+		buf = appendTableHeader(buf, tableID, tbl)
+		buf = append(buf, `,"data":[`...)
+		inTable = true
+		first := true
+		err := tbl.Do(func(cr query.ColReader) error {
+			buf = appendRows(buf, cr, first)
+			first = first && cr.Len() == 0
+			return flush()
+		})
+		if err != nil {
+			return err
+		}
+		buf = append(buf, "]}"...)
+		inTable = false
+		tableID++
+		return flush()
+	})
+	if err != nil && query.IsEncoderError(err) {
+		return err
+	}
+
+	if inTable {
+		buf = append(buf, "]}"...)
+	}
+	buf = append(buf, ']')
+	if err != nil {
+		buf = append(buf, `,"error":`...)
+		buf = appendString(buf, err.Error())
+	}
+	buf = append(buf, "}\n"...)
+	if ferr := flush(); ferr != nil {
+		return ferr
+	}
+	return err
+}
+
+// EncodeError writes the error of a query as an object with only an error property.
+func (e *ResultEncoder) EncodeError(w io.Writer, err error) error {
+	buf := []byte(`{"error":`)
+	buf = appendString(buf, err.Error())
+	buf = append(buf, "}\n"...)
+	_, werr := w.Write(buf)
+	return werr
+}
+
+// appendTableHeader appends the properties of a table before its data.
+func appendTableHeader(buf []byte, tableID int, tbl query.Table) []byte {
+	key := tbl.Key()
+	buf = append(buf, `{"table":`...)
+	buf = strconv.AppendInt(buf, int64(tableID), 10)
+	buf = append(buf, `,"key":{`...)
+	for j, c := range key.Cols() {
+		if j > 0 {
+			buf = append(buf, ',')
+		}
+		buf = appendString(buf, c.Label)
+		buf = append(buf, ':')
+		buf = appendValue(buf, key.Value(j))
+	}
+	buf = append(buf, `},"columns":[`...)
+	for j, c := range tbl.Cols() {
+		if j > 0 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, `{"label":`...)
+		buf = appendString(buf, c.Label)
+		buf = append(buf, `,"datatype":`...)
+		buf = appendString(buf, encodeType(c.Type))
+		buf = append(buf, `,"group":`...)
+		buf = strconv.AppendBool(buf, key.HasCol(c.Label))
+		buf = append(buf, '}')
+	}
+	return append(buf, ']')
+}
+
+// appendRows appends the rows of cr to the data of a table, first reports whether they are its first rows.
+func appendRows(buf []byte, cr query.ColReader, first bool) []byte {
+	for i := 0; i < cr.Len(); i++ {
+		if !first || i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, '[')
+		for j := range cr.Cols() {
+			if j > 0 {
+				buf = append(buf, ',')
+			}
+			buf = appendValue(buf, execute.ValueForRow(i, j, cr))
+		}
+		buf = append(buf, ']')
+	}
+	return buf
+}
+
+func appendString(buf []byte, s string) []byte {
+	// Marshaling a string cannot fail.
+	b, _ := json.Marshal(s)
+	return append(buf, b...)
+}
+
+func appendValue(buf []byte, v values.Value) []byte {
+	switch v.Type() {
+	case semantic.Nil:
+		return append(buf, "null"...)
+	case semantic.Bool:
+		return strconv.AppendBool(buf, v.Bool())
+	case semantic.Int:
+		return strconv.AppendInt(buf, v.Int(), 10)
+	case semantic.UInt:
+		return strconv.AppendUint(buf, v.UInt(), 10)
+	case semantic.Float:
+		f := v.Float()
+		switch {
+		case math.IsNaN(f):
+			return append(buf, `"NaN"`...)
+		case math.IsInf(f, 1):
+			return append(buf, `"+Inf"`...)
+		case math.IsInf(f, -1):
+			return append(buf, `"-Inf"`...)
+		}
+		return strconv.AppendFloat(buf, f, 'g', -1, 64)
+	case semantic.String:
+		return appendString(buf, v.Str())
+	case semantic.Time:
+		return appendString(buf, v.Time().Time().Format(time.RFC3339Nano))
+	default:
+		panic(fmt.Errorf("unexpected value type %v", v.Type()))
+	}
+}
+
+func encodeType(typ query.DataType) string {
+	switch typ {
+	case query.TBool:
+		return boolDatatype
+	case query.TInt:
+		return intDatatype
+	case query.TUInt:
+		return uintDatatype
+	case query.TFloat:
+		return floatDatatype
+	case query.TString:
+		return stringDatatype
+	case query.TTime:
+		return timeDatatype
+	default:
+		execute.PanicUnknownType(typ)
+		return ""
+	}
+}
+
+// MultiResultEncoder encodes multiple results, one per line.
+type MultiResultEncoder struct {
+	e *ResultEncoder
+}
+
+// NewMultiResultEncoder creates a new MultiResultEncoder.
+func NewMultiResultEncoder() *MultiResultEncoder {
+	return &MultiResultEncoder{
+		e: NewResultEncoder(),
+	}
+}
+
+type flusher interface {
+	Flush()
+}
+
+// Encode writes the results to w, flushing it after each result if it is a flusher.
+// An error of the query ends the encoding and is written with the result it ended, or on its own.
+func (e *MultiResultEncoder) Encode(w io.Writer, results query.ResultIterator) (int64, error) {
+	wc := &iocounter.Writer{Writer: w}
+	for results.More() {
+		if err := e.e.encode(wc, results.Next()); err != nil {
+			if query.IsEncoderError(err) {
+				return wc.Count(), err
+			}
+			// The error has been encoded with its result.
+			results.Cancel()
+			return wc.Count(), nil
+		}
+		if f, ok := w.(flusher); ok {
+			f.Flush()
+		}
+	}
+	if err := results.Err(); err != nil {
+		err := e.e.EncodeError(wc, err)
+		return wc.Count(), err
+	}
+	return wc.Count(), nil
+}
+
+// ResultDecoder decodes a single result encoded in JSON.
+type ResultDecoder struct{}
+
+// NewResultDecoder creates a new ResultDecoder.
+func NewResultDecoder() *ResultDecoder {
+	return new(ResultDecoder)
+}
+
+func (d *ResultDecoder) Decode(r io.Reader) (query.Result, error) {
+	var er encodedResult
+	if err := json.NewDecoder(r).Decode(&er); err != nil {
+		return nil, err
+	}
+	return newResult(er)
+}
+
+// MultiResultDecoder reads the results encoded by a MultiResultEncoder.
+// Each result is read into memory when the iterator advances to it.
+type MultiResultDecoder struct{}
+
+// NewMultiResultDecoder creates a new MultiResultDecoder.
+func NewMultiResultDecoder() *MultiResultDecoder {
+	return new(MultiResultDecoder)
+}
+
+func (d *MultiResultDecoder) Decode(r io.ReadCloser) (query.ResultIterator, error) {
+	return &resultIterator{
+		r:   r,
+		dec: json.NewDecoder(r),
+	}, nil
+}
+
+// resultIterator iterates through the results encoded in r.
+type resultIterator struct {
+	r    io.ReadCloser
+	dec  *json.Decoder
+	next query.Result
+	err  error
+
+	canceled bool
+}
+
+func (r *resultIterator) More() bool {
+	if r.canceled {
+		return false
+	}
+	var er encodedResult
+	if err := r.dec.Decode(&er); err != nil {
+		if err != io.EOF {
+			r.err = err
+		}
+		r.Cancel()
+		return false
+	}
+	r.next, r.err = newResult(er)
+	if r.err != nil {
+		r.Cancel()
+		return false
+	}
+	return true
+}
+
+func (r *resultIterator) Next() query.Result {
+	return r.next
+}
+
+func (r *resultIterator) Cancel() {
+	if r.canceled {
+		return
+	}
+	r.canceled = true
+	r.r.Close()
+}
+
+func (r *resultIterator) Err() error {
+	return r.err
+}
+
+// result is a decoded result, with the error that ended it if any.
+type result struct {
+	name   string
+	tables []query.Table
+	err    error
+}
+
+func newResult(er encodedResult) (*result, error) {
+	if er.Result == nil {
+		if er.Error != "" {
+			return nil, errors.New(er.Error)
+		}
+		return nil, errors.New("json decoder error: missing result name")
+	}
+	r := &result{
+		name:   *er.Result,
+		tables: make([]query.Table, len(er.Tables)),
+	}
+	alloc := &execute.Allocator{Limit: math.MaxInt64}
+	for i, et := range er.Tables {
+		tbl, err := newTable(et, alloc)
+		if err != nil {
+			return nil, errors.Wrapf(err, "json decoder error: table %d of result %q", et.Table, r.name)
+		}
+		r.tables[i] = tbl
+	}
+	if er.Error != "" {
+		r.err = errors.New(er.Error)
+	}
+	return r, nil
+}
+
+func (r *result) Name() string {
+	return r.name
+}
+
+func (r *result) Tables() query.TableIterator {
+	return r
+}
+
+// Do calls f with each table, and returns the error that ended the result once all tables are processed.
+func (r *result) Do(f func(query.Table) error) error {
+	for _, tbl := range r.tables {
+		if err := f(tbl); err != nil {
+			return err
+		}
+	}
+	return r.err
+}
+
+func newTable(et encodedTable, alloc *execute.Allocator) (query.Table, error) {
+	cols := make([]query.ColMeta, len(et.Columns))
+	var keyCols []query.ColMeta
+	var keyValues []values.Value
+	for j, c := range et.Columns {
+		typ, err := decodeType(c.Datatype)
+		if err != nil {
+			return nil, err
+		}
+		cols[j] = query.ColMeta{Label: c.Label, Type: typ}
+		if !c.Group {
+			continue
+		}
+		raw, ok := et.Key[c.Label]
+		if !ok {
+			return nil, fmt.Errorf("missing group key value of column %q", c.Label)
+		}
+		v, err := decodeValue(raw, typ)
+		if err != nil {
+			return nil, errors.Wrapf(err, "column %q", c.Label)
+		}
+		keyCols = append(keyCols, cols[j])
+		keyValues = append(keyValues, v)
+	}
+
+	builder := execute.NewColListTableBuilder(execute.NewGroupKey(keyCols, keyValues), alloc)
+	for _, c := range cols {
+		builder.AddCol(c)
+	}
+	for i, row := range et.Data {
+		if len(row) != len(cols) {
+			return nil, fmt.Errorf("row %d has %d values, expected %d", i, len(row), len(cols))
+		}
+		for j, raw := range row {
+			v, err := decodeValue(raw, cols[j].Type)
+			if err != nil {
+				return nil, errors.Wrapf(err, "column %q", cols[j].Label)
+			}
+			builder.AppendValue(j, v)
+		}
+	}
+	return builder.Table()
+}
+
+func decodeType(datatype string) (query.DataType, error) {
+	switch datatype {
+	case boolDatatype:
+		return query.TBool, nil
+	case intDatatype:
+		return query.TInt, nil
+	case uintDatatype:
+		return query.TUInt, nil
+	case floatDatatype:
+		return query.TFloat, nil
+	case stringDatatype:
+		return query.TString, nil
+	case timeDatatype:
+		return query.TTime, nil
+	default:
+		return query.TInvalid, fmt.Errorf("unsupported data type %q", datatype)
+	}
+}
+
+// decodeValue decodes a value of the data type, null is decoded as values.Null.
+func decodeValue(raw json.RawMessage, typ query.DataType) (values.Value, error) {
+	if string(raw) == "null" {
+		return values.Null, nil
+	}
+	switch typ {
+	case query.TBool:
+		var b bool
+		if err := json.Unmarshal(raw, &b); err != nil {
+			return nil, err
+		}
+		return values.NewBoolValue(b), nil
+	case query.TInt:
+		i, err := strconv.ParseInt(string(raw), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return values.NewIntValue(i), nil
+	case query.TUInt:
+		u, err := strconv.ParseUint(string(raw), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return values.NewUIntValue(u), nil
+	case query.TFloat:
+		s := string(raw)
+		if len(raw) > 0 && raw[0] == '"' {
+			// Floats that are not numbers are strings.
+			if err := json.Unmarshal(raw, &s); err != nil {
+				return nil, err
+			}
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, err
+		}
+		return values.NewFloatValue(f), nil
+	case query.TString:
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, err
+		}
+		return values.NewStringValue(s), nil
+	case query.TTime:
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, err
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, err
+		}
+		return values.NewTimeValue(values.ConvertTime(t)), nil
+	default:
+		return nil, fmt.Errorf("unsupported data type %v", typ)
+	}
+}
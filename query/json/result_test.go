@@ -0,0 +1,407 @@
+package json_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/execute/executetest"
+	"github.com/EMCECS/influx/query/json"
+	"github.com/EMCECS/influx/query/values"
+	"github.com/andreyvit/diff"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+type TestCase struct {
+	name    string
+	encoded string
+	result  *executetest.Result
+}
+
+var symetricalTestCases = []TestCase{
+	{
+		name: "single table",
+		encoded: `{"result":"_result","tables":[{"table":0,"key":{"_start":"2018-04-17T00:00:00Z","_stop":"2018-04-17T00:05:00Z","_measurement":"cpu","host":"A"},"columns":[{"label":"_start","datatype":"dateTime","group":true},{"label":"_stop","datatype":"dateTime","group":true},{"label":"_time","datatype":"dateTime","group":false},{"label":"_measurement","datatype":"string","group":true},{"label":"host","datatype":"string","group":true},{"label":"_value","datatype":"double","group":false}],"data":[["2018-04-17T00:00:00Z","2018-04-17T00:05:00Z","2018-04-17T00:00:00Z","cpu","A",42],["2018-04-17T00:00:00Z","2018-04-17T00:05:00Z","2018-04-17T00:00:01Z","cpu","A",43.5]]}]}
+`,
+		result: &executetest.Result{
+			Nm: "_result",
+			Tbls: []*executetest.Table{{
+				KeyCols: []string{"_start", "_stop", "_measurement", "host"},
+				ColMeta: []query.ColMeta{
+					{Label: "_start", Type: query.TTime},
+					{Label: "_stop", Type: query.TTime},
+					{Label: "_time", Type: query.TTime},
+					{Label: "_measurement", Type: query.TString},
+					{Label: "host", Type: query.TString},
+					{Label: "_value", Type: query.TFloat},
+				},
+				Data: [][]interface{}{
+					{
+						values.ConvertTime(time.Date(2018, 4, 17, 0, 0, 0, 0, time.UTC)),
+						values.ConvertTime(time.Date(2018, 4, 17, 0, 5, 0, 0, time.UTC)),
+						values.ConvertTime(time.Date(2018, 4, 17, 0, 0, 0, 0, time.UTC)),
+						"cpu",
+						"A",
+						42.0,
+					},
+					{
+						values.ConvertTime(time.Date(2018, 4, 17, 0, 0, 0, 0, time.UTC)),
+						values.ConvertTime(time.Date(2018, 4, 17, 0, 5, 0, 0, time.UTC)),
+						values.ConvertTime(time.Date(2018, 4, 17, 0, 0, 1, 0, time.UTC)),
+						"cpu",
+						"A",
+						43.5,
+					},
+				},
+			}},
+		},
+	},
+	{
+		name: "multiple tables",
+		encoded: `{"result":"_result","tables":[{"table":0,"key":{"host":"A"},"columns":[{"label":"host","datatype":"string","group":true},{"label":"_value","datatype":"long","group":false}],"data":[["A",1],["A",2]]},{"table":1,"key":{"host":"B"},"columns":[{"label":"host","datatype":"string","group":true},{"label":"_value","datatype":"long","group":false}],"data":[["B",3]]}]}
+`,
+		result: &executetest.Result{
+			Nm: "_result",
+			Tbls: []*executetest.Table{
+				{
+					KeyCols: []string{"host"},
+					ColMeta: []query.ColMeta{
+						{Label: "host", Type: query.TString},
+						{Label: "_value", Type: query.TInt},
+					},
+					Data: [][]interface{}{
+						{"A", int64(1)},
+						{"A", int64(2)},
+					},
+				},
+				{
+					KeyCols: []string{"host"},
+					ColMeta: []query.ColMeta{
+						{Label: "host", Type: query.TString},
+						{Label: "_value", Type: query.TInt},
+					},
+					Data: [][]interface{}{
+						{"B", int64(3)},
+					},
+				},
+			},
+		},
+	},
+	{
+		name: "empty table",
+		encoded: `{"result":"_result","tables":[{"table":0,"key":{"host":"A"},"columns":[{"label":"host","datatype":"string","group":true},{"label":"_value","datatype":"boolean","group":false}],"data":[]}]}
+`,
+		result: &executetest.Result{
+			Nm: "_result",
+			Tbls: []*executetest.Table{{
+				KeyCols:   []string{"host"},
+				KeyValues: []interface{}{"A"},
+				ColMeta: []query.ColMeta{
+					{Label: "host", Type: query.TString},
+					{Label: "_value", Type: query.TBool},
+				},
+			}},
+		},
+	},
+	{
+		name: "all types with nulls",
+		encoded: `{"result":"_result","tables":[{"table":0,"key":{},"columns":[{"label":"b","datatype":"boolean","group":false},{"label":"i","datatype":"long","group":false},{"label":"u","datatype":"unsignedLong","group":false},{"label":"f","datatype":"double","group":false},{"label":"s","datatype":"string","group":false},{"label":"t","datatype":"dateTime","group":false}],"data":[[true,-9223372036854775808,18446744073709551615,1e-07,"a \"quoted\"\nstring","2018-04-17T00:00:00.000000001Z"],[null,null,null,null,null,null]]}]}
+`,
+		result: &executetest.Result{
+			Nm: "_result",
+			Tbls: []*executetest.Table{{
+				ColMeta: []query.ColMeta{
+					{Label: "b", Type: query.TBool},
+					{Label: "i", Type: query.TInt},
+					{Label: "u", Type: query.TUInt},
+					{Label: "f", Type: query.TFloat},
+					{Label: "s", Type: query.TString},
+					{Label: "t", Type: query.TTime},
+				},
+				Data: [][]interface{}{
+					{
+						true,
+						int64(math.MinInt64),
+						uint64(math.MaxUint64),
+						1e-7,
+						"a \"quoted\"\nstring",
+						values.ConvertTime(time.Date(2018, 4, 17, 0, 0, 0, 1, time.UTC)),
+					},
+					{nil, nil, nil, nil, nil, nil},
+				},
+			}},
+		},
+	},
+	{
+		name: "infinite floats",
+		encoded: `{"result":"_result","tables":[{"table":0,"key":{},"columns":[{"label":"_value","datatype":"double","group":false}],"data":[["+Inf"],["-Inf"]]}]}
+`,
+		result: &executetest.Result{
+			Nm: "_result",
+			Tbls: []*executetest.Table{{
+				ColMeta: []query.ColMeta{
+					{Label: "_value", Type: query.TFloat},
+				},
+				Data: [][]interface{}{
+					{math.Inf(1)},
+					{math.Inf(-1)},
+				},
+			}},
+		},
+	},
+}
+
+func TestResultDecoder(t *testing.T) {
+	for _, tc := range symetricalTestCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := json.NewResultDecoder().Decode(bytes.NewReader([]byte(tc.encoded)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := convertResult(result)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got.Normalize()
+			tc.result.Normalize()
+
+			if !cmp.Equal(got, tc.result) {
+				t.Error("unexpected results -want/+got", cmp.Diff(tc.result, got))
+			}
+		})
+	}
+}
+
+func TestResultEncoder(t *testing.T) {
+	for _, tc := range symetricalTestCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var got bytes.Buffer
+			n, err := json.NewResultEncoder().Encode(&got, tc.result)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if g, w := got.String(), tc.encoded; g != w {
+				t.Errorf("unexpected encoding -want/+got:\n%s", diff.LineDiff(w, g))
+			}
+			if g, w := n, int64(len(tc.encoded)); g != w {
+				t.Errorf("unexpected encoding count -want/+got:\n%s", cmp.Diff(w, g))
+			}
+		})
+	}
+}
+
+func TestResultEncoder_NaN(t *testing.T) {
+	result := &executetest.Result{
+		Nm: "_result",
+		Tbls: []*executetest.Table{{
+			ColMeta: []query.ColMeta{{Label: "_value", Type: query.TFloat}},
+			Data:    [][]interface{}{{math.NaN()}},
+		}},
+	}
+	want := `{"result":"_result","tables":[{"table":0,"key":{},"columns":[{"label":"_value","datatype":"double","group":false}],"data":[["NaN"]]}]}
+`
+	var buf bytes.Buffer
+	if _, err := json.NewResultEncoder().Encode(&buf, result); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Fatalf("unexpected encoding -want/+got:\n%s", diff.LineDiff(want, got))
+	}
+
+	decoded, err := json.NewResultDecoder().Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := convertResult(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := got.Tbls[0].Data[0][0].(float64); !math.IsNaN(f) {
+		t.Fatalf("unexpected value: got %v want NaN", f)
+	}
+}
+
+var multiResultTables = []*executetest.Table{{
+	KeyCols: []string{"host"},
+	ColMeta: []query.ColMeta{
+		{Label: "host", Type: query.TString},
+		{Label: "_value", Type: query.TInt},
+	},
+	Data: [][]interface{}{
+		{"A", int64(1)},
+	},
+}}
+
+func TestMultiResultEncoder(t *testing.T) {
+	testCases := []struct {
+		name    string
+		results query.ResultIterator
+		encoded string
+	}{
+		{
+			name: "multiple results",
+			results: query.NewSliceResultIterator([]query.Result{
+				&executetest.Result{Nm: "a", Tbls: multiResultTables},
+				&executetest.Result{Nm: "b", Tbls: multiResultTables},
+			}),
+			encoded: `{"result":"a","tables":[{"table":0,"key":{"host":"A"},"columns":[{"label":"host","datatype":"string","group":true},{"label":"_value","datatype":"long","group":false}],"data":[["A",1]]}]}
+{"result":"b","tables":[{"table":0,"key":{"host":"A"},"columns":[{"label":"host","datatype":"string","group":true},{"label":"_value","datatype":"long","group":false}],"data":[["A",1]]}]}
+`,
+		},
+		{
+			name: "error in result",
+			results: query.NewSliceResultIterator([]query.Result{
+				&executetest.Result{Nm: "a", Tbls: multiResultTables, Err: errors.New("execution failed")},
+				&executetest.Result{Nm: "b", Tbls: multiResultTables},
+			}),
+			encoded: `{"result":"a","tables":[],"error":"execution failed"}
+`,
+		},
+		{
+			name:    "error in query",
+			results: errorResultIterator{Error: errors.New("query failed")},
+			encoded: `{"error":"query failed"}
+`,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var got bytes.Buffer
+			n, err := json.NewMultiResultEncoder().Encode(&got, tc.results)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if g, w := got.String(), tc.encoded; g != w {
+				t.Errorf("unexpected encoding -want/+got:\n%s", diff.LineDiff(w, g))
+			}
+			if g, w := n, int64(len(tc.encoded)); g != w {
+				t.Errorf("unexpected encoding count -want/+got:\n%s", cmp.Diff(w, g))
+			}
+		})
+	}
+}
+
+func TestMultiResultDecoder(t *testing.T) {
+	testCases := []struct {
+		name    string
+		encoded string
+		results []*executetest.Result
+		err     error
+	}{
+		{
+			name: "multiple results",
+			encoded: `{"result":"a","tables":[{"table":0,"key":{"host":"A"},"columns":[{"label":"host","datatype":"string","group":true},{"label":"_value","datatype":"long","group":false}],"data":[["A",1]]}]}
+{"result":"b","tables":[{"table":0,"key":{"host":"A"},"columns":[{"label":"host","datatype":"string","group":true},{"label":"_value","datatype":"long","group":false}],"data":[["A",1]]}]}
+`,
+			results: []*executetest.Result{
+				{Nm: "a", Tbls: multiResultTables},
+				{Nm: "b", Tbls: multiResultTables},
+			},
+		},
+		{
+			name: "error in result",
+			encoded: `{"result":"a","tables":[{"table":0,"key":{"host":"A"},"columns":[{"label":"host","datatype":"string","group":true},{"label":"_value","datatype":"long","group":false}],"data":[["A",1]]}],"error":"execution failed"}
+`,
+			err: errors.New("execution failed"),
+		},
+		{
+			name: "error in query",
+			encoded: `{"error":"query failed"}
+`,
+			err: errors.New("query failed"),
+		},
+		{
+			name: "invalid row",
+			encoded: `{"result":"a","tables":[{"table":0,"key":{},"columns":[{"label":"_value","datatype":"long","group":false}],"data":[[1.5]]}]}
+`,
+			err: errors.New(`json decoder error: table 0 of result "a": column "_value": strconv.ParseInt: parsing "1.5": invalid syntax`),
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			results, err := json.NewMultiResultDecoder().Decode(ioutil.NopCloser(bytes.NewReader([]byte(tc.encoded))))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer results.Cancel()
+
+			var got []*executetest.Result
+			for results.More() {
+				r, err := convertResult(results.Next())
+				if err != nil {
+					if tc.err == nil {
+						t.Fatal(err)
+					}
+					if got, want := err.Error(), tc.err.Error(); got != want {
+						t.Fatalf("unexpected error: got %q want %q", got, want)
+					}
+					return
+				}
+				r.Normalize()
+				got = append(got, r)
+			}
+			if err := results.Err(); err != nil || tc.err != nil {
+				if err == nil || tc.err == nil {
+					t.Fatalf("unexpected error: got %v want %v", err, tc.err)
+				}
+				if got, want := err.Error(), tc.err.Error(); got != want {
+					t.Fatalf("unexpected error: got %q want %q", got, want)
+				}
+				return
+			}
+			for _, r := range tc.results {
+				r.Normalize()
+			}
+			if !cmp.Equal(got, tc.results) {
+				t.Error("unexpected results -want/+got", cmp.Diff(tc.results, got))
+			}
+		})
+	}
+}
+
+func convertResult(result query.Result) (*executetest.Result, error) {
+	got := &executetest.Result{
+		Nm: result.Name(),
+	}
+	if err := result.Tables().Do(func(tbl query.Table) error {
+		cb, err := executetest.ConvertTable(tbl)
+		if err != nil {
+			return err
+		}
+		got.Tbls = append(got.Tbls, cb)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return got, nil
+}
+
+type errorResultIterator struct {
+	Error error
+}
+
+func (r errorResultIterator) More() bool {
+	return false
+}
+
+func (r errorResultIterator) Next() query.Result {
+	panic("no results")
+}
+
+func (r errorResultIterator) Cancel() {
+}
+
+func (r errorResultIterator) Err() error {
+	return r.Error
+}
@@ -0,0 +1,33 @@
+package lineprotocol
+
+import (
+	"net/http"
+
+	"github.com/EMCECS/influx/query"
+)
+
+const DialectType = "lp"
+
+// AddDialectMappings adds the line protocol specific dialect mappings.
+func AddDialectMappings(mappings query.DialectMappings) error {
+	return mappings.Add(DialectType, func() query.Dialect {
+		return new(Dialect)
+	})
+}
+
+// Dialect describes the output format of queries as line protocol.
+type Dialect struct {
+	ResultEncoderConfig
+}
+
+func (d Dialect) SetHeaders(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("Transfer-Encoding", "chunked")
+}
+
+func (d Dialect) Encoder() query.MultiResultEncoder {
+	return NewMultiResultEncoder(d.ResultEncoderConfig)
+}
+func (d Dialect) DialectType() query.DialectType {
+	return DialectType
+}
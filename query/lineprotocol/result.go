@@ -0,0 +1,338 @@
+// Package lineprotocol contains the line protocol result encoder.
+//
+// Each row of a table is written as a point, so that results can be written back to a server.
+// A table with _field and _value columns has one field per row, named by _field,
+// otherwise the table is pivoted and each of its columns that is not a tag is a field:
+//
+//	cpu,host=A usage_idle=42.5,usage_user=3.5 1523923200000000000
+//
+// The measurement is the _measurement column, or the configured measurement when there is none,
+// and the timestamp is the _time column.
+// The tags are the configured tag columns, or the group key columns other than _start, _stop and _field.
+// Null values, as well as floats that are not numbers, are left out of their point.
+//
+// Line protocol has no notion of results, the points of all results are written one after the other.
+// An error of the query is written as a comment, which is ignored when the points are written.
+package lineprotocol
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/execute"
+	"github.com/EMCECS/influx/query/iocounter"
+	"github.com/EMCECS/influx/query/semantic"
+	"github.com/EMCECS/influx/query/values"
+	"github.com/influxdata/line-protocol"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ContentType is the media type of the line protocol dialect.
+	ContentType = "text/plain; charset=utf-8"
+
+	measurementColLabel = "_measurement"
+	fieldColLabel       = "_field"
+)
+
+// ResultEncoderConfig are options that can be specified on the ResultEncoder.
+type ResultEncoderConfig struct {
+	// TagColumns is the list of the labels of the columns written as tags.
+	// When empty, the group key columns other than _start, _stop and _field are the tags.
+	TagColumns []string `json:"tagColumns,omitempty"`
+
+	// Measurement is the measurement of the points of tables without a _measurement column.
+	Measurement string `json:"measurement,omitempty"`
+}
+
+// ResultEncoder encodes the rows of a result as line protocol points.
+type ResultEncoder struct {
+	c ResultEncoderConfig
+}
+
+// NewResultEncoder creates a new ResultEncoder.
+func NewResultEncoder(c ResultEncoderConfig) *ResultEncoder {
+	return &ResultEncoder{
+		c: c,
+	}
+}
+
+type lineProtocolEncoderError struct {
+	error
+}
+
+func (e *lineProtocolEncoderError) Error() string {
+	return e.error.Error()
+}
+
+func (e *lineProtocolEncoderError) IsEncoderError() bool {
+	return true
+}
+
+func wrapEncodingError(err error) error {
+	return &lineProtocolEncoderError{errors.Wrap(err, "failed to encode line protocol")}
+}
+
+// Encode writes the points of the rows of the result to w.
+func (e *ResultEncoder) Encode(w io.Writer, result query.Result) (int64, error) {
+	wc := &iocounter.Writer{Writer: w}
+	bw := bufio.NewWriter(wc)
+	enc := protocol.NewEncoder(bw)
+	enc.SetFieldTypeSupport(protocol.UintSupport)
+
+	err := result.Tables().Do(func(tbl query.Table) error {
+		return e.encodeTable(enc, bw, tbl)
+	})
+	if ferr := bw.Flush(); ferr != nil && err == nil {
+		err = wrapEncodingError(ferr)
+	}
+	return wc.Count(), err
+}
+
+func (e *ResultEncoder) encodeTable(enc *protocol.Encoder, bw *bufio.Writer, tbl query.Table) error {
+	s, err := e.newSchema(tbl.Key(), tbl.Cols())
+	if err != nil {
+		return wrapEncodingError(err)
+	}
+	p := s.newPoint()
+	return tbl.Do(func(cr query.ColReader) error {
+		for i := 0; i < cr.Len(); i++ {
+			s.read(p, cr, i)
+			if len(p.fields) == 0 {
+				continue
+			}
+			if p.name == "" {
+				return wrapEncodingError(fmt.Errorf("missing measurement in table %v", tbl.Key()))
+			}
+			if _, err := enc.Encode(p); err != nil {
+				return wrapEncodingError(err)
+			}
+		}
+		if err := bw.Flush(); err != nil {
+			return wrapEncodingError(err)
+		}
+		return nil
+	})
+}
+
+// EncodeError writes the error of a query as a comment.
+func (e *ResultEncoder) EncodeError(w io.Writer, err error) error {
+	msg := strings.Replace(err.Error(), "\n", " ", -1)
+	_, werr := io.WriteString(w, "# error: "+msg+"\n")
+	return werr
+}
+
+// schema describes how the columns of a table make up the points of its rows.
+type schema struct {
+	measurement string
+	// measurementIdx is the index of the _measurement column, or -1.
+	measurementIdx int
+	// timeIdx is the index of the _time column, or -1.
+	timeIdx int
+	// fieldIdx is the index of the _field column, or -1 if the table is pivoted.
+	fieldIdx int
+	// tags are the indexes of the tag columns, sorted by label.
+	tags []int
+	// fields are the indexes of the field columns.
+	fields []int
+	cols   []query.ColMeta
+}
+
+func (e *ResultEncoder) newSchema(key query.GroupKey, cols []query.ColMeta) (*schema, error) {
+	s := &schema{
+		measurement:    e.c.Measurement,
+		measurementIdx: execute.ColIdx(measurementColLabel, cols),
+		timeIdx:        execute.ColIdx(execute.DefaultTimeColLabel, cols),
+		fieldIdx:       execute.ColIdx(fieldColLabel, cols),
+		cols:           cols,
+	}
+	if s.measurementIdx < 0 && s.measurement == "" {
+		return nil, fmt.Errorf("table %v has no %s column and no measurement is configured", key, measurementColLabel)
+	}
+	if s.measurementIdx >= 0 && cols[s.measurementIdx].Type != query.TString {
+		return nil, fmt.Errorf("column %s is not of type %v", measurementColLabel, query.TString)
+	}
+	if s.timeIdx >= 0 && cols[s.timeIdx].Type != query.TTime {
+		return nil, fmt.Errorf("column %s is not of type %v", execute.DefaultTimeColLabel, query.TTime)
+	}
+	valueIdx := execute.ColIdx(execute.DefaultValueColLabel, cols)
+	if s.fieldIdx >= 0 && valueIdx < 0 {
+		s.fieldIdx = -1
+	}
+	if s.fieldIdx >= 0 && cols[s.fieldIdx].Type != query.TString {
+		return nil, fmt.Errorf("column %s is not of type %v", fieldColLabel, query.TString)
+	}
+
+	for j, c := range cols {
+		switch {
+		case j == s.measurementIdx, j == s.timeIdx, j == s.fieldIdx:
+		case e.isTag(key, c.Label):
+			s.tags = append(s.tags, j)
+		case s.fieldIdx >= 0:
+			if j == valueIdx {
+				s.fields = append(s.fields, j)
+			}
+		case c.Label != execute.DefaultStartColLabel && c.Label != execute.DefaultStopColLabel:
+			s.fields = append(s.fields, j)
+		}
+	}
+	sort.Slice(s.tags, func(i, j int) bool {
+		return cols[s.tags[i]].Label < cols[s.tags[j]].Label
+	})
+	return s, nil
+}
+
+func (e *ResultEncoder) isTag(key query.GroupKey, label string) bool {
+	if len(e.c.TagColumns) > 0 {
+		for _, t := range e.c.TagColumns {
+			if t == label {
+				return true
+			}
+		}
+		return false
+	}
+	switch label {
+	case execute.DefaultStartColLabel, execute.DefaultStopColLabel, fieldColLabel:
+		return false
+	}
+	return key.HasCol(label)
+}
+
+// point is a protocol.Metric whose tags and fields are reused for every row of a table.
+type point struct {
+	name   string
+	t      time.Time
+	tags   []*protocol.Tag
+	fields []*protocol.Field
+	// fieldBuf holds the fields of the row, the fields with a null value are left out of fields.
+	fieldBuf []protocol.Field
+}
+
+func (p *point) Name() string {
+	return p.name
+}
+
+func (p *point) TagList() []*protocol.Tag {
+	return p.tags
+}
+
+func (p *point) FieldList() []*protocol.Field {
+	return p.fields
+}
+
+func (p *point) Time() time.Time {
+	return p.t
+}
+
+func (s *schema) newPoint() *point {
+	p := &point{
+		name:     s.measurement,
+		tags:     make([]*protocol.Tag, len(s.tags)),
+		fieldBuf: make([]protocol.Field, len(s.fields)),
+	}
+	for i, j := range s.tags {
+		p.tags[i] = &protocol.Tag{Key: s.cols[j].Label}
+	}
+	for i, j := range s.fields {
+		p.fieldBuf[i].Key = s.cols[j].Label
+	}
+	return p
+}
+
+// read sets the point to the values of the row i.
+// A point without fields is not written.
+func (s *schema) read(p *point, cr query.ColReader, i int) {
+	if s.measurementIdx >= 0 {
+		p.name = ""
+		if v := execute.ValueForRow(i, s.measurementIdx, cr); v.Type() != semantic.Nil {
+			p.name = v.Str()
+		}
+	}
+	p.t = time.Time{}
+	if s.timeIdx >= 0 {
+		if v := execute.ValueForRow(i, s.timeIdx, cr); v.Type() != semantic.Nil {
+			p.t = v.Time().Time()
+		}
+	}
+	// Tags with an empty value are not written.
+	for k, j := range s.tags {
+		p.tags[k].Value = formatTag(execute.ValueForRow(i, j, cr))
+	}
+
+	p.fields = p.fields[:0]
+	if s.fieldIdx >= 0 {
+		if len(s.fields) == 0 {
+			return
+		}
+		v := execute.ValueForRow(i, s.fieldIdx, cr)
+		if v.Type() == semantic.Nil || v.Str() == "" {
+			return
+		}
+		p.fieldBuf[0].Key = v.Str()
+	}
+	for k, j := range s.fields {
+		v := fieldValue(execute.ValueForRow(i, j, cr))
+		if v == nil {
+			continue
+		}
+		p.fieldBuf[k].Value = v
+		p.fields = append(p.fields, &p.fieldBuf[k])
+	}
+}
+
+func formatTag(v values.Value) string {
+	switch v.Type() {
+	case semantic.Nil:
+		return ""
+	case semantic.Bool:
+		return strconv.FormatBool(v.Bool())
+	case semantic.Int:
+		return strconv.FormatInt(v.Int(), 10)
+	case semantic.UInt:
+		return strconv.FormatUint(v.UInt(), 10)
+	case semantic.Float:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	case semantic.String:
+		return v.Str()
+	case semantic.Time:
+		return v.Time().Time().Format(time.RFC3339Nano)
+	default:
+		panic(fmt.Errorf("unexpected value type %v", v.Type()))
+	}
+}
+
+// fieldValue returns the value of a field, or nil if the value is null.
+// Times are written as integer nanoseconds.
+func fieldValue(v values.Value) interface{} {
+	switch v.Type() {
+	case semantic.Nil:
+		return nil
+	case semantic.Bool:
+		return v.Bool()
+	case semantic.Int:
+		return v.Int()
+	case semantic.UInt:
+		return v.UInt()
+	case semantic.Float:
+		return v.Float()
+	case semantic.String:
+		return v.Str()
+	case semantic.Time:
+		return int64(v.Time())
+	default:
+		panic(fmt.Errorf("unexpected value type %v", v.Type()))
+	}
+}
+
+// NewMultiResultEncoder creates a MultiResultEncoder that writes the points of all results one after the other.
+func NewMultiResultEncoder(c ResultEncoderConfig) query.MultiResultEncoder {
+	return &query.DelimitedMultiResultEncoder{
+		Encoder: NewResultEncoder(c),
+	}
+}
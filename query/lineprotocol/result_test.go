@@ -0,0 +1,241 @@
+package lineprotocol_test
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/execute/executetest"
+	"github.com/EMCECS/influx/query/lineprotocol"
+	"github.com/EMCECS/influx/query/values"
+	"github.com/andreyvit/diff"
+	"github.com/pkg/errors"
+)
+
+var (
+	start = values.ConvertTime(time.Date(2018, 4, 17, 0, 0, 0, 0, time.UTC))
+	stop  = values.ConvertTime(time.Date(2018, 4, 17, 0, 0, 1, 0, time.UTC))
+	t0    = values.ConvertTime(time.Date(2018, 4, 17, 0, 0, 0, 0, time.UTC))
+	t1    = values.ConvertTime(time.Date(2018, 4, 17, 0, 0, 0, 500000000, time.UTC))
+)
+
+func TestResultEncoder(t *testing.T) {
+	testCases := []struct {
+		name    string
+		config  lineprotocol.ResultEncoderConfig
+		result  *executetest.Result
+		encoded string
+	}{
+		{
+			name: "fields",
+			result: &executetest.Result{Nm: "_result", Tbls: []*executetest.Table{
+				{
+					KeyCols: []string{"_start", "_stop", "_measurement", "_field", "host"},
+					ColMeta: []query.ColMeta{
+						{Label: "_start", Type: query.TTime},
+						{Label: "_stop", Type: query.TTime},
+						{Label: "_time", Type: query.TTime},
+						{Label: "_measurement", Type: query.TString},
+						{Label: "_field", Type: query.TString},
+						{Label: "host", Type: query.TString},
+						{Label: "_value", Type: query.TFloat},
+					},
+					Data: [][]interface{}{
+						{start, stop, t0, "cpu", "usage_idle", "A", 42.5},
+						{start, stop, t1, "cpu", "usage_idle", "A", 43.0},
+					},
+				},
+				{
+					KeyCols: []string{"_start", "_stop", "_measurement", "_field", "host"},
+					ColMeta: []query.ColMeta{
+						{Label: "_start", Type: query.TTime},
+						{Label: "_stop", Type: query.TTime},
+						{Label: "_time", Type: query.TTime},
+						{Label: "_measurement", Type: query.TString},
+						{Label: "_field", Type: query.TString},
+						{Label: "host", Type: query.TString},
+						{Label: "_value", Type: query.TInt},
+					},
+					Data: [][]interface{}{
+						{start, stop, t0, "cpu", "count", "A", int64(7)},
+					},
+				},
+			}},
+			encoded: `cpu,host=A usage_idle=42.5 1523923200000000000
+cpu,host=A usage_idle=43 1523923200500000000
+cpu,host=A count=7i 1523923200000000000
+`,
+		},
+		{
+			name: "pivoted",
+			result: &executetest.Result{Nm: "_result", Tbls: []*executetest.Table{{
+				KeyCols: []string{"_measurement", "region", "host"},
+				ColMeta: []query.ColMeta{
+					{Label: "_time", Type: query.TTime},
+					{Label: "_measurement", Type: query.TString},
+					{Label: "region", Type: query.TString},
+					{Label: "host", Type: query.TString},
+					{Label: "usage", Type: query.TFloat},
+					{Label: "count", Type: query.TInt},
+					{Label: "total", Type: query.TUInt},
+					{Label: "up", Type: query.TBool},
+					{Label: "state", Type: query.TString},
+					{Label: "last", Type: query.TTime},
+				},
+				Data: [][]interface{}{
+					{t0, "system", "east", "A", 1.5, int64(-2), uint64(3), true, "ok", t1},
+				},
+			}}},
+			encoded: `system,host=A,region=east usage=1.5,count=-2i,total=3u,up=true,state="ok",last=1523923200500000000i 1523923200000000000
+`,
+		},
+		{
+			name: "tag columns",
+			config: lineprotocol.ResultEncoderConfig{
+				TagColumns: []string{"host", "region"},
+			},
+			result: &executetest.Result{Nm: "_result", Tbls: []*executetest.Table{{
+				KeyCols: []string{"_measurement", "host"},
+				ColMeta: []query.ColMeta{
+					{Label: "_time", Type: query.TTime},
+					{Label: "_measurement", Type: query.TString},
+					{Label: "host", Type: query.TString},
+					{Label: "region", Type: query.TString},
+					{Label: "usage", Type: query.TFloat},
+				},
+				Data: [][]interface{}{
+					{t0, "system", "A", "east", 1.5},
+					{t1, "system", "A", "west", 2.5},
+				},
+			}}},
+			encoded: `system,host=A,region=east usage=1.5 1523923200000000000
+system,host=A,region=west usage=2.5 1523923200500000000
+`,
+		},
+		{
+			name: "measurement",
+			config: lineprotocol.ResultEncoderConfig{
+				Measurement: "mem",
+			},
+			result: &executetest.Result{Nm: "_result", Tbls: []*executetest.Table{{
+				KeyCols: []string{"host"},
+				ColMeta: []query.ColMeta{
+					{Label: "host", Type: query.TString},
+					{Label: "used", Type: query.TInt},
+				},
+				Data: [][]interface{}{
+					{"A", int64(10)},
+				},
+			}}},
+			encoded: `mem,host=A used=10i
+`,
+		},
+		{
+			name: "nulls",
+			result: &executetest.Result{Nm: "_result", Tbls: []*executetest.Table{{
+				KeyCols: []string{"_measurement"},
+				ColMeta: []query.ColMeta{
+					{Label: "_time", Type: query.TTime},
+					{Label: "_measurement", Type: query.TString},
+					{Label: "usage", Type: query.TFloat},
+					{Label: "count", Type: query.TInt},
+				},
+				Data: [][]interface{}{
+					{t0, "system", math.NaN(), int64(1)},
+					{t1, "system", nil, nil},
+					{nil, "system", 2.5, nil},
+				},
+			}}},
+			encoded: `system count=1i 1523923200000000000
+system usage=2.5
+`,
+		},
+		{
+			name: "escaping",
+			result: &executetest.Result{Nm: "_result", Tbls: []*executetest.Table{{
+				KeyCols: []string{"_measurement", "host name"},
+				ColMeta: []query.ColMeta{
+					{Label: "_time", Type: query.TTime},
+					{Label: "_measurement", Type: query.TString},
+					{Label: "host name", Type: query.TString},
+					{Label: "message", Type: query.TString},
+				},
+				Data: [][]interface{}{
+					{t0, "my system", "a,b=c", `say "hi" \o/`},
+				},
+			}}},
+			encoded: `my\ system,host\ name=a\,b\=c message="say \"hi\" \\o/" 1523923200000000000
+`,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			encoder := lineprotocol.NewResultEncoder(tc.config)
+			n, err := encoder.Encode(&buf, tc.result)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := buf.String(), tc.encoded; got != want {
+				t.Errorf("unexpected encoding -want/+got:\n%s", diff.LineDiff(want, got))
+			}
+			if n != int64(buf.Len()) {
+				t.Errorf("unexpected count of bytes written: got %d want %d", n, buf.Len())
+			}
+		})
+	}
+}
+
+func TestResultEncoder_MissingMeasurement(t *testing.T) {
+	result := &executetest.Result{Nm: "_result", Tbls: []*executetest.Table{{
+		KeyCols: []string{"host"},
+		ColMeta: []query.ColMeta{
+			{Label: "host", Type: query.TString},
+			{Label: "used", Type: query.TInt},
+		},
+		Data: [][]interface{}{
+			{"A", int64(10)},
+		},
+	}}}
+	_, err := lineprotocol.NewResultEncoder(lineprotocol.ResultEncoderConfig{}).Encode(new(bytes.Buffer), result)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if !query.IsEncoderError(err) {
+		t.Errorf("expected an encoder error, got %v", err)
+	}
+}
+
+func TestMultiResultEncoder(t *testing.T) {
+	table := func() *executetest.Table {
+		return &executetest.Table{
+			KeyCols: []string{"_measurement"},
+			ColMeta: []query.ColMeta{
+				{Label: "_time", Type: query.TTime},
+				{Label: "_measurement", Type: query.TString},
+				{Label: "_value", Type: query.TFloat},
+			},
+			Data: [][]interface{}{
+				{t0, "cpu", 1.0},
+			},
+		}
+	}
+	results := query.NewSliceResultIterator([]query.Result{
+		&executetest.Result{Nm: "a", Tbls: []*executetest.Table{table()}},
+		&executetest.Result{Nm: "b", Tbls: []*executetest.Table{table()}, Err: errors.New("execution failed\nbadly")},
+	})
+	var buf bytes.Buffer
+	encoder := lineprotocol.NewMultiResultEncoder(lineprotocol.ResultEncoderConfig{})
+	if _, err := encoder.Encode(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	want := `cpu _value=1 1523923200000000000
+# error: execution failed badly
+`
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected encoding -want/+got:\n%s", diff.LineDiff(want, got))
+	}
+}
@@ -0,0 +1,404 @@
+package lsp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/EMCECS/influx/query/ast"
+	"github.com/EMCECS/influx/query/parser"
+	"github.com/EMCECS/influx/query/semantic"
+)
+
+// document is an open text document and the result of analyzing it.
+type document struct {
+	uri   string
+	text  string
+	lines []string
+
+	// program is nil if the document could not be parsed.
+	program     *ast.Program
+	diagnostics []Diagnostic
+	// references are all identifiers of the program that refer to a variable, including the declarations themselves.
+	references []reference
+	// declarations are the variables declared by the program.
+	// They are kept from the previous version of the document while it cannot be parsed
+	// so that completion keeps working while the user is typing.
+	declarations []reference
+}
+
+// reference is an identifier that refers to a variable.
+type reference struct {
+	ident *ast.Identifier
+	// decl is the identifier that declares the variable, nil for builtins and undefined names.
+	decl *ast.Identifier
+	// init is the expression the variable is initialized with, nil for function parameters.
+	init ast.Expression
+	// param reports whether the variable is a function parameter.
+	param bool
+	// builtin reports whether the variable is a builtin.
+	builtin bool
+}
+
+// newDocument parses and analyzes the text of a document.
+// The previous version of the document may be nil.
+func newDocument(uri, text string, builtins semantic.DeclarationScope, prev *document) *document {
+	d := &document{
+		uri:   uri,
+		text:  text,
+		lines: strings.Split(text, "\n"),
+	}
+	program, err := parser.NewAST(text)
+	if err != nil {
+		for _, e := range parser.Errors(err) {
+			pos := d.position(e.Position)
+			d.diagnostics = append(d.diagnostics, Diagnostic{
+				Range:    Range{Start: pos, End: Position{Line: pos.Line, Character: pos.Character + 1}},
+				Severity: SeverityError,
+				Source:   "flux",
+				Message:  e.Message,
+			})
+		}
+		if prev != nil {
+			d.declarations = prev.declarations
+		}
+		return d
+	}
+	d.program = program
+
+	r := &resolver{
+		doc:      d,
+		builtins: builtins,
+		scope:    newScope(nil),
+	}
+	// Analyze each statement on its own so that semantic errors can be reported with a position.
+	// Statements with unresolved identifiers are not analyzed further since the semantic
+	// analysis would only report the same problem without a position.
+	declarations := builtins.Copy()
+	for _, s := range program.Body {
+		n := len(d.diagnostics)
+		r.statement(s)
+		if len(d.diagnostics) > n {
+			continue
+		}
+		if _, err := semantic.New(&ast.Program{Body: []ast.Statement{s}}, declarations); err != nil {
+			d.addError(s, err.Error())
+		}
+	}
+	return d
+}
+
+func (d *document) addError(n ast.Node, msg string) {
+	d.diagnostics = append(d.diagnostics, Diagnostic{
+		Range:    d.nodeRange(n),
+		Severity: SeverityError,
+		Source:   "flux",
+		Message:  msg,
+	})
+}
+
+// position converts a one based line and rune column into a protocol position.
+func (d *document) position(p ast.Position) Position {
+	line := p.Line - 1
+	if line < 0 {
+		// Errors without a position are reported at the start of the document.
+		return Position{}
+	}
+	if line >= len(d.lines) {
+		return Position{Line: line}
+	}
+	return Position{Line: line, Character: utf16Len(runePrefix(d.lines[line], p.Column-1))}
+}
+
+// astPosition converts a protocol position into a one based line and rune column.
+func (d *document) astPosition(p Position) ast.Position {
+	if p.Line < 0 || p.Line >= len(d.lines) {
+		return ast.Position{Line: p.Line + 1, Column: 1}
+	}
+	col := 1
+	units := 0
+	for _, r := range d.lines[p.Line] {
+		if units >= p.Character {
+			break
+		}
+		units += len(utf16.Encode([]rune{r}))
+		col++
+	}
+	return ast.Position{Line: p.Line + 1, Column: col}
+}
+
+// nodeRange returns the protocol range of a node.
+// The parser does not report end positions across lines so the end is computed from the node source.
+func (d *document) nodeRange(n ast.Node) Range {
+	loc := n.Location()
+	if loc == nil {
+		return Range{}
+	}
+	start := d.position(loc.Start)
+	if loc.Source == nil {
+		return Range{Start: start, End: d.position(loc.End)}
+	}
+	src := strings.TrimRight(*loc.Source, " \t\r\n")
+	i := strings.LastIndexByte(src, '\n')
+	if i < 0 {
+		return Range{Start: start, End: Position{Line: start.Line, Character: start.Character + utf16Len(src)}}
+	}
+	return Range{
+		Start: start,
+		End: Position{
+			Line:      start.Line + strings.Count(src, "\n"),
+			Character: utf16Len(src[i+1:]),
+		},
+	}
+}
+
+// fullRange returns the range of the entire document.
+func (d *document) fullRange() Range {
+	last := len(d.lines) - 1
+	return Range{End: Position{Line: last, Character: utf16Len(d.lines[last])}}
+}
+
+// referenceAt returns the reference at the position, if any.
+func (d *document) referenceAt(p Position) (reference, bool) {
+	pos := d.astPosition(p)
+	for _, ref := range d.references {
+		loc := ref.ident.Location()
+		if loc == nil || loc.Start.Line != pos.Line {
+			continue
+		}
+		end := loc.Start.Column + utf8.RuneCountInString(ref.ident.Name)
+		if pos.Column >= loc.Start.Column && pos.Column <= end {
+			return ref, true
+		}
+	}
+	return reference{}, false
+}
+
+// variables returns the variables declared at the top level of the program before the position.
+func (d *document) variables(p Position) []reference {
+	pos := d.astPosition(p)
+	seen := make(map[string]bool)
+	var vars []reference
+	for _, ref := range d.declarations {
+		if seen[ref.ident.Name] {
+			continue
+		}
+		if loc := ref.ident.Location(); loc != nil && loc.Start.Line > pos.Line {
+			continue
+		}
+		seen[ref.ident.Name] = true
+		vars = append(vars, ref)
+	}
+	sort.Slice(vars, func(i, j int) bool { return vars[i].ident.Name < vars[j].ident.Name })
+	return vars
+}
+
+// linePrefix returns the text of the line before the position.
+func (d *document) linePrefix(p Position) string {
+	if p.Line < 0 || p.Line >= len(d.lines) {
+		return ""
+	}
+	pos := d.astPosition(p)
+	return runePrefix(d.lines[p.Line], pos.Column-1)
+}
+
+// textBefore returns the document text before the position.
+func (d *document) textBefore(p Position) string {
+	if p.Line < 0 || p.Line >= len(d.lines) {
+		return d.text
+	}
+	return strings.Join(d.lines[:p.Line], "\n") + "\n" + d.linePrefix(p)
+}
+
+// runePrefix returns the first n runes of s.
+func runePrefix(s string, n int) string {
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
+}
+
+// utf16Len returns the number of UTF-16 code units needed to encode s.
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += len(utf16.Encode([]rune{r}))
+	}
+	return n
+}
+
+// scope is a lexical scope of variable declarations.
+type scope struct {
+	parent *scope
+	vars   map[string]reference
+}
+
+func newScope(parent *scope) *scope {
+	return &scope{
+		parent: parent,
+		vars:   make(map[string]reference),
+	}
+}
+
+func (s *scope) lookup(name string) (reference, bool) {
+	for ; s != nil; s = s.parent {
+		if ref, ok := s.vars[name]; ok {
+			return ref, true
+		}
+	}
+	return reference{}, false
+}
+
+// resolver links identifiers to their declarations and reports undefined identifiers and unknown parameters.
+type resolver struct {
+	doc      *document
+	builtins semantic.DeclarationScope
+	scope    *scope
+}
+
+func (r *resolver) statement(s ast.Statement) {
+	switch s := s.(type) {
+	case *ast.BlockStatement:
+		r.scope = newScope(r.scope)
+		for _, s := range s.Body {
+			r.statement(s)
+		}
+		r.scope = r.scope.parent
+	case *ast.ExpressionStatement:
+		r.expression(s.Expression)
+	case *ast.ReturnStatement:
+		r.expression(s.Argument)
+	case *ast.OptionStatement:
+		r.declare(s.Declaration)
+	case *ast.VariableDeclaration:
+		for _, d := range s.Declarations {
+			r.declare(d)
+		}
+	}
+}
+
+func (r *resolver) declare(d *ast.VariableDeclarator) {
+	r.expression(d.Init)
+	ref := reference{
+		ident: d.ID,
+		decl:  d.ID,
+		init:  d.Init,
+	}
+	r.scope.vars[d.ID.Name] = ref
+	r.doc.references = append(r.doc.references, ref)
+	if r.scope.parent == nil {
+		r.doc.declarations = append(r.doc.declarations, ref)
+	}
+}
+
+func (r *resolver) expression(e ast.Expression) {
+	switch e := e.(type) {
+	case *ast.Identifier:
+		r.identifier(e)
+	case *ast.ArrayExpression:
+		for _, el := range e.Elements {
+			r.expression(el)
+		}
+	case *ast.ArrowFunctionExpression:
+		r.scope = newScope(r.scope)
+		for _, p := range e.Params {
+			if p.Value != nil {
+				r.expression(p.Value)
+			}
+			ref := reference{
+				ident: p.Key,
+				decl:  p.Key,
+				param: true,
+			}
+			r.scope.vars[p.Key.Name] = ref
+			r.doc.references = append(r.doc.references, ref)
+		}
+		switch body := e.Body.(type) {
+		case ast.Statement:
+			r.statement(body)
+		case ast.Expression:
+			r.expression(body)
+		}
+		r.scope = r.scope.parent
+	case *ast.BinaryExpression:
+		r.expression(e.Left)
+		r.expression(e.Right)
+	case *ast.LogicalExpression:
+		r.expression(e.Left)
+		r.expression(e.Right)
+	case *ast.UnaryExpression:
+		r.expression(e.Argument)
+	case *ast.ConditionalExpression:
+		r.expression(e.Test)
+		r.expression(e.Consequent)
+		r.expression(e.Alternate)
+	case *ast.CallExpression:
+		r.expression(e.Callee)
+		r.checkParams(e)
+		for _, arg := range e.Arguments {
+			r.expression(arg)
+		}
+	case *ast.PipeExpression:
+		r.expression(e.Argument)
+		r.expression(e.Call)
+	case *ast.MemberExpression:
+		r.expression(e.Object)
+		if _, ok := e.Property.(*ast.Identifier); !ok {
+			r.expression(e.Property)
+		}
+	case *ast.ObjectExpression:
+		for _, p := range e.Properties {
+			if p.Value != nil {
+				r.expression(p.Value)
+			}
+		}
+	}
+}
+
+func (r *resolver) identifier(ident *ast.Identifier) {
+	if ref, ok := r.scope.lookup(ident.Name); ok {
+		ref.ident = ident
+		r.doc.references = append(r.doc.references, ref)
+		return
+	}
+	if _, ok := r.builtins[ident.Name]; ok {
+		r.doc.references = append(r.doc.references, reference{
+			ident:   ident,
+			builtin: true,
+		})
+		return
+	}
+	r.doc.addError(ident, fmt.Sprintf("undefined identifier %q", ident.Name))
+}
+
+// checkParams reports arguments that are not parameters of the called builtin function.
+func (r *resolver) checkParams(call *ast.CallExpression) {
+	callee, ok := call.Callee.(*ast.Identifier)
+	if !ok {
+		return
+	}
+	if _, ok := r.scope.lookup(callee.Name); ok {
+		return
+	}
+	d, ok := r.builtins[callee.Name]
+	if !ok || d.InitType().Kind() != semantic.Function {
+		return
+	}
+	params := d.InitType().Params()
+	for _, arg := range call.Arguments {
+		obj, ok := arg.(*ast.ObjectExpression)
+		if !ok {
+			continue
+		}
+		for _, p := range obj.Properties {
+			if _, ok := params[p.Key.Name]; !ok {
+				r.doc.addError(p.Key, fmt.Sprintf("function %q has no parameter %q", callee.Name, p.Key.Name))
+			}
+		}
+	}
+}
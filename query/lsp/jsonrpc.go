@@ -0,0 +1,121 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// JSON-RPC error codes used by the server.
+const (
+	codeParseError     = -32700
+	codeInvalidParams  = -32602
+	codeMethodNotFound = -32601
+	codeInternalError  = -32603
+)
+
+// message is a JSON-RPC 2.0 request, notification or response.
+// Requests carry an ID, notifications do not.
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  *json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError        `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// conn reads and writes JSON-RPC messages framed with Content-Length headers.
+type conn struct {
+	r *textproto.Reader
+
+	mu sync.Mutex // guards w
+	w  io.Writer
+}
+
+func newConn(r io.Reader, w io.Writer) *conn {
+	return &conn{
+		r: textproto.NewReader(bufio.NewReader(r)),
+		w: w,
+	}
+}
+
+// read reads the next message.
+func (c *conn) read() (*message, error) {
+	header, err := c.r.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	length, err := strconv.Atoi(strings.TrimSpace(header.Get("Content-Length")))
+	if err != nil || length <= 0 {
+		return nil, fmt.Errorf("invalid Content-Length header %q", header.Get("Content-Length"))
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.r.R, body); err != nil {
+		return nil, err
+	}
+	m := new(message)
+	if err := json.Unmarshal(body, m); err != nil {
+		return nil, &rpcError{Code: codeParseError, Message: err.Error()}
+	}
+	return m, nil
+}
+
+// write writes a single message.
+func (c *conn) write(m *message) error {
+	m.JSONRPC = "2.0"
+	body, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = c.w.Write(body)
+	return err
+}
+
+// reply writes the response to the request with the given ID.
+func (c *conn) reply(id *json.RawMessage, result interface{}, err error) error {
+	m := &message{ID: id}
+	if err != nil {
+		rerr, ok := err.(*rpcError)
+		if !ok {
+			rerr = &rpcError{Code: codeInternalError, Message: err.Error()}
+		}
+		m.Error = rerr
+		return c.write(m)
+	}
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	r := json.RawMessage(raw)
+	m.Result = &r
+	return c.write(m)
+}
+
+// notify writes a notification to the client.
+func (c *conn) notify(method string, params interface{}) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return c.write(&message{Method: method, Params: raw})
+}
@@ -0,0 +1,154 @@
+package lsp
+
+// The types below are the subset of the Language Server Protocol used by the server.
+// See https://microsoft.github.io/language-server-protocol/specification for their definitions.
+
+// Position is a zero based line and UTF-16 character offset in a text document.
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// Range is a range in a text document, the end position is exclusive.
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Location is a range inside a document.
+type Location struct {
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
+}
+
+// DiagnosticSeverity is the severity of a diagnostic.
+type DiagnosticSeverity int
+
+const (
+	SeverityError   DiagnosticSeverity = 1
+	SeverityWarning DiagnosticSeverity = 2
+)
+
+// Diagnostic is an error or warning reported for a range of a document.
+type Diagnostic struct {
+	Range    Range              `json:"range"`
+	Severity DiagnosticSeverity `json:"severity"`
+	Source   string             `json:"source"`
+	Message  string             `json:"message"`
+}
+
+// TextEdit is a change to apply to a document.
+type TextEdit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"newText"`
+}
+
+// CompletionItemKind identifies the kind of a completion item.
+type CompletionItemKind int
+
+const (
+	CompletionKindFunction CompletionItemKind = 3
+	CompletionKindField    CompletionItemKind = 5
+	CompletionKindVariable CompletionItemKind = 6
+)
+
+// CompletionItem is a single suggestion for completion.
+type CompletionItem struct {
+	Label      string             `json:"label"`
+	Kind       CompletionItemKind `json:"kind"`
+	Detail     string             `json:"detail,omitempty"`
+	InsertText string             `json:"insertText,omitempty"`
+}
+
+// CompletionList is the result of a completion request.
+type CompletionList struct {
+	IsIncomplete bool             `json:"isIncomplete"`
+	Items        []CompletionItem `json:"items"`
+}
+
+// MarkupContent is documentation text for the client to render.
+type MarkupContent struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+// Hover is the result of a hover request.
+type Hover struct {
+	Contents MarkupContent `json:"contents"`
+	Range    *Range        `json:"range,omitempty"`
+}
+
+// TextDocumentSyncKind defines how the client syncs document changes to the server.
+type TextDocumentSyncKind int
+
+// SyncFull sends the full content of the document on each change.
+const SyncFull TextDocumentSyncKind = 1
+
+type textDocumentSyncOptions struct {
+	OpenClose bool                 `json:"openClose"`
+	Change    TextDocumentSyncKind `json:"change"`
+}
+
+type completionOptions struct {
+	TriggerCharacters []string `json:"triggerCharacters,omitempty"`
+}
+
+type serverCapabilities struct {
+	TextDocumentSync           textDocumentSyncOptions `json:"textDocumentSync"`
+	CompletionProvider         completionOptions       `json:"completionProvider"`
+	HoverProvider              bool                    `json:"hoverProvider"`
+	DefinitionProvider         bool                    `json:"definitionProvider"`
+	DocumentFormattingProvider bool                    `json:"documentFormattingProvider"`
+}
+
+type serverInfo struct {
+	Name string `json:"name"`
+}
+
+type initializeResult struct {
+	Capabilities serverCapabilities `json:"capabilities"`
+	ServerInfo   serverInfo         `json:"serverInfo"`
+}
+
+type textDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+type textDocumentItem struct {
+	URI        string `json:"uri"`
+	LanguageID string `json:"languageId"`
+	Version    int    `json:"version"`
+	Text       string `json:"text"`
+}
+
+type didOpenTextDocumentParams struct {
+	TextDocument textDocumentItem `json:"textDocument"`
+}
+
+type textDocumentContentChangeEvent struct {
+	Range *Range `json:"range,omitempty"`
+	Text  string `json:"text"`
+}
+
+type didChangeTextDocumentParams struct {
+	TextDocument   textDocumentIdentifier           `json:"textDocument"`
+	ContentChanges []textDocumentContentChangeEvent `json:"contentChanges"`
+}
+
+type didCloseTextDocumentParams struct {
+	TextDocument textDocumentIdentifier `json:"textDocument"`
+}
+
+type textDocumentPositionParams struct {
+	TextDocument textDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+}
+
+type documentFormattingParams struct {
+	TextDocument textDocumentIdentifier `json:"textDocument"`
+}
+
+type publishDiagnosticsParams struct {
+	URI         string       `json:"uri"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
@@ -0,0 +1,354 @@
+// Package lsp implements a Language Server Protocol server for Flux.
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/EMCECS/influx/query"
+	"github.com/EMCECS/influx/query/ast"
+	"github.com/EMCECS/influx/query/semantic"
+)
+
+// Server is a Flux language server that communicates with a single client over a stream.
+type Server struct {
+	conn     *conn
+	builtins semantic.DeclarationScope
+	docs     map[string]*document
+}
+
+// NewServer creates a server that reads requests from r and writes responses to w.
+// The builtin functions must have been finalized before the server is created.
+func NewServer(r io.Reader, w io.Writer) *Server {
+	_, decls := query.BuiltIns()
+	return &Server{
+		conn:     newConn(r, w),
+		builtins: decls,
+		docs:     make(map[string]*document),
+	}
+}
+
+// Serve handles requests until the client sends the exit notification or the stream is closed.
+func (s *Server) Serve() error {
+	for {
+		m, err := s.conn.read()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			if rerr, ok := err.(*rpcError); ok {
+				if err := s.conn.reply(nil, nil, rerr); err != nil {
+					return err
+				}
+				continue
+			}
+			return err
+		}
+		if m.Method == "exit" {
+			return nil
+		}
+		result, err := s.handle(m)
+		if m.ID == nil {
+			// Notifications have no response.
+			continue
+		}
+		if err := s.conn.reply(m.ID, result, err); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(m *message) (interface{}, error) {
+	switch m.Method {
+	case "initialize":
+		return s.initialize(), nil
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		return nil, nil
+	case "textDocument/didOpen":
+		var params didOpenTextDocumentParams
+		if err := unmarshalParams(m, &params); err != nil {
+			return nil, err
+		}
+		return nil, s.update(params.TextDocument.URI, params.TextDocument.Text)
+	case "textDocument/didChange":
+		var params didChangeTextDocumentParams
+		if err := unmarshalParams(m, &params); err != nil {
+			return nil, err
+		}
+		if len(params.ContentChanges) == 0 {
+			return nil, nil
+		}
+		// Only full synchronization is supported, the last change holds the entire document.
+		text := params.ContentChanges[len(params.ContentChanges)-1].Text
+		return nil, s.update(params.TextDocument.URI, text)
+	case "textDocument/didClose":
+		var params didCloseTextDocumentParams
+		if err := unmarshalParams(m, &params); err != nil {
+			return nil, err
+		}
+		delete(s.docs, params.TextDocument.URI)
+		return nil, s.conn.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+			URI:         params.TextDocument.URI,
+			Diagnostics: []Diagnostic{},
+		})
+	case "textDocument/completion":
+		var params textDocumentPositionParams
+		if err := unmarshalParams(m, &params); err != nil {
+			return nil, err
+		}
+		d, err := s.document(params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		return s.completion(d, params.Position), nil
+	case "textDocument/hover":
+		var params textDocumentPositionParams
+		if err := unmarshalParams(m, &params); err != nil {
+			return nil, err
+		}
+		d, err := s.document(params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		return s.hover(d, params.Position), nil
+	case "textDocument/definition":
+		var params textDocumentPositionParams
+		if err := unmarshalParams(m, &params); err != nil {
+			return nil, err
+		}
+		d, err := s.document(params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		return s.definition(d, params.Position), nil
+	case "textDocument/formatting":
+		var params documentFormattingParams
+		if err := unmarshalParams(m, &params); err != nil {
+			return nil, err
+		}
+		d, err := s.document(params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		return s.formatting(d), nil
+	default:
+		return nil, &rpcError{Code: codeMethodNotFound, Message: fmt.Sprintf("method not found: %s", m.Method)}
+	}
+}
+
+func unmarshalParams(m *message, v interface{}) error {
+	if err := json.Unmarshal(m.Params, v); err != nil {
+		return &rpcError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func (s *Server) initialize() initializeResult {
+	return initializeResult{
+		Capabilities: serverCapabilities{
+			TextDocumentSync: textDocumentSyncOptions{
+				OpenClose: true,
+				Change:    SyncFull,
+			},
+			CompletionProvider: completionOptions{
+				TriggerCharacters: []string{"(", ","},
+			},
+			HoverProvider:              true,
+			DefinitionProvider:         true,
+			DocumentFormattingProvider: true,
+		},
+		ServerInfo: serverInfo{Name: "flux"},
+	}
+}
+
+func (s *Server) document(uri string) (*document, error) {
+	d, ok := s.docs[uri]
+	if !ok {
+		return nil, &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("unknown document %q", uri)}
+	}
+	return d, nil
+}
+
+// update analyzes the new text of a document and publishes its diagnostics.
+func (s *Server) update(uri, text string) error {
+	d := newDocument(uri, text, s.builtins, s.docs[uri])
+	s.docs[uri] = d
+	diagnostics := d.diagnostics
+	if diagnostics == nil {
+		diagnostics = []Diagnostic{}
+	}
+	return s.conn.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: diagnostics,
+	})
+}
+
+func (s *Server) completion(d *document, p Position) CompletionList {
+	list := CompletionList{Items: []CompletionItem{}}
+
+	prefix := strings.TrimRightFunc(d.linePrefix(p), isIdentRune)
+	if strings.HasSuffix(prefix, ".") {
+		// Member properties cannot be suggested without type information of the object.
+		return list
+	}
+
+	before := strings.TrimRightFunc(d.textBefore(p), isIdentRune)
+	if trimmed := strings.TrimRight(before, " \t\r\n"); strings.HasSuffix(trimmed, "(") || strings.HasSuffix(trimmed, ",") {
+		if callee := enclosingCall(trimmed); callee != "" {
+			if decl, ok := s.builtins[callee]; ok && decl.InitType().Kind() == semantic.Function {
+				typ := decl.InitType()
+				for _, name := range sortedParams(typ) {
+					if name == typ.PipeArgument() {
+						continue
+					}
+					list.Items = append(list.Items, CompletionItem{
+						Label:      name,
+						Kind:       CompletionKindField,
+						Detail:     typ.Params()[name].Kind().String(),
+						InsertText: name + ": ",
+					})
+				}
+				return list
+			}
+		}
+	}
+
+	names := make([]string, 0, len(s.builtins))
+	for name := range s.builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		typ := s.builtins[name].InitType()
+		item := CompletionItem{
+			Label:  name,
+			Kind:   CompletionKindVariable,
+			Detail: typ.Kind().String(),
+		}
+		if typ.Kind() == semantic.Function {
+			item.Kind = CompletionKindFunction
+			item.Detail = signature(name, typ)
+		}
+		list.Items = append(list.Items, item)
+	}
+	for _, ref := range d.variables(p) {
+		list.Items = append(list.Items, CompletionItem{
+			Label: ref.ident.Name,
+			Kind:  CompletionKindVariable,
+		})
+	}
+	return list
+}
+
+func (s *Server) hover(d *document, p Position) *Hover {
+	ref, ok := d.referenceAt(p)
+	if !ok {
+		return nil
+	}
+	var value string
+	switch {
+	case ref.builtin:
+		typ := s.builtins[ref.ident.Name].InitType()
+		if typ.Kind() == semantic.Function {
+			value = signature(ref.ident.Name, typ)
+		} else {
+			value = ref.ident.Name + ": " + typ.Kind().String()
+		}
+	case ref.param:
+		value = "(parameter) " + ref.ident.Name
+	case ref.init != nil:
+		value = ref.ident.Name + " = " + ast.Format(ref.init)
+	default:
+		return nil
+	}
+	r := d.nodeRange(ref.ident)
+	return &Hover{
+		Contents: MarkupContent{
+			Kind:  "markdown",
+			Value: "```flux\n" + value + "\n```",
+		},
+		Range: &r,
+	}
+}
+
+func (s *Server) definition(d *document, p Position) *Location {
+	ref, ok := d.referenceAt(p)
+	if !ok || ref.decl == nil {
+		return nil
+	}
+	return &Location{
+		URI:   d.uri,
+		Range: d.nodeRange(ref.decl),
+	}
+}
+
+func (s *Server) formatting(d *document) []TextEdit {
+	if d.program == nil {
+		// Documents with syntax errors are left as is.
+		return nil
+	}
+	formatted := ast.FormatWithComments(d.program, d.text)
+	if formatted == d.text {
+		return []TextEdit{}
+	}
+	return []TextEdit{{
+		Range:   d.fullRange(),
+		NewText: formatted,
+	}}
+}
+
+// signature returns the signature of a function for display.
+func signature(name string, typ semantic.Type) string {
+	params := sortedParams(typ)
+	args := make([]string, 0, len(params))
+	for _, p := range params {
+		if p == typ.PipeArgument() {
+			p = "<-" + p
+		}
+		args = append(args, p+": "+typ.Params()[strings.TrimPrefix(p, "<-")].Kind().String())
+	}
+	return fmt.Sprintf("%s(%s) %s", name, strings.Join(args, ", "), typ.ReturnType().Kind())
+}
+
+func sortedParams(typ semantic.Type) []string {
+	params := make([]string, 0, len(typ.Params()))
+	for p := range typ.Params() {
+		params = append(params, p)
+	}
+	sort.Strings(params)
+	return params
+}
+
+// enclosingCall returns the name of the function whose argument list is open at the end of src.
+func enclosingCall(src string) string {
+	depth := 0
+	for i := len(src) - 1; i >= 0; i-- {
+		switch src[i] {
+		case ')', ']', '}':
+			depth++
+		case '[', '{':
+			depth--
+		case '(':
+			if depth > 0 {
+				depth--
+				continue
+			}
+			name := strings.TrimRight(src[:i], " \t")
+			j := strings.LastIndexFunc(name, func(r rune) bool { return !isIdentRune(r) })
+			return name[j+1:]
+		}
+		if depth < 0 {
+			return ""
+		}
+	}
+	return ""
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'
+}
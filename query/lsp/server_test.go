@@ -0,0 +1,399 @@
+package lsp_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"testing"
+
+	_ "github.com/EMCECS/influx/query/builtin"
+	"github.com/EMCECS/influx/query/lsp"
+	"github.com/google/go-cmp/cmp"
+)
+
+const uri = "file:///query.flux"
+
+type request struct {
+	ID     int         `json:"id,omitempty"`
+	Method string      `json:"method"`
+	Params interface{} `json:"params,omitempty"`
+}
+
+type response struct {
+	ID     int             `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code int `json:"code"`
+	} `json:"error"`
+}
+
+// run sends the requests to a new server and returns everything the server wrote.
+func run(t *testing.T, reqs ...request) []response {
+	t.Helper()
+	var in bytes.Buffer
+	for _, r := range reqs {
+		body, err := json.Marshal(struct {
+			JSONRPC string `json:"jsonrpc"`
+			request
+		}{JSONRPC: "2.0", request: r})
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+	var out bytes.Buffer
+	if err := lsp.NewServer(&in, &out).Serve(); err != nil {
+		t.Fatal(err)
+	}
+
+	var resps []response
+	r := textproto.NewReader(bufio.NewReader(&out))
+	for {
+		header, err := r.ReadMIMEHeader()
+		if err == io.EOF {
+			return resps
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		n, err := strconv.Atoi(header.Get("Content-Length"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body := make([]byte, n)
+		if _, err := io.ReadFull(r.R, body); err != nil {
+			t.Fatal(err)
+		}
+		var resp response
+		if err := json.Unmarshal(body, &resp); err != nil {
+			t.Fatal(err)
+		}
+		resps = append(resps, resp)
+	}
+}
+
+func open(text string) request {
+	return request{
+		Method: "textDocument/didOpen",
+		Params: map[string]interface{}{
+			"textDocument": map[string]interface{}{
+				"uri":        uri,
+				"languageId": "flux",
+				"version":    1,
+				"text":       text,
+			},
+		},
+	}
+}
+
+func change(text string) request {
+	return request{
+		Method: "textDocument/didChange",
+		Params: map[string]interface{}{
+			"textDocument":   map[string]interface{}{"uri": uri, "version": 2},
+			"contentChanges": []map[string]string{{"text": text}},
+		},
+	}
+}
+
+func at(id int, method string, line, character int) request {
+	return request{
+		ID:     id,
+		Method: method,
+		Params: map[string]interface{}{
+			"textDocument": map[string]string{"uri": uri},
+			"position":     lsp.Position{Line: line, Character: character},
+		},
+	}
+}
+
+func result(t *testing.T, resps []response, id int, v interface{}) {
+	t.Helper()
+	for _, r := range resps {
+		if r.ID == id && r.Method == "" {
+			if r.Error != nil {
+				t.Fatalf("unexpected error code %d", r.Error.Code)
+			}
+			if err := json.Unmarshal(r.Result, v); err != nil {
+				t.Fatal(err)
+			}
+			return
+		}
+	}
+	t.Fatalf("no response for request %d", id)
+}
+
+func diagnostics(t *testing.T, resps []response) [][]lsp.Diagnostic {
+	t.Helper()
+	var all [][]lsp.Diagnostic
+	for _, r := range resps {
+		if r.Method != "textDocument/publishDiagnostics" {
+			continue
+		}
+		var params struct {
+			Diagnostics []lsp.Diagnostic `json:"diagnostics"`
+		}
+		if err := json.Unmarshal(r.Params, &params); err != nil {
+			t.Fatal(err)
+		}
+		all = append(all, params.Diagnostics)
+	}
+	return all
+}
+
+func TestServer_Initialize(t *testing.T) {
+	resps := run(t,
+		request{ID: 1, Method: "initialize", Params: map[string]interface{}{}},
+		request{Method: "initialized", Params: map[string]interface{}{}},
+		request{ID: 2, Method: "unknown/method"},
+		request{ID: 3, Method: "shutdown"},
+		request{Method: "exit"},
+	)
+	var init struct {
+		Capabilities struct {
+			TextDocumentSync struct {
+				Change int `json:"change"`
+			} `json:"textDocumentSync"`
+			HoverProvider              bool `json:"hoverProvider"`
+			DefinitionProvider         bool `json:"definitionProvider"`
+			DocumentFormattingProvider bool `json:"documentFormattingProvider"`
+		} `json:"capabilities"`
+	}
+	result(t, resps, 1, &init)
+	if init.Capabilities.TextDocumentSync.Change != 1 ||
+		!init.Capabilities.HoverProvider ||
+		!init.Capabilities.DefinitionProvider ||
+		!init.Capabilities.DocumentFormattingProvider {
+		t.Errorf("unexpected capabilities %+v", init.Capabilities)
+	}
+	if len(resps) != 3 {
+		t.Fatalf("unexpected number of responses: got %d want 3", len(resps))
+	}
+	if resps[1].Error == nil || resps[1].Error.Code != -32601 {
+		t.Errorf("expected method not found error for unknown method")
+	}
+}
+
+func TestServer_Diagnostics(t *testing.T) {
+	testCases := []struct {
+		name string
+		text string
+		want []lsp.Diagnostic
+	}{
+		{
+			name: "valid",
+			text: "a = 1\nfrom(bucket: \"telegraf\") |> range(start: -a)\n",
+			want: []lsp.Diagnostic{},
+		},
+		{
+			name: "syntax error",
+			text: "a = 1\nb = (\n",
+			want: []lsp.Diagnostic{{
+				Range:    lsp.Range{Start: lsp.Position{Line: 2, Character: 0}, End: lsp.Position{Line: 2, Character: 1}},
+				Severity: lsp.SeverityError,
+				Source:   "flux",
+			}},
+		},
+		{
+			name: "undefined identifier",
+			text: "a = 1\nb = a + c\n",
+			want: []lsp.Diagnostic{{
+				Range:    lsp.Range{Start: lsp.Position{Line: 1, Character: 8}, End: lsp.Position{Line: 1, Character: 9}},
+				Severity: lsp.SeverityError,
+				Source:   "flux",
+				Message:  `undefined identifier "c"`,
+			}},
+		},
+		{
+			name: "function parameters",
+			text: "f = (r) => r._value > 0\nfrom(bucket: \"telegraf\") |> filter(fn: f)\n",
+			want: []lsp.Diagnostic{},
+		},
+		{
+			name: "untyped parameter",
+			text: "from(bucket: \"telegraf\") |> range(start: -1h) |> fill(value: 0.0)\n",
+			want: []lsp.Diagnostic{},
+		},
+		{
+			name: "unknown parameter",
+			text: "from(bucket: \"telegraf\") |> range(begin: -1h)\n",
+			want: []lsp.Diagnostic{{
+				Range:    lsp.Range{Start: lsp.Position{Line: 0, Character: 34}, End: lsp.Position{Line: 0, Character: 39}},
+				Severity: lsp.SeverityError,
+				Source:   "flux",
+				Message:  `function "range" has no parameter "begin"`,
+			}},
+		},
+		{
+			name: "semantic error",
+			text: "f = 1\nfrom(bucket: \"telegraf\") |> f()\n",
+			want: []lsp.Diagnostic{{
+				Range:    lsp.Range{Start: lsp.Position{Line: 1, Character: 0}, End: lsp.Position{Line: 1, Character: 31}},
+				Severity: lsp.SeverityError,
+				Source:   "flux",
+			}},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			all := diagnostics(t, run(t, open(tc.text)))
+			if len(all) != 1 {
+				t.Fatalf("unexpected number of diagnostic notifications: got %d want 1", len(all))
+			}
+			got := all[0]
+			// Parser and semantic error messages are long and not stable, only check that one was reported.
+			if len(got) == 1 && len(tc.want) == 1 && tc.want[0].Message == "" {
+				got[0].Message = ""
+			}
+			if !cmp.Equal(tc.want, got) {
+				t.Errorf("unexpected diagnostics -want/+got\n%s", cmp.Diff(tc.want, got))
+			}
+		})
+	}
+}
+
+func TestServer_DidChange(t *testing.T) {
+	all := diagnostics(t, run(t,
+		open("a = b\n"),
+		change("b = 1\na = b\n"),
+	))
+	if len(all) != 2 {
+		t.Fatalf("unexpected number of diagnostic notifications: got %d want 2", len(all))
+	}
+	if len(all[0]) != 1 {
+		t.Errorf("expected an error before the change, got %v", all[0])
+	}
+	if len(all[1]) != 0 {
+		t.Errorf("expected no errors after the change, got %v", all[1])
+	}
+}
+
+func TestServer_Completion(t *testing.T) {
+	// The document is incomplete while the user is typing,
+	// variables are completed from the last version that could be parsed.
+	resps := run(t,
+		open("threshold = 10\n"),
+		change("threshold = 10\nfrom(bucket: \"telegraf\")\n    |> range(\n    |> c\n"),
+		at(1, "textDocument/completion", 2, 13),
+		at(2, "textDocument/completion", 3, 8),
+	)
+
+	var params lsp.CompletionList
+	result(t, resps, 1, &params)
+	var labels []string
+	for _, item := range params.Items {
+		labels = append(labels, item.Label)
+	}
+	if want := []string{"start", "startCol", "stop", "stopCol", "timeCol"}; !cmp.Equal(want, labels) {
+		t.Errorf("unexpected parameter completions -want/+got\n%s", cmp.Diff(want, labels))
+	}
+
+	var names lsp.CompletionList
+	result(t, resps, 2, &names)
+	found := make(map[string]lsp.CompletionItemKind)
+	for _, item := range names.Items {
+		found[item.Label] = item.Kind
+	}
+	if found["count"] != lsp.CompletionKindFunction {
+		t.Errorf("expected count to be completed as a function")
+	}
+	if found["threshold"] != lsp.CompletionKindVariable {
+		t.Errorf("expected threshold to be completed as a variable")
+	}
+}
+
+func TestServer_Hover(t *testing.T) {
+	text := "n = 5\nfrom(bucket: \"telegraf\") |> limit(n: n)\n"
+	resps := run(t,
+		open(text),
+		at(1, "textDocument/hover", 1, 30),
+		at(2, "textDocument/hover", 1, 37),
+		at(3, "textDocument/hover", 1, 12),
+	)
+
+	testCases := []struct {
+		id   int
+		want string
+	}{
+		{id: 1, want: "```flux\nlimit(n: int, offset: int, <-table: object) object\n```"},
+		{id: 2, want: "```flux\nn = 5\n```"},
+	}
+	for _, tc := range testCases {
+		var h lsp.Hover
+		result(t, resps, tc.id, &h)
+		if h.Contents.Value != tc.want {
+			t.Errorf("unexpected hover for request %d -want/+got\n%s", tc.id, cmp.Diff(tc.want, h.Contents.Value))
+		}
+	}
+
+	var h *lsp.Hover
+	result(t, resps, 3, &h)
+	if h != nil {
+		t.Errorf("expected no hover outside of an identifier, got %+v", h)
+	}
+}
+
+func TestServer_Definition(t *testing.T) {
+	text := "f = (x) =>\n    x * 2\ny = f(x: 1)\n"
+	resps := run(t,
+		open(text),
+		at(1, "textDocument/definition", 2, 4),
+		at(2, "textDocument/definition", 1, 4),
+	)
+
+	testCases := []struct {
+		id   int
+		want lsp.Location
+	}{
+		{
+			id: 1,
+			want: lsp.Location{
+				URI:   uri,
+				Range: lsp.Range{Start: lsp.Position{Line: 0, Character: 0}, End: lsp.Position{Line: 0, Character: 1}},
+			},
+		},
+		{
+			id: 2,
+			want: lsp.Location{
+				URI:   uri,
+				Range: lsp.Range{Start: lsp.Position{Line: 0, Character: 5}, End: lsp.Position{Line: 0, Character: 6}},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		var got lsp.Location
+		result(t, resps, tc.id, &got)
+		if !cmp.Equal(tc.want, got) {
+			t.Errorf("unexpected definition for request %d -want/+got\n%s", tc.id, cmp.Diff(tc.want, got))
+		}
+	}
+}
+
+func TestServer_Formatting(t *testing.T) {
+	text := "// comment\nfrom(bucket:\"telegraf\")|>range(start:-1h)\n"
+	resps := run(t,
+		open(text),
+		request{
+			ID:     1,
+			Method: "textDocument/formatting",
+			Params: map[string]interface{}{
+				"textDocument": map[string]string{"uri": uri},
+			},
+		},
+	)
+	var got []lsp.TextEdit
+	result(t, resps, 1, &got)
+	want := []lsp.TextEdit{{
+		Range:   lsp.Range{End: lsp.Position{Line: 2, Character: 0}},
+		NewText: "// comment\nfrom(bucket: \"telegraf\")\n    |> range(start: -1h)\n",
+	}}
+	if !cmp.Equal(want, got) {
+		t.Errorf("unexpected edits -want/+got\n%s", cmp.Diff(want, got))
+	}
+}
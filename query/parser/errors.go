@@ -0,0 +1,45 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/EMCECS/influx/query/ast"
+)
+
+// Error is a syntax error found at a position in the Flux source.
+type Error struct {
+	Position ast.Position
+	Message  string
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Position.Line, e.Position.Column, e.Message)
+}
+
+// Errors returns the syntax errors contained in an error returned by NewAST, with their source positions.
+// An error that was not produced by the parser is returned as a single Error without a position.
+func Errors(err error) []Error {
+	if err == nil {
+		return nil
+	}
+	list, ok := err.(errList)
+	if !ok {
+		list = errList{err}
+	}
+	errs := make([]Error, 0, len(list))
+	for _, e := range list {
+		pe, ok := e.(*parserError)
+		if !ok {
+			errs = append(errs, Error{Message: e.Error()})
+			continue
+		}
+		errs = append(errs, Error{
+			Position: ast.Position{
+				Line:   pe.pos.line,
+				Column: pe.pos.col,
+			},
+			Message: pe.Inner.Error(),
+		})
+	}
+	return errs
+}
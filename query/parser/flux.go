@@ -2957,18 +2957,9 @@ var g = &grammar{
 										name: "DoubleStringChar",
 									},
 								},
-								&choiceExpr{
-									pos: position{line: 506, col: 31, offset: 10416},
-									alternatives: []interface{}{
-										&ruleRefExpr{
-											pos:  position{line: 506, col: 31, offset: 10416},
-											name: "EOL",
-										},
-										&ruleRefExpr{
-											pos:  position{line: 506, col: 37, offset: 10422},
-											name: "EOF",
-										},
-									},
+								&ruleRefExpr{
+									pos:  position{line: 506, col: 29, offset: 10414},
+									name: "EOF",
 								},
 							},
 						},
@@ -3000,10 +2991,6 @@ var g = &grammar{
 											val:        "\\",
 											ignoreCase: false,
 										},
-										&ruleRefExpr{
-											pos:  position{line: 511, col: 21, offset: 10537},
-											name: "EOL",
-										},
 									},
 								},
 							},
@@ -233,6 +233,22 @@ func TestParse(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "declare variable as a multi-line string",
+			raw: `csv = "a,b
+1,2
+"`,
+			want: &ast.Program{
+				Body: []ast.Statement{
+					&ast.VariableDeclaration{
+						Declarations: []*ast.VariableDeclarator{{
+							ID:   &ast.Identifier{Name: "csv"},
+							Init: &ast.StringLiteral{Value: "a,b\n1,2\n"},
+						}},
+					},
+				},
+			},
+		},
 		{
 			name: "declare variable as a float",
 			raw:  `howdy = 1.1`,
@@ -1793,6 +1809,11 @@ join(tables:[a,b], on:["t1"], fn: (a,b) => (a["_field"] - b["_field"]) / b["_fie
 				}},
 			},
 		},
+		{
+			name:    "parse error unterminated string",
+			raw:     "a = \"foo\nb = 1\n",
+			wantErr: true,
+		},
 		{
 			name:    "parse error extra gibberish",
 			raw:     `from(bucket:"Flux/autogen") &^*&H#IUJBN`,
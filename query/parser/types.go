@@ -278,8 +278,11 @@ func operator(text []byte) (ast.OperatorKind, error) {
 	return ast.OperatorLookup(strings.ToLower(string(text))), nil
 }
 
+// newlineEscaper escapes the line breaks of multi-line string literals so that they can be unquoted.
+var newlineEscaper = strings.NewReplacer("\r", `\r`, "\n", `\n`)
+
 func stringLiteral(text []byte, pos position) (*ast.StringLiteral, error) {
-	s, err := strconv.Unquote(string(text))
+	s, err := strconv.Unquote(newlineEscaper.Replace(string(text)))
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,336 @@
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/EMCECS/influx/query"
+)
+
+// ExplainFormat is a format in which an explanation can be written.
+type ExplainFormat string
+
+const (
+	ExplainText ExplainFormat = "text"
+	ExplainJSON ExplainFormat = "json"
+	ExplainDOT  ExplainFormat = "dot"
+)
+
+// ParseExplainFormat returns the explain format with the given name.
+func ParseExplainFormat(s string) (ExplainFormat, error) {
+	switch f := ExplainFormat(s); f {
+	case ExplainText, ExplainJSON, ExplainDOT:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown explain format %q, must be one of text, json or dot", s)
+	}
+}
+
+// Explanation describes how a query is planned.
+// It contains the query spec, the logical plan and the physical plan of the query.
+type Explanation struct {
+	Spec     []*ExplainedNode `json:"spec"`
+	Logical  []*ExplainedNode `json:"logical"`
+	Physical []*ExplainedNode `json:"physical"`
+	// PushedDown lists the logical procedures that were pushed down
+	// into other procedures by the physical planner.
+	PushedDown []string `json:"pushed_down,omitempty"`
+	// Results maps the name of each result to the physical procedure producing it.
+	Results map[string]string `json:"results"`
+
+	names  map[ProcedureID]string
+	used   map[string]bool
+	yields map[string]bool
+}
+
+// ExplainedNode is a single operation or procedure of an explanation.
+type ExplainedNode struct {
+	// Name identifies the node within the explanation.
+	// Procedures created from an operation have the name of the operation's ID.
+	Name     string           `json:"name"`
+	Kind     string           `json:"kind"`
+	Parents  []string         `json:"parents,omitempty"`
+	Children []string         `json:"children,omitempty"`
+	Bounds   *ExplainedBounds `json:"bounds,omitempty"`
+	Spec     json.RawMessage  `json:"spec"`
+
+	// Parallelism is the number of copies of a physical procedure that process its tables in parallel.
+	Parallelism int `json:"parallelism,omitempty"`
+}
+
+// ExplainedBounds are the time bounds of a physical procedure.
+type ExplainedBounds struct {
+	Start time.Time `json:"start"`
+	Stop  time.Time `json:"stop"`
+}
+
+// NewExplanation explains a query spec and its logical plan.
+// The physical planner modifies the procedures of the logical plan,
+// so the explanation must be created before the physical plan.
+func NewExplanation(spec *query.Spec, lp *LogicalPlanSpec) *Explanation {
+	e := &Explanation{
+		names:  make(map[ProcedureID]string, len(spec.Operations)),
+		used:   make(map[string]bool, len(spec.Operations)),
+		yields: make(map[string]bool),
+	}
+	for _, o := range spec.Operations {
+		e.names[ProcedureIDFromOperationID(o.ID)] = string(o.ID)
+		e.used[string(o.ID)] = true
+	}
+
+	for _, o := range spec.Operations {
+		n := &ExplainedNode{
+			Name: string(o.ID),
+			Kind: string(o.Spec.Kind()),
+			Spec: marshalSpec(o.Spec),
+		}
+		for _, edge := range spec.Edges {
+			if edge.Child == o.ID {
+				n.Parents = append(n.Parents, string(edge.Parent))
+			}
+			if edge.Parent == o.ID {
+				n.Children = append(n.Children, string(edge.Child))
+			}
+		}
+		e.Spec = append(e.Spec, n)
+	}
+	lp.Do(func(pr *Procedure) {
+		n := e.explainProcedure(pr)
+		if _, ok := pr.Spec.(YieldProcedureSpec); ok {
+			e.yields[n.Name] = true
+		}
+		e.Logical = append(e.Logical, n)
+	})
+	return e
+}
+
+// ExplainPhysical adds the physical plan to the explanation.
+func (e *Explanation) ExplainPhysical(p *PlanSpec) {
+	physical := make(map[string]bool, len(p.Order))
+	p.Do(func(pr *Procedure) {
+		n := e.explainProcedure(pr)
+		if pr.Bounds != nil {
+			n.Bounds = &ExplainedBounds{
+				Start: pr.Bounds.Start.Time(),
+				Stop:  pr.Bounds.Stop.Time(),
+			}
+		}
+		if pr.Parallelism > 1 {
+			n.Parallelism = pr.Parallelism
+		}
+		physical[n.Name] = true
+		e.Physical = append(e.Physical, n)
+	})
+
+	// Yields are removed from the physical plan as they become its results,
+	// any other procedure that is missing has been pushed down.
+	for _, n := range e.Logical {
+		if !physical[n.Name] && !e.yields[n.Name] {
+			e.PushedDown = append(e.PushedDown, n.Name)
+		}
+	}
+
+	e.Results = make(map[string]string, len(p.Results))
+	for name, y := range p.Results {
+		e.Results[name] = e.name(y.ID, "")
+	}
+}
+
+func (e *Explanation) explainProcedure(pr *Procedure) *ExplainedNode {
+	kind := string(pr.Spec.Kind())
+	n := &ExplainedNode{
+		Name: e.name(pr.ID, kind),
+		Kind: kind,
+		Spec: marshalSpec(pr.Spec),
+	}
+	for _, id := range pr.Parents {
+		n.Parents = append(n.Parents, e.name(id, ""))
+	}
+	for _, id := range pr.Children {
+		n.Children = append(n.Children, e.name(id, ""))
+	}
+	return n
+}
+
+// name returns the name of the procedure.
+// Procedures that were not created from an operation, such as duplicates created by the physical planner,
+// are named after their kind. If the kind is not known, the name is the procedure ID.
+func (e *Explanation) name(id ProcedureID, kind string) string {
+	if name, ok := e.names[id]; ok {
+		return name
+	}
+	if kind == "" {
+		return id.String()
+	}
+	var name string
+	for i := 0; ; i++ {
+		name = fmt.Sprintf("%s%d", kind, i)
+		if !e.used[name] {
+			break
+		}
+	}
+	e.names[id] = name
+	e.used[name] = true
+	return name
+}
+
+// marshalSpec encodes the spec as JSON.
+// Specs that cannot be encoded are described as a JSON string instead.
+func marshalSpec(spec interface{}) json.RawMessage {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprintf("%+v", spec))
+	}
+	return data
+}
+
+// Write writes the explanation in the given format.
+func (e *Explanation) Write(w io.Writer, format ExplainFormat) error {
+	switch format {
+	case ExplainText:
+		return e.writeText(w)
+	case ExplainJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(e)
+	case ExplainDOT:
+		return e.writeDOT(w)
+	default:
+		return fmt.Errorf("unknown explain format %q", format)
+	}
+}
+
+func (e *Explanation) writeText(w io.Writer) error {
+	var b strings.Builder
+	sections := []struct {
+		title string
+		nodes []*ExplainedNode
+	}{
+		{title: "Query spec", nodes: e.Spec},
+		{title: "Logical plan", nodes: e.Logical},
+		{title: "Physical plan", nodes: e.Physical},
+	}
+	for _, s := range sections {
+		fmt.Fprintf(&b, "%s:\n", s.title)
+		for _, n := range s.nodes {
+			fmt.Fprintf(&b, "    %s (%s)", n.Name, n.Kind)
+			if len(n.Parents) > 0 {
+				fmt.Fprintf(&b, " <- %s", strings.Join(n.Parents, ", "))
+			}
+			b.WriteString("\n")
+			if spec := compactSpec(n.Spec); spec != "" {
+				fmt.Fprintf(&b, "        spec: %s\n", spec)
+			}
+			if n.Bounds != nil {
+				fmt.Fprintf(&b, "        bounds: [%s, %s)\n", n.Bounds.Start.Format(time.RFC3339Nano), n.Bounds.Stop.Format(time.RFC3339Nano))
+			}
+			if n.Parallelism > 0 {
+				fmt.Fprintf(&b, "        parallelism: %d\n", n.Parallelism)
+			}
+		}
+	}
+	if len(e.PushedDown) > 0 {
+		fmt.Fprintf(&b, "Pushed down:\n    %s\n", strings.Join(e.PushedDown, ", "))
+	}
+	if len(e.Results) > 0 {
+		b.WriteString("Results:\n")
+		names := make([]string, 0, len(e.Results))
+		for name := range e.Results {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(&b, "    %s <- %s\n", name, e.Results[name])
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// compactSpec returns the spec without its zero valued fields,
+// so that only the settings of a procedure, for example those pushed down into it, are shown.
+func compactSpec(spec json.RawMessage) string {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(spec, &fields); err != nil {
+		// The spec is not an object, show it as is.
+		return string(spec)
+	}
+	for k, v := range fields {
+		if isZero(v) {
+			delete(fields, k)
+		}
+	}
+	if len(fields) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return string(spec)
+	}
+	return string(data)
+}
+
+func isZero(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case bool:
+		return !v
+	case float64:
+		return v == 0
+	case string:
+		// Zero durations and times are encoded as strings.
+		return v == "" || v == "0s" || v == "0001-01-01T00:00:00Z"
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		for _, f := range v {
+			if !isZero(f) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+func (e *Explanation) writeDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph Explanation {\n")
+	sections := []struct {
+		id, label string
+		nodes     []*ExplainedNode
+	}{
+		{id: "spec", label: "Query spec", nodes: e.Spec},
+		{id: "logical", label: "Logical plan", nodes: e.Logical},
+		{id: "physical", label: "Physical plan", nodes: e.Physical},
+	}
+	for _, s := range sections {
+		fmt.Fprintf(&b, "  subgraph %q {\n", "cluster_"+s.id)
+		fmt.Fprintf(&b, "    label=%q;\n", s.label)
+		for _, n := range s.nodes {
+			label := n.Name + "\n" + n.Kind
+			if n.Bounds != nil {
+				label += fmt.Sprintf("\n[%s, %s)", n.Bounds.Start.Format(time.RFC3339Nano), n.Bounds.Stop.Format(time.RFC3339Nano))
+			}
+			if n.Parallelism > 0 {
+				label += fmt.Sprintf("\nx%d", n.Parallelism)
+			}
+			fmt.Fprintf(&b, "    %q [label=%q];\n", s.id+"_"+n.Name, label)
+		}
+		for _, n := range s.nodes {
+			for _, c := range n.Children {
+				fmt.Fprintf(&b, "    %q -> %q;\n", s.id+"_"+n.Name, s.id+"_"+c)
+			}
+		}
+		b.WriteString("  }\n")
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
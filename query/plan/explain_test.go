@@ -0,0 +1,159 @@
+package plan_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/EMCECS/influx/query"
+	_ "github.com/EMCECS/influx/query/builtin"
+	"github.com/EMCECS/influx/query/plan"
+	"github.com/google/go-cmp/cmp"
+)
+
+func explain(t *testing.T, q string) *plan.Explanation {
+	t.Helper()
+	now := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	spec, err := query.Compile(context.Background(), q, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lp, err := plan.NewLogicalPlanner().Plan(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := plan.NewExplanation(spec, lp)
+	pp, err := plan.NewPlanner().Plan(lp, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.ExplainPhysical(pp)
+	return e
+}
+
+func TestExplanation(t *testing.T) {
+	e := explain(t, `from(bucket: "telegraf") |> range(start: -5m) |> limit(n: 10) |> mean()`)
+
+	if want := []string{"range1", "limit2"}; !cmp.Equal(want, e.PushedDown) {
+		t.Errorf("unexpected pushed down procedures -want/+got\n%s", cmp.Diff(want, e.PushedDown))
+	}
+	if want := map[string]string{"_result": "mean3"}; !cmp.Equal(want, e.Results) {
+		t.Errorf("unexpected results -want/+got\n%s", cmp.Diff(want, e.Results))
+	}
+
+	testCases := []struct {
+		format plan.ExplainFormat
+		want   string
+	}{
+		{
+			format: plan.ExplainText,
+			want: `Query spec:
+    from0 (from)
+        spec: {"bucket":"telegraf"}
+    range1 (range) <- from0
+        spec: {"start":"-5m0s","startCol":"_start","stop":"now","stopCol":"_stop","timeCol":"_time"}
+    limit2 (limit) <- range1
+        spec: {"n":10}
+    mean3 (mean) <- limit2
+        spec: {"columns":["_value"],"timeDst":"_time","timeSrc":"_stop"}
+Logical plan:
+    from0 (from)
+        spec: {"Bucket":"telegraf"}
+    range1 (range) <- from0
+        spec: {"Bounds":{"Start":"-5m0s","Stop":"now"},"StartCol":"_start","StopCol":"_stop","TimeCol":"_time"}
+    limit2 (limit) <- range1
+        spec: {"n":10}
+    mean3 (mean) <- limit2
+        spec: {"columns":["_value"],"timeDst":"_time","timeSrc":"_stop"}
+Physical plan:
+    from0 (from)
+        spec: {"Bounds":{"Start":"-5m0s","Stop":"now"},"BoundsSet":true,"Bucket":"telegraf","LimitSet":true,"PointsLimit":10}
+        bounds: [2017-12-31T23:55:00Z, 2018-01-01T00:00:00Z)
+    mean3 (mean) <- from0
+        spec: {"columns":["_value"],"timeDst":"_time","timeSrc":"_stop"}
+        bounds: [2017-12-31T23:55:00Z, 2018-01-01T00:00:00Z)
+        parallelism: 2
+Pushed down:
+    range1, limit2
+Results:
+    _result <- mean3
+`,
+		},
+		{
+			format: plan.ExplainDOT,
+			want: `digraph Explanation {
+  subgraph "cluster_spec" {
+    label="Query spec";
+    "spec_from0" [label="from0\nfrom"];
+    "spec_range1" [label="range1\nrange"];
+    "spec_limit2" [label="limit2\nlimit"];
+    "spec_mean3" [label="mean3\nmean"];
+    "spec_from0" -> "spec_range1";
+    "spec_range1" -> "spec_limit2";
+    "spec_limit2" -> "spec_mean3";
+  }
+  subgraph "cluster_logical" {
+    label="Logical plan";
+    "logical_from0" [label="from0\nfrom"];
+    "logical_range1" [label="range1\nrange"];
+    "logical_limit2" [label="limit2\nlimit"];
+    "logical_mean3" [label="mean3\nmean"];
+    "logical_from0" -> "logical_range1";
+    "logical_range1" -> "logical_limit2";
+    "logical_limit2" -> "logical_mean3";
+  }
+  subgraph "cluster_physical" {
+    label="Physical plan";
+    "physical_from0" [label="from0\nfrom\n[2017-12-31T23:55:00Z, 2018-01-01T00:00:00Z)"];
+    "physical_mean3" [label="mean3\nmean\n[2017-12-31T23:55:00Z, 2018-01-01T00:00:00Z)\nx2"];
+    "physical_from0" -> "physical_mean3";
+  }
+}
+`,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(string(tc.format), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := e.Write(&buf, tc.format); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tc.want {
+				t.Errorf("unexpected explanation -want/+got\n%s", cmp.Diff(tc.want, got))
+			}
+		})
+	}
+
+	t.Run(string(plan.ExplainJSON), func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := e.Write(&buf, plan.ExplainJSON); err != nil {
+			t.Fatal(err)
+		}
+		decoded := new(plan.Explanation)
+		if err := json.Unmarshal(buf.Bytes(), decoded); err != nil {
+			t.Fatal(err)
+		}
+		// The explanation is the same when it is encoded again.
+		var again bytes.Buffer
+		if err := decoded.Write(&again, plan.ExplainJSON); err != nil {
+			t.Fatal(err)
+		}
+		if want, got := buf.String(), again.String(); want != got {
+			t.Errorf("unexpected explanation -want/+got\n%s", cmp.Diff(want, got))
+		}
+	})
+}
+
+func TestParseExplainFormat(t *testing.T) {
+	for _, s := range []string{"text", "json", "dot"} {
+		if f, err := plan.ParseExplainFormat(s); err != nil || string(f) != s {
+			t.Errorf("unexpected format %q for %q, error: %v", f, s, err)
+		}
+	}
+	if _, err := plan.ParseExplainFormat("svg"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
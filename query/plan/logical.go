@@ -16,6 +16,8 @@ type LogicalPlanSpec struct {
 	Order      []ProcedureID
 	Resources  query.ResourceManagement
 	Now        time.Time
+	// Live reports whether the plan is for a live query, whose bounds stopping now have no stop.
+	Live bool
 }
 
 func (lp *LogicalPlanSpec) Do(f func(pr *Procedure)) {
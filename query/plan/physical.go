@@ -23,6 +23,8 @@ var (
 type PlanSpec struct {
 	// Now represents the relative current time of the plan.
 	Now time.Time
+	// Live reports whether the plan is for a live query, whose bounds stopping now have no stop.
+	Live bool
 	// Procedures is a set of all operations
 	Procedures map[ProcedureID]*Procedure
 	Order      []ProcedureID
@@ -37,6 +39,20 @@ type YieldSpec struct {
 	ID ProcedureID
 }
 
+// LimitConcurrency lowers the concurrency quota of the plan to n,
+// and the parallelism of its procedures with it.
+func (p *PlanSpec) LimitConcurrency(n int) {
+	if p.Resources.ConcurrencyQuota <= n {
+		return
+	}
+	p.Resources.ConcurrencyQuota = n
+	p.Do(func(pr *Procedure) {
+		if pr.Parallelism > n {
+			pr.Parallelism = n
+		}
+	})
+}
+
 func (p *PlanSpec) Do(f func(pr *Procedure)) {
 	for _, id := range p.Order {
 		f(p.Procedures[id])
@@ -96,6 +112,7 @@ func (p *planner) Plan(lp *LogicalPlanSpec, s Storage) (*PlanSpec, error) {
 
 	p.plan = &PlanSpec{
 		Now:        now,
+		Live:       lp.Live,
 		Procedures: make(map[ProcedureID]*Procedure, len(lp.Procedures)),
 		Order:      make([]ProcedureID, 0, len(lp.Order)),
 		Resources:  lp.Resources,
@@ -182,7 +199,12 @@ func (p *planner) Plan(lp *LogicalPlanSpec, s Storage) (*PlanSpec, error) {
 		// the procedure's new bounds are the intersection of any bounds it inherited
 		// from its parents, and its own bounds.
 		if bounded, ok := pr.Spec.(BoundedProcedureSpec); ok {
-			convertedBounds, err := ToBoundsSpec(bounded.TimeBounds(), pr.plan.Now)
+			bounds := bounded.TimeBounds()
+			if pr.plan.Live && bounds.Stop.IsRelative && bounds.Stop.Relative == 0 {
+				// The data of a live query is read as it is written.
+				bounds.Stop = query.MaxTime
+			}
+			convertedBounds, err := ToBoundsSpec(bounds, pr.plan.Now)
 			if err != nil {
 				return nil, errors.Wrapf(err, "invalid time bounds from procedure %s", pr.Spec.Kind())
 			}
@@ -225,6 +247,10 @@ func (p *planner) Plan(lp *LogicalPlanSpec, s Storage) (*PlanSpec, error) {
 			return nil, errors.New("query must specify explicit yields when there is more than one result.")
 		}
 	}
+	if p.plan.Live && len(p.plan.Results) > 1 {
+		// The results of a live query never end, they cannot be read one after the other.
+		return nil, errors.New("live query must yield a single result")
+	}
 
 	// Check to see if any results are unbounded.
 	// Since bounds are inherited,
@@ -247,6 +273,15 @@ func (p *planner) Plan(lp *LogicalPlanSpec, s Storage) (*PlanSpec, error) {
 		p.plan.Resources.MemoryBytesQuota = p.defaultMemoryLimit
 	}
 
+	// Partition the tables of parallel procedures across as many copies as the query has workers.
+	if n := p.plan.Resources.ConcurrencyQuota; n > 1 {
+		p.plan.Do(func(pr *Procedure) {
+			if s, ok := pr.Spec.(ParallelProcedureSpec); ok && s.Parallel() && len(pr.Parents) == 1 {
+				pr.Parallelism = n
+			}
+		})
+	}
+
 	return p.plan, nil
 }
 
@@ -267,7 +267,8 @@ func TestPhysicalPlanner_Plan(t *testing.T) {
 							Start: values.ConvertTime(now.Add(-1 * time.Hour)),
 							Stop:  values.ConvertTime(now),
 						},
-						Children: nil,
+						Children:    nil,
+						Parallelism: 2,
 					},
 				},
 				Results: map[string]plan.YieldSpec{
@@ -989,7 +990,8 @@ func TestPhysicalPlanner_Plan(t *testing.T) {
 						Parents: []plan.ProcedureID{
 							plan.ProcedureIDFromOperationID("range2"),
 						},
-						Children: []plan.ProcedureID{plan.ProcedureIDFromOperationID("mean")},
+						Children:    []plan.ProcedureID{plan.ProcedureIDFromOperationID("mean")},
+						Parallelism: 5,
 					},
 					plan.ProcedureIDFromOperationID("mean"): {
 						ID:   plan.ProcedureIDFromOperationID("mean"),
@@ -1001,7 +1003,8 @@ func TestPhysicalPlanner_Plan(t *testing.T) {
 						Parents: []plan.ProcedureID{
 							(plan.ProcedureIDFromOperationID("limit")),
 						},
-						Children: nil,
+						Children:    nil,
+						Parallelism: 5,
 					},
 				},
 				Results: map[string]plan.YieldSpec{
@@ -1016,6 +1019,84 @@ func TestPhysicalPlanner_Plan(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "live",
+			lp: &plan.LogicalPlanSpec{
+				Now:  now,
+				Live: true,
+				Resources: query.ResourceManagement{
+					ConcurrencyQuota: 1,
+					MemoryBytesQuota: 10000,
+				},
+				Procedures: map[plan.ProcedureID]*plan.Procedure{
+					plan.ProcedureIDFromOperationID("from"): {
+						ID: plan.ProcedureIDFromOperationID("from"),
+						Spec: &functions.FromProcedureSpec{
+							Bucket: "mybucket",
+						},
+						Parents:  nil,
+						Children: []plan.ProcedureID{plan.ProcedureIDFromOperationID("range")},
+					},
+					plan.ProcedureIDFromOperationID("range"): {
+						ID: plan.ProcedureIDFromOperationID("range"),
+						Spec: &functions.RangeProcedureSpec{
+							Bounds: query.Bounds{
+								Start: query.Time{
+									IsRelative: true,
+									Relative:   -1 * time.Hour,
+								},
+								Stop: query.Now,
+							},
+							TimeCol: "_time",
+						},
+						Parents: []plan.ProcedureID{
+							plan.ProcedureIDFromOperationID("from"),
+						},
+						Children: nil,
+					},
+				},
+				Order: []plan.ProcedureID{
+					plan.ProcedureIDFromOperationID("from"),
+					plan.ProcedureIDFromOperationID("range"),
+				},
+			},
+			pp: &plan.PlanSpec{
+				Now:  now,
+				Live: true,
+				Resources: query.ResourceManagement{
+					ConcurrencyQuota: 1,
+					MemoryBytesQuota: 10000,
+				},
+				Procedures: map[plan.ProcedureID]*plan.Procedure{
+					plan.ProcedureIDFromOperationID("from"): {
+						ID: plan.ProcedureIDFromOperationID("from"),
+						Spec: &functions.FromProcedureSpec{
+							Bucket:    "mybucket",
+							BoundsSet: true,
+							Bounds: query.Bounds{
+								Start: query.Time{
+									IsRelative: true,
+									Relative:   -1 * time.Hour,
+								},
+								Stop: query.Now,
+							},
+						},
+						Bounds: &plan.BoundsSpec{
+							Start: values.ConvertTime(now.Add(-1 * time.Hour)),
+							Stop:  values.ConvertTime(query.MaxTime.Absolute),
+						},
+						Parents:  nil,
+						Children: []plan.ProcedureID{},
+					},
+				},
+				Results: map[string]plan.YieldSpec{
+					plan.DefaultYieldName: {ID: plan.ProcedureIDFromOperationID("from")},
+				},
+				Order: []plan.ProcedureID{
+					plan.ProcedureIDFromOperationID("from"),
+				},
+			},
+		},
 	}
 	for _, tc := range testCases {
 		tc := tc
@@ -1264,8 +1345,9 @@ func TestPhysicalPlanner_Plan_PushDown_Mixed(t *testing.T) {
 					Start: values.ConvertTime(now.Add(-1 * time.Hour)),
 					Stop:  values.ConvertTime(now),
 				},
-				Parents:  []plan.ProcedureID{plan.ProcedureIDFromOperationID("from")},
-				Children: []plan.ProcedureID{},
+				Parents:     []plan.ProcedureID{plan.ProcedureIDFromOperationID("from")},
+				Children:    []plan.ProcedureID{},
+				Parallelism: 3,
 			},
 		},
 		Results: map[string]plan.YieldSpec{
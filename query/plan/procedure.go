@@ -24,6 +24,10 @@ type Procedure struct {
 	Children []ProcedureID
 	Spec     ProcedureSpec
 	Bounds   *BoundsSpec
+	// Parallelism is the number of copies of the procedure that process its tables in parallel,
+	// the tables are partitioned across the copies by group key.
+	// The procedure is not parallel when it is zero or one.
+	Parallelism int
 }
 
 func (p *Procedure) Copy() *Procedure {
@@ -94,6 +98,43 @@ type AggregateProcedureSpec interface {
 	ReAggregateSpec() ProcedureSpec
 }
 
+// ParallelProcedureSpec is a procedure that can process its tables in parallel.
+type ParallelProcedureSpec interface {
+	// Parallel reports whether each table is processed independently of the others,
+	// into tables whose group keys are only produced from the group key of that table.
+	Parallel() bool
+}
+
+// SliceableProcedureSpec is a procedure whose tables can be produced one time slice of its bounds at a time.
+type SliceableProcedureSpec interface {
+	// Sliceable reports whether the tables produced over contiguous slices of the bounds,
+	// once their rows are concatenated by group key, are the tables produced over the whole bounds,
+	// the _start and _stop columns aside.
+	Sliceable() bool
+}
+
+// WindowSliceableProcedureSpec is a procedure that groups the rows of its tables into windows of time.
+type WindowSliceableProcedureSpec interface {
+	// WindowSliceable returns the duration of the windows and the label of the column of their start,
+	// which is part of the group key of the tables of each window.
+	// It reports false unless the windows are contiguous, do not overlap and are aligned with the Unix epoch,
+	// so that the tables of the windows that start within a time slice can be produced from that slice alone.
+	WindowSliceable() (every values.Duration, startCol string, ok bool)
+}
+
+// KeyPreservingProcedureSpec is a procedure that processes each of its tables independently of the others.
+type KeyPreservingProcedureSpec interface {
+	// PreservesKey reports whether each table is processed into a single table with the same group key,
+	// from the rows of that table alone.
+	PreservesKey() bool
+}
+
+// SideEffectProcedureSpec is a procedure whose execution has effects besides producing tables.
+type SideEffectProcedureSpec interface {
+	// SideEffect reports whether the procedure has effects outside of the query, such as writing to an external system.
+	SideEffect() bool
+}
+
 type ParentAwareProcedureSpec interface {
 	ParentChanged(old, new ProcedureID)
 }
@@ -183,10 +224,13 @@ func (b *BoundsSpec) Intersect(o *BoundsSpec) *BoundsSpec {
 }
 
 type WindowSpec struct {
-	Every  query.Duration
-	Period query.Duration
-	Round  query.Duration
-	Start  query.Time
+	Every        query.Duration
+	Period       query.Duration
+	Round        query.Duration
+	Start        query.Time
+	EveryMonths  int64
+	PeriodMonths int64
+	Location     string
 }
 
 var kindToProcedure = make(map[ProcedureKind]CreateProcedureSpec)
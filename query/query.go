@@ -33,6 +33,40 @@ type ProxyQueryService interface {
 	Query(ctx context.Context, w io.Writer, req *ProxyRequest) (int64, error)
 }
 
+// ProxyQueryStatisticser is implemented by the proxy query services that report the statistics of their queries.
+type ProxyQueryStatisticser interface {
+	// QueryWithStatistics performs the requested query like Query,
+	// and also returns the statistics of the query once its results have been encoded.
+	QueryWithStatistics(ctx context.Context, w io.Writer, req *ProxyRequest) (int64, Statistics, error)
+}
+
+// RunningQueryService lists and cancels the queries being run by a query service.
+type RunningQueryService interface {
+	// FindRunningQueries returns the running queries, only those of the organization if orgID is not nil.
+	FindRunningQueries(ctx context.Context, orgID *platform.ID) ([]*RunningQuery, error)
+
+	// FindRunningQueryByID returns a single running query by ID.
+	FindRunningQueryByID(ctx context.Context, id uint64) (*RunningQuery, error)
+
+	// CancelRunningQuery cancels a running query by ID.
+	CancelRunningQuery(ctx context.Context, id uint64) error
+}
+
+// RunningQuery describes a query being run.
+type RunningQuery struct {
+	// ID identifies the query as long as it runs.
+	ID             uint64      `json:"id"`
+	OrganizationID platform.ID `json:"organization_id"`
+	// State is the state of the query, such as queueing, planning or executing.
+	State string `json:"state"`
+	// Elapsed is the amount of time in nanoseconds since the query was submitted.
+	Elapsed time.Duration `json:"elapsed"`
+	// Allocated is the number of bytes the query currently allocates.
+	Allocated int64 `json:"allocated"`
+	// Query is the Flux text of the query, it is empty if the query was not compiled from Flux.
+	Query string `json:"query,omitempty"`
+}
+
 // ResultIterator allows iterating through all results
 // Cancel must be called to free resources.
 // ResultIterators may implement Statisticser.
@@ -91,6 +125,23 @@ type Request struct {
 	// Compiler converts the query to a specification to run against the data.
 	Compiler Compiler `json:"compiler"`
 
+	// Explain requests the plan of the query instead of its results.
+	// The query is compiled and planned but not executed.
+	Explain bool `json:"explain,omitempty"`
+
+	// Profile requests a profile of each operator of the query.
+	// The profile is reported in the statistics of the query and as the additional result named ProfilerResultName.
+	Profile bool `json:"profile,omitempty"`
+
+	// Live keeps the query running as new data is written, instead of stopping at the time it started.
+	// Ranges stopping now have no stop, and the tables of the results are delivered as they are triggered
+	// by the watermark of the sources. A live query without a stop runs until it is cancelled.
+	Live bool `json:"live,omitempty"`
+
+	// Timeout is the maximum duration the query executes for.
+	// The default timeout of the service applies if it is zero.
+	Timeout time.Duration `json:"timeout,omitempty"`
+
 	// compilerMappings maps compiler types to creation methods
 	compilerMappings CompilerMappings
 }
@@ -272,4 +323,37 @@ type Statistics struct {
 	Concurrency int `json:"concurrency"`
 	// MaxAllocated is the maximum number of bytes the query allocated.
 	MaxAllocated int64 `json:"max_allocated"`
+	// SpilledBytes is the number of bytes of buffered tables the query spilled to disk.
+	SpilledBytes int64 `json:"spilled_bytes"`
+
+	// Operators is the profile of each operator of the query, if the query was profiled.
+	Operators []OperatorProfile `json:"operators,omitempty"`
+}
+
+// ProfilerResultName is the name of the result that contains the operator profiles of a profiled query.
+// The result is only complete once all other results have been read, so it is always the last result.
+const ProfilerResultName = "_profiler"
+
+// OperatorProfile is the profile of a single operator, a source or a transformation, of a query.
+type OperatorProfile struct {
+	// ID identifies the operator within the query.
+	ID string `json:"id"`
+	// Kind is the kind of the operator.
+	Kind string `json:"kind"`
+
+	// TablesIn and RowsIn are the number of tables and rows the operator received.
+	TablesIn int64 `json:"tables_in"`
+	RowsIn   int64 `json:"rows_in"`
+	// TablesOut and RowsOut are the number of tables and rows the operator produced.
+	// Rows are counted as they are read by the next operator.
+	TablesOut int64 `json:"tables_out"`
+	RowsOut   int64 `json:"rows_out"`
+
+	// ProcessDuration is the amount of time in nanoseconds the operator spent processing its input.
+	// For sources it is the amount of time spent producing tables.
+	ProcessDuration time.Duration `json:"process_duration"`
+	// WaitDuration is the amount of time in nanoseconds the operator spent waiting to be scheduled by the dispatcher.
+	WaitDuration time.Duration `json:"wait_duration"`
+	// MaxAllocated is the maximum number of bytes the operator allocated.
+	MaxAllocated int64 `json:"max_allocated"`
 }
@@ -21,6 +21,8 @@ type NewQueryTestCase struct {
 	WantErr          bool
 	WantReadBuckets  *[]platform.BucketFilter
 	WantWriteBuckets *[]platform.BucketFilter
+	// SpecExecutor executes the specs of the tables read while compiling, such as by tableFind.
+	SpecExecutor query.SpecExecutor
 }
 
 var opts = append(
@@ -29,13 +31,20 @@ var opts = append(
 	cmp.AllowUnexported(functions.JoinOpSpec{}),
 	cmpopts.IgnoreUnexported(query.Spec{}),
 	cmpopts.IgnoreUnexported(functions.JoinOpSpec{}),
+	cmpopts.IgnoreUnexported(functions.AsofJoinOpSpec{}),
+	cmpopts.IgnoreUnexported(functions.DiffOpSpec{}),
+	cmpopts.IgnoreUnexported(functions.AssertEqualsOpSpec{}),
 )
 
 func NewQueryTestHelper(t *testing.T, tc NewQueryTestCase) {
 	t.Helper()
 
 	now := time.Now().UTC()
-	got, err := query.Compile(context.Background(), tc.Raw, now)
+	ctx := context.Background()
+	if tc.SpecExecutor != nil {
+		ctx = query.ContextWithSpecExecutor(ctx, tc.SpecExecutor)
+	}
+	got, err := query.Compile(ctx, tc.Raw, now)
 	if (err != nil) != tc.WantErr {
 		t.Errorf("query.NewQuery() error = %v, wantErr %v", err, tc.WantErr)
 		return
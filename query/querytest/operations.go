@@ -18,6 +18,7 @@ func OperationMarshalingTestHelper(t *testing.T, data []byte, expOp *query.Opera
 		semantictest.CmpOptions,
 		cmp.AllowUnexported(functions.JoinOpSpec{}),
 		cmpopts.IgnoreUnexported(functions.JoinOpSpec{}),
+		cmpopts.IgnoreUnexported(functions.AsofJoinOpSpec{}),
 	)
 
 	// Ensure we can properly unmarshal a spec
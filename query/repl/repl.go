@@ -21,6 +21,7 @@ import (
 	"github.com/EMCECS/influx/query/functions"
 	"github.com/EMCECS/influx/query/interpreter"
 	"github.com/EMCECS/influx/query/parser"
+	"github.com/EMCECS/influx/query/plan"
 	"github.com/EMCECS/influx/query/semantic"
 	"github.com/EMCECS/influx/query/values"
 	"github.com/pkg/errors"
@@ -32,6 +33,7 @@ type REPL struct {
 	interpreter  *interpreter.Interpreter
 	declarations semantic.DeclarationScope
 	c            *control.Controller
+	encoder      query.MultiResultEncoder
 
 	cancelMu   sync.Mutex
 	cancelFunc context.CancelFunc
@@ -66,6 +68,11 @@ func New(c *control.Controller, orgID platform.ID) *REPL {
 	}
 }
 
+// SetEncoder sets the encoder of the results of queries, which are printed as formatted tables when it is nil.
+func (r *REPL) SetEncoder(e query.MultiResultEncoder) {
+	r.encoder = e
+}
+
 func (r *REPL) Run() {
 	p := prompt.New(
 		r.input,
@@ -131,13 +138,35 @@ func (r *REPL) completer(d prompt.Document) []prompt.Suggest {
 }
 
 func (r *REPL) Input(t string) error {
-	_, err := r.executeLine(t, false)
+	_, err := r.executeLine(t, false, "")
+	return err
+}
+
+// Explain processes the input and prints the plan of its query in the given format instead of executing it.
+func (r *REPL) Explain(t string, format plan.ExplainFormat) error {
+	_, err := r.executeLine(t, false, format)
 	return err
 }
 
+// explainCommand is the prefix of input lines whose query is explained.
+// It may be followed by the name of an explain format, the default format is text.
+const explainCommand = ":explain"
+
 // input processes a line of input and prints the result.
 func (r *REPL) input(t string) {
-	v, err := r.executeLine(t, true)
+	var (
+		v   values.Value
+		err error
+	)
+	if strings.HasPrefix(t, explainCommand) {
+		var format plan.ExplainFormat
+		format, t, err = parseExplain(strings.TrimPrefix(t, explainCommand))
+		if err == nil {
+			v, err = r.executeLine(t, false, format)
+		}
+	} else {
+		v, err = r.executeLine(t, true, "")
+	}
 	if err != nil {
 		fmt.Println("Error:", err)
 	} else if v != nil {
@@ -145,9 +174,26 @@ func (r *REPL) input(t string) {
 	}
 }
 
+// parseExplain splits the arguments of the explain command into the explain format and the query.
+func parseExplain(args string) (plan.ExplainFormat, string, error) {
+	args = strings.TrimSpace(args)
+	if args == "" {
+		return "", "", fmt.Errorf("usage: %s [text|json|dot] <query>", explainCommand)
+	}
+	word := args
+	if i := strings.IndexAny(args, " \t"); i >= 0 {
+		word = args[:i]
+	}
+	if format, err := plan.ParseExplainFormat(word); err == nil {
+		return format, strings.TrimSpace(args[len(word):]), nil
+	}
+	return plan.ExplainText, args, nil
+}
+
 // executeLine processes a line of input.
 // If the input evaluates to a valid value, that value is returned.
-func (r *REPL) executeLine(t string, expectYield bool) (values.Value, error) {
+// If explain is set, the query of the input is explained in that format instead of executed.
+func (r *REPL) executeLine(t string, expectYield bool, explain plan.ExplainFormat) (values.Value, error) {
 	if t == "" {
 		return nil, nil
 	}
@@ -181,6 +227,9 @@ func (r *REPL) executeLine(t string, expectYield bool) (values.Value, error) {
 		t := v.(*query.TableObject)
 		if !expectYield || (expectYield && t.Kind == functions.YieldKind) {
 			spec := query.ToSpec(r.interpreter, t)
+			if explain != "" {
+				return nil, r.doExplain(spec, explain)
+			}
 			return nil, r.doQuery(spec)
 		}
 	}
@@ -220,6 +269,11 @@ func (r *REPL) doQuery(spec *query.Spec) error {
 		return err
 	}
 
+	if r.encoder != nil {
+		_, err := r.encoder.Encode(os.Stdout, query.NewMapResultIterator(results))
+		return err
+	}
+
 	names := make([]string, 0, len(results))
 	for name := range results {
 		names = append(names, name)
@@ -241,6 +295,36 @@ func (r *REPL) doQuery(spec *query.Spec) error {
 	return nil
 }
 
+func (r *REPL) doExplain(spec *query.Spec, format plan.ExplainFormat) error {
+	req := &query.Request{
+		OrganizationID: r.orgID,
+		Compiler: query.SpecCompiler{
+			Spec: spec,
+		},
+		Explain: true,
+	}
+
+	q, err := r.c.Query(context.Background(), req)
+	if err != nil {
+		return err
+	}
+	defer q.Done()
+
+	results, ok := <-q.Ready()
+	if !ok {
+		return q.Err()
+	}
+	res, ok := results[control.ExplainResultName]
+	if !ok {
+		return errors.New("query was not explained")
+	}
+	e, err := control.ReadExplanation(res)
+	if err != nil {
+		return err
+	}
+	return e.Write(os.Stdout, format)
+}
+
 func getFluxFiles(path string) ([]string, error) {
 	return filepath.Glob(path + "*.flux")
 }
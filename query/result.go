@@ -89,6 +89,10 @@ type ColReader interface {
 	Floats(j int) []float64
 	Strings(j int) []string
 	Times(j int) []values.Time
+	// Nulls reports for each row whether the value of column j is null.
+	// The value of a null is the zero value of the column type.
+	// A nil slice means that the column contains no nulls.
+	Nulls(j int) []bool
 }
 
 type GroupKey interface {
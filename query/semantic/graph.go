@@ -660,6 +660,9 @@ func (l *DateTimeLiteral) Copy() Node {
 
 type DurationLiteral struct {
 	Value time.Duration `json:"value"`
+	// Months is a number of calendar months added to Value.
+	// The length of a month depends on the date it is added to.
+	Months int64 `json:"months,omitempty"`
 }
 
 func (*DurationLiteral) NodeType() string { return "DurationLiteral" }
@@ -1152,17 +1155,16 @@ func analyzePipeExpression(pipe *ast.PipeExpression, declarations DeclarationSco
 		return nil, err
 	}
 
-	decl, err := resolveDeclaration(call.Callee)
+	fnTyp, name, err := resolveCalleeType(call.Callee)
 	if err != nil {
 		return nil, err
 	}
-	fnTyp := decl.InitType()
 	if fnTyp.Kind() != Function {
 		return nil, fmt.Errorf("cannot pipe into non function %q", fnTyp.Kind())
 	}
 	key := fnTyp.PipeArgument()
 	if key == "" {
-		return nil, fmt.Errorf("function %q does not have a pipe argument", decl.ID().Name)
+		return nil, fmt.Errorf("function %q does not have a pipe argument", name)
 	}
 
 	value, err := analyzeExpression(pipe.Argument, declarations)
@@ -1188,6 +1190,30 @@ func analyzePipeExpression(pipe *ast.PipeExpression, declarations DeclarationSco
 	return call, nil
 }
 
+// resolveCalleeType returns the type and the name of the function called by a call expression.
+// The callee is either a declared function or the property of a declared object, such as anomaly.mad.
+func resolveCalleeType(callee Expression) (Type, string, error) {
+	if m, ok := callee.(*MemberExpression); ok {
+		objTyp, name, err := resolveCalleeType(m.Object)
+		if err != nil {
+			return nil, "", err
+		}
+		if objTyp.Kind() != Object {
+			return nil, "", fmt.Errorf("cannot access property %q of non object %q", m.Property, objTyp.Kind())
+		}
+		typ := objTyp.PropertyType(m.Property)
+		if typ.Kind() == Invalid {
+			return nil, "", fmt.Errorf("%s has no property %q", name, m.Property)
+		}
+		return typ, name + "." + m.Property, nil
+	}
+	decl, err := resolveDeclaration(callee)
+	if err != nil {
+		return nil, "", err
+	}
+	return decl.InitType(), decl.ID().Name, nil
+}
+
 // resolveDeclaration traverse the expression until a variable declaration is found for the expression.
 func resolveDeclaration(n Node) (VariableDeclaration, error) {
 	switch n := n.(type) {
@@ -1324,16 +1350,21 @@ func analyzeDateTimeLiteral(lit *ast.DateTimeLiteral, declarations DeclarationSc
 	}, nil
 }
 func analyzeDurationLiteral(lit *ast.DurationLiteral, declarations DeclarationScope) (*DurationLiteral, error) {
-	var duration time.Duration
+	var (
+		duration time.Duration
+		months   int64
+	)
 	for _, d := range lit.Values {
-		dur, err := toDuration(d)
+		mo, dur, err := toDuration(d)
 		if err != nil {
 			return nil, err
 		}
+		months += mo
 		duration += dur
 	}
 	return &DurationLiteral{
-		Value: duration,
+		Value:  duration,
+		Months: months,
 	}, nil
 }
 func analyzeFloatLiteral(lit *ast.FloatLiteral, declarations DeclarationScope) (*FloatLiteral, error) {
@@ -1366,23 +1397,17 @@ func analyzeRegexpLiteral(lit *ast.RegexpLiteral, declarations DeclarationScope)
 		Value: lit.Value,
 	}, nil
 }
-func toDuration(lit ast.Duration) (time.Duration, error) {
-	// TODO: This is temporary code until we have proper duration type that takes different months, DST, etc into account
-	var dur time.Duration
-	var err error
+
+// toDuration splits a duration into calendar months and a fixed duration.
+func toDuration(lit ast.Duration) (int64, time.Duration, error) {
 	mag := lit.Magnitude
 	unit := lit.Unit
 
 	switch unit {
 	case "y":
-		mag *= 12
-		unit = "mo"
-		fallthrough
+		return mag * 12, 0, nil
 	case "mo":
-		const weeksPerMonth = 365.25 / 12 / 7
-		mag = int64(float64(mag) * weeksPerMonth)
-		unit = "w"
-		fallthrough
+		return mag, 0, nil
 	case "w":
 		mag *= 7
 		unit = "d"
@@ -1393,7 +1418,7 @@ func toDuration(lit ast.Duration) (time.Duration, error) {
 		fallthrough
 	default:
 		// ParseDuration will handle h, m, s, ms, us, ns.
-		dur, err = time.ParseDuration(strconv.FormatInt(mag, 10) + unit)
+		dur, err := time.ParseDuration(strconv.FormatInt(mag, 10) + unit)
+		return 0, dur, err
 	}
-	return dur, err
 }
@@ -203,6 +203,33 @@ func TestNew(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "calendar duration",
+			program: &ast.Program{
+				Body: []ast.Statement{
+					&ast.ExpressionStatement{
+						Expression: &ast.DurationLiteral{
+							Values: []ast.Duration{
+								{Magnitude: 1, Unit: "y"},
+								{Magnitude: 2, Unit: "mo"},
+								{Magnitude: 1, Unit: "w"},
+								{Magnitude: 3, Unit: "h"},
+							},
+						},
+					},
+				},
+			},
+			want: &semantic.Program{
+				Body: []semantic.Statement{
+					&semantic.ExpressionStatement{
+						Expression: &semantic.DurationLiteral{
+							Value:  7*24*time.Hour + 3*time.Hour,
+							Months: 14,
+						},
+					},
+				},
+			},
+		},
 	}
 	for _, tc := range testCases {
 		tc := tc
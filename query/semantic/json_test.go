@@ -236,6 +236,14 @@ func TestJSONMarshal(t *testing.T) {
 			},
 			want: `{"type":"DurationLiteral","value":"1h1m0s"}`,
 		},
+		{
+			name: "calendar duration literal",
+			node: &semantic.DurationLiteral{
+				Value:  time.Hour,
+				Months: 1,
+			},
+			want: `{"type":"DurationLiteral","months":1,"value":"1h0m0s"}`,
+		},
 		{
 			name: "datetime literal",
 			node: &semantic.DateTimeLiteral{
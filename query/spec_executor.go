@@ -0,0 +1,107 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/EMCECS/influx/query/interpreter"
+	"github.com/EMCECS/influx/query/semantic"
+	"github.com/EMCECS/influx/query/values"
+)
+
+// SpecExecutor executes query specs while a script is compiled.
+// It lets functions such as tableFind use the tables of one part of a script as values in the rest of it.
+type SpecExecutor interface {
+	// ExecuteSpec plans and executes the spec, returning its results by name.
+	// The results are read before ctx is done, the execution releases its resources once it is done.
+	ExecuteSpec(ctx context.Context, spec *Spec) (map[string]Result, error)
+}
+
+type specExecutorKey struct{}
+
+// ContextWithSpecExecutor returns a context whose compilations execute specs with e.
+func ContextWithSpecExecutor(ctx context.Context, e SpecExecutor) context.Context {
+	return context.WithValue(ctx, specExecutorKey{}, e)
+}
+
+// SpecExecutorFromContext returns the spec executor of the context, or nil if there is none.
+func SpecExecutorFromContext(ctx context.Context) SpecExecutor {
+	e, _ := ctx.Value(specExecutorKey{}).(SpecExecutor)
+	return e
+}
+
+type interpreterKey struct{}
+
+// ExecuteTableObject executes the operations that produce the table object during a compilation,
+// and returns the result of the last operation.
+// The spec is executed at the now time of the compiled script.
+func ExecuteTableObject(ctx context.Context, t *TableObject) (Result, error) {
+	e := SpecExecutorFromContext(ctx)
+	if e == nil {
+		return nil, errors.New("tables cannot be read while compiling this query, no spec executor is available")
+	}
+	spec := t.ToSpec()
+	if itrp, ok := ctx.Value(interpreterKey{}).(*interpreter.Interpreter); ok {
+		nowValue, err := itrp.Option(nowOption).Function().Call(nil)
+		if err != nil {
+			return nil, err
+		}
+		spec.Now = nowValue.Time().Time()
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	results, err := e.ExecuteSpec(ctx, spec)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) != 1 {
+		return nil, fmt.Errorf("expected a single result, got %d", len(results))
+	}
+	for _, r := range results {
+		return r, nil
+	}
+	return nil, nil
+}
+
+// ContextFunction is a builtin function called with the context of the compilation.
+type ContextFunction func(ctx context.Context, args Arguments) (values.Value, error)
+
+type contextFunction struct {
+	name string
+	t    semantic.Type
+	f    ContextFunction
+}
+
+var builtinContextFunctions = make(map[string]contextFunction)
+
+// RegisterContextFunction adds a new builtin function that is called with the context of the compilation,
+// such as functions that execute queries with ExecuteTableObject.
+// The function is called with a background context outside of Compile.
+func RegisterContextFunction(name string, f ContextFunction, sig semantic.FunctionSignature) {
+	cf := contextFunction{
+		name: name,
+		t:    semantic.NewFunctionType(sig),
+		f:    f,
+	}
+	builtinContextFunctions[name] = cf
+	RegisterBuiltInValue(name, cf.bind(context.Background()))
+}
+
+func (cf contextFunction) bind(ctx context.Context) values.Function {
+	call := func(args values.Object) (values.Value, error) {
+		return interpreter.DoFunctionCall(func(args interpreter.Arguments) (values.Value, error) {
+			return cf.f(ctx, Arguments{Arguments: args})
+		}, args)
+	}
+	return values.NewFunction(cf.name, cf.t, call, false)
+}
+
+// bindContextFunctions binds the context functions of the interpreter to the context of a compilation.
+func bindContextFunctions(ctx context.Context, itrp *interpreter.Interpreter) {
+	ctx = context.WithValue(ctx, interpreterKey{}, itrp)
+	for name, cf := range builtinContextFunctions {
+		itrp.SetVar(name, cf.bind(ctx))
+	}
+}
@@ -19,6 +19,8 @@ type object struct {
 	values        map[string]Value
 	propertyTypes map[string]semantic.Type
 	typ           atomic.Value // semantic.Type
+	// keys are the names of the properties in the order they were first set.
+	keys []string
 }
 
 func NewObject() *object {
@@ -39,6 +41,9 @@ func (o *object) Type() semantic.Type {
 }
 
 func (o *object) Set(name string, v Value) {
+	if _, ok := o.values[name]; !ok {
+		o.keys = append(o.keys, name)
+	}
 	o.values[name] = v
 	if o.propertyTypes[name] != v.Type() {
 		o.setPropertyType(name, v.Type())
@@ -58,9 +63,10 @@ func (o *object) setPropertyType(name string, t semantic.Type) {
 	o.typ.Store(typ)
 }
 
+// Range calls f for each property in the order the properties were first set.
 func (o *object) Range(f func(name string, v Value)) {
-	for k, v := range o.values {
-		f(k, v)
+	for _, k := range o.keys {
+		f(k, o.values[k])
 	}
 }
 
@@ -1,7 +1,10 @@
 package values
 
 import (
+	"fmt"
 	"time"
+
+	"github.com/EMCECS/influx/query/semantic"
 )
 
 type Time int64
@@ -78,3 +81,48 @@ func ParseDuration(s string) (Duration, error) {
 	}
 	return Duration(d), nil
 }
+
+// averageMonth is the average length of a month in the Gregorian calendar,
+// which repeats every 400 years, that is 4800 months or 146097 days.
+const averageMonth = Duration(146097 * (24 * time.Hour / 4800))
+
+// CalendarDuration is a duration made of a number of calendar months and a fixed duration.
+// The length of a month depends on the date the duration is added to.
+type CalendarDuration struct {
+	Months   int64
+	Duration Duration
+}
+
+// Approximate returns the fixed duration closest to d on average.
+func (d CalendarDuration) Approximate() Duration {
+	return Duration(d.Months)*averageMonth + d.Duration
+}
+
+func (d CalendarDuration) String() string {
+	if d.Months == 0 {
+		return d.Duration.String()
+	}
+	return fmt.Sprintf("%dmo%v", d.Months, d.Duration)
+}
+
+// NewCalendarDurationValue returns a duration value that keeps its calendar months.
+// Reading the value with Duration returns its approximate fixed duration.
+func NewCalendarDurationValue(v CalendarDuration) Value {
+	if v.Months == 0 {
+		return NewDurationValue(v.Duration)
+	}
+	return value{
+		t: semantic.Duration,
+		v: v,
+	}
+}
+
+// CalendarDurationOf returns the calendar months and the fixed duration of a duration value.
+func CalendarDurationOf(v Value) CalendarDuration {
+	if vv, ok := v.(value); ok {
+		if d, ok := vv.v.(CalendarDuration); ok {
+			return d
+		}
+	}
+	return CalendarDuration{Duration: v.Duration()}
+}
@@ -62,6 +62,9 @@ func (v value) Time() Time {
 }
 func (v value) Duration() Duration {
 	CheckKind(v.t.Kind(), semantic.Duration)
+	if d, ok := v.v.(CalendarDuration); ok {
+		return d.Approximate()
+	}
 	return v.v.(Duration)
 }
 func (v value) Regexp() *regexp.Regexp {
@@ -98,7 +101,7 @@ func (v value) Equal(r Value) bool {
 	case semantic.Time:
 		return v.Time() == r.Time()
 	case semantic.Duration:
-		return v.Duration() == r.Duration()
+		return CalendarDurationOf(v) == CalendarDurationOf(r)
 	case semantic.Regexp:
 		return v.Regexp().String() == r.Regexp().String()
 	case semantic.Object:
@@ -107,6 +110,8 @@ func (v value) Equal(r Value) bool {
 		return v.Array().Equal(r.Array())
 	case semantic.Function:
 		return v.Function().Equal(r.Function())
+	case semantic.Nil:
+		return true
 	default:
 		return false
 	}
@@ -119,6 +124,9 @@ func (v value) String() string {
 // InvalidValue is a non nil value who's type is semantic.Invalid
 var InvalidValue = value{t: semantic.Invalid}
 
+// Null is the value of a missing value in a table, its type is semantic.Nil.
+var Null = value{t: semantic.Nil}
+
 func NewValue(v interface{}, k semantic.Kind) (Value, error) {
 	switch k {
 	case semantic.String:
@@ -549,8 +549,9 @@ func UpdateOrganization(
 	t *testing.T,
 ) {
 	type args struct {
-		name string
-		id   platform.ID
+		name        string
+		queryQuotas *platform.QueryQuotas
+		id          platform.ID
 	}
 	type wants struct {
 		err          error
@@ -588,6 +589,36 @@ func UpdateOrganization(
 				},
 			},
 		},
+		{
+			name: "update query quotas",
+			fields: OrganizationFields{
+				Organizations: []*platform.Organization{
+					{
+						ID:   platform.ID("1"),
+						Name: "organization1",
+					},
+				},
+			},
+			args: args{
+				id: platform.ID("1"),
+				queryQuotas: &platform.QueryQuotas{
+					ConcurrencyQuota: 4,
+					MemoryBytesQuota: 1 << 30,
+					Weight:           2,
+				},
+			},
+			wants: wants{
+				organization: &platform.Organization{
+					ID:   platform.ID("1"),
+					Name: "organization1",
+					QueryQuotas: &platform.QueryQuotas{
+						ConcurrencyQuota: 4,
+						MemoryBytesQuota: 1 << 30,
+						Weight:           2,
+					},
+				},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -600,6 +631,7 @@ func UpdateOrganization(
 			if tt.args.name != "" {
 				upd.Name = &tt.args.name
 			}
+			upd.QueryQuotas = tt.args.queryQuotas
 
 			organization, err := s.UpdateOrganization(ctx, tt.args.id, upd)
 			if (err != nil) != (tt.wants.err != nil) {